@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/buildinfo"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
+)
+
+var (
+	httpListenAddr = flag.String("httpListenAddr", ":8480", "Address to listen for http connections")
+	storageNodes   = flag.String("storageNode", "", "Comma-separated list of vmstorage nodes' addresses. Each address must point to vmstorage -vminsertAddr. For example, `vmstorage-1:8400,vmstorage-2:8400`")
+)
+
+func main() {
+	flag.Parse()
+	buildinfo.Init()
+	logger.Init()
+
+	addrs := flagutil.ParseArray(*storageNodes)
+	if len(addrs) == 0 {
+		logger.Fatalf("missing -storageNode arg")
+	}
+	logger.Infof("starting vminsert at %q...", *httpListenAddr)
+	startTime := time.Now()
+	netstorage.InitStorageNodes(addrs)
+	vminsert.Init()
+
+	go httpserver.Serve(*httpListenAddr, requestHandler)
+	logger.Infof("started vminsert in %s", time.Since(startTime))
+
+	sig := procutil.WaitForSigterm()
+	logger.Infof("received signal %s", sig)
+
+	logger.Infof("gracefully shutting down http service at %q", *httpListenAddr)
+	startTime = time.Now()
+	if err := httpserver.Stop(*httpListenAddr); err != nil {
+		logger.Fatalf("cannot stop http service: %s", err)
+	}
+	vminsert.Stop()
+	logger.Infof("successfully shut down http service in %s", time.Since(startTime))
+
+	netstorage.Stop()
+
+	logger.Infof("the vminsert has been stopped in %s", time.Since(startTime))
+}
+
+func requestHandler(w http.ResponseWriter, r *http.Request) bool {
+	return vminsert.RequestHandler(w, r)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/buildinfo"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
+)
+
+var (
+	httpListenAddr = flag.String("httpListenAddr", ":8481", "Address to listen for http connections")
+	storageNodes   = flag.String("storageNode", "", "Comma-separated list of vmstorage nodes' addresses. Each address must point to vmstorage -vmselectAddr. For example, `vmstorage-1:8401,vmstorage-2:8401`")
+)
+
+func main() {
+	flag.Parse()
+	buildinfo.Init()
+	logger.Init()
+
+	addrs := flagutil.ParseArray(*storageNodes)
+	if len(addrs) == 0 {
+		logger.Fatalf("missing -storageNode arg")
+	}
+	logger.Infof("starting vmselect at %q...", *httpListenAddr)
+	startTime := time.Now()
+	netstorage.InitStorageNodes(addrs)
+	vmselect.Init()
+
+	go httpserver.Serve(*httpListenAddr, requestHandler)
+	logger.Infof("started vmselect in %s", time.Since(startTime))
+
+	sig := procutil.WaitForSigterm()
+	logger.Infof("received signal %s", sig)
+
+	logger.Infof("gracefully shutting down http service at %q", *httpListenAddr)
+	startTime = time.Now()
+	if err := httpserver.Stop(*httpListenAddr); err != nil {
+		logger.Fatalf("cannot stop http service: %s", err)
+	}
+	logger.Infof("successfully shut down http service in %s", time.Since(startTime))
+
+	vmselect.Stop()
+	netstorage.Stop()
+
+	logger.Infof("the vmselect has been stopped in %s", time.Since(startTime))
+}
+
+func requestHandler(w http.ResponseWriter, r *http.Request) bool {
+	return vmselect.RequestHandler(w, r)
+}
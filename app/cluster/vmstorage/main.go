@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage/transport"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/buildinfo"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
+)
+
+var (
+	httpListenAddr = flag.String("httpListenAddr", ":8482", "Address to listen for http connections")
+	vminsertAddr   = flag.String("vminsertAddr", ":8400", "TCP address to accept connections from vminsert services")
+	vmselectAddr   = flag.String("vmselectAddr", ":8401", "TCP address to accept connections from vmselect services")
+)
+
+func main() {
+	flag.Parse()
+	buildinfo.Init()
+	logger.Init()
+
+	logger.Infof("starting vmstorage at %q...", *httpListenAddr)
+	startTime := time.Now()
+	vmstorage.Init()
+
+	srv, err := transport.NewServer(*vminsertAddr, *vmselectAddr)
+	if err != nil {
+		logger.Fatalf("cannot create a server with vminsertAddr=%s, vmselectAddr=%s: %s", *vminsertAddr, *vmselectAddr, err)
+	}
+	go srv.RunVMInsert()
+	go srv.RunVMSelect()
+
+	go httpserver.Serve(*httpListenAddr, requestHandler)
+	logger.Infof("started vmstorage in %s", time.Since(startTime))
+
+	sig := procutil.WaitForSigterm()
+	logger.Infof("received signal %s", sig)
+
+	logger.Infof("gracefully shutting down http service at %q", *httpListenAddr)
+	startTime = time.Now()
+	if err := httpserver.Stop(*httpListenAddr); err != nil {
+		logger.Fatalf("cannot stop http service: %s", err)
+	}
+	logger.Infof("successfully shut down http service in %s", time.Since(startTime))
+
+	logger.Infof("gracefully shutting down the service")
+	startTime = time.Now()
+	srv.MustClose()
+	logger.Infof("successfully shut down the service in %s", time.Since(startTime))
+
+	vmstorage.Stop()
+
+	logger.Infof("the vmstorage has been stopped")
+}
+
+func requestHandler(w http.ResponseWriter, r *http.Request) bool {
+	return vmstorage.RequestHandler(w, r)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
@@ -25,6 +26,7 @@ func main() {
 	vmstorage.Init()
 	vmselect.Init()
 	vminsert.Init()
+	vmalert.Init()
 
 	go httpserver.Serve(*httpListenAddr, requestHandler)
 	logger.Infof("started VictoriaMetrics in %s", time.Since(startTime))
@@ -37,6 +39,8 @@ func main() {
 	if err := httpserver.Stop(*httpListenAddr); err != nil {
 		logger.Fatalf("cannot stop the webservice: %s", err)
 	}
+	// Stop vmalert before vminsert, since vmalert writes rule results via vminsert.
+	vmalert.Stop()
 	vminsert.Stop()
 	logger.Infof("successfully shut down the webservice in %s", time.Since(startTime))
 
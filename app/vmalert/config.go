@@ -0,0 +1,172 @@
+package vmalert
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/promql"
+	"gopkg.in/yaml.v2"
+)
+
+// ruleFile represents Prometheus rule file.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/recording_rules/#configuring-rules
+type ruleFile struct {
+	Groups []GroupConfig `yaml:"groups"`
+}
+
+// GroupConfig represents a group of rules evaluated at the same interval.
+type GroupConfig struct {
+	Name     string        `yaml:"name"`
+	Interval time.Duration `yaml:"interval"`
+	Rules    []RuleConfig  `yaml:"rules"`
+}
+
+// RuleConfig represents either recording or alerting rule.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/recording_rules/#rule
+// and https://prometheus.io/docs/prometheus/latest/configuration/alerting_rules/
+type RuleConfig struct {
+	Record      string            `yaml:"record"`
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         time.Duration     `yaml:"for"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// loadGroups loads rule groups from files matching the given patterns.
+func loadGroups(patterns []string) ([]GroupConfig, error) {
+	var gcs []GroupConfig
+	groupNames := make(map[string]string)
+	for _, pattern := range patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %s", pattern, err)
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no files found for pattern %q", pattern)
+		}
+		for _, path := range paths {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read rules from %q: %s", path, err)
+			}
+			groups, err := parseGroups(data)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse rules from %q: %s", path, err)
+			}
+			for _, gc := range groups {
+				if prevPath, ok := groupNames[gc.Name]; ok {
+					return nil, fmt.Errorf("duplicate group name %q in %q; the group is already defined in %q", gc.Name, path, prevPath)
+				}
+				groupNames[gc.Name] = path
+			}
+			gcs = append(gcs, groups...)
+		}
+	}
+	return gcs, nil
+}
+
+func parseGroups(data []byte) ([]GroupConfig, error) {
+	var rf ruleFile
+	if err := yaml.UnmarshalStrict(data, &rf); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal data: %s", err)
+	}
+	groupNames := make(map[string]bool, len(rf.Groups))
+	for i := range rf.Groups {
+		gc := &rf.Groups[i]
+		if len(gc.Name) == 0 {
+			return nil, fmt.Errorf("missing `name` for group #%d", i+1)
+		}
+		if groupNames[gc.Name] {
+			return nil, fmt.Errorf("duplicate group name %q", gc.Name)
+		}
+		groupNames[gc.Name] = true
+		if gc.Interval < 0 {
+			return nil, fmt.Errorf("`interval` cannot be negative for group %q; got %s", gc.Name, gc.Interval)
+		}
+		for j := range gc.Rules {
+			if err := gc.Rules[j].validate(); err != nil {
+				return nil, fmt.Errorf("invalid rule #%d in group %q: %s", j+1, gc.Name, err)
+			}
+		}
+	}
+	return rf.Groups, nil
+}
+
+func (rc *RuleConfig) validate() error {
+	if len(rc.Record) == 0 && len(rc.Alert) == 0 {
+		return fmt.Errorf("either `record` or `alert` must be set")
+	}
+	if len(rc.Record) > 0 && len(rc.Alert) > 0 {
+		return fmt.Errorf("only one of `record` or `alert` must be set")
+	}
+	if len(rc.Expr) == 0 {
+		return fmt.Errorf("missing `expr`")
+	}
+	if _, err := promql.ExpandWithExprs(rc.Expr); err != nil {
+		return fmt.Errorf("invalid `expr` %q: %s", rc.Expr, err)
+	}
+	for name := range rc.Labels {
+		if name == "__name__" {
+			return fmt.Errorf("`labels` cannot contain `__name__`")
+		}
+	}
+	if len(rc.Record) > 0 {
+		if !isValidMetricName(rc.Record) {
+			return fmt.Errorf("invalid metric name in `record`: %q", rc.Record)
+		}
+		if rc.For != 0 {
+			return fmt.Errorf("`for` cannot be set for recording rule %q", rc.Record)
+		}
+		if len(rc.Annotations) > 0 {
+			return fmt.Errorf("`annotations` cannot be set for recording rule %q", rc.Record)
+		}
+		return nil
+	}
+	if rc.For < 0 {
+		return fmt.Errorf("`for` cannot be negative; got %s", rc.For)
+	}
+	for name, text := range rc.Labels {
+		if _, err := newTemplate(name, text); err != nil {
+			return fmt.Errorf("invalid template in label %q: %s", name, err)
+		}
+	}
+	for name, text := range rc.Annotations {
+		if _, err := newTemplate(name, text); err != nil {
+			return fmt.Errorf("invalid template in annotation %q: %s", name, err)
+		}
+	}
+	return nil
+}
+
+func isValidMetricName(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' || i > 0 && c >= '0' && c <= '9' {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// newTemplate returns template for the given text.
+//
+// The text may refer to `$labels` and `$value` like in Prometheus.
+func newTemplate(name, text string) (*template.Template, error) {
+	if !strings.Contains(text, "{{") {
+		return nil, nil
+	}
+	return template.New(name).Option("missingkey=zero").Parse(templateHeader + text)
+}
+
+const templateHeader = "{{$labels := .Labels}}{{$value := .Value}}"
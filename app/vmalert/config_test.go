@@ -0,0 +1,152 @@
+package vmalert
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseGroupsFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		if _, err := parseGroups([]byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", data)
+		}
+	}
+
+	// Invalid yaml
+	f("foo bar")
+
+	// Unknown field
+	f(`
+groups:
+- name: foo
+  foo: bar
+`)
+
+	// Missing group name
+	f(`
+groups:
+- rules:
+  - record: foo
+    expr: bar
+`)
+
+	// Duplicate group name
+	f(`
+groups:
+- name: foo
+- name: foo
+`)
+
+	// Missing record and alert
+	f(`
+groups:
+- name: foo
+  rules:
+  - expr: bar
+`)
+
+	// Both record and alert
+	f(`
+groups:
+- name: foo
+  rules:
+  - record: foo
+    alert: foo
+    expr: bar
+`)
+
+	// Missing expr
+	f(`
+groups:
+- name: foo
+  rules:
+  - record: foo
+`)
+
+	// Invalid expr
+	f(`
+groups:
+- name: foo
+  rules:
+  - record: foo
+    expr: sum(
+`)
+
+	// Invalid record name
+	f(`
+groups:
+- name: foo
+  rules:
+  - record: foo-bar
+    expr: bar
+`)
+
+	// `for` in recording rule
+	f(`
+groups:
+- name: foo
+  rules:
+  - record: foo
+    expr: bar
+    for: 1m
+`)
+
+	// Invalid annotation template
+	f(`
+groups:
+- name: foo
+  rules:
+  - alert: foo
+    expr: bar
+    annotations:
+      summary: "{{ $labels.foo "
+`)
+}
+
+func TestParseGroupsSuccess(t *testing.T) {
+	data := `
+groups:
+- name: example
+  interval: 30s
+  rules:
+  - record: job:http_requests:rate5m
+    expr: sum(rate(http_requests_total[5m])) by (job)
+  - alert: HighErrorRate
+    expr: job:http_errors:ratio > 0.5
+    for: 10m
+    labels:
+      severity: page
+    annotations:
+      summary: "High error rate for {{ $labels.job }}: {{ $value }}"
+`
+	gcs, err := parseGroups([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	gcsExpected := []GroupConfig{{
+		Name:     "example",
+		Interval: 30 * time.Second,
+		Rules: []RuleConfig{
+			{
+				Record: "job:http_requests:rate5m",
+				Expr:   "sum(rate(http_requests_total[5m])) by (job)",
+			},
+			{
+				Alert: "HighErrorRate",
+				Expr:  "job:http_errors:ratio > 0.5",
+				For:   10 * time.Minute,
+				Labels: map[string]string{
+					"severity": "page",
+				},
+				Annotations: map[string]string{
+					"summary": "High error rate for {{ $labels.job }}: {{ $value }}",
+				},
+			},
+		},
+	}}
+	if !reflect.DeepEqual(gcs, gcsExpected) {
+		t.Fatalf("unexpected groups;\ngot\n%+v\nwant\n%+v", gcs, gcsExpected)
+	}
+}
@@ -0,0 +1,146 @@
+package vmalert
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// group evaluates rules sequentially at the given interval.
+type group struct {
+	name     string
+	interval time.Duration
+	rules    []rule
+
+	// restoreLookback is the lookback for `ALERTS_FOR_STATE` series when restoring alerts state on start.
+	restoreLookback time.Duration
+
+	query  querier
+	insert func(ss []series, timestamp int64) error
+
+	// notify is called with firing and resolved alerts. It may be nil.
+	notify func(nas []notifierAlert) error
+}
+
+func newGroup(gc *GroupConfig, defaultInterval time.Duration) *group {
+	interval := gc.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	rules := make([]rule, 0, len(gc.Rules))
+	for i := range gc.Rules {
+		rules = append(rules, newRule(&gc.Rules[i]))
+	}
+	return &group{
+		name:     gc.Name,
+		interval: interval,
+		rules:    rules,
+	}
+}
+
+func (g *group) run(stopCh <-chan struct{}) {
+	// Spread evaluations for distinct groups over the interval.
+	randSleep := time.Duration(rand.Int63n(int64(g.interval)))
+	timer := time.NewTimer(randSleep)
+	select {
+	case <-stopCh:
+		timer.Stop()
+		return
+	case <-timer.C:
+	}
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	t := time.Now()
+	g.restore(t.UnixNano() / 1e6)
+	for {
+		g.eval(t.UnixNano() / 1e6)
+		select {
+		case <-stopCh:
+			return
+		case t = <-ticker.C:
+		}
+	}
+}
+
+// eval evaluates all the rules in g at the given timestamp in milliseconds.
+func (g *group) eval(timestamp int64) {
+	startTime := time.Now()
+	defer evalDuration.UpdateDuration(startTime)
+
+	step := g.interval.Nanoseconds() / 1e6
+	var ss []series
+	var nas []notifierAlert
+	for _, r := range g.rules {
+		evals.Inc()
+		rss, rnas, err := r.exec(g.query, timestamp, step)
+		if err != nil {
+			evalErrors.Inc()
+			logger.Errorf("group %q: cannot evaluate %s: %s", g.name, r, err)
+			continue
+		}
+		ss = append(ss, rss...)
+		nas = append(nas, rnas...)
+	}
+
+	if len(ss) > 0 {
+		if err := g.insert(ss, timestamp); err != nil {
+			logger.Errorf("group %q: cannot store %d rows: %s", g.name, len(ss), err)
+		} else {
+			rowsInserted.Add(len(ss))
+		}
+	}
+	if g.notify != nil {
+		if err := g.notify(nas); err != nil {
+			logger.Errorf("group %q: cannot send %d alerts: %s", g.name, len(nas), err)
+		}
+	}
+}
+
+// restore restores the state of alerting rules in g from `ALERTS_FOR_STATE` series stored before the given timestamp.
+//
+// It does nothing if g.restoreLookback is zero.
+func (g *group) restore(timestamp int64) {
+	if g.restoreLookback <= 0 {
+		return
+	}
+	for _, r := range g.rules {
+		ar, ok := r.(*alertingRule)
+		if !ok {
+			continue
+		}
+		if err := ar.restore(g.query, timestamp, g.restoreLookback); err != nil {
+			logger.Errorf("group %q: cannot restore state for %s: %s", g.name, ar, err)
+		}
+	}
+}
+
+// insertSeries writes ss with the given timestamp to the default tenant.
+//
+// The series are written via the common ingestion path, so relabeling, timestamp limits
+// and stream aggregation rules are applied to them like to the ingested data.
+func insertSeries(ss []series, timestamp int64) error {
+	ic := &common.InsertCtx{}
+	ic.Reset(len(ss), rowsRejected)
+	for i := range ss {
+		s := &ss[i]
+		ic.Labels = ic.Labels[:0]
+		for k, v := range s.labels {
+			ic.AddLabel(k, v)
+		}
+		ic.WriteDataPoint(auth.DefaultToken, nil, ic.Labels, timestamp, s.value)
+	}
+	return ic.FlushBufs()
+}
+
+var (
+	evals        = metrics.NewCounter(`vm_rules_evaluations_total`)
+	evalErrors   = metrics.NewCounter(`vm_rules_evaluation_errors_total`)
+	evalDuration = metrics.NewSummary(`vm_rules_group_evaluation_duration_seconds`)
+	rowsInserted = metrics.NewCounter(`vm_rows_inserted_total{type="rules"}`)
+	rowsRejected = common.NewRejectedRowsCounters("rules")
+)
@@ -0,0 +1,84 @@
+package vmalert
+
+import (
+	"flag"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/promql"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+var (
+	ruleFiles = flag.String("rule", "", "Comma-separated list of paths to files with alerting and recording rules in Prometheus format. "+
+		"Paths may contain glob patterns such as `/etc/rules/*.yml`. Rules are evaluated against the default tenant. Rules aren't evaluated if empty")
+	evaluationInterval = flag.Duration("rule.evaluationInterval", time.Minute, "Evaluation interval for rule groups without `interval`")
+	evaluationTimeout  = flag.Duration("rule.evaluationTimeout", 30*time.Second, "The maximum duration for a single rule evaluation")
+	notifierURL        = flag.String("notifier.url", "", "Alertmanager-compatible URL for sending firing alerts in JSON, e.g. `http://alertmanager:9093/api/v2/alerts`. "+
+		"Alerts aren't sent if empty")
+	notifierTimeout = flag.Duration("notifier.timeout", 10*time.Second, "Timeout for sending alerts to -notifier.url")
+	restoreLookback = flag.Duration("rule.restoreLookback", time.Hour, "The maximum age of `ALERTS_FOR_STATE` samples used for restoring the state of alerts on start. "+
+		"Alerts with `for` become pending again after start if zero")
+)
+
+// Init starts rules evaluation.
+//
+// It does nothing if `-rule` is empty.
+func Init() {
+	if len(*ruleFiles) == 0 {
+		return
+	}
+	gcs, err := loadGroups(strings.Split(*ruleFiles, ","))
+	if err != nil {
+		logger.Fatalf("cannot load rules from -rule=%q: %s", *ruleFiles, err)
+	}
+	var n *notifier
+	if len(*notifierURL) > 0 {
+		n = newNotifier(*notifierURL, *notifierTimeout)
+	}
+	stopCh = make(chan struct{})
+	for i := range gcs {
+		g := newGroup(&gcs[i], *evaluationInterval)
+		g.query = execQuery
+		g.insert = insertSeries
+		g.restoreLookback = *restoreLookback
+		if n != nil {
+			g.notify = n.send
+		}
+		groupsWG.Add(1)
+		go func() {
+			defer groupsWG.Done()
+			g.run(stopCh)
+		}()
+	}
+	logger.Infof("started evaluation of %d rule groups from -rule=%q", len(gcs), *ruleFiles)
+}
+
+// Stop stops rules evaluation.
+func Stop() {
+	if stopCh == nil {
+		return
+	}
+	close(stopCh)
+	groupsWG.Wait()
+	stopCh = nil
+}
+
+var (
+	stopCh   chan struct{}
+	groupsWG sync.WaitGroup
+)
+
+func execQuery(q string, timestamp, step int64) ([]netstorage.Result, error) {
+	ec := promql.EvalConfig{
+		AuthToken: auth.DefaultToken,
+		Start:     timestamp,
+		End:       timestamp,
+		Step:      step,
+		Deadline:  netstorage.NewDeadline(*evaluationTimeout),
+	}
+	return promql.Exec(&ec, q, true)
+}
@@ -0,0 +1,69 @@
+package vmalert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// notifierAlert is an alert in the format accepted by Alertmanager.
+//
+// See https://prometheus.io/docs/alerting/clients/
+type notifierAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    string            `json:"startsAt"`
+	EndsAt      string            `json:"endsAt"`
+}
+
+// notifier sends alerts to Alertmanager-compatible endpoint.
+type notifier struct {
+	url string
+	hc  *http.Client
+}
+
+func newNotifier(url string, timeout time.Duration) *notifier {
+	return &notifier{
+		url: url,
+		hc: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+// send sends nas to n.
+func (n *notifier) send(nas []notifierAlert) error {
+	if len(nas) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(nas)
+	if err != nil {
+		return fmt.Errorf("cannot marshal alerts: %s", err)
+	}
+	resp, err := n.hc.Post(n.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		notifierErrors.Inc()
+		return fmt.Errorf("cannot send alerts to %q: %s", n.url, err)
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode/100 != 2 {
+		notifierErrors.Inc()
+		return fmt.Errorf("unexpected status code returned from %q: %d; expecting 2xx", n.url, resp.StatusCode)
+	}
+	alertsSent.Add(len(nas))
+	return nil
+}
+
+var (
+	alertsSent     = metrics.NewCounter(`vm_rules_alerts_sent_total`)
+	notifierErrors = metrics.NewCounter(`vm_rules_notifier_errors_total`)
+)
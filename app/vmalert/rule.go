@@ -0,0 +1,334 @@
+package vmalert
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// querier must return instant query results for q at the given timestamp.
+//
+// The step is used as the default lookbehind window for rollups.
+type querier func(q string, timestamp, step int64) ([]netstorage.Result, error)
+
+// series is a single time series produced by rules evaluation.
+type series struct {
+	// labels must contain `__name__`.
+	labels map[string]string
+	value  float64
+}
+
+// rule is either recordingRule or alertingRule.
+type rule interface {
+	// exec executes the rule at the given timestamp.
+	//
+	// It returns the produced time series and alerts to send to notifier.
+	exec(q querier, timestamp, step int64) ([]series, []notifierAlert, error)
+
+	// String returns human-readable rule name.
+	String() string
+}
+
+func newRule(rc *RuleConfig) rule {
+	if len(rc.Record) > 0 {
+		return &recordingRule{
+			name:   rc.Record,
+			expr:   rc.Expr,
+			labels: rc.Labels,
+		}
+	}
+	return &alertingRule{
+		name:                rc.Alert,
+		expr:                rc.Expr,
+		forDuration:         rc.For,
+		labelTemplates:      mustNewTemplates(rc.Labels),
+		annotationTemplates: mustNewTemplates(rc.Annotations),
+		alerts:              make(map[string]*alert),
+	}
+}
+
+// recordingRule stores expr results under a new metric name.
+type recordingRule struct {
+	name   string
+	expr   string
+	labels map[string]string
+}
+
+func (rr *recordingRule) String() string {
+	return "recording rule " + rr.name
+}
+
+func (rr *recordingRule) exec(q querier, timestamp, step int64) ([]series, []notifierAlert, error) {
+	rs, err := q(rr.expr, timestamp, step)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot execute %q: %s", rr.expr, err)
+	}
+	ss := make([]series, 0, len(rs))
+	for i := range rs {
+		r := &rs[i]
+		labels := labelsFromMetricName(&r.MetricName)
+		for k, v := range rr.labels {
+			labels[k] = v
+		}
+		labels["__name__"] = rr.name
+		ss = append(ss, series{
+			labels: labels,
+			value:  r.Values[0],
+		})
+	}
+	return ss, nil, nil
+}
+
+type alertState int
+
+const (
+	statePending alertState = iota
+	stateFiring
+)
+
+func (as alertState) String() string {
+	if as == stateFiring {
+		return "firing"
+	}
+	return "pending"
+}
+
+// alert is an active alert produced by alertingRule.
+type alert struct {
+	labels      map[string]string
+	annotations map[string]string
+	state       alertState
+	value       float64
+
+	// activeAt is the timestamp in milliseconds when the alert became active.
+	activeAt int64
+}
+
+// alertingRule generates alerts for non-empty expr results.
+//
+// Alerts become firing after being active for forDuration.
+type alertingRule struct {
+	name                string
+	expr                string
+	forDuration         time.Duration
+	labelTemplates      map[string]textTemplate
+	annotationTemplates map[string]textTemplate
+
+	// alerts contains active alerts keyed by their labels.
+	alerts map[string]*alert
+
+	// restored contains activeAt timestamps for alerts restored by restore call.
+	// It is used by the next exec call for alerts missing in alerts.
+	restored map[string]int64
+}
+
+func (ar *alertingRule) String() string {
+	return "alerting rule " + ar.name
+}
+
+func (ar *alertingRule) exec(q querier, timestamp, step int64) ([]series, []notifierAlert, error) {
+	rs, err := q(ar.expr, timestamp, step)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot execute %q: %s", ar.expr, err)
+	}
+
+	// Update active alerts.
+	seen := make(map[string]bool, len(rs))
+	for i := range rs {
+		r := &rs[i]
+		data := &templateData{
+			Labels: labelsFromMetricName(&r.MetricName),
+			Value:  r.Values[0],
+		}
+		labels := make(map[string]string, len(data.Labels)+len(ar.labelTemplates)+1)
+		for k, v := range data.Labels {
+			labels[k] = v
+		}
+		for k, t := range ar.labelTemplates {
+			labels[k] = t.execute(data)
+		}
+		labels["alertname"] = ar.name
+		key := labelsKey(labels)
+		if seen[key] {
+			return nil, nil, fmt.Errorf("%q returned multiple series with identical labels %s after applying rule labels", ar.expr, key)
+		}
+		seen[key] = true
+
+		a := ar.alerts[key]
+		if a == nil {
+			activeAt := timestamp
+			if t, ok := ar.restored[key]; ok && t < activeAt {
+				activeAt = t
+			}
+			a = &alert{
+				labels:   labels,
+				state:    statePending,
+				activeAt: activeAt,
+			}
+			ar.alerts[key] = a
+		}
+		a.value = data.Value
+		a.annotations = make(map[string]string, len(ar.annotationTemplates))
+		for k, t := range ar.annotationTemplates {
+			a.annotations[k] = t.execute(data)
+		}
+		if a.state == statePending && timestamp-a.activeAt >= ar.forDuration.Nanoseconds()/1e6 {
+			a.state = stateFiring
+		}
+	}
+
+	ar.restored = nil
+
+	// Generate series for active alerts and notifications for firing and resolved alerts.
+	// Firing alerts are re-sent on each evaluation with endsAt in the future,
+	// so the notifier may resolve them on its own if the evaluation stops.
+	var ss []series
+	var nas []notifierAlert
+	endsAt := timestamp + 3*step
+	for key, a := range ar.alerts {
+		if !seen[key] {
+			if a.state == stateFiring {
+				nas = append(nas, a.toNotifierAlert(timestamp))
+			}
+			delete(ar.alerts, key)
+			continue
+		}
+		ss = append(ss, a.toSeries("ALERTS", 1, "alertstate", a.state.String()))
+		ss = append(ss, a.toSeries("ALERTS_FOR_STATE", float64(a.activeAt/1e3)))
+		if a.state == stateFiring {
+			nas = append(nas, a.toNotifierAlert(endsAt))
+		}
+	}
+	return ss, nas, nil
+}
+
+// restore loads activeAt timestamps for ar alerts from `ALERTS_FOR_STATE` series stored during the lookback before the given timestamp.
+//
+// Restored alerts become active on the next exec call if they are still returned by ar.expr,
+// so alerts with `for` don't become pending again after restart.
+func (ar *alertingRule) restore(q querier, timestamp int64, lookback time.Duration) error {
+	if ar.forDuration <= 0 {
+		// Alerts without `for` become firing immediately, so there is no need in restoring them.
+		return nil
+	}
+	expr := fmt.Sprintf("last_over_time(ALERTS_FOR_STATE{alertname=%q}[%ds])", ar.name, int64(lookback/time.Second))
+	rs, err := q(expr, timestamp, lookback.Nanoseconds()/1e6)
+	if err != nil {
+		return fmt.Errorf("cannot execute %q: %s", expr, err)
+	}
+	restored := make(map[string]int64, len(rs))
+	for i := range rs {
+		r := &rs[i]
+		labels := labelsFromMetricName(&r.MetricName)
+		restored[labelsKey(labels)] = int64(r.Values[0]) * 1e3
+	}
+	ar.restored = restored
+	return nil
+}
+
+func (a *alert) toSeries(name string, value float64, extraLabels ...string) series {
+	labels := make(map[string]string, len(a.labels)+len(extraLabels)/2+1)
+	for k, v := range a.labels {
+		labels[k] = v
+	}
+	for i := 0; i+1 < len(extraLabels); i += 2 {
+		labels[extraLabels[i]] = extraLabels[i+1]
+	}
+	labels["__name__"] = name
+	return series{
+		labels: labels,
+		value:  value,
+	}
+}
+
+func (a *alert) toNotifierAlert(endsAt int64) notifierAlert {
+	return notifierAlert{
+		Labels:      a.labels,
+		Annotations: a.annotations,
+		StartsAt:    formatTimestamp(a.activeAt),
+		EndsAt:      formatTimestamp(endsAt),
+	}
+}
+
+func formatTimestamp(timestamp int64) string {
+	return time.Unix(0, timestamp*1e6).UTC().Format(time.RFC3339Nano)
+}
+
+// templateData is passed to label and annotation templates.
+type templateData struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// textTemplate is either a plain string or a template.
+type textTemplate struct {
+	text string
+	t    *template.Template
+}
+
+func (tt textTemplate) execute(data *templateData) string {
+	if tt.t == nil {
+		return tt.text
+	}
+	var bb bytes.Buffer
+	if err := tt.t.Execute(&bb, data); err != nil {
+		templateErrors.Inc()
+		logger.Errorf("cannot execute template %q: %s", tt.text, err)
+		return tt.text
+	}
+	return bb.String()
+}
+
+var templateErrors = metrics.NewCounter(`vm_rules_template_errors_total`)
+
+func mustNewTemplates(m map[string]string) map[string]textTemplate {
+	tts := make(map[string]textTemplate, len(m))
+	for k, text := range m {
+		t, err := newTemplate(k, text)
+		if err != nil {
+			logger.Panicf("BUG: template %q must be already validated; got error: %s", text, err)
+		}
+		tts[k] = textTemplate{
+			text: text,
+			t:    t,
+		}
+	}
+	return tts
+}
+
+// labelsFromMetricName returns labels for mn without the metric name.
+func labelsFromMetricName(mn *storage.MetricName) map[string]string {
+	labels := make(map[string]string, len(mn.Tags)+1)
+	for i := range mn.Tags {
+		tag := &mn.Tags[i]
+		labels[string(tag.Key)] = string(tag.Value)
+	}
+	return labels
+}
+
+// labelsKey returns canonical string representation for labels.
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString("{")
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "%s=%q", k, labels[k])
+	}
+	b.WriteString("}")
+	return b.String()
+}
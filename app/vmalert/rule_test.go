@@ -0,0 +1,224 @@
+package vmalert
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+)
+
+func TestRecordingRuleExec(t *testing.T) {
+	rr := newRule(&RuleConfig{
+		Record: "job:foo:sum",
+		Expr:   "sum(foo) by (job)",
+		Labels: map[string]string{
+			"env": "prod",
+		},
+	})
+	q := newFakeQuerier(map[string]float64{
+		`{job="a"}`: 1,
+		`{job="b"}`: 2,
+	})
+	ss, nas, err := rr.exec(q, 1000, 60e3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(nas) != 0 {
+		t.Fatalf("unexpected alerts from recording rule: %+v", nas)
+	}
+	checkSeries(t, ss, []string{
+		`{__name__="job:foo:sum",env="prod",job="a"} 1`,
+		`{__name__="job:foo:sum",env="prod",job="b"} 2`,
+	})
+}
+
+func TestAlertingRuleExec(t *testing.T) {
+	ar := newRule(&RuleConfig{
+		Alert: "TooHigh",
+		Expr:  "foo > 10",
+		For:   2 * time.Minute,
+		Labels: map[string]string{
+			"severity": "page",
+		},
+		Annotations: map[string]string{
+			"summary": "{{ $labels.instance }} is at {{ $value }}",
+		},
+	}).(*alertingRule)
+
+	// The alert becomes pending.
+	q := newFakeQuerier(map[string]float64{
+		`{instance="a"}`: 12,
+	})
+	ss, nas, err := ar.exec(q, 60e3, 60e3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkSeries(t, ss, []string{
+		`{__name__="ALERTS",alertname="TooHigh",alertstate="pending",instance="a",severity="page"} 1`,
+		`{__name__="ALERTS_FOR_STATE",alertname="TooHigh",instance="a",severity="page"} 60`,
+	})
+	if len(nas) != 0 {
+		t.Fatalf("pending alerts mustn't be sent; got %+v", nas)
+	}
+
+	// The alert is still pending.
+	ss, nas, err = ar.exec(q, 120e3, 60e3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkSeries(t, ss, []string{
+		`{__name__="ALERTS",alertname="TooHigh",alertstate="pending",instance="a",severity="page"} 1`,
+		`{__name__="ALERTS_FOR_STATE",alertname="TooHigh",instance="a",severity="page"} 60`,
+	})
+	if len(nas) != 0 {
+		t.Fatalf("pending alerts mustn't be sent; got %+v", nas)
+	}
+
+	// The alert becomes firing after `for` duration, while the new alert becomes pending.
+	q = newFakeQuerier(map[string]float64{
+		`{instance="a"}`: 13,
+		`{instance="b"}`: 20,
+	})
+	ss, nas, err = ar.exec(q, 180e3, 60e3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkSeries(t, ss, []string{
+		`{__name__="ALERTS",alertname="TooHigh",alertstate="firing",instance="a",severity="page"} 1`,
+		`{__name__="ALERTS",alertname="TooHigh",alertstate="pending",instance="b",severity="page"} 1`,
+		`{__name__="ALERTS_FOR_STATE",alertname="TooHigh",instance="a",severity="page"} 60`,
+		`{__name__="ALERTS_FOR_STATE",alertname="TooHigh",instance="b",severity="page"} 180`,
+	})
+	if len(nas) != 1 {
+		t.Fatalf("expecting a single firing alert; got %+v", nas)
+	}
+	na := nas[0]
+	if na.Labels["instance"] != "a" || na.Labels["alertname"] != "TooHigh" {
+		t.Fatalf("unexpected alert labels: %v", na.Labels)
+	}
+	if summary := na.Annotations["summary"]; summary != "a is at 13" {
+		t.Fatalf("unexpected summary; got %q; want %q", summary, "a is at 13")
+	}
+	if na.StartsAt != formatTimestamp(60e3) {
+		t.Fatalf("unexpected startsAt; got %q; want %q", na.StartsAt, formatTimestamp(60e3))
+	}
+	if na.EndsAt != formatTimestamp(360e3) {
+		t.Fatalf("unexpected endsAt; got %q; want %q", na.EndsAt, formatTimestamp(360e3))
+	}
+
+	// The firing alert is resolved, while the pending alert disappears.
+	q = newFakeQuerier(nil)
+	ss, nas, err = ar.exec(q, 240e3, 60e3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkSeries(t, ss, nil)
+	if len(nas) != 1 {
+		t.Fatalf("expecting a single resolved alert; got %+v", nas)
+	}
+	if nas[0].Labels["instance"] != "a" || nas[0].EndsAt != formatTimestamp(240e3) {
+		t.Fatalf("unexpected resolved alert: %+v", nas[0])
+	}
+	if len(ar.alerts) != 0 {
+		t.Fatalf("expecting no active alerts; got %d", len(ar.alerts))
+	}
+}
+
+func TestAlertingRuleExecError(t *testing.T) {
+	ar := newRule(&RuleConfig{
+		Alert: "Foo",
+		Expr:  "foo",
+	})
+	q := func(q string, timestamp, step int64) ([]netstorage.Result, error) {
+		return nil, fmt.Errorf("some error")
+	}
+	if _, _, err := ar.exec(q, 1000, 1000); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestAlertingRuleRestore(t *testing.T) {
+	ar := newRule(&RuleConfig{
+		Alert: "TooHigh",
+		Expr:  "foo > 10",
+		For:   2 * time.Minute,
+	}).(*alertingRule)
+
+	// Restore the alert, which became active before restart.
+	q := newFakeQuerier(map[string]float64{
+		`{alertname="TooHigh",instance="a"}`: 100,
+	})
+	if err := ar.restore(q, 300e3, time.Hour); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The restored alert must be firing, while the new alert must be pending.
+	q = newFakeQuerier(map[string]float64{
+		`{instance="a"}`: 12,
+		`{instance="b"}`: 20,
+	})
+	ss, nas, err := ar.exec(q, 300e3, 60e3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkSeries(t, ss, []string{
+		`{__name__="ALERTS",alertname="TooHigh",alertstate="firing",instance="a"} 1`,
+		`{__name__="ALERTS",alertname="TooHigh",alertstate="pending",instance="b"} 1`,
+		`{__name__="ALERTS_FOR_STATE",alertname="TooHigh",instance="a"} 100`,
+		`{__name__="ALERTS_FOR_STATE",alertname="TooHigh",instance="b"} 300`,
+	})
+	if len(nas) != 1 || nas[0].Labels["instance"] != "a" {
+		t.Fatalf("expecting a single firing alert for instance=a; got %+v", nas)
+	}
+
+	// The restored state mustn't be used after the alert is resolved.
+	if _, _, err := ar.exec(newFakeQuerier(nil), 360e3, 60e3); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	q = newFakeQuerier(map[string]float64{
+		`{instance="a"}`: 12,
+	})
+	ss, _, err = ar.exec(q, 420e3, 60e3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkSeries(t, ss, []string{
+		`{__name__="ALERTS",alertname="TooHigh",alertstate="pending",instance="a"} 1`,
+		`{__name__="ALERTS_FOR_STATE",alertname="TooHigh",instance="a"} 420`,
+	})
+}
+
+// newFakeQuerier returns querier, which returns series with the given labels and values.
+//
+// Labels must be in the form `{k1="v1",k2="v2"}`.
+func newFakeQuerier(m map[string]float64) querier {
+	return func(q string, timestamp, step int64) ([]netstorage.Result, error) {
+		var rs []netstorage.Result
+		for labels, value := range m {
+			var r netstorage.Result
+			for _, kv := range strings.Split(strings.Trim(labels, "{}"), ",") {
+				n := strings.IndexByte(kv, '=')
+				r.MetricName.AddTag(kv[:n], strings.Trim(kv[n+1:], `"`))
+			}
+			r.Values = []float64{value}
+			r.Timestamps = []int64{timestamp}
+			rs = append(rs, r)
+		}
+		return rs, nil
+	}
+}
+
+func checkSeries(t *testing.T, ss []series, expected []string) {
+	t.Helper()
+	var got []string
+	for _, s := range ss {
+		got = append(got, fmt.Sprintf("%s %g", labelsKey(s.labels), s.value))
+	}
+	sort.Strings(got)
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected series;\ngot\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
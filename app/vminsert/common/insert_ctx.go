@@ -3,8 +3,10 @@ package common
 import (
 	"fmt"
 
-	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 )
@@ -15,10 +17,21 @@ type InsertCtx struct {
 
 	mrs            []storage.MetricRow
 	metricNamesBuf []byte
+
+	relabelLabels []prompb.Label
+	prevLabels    []prompb.Label
+
+	// The range for timestamps of the accepted rows. It is updated on every Reset call.
+	minTimestamp int64
+	maxTimestamp int64
+	rejectedRows *RejectedRowsCounters
 }
 
 // Reset resets ctx for future fill with rowsLen rows.
-func (ctx *InsertCtx) Reset(rowsLen int) {
+//
+// Rows rejected because of out-of-range timestamps are counted in rrc.
+// rrc may be nil only if ctx is reset before returning it to a pool, i.e. no data points are written to ctx until the next Reset call.
+func (ctx *InsertCtx) Reset(rowsLen int, rrc *RejectedRowsCounters) {
 	for _, label := range ctx.Labels {
 		label.Name = nil
 		label.Value = nil
@@ -35,28 +48,85 @@ func (ctx *InsertCtx) Reset(rowsLen int) {
 	}
 	ctx.mrs = ctx.mrs[:0]
 	ctx.metricNamesBuf = ctx.metricNamesBuf[:0]
+
+	for i := range ctx.relabelLabels {
+		label := &ctx.relabelLabels[i]
+		label.Name = nil
+		label.Value = nil
+	}
+	ctx.relabelLabels = ctx.relabelLabels[:0]
+	for i := range ctx.prevLabels {
+		label := &ctx.prevLabels[i]
+		label.Name = nil
+		label.Value = nil
+	}
+	ctx.prevLabels = ctx.prevLabels[:0]
+
+	ctx.minTimestamp, ctx.maxTimestamp = getTimestampLimits()
+	ctx.rejectedRows = rrc
 }
 
-func (ctx *InsertCtx) marshalMetricNameRaw(prefix []byte, labels []prompb.Label) []byte {
+func (ctx *InsertCtx) marshalMetricNameRaw(at *auth.Token, prefix []byte, labels []prompb.Label) []byte {
 	start := len(ctx.metricNamesBuf)
-	ctx.metricNamesBuf = append(ctx.metricNamesBuf, prefix...)
-	ctx.metricNamesBuf = storage.MarshalMetricNameRaw(ctx.metricNamesBuf, labels)
+	if len(prefix) == 0 {
+		ctx.metricNamesBuf = storage.MarshalMetricNameRaw(ctx.metricNamesBuf, at.AccountID, at.ProjectID, labels)
+	} else {
+		// The prefix already contains the tenant.
+		ctx.metricNamesBuf = append(ctx.metricNamesBuf, prefix...)
+		ctx.metricNamesBuf = storage.MarshalMetricLabelsRaw(ctx.metricNamesBuf, labels)
+	}
 	metricNameRaw := ctx.metricNamesBuf[start:]
 	return metricNameRaw[:len(metricNameRaw):len(metricNameRaw)]
 }
 
-// WriteDataPoint writes (timestamp, value) with the given prefix and lables into ctx buffer.
-func (ctx *InsertCtx) WriteDataPoint(prefix []byte, labels []prompb.Label, timestamp int64, value float64) {
-	metricNameRaw := ctx.marshalMetricNameRaw(prefix, labels)
+// WriteDataPoint writes (timestamp, value) for the given tenant with the given prefix and lables into ctx buffer.
+//
+// Non-empty prefix must be obtained via storage.MarshalMetricNameRaw for the given at.
+// The prefix must be empty if NeedAllLabels returns true, since relabeling and stream aggregation rules need all the labels.
+//
+// The data point is dropped if relabeling rules drop it or if its timestamp is out of -insert.maxPastDrift ... -insert.maxFutureDrift range.
+// The data point is also dropped if it is aggregated by stream aggregation rules and -streamAggr.keepInput isn't set.
+func (ctx *InsertCtx) WriteDataPoint(at *auth.Token, prefix []byte, labels []prompb.Label, timestamp int64, value float64) {
+	if !ctx.checkTimestamp(labels, timestamp) {
+		return
+	}
+	if HasRelabeling() {
+		if len(prefix) > 0 {
+			logger.Panicf("BUG: prefix cannot be used together with relabeling")
+		}
+		labels = ctx.applyRelabeling(labels)
+		if len(labels) == 0 {
+			return
+		}
+	}
+	if pushToStreamAggr(at, prefix, labels, timestamp, value) {
+		return
+	}
+	metricNameRaw := ctx.marshalMetricNameRaw(at, prefix, labels)
 	ctx.addRow(metricNameRaw, timestamp, value)
 }
 
-// WriteDataPointExt writes (timestamp, value) with the given metricNameRaw and labels into ctx buffer.
+// WriteDataPointExt writes (timestamp, value) for the given tenant with the given metricNameRaw and labels into ctx buffer.
 //
 // It returns metricNameRaw for the given labels if len(metricNameRaw) == 0.
-func (ctx *InsertCtx) WriteDataPointExt(metricNameRaw []byte, labels []prompb.Label, timestamp int64, value float64) []byte {
+//
+// Relabeling rules are applied to every data point if HasRelabeling returns true, so nil is returned in this case.
+//
+// The data point is dropped if its timestamp is out of -insert.maxPastDrift ... -insert.maxFutureDrift range
+// or if it is aggregated by stream aggregation rules and -streamAggr.keepInput isn't set.
+func (ctx *InsertCtx) WriteDataPointExt(at *auth.Token, metricNameRaw []byte, labels []prompb.Label, timestamp int64, value float64) []byte {
+	if !ctx.checkTimestamp(labels, timestamp) {
+		return metricNameRaw
+	}
+	if HasRelabeling() {
+		ctx.WriteDataPoint(at, nil, labels, timestamp, value)
+		return nil
+	}
+	if pushToStreamAggr(at, nil, labels, timestamp, value) {
+		return metricNameRaw
+	}
 	if len(metricNameRaw) == 0 {
-		metricNameRaw = ctx.marshalMetricNameRaw(nil, labels)
+		metricNameRaw = ctx.marshalMetricNameRaw(at, nil, labels)
 	}
 	ctx.addRow(metricNameRaw, timestamp, value)
 	return metricNameRaw
@@ -76,10 +146,10 @@ func (ctx *InsertCtx) addRow(metricNameRaw []byte, timestamp int64, value float6
 	mr.Value = value
 }
 
-// AddLabel adds (name, value) label to ctx.Labels.
+// AddLabelBytes adds (name, value) label to ctx.Labels.
 //
 // name and value must exist until ctx.Labels is used.
-func (ctx *InsertCtx) AddLabel(name, value string) {
+func (ctx *InsertCtx) AddLabelBytes(name, value []byte) {
 	labels := ctx.Labels
 	if cap(labels) > len(labels) {
 		labels = labels[:len(labels)+1]
@@ -90,15 +160,22 @@ func (ctx *InsertCtx) AddLabel(name, value string) {
 
 	// Do not copy name and value contents for performance reasons.
 	// This reduces GC overhead on the number of objects and allocations.
-	label.Name = bytesutil.ToUnsafeBytes(name)
-	label.Value = bytesutil.ToUnsafeBytes(value)
+	label.Name = name
+	label.Value = value
 
 	ctx.Labels = labels
 }
 
+// AddLabel adds (name, value) label to ctx.Labels.
+//
+// name and value must exist until ctx.Labels is used.
+func (ctx *InsertCtx) AddLabel(name, value string) {
+	ctx.AddLabelBytes(bytesutil.ToUnsafeBytes(name), bytesutil.ToUnsafeBytes(value))
+}
+
 // FlushBufs flushes buffered rows to the underlying storage.
 func (ctx *InsertCtx) FlushBufs() error {
-	if err := vmstorage.AddRows(ctx.mrs); err != nil {
+	if err := netstorage.AddRows(ctx.mrs); err != nil {
 		return fmt.Errorf("cannot store metrics: %s", err)
 	}
 	return nil
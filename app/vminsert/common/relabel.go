@@ -0,0 +1,109 @@
+package common
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var relabelConfig = flag.String("relabelConfig", "", "Optional path to a file with relabeling rules in Prometheus `relabel_configs` format. "+
+	"The rules are applied to all the ingested data before it is written to storage")
+
+// relabelRule is a single relabeling rule with its stats.
+type relabelRule struct {
+	prc promrelabel.ParsedRelabelConfig
+
+	rowsDropped  *metrics.Counter
+	rowsModified *metrics.Counter
+}
+
+var relabelRules []relabelRule
+
+var (
+	relabelErrors            = metrics.NewCounter(`vm_relabel_errors_total`)
+	relabelErrorLogThrottler = logger.NewThrottler(5 * time.Second)
+)
+
+// InitRelabel loads relabeling rules from -relabelConfig.
+//
+// It must be called before inserting data via InsertCtx.
+func InitRelabel() {
+	if len(*relabelConfig) == 0 {
+		return
+	}
+	prcs, err := promrelabel.LoadRelabelConfigs(*relabelConfig)
+	if err != nil {
+		logger.Fatalf("cannot load -relabelConfig: %s", err)
+	}
+	relabelRules = make([]relabelRule, len(prcs))
+	for i := range prcs {
+		rr := &relabelRules[i]
+		rr.prc = prcs[i]
+		rr.rowsDropped = metrics.NewCounter(fmt.Sprintf(`vm_relabel_rows_dropped_total{rule="%d", action=%q}`, i+1, prcs[i].Action))
+		rr.rowsModified = metrics.NewCounter(fmt.Sprintf(`vm_relabel_rows_modified_total{rule="%d", action=%q}`, i+1, prcs[i].Action))
+	}
+	logger.Infof("loaded %d relabeling rules from -relabelConfig=%q", len(relabelRules), *relabelConfig)
+}
+
+// HasRelabeling returns true if relabeling rules are set via -relabelConfig.
+func HasRelabeling() bool {
+	return len(relabelRules) > 0
+}
+
+var metricNameLabel = []byte("__name__")
+
+// applyRelabeling applies relabeling rules to labels and returns the result.
+//
+// Empty result means the row must be dropped.
+// labels aren't modified.
+func (ctx *InsertCtx) applyRelabeling(labels []prompb.Label) []prompb.Label {
+	dst := append(ctx.relabelLabels[:0], labels...)
+	for i := range dst {
+		if len(dst[i].Name) == 0 {
+			// Empty label name is used for the metric name in ingestion protocols.
+			dst[i].Name = metricNameLabel
+		}
+	}
+	for i := range relabelRules {
+		rr := &relabelRules[i]
+		ctx.prevLabels = append(ctx.prevLabels[:0], dst...)
+		result, err := rr.prc.Apply(dst)
+		if err != nil {
+			// Drop the row, since it cannot be relabeled properly.
+			relabelErrors.Inc()
+			if relabelErrorLogThrottler.Allow() {
+				logger.Errorf("cannot apply relabeling rule #%d to %s: %s", i+1, labelsString(ctx.prevLabels), err)
+			}
+			dst = dst[:0]
+			break
+		}
+		dst = result
+		if len(dst) == 0 {
+			rr.rowsDropped.Inc()
+			break
+		}
+		if !labelsEqual(ctx.prevLabels, dst) {
+			rr.rowsModified.Inc()
+		}
+	}
+	ctx.relabelLabels = dst
+	return dst
+}
+
+func labelsEqual(a, b []prompb.Label) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i].Name, b[i].Name) || !bytes.Equal(a[i].Value, b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
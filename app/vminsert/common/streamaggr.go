@@ -0,0 +1,114 @@
+package common
+
+import (
+	"flag"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/streamaggr"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	streamAggrConfig = flag.String("streamAggr.config", "", "Optional path to a file with stream aggregation rules. "+
+		"Matching samples are aggregated at ingestion time and only the aggregated series are written to storage unless -streamAggr.keepInput is set. "+
+		"Note that sum and count outputs are calculated over input series, not samples. The aggregated series bypass relabeling and -insert.maxPastDrift, -insert.maxFutureDrift limits")
+	streamAggrKeepInput = flag.Bool("streamAggr.keepInput", false, "Whether to write samples matching -streamAggr.config rules to storage in addition to the aggregated series")
+)
+
+var (
+	streamAggregators *streamaggr.Aggregators
+
+	streamAggrRowsMatched = metrics.NewCounter(`vm_streamaggr_rows_matched_total`)
+	streamAggrRowsWritten = metrics.NewCounter(`vm_streamaggr_rows_written_total`)
+	streamAggrFlushErrors = metrics.NewCounter(`vm_streamaggr_flush_errors_total`)
+)
+
+// InitStreamAggr loads stream aggregation rules from -streamAggr.config.
+//
+// It must be called before inserting data via InsertCtx.
+func InitStreamAggr() {
+	if len(*streamAggrConfig) == 0 {
+		return
+	}
+	sas, err := streamaggr.LoadFromFile(*streamAggrConfig, pushAggregatedSeries)
+	if err != nil {
+		logger.Fatalf("cannot load -streamAggr.config: %s", err)
+	}
+	streamAggregators = sas
+	logger.Infof("loaded %d stream aggregation rules from -streamAggr.config=%q", sas.Len(), *streamAggrConfig)
+}
+
+// StopStreamAggr stops stream aggregation and writes the data aggregated during the current interval to storage.
+//
+// It must be called after data insertion is stopped.
+func StopStreamAggr() {
+	if streamAggregators == nil {
+		return
+	}
+	streamAggregators.MustStop()
+}
+
+// HasStreamAggr returns true if stream aggregation rules are set via -streamAggr.config.
+func HasStreamAggr() bool {
+	return streamAggregators != nil
+}
+
+// NeedAllLabels returns true if all the labels must be passed to WriteDataPoint,
+// i.e. the metric name prefix cannot be used.
+func NeedAllLabels() bool {
+	return HasRelabeling() || HasStreamAggr()
+}
+
+// pushToStreamAggr pushes the data point to stream aggregation rules.
+//
+// It returns true if the data point must be dropped, since it is replaced by the aggregated series.
+func pushToStreamAggr(at *auth.Token, prefix []byte, labels []prompb.Label, timestamp int64, value float64) bool {
+	sas := streamAggregators
+	if sas == nil {
+		return false
+	}
+	if len(prefix) > 0 {
+		logger.Panicf("BUG: prefix cannot be used together with stream aggregation")
+	}
+	if !sas.Push(at, labels, timestamp, value) {
+		return false
+	}
+	streamAggrRowsMatched.Inc()
+	return !*streamAggrKeepInput
+}
+
+// pushAggregatedSeries writes tss to storage.
+//
+// tss are written directly to netstorage, since they are built from already relabeled samples
+// and mustn't be aggregated again. Their timestamps are set to the current time, so drift limits aren't checked.
+func pushAggregatedSeries(at *auth.Token, tss []prompb.TimeSeries) {
+	var metricNamesBuf []byte
+	offsets := make([]int, 0, len(tss)+1)
+	for i := range tss {
+		offsets = append(offsets, len(metricNamesBuf))
+		metricNamesBuf = storage.MarshalMetricNameRaw(metricNamesBuf, at.AccountID, at.ProjectID, tss[i].Labels)
+	}
+	offsets = append(offsets, len(metricNamesBuf))
+
+	mrs := make([]storage.MetricRow, 0, len(tss))
+	for i := range tss {
+		metricNameRaw := metricNamesBuf[offsets[i]:offsets[i+1]]
+		for _, sample := range tss[i].Samples {
+			mrs = append(mrs, storage.MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     sample.Timestamp,
+				Value:         sample.Value,
+			})
+		}
+	}
+	if err := netstorage.AddRows(mrs); err != nil {
+		streamAggrFlushErrors.Inc()
+		logger.Errorf("cannot store %d aggregated rows: %s", len(mrs), err)
+		return
+	}
+	streamAggrRowsWritten.Add(len(mrs))
+}
@@ -0,0 +1,107 @@
+package common
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	maxFutureDrift = flag.Duration("insert.maxFutureDrift", 2*24*time.Hour, "Samples with timestamps exceeding the current time by more than the given duration are rejected. "+
+		"Zero disables the check. Values bigger than 48h have no effect, since the storage drops such samples anyway")
+	maxPastDrift = flag.Duration("insert.maxPastDrift", 0, "Samples with timestamps older than the current time minus the given duration are rejected. "+
+		"Zero disables the check, so only samples outside -retentionPeriod are dropped by the storage")
+	logRejectedSamples = flag.Bool("insert.logRejectedSamples", false, "Whether to log samples rejected because of -insert.maxFutureDrift or -insert.maxPastDrift. "+
+		"Up to a single sample is logged per 5 seconds")
+)
+
+// RejectedRowsCounters contains counters for rows rejected because of out-of-range timestamps
+// for a single ingestion protocol.
+type RejectedRowsCounters struct {
+	name     string
+	tooSmall *metrics.Counter
+	tooBig   *metrics.Counter
+}
+
+// NewRejectedRowsCounters returns counters for rows rejected by the protocol with the given name.
+//
+// The name must match the `type` label in `vm_rows_inserted_total` metric for the protocol.
+func NewRejectedRowsCounters(name string) *RejectedRowsCounters {
+	return &RejectedRowsCounters{
+		name:     name,
+		tooSmall: metrics.NewCounter(fmt.Sprintf(`vm_rows_rejected_total{type=%q, reason="too_small_timestamp"}`, name)),
+		tooBig:   metrics.NewCounter(fmt.Sprintf(`vm_rows_rejected_total{type=%q, reason="too_big_timestamp"}`, name)),
+	}
+}
+
+// getTimestampLimits returns the range for timestamps of the accepted rows at the moment.
+func getTimestampLimits() (int64, int64) {
+	minTimestamp := int64(math.MinInt64)
+	maxTimestamp := int64(math.MaxInt64)
+	now := time.Now().UnixNano() / 1e6
+	if *maxPastDrift > 0 {
+		minTimestamp = now - int64(*maxPastDrift/time.Millisecond)
+	}
+	if *maxFutureDrift > 0 {
+		maxTimestamp = now + int64(*maxFutureDrift/time.Millisecond)
+	}
+	return minTimestamp, maxTimestamp
+}
+
+// checkTimestamp returns true if the row with the given timestamp and labels fits the limits set in ctx.Reset.
+//
+// Rejected rows are registered in the counters passed to ctx.Reset.
+func (ctx *InsertCtx) checkTimestamp(labels []prompb.Label, timestamp int64) bool {
+	if timestamp >= ctx.minTimestamp && timestamp <= ctx.maxTimestamp {
+		return true
+	}
+	rrc := ctx.rejectedRows
+	if rrc == nil {
+		logger.Panicf("BUG: InsertCtx.Reset must be called with non-nil RejectedRowsCounters before writing data points")
+	}
+	if timestamp < ctx.minTimestamp {
+		rrc.tooSmall.Inc()
+	} else {
+		rrc.tooBig.Inc()
+	}
+	if *logRejectedSamples {
+		logRejectedSample(rrc.name, labels, timestamp, ctx.minTimestamp, ctx.maxTimestamp)
+	}
+	return false
+}
+
+var rejectedSampleLogThrottler = logger.NewThrottler(5 * time.Second)
+
+func logRejectedSample(name string, labels []prompb.Label, timestamp, minTimestamp, maxTimestamp int64) {
+	if !rejectedSampleLogThrottler.Allow() {
+		return
+	}
+	logger.Errorf("rejecting %s sample for %s with timestamp %d outside the range [%d ... %d] set by -insert.maxPastDrift=%s and -insert.maxFutureDrift=%s",
+		name, labelsString(labels), timestamp, minTimestamp, maxTimestamp, *maxPastDrift, *maxFutureDrift)
+}
+
+func labelsString(labels []prompb.Label) string {
+	var b []byte
+	b = append(b, '{')
+	for i := range labels {
+		label := &labels[i]
+		name := label.Name
+		if len(name) == 0 {
+			name = metricNameLabel
+		}
+		b = append(b, name...)
+		b = append(b, '=')
+		b = strconv.AppendQuote(b, string(label.Value))
+		if i+1 < len(labels) {
+			b = append(b, ',')
+		}
+	}
+	b = append(b, '}')
+	return string(b)
+}
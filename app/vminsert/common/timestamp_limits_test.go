@@ -0,0 +1,67 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+)
+
+func TestInsertCtxCheckTimestamp(t *testing.T) {
+	origMaxPastDrift := *maxPastDrift
+	origMaxFutureDrift := *maxFutureDrift
+	defer func() {
+		*maxPastDrift = origMaxPastDrift
+		*maxFutureDrift = origMaxFutureDrift
+	}()
+	*maxPastDrift = time.Hour
+	*maxFutureDrift = time.Hour
+
+	rrc := NewRejectedRowsCounters("test_check_timestamp")
+	var ctx InsertCtx
+	ctx.Reset(0, rrc)
+	labels := []prompb.Label{{
+		Name:  []byte(""),
+		Value: []byte("foo"),
+	}}
+	now := time.Now().UnixNano() / 1e6
+	f := func(timestamp int64, resultExpected bool) {
+		t.Helper()
+		if result := ctx.checkTimestamp(labels, timestamp); result != resultExpected {
+			t.Fatalf("unexpected result for timestamp=%d; got %v; want %v", timestamp, result, resultExpected)
+		}
+	}
+	f(now, true)
+	f(now-59*60*1000, true)
+	f(now+59*60*1000, true)
+	f(now-2*3600*1000, false)
+	f(now+2*3600*1000, false)
+	f(now+3*3600*1000, false)
+	if n := rrc.tooSmall.Get(); n != 1 {
+		t.Fatalf("unexpected number of rows with too small timestamps; got %d; want 1", n)
+	}
+	if n := rrc.tooBig.Get(); n != 2 {
+		t.Fatalf("unexpected number of rows with too big timestamps; got %d; want 2", n)
+	}
+
+	// Zero drifts disable the checks.
+	*maxPastDrift = 0
+	*maxFutureDrift = 0
+	ctx.Reset(0, rrc)
+	f(0, true)
+	f(now+365*24*3600*1000, true)
+}
+
+func TestLabelsString(t *testing.T) {
+	f := func(labels []prompb.Label, sExpected string) {
+		t.Helper()
+		if s := labelsString(labels); s != sExpected {
+			t.Fatalf("unexpected result; got %s; want %s", s, sExpected)
+		}
+	}
+	f(nil, "{}")
+	f([]prompb.Label{
+		{Name: []byte(""), Value: []byte("foo")},
+		{Name: []byte("job"), Value: []byte(`a"b`)},
+	}, `{__name__="foo",job="a\"b"}`)
+}
@@ -0,0 +1,181 @@
+package csvimport
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ColumnDescriptor represents parsing rules for a single csv column.
+//
+// The column is transformed to either timestamp, tag or metric value.
+type ColumnDescriptor struct {
+	// ParseTimestamp is not nil if the column contains timestamp.
+	//
+	// It must return timestamp in milliseconds.
+	ParseTimestamp func(s string) (int64, error)
+
+	// TagName is not empty if the column contains tag value.
+	TagName string
+
+	// MetricName is not empty if the column contains metric value.
+	MetricName string
+}
+
+const maxColumnsPerRow = 64 * 1024
+
+// ParseColumnDescriptors parses column descriptors from s.
+//
+// s must contain comma-separated list of `<column_pos>:<column_type>:<extension>` entries,
+// where <column_pos> is csv column position starting from 1 and <column_type> is one of:
+//
+//   - time - the column contains timestamp in the format set in <extension>.
+//     Supported formats: unix_s, unix_ms, unix_ns, rfc3339 and custom:<layout>,
+//     where <layout> is a layout for Go time.Parse. See https://golang.org/pkg/time/#Parse
+//   - label - the column contains label value for the label name set in <extension>.
+//   - metric - the column contains metric value for the metric name set in <extension>.
+//
+// s must contain at least a single `metric` column and no more than a single `time` column.
+func ParseColumnDescriptors(s string) ([]ColumnDescriptor, error) {
+	m := make(map[int]ColumnDescriptor)
+	cols := strings.Split(s, ",")
+	hasValueCol := false
+	hasTimeCol := false
+	maxPos := 0
+	for i, col := range cols {
+		var cd ColumnDescriptor
+		a := strings.SplitN(col, ":", 3)
+		if len(a) != 3 {
+			return nil, fmt.Errorf("entry #%d must have the following form: <column_pos>:<column_type>:<extension>; got %q", i+1, col)
+		}
+		pos, err := strconv.Atoi(a[0])
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse <column_pos> part from the entry #%d %q: %s", i+1, col, err)
+		}
+		if pos <= 0 {
+			return nil, fmt.Errorf("<column_pos> cannot be smaller than 1; got %d for entry #%d %q", pos, i+1, col)
+		}
+		if pos > maxColumnsPerRow {
+			return nil, fmt.Errorf("<column_pos> cannot be bigger than %d; got %d for entry #%d %q", maxColumnsPerRow, pos, i+1, col)
+		}
+		if pos > maxPos {
+			maxPos = pos
+		}
+		typ := a[1]
+		switch typ {
+		case "time":
+			if hasTimeCol {
+				return nil, fmt.Errorf("duplicate time column has been found at entry #%d %q for %q", i+1, col, s)
+			}
+			parseTimestamp, err := parseTimeFormat(a[2])
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse time format from the entry #%d %q: %s", i+1, col, err)
+			}
+			cd.ParseTimestamp = parseTimestamp
+			hasTimeCol = true
+		case "label":
+			cd.TagName = a[2]
+			if len(cd.TagName) == 0 {
+				return nil, fmt.Errorf("label name cannot be empty in the entry #%d %q", i+1, col)
+			}
+		case "metric":
+			cd.MetricName = a[2]
+			if len(cd.MetricName) == 0 {
+				return nil, fmt.Errorf("metric name cannot be empty in the entry #%d %q", i+1, col)
+			}
+			hasValueCol = true
+		default:
+			return nil, fmt.Errorf("unknown <column_type>: %q; allowed values: time, metric, label", typ)
+		}
+		pos--
+		if _, ok := m[pos]; ok {
+			return nil, fmt.Errorf("duplicate <column_pos> %d for the entry #%d %q", pos+1, i+1, col)
+		}
+		m[pos] = cd
+	}
+	if !hasValueCol {
+		return nil, fmt.Errorf("missing 'metric' column in %q", s)
+	}
+	cds := make([]ColumnDescriptor, maxPos)
+	for pos, cd := range m {
+		cds[pos] = cd
+	}
+	return cds, nil
+}
+
+func parseTimeFormat(format string) (func(s string) (int64, error), error) {
+	if strings.HasPrefix(format, "custom:") {
+		format = format[len("custom:"):]
+		return newParseCustomTimeFunc(format), nil
+	}
+	switch format {
+	case "unix_s":
+		return parseUnixTimestampSeconds, nil
+	case "unix_ms":
+		return parseUnixTimestampMilliseconds, nil
+	case "unix_ns":
+		return parseUnixTimestampNanoseconds, nil
+	case "rfc3339":
+		return parseRFC3339, nil
+	default:
+		return nil, fmt.Errorf("unknown format for time parsing: %q; supported formats: unix_s, unix_ms, unix_ns, rfc3339, custom:<layout>", format)
+	}
+}
+
+func parseUnixTimestampSeconds(s string) (int64, error) {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse unix timestamp in seconds from %q: %s", s, err)
+	}
+	if n >= int64Max/1e3 || n < int64Min/1e3 {
+		return 0, fmt.Errorf("unix timestamp in seconds is out of range: %v", n)
+	}
+	return int64(n * 1e3), nil
+}
+
+func parseUnixTimestampMilliseconds(s string) (int64, error) {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse unix timestamp in milliseconds from %q: %s", s, err)
+	}
+	if n >= int64Max || n < int64Min {
+		return 0, fmt.Errorf("unix timestamp in milliseconds is out of range: %v", n)
+	}
+	return int64(n), nil
+}
+
+func parseUnixTimestampNanoseconds(s string) (int64, error) {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse unix timestamp in nanoseconds from %q: %s", s, err)
+	}
+	if n >= int64Max || n < int64Min {
+		return 0, fmt.Errorf("unix timestamp in nanoseconds is out of range: %v", n)
+	}
+	return int64(n) / 1e6, nil
+}
+
+func parseRFC3339(s string) (int64, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse time in RFC3339 from %q: %s", s, err)
+	}
+	return t.UnixNano() / 1e6, nil
+}
+
+func newParseCustomTimeFunc(format string) func(s string) (int64, error) {
+	return func(s string) (int64, error) {
+		t, err := time.Parse(format, s)
+		if err != nil {
+			return 0, fmt.Errorf("cannot parse time in custom format %q from %q: %s", format, s, err)
+		}
+		return t.UnixNano() / 1e6, nil
+	}
+}
+
+const (
+	int64Max = float64(math.MaxInt64)
+	int64Min = float64(math.MinInt64)
+)
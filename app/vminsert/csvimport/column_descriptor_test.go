@@ -0,0 +1,97 @@
+package csvimport
+
+import (
+	"testing"
+)
+
+func TestParseColumnDescriptorsSuccess(t *testing.T) {
+	cds, err := ParseColumnDescriptors("4:metric:temperature,1:time:unix_s,2:label:city,5:metric:humidity")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(cds) != 5 {
+		t.Fatalf("unexpected number of column descriptors; got %d; want 5", len(cds))
+	}
+	if cds[0].ParseTimestamp == nil || cds[0].TagName != "" || cds[0].MetricName != "" {
+		t.Fatalf("unexpected column descriptor #1: %+v", cds[0])
+	}
+	if cds[1].ParseTimestamp != nil || cds[1].TagName != "city" || cds[1].MetricName != "" {
+		t.Fatalf("unexpected column descriptor #2: %+v", cds[1])
+	}
+	if cds[2].ParseTimestamp != nil || cds[2].TagName != "" || cds[2].MetricName != "" {
+		t.Fatalf("unexpected column descriptor #3: %+v", cds[2])
+	}
+	if cds[3].MetricName != "temperature" || cds[4].MetricName != "humidity" {
+		t.Fatalf("unexpected metric column descriptors: %+v, %+v", cds[3], cds[4])
+	}
+}
+
+func TestParseColumnDescriptorsFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		cds, err := ParseColumnDescriptors(s)
+		if err == nil {
+			t.Fatalf("expecting non-nil error for ParseColumnDescriptors(%q)", s)
+		}
+		if cds != nil {
+			t.Fatalf("expecting nil cds; got %+v", cds)
+		}
+	}
+	f("")
+	f("foobar")
+	f("1:metric")
+	f("x:metric:foo")
+	f("0:metric:foo")
+	f("-1:metric:foo")
+	f("100000:metric:foo")
+	f("1:metric:")
+	f("1:label:")
+	f("1:foobar:baz")
+	f("1:time:unix_s")
+	f("1:label:foo,2:time:unix_s")
+	f("1:metric:foo,1:metric:bar")
+	f("1:metric:foo,2:time:unix_s,3:time:unix_ms")
+	f("1:metric:foo,2:time:foobar")
+}
+
+func TestParseTimeFormat(t *testing.T) {
+	f := func(format, s string, timestampExpected int64) {
+		t.Helper()
+		parseTimestamp, err := parseTimeFormat(format)
+		if err != nil {
+			t.Fatalf("cannot parse time format %q: %s", format, err)
+		}
+		timestamp, err := parseTimestamp(s)
+		if err != nil {
+			t.Fatalf("cannot parse %q with format %q: %s", s, format, err)
+		}
+		if timestamp != timestampExpected {
+			t.Fatalf("unexpected timestamp for %q with format %q; got %d; want %d", s, format, timestamp, timestampExpected)
+		}
+	}
+	f("unix_s", "1572000000", 1572000000000)
+	f("unix_s", "1572000000.123", 1572000000123)
+	f("unix_ms", "1572000000123", 1572000000123)
+	f("unix_ns", "1572000000123456789", 1572000000123)
+	f("rfc3339", "2019-10-25T10:40:00Z", 1572000000000)
+	f("rfc3339", "2019-10-25T12:40:00.5+02:00", 1572000000500)
+	f("custom:2006-01-02 15:04:05", "2019-10-25 10:40:00", 1572000000000)
+	f("custom:2006-01-02", "2019-10-25", 1571961600000)
+
+	fail := func(format, s string) {
+		t.Helper()
+		parseTimestamp, err := parseTimeFormat(format)
+		if err != nil {
+			t.Fatalf("cannot parse time format %q: %s", format, err)
+		}
+		if _, err := parseTimestamp(s); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q with format %q", s, format)
+		}
+	}
+	fail("unix_s", "")
+	fail("unix_s", "foobar")
+	fail("unix_s", "1e20")
+	fail("unix_ms", "1e30")
+	fail("rfc3339", "2019-10-25")
+	fail("custom:2006-01-02", "2019/10/25")
+}
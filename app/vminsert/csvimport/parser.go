@@ -0,0 +1,209 @@
+package csvimport
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rows contains parsed csv rows.
+type Rows struct {
+	Rows []Row
+
+	tagsPool []Tag
+	fields   []string
+	buf      []byte
+}
+
+// Reset resets rs.
+func (rs *Rows) Reset() {
+	// Reset items, so they can be GC'ed
+
+	for i := range rs.Rows {
+		rs.Rows[i].reset()
+	}
+	rs.Rows = rs.Rows[:0]
+
+	for i := range rs.tagsPool {
+		rs.tagsPool[i].reset()
+	}
+	rs.tagsPool = rs.tagsPool[:0]
+
+	for i := range rs.fields {
+		rs.fields[i] = ""
+	}
+	rs.fields = rs.fields[:0]
+	rs.buf = rs.buf[:0]
+}
+
+// Unmarshal unmarshals csv lines from s according to the given cds.
+//
+// A row per each `metric` column is created for every csv line.
+// Rows without timestamp column have zero Timestamp.
+//
+// firstLineNum is the number of the first line in s. It is used in error messages.
+//
+// s must be unchanged until rs is in use.
+func (rs *Rows) Unmarshal(s string, cds []ColumnDescriptor, firstLineNum int) error {
+	rs.Reset()
+	lineNum := firstLineNum
+	for len(s) > 0 {
+		n := strings.IndexByte(s, '\n')
+		line := s
+		if n >= 0 {
+			line = s[:n]
+			s = s[n+1:]
+		} else {
+			s = ""
+		}
+		if err := rs.unmarshalLine(line, cds); err != nil {
+			return fmt.Errorf("error on line #%d: %s", lineNum, err)
+		}
+		lineNum++
+	}
+	return nil
+}
+
+func (rs *Rows) unmarshalLine(line string, cds []ColumnDescriptor) error {
+	line = strings.TrimSuffix(line, "\r")
+	if len(strings.TrimSpace(line)) == 0 {
+		// Skip empty lines.
+		return nil
+	}
+	var err error
+	rs.fields, err = rs.splitFields(rs.fields[:0], line)
+	if err != nil {
+		return err
+	}
+	fields := rs.fields
+	if len(fields) < len(cds) {
+		return fmt.Errorf("too few columns: %d; expecting at least %d columns", len(fields), len(cds))
+	}
+
+	// Collect tags and timestamp at first, since they are shared among all the metrics on the line.
+	tagsStart := len(rs.tagsPool)
+	timestamp := int64(0)
+	for i := range cds {
+		cd := &cds[i]
+		switch {
+		case cd.ParseTimestamp != nil:
+			ts, err := cd.ParseTimestamp(fields[i])
+			if err != nil {
+				return fmt.Errorf("cannot parse timestamp from column #%d: %s", i+1, err)
+			}
+			timestamp = ts
+		case cd.TagName != "":
+			if len(fields[i]) == 0 {
+				// Skip empty labels.
+				continue
+			}
+			if cap(rs.tagsPool) > len(rs.tagsPool) {
+				rs.tagsPool = rs.tagsPool[:len(rs.tagsPool)+1]
+			} else {
+				rs.tagsPool = append(rs.tagsPool, Tag{})
+			}
+			tag := &rs.tagsPool[len(rs.tagsPool)-1]
+			tag.Key = cd.TagName
+			tag.Value = fields[i]
+		}
+	}
+	tags := rs.tagsPool[tagsStart:]
+	tags = tags[:len(tags):len(tags)]
+
+	for i := range cds {
+		cd := &cds[i]
+		if cd.MetricName == "" {
+			continue
+		}
+		if len(fields[i]) == 0 {
+			// Skip missing values.
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return fmt.Errorf("cannot parse value for metric %q from column #%d: %s", cd.MetricName, i+1, err)
+		}
+		if cap(rs.Rows) > len(rs.Rows) {
+			rs.Rows = rs.Rows[:len(rs.Rows)+1]
+		} else {
+			rs.Rows = append(rs.Rows, Row{})
+		}
+		r := &rs.Rows[len(rs.Rows)-1]
+		r.Metric = cd.MetricName
+		r.Tags = tags
+		r.Value = v
+		r.Timestamp = timestamp
+	}
+	return nil
+}
+
+// splitFields appends comma-separated fields from line to dst and returns the result.
+//
+// Fields may be quoted with double quotes. Double quotes inside quoted fields must be escaped with another double quote.
+func (rs *Rows) splitFields(dst []string, line string) ([]string, error) {
+	for {
+		if len(line) == 0 || line[0] != '"' {
+			// Fast path - unquoted field.
+			n := strings.IndexByte(line, ',')
+			if n < 0 {
+				return append(dst, line), nil
+			}
+			dst = append(dst, line[:n])
+			line = line[n+1:]
+			continue
+		}
+
+		// Slow path - quoted field.
+		line = line[1:]
+		bufStart := len(rs.buf)
+		for {
+			n := strings.IndexByte(line, '"')
+			if n < 0 {
+				return dst, fmt.Errorf("missing closing quote for field #%d", len(dst)+1)
+			}
+			rs.buf = append(rs.buf, line[:n]...)
+			line = line[n+1:]
+			if len(line) > 0 && line[0] == '"' {
+				// Escaped quote.
+				rs.buf = append(rs.buf, '"')
+				line = line[1:]
+				continue
+			}
+			break
+		}
+		dst = append(dst, string(rs.buf[bufStart:]))
+		if len(line) == 0 {
+			return dst, nil
+		}
+		if line[0] != ',' {
+			return dst, fmt.Errorf("unexpected char after the closing quote for field #%d: %q; expecting ','", len(dst), line[0])
+		}
+		line = line[1:]
+	}
+}
+
+// Row is a single csv row.
+type Row struct {
+	Metric    string
+	Tags      []Tag
+	Value     float64
+	Timestamp int64
+}
+
+func (r *Row) reset() {
+	r.Metric = ""
+	r.Tags = nil
+	r.Value = 0
+	r.Timestamp = 0
+}
+
+// Tag is a single label for csv row.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+func (t *Tag) reset() {
+	t.Key = ""
+	t.Value = ""
+}
@@ -0,0 +1,144 @@
+package csvimport
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRowsUnmarshalFailure(t *testing.T) {
+	cds, err := ParseColumnDescriptors("1:label:symbol,2:time:unix_s,3:metric:price")
+	if err != nil {
+		t.Fatalf("unexpected error when parsing column descriptors: %s", err)
+	}
+	f := func(s string, lineNumExpected string) {
+		t.Helper()
+		var rs Rows
+		err := rs.Unmarshal(s, cds, 10)
+		if err == nil {
+			t.Fatalf("expecting non-nil error when unmarshaling %q", s)
+		}
+		if !strings.Contains(err.Error(), lineNumExpected) {
+			t.Fatalf("missing %q in the error %q", lineNumExpected, err)
+		}
+	}
+
+	// Too few columns
+	f("aaa", "line #10")
+	f("aaa,1234", "line #10")
+
+	// Invalid timestamp
+	f("aaa,bbb,12", "line #10")
+
+	// Invalid value
+	f("aaa,1234,\n\naaa,1234,ccc", "line #12")
+
+	// Missing closing quote
+	f(`"aaa,1234,12`, "line #10")
+
+	// Unexpected char after the closing quote
+	f(`"aaa"b,1234,12`, "line #10")
+}
+
+func TestRowsUnmarshalSuccess(t *testing.T) {
+	f := func(format, s string, rowsExpected []Row) {
+		t.Helper()
+		cds, err := ParseColumnDescriptors(format)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", format, err)
+		}
+		var rs Rows
+		if err := rs.Unmarshal(s, cds, 1); err != nil {
+			t.Fatalf("unexpected error when unmarshaling %q: %s", s, err)
+		}
+		if !reflect.DeepEqual(rs.Rows, rowsExpected) {
+			t.Fatalf("unexpected rows;\ngot\n%+v\nwant\n%+v", rs.Rows, rowsExpected)
+		}
+
+		// Try unmarshaling again
+		if err := rs.Unmarshal(s, cds, 1); err != nil {
+			t.Fatalf("unexpected error when unmarshaling %q: %s", s, err)
+		}
+		if !reflect.DeepEqual(rs.Rows, rowsExpected) {
+			t.Fatalf("unexpected rows;\ngot\n%+v\nwant\n%+v", rs.Rows, rowsExpected)
+		}
+
+		rs.Reset()
+		if len(rs.Rows) != 0 {
+			t.Fatalf("non-empty rows after reset: %+v", rs.Rows)
+		}
+	}
+
+	// Empty lines
+	f("1:metric:foo", "", nil)
+	f("1:metric:foo", "\n\r\n", nil)
+
+	// Single metric without timestamp
+	f("1:metric:foo", "123", []Row{{
+		Metric: "foo",
+		Value:  123,
+	}})
+
+	// Multiple metrics with labels and timestamp
+	f("1:label:symbol,2:time:unix_s,3:metric:bid,4:metric:ask,6:label:exchange", "GOOG,1572000000,1.23,4.56,ignored,NYSE\r\nAAPL,1572000001,7,,x,", []Row{
+		{
+			Metric: "bid",
+			Tags: []Tag{
+				{
+					Key:   "symbol",
+					Value: "GOOG",
+				},
+				{
+					Key:   "exchange",
+					Value: "NYSE",
+				},
+			},
+			Value:     1.23,
+			Timestamp: 1572000000000,
+		},
+		{
+			Metric: "ask",
+			Tags: []Tag{
+				{
+					Key:   "symbol",
+					Value: "GOOG",
+				},
+				{
+					Key:   "exchange",
+					Value: "NYSE",
+				},
+			},
+			Value:     4.56,
+			Timestamp: 1572000000000,
+		},
+		{
+			Metric: "bid",
+			Tags: []Tag{{
+				Key:   "symbol",
+				Value: "AAPL",
+			}},
+			Value:     7,
+			Timestamp: 1572000001000,
+		},
+	})
+
+	// Quoted fields
+	f("1:label:name,2:metric:value", `"foo, ""bar""",12`+"\n"+`baz,"34"`, []Row{
+		{
+			Metric: "value",
+			Tags: []Tag{{
+				Key:   "name",
+				Value: `foo, "bar"`,
+			}},
+			Value: 12,
+		},
+		{
+			Metric: "value",
+			Tags: []Tag{{
+				Key:   "name",
+				Value: "baz",
+			}},
+			Value: 34,
+		},
+	})
+}
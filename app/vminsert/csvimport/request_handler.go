@@ -0,0 +1,199 @@
+package csvimport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/concurrencylimiter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	rowsInserted = metrics.NewCounter(`vm_rows_inserted_total{type="csv"}`)
+	rowsRejected = common.NewRejectedRowsCounters("csv")
+)
+
+// InsertHandler processes `/api/v1/import/csv` request for the given tenant.
+//
+// Columns are mapped to metrics, labels and timestamps according to `format` query arg.
+// See ParseColumnDescriptors for details.
+func InsertHandler(at *auth.Token, req *http.Request) error {
+	// Do not use req.FormValue, since it may consume the request body with the data.
+	format := req.URL.Query().Get("format")
+	if len(format) == 0 {
+		return fmt.Errorf("missing `format` query arg")
+	}
+	cds, err := ParseColumnDescriptors(format)
+	if err != nil {
+		return fmt.Errorf("cannot parse `format`=%q: %s", format, err)
+	}
+	return concurrencylimiter.Do(func() error {
+		return insertHandlerInternal(at, req, cds)
+	})
+}
+
+func insertHandlerInternal(at *auth.Token, req *http.Request, cds []ColumnDescriptor) error {
+	csvReadCalls.Inc()
+
+	r := req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := getGzipReader(r)
+		if err != nil {
+			return fmt.Errorf("cannot read gzipped csv data: %s", err)
+		}
+		defer putGzipReader(zr)
+		r = zr
+	}
+
+	ctx := getPushCtx()
+	defer putPushCtx(ctx)
+	for ctx.Read(r, cds) {
+		if err := ctx.InsertRows(at); err != nil {
+			return err
+		}
+	}
+	return ctx.Error()
+}
+
+func (ctx *pushCtx) InsertRows(at *auth.Token) error {
+	rows := ctx.Rows.Rows
+	ic := &ctx.Common
+	ic.Reset(len(rows), rowsRejected)
+	for i := range rows {
+		r := &rows[i]
+		ic.Labels = ic.Labels[:0]
+		ic.AddLabel("", r.Metric)
+		for j := range r.Tags {
+			tag := &r.Tags[j]
+			ic.AddLabel(tag.Key, tag.Value)
+		}
+		ic.WriteDataPoint(at, nil, ic.Labels, r.Timestamp, r.Value)
+	}
+	rowsInserted.Add(len(rows))
+	return ic.FlushBufs()
+}
+
+func getGzipReader(r io.Reader) (*gzip.Reader, error) {
+	v := gzipReaderPool.Get()
+	if v == nil {
+		return gzip.NewReader(r)
+	}
+	zr := v.(*gzip.Reader)
+	if err := zr.Reset(r); err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
+func putGzipReader(zr *gzip.Reader) {
+	_ = zr.Close()
+	gzipReaderPool.Put(zr)
+}
+
+var gzipReaderPool sync.Pool
+
+func (ctx *pushCtx) Read(r io.Reader, cds []ColumnDescriptor) bool {
+	if ctx.err != nil {
+		return false
+	}
+	ctx.reqBuf, ctx.tailBuf, ctx.err = common.ReadLinesBlock(r, ctx.reqBuf, ctx.tailBuf)
+	if ctx.err != nil {
+		if ctx.err != io.EOF {
+			csvReadErrors.Inc()
+			ctx.err = fmt.Errorf("cannot read csv data: %s", ctx.err)
+		}
+		return false
+	}
+	if err := ctx.Rows.Unmarshal(bytesutil.ToUnsafeString(ctx.reqBuf), cds, ctx.lineNum); err != nil {
+		csvUnmarshalErrors.Inc()
+		ctx.err = fmt.Errorf("cannot unmarshal csv data: %s", err)
+		return false
+	}
+	// ReadLinesBlock strips the last newline from ctx.reqBuf.
+	ctx.lineNum += bytes.Count(ctx.reqBuf, newline) + 1
+
+	// Fill missing timestamps with the current timestamp.
+	currentTimestamp := time.Now().UnixNano() / 1e6
+	rows := ctx.Rows.Rows
+	for i := range rows {
+		r := &rows[i]
+		if r.Timestamp == 0 {
+			r.Timestamp = currentTimestamp
+		}
+	}
+	return true
+}
+
+var newline = []byte("\n")
+
+var (
+	csvReadCalls       = metrics.NewCounter(`vm_read_calls_total{name="csv"}`)
+	csvReadErrors      = metrics.NewCounter(`vm_read_errors_total{name="csv"}`)
+	csvUnmarshalErrors = metrics.NewCounter(`vm_unmarshal_errors_total{name="csv"}`)
+)
+
+type pushCtx struct {
+	Rows   Rows
+	Common common.InsertCtx
+
+	reqBuf  []byte
+	tailBuf []byte
+
+	// lineNum is the number of the first line in reqBuf.
+	lineNum int
+
+	err error
+}
+
+func (ctx *pushCtx) Error() error {
+	if ctx.err == io.EOF {
+		return nil
+	}
+	return ctx.err
+}
+
+func (ctx *pushCtx) reset() {
+	ctx.Rows.Reset()
+	ctx.Common.Reset(0, nil)
+
+	ctx.reqBuf = ctx.reqBuf[:0]
+	ctx.tailBuf = ctx.tailBuf[:0]
+	ctx.lineNum = 1
+
+	ctx.err = nil
+}
+
+func getPushCtx() *pushCtx {
+	select {
+	case ctx := <-pushCtxPoolCh:
+		return ctx
+	default:
+		if v := pushCtxPool.Get(); v != nil {
+			return v.(*pushCtx)
+		}
+		return &pushCtx{
+			lineNum: 1,
+		}
+	}
+}
+
+func putPushCtx(ctx *pushCtx) {
+	ctx.reset()
+	select {
+	case pushCtxPoolCh <- ctx:
+	default:
+		pushCtxPool.Put(ctx)
+	}
+}
+
+var pushCtxPool sync.Pool
+var pushCtxPoolCh = make(chan *pushCtx, runtime.GOMAXPROCS(-1))
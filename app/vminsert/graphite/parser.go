@@ -64,24 +64,10 @@ func (r *Row) unmarshal(s string, tagsPool []Tag) ([]Tag, error) {
 	if n < 0 {
 		return tagsPool, fmt.Errorf("cannot find whitespace between metric and value in %q", s)
 	}
-	metricAndTags := s[:n]
 	tail := s[n+1:]
-
-	n = strings.IndexByte(metricAndTags, ';')
-	if n < 0 {
-		// No tags
-		r.Metric = metricAndTags
-	} else {
-		// Tags found
-		r.Metric = metricAndTags[:n]
-		tagsStart := len(tagsPool)
-		var err error
-		tagsPool, err = unmarshalTags(tagsPool, metricAndTags[n+1:])
-		if err != nil {
-			return tagsPool, fmt.Errorf("cannot umarshal tags: %s", err)
-		}
-		tags := tagsPool[tagsStart:]
-		r.Tags = tags[:len(tags):len(tags)]
+	tagsPool, err := r.unmarshalMetricAndTags(s[:n], tagsPool)
+	if err != nil {
+		return tagsPool, err
 	}
 
 	n = strings.IndexByte(tail, ' ')
@@ -95,6 +81,28 @@ func (r *Row) unmarshal(s string, tagsPool []Tag) ([]Tag, error) {
 	return tagsPool, nil
 }
 
+// unmarshalMetricAndTags unmarshals metric name with optional `;tag=value` pairs from s.
+func (r *Row) unmarshalMetricAndTags(s string, tagsPool []Tag) ([]Tag, error) {
+	n := strings.IndexByte(s, ';')
+	if n < 0 {
+		// No tags
+		r.Metric = s
+		return tagsPool, nil
+	}
+
+	// Tags found
+	r.Metric = s[:n]
+	tagsStart := len(tagsPool)
+	var err error
+	tagsPool, err = unmarshalTags(tagsPool, s[n+1:])
+	if err != nil {
+		return tagsPool, fmt.Errorf("cannot umarshal tags: %s", err)
+	}
+	tags := tagsPool[tagsStart:]
+	r.Tags = tags[:len(tags):len(tags)]
+	return tagsPool, nil
+}
+
 func unmarshalRows(dst []Row, s string, tagsPool []Tag) ([]Row, []Tag, error) {
 	for len(s) > 0 {
 		n := strings.IndexByte(s, '\n')
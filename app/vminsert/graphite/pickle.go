@@ -0,0 +1,595 @@
+package graphite
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// UnmarshalPickle unmarshals a single Graphite pickle protocol message from data.
+//
+// The message must contain a pickled list of `(path, (timestamp, value))` tuples.
+// The path may contain tags in the `name;tag1=value1;tag2=value2` form.
+//
+// Only the opcodes required for unpickling lists, tuples, strings and numbers
+// are supported, so arbitrary Python objects cannot be constructed from data.
+//
+// See https://graphite.readthedocs.io/en/latest/feeding-carbon.html#the-pickle-protocol
+func (rs *Rows) UnmarshalPickle(data []byte) error {
+	rs.Reset()
+	v, err := unpickle(data)
+	if err != nil {
+		return fmt.Errorf("cannot unpickle data: %s", err)
+	}
+	items, ok := getPickleSequence(v)
+	if !ok {
+		return fmt.Errorf("unexpected top-level object; got %T; want list of `(path, (timestamp, value))` tuples", v)
+	}
+	for i, item := range items {
+		if cap(rs.Rows) > len(rs.Rows) {
+			rs.Rows = rs.Rows[:len(rs.Rows)+1]
+		} else {
+			rs.Rows = append(rs.Rows, Row{})
+		}
+		r := &rs.Rows[len(rs.Rows)-1]
+		rs.tagsPool, err = r.unmarshalPickle(item, rs.tagsPool)
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal item #%d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+func (r *Row) unmarshalPickle(v interface{}, tagsPool []Tag) ([]Tag, error) {
+	r.reset()
+	a, ok := getPickleSequence(v)
+	if !ok || len(a) != 2 {
+		return tagsPool, fmt.Errorf("expecting `(path, (timestamp, value))` tuple; got %v", v)
+	}
+	path, ok := a[0].(string)
+	if !ok {
+		return tagsPool, fmt.Errorf("path must be string; got %T", a[0])
+	}
+	tagsPool, err := r.unmarshalMetricAndTags(path, tagsPool)
+	if err != nil {
+		return tagsPool, fmt.Errorf("cannot unmarshal path %q: %s", path, err)
+	}
+	point, ok := getPickleSequence(a[1])
+	if !ok || len(point) != 2 {
+		return tagsPool, fmt.Errorf("expecting `(timestamp, value)` tuple for path %q; got %v", path, a[1])
+	}
+	ts, err := getPickleFloat64(point[0])
+	if err != nil {
+		return tagsPool, fmt.Errorf("cannot parse timestamp for path %q: %s", path, err)
+	}
+	if math.IsNaN(ts) || ts < math.MinInt64 || ts >= math.MaxInt64 {
+		return tagsPool, fmt.Errorf("timestamp for path %q is out of range: %v", path, point[0])
+	}
+	value, err := getPickleFloat64(point[1])
+	if err != nil {
+		return tagsPool, fmt.Errorf("cannot parse value for path %q: %s", path, err)
+	}
+	r.Timestamp = int64(ts)
+	r.Value = value
+	return tagsPool, nil
+}
+
+func getPickleSequence(v interface{}) ([]interface{}, bool) {
+	switch t := v.(type) {
+	case *pickleList:
+		return t.items, true
+	case pickleTuple:
+		return t, true
+	default:
+		return nil, false
+	}
+}
+
+func getPickleFloat64(v interface{}) (float64, error) {
+	switch t := v.(type) {
+	case int64:
+		return float64(t), nil
+	case float64:
+		return t, nil
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(t).Float64()
+		return f, nil
+	case string:
+		return strconv.ParseFloat(t, 64)
+	default:
+		return 0, fmt.Errorf("expecting number; got %T", v)
+	}
+}
+
+// pickleList is a mutable Python list.
+//
+// It is a pointer, since items may be appended to it after it is put into memo.
+type pickleList struct {
+	items []interface{}
+}
+
+// pickleTuple is an immutable Python tuple.
+type pickleTuple []interface{}
+
+// pickleMark is pushed to the stack by MARK opcode.
+type pickleMark struct{}
+
+// Pickle opcodes.
+//
+// See https://github.com/python/cpython/blob/master/Lib/pickletools.py
+const (
+	opMark            = '('
+	opStop            = '.'
+	opPop             = '0'
+	opPopMark         = '1'
+	opNone            = 'N'
+	opNewTrue         = 0x88
+	opNewFalse        = 0x89
+	opInt             = 'I'
+	opBinInt          = 'J'
+	opBinInt1         = 'K'
+	opBinInt2         = 'M'
+	opLong            = 'L'
+	opLong1           = 0x8a
+	opLong4           = 0x8b
+	opFloat           = 'F'
+	opBinFloat        = 'G'
+	opString          = 'S'
+	opBinString       = 'T'
+	opShortBinString  = 'U'
+	opUnicode         = 'V'
+	opBinUnicode      = 'X'
+	opShortBinUnicode = 0x8c
+	opBinUnicode8     = 0x8d
+	opBinBytes        = 'B'
+	opShortBinBytes   = 'C'
+	opEmptyList       = ']'
+	opList            = 'l'
+	opAppend          = 'a'
+	opAppends         = 'e'
+	opEmptyTuple      = ')'
+	opTuple           = 't'
+	opTuple1          = 0x85
+	opTuple2          = 0x86
+	opTuple3          = 0x87
+	opPut             = 'p'
+	opBinPut          = 'q'
+	opLongBinPut      = 'r'
+	opMemoize         = 0x94
+	opGet             = 'g'
+	opBinGet          = 'h'
+	opLongBinGet      = 'j'
+	opProto           = 0x80
+	opFrame           = 0x95
+)
+
+// unpickle returns the object pickled in data.
+//
+// Only a safe subset of pickle opcodes is supported.
+func unpickle(data []byte) (interface{}, error) {
+	u := &unpickler{
+		data: data,
+		memo: make(map[int]interface{}),
+	}
+	return u.run()
+}
+
+type unpickler struct {
+	data  []byte
+	stack []interface{}
+	memo  map[int]interface{}
+}
+
+func (u *unpickler) run() (interface{}, error) {
+	for {
+		if len(u.data) == 0 {
+			return nil, fmt.Errorf("missing STOP opcode")
+		}
+		op := u.data[0]
+		u.data = u.data[1:]
+		switch op {
+		case opStop:
+			if len(u.data) > 0 {
+				return nil, fmt.Errorf("unexpected data left after STOP opcode: %d bytes", len(u.data))
+			}
+			v, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+			if len(u.stack) > 0 {
+				return nil, fmt.Errorf("unexpected objects left on the stack after STOP opcode: %d", len(u.stack))
+			}
+			return v, nil
+		case opProto:
+			b, err := u.readBytes(1)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read PROTO version: %s", err)
+			}
+			if b[0] > 5 {
+				return nil, fmt.Errorf("unsupported pickle protocol version: %d", b[0])
+			}
+		case opFrame:
+			// Frames are just hints for buffering, so skip frame size.
+			if _, err := u.readBytes(8); err != nil {
+				return nil, fmt.Errorf("cannot read FRAME size: %s", err)
+			}
+		case opMark:
+			u.push(pickleMark{})
+		case opPop:
+			if _, err := u.pop(); err != nil {
+				return nil, err
+			}
+		case opPopMark:
+			if _, err := u.popMark(); err != nil {
+				return nil, err
+			}
+		case opNone:
+			u.push(nil)
+		case opNewTrue:
+			u.push(int64(1))
+		case opNewFalse:
+			u.push(int64(0))
+		case opInt:
+			line, err := u.readLine()
+			if err != nil {
+				return nil, fmt.Errorf("cannot read INT: %s", err)
+			}
+			switch line {
+			case "00":
+				u.push(int64(0))
+			case "01":
+				u.push(int64(1))
+			default:
+				n, err := strconv.ParseInt(line, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("cannot parse INT: %s", err)
+				}
+				u.push(n)
+			}
+		case opBinInt:
+			b, err := u.readBytes(4)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read BININT: %s", err)
+			}
+			u.push(int64(int32(binary.LittleEndian.Uint32(b))))
+		case opBinInt1:
+			b, err := u.readBytes(1)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read BININT1: %s", err)
+			}
+			u.push(int64(b[0]))
+		case opBinInt2:
+			b, err := u.readBytes(2)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read BININT2: %s", err)
+			}
+			u.push(int64(binary.LittleEndian.Uint16(b)))
+		case opLong:
+			line, err := u.readLine()
+			if err != nil {
+				return nil, fmt.Errorf("cannot read LONG: %s", err)
+			}
+			line = strings.TrimSuffix(line, "L")
+			n, ok := new(big.Int).SetString(line, 10)
+			if !ok {
+				return nil, fmt.Errorf("cannot parse LONG %q", line)
+			}
+			u.pushBigInt(n)
+		case opLong1, opLong4:
+			var size int
+			if op == opLong1 {
+				b, err := u.readBytes(1)
+				if err != nil {
+					return nil, fmt.Errorf("cannot read LONG1 size: %s", err)
+				}
+				size = int(b[0])
+			} else {
+				b, err := u.readBytes(4)
+				if err != nil {
+					return nil, fmt.Errorf("cannot read LONG4 size: %s", err)
+				}
+				size = int(int32(binary.LittleEndian.Uint32(b)))
+			}
+			b, err := u.readBytes(size)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read LONG1/LONG4 data: %s", err)
+			}
+			u.pushBigInt(decodeLong(b))
+		case opFloat:
+			line, err := u.readLine()
+			if err != nil {
+				return nil, fmt.Errorf("cannot read FLOAT: %s", err)
+			}
+			f, err := strconv.ParseFloat(line, 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse FLOAT: %s", err)
+			}
+			u.push(f)
+		case opBinFloat:
+			b, err := u.readBytes(8)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read BINFLOAT: %s", err)
+			}
+			u.push(math.Float64frombits(binary.BigEndian.Uint64(b)))
+		case opString:
+			line, err := u.readLine()
+			if err != nil {
+				return nil, fmt.Errorf("cannot read STRING: %s", err)
+			}
+			s, err := unquotePickleString(line)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse STRING: %s", err)
+			}
+			u.push(s)
+		case opUnicode:
+			line, err := u.readLine()
+			if err != nil {
+				return nil, fmt.Errorf("cannot read UNICODE: %s", err)
+			}
+			// Graphite paths are ASCII, so raw-unicode-escape decoding is skipped.
+			u.push(line)
+		case opShortBinString, opShortBinUnicode, opShortBinBytes:
+			b, err := u.readBytes(1)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read string size: %s", err)
+			}
+			if err := u.pushString(int(b[0])); err != nil {
+				return nil, err
+			}
+		case opBinString, opBinUnicode, opBinBytes:
+			b, err := u.readBytes(4)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read string size: %s", err)
+			}
+			if err := u.pushString(int(binary.LittleEndian.Uint32(b))); err != nil {
+				return nil, err
+			}
+		case opBinUnicode8:
+			b, err := u.readBytes(8)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read string size: %s", err)
+			}
+			size := binary.LittleEndian.Uint64(b)
+			if size > uint64(len(u.data)) {
+				return nil, fmt.Errorf("too big string size: %d bytes", size)
+			}
+			if err := u.pushString(int(size)); err != nil {
+				return nil, err
+			}
+		case opEmptyList:
+			u.push(&pickleList{})
+		case opList:
+			items, err := u.popMark()
+			if err != nil {
+				return nil, err
+			}
+			u.push(&pickleList{
+				items: items,
+			})
+		case opAppend:
+			v, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+			pl, err := u.topList()
+			if err != nil {
+				return nil, err
+			}
+			pl.items = append(pl.items, v)
+		case opAppends:
+			items, err := u.popMark()
+			if err != nil {
+				return nil, err
+			}
+			pl, err := u.topList()
+			if err != nil {
+				return nil, err
+			}
+			pl.items = append(pl.items, items...)
+		case opEmptyTuple:
+			u.push(pickleTuple{})
+		case opTuple:
+			items, err := u.popMark()
+			if err != nil {
+				return nil, err
+			}
+			u.push(pickleTuple(items))
+		case opTuple1, opTuple2, opTuple3:
+			n := int(op-opTuple1) + 1
+			if len(u.stack) < n {
+				return nil, fmt.Errorf("not enough objects on the stack for TUPLE%d", n)
+			}
+			items := append([]interface{}{}, u.stack[len(u.stack)-n:]...)
+			u.stack = u.stack[:len(u.stack)-n]
+			for _, item := range items {
+				if _, ok := item.(pickleMark); ok {
+					return nil, fmt.Errorf("unexpected MARK in TUPLE%d", n)
+				}
+			}
+			u.push(pickleTuple(items))
+		case opPut:
+			line, err := u.readLine()
+			if err != nil {
+				return nil, fmt.Errorf("cannot read PUT: %s", err)
+			}
+			n, err := strconv.Atoi(line)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse PUT index: %s", err)
+			}
+			if err := u.memoPut(n); err != nil {
+				return nil, err
+			}
+		case opBinPut:
+			b, err := u.readBytes(1)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read BINPUT: %s", err)
+			}
+			if err := u.memoPut(int(b[0])); err != nil {
+				return nil, err
+			}
+		case opLongBinPut:
+			b, err := u.readBytes(4)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read LONG_BINPUT: %s", err)
+			}
+			if err := u.memoPut(int(binary.LittleEndian.Uint32(b))); err != nil {
+				return nil, err
+			}
+		case opMemoize:
+			if err := u.memoPut(len(u.memo)); err != nil {
+				return nil, err
+			}
+		case opGet:
+			line, err := u.readLine()
+			if err != nil {
+				return nil, fmt.Errorf("cannot read GET: %s", err)
+			}
+			n, err := strconv.Atoi(line)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse GET index: %s", err)
+			}
+			if err := u.memoGet(n); err != nil {
+				return nil, err
+			}
+		case opBinGet:
+			b, err := u.readBytes(1)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read BINGET: %s", err)
+			}
+			if err := u.memoGet(int(b[0])); err != nil {
+				return nil, err
+			}
+		case opLongBinGet:
+			b, err := u.readBytes(4)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read LONG_BINGET: %s", err)
+			}
+			if err := u.memoGet(int(binary.LittleEndian.Uint32(b))); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported opcode 0x%02x", op)
+		}
+	}
+}
+
+func (u *unpickler) push(v interface{}) {
+	u.stack = append(u.stack, v)
+}
+
+func (u *unpickler) pushBigInt(n *big.Int) {
+	if n.IsInt64() {
+		u.push(n.Int64())
+		return
+	}
+	u.push(n)
+}
+
+func (u *unpickler) pushString(size int) error {
+	b, err := u.readBytes(size)
+	if err != nil {
+		return fmt.Errorf("cannot read string: %s", err)
+	}
+	u.push(string(b))
+	return nil
+}
+
+func (u *unpickler) pop() (interface{}, error) {
+	if len(u.stack) == 0 {
+		return nil, fmt.Errorf("cannot pop object from empty stack")
+	}
+	v := u.stack[len(u.stack)-1]
+	u.stack = u.stack[:len(u.stack)-1]
+	if _, ok := v.(pickleMark); ok {
+		return nil, fmt.Errorf("unexpected MARK on the stack")
+	}
+	return v, nil
+}
+
+// popMark pops all the objects from the stack till the topmost MARK and returns them.
+func (u *unpickler) popMark() ([]interface{}, error) {
+	for i := len(u.stack) - 1; i >= 0; i-- {
+		if _, ok := u.stack[i].(pickleMark); ok {
+			items := append([]interface{}{}, u.stack[i+1:]...)
+			u.stack = u.stack[:i]
+			return items, nil
+		}
+	}
+	return nil, fmt.Errorf("missing MARK on the stack")
+}
+
+func (u *unpickler) topList() (*pickleList, error) {
+	if len(u.stack) == 0 {
+		return nil, fmt.Errorf("missing list on the stack")
+	}
+	pl, ok := u.stack[len(u.stack)-1].(*pickleList)
+	if !ok {
+		return nil, fmt.Errorf("cannot append to %T; expecting list", u.stack[len(u.stack)-1])
+	}
+	return pl, nil
+}
+
+func (u *unpickler) memoPut(n int) error {
+	if len(u.stack) == 0 {
+		return fmt.Errorf("cannot memoize object from empty stack")
+	}
+	u.memo[n] = u.stack[len(u.stack)-1]
+	return nil
+}
+
+func (u *unpickler) memoGet(n int) error {
+	v, ok := u.memo[n]
+	if !ok {
+		return fmt.Errorf("missing memo entry #%d", n)
+	}
+	u.push(v)
+	return nil
+}
+
+func (u *unpickler) readBytes(n int) ([]byte, error) {
+	if n < 0 || n > len(u.data) {
+		return nil, fmt.Errorf("unexpected end of data; cannot read %d bytes from %d bytes", n, len(u.data))
+	}
+	b := u.data[:n]
+	u.data = u.data[n:]
+	return b, nil
+}
+
+func (u *unpickler) readLine() (string, error) {
+	for i, c := range u.data {
+		if c == '\n' {
+			line := string(u.data[:i])
+			u.data = u.data[i+1:]
+			return line, nil
+		}
+	}
+	return "", fmt.Errorf("missing newline")
+}
+
+// decodeLong decodes little-endian two's complement integer from b.
+func decodeLong(b []byte) *big.Int {
+	be := make([]byte, len(b))
+	for i, c := range b {
+		be[len(b)-1-i] = c
+	}
+	n := new(big.Int).SetBytes(be)
+	if len(b) > 0 && b[len(b)-1]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	return n
+}
+
+// unquotePickleString unquotes Python string literal from STRING opcode.
+func unquotePickleString(s string) (string, error) {
+	if len(s) < 2 || s[0] != s[len(s)-1] || (s[0] != '\'' && s[0] != '"') {
+		return "", fmt.Errorf("string must be quoted; got %q", s)
+	}
+	if s[0] == '\'' {
+		// Convert to Go-compatible double-quoted string.
+		s = `"` + strings.Replace(strings.Replace(s[1:len(s)-1], `\'`, `'`, -1), `"`, `\"`, -1) + `"`
+	}
+	return strconv.Unquote(s)
+}
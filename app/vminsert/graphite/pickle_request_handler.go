@@ -0,0 +1,75 @@
+package graphite
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/concurrencylimiter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// maxPickleMessageSize is the maximum size of a single pickle protocol message.
+//
+// carbon-relay sends up to a few thousands of datapoints per message, so this is more than enough.
+const maxPickleMessageSize = 16 * 1024 * 1024
+
+// pickleInsertHandler processes remote write for graphite pickle protocol.
+//
+// See https://graphite.readthedocs.io/en/latest/feeding-carbon.html#the-pickle-protocol
+func pickleInsertHandler(c net.Conn) error {
+	ctx := getPushCtx()
+	defer putPushCtx(ctx)
+	for ctx.ReadPickle(c) {
+		if err := concurrencylimiter.Do(ctx.InsertRows); err != nil {
+			return err
+		}
+	}
+	return ctx.Error()
+}
+
+// ReadPickle reads and unmarshals the next pickle protocol message from r.
+//
+// Every message is prefixed with its size encoded as 4-byte big-endian integer.
+func (ctx *pushCtx) ReadPickle(r io.Reader) bool {
+	pickleReadCalls.Inc()
+	if ctx.err != nil {
+		return false
+	}
+	var sizeBuf [4]byte
+	if _, err := io.ReadFull(r, sizeBuf[:]); err != nil {
+		if err != io.EOF {
+			pickleReadErrors.Inc()
+			err = fmt.Errorf("cannot read graphite pickle protocol message size: %s", err)
+		}
+		ctx.err = err
+		return false
+	}
+	size := binary.BigEndian.Uint32(sizeBuf[:])
+	if size > maxPickleMessageSize {
+		pickleReadErrors.Inc()
+		ctx.err = fmt.Errorf("too big graphite pickle protocol message: %d bytes; mustn't exceed %d bytes", size, maxPickleMessageSize)
+		return false
+	}
+	ctx.reqBuf = bytesutil.Resize(ctx.reqBuf, int(size))
+	if _, err := io.ReadFull(r, ctx.reqBuf); err != nil {
+		pickleReadErrors.Inc()
+		ctx.err = fmt.Errorf("cannot read graphite pickle protocol message with size %d: %s", size, err)
+		return false
+	}
+	if err := ctx.Rows.UnmarshalPickle(ctx.reqBuf); err != nil {
+		pickleUnmarshalErrors.Inc()
+		ctx.err = fmt.Errorf("cannot unmarshal graphite pickle protocol message with size %d: %s", size, err)
+		return false
+	}
+	ctx.Rows.normalizeTimestamps()
+	return true
+}
+
+var (
+	pickleReadCalls       = metrics.NewCounter(`vm_read_calls_total{name="graphite_pickle"}`)
+	pickleReadErrors      = metrics.NewCounter(`vm_read_errors_total{name="graphite_pickle"}`)
+	pickleUnmarshalErrors = metrics.NewCounter(`vm_unmarshal_errors_total{name="graphite_pickle"}`)
+)
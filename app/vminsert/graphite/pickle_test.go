@@ -0,0 +1,172 @@
+package graphite
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRowsUnmarshalPickleFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		var rows Rows
+		if err := rows.UnmarshalPickle([]byte(s)); err == nil {
+			t.Fatalf("expecting non-nil error when unmarshaling %q", s)
+		}
+
+		// Try again
+		if err := rows.UnmarshalPickle([]byte(s)); err == nil {
+			t.Fatalf("expecting non-nil error when unmarshaling %q", s)
+		}
+	}
+
+	// Empty data
+	f("")
+
+	// Missing STOP
+	f("\x80\x02]q\x00")
+
+	// Unexpected data after STOP
+	f("\x80\x02]q\x00.foo")
+
+	// Unsupported opcodes. The payload below calls os.system on unpickling in Python.
+	f("\x80\x02cposix\nsystem\nq\x00.")
+	f("\x80\x02cos\nsystem\nX\x02\x00\x00\x00ls\x85R.")
+
+	// Truncated string
+	f("\x80\x02]q\x00(X\x15\x00\x00\x00foo")
+
+	// Missing memo entry
+	f("\x80\x02]q\x00(h\x05e.")
+
+	// Top-level object isn't a list
+	f("\x80\x02K\x01.")
+
+	// Invalid item
+	f("\x80\x02]q\x00K\x01a.")
+
+	// Invalid path
+	f("\x80\x02]q\x00K\x01K\x01K\x02\x86\x86a.")
+
+	// Invalid tags
+	f("\x80\x02]q\x00X\x04\x00\x00\x00foo;K\x01K\x02\x86\x86a.")
+
+	// Missing value
+	f("\x80\x02]q\x00X\x03\x00\x00\x00fooK\x01\x85\x86a.")
+
+	// Too big timestamp
+	f("\x80\x02]q\x00X\x01\x00\x00\x00aq\x01\x8a\t\x00\x00\x00\x00\x00\x00\x00\x00@K\x01\x86q\x02\x86q\x03a.")
+
+	// Invalid value
+	f("\x80\x02]q\x00X\x03\x00\x00\x00fooK\x01N\x86\x86a.")
+	f("\x80\x02]q\x00X\x03\x00\x00\x00fooK\x01X\x03\x00\x00\x00abc\x86\x86a.")
+}
+
+func TestRowsUnmarshalPickleSuccess(t *testing.T) {
+	f := func(s string, rowsExpected *Rows) {
+		t.Helper()
+		var rows Rows
+		if err := rows.UnmarshalPickle([]byte(s)); err != nil {
+			t.Fatalf("cannot unmarshal %q: %s", s, err)
+		}
+		if !reflect.DeepEqual(rows.Rows, rowsExpected.Rows) {
+			t.Fatalf("unexpected rows;\ngot\n%+v;\nwant\n%+v", rows.Rows, rowsExpected.Rows)
+		}
+
+		// Try unmarshaling again
+		if err := rows.UnmarshalPickle([]byte(s)); err != nil {
+			t.Fatalf("cannot unmarshal %q: %s", s, err)
+		}
+		if !reflect.DeepEqual(rows.Rows, rowsExpected.Rows) {
+			t.Fatalf("unexpected rows;\ngot\n%+v;\nwant\n%+v", rows.Rows, rowsExpected.Rows)
+		}
+
+		rows.Reset()
+		if len(rows.Rows) != 0 {
+			t.Fatalf("non-empty rows after reset: %+v", rows.Rows)
+		}
+	}
+
+	// Empty list
+	f("\x80\x02]q\x00.", &Rows{})
+	f("(lp0\n.", &Rows{})
+
+	rowsExpected := &Rows{
+		Rows: []Row{
+			{
+				Metric: "foo.bar",
+				Tags: []Tag{
+					{
+						Key:   "env",
+						Value: "prod",
+					},
+					{
+						Key:   "dc",
+						Value: "x",
+					},
+				},
+				Value:     1.5,
+				Timestamp: 1570000000,
+			},
+			{
+				Metric:    "baz",
+				Value:     2,
+				Timestamp: 1570000001,
+			},
+		},
+	}
+
+	// pickle.dumps([("foo.bar;env=prod;dc=x", (1570000000, 1.5)), ("baz", (1570000001.0, 2))], protocol=N) for N = 0...4
+	f("(lp0\n(Vfoo.bar;env=prod;dc=x\np1\n(I1570000000\nF1.5\ntp2\ntp3\na(Vbaz\np4\n(F1570000001.0\nI2\ntp5\ntp6\na.", rowsExpected)
+	f("]q\x00((X\x15\x00\x00\x00foo.bar;env=prod;dc=xq\x01(J\x80L\x94]G?\xf8\x00\x00\x00\x00\x00\x00tq\x02tq\x03(X\x03\x00\x00\x00bazq\x04(GA\xd7e\x13 @\x00\x00K\x02tq\x05tq\x06e.", rowsExpected)
+	f("\x80\x02]q\x00(X\x15\x00\x00\x00foo.bar;env=prod;dc=xq\x01J\x80L\x94]G?\xf8\x00\x00\x00\x00\x00\x00\x86q\x02\x86q\x03X\x03\x00\x00\x00bazq\x04GA\xd7e\x13 @\x00\x00K\x02\x86q\x05\x86q\x06e.", rowsExpected)
+	f("\x80\x03]q\x00(X\x15\x00\x00\x00foo.bar;env=prod;dc=xq\x01J\x80L\x94]G?\xf8\x00\x00\x00\x00\x00\x00\x86q\x02\x86q\x03X\x03\x00\x00\x00bazq\x04GA\xd7e\x13 @\x00\x00K\x02\x86q\x05\x86q\x06e.", rowsExpected)
+	f("\x80\x04\x95D\x00\x00\x00\x00\x00\x00\x00]\x94(\x8c\x15foo.bar;env=prod;dc=x\x94J\x80L\x94]G?\xf8\x00\x00\x00\x00\x00\x00\x86\x94\x86\x94\x8c\x03baz\x94GA\xd7e\x13 @\x00\x00K\x02\x86\x94\x86\x94e.", rowsExpected)
+
+	// Python 2 carbon-relay sends byte strings
+	f("(lp0\n(S'foo'\np1\n(I1570000000\nI3\ntp2\ntp3\na.", &Rows{
+		Rows: []Row{{
+			Metric:    "foo",
+			Value:     3,
+			Timestamp: 1570000000,
+		}},
+	})
+	f("\x80\x02]q\x00(U\x03fooq\x01J\x80L\x94]L1L\n\x86q\x02\x86q\x03e.", &Rows{
+		Rows: []Row{{
+			Metric:    "foo",
+			Value:     1,
+			Timestamp: 1570000000,
+		}},
+	})
+
+	// String values, long ints and negative ints
+	f("\x80\x02]q\x00(X\x01\x00\x00\x00aq\x01K{X\x03\x00\x00\x004.5q\x02\x86q\x03\x86q\x04X\x01\x00\x00\x00bq\x05\x8a\x06\x00\x00\x00\x00\x00\x01J\xfd\xff\xff\xff\x86q\x06\x86q\x07e.", &Rows{
+		Rows: []Row{
+			{
+				Metric:    "a",
+				Value:     4.5,
+				Timestamp: 123,
+			},
+			{
+				Metric:    "b",
+				Value:     -3,
+				Timestamp: 1 << 40,
+			},
+		},
+	})
+
+	// Memoized items
+	f("\x80\x02]q\x00(X\x01\x00\x00\x00aq\x01K{K\x01\x86q\x02\x86q\x03h\x03e.", &Rows{
+		Rows: []Row{
+			{
+				Metric:    "a",
+				Value:     1,
+				Timestamp: 123,
+			},
+			{
+				Metric:    "a",
+				Value:     1,
+				Timestamp: 123,
+			},
+		},
+	})
+}
@@ -10,11 +10,15 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/concurrencylimiter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/metrics"
 )
 
-var rowsInserted = metrics.NewCounter(`vm_rows_inserted_total{type="graphite"}`)
+var (
+	rowsInserted = metrics.NewCounter(`vm_rows_inserted_total{type="graphite"}`)
+	rowsRejected = common.NewRejectedRowsCounters("graphite")
+)
 
 // insertHandler processes remote write for graphite plaintext protocol.
 //
@@ -39,7 +43,7 @@ func insertHandlerInternal(r io.Reader) error {
 func (ctx *pushCtx) InsertRows() error {
 	rows := ctx.Rows.Rows
 	ic := &ctx.Common
-	ic.Reset(len(rows))
+	ic.Reset(len(rows), rowsRejected)
 	for i := range rows {
 		r := &rows[i]
 		ic.Labels = ic.Labels[:0]
@@ -48,7 +52,8 @@ func (ctx *pushCtx) InsertRows() error {
 			tag := &r.Tags[j]
 			ic.AddLabel(tag.Key, tag.Value)
 		}
-		ic.WriteDataPoint(nil, ic.Labels, r.Timestamp, r.Value)
+		// Graphite plaintext protocol has no room for the tenant, so write data to the default tenant.
+		ic.WriteDataPoint(auth.DefaultToken, nil, ic.Labels, r.Timestamp, r.Value)
 	}
 	rowsInserted.Add(len(rows))
 	return ic.FlushBufs()
@@ -87,9 +92,16 @@ func (ctx *pushCtx) Read(r io.Reader) bool {
 		return false
 	}
 
+	ctx.Rows.normalizeTimestamps()
+	return true
+}
+
+// normalizeTimestamps fills missing timestamps in rs with the current timestamp
+// and converts all the timestamps from seconds to milliseconds.
+func (rs *Rows) normalizeTimestamps() {
 	// Fill missing timestamps with the current timestamp rounded to seconds.
 	currentTimestamp := time.Now().Unix()
-	rows := ctx.Rows.Rows
+	rows := rs.Rows
 	for i := range rows {
 		r := &rows[i]
 		if r.Timestamp == 0 {
@@ -101,8 +113,6 @@ func (ctx *pushCtx) Read(r io.Reader) bool {
 	for i := range rows {
 		rows[i].Timestamp *= 1e3
 	}
-
-	return true
 }
 
 type pushCtx struct {
@@ -124,7 +134,7 @@ func (ctx *pushCtx) Error() error {
 
 func (ctx *pushCtx) reset() {
 	ctx.Rows.Reset()
-	ctx.Common.Reset(0)
+	ctx.Common.Reset(0, nil)
 	ctx.reqBuf = ctx.reqBuf[:0]
 	ctx.tailBuf = ctx.tailBuf[:0]
 
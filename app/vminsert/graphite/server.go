@@ -18,6 +18,9 @@ var (
 
 	writeRequestsUDP = metrics.NewCounter(`vm_graphite_requests_total{name="write", net="udp"}`)
 	writeErrorsUDP   = metrics.NewCounter(`vm_graphite_request_errors_total{name="write", net="udp"}`)
+
+	writeRequestsPickle = metrics.NewCounter(`vm_graphite_requests_total{name="write", net="tcp", protocol="pickle"}`)
+	writeErrorsPickle   = metrics.NewCounter(`vm_graphite_request_errors_total{name="write", net="tcp", protocol="pickle"}`)
 )
 
 // Serve starts graphite server on the given addr.
@@ -40,7 +43,7 @@ func Serve(addr string) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		serveTCP(listenerTCP)
+		serveTCP(listenerTCP, "plaintext", insertHandlerConn, writeRequestsTCP, writeErrorsTCP)
 		logger.Infof("stopped TCP Graphite server at %q", addr)
 	}()
 	wg.Add(1)
@@ -52,7 +55,25 @@ func Serve(addr string) {
 	wg.Wait()
 }
 
-func serveTCP(ln net.Listener) {
+// ServePickle starts graphite pickle protocol server on the given addr.
+//
+// See https://graphite.readthedocs.io/en/latest/feeding-carbon.html#the-pickle-protocol
+func ServePickle(addr string) {
+	logger.Infof("starting TCP Graphite pickle server at %q", addr)
+	ln, err := net.Listen("tcp4", addr)
+	if err != nil {
+		logger.Fatalf("cannot start TCP Graphite pickle server at %q: %s", addr, err)
+	}
+	listenerPickle = ln
+	serveTCP(listenerPickle, "pickle", pickleInsertHandler, writeRequestsPickle, writeErrorsPickle)
+	logger.Infof("stopped TCP Graphite pickle server at %q", addr)
+}
+
+func insertHandlerConn(c net.Conn) error {
+	return insertHandler(c)
+}
+
+func serveTCP(ln net.Listener, protocol string, insertHandler func(c net.Conn) error, writeRequests, writeErrors *metrics.Counter) {
 	for {
 		c, err := ln.Accept()
 		if err != nil {
@@ -64,15 +85,15 @@ func serveTCP(ln net.Listener) {
 				if strings.Contains(err.Error(), "use of closed network connection") {
 					break
 				}
-				logger.Fatalf("unrecoverable error when accepting TCP Graphite connections: %s", err)
+				logger.Fatalf("unrecoverable error when accepting TCP Graphite %s connections: %s", protocol, err)
 			}
-			logger.Fatalf("unexpected error when accepting TCP Graphite connections: %s", err)
+			logger.Fatalf("unexpected error when accepting TCP Graphite %s connections: %s", protocol, err)
 		}
 		go func() {
-			writeRequestsTCP.Inc()
+			writeRequests.Inc()
 			if err := insertHandler(c); err != nil {
-				writeErrorsTCP.Inc()
-				logger.Errorf("error in TCP Graphite conn %q<->%q: %s", c.LocalAddr(), c.RemoteAddr(), err)
+				writeErrors.Inc()
+				logger.Errorf("error in TCP Graphite %s conn %q<->%q: %s", protocol, c.LocalAddr(), c.RemoteAddr(), err)
 			}
 			_ = c.Close()
 		}()
@@ -122,6 +143,8 @@ func serveUDP(ln net.PacketConn) {
 var (
 	listenerTCP net.Listener
 	listenerUDP net.PacketConn
+
+	listenerPickle net.Listener
 )
 
 // Stop stops the server.
@@ -135,3 +158,11 @@ func Stop() {
 		logger.Errorf("cannot close UDP Graphite server: %s", err)
 	}
 }
+
+// StopPickle stops the pickle protocol server.
+func StopPickle() {
+	logger.Infof("stopping TCP Graphite pickle server at %q...", listenerPickle.Addr())
+	if err := listenerPickle.Close(); err != nil {
+		logger.Errorf("cannot close TCP Graphite pickle server: %s", err)
+	}
+}
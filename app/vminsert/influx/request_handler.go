@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"runtime"
 	"sync"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/concurrencylimiter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 	"github.com/VictoriaMetrics/metrics"
@@ -22,18 +24,21 @@ var (
 	skipSingleField           = flag.Bool("influxSkipSingleField", false, "Uses `{measurement}` instead of `{measurement}{separator}{field_name}` for metic name if Influx line contains only a single field")
 )
 
-var rowsInserted = metrics.NewCounter(`vm_rows_inserted_total{type="influx"}`)
+var (
+	rowsInserted = metrics.NewCounter(`vm_rows_inserted_total{type="influx"}`)
+	rowsRejected = common.NewRejectedRowsCounters("influx")
+)
 
 // InsertHandler processes remote write for influx line protocol.
 //
 // See https://github.com/influxdata/influxdb/blob/4cbdc197b8117fee648d62e2e5be75c6575352f0/tsdb/README.md
-func InsertHandler(req *http.Request) error {
+func InsertHandler(at *auth.Token, req *http.Request) error {
 	return concurrencylimiter.Do(func() error {
-		return insertHandlerInternal(req)
+		return insertHandlerInternal(at, req)
 	})
 }
 
-func insertHandlerInternal(req *http.Request) error {
+func insertHandlerInternal(at *auth.Token, req *http.Request) error {
 	influxReadCalls.Inc()
 
 	r := req.Body
@@ -66,24 +71,40 @@ func insertHandlerInternal(req *http.Request) error {
 	// Read db tag from https://docs.influxdata.com/influxdb/v1.7/tools/api/#write-http-endpoint
 	db := q.Get("db")
 
+	return insertRows(at, r, tsMultiplier, db)
+}
+
+// insertHandlerStream processes influx line protocol data sent over raw TCP or UDP.
+//
+// Timestamps are expected in nanoseconds, since there is no room for `precision` arg in the stream.
+// Data is written to the default tenant.
+func insertHandlerStream(r io.Reader) error {
+	return concurrencylimiter.Do(func() error {
+		influxReadCalls.Inc()
+		return insertRows(auth.DefaultToken, r, 1e6, "")
+	})
+}
+
+func insertRows(at *auth.Token, r io.Reader, tsMultiplier int64, db string) error {
 	ctx := getPushCtx()
 	defer putPushCtx(ctx)
 	for ctx.Read(r, tsMultiplier) {
-		if err := ctx.InsertRows(db); err != nil {
+		if err := ctx.InsertRows(at, db); err != nil {
 			return err
 		}
 	}
 	return ctx.Error()
 }
 
-func (ctx *pushCtx) InsertRows(db string) error {
+func (ctx *pushCtx) InsertRows(at *auth.Token, db string) error {
 	rows := ctx.Rows.Rows
 	rowsLen := 0
 	for i := range rows {
 		rowsLen += len(rows[i].Tags)
 	}
 	ic := &ctx.Common
-	ic.Reset(rowsLen)
+	ic.Reset(rowsLen, rowsRejected)
+	needAllLabels := common.NeedAllLabels()
 	for i := range rows {
 		r := &rows[i]
 		ic.Labels = ic.Labels[:0]
@@ -92,7 +113,10 @@ func (ctx *pushCtx) InsertRows(db string) error {
 			tag := &r.Tags[j]
 			ic.AddLabel(tag.Key, tag.Value)
 		}
-		ctx.metricNameBuf = storage.MarshalMetricNameRaw(ctx.metricNameBuf[:0], ic.Labels)
+		labelsLen := len(ic.Labels)
+		if !needAllLabels {
+			ctx.metricNameBuf = storage.MarshalMetricNameRaw(ctx.metricNameBuf[:0], at.AccountID, at.ProjectID, ic.Labels)
+		}
 		ctx.metricGroupBuf = append(ctx.metricGroupBuf[:0], r.Measurement...)
 		skipFieldKey := len(r.Fields) == 1 && *skipSingleField
 		if !skipFieldKey {
@@ -105,9 +129,16 @@ func (ctx *pushCtx) InsertRows(db string) error {
 				ctx.metricGroupBuf = append(ctx.metricGroupBuf[:metricGroupPrefixLen], f.Key...)
 			}
 			metricGroup := bytesutil.ToUnsafeString(ctx.metricGroupBuf)
+			if needAllLabels {
+				// Relabeling and stream aggregation rules need all the labels, so the metric name prefix cannot be used.
+				ic.Labels = ic.Labels[:labelsLen]
+				ic.AddLabel("", metricGroup)
+				ic.WriteDataPoint(at, nil, ic.Labels, r.Timestamp, f.Value)
+				continue
+			}
 			ic.Labels = ic.Labels[:0]
 			ic.AddLabel("", metricGroup)
-			ic.WriteDataPoint(ctx.metricNameBuf, ic.Labels[:1], r.Timestamp, f.Value)
+			ic.WriteDataPoint(at, ctx.metricNameBuf, ic.Labels[:1], r.Timestamp, f.Value)
 		}
 		rowsInserted.Add(len(r.Fields))
 	}
@@ -133,17 +164,31 @@ func putGzipReader(zr *gzip.Reader) {
 
 var gzipReaderPool sync.Pool
 
+const flushTimeout = 3 * time.Second
+
 func (ctx *pushCtx) Read(r io.Reader, tsMultiplier int64) bool {
 	if ctx.err != nil {
 		return false
 	}
+	if c, ok := r.(net.Conn); ok {
+		if err := c.SetReadDeadline(time.Now().Add(flushTimeout)); err != nil {
+			influxReadErrors.Inc()
+			ctx.err = fmt.Errorf("cannot set read deadline: %s", err)
+			return false
+		}
+	}
 	ctx.reqBuf, ctx.tailBuf, ctx.err = common.ReadLinesBlock(r, ctx.reqBuf, ctx.tailBuf)
 	if ctx.err != nil {
-		if ctx.err != io.EOF {
-			influxReadErrors.Inc()
-			ctx.err = fmt.Errorf("cannot read influx line protocol data: %s", ctx.err)
+		if ne, ok := ctx.err.(net.Error); ok && ne.Timeout() {
+			// Flush the read data on timeout and try reading again.
+			ctx.err = nil
+		} else {
+			if ctx.err != io.EOF {
+				influxReadErrors.Inc()
+				ctx.err = fmt.Errorf("cannot read influx line protocol data: %s", ctx.err)
+			}
+			return false
 		}
-		return false
 	}
 	if err := ctx.Rows.Unmarshal(bytesutil.ToUnsafeString(ctx.reqBuf)); err != nil {
 		influxUnmarshalErrors.Inc()
@@ -203,7 +248,7 @@ func (ctx *pushCtx) Error() error {
 
 func (ctx *pushCtx) reset() {
 	ctx.Rows.Reset()
-	ctx.Common.Reset(0)
+	ctx.Common.Reset(0, nil)
 
 	ctx.reqBuf = ctx.reqBuf[:0]
 	ctx.tailBuf = ctx.tailBuf[:0]
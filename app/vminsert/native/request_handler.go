@@ -0,0 +1,254 @@
+package native
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/concurrencylimiter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// maxBlockSize is the maximum size of a single MetricBlock accepted by /api/v1/import/native.
+//
+// Blocks in the storage contain up to a few thousands of compressed rows, so real blocks are much smaller.
+const maxBlockSize = 64 * 1024 * 1024
+
+var (
+	rowsInserted = metrics.NewCounter(`vm_rows_inserted_total{type="native"}`)
+	rowsRejected = common.NewRejectedRowsCounters("native")
+)
+
+// InsertHandler processes `/api/v1/import/native` request for the given tenant.
+//
+// The request body must contain data obtained from `/api/v1/export/native`.
+func InsertHandler(at *auth.Token, req *http.Request) error {
+	return concurrencylimiter.Do(func() error {
+		return insertHandlerInternal(at, req)
+	})
+}
+
+func insertHandlerInternal(at *auth.Token, req *http.Request) error {
+	nativeReadCalls.Inc()
+
+	r := req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := getGzipReader(r)
+		if err != nil {
+			return fmt.Errorf("cannot read gzipped native data: %s", err)
+		}
+		defer putGzipReader(zr)
+		r = zr
+	}
+
+	ctx := getPushCtx()
+	defer putPushCtx(ctx)
+	if err := ctx.ReadHeader(r); err != nil {
+		return err
+	}
+	for ctx.Read(r) {
+		if err := ctx.InsertRows(at); err != nil {
+			return err
+		}
+	}
+	return ctx.Error()
+}
+
+func (ctx *pushCtx) InsertRows(at *auth.Token) error {
+	mn := &ctx.mn
+	ic := &ctx.Common
+	ic.Reset(len(ctx.Timestamps), rowsRejected)
+	ic.Labels = ic.Labels[:0]
+	ic.AddLabelBytes(nil, mn.MetricGroup)
+	for i := range mn.Tags {
+		tag := &mn.Tags[i]
+		ic.AddLabelBytes(tag.Key, tag.Value)
+	}
+	// The tenant from the exported metric name is ignored, so the data is imported to the given tenant.
+	var metricNameRaw []byte
+	for i, timestamp := range ctx.Timestamps {
+		metricNameRaw = ic.WriteDataPointExt(at, metricNameRaw, ic.Labels, timestamp, ctx.Values[i])
+	}
+	rowsInserted.Add(len(ctx.Timestamps))
+	return ic.FlushBufs()
+}
+
+// ReadHeader reads native format header from r.
+func (ctx *pushCtx) ReadHeader(r io.Reader) error {
+	ctx.buf = bytesutil.Resize(ctx.buf, storage.NativeHeaderSize)
+	if _, err := io.ReadFull(r, ctx.buf); err != nil {
+		nativeReadErrors.Inc()
+		return fmt.Errorf("cannot read native header: %s", err)
+	}
+	tr, err := storage.UnmarshalNativeHeader(ctx.buf)
+	if err != nil {
+		nativeUnmarshalErrors.Inc()
+		return fmt.Errorf("cannot unmarshal native header: %s", err)
+	}
+	ctx.tr = tr
+	return nil
+}
+
+// Read reads the next MetricBlock from r and unpacks it into ctx.mn, ctx.Timestamps and ctx.Values.
+//
+// Samples outside the time range from the native header are skipped.
+func (ctx *pushCtx) Read(r io.Reader) bool {
+	if ctx.err != nil {
+		return false
+	}
+	ctx.buf = bytesutil.Resize(ctx.buf, 4)
+	if _, err := io.ReadFull(r, ctx.buf); err != nil {
+		if err != io.EOF {
+			nativeReadErrors.Inc()
+			ctx.err = fmt.Errorf("cannot read block size: %s", err)
+		}
+		return false
+	}
+	blockSize := storage.UnmarshalNativeMetricBlockSize(ctx.buf)
+	if blockSize > maxBlockSize {
+		nativeReadErrors.Inc()
+		ctx.err = fmt.Errorf("too big block size: %d bytes; it mustn't exceed %d bytes", blockSize, maxBlockSize)
+		return false
+	}
+	ctx.buf = bytesutil.Resize(ctx.buf, int(blockSize))
+	if _, err := io.ReadFull(r, ctx.buf); err != nil {
+		nativeReadErrors.Inc()
+		ctx.err = fmt.Errorf("cannot read block with size %d bytes: %s", blockSize, err)
+		return false
+	}
+	if err := ctx.unpackBlock(ctx.buf); err != nil {
+		nativeUnmarshalErrors.Inc()
+		ctx.err = fmt.Errorf("cannot unmarshal block with size %d bytes: %s", blockSize, err)
+		return false
+	}
+	return true
+}
+
+func (ctx *pushCtx) unpackBlock(data []byte) error {
+	mb := &ctx.mb
+	mb.Block = &ctx.block
+	tail, err := mb.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	if len(tail) > 0 {
+		return fmt.Errorf("unexpected tail left after unmarshaling MetricBlock; len(tail)=%d", len(tail))
+	}
+	if err := ctx.mn.Unmarshal(mb.MetricName); err != nil {
+		return fmt.Errorf("cannot unmarshal MetricName: %s", err)
+	}
+	b := mb.Block
+	if b.RowsCount() <= 0 {
+		return fmt.Errorf("block must contain at least a single row")
+	}
+	if err := b.UnmarshalData(); err != nil {
+		return fmt.Errorf("cannot unmarshal block data: %s", err)
+	}
+	timestamps := b.Timestamps()
+	values := b.Values()
+
+	// Skip samples outside the exported time range.
+	i := 0
+	for i < len(timestamps) && timestamps[i] < ctx.tr.MinTimestamp {
+		i++
+	}
+	j := len(timestamps)
+	for j > i && timestamps[j-1] > ctx.tr.MaxTimestamp {
+		j--
+	}
+	ctx.Timestamps = append(ctx.Timestamps[:0], timestamps[i:j]...)
+	ctx.Values = decimal.AppendDecimalToFloat(ctx.Values[:0], values[i:j], b.Scale())
+	return nil
+}
+
+var (
+	nativeReadCalls       = metrics.NewCounter(`vm_read_calls_total{name="native"}`)
+	nativeReadErrors      = metrics.NewCounter(`vm_read_errors_total{name="native"}`)
+	nativeUnmarshalErrors = metrics.NewCounter(`vm_unmarshal_errors_total{name="native"}`)
+)
+
+type pushCtx struct {
+	Common common.InsertCtx
+
+	// Timestamps and Values contain unpacked samples for mn obtained in Read.
+	Timestamps []int64
+	Values     []float64
+
+	tr    storage.TimeRange
+	mn    storage.MetricName
+	mb    storage.MetricBlock
+	block storage.Block
+	buf   []byte
+
+	err error
+}
+
+func (ctx *pushCtx) Error() error {
+	return ctx.err
+}
+
+func (ctx *pushCtx) reset() {
+	ctx.Common.Reset(0, nil)
+
+	ctx.Timestamps = ctx.Timestamps[:0]
+	ctx.Values = ctx.Values[:0]
+
+	ctx.tr = storage.TimeRange{}
+	ctx.mn.Reset()
+	ctx.mb.MetricName = ctx.mb.MetricName[:0]
+	ctx.block.Reset()
+	ctx.buf = ctx.buf[:0]
+
+	ctx.err = nil
+}
+
+func getGzipReader(r io.Reader) (*gzip.Reader, error) {
+	v := gzipReaderPool.Get()
+	if v == nil {
+		return gzip.NewReader(r)
+	}
+	zr := v.(*gzip.Reader)
+	if err := zr.Reset(r); err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
+func putGzipReader(zr *gzip.Reader) {
+	_ = zr.Close()
+	gzipReaderPool.Put(zr)
+}
+
+var gzipReaderPool sync.Pool
+
+func getPushCtx() *pushCtx {
+	select {
+	case ctx := <-pushCtxPoolCh:
+		return ctx
+	default:
+		if v := pushCtxPool.Get(); v != nil {
+			return v.(*pushCtx)
+		}
+		return &pushCtx{}
+	}
+}
+
+func putPushCtx(ctx *pushCtx) {
+	ctx.reset()
+	select {
+	case pushCtxPoolCh <- ctx:
+	default:
+		pushCtxPool.Put(ctx)
+	}
+}
+
+var pushCtxPool sync.Pool
+var pushCtxPoolCh = make(chan *pushCtx, runtime.GOMAXPROCS(-1))
@@ -0,0 +1,118 @@
+package native
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestPushCtxReadSuccess(t *testing.T) {
+	var mn storage.MetricName
+	mn.AccountID = 12
+	mn.MetricGroup = []byte("foo")
+	mn.AddTag("job", "bar")
+	var b storage.Block
+	b.Init(&storage.TSID{MetricID: 1}, []int64{1000, 2000, 3000, 4000}, []int64{10, 20, 30, 40}, -1, 64)
+	b.MarshalData(0, 0)
+	mb := &storage.MetricBlock{
+		MetricName: mn.Marshal(nil),
+		Block:      &b,
+	}
+	tr := storage.TimeRange{
+		MinTimestamp: 2000,
+		MaxTimestamp: 3500,
+	}
+	data := storage.MarshalNativeHeader(nil, tr)
+	data = storage.MarshalNativeMetricBlock(data, mb)
+	data = storage.MarshalNativeMetricBlock(data, mb)
+
+	ctx := getPushCtx()
+	defer putPushCtx(ctx)
+	r := bytes.NewReader(data)
+	if err := ctx.ReadHeader(r); err != nil {
+		t.Fatalf("cannot read header: %s", err)
+	}
+	blocksCount := 0
+	for ctx.Read(r) {
+		blocksCount++
+		if !reflect.DeepEqual(&ctx.mn, &mn) {
+			t.Fatalf("unexpected metric name; got %s; want %s", &ctx.mn, &mn)
+		}
+		if !reflect.DeepEqual(ctx.Timestamps, []int64{2000, 3000}) {
+			t.Fatalf("unexpected timestamps; got %d", ctx.Timestamps)
+		}
+		if !reflect.DeepEqual(ctx.Values, []float64{2, 3}) {
+			t.Fatalf("unexpected values; got %v", ctx.Values)
+		}
+	}
+	if err := ctx.Error(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if blocksCount != 2 {
+		t.Fatalf("unexpected number of blocks read; got %d; want 2", blocksCount)
+	}
+}
+
+func TestPushCtxReadFailure(t *testing.T) {
+	header := storage.MarshalNativeHeader(nil, storage.TimeRange{})
+	f := func(data []byte) {
+		t.Helper()
+		ctx := getPushCtx()
+		defer putPushCtx(ctx)
+		r := bytes.NewReader(data)
+		if err := ctx.ReadHeader(r); err != nil {
+			t.Fatalf("cannot read header: %s", err)
+		}
+		for ctx.Read(r) {
+		}
+		if err := ctx.Error(); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// Truncated block size
+	f(append(header, 1, 2))
+
+	// Too big block size
+	f(append(header, 0xff, 0xff, 0xff, 0xff))
+
+	// Truncated block
+	f(append(header, 0, 0, 0, 10, 1, 2, 3))
+
+	// Invalid block
+	f(append(header, 0, 0, 0, 3, 1, 2, 3))
+
+	// Small block with too many rows in the header
+	var mn storage.MetricName
+	mn.MetricGroup = []byte("foo")
+	const rowsCount = 1 << 20
+	timestamps := make([]int64, rowsCount)
+	values := make([]int64, rowsCount)
+	for i := range timestamps {
+		timestamps[i] = int64(i) * 1000
+	}
+	var b storage.Block
+	b.Init(&storage.TSID{MetricID: 1}, timestamps, values, 0, 64)
+	b.MarshalData(0, 0)
+	mb := &storage.MetricBlock{
+		MetricName: mn.Marshal(nil),
+		Block:      &b,
+	}
+	f(storage.MarshalNativeMetricBlock(header, mb))
+}
+
+func TestPushCtxReadHeaderFailure(t *testing.T) {
+	f := func(data []byte) {
+		t.Helper()
+		ctx := getPushCtx()
+		defer putPushCtx(ctx)
+		if err := ctx.ReadHeader(bytes.NewReader(data)); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	f(nil)
+	f([]byte("foobar"))
+	f([]byte("foobarbazfoobarbazfoobarbazfoobarbaz"))
+}
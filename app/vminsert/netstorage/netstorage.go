@@ -0,0 +1,326 @@
+package netstorage
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/handshake"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metrics"
+	xxhash "github.com/cespare/xxhash/v2"
+)
+
+// sendTimeout is the maximum duration for sending a single packet with rows
+// to vmstorage and receiving the response.
+const sendTimeout = time.Minute
+
+// maxErrorMessageSize is the maximum size of error message received from vmstorage.
+const maxErrorMessageSize = 64 * 1024
+
+// brokenNodeRecheckInterval is the interval after which rows are sent again
+// to the vmstorage node, which failed to accept rows.
+const brokenNodeRecheckInterval = 5 * time.Second
+
+// storageNode is a client sending data to a single vmstorage node.
+type storageNode struct {
+	// brokenUntil is unix timestamp in nanoseconds until which rows aren't sent to the node
+	// after unsuccessful attempt to send rows to it.
+	//
+	// It is accessed atomically, so it must go at the top of the struct in order to be properly aligned on 32-bit archs.
+	brokenUntil int64
+
+	addr string
+
+	connsLock sync.Mutex
+	conns     []*handshake.BufferedConn
+
+	// The number of rows sent to the node.
+	rowsSent *metrics.Counter
+
+	// The number of errors during sending rows to the node.
+	sendErrors *metrics.Counter
+
+	// The number of dial errors to the node.
+	dialErrors *metrics.Counter
+
+	// The number of rows rerouted to other nodes, since the node couldn't accept them.
+	rowsRerouted *metrics.Counter
+}
+
+// isBroken returns true if rows cannot be sent to sn now.
+func (sn *storageNode) isBroken() bool {
+	return time.Now().UnixNano() < atomic.LoadInt64(&sn.brokenUntil)
+}
+
+// markBroken prevents from sending rows to sn during brokenNodeRecheckInterval.
+func (sn *storageNode) markBroken() {
+	atomic.StoreInt64(&sn.brokenUntil, time.Now().Add(brokenNodeRecheckInterval).UnixNano())
+}
+
+func (sn *storageNode) getConn() (*handshake.BufferedConn, error) {
+	sn.connsLock.Lock()
+	if n := len(sn.conns); n > 0 {
+		bc := sn.conns[n-1]
+		sn.conns[n-1] = nil
+		sn.conns = sn.conns[:n-1]
+		sn.connsLock.Unlock()
+		return bc, nil
+	}
+	sn.connsLock.Unlock()
+
+	c, err := net.DialTimeout("tcp4", sn.addr, 5*time.Second)
+	if err != nil {
+		sn.dialErrors.Inc()
+		return nil, fmt.Errorf("cannot dial %q: %s", sn.addr, err)
+	}
+	bc, err := handshake.VMInsertClient(c)
+	if err != nil {
+		_ = c.Close()
+		sn.dialErrors.Inc()
+		return nil, fmt.Errorf("handshake error with %q: %s", sn.addr, err)
+	}
+	return bc, nil
+}
+
+func (sn *storageNode) putConn(bc *handshake.BufferedConn) {
+	sn.connsLock.Lock()
+	sn.conns = append(sn.conns, bc)
+	sn.connsLock.Unlock()
+}
+
+func (sn *storageNode) closeConns() {
+	sn.connsLock.Lock()
+	for _, bc := range sn.conns {
+		_ = bc.Close()
+	}
+	sn.conns = nil
+	sn.connsLock.Unlock()
+}
+
+// sendRows sends buf with marshaled rows to sn.
+func (sn *storageNode) sendRows(buf []byte, rowsCount int) error {
+	bc, err := sn.getConn()
+	if err != nil {
+		sn.sendErrors.Inc()
+		return err
+	}
+	errMsg, err := sn.sendPacket(bc, buf)
+	if err != nil {
+		// The connection may be broken, so close it.
+		_ = bc.Close()
+		sn.sendErrors.Inc()
+		return fmt.Errorf("cannot send %d rows to vmstorage %q: %s", rowsCount, sn.addr, err)
+	}
+	sn.putConn(bc)
+	if len(errMsg) > 0 {
+		sn.sendErrors.Inc()
+		return fmt.Errorf("vmstorage %q cannot store %d rows: %s", sn.addr, rowsCount, errMsg)
+	}
+	sn.rowsSent.Add(rowsCount)
+	return nil
+}
+
+func (sn *storageNode) sendPacket(bc *handshake.BufferedConn, buf []byte) (string, error) {
+	if err := bc.SetDeadline(time.Now().Add(sendTimeout)); err != nil {
+		return "", fmt.Errorf("cannot set deadline: %s", err)
+	}
+	if err := writeBytes(bc, buf); err != nil {
+		return "", fmt.Errorf("cannot write packet: %s", err)
+	}
+	if err := bc.Flush(); err != nil {
+		return "", fmt.Errorf("cannot flush packet: %s", err)
+	}
+	errMsg, err := readBytes(nil, bc, maxErrorMessageSize)
+	if err != nil {
+		return "", fmt.Errorf("cannot read response: %s", err)
+	}
+	if err := bc.SetDeadline(time.Time{}); err != nil {
+		return "", fmt.Errorf("cannot reset deadline: %s", err)
+	}
+	return string(errMsg), nil
+}
+
+var storageNodes []*storageNode
+
+// InitStorageNodes initializes vmstorage nodes' connections to the given addrs.
+//
+// Rows are written to the local vmstorage if addrs is empty.
+func InitStorageNodes(addrs []string) {
+	for _, addr := range addrs {
+		sn := &storageNode{
+			addr: addr,
+
+			rowsSent:   metrics.NewCounter(fmt.Sprintf(`vm_rpc_rows_sent_total{name="vminsert", addr=%q}`, addr)),
+			sendErrors: metrics.NewCounter(fmt.Sprintf(`vm_rpc_send_errors_total{name="vminsert", addr=%q}`, addr)),
+			dialErrors: metrics.NewCounter(fmt.Sprintf(`vm_rpc_dial_errors_total{name="vminsert", addr=%q}`, addr)),
+
+			rowsRerouted: metrics.NewCounter(fmt.Sprintf(`vm_rpc_rows_rerouted_total{name="vminsert", addr=%q}`, addr)),
+		}
+		storageNodes = append(storageNodes, sn)
+	}
+	if len(storageNodes) > 0 {
+		logger.Infof("initialized %d vmstorage nodes for vminsert: %q", len(storageNodes), addrs)
+	}
+}
+
+// Stop stops netstorage.
+func Stop() {
+	for _, sn := range storageNodes {
+		sn.closeConns()
+	}
+}
+
+// AddRows adds mrs to the storage.
+//
+// Rows are sharded among vmstorage nodes passed to InitStorageNodes
+// by the hash of MetricNameRaw, so all the rows for a single time series
+// go to the same node. Rows for the node, which cannot accept them,
+// are rerouted to the remaining nodes.
+func AddRows(mrs []storage.MetricRow) error {
+	if len(storageNodes) == 0 {
+		return vmstorage.AddRows(mrs)
+	}
+
+	ctx := getAddRowsCtx()
+	defer putAddRowsCtx(ctx)
+
+	for i := range mrs {
+		mr := &mrs[i]
+		idx := getStorageNodeIdx(xxhash.Sum64(mr.MetricNameRaw))
+		ctx.bufs[idx] = mr.Marshal(ctx.bufs[idx])
+		ctx.rowsCounts[idx]++
+	}
+
+	// Send rows to storage nodes in parallel.
+	errCh := make(chan error, len(storageNodes))
+	for idx, sn := range storageNodes {
+		if ctx.rowsCounts[idx] == 0 {
+			errCh <- nil
+			continue
+		}
+		go func(idx int, sn *storageNode) {
+			err := sn.sendRows(ctx.bufs[idx], ctx.rowsCounts[idx])
+			if err != nil {
+				sn.markBroken()
+				err = rerouteRows(idx, ctx.bufs[idx], ctx.rowsCounts[idx], err)
+			}
+			errCh <- err
+		}(idx, sn)
+	}
+	var errors []error
+	for range storageNodes {
+		if err := <-errCh; err != nil {
+			errors = append(errors, err)
+		}
+	}
+	if len(errors) > 0 {
+		// Return only the first error, since it has no sense in returning all errors.
+		return fmt.Errorf("error occurred during sending rows to vmstorage nodes: %s", errors[0])
+	}
+	return nil
+}
+
+// getStorageNodeIdx returns the index of the node for rows with the given hash.
+//
+// The next node is returned if the node for the given hash is broken.
+func getStorageNodeIdx(h uint64) int {
+	n := len(storageNodes)
+	idx := int(h % uint64(n))
+	for i := 0; i < n; i++ {
+		if !storageNodes[(idx+i)%n].isBroken() {
+			return (idx + i) % n
+		}
+	}
+	// All the nodes are broken. Try sending rows to the original node.
+	return idx
+}
+
+// rerouteRows sends buf with rowsCount marshaled rows to nodes other than storageNodes[idx].
+//
+// sendErr is the error returned when sending rows to storageNodes[idx].
+func rerouteRows(idx int, buf []byte, rowsCount int, sendErr error) error {
+	n := len(storageNodes)
+	for i := 1; i < n; i++ {
+		sn := storageNodes[(idx+i)%n]
+		if sn.isBroken() {
+			continue
+		}
+		if err := sn.sendRows(buf, rowsCount); err != nil {
+			sn.markBroken()
+			continue
+		}
+		storageNodes[idx].rowsRerouted.Add(rowsCount)
+		return nil
+	}
+	return fmt.Errorf("cannot send %d rows to any of %d vmstorage nodes: %s", rowsCount, n, sendErr)
+}
+
+type addRowsCtx struct {
+	bufs       [][]byte
+	rowsCounts []int
+}
+
+func getAddRowsCtx() *addRowsCtx {
+	v := addRowsCtxPool.Get()
+	if v == nil {
+		v = &addRowsCtx{
+			bufs:       make([][]byte, len(storageNodes)),
+			rowsCounts: make([]int, len(storageNodes)),
+		}
+	}
+	return v.(*addRowsCtx)
+}
+
+func putAddRowsCtx(ctx *addRowsCtx) {
+	for i := range ctx.bufs {
+		ctx.bufs[i] = ctx.bufs[i][:0]
+		ctx.rowsCounts[i] = 0
+	}
+	addRowsCtxPool.Put(ctx)
+}
+
+var addRowsCtxPool sync.Pool
+
+func writeBytes(w io.Writer, buf []byte) error {
+	var sizeBuf [8]byte
+	if _, err := w.Write(encoding.MarshalUint64(sizeBuf[:0], uint64(len(buf)))); err != nil {
+		return fmt.Errorf("cannot write data size: %s", err)
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+	if _, err := w.Write(buf); err != nil {
+		return fmt.Errorf("cannot write data with size %d: %s", len(buf), err)
+	}
+	return nil
+}
+
+func readBytes(buf []byte, r io.Reader, maxDataSize int) ([]byte, error) {
+	var sizeBuf [8]byte
+	if _, err := io.ReadFull(r, sizeBuf[:]); err != nil {
+		return buf, fmt.Errorf("cannot read data size: %s", err)
+	}
+	n := encoding.UnmarshalUint64(sizeBuf[:])
+	if n > uint64(maxDataSize) {
+		return buf, fmt.Errorf("too big data size: %d; it mustn't exceed %d bytes", n, maxDataSize)
+	}
+	if n == 0 {
+		return buf, nil
+	}
+	bufLen := len(buf)
+	if m := bufLen + int(n) - cap(buf); m > 0 {
+		buf = append(buf[:cap(buf)], make([]byte, m)...)
+	}
+	buf = buf[:bufLen+int(n)]
+	if _, err := io.ReadFull(r, buf[bufLen:]); err != nil {
+		return buf, fmt.Errorf("cannot read data with size %d: %s", n, err)
+	}
+	return buf, nil
+}
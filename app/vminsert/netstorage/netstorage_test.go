@@ -0,0 +1,121 @@
+package netstorage
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/handshake"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestAddRowsReroute(t *testing.T) {
+	// Start a single vmstorage stub and obtain an address without listener for the unavailable vmstorage.
+	var rowsReceived uint64
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot start listener: %s", err)
+	}
+	var wg sync.WaitGroup
+	defer func() {
+		_ = ln.Close()
+		wg.Wait()
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serveVMInsertStub(ln, &rowsReceived)
+	}()
+	lnBroken, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot start listener: %s", err)
+	}
+	brokenAddr := lnBroken.Addr().String()
+	_ = lnBroken.Close()
+
+	InitStorageNodes([]string{brokenAddr, ln.Addr().String()})
+	defer func() {
+		Stop()
+		storageNodes = nil
+	}()
+
+	addRows := func(rowsCount int) {
+		t.Helper()
+		mrs := make([]storage.MetricRow, rowsCount)
+		for i := range mrs {
+			mrs[i] = storage.MetricRow{
+				MetricNameRaw: []byte(fmt.Sprintf("metric_%d", i)),
+				Timestamp:     int64(i),
+				Value:         float64(i),
+			}
+		}
+		if err := AddRows(mrs); err != nil {
+			t.Fatalf("unexpected error when adding rows: %s", err)
+		}
+	}
+
+	// Rows for the unavailable node must be rerouted to the remaining node.
+	addRows(100)
+	if n := atomic.LoadUint64(&rowsReceived); n != 100 {
+		t.Fatalf("unexpected number of rows received; got %d; want %d", n, 100)
+	}
+	if n := storageNodes[0].rowsRerouted.Get(); n == 0 {
+		t.Fatalf("expecting non-zero number of rerouted rows")
+	}
+	if !storageNodes[0].isBroken() {
+		t.Fatalf("the unavailable node must be marked as broken")
+	}
+
+	// Rows must be sent directly to the remaining node while the unavailable node is marked as broken.
+	rowsRerouted := storageNodes[0].rowsRerouted.Get()
+	addRows(100)
+	if n := atomic.LoadUint64(&rowsReceived); n != 200 {
+		t.Fatalf("unexpected number of rows received; got %d; want %d", n, 200)
+	}
+	if n := storageNodes[0].rowsRerouted.Get(); n != rowsRerouted {
+		t.Fatalf("unexpected number of rerouted rows; got %d; want %d", n, rowsRerouted)
+	}
+}
+
+func serveVMInsertStub(ln net.Listener, rowsReceived *uint64) {
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			bc, err := handshake.VMInsertServer(c)
+			if err != nil {
+				_ = c.Close()
+				return
+			}
+			defer func() {
+				_ = bc.Close()
+			}()
+			var buf []byte
+			var mr storage.MetricRow
+			for {
+				buf, err = readBytes(buf[:0], bc, 1024*1024)
+				if err != nil {
+					return
+				}
+				tail := buf
+				for len(tail) > 0 {
+					tail, err = mr.Unmarshal(tail)
+					if err != nil {
+						return
+					}
+					atomic.AddUint64(rowsReceived, 1)
+				}
+				if err := writeBytes(bc, nil); err != nil {
+					return
+				}
+				if err := bc.Flush(); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,924 @@
+// Code generated manually from opentelemetry/proto/collector/metrics/v1/metrics_service.proto
+// and opentelemetry/proto/metrics/v1/metrics.proto
+
+package pb
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ExportMetricsServiceRequest represents the corresponding OTLP protobuf message.
+type ExportMetricsServiceRequest struct {
+	ResourceMetrics []ResourceMetrics
+}
+
+// Reset resets req.
+func (req *ExportMetricsServiceRequest) Reset() {
+	for i := range req.ResourceMetrics {
+		req.ResourceMetrics[i] = ResourceMetrics{}
+	}
+	req.ResourceMetrics = req.ResourceMetrics[:0]
+}
+
+// Unmarshal unmarshals req from src.
+//
+// req is reset before unmarshaling.
+func (req *ExportMetricsServiceRequest) Unmarshal(src []byte) error {
+	req.Reset()
+	// message ExportMetricsServiceRequest {
+	//   repeated ResourceMetrics resource_metrics = 1;
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in ExportMetricsServiceRequest: %s", err)
+		}
+		src = tail
+		if fc.num == 1 {
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read ResourceMetrics: %s", err)
+			}
+			req.ResourceMetrics = append(req.ResourceMetrics, ResourceMetrics{})
+			rm := &req.ResourceMetrics[len(req.ResourceMetrics)-1]
+			if err := rm.unmarshal(data); err != nil {
+				return fmt.Errorf("cannot unmarshal ResourceMetrics: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+// ResourceMetrics represents the corresponding OTLP protobuf message.
+type ResourceMetrics struct {
+	Resource     Resource
+	ScopeMetrics []ScopeMetrics
+}
+
+func (rm *ResourceMetrics) unmarshal(src []byte) error {
+	// message ResourceMetrics {
+	//   Resource resource = 1;
+	//   repeated ScopeMetrics scope_metrics = 2;
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in ResourceMetrics: %s", err)
+		}
+		src = tail
+		switch fc.num {
+		case 1:
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read Resource: %s", err)
+			}
+			if err := rm.Resource.unmarshal(data); err != nil {
+				return fmt.Errorf("cannot unmarshal Resource: %s", err)
+			}
+		case 2:
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read ScopeMetrics: %s", err)
+			}
+			rm.ScopeMetrics = append(rm.ScopeMetrics, ScopeMetrics{})
+			sm := &rm.ScopeMetrics[len(rm.ScopeMetrics)-1]
+			if err := sm.unmarshal(data); err != nil {
+				return fmt.Errorf("cannot unmarshal ScopeMetrics: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Resource represents the corresponding OTLP protobuf message.
+type Resource struct {
+	Attributes []KeyValue
+}
+
+func (r *Resource) unmarshal(src []byte) error {
+	// message Resource {
+	//   repeated KeyValue attributes = 1;
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in Resource: %s", err)
+		}
+		src = tail
+		if fc.num == 1 {
+			if r.Attributes, err = appendKeyValue(r.Attributes, fc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ScopeMetrics represents the corresponding OTLP protobuf message.
+type ScopeMetrics struct {
+	Scope   InstrumentationScope
+	Metrics []Metric
+}
+
+func (sm *ScopeMetrics) unmarshal(src []byte) error {
+	// message ScopeMetrics {
+	//   InstrumentationScope scope = 1;
+	//   repeated Metric metrics = 2;
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in ScopeMetrics: %s", err)
+		}
+		src = tail
+		switch fc.num {
+		case 1:
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read InstrumentationScope: %s", err)
+			}
+			if err := sm.Scope.unmarshal(data); err != nil {
+				return fmt.Errorf("cannot unmarshal InstrumentationScope: %s", err)
+			}
+		case 2:
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read Metric: %s", err)
+			}
+			sm.Metrics = append(sm.Metrics, Metric{})
+			m := &sm.Metrics[len(sm.Metrics)-1]
+			if err := m.unmarshal(data); err != nil {
+				return fmt.Errorf("cannot unmarshal Metric: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+// InstrumentationScope represents the corresponding OTLP protobuf message.
+type InstrumentationScope struct {
+	Name       string
+	Version    string
+	Attributes []KeyValue
+}
+
+func (is *InstrumentationScope) unmarshal(src []byte) error {
+	// message InstrumentationScope {
+	//   string name = 1;
+	//   string version = 2;
+	//   repeated KeyValue attributes = 3;
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in InstrumentationScope: %s", err)
+		}
+		src = tail
+		switch fc.num {
+		case 1:
+			if is.Name, err = fc.getString(); err != nil {
+				return fmt.Errorf("cannot read scope name: %s", err)
+			}
+		case 2:
+			if is.Version, err = fc.getString(); err != nil {
+				return fmt.Errorf("cannot read scope version: %s", err)
+			}
+		case 3:
+			if is.Attributes, err = appendKeyValue(is.Attributes, fc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// KeyValue represents the corresponding OTLP protobuf message.
+type KeyValue struct {
+	Key   string
+	Value AnyValue
+}
+
+func appendKeyValue(dst []KeyValue, fc *field) ([]KeyValue, error) {
+	data, err := fc.getMessageData()
+	if err != nil {
+		return dst, fmt.Errorf("cannot read KeyValue: %s", err)
+	}
+	dst = append(dst, KeyValue{})
+	kv := &dst[len(dst)-1]
+	if err := kv.unmarshal(data); err != nil {
+		return dst, fmt.Errorf("cannot unmarshal KeyValue: %s", err)
+	}
+	return dst, nil
+}
+
+func (kv *KeyValue) unmarshal(src []byte) error {
+	// message KeyValue {
+	//   string key = 1;
+	//   AnyValue value = 2;
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in KeyValue: %s", err)
+		}
+		src = tail
+		switch fc.num {
+		case 1:
+			if kv.Key, err = fc.getString(); err != nil {
+				return fmt.Errorf("cannot read key: %s", err)
+			}
+		case 2:
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read AnyValue for key %q: %s", kv.Key, err)
+			}
+			if err := kv.Value.unmarshal(data); err != nil {
+				return fmt.Errorf("cannot unmarshal AnyValue for key %q: %s", kv.Key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// AnyValue represents the corresponding OTLP protobuf message.
+//
+// Only a single field is set depending on Type.
+type AnyValue struct {
+	Type AnyValueType
+
+	StringValue  string
+	BoolValue    bool
+	IntValue     int64
+	DoubleValue  float64
+	ArrayValue   []AnyValue
+	KeyValueList []KeyValue
+	BytesValue   []byte
+}
+
+// AnyValueType is the type of the value stored in AnyValue.
+type AnyValueType int
+
+// AnyValue types.
+const (
+	AnyValueEmpty = AnyValueType(iota)
+	AnyValueString
+	AnyValueBool
+	AnyValueInt
+	AnyValueDouble
+	AnyValueArray
+	AnyValueKeyValueList
+	AnyValueBytes
+)
+
+func (av *AnyValue) unmarshal(src []byte) error {
+	// message AnyValue {
+	//   oneof value {
+	//     string string_value = 1;
+	//     bool bool_value = 2;
+	//     int64 int_value = 3;
+	//     double double_value = 4;
+	//     ArrayValue array_value = 5;
+	//     KeyValueList kvlist_value = 6;
+	//     bytes bytes_value = 7;
+	//   }
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in AnyValue: %s", err)
+		}
+		src = tail
+		switch fc.num {
+		case 1:
+			av.Type = AnyValueString
+			if av.StringValue, err = fc.getString(); err != nil {
+				return fmt.Errorf("cannot read string_value: %s", err)
+			}
+		case 2:
+			av.Type = AnyValueBool
+			v, err := fc.getUint64()
+			if err != nil {
+				return fmt.Errorf("cannot read bool_value: %s", err)
+			}
+			av.BoolValue = v != 0
+		case 3:
+			av.Type = AnyValueInt
+			v, err := fc.getUint64()
+			if err != nil {
+				return fmt.Errorf("cannot read int_value: %s", err)
+			}
+			av.IntValue = int64(v)
+		case 4:
+			av.Type = AnyValueDouble
+			if av.DoubleValue, err = fc.getDouble(); err != nil {
+				return fmt.Errorf("cannot read double_value: %s", err)
+			}
+		case 5:
+			av.Type = AnyValueArray
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read array_value: %s", err)
+			}
+			if err := av.unmarshalArrayValue(data); err != nil {
+				return fmt.Errorf("cannot unmarshal array_value: %s", err)
+			}
+		case 6:
+			av.Type = AnyValueKeyValueList
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read kvlist_value: %s", err)
+			}
+			if err := av.unmarshalKeyValueList(data); err != nil {
+				return fmt.Errorf("cannot unmarshal kvlist_value: %s", err)
+			}
+		case 7:
+			av.Type = AnyValueBytes
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read bytes_value: %s", err)
+			}
+			av.BytesValue = append(av.BytesValue[:0], data...)
+		}
+	}
+	return nil
+}
+
+func (av *AnyValue) unmarshalArrayValue(src []byte) error {
+	// message ArrayValue {
+	//   repeated AnyValue values = 1;
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in ArrayValue: %s", err)
+		}
+		src = tail
+		if fc.num == 1 {
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read AnyValue: %s", err)
+			}
+			av.ArrayValue = append(av.ArrayValue, AnyValue{})
+			v := &av.ArrayValue[len(av.ArrayValue)-1]
+			if err := v.unmarshal(data); err != nil {
+				return fmt.Errorf("cannot unmarshal AnyValue: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (av *AnyValue) unmarshalKeyValueList(src []byte) error {
+	// message KeyValueList {
+	//   repeated KeyValue values = 1;
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in KeyValueList: %s", err)
+		}
+		src = tail
+		if fc.num == 1 {
+			if av.KeyValueList, err = appendKeyValue(av.KeyValueList, fc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FormatString returns string representation for av suitable for label value.
+//
+// Arrays and key-value lists are formatted as JSON.
+// Bytes are formatted as base64.
+func (av *AnyValue) FormatString() string {
+	switch av.Type {
+	case AnyValueString:
+		return av.StringValue
+	case AnyValueBytes:
+		return base64.StdEncoding.EncodeToString(av.BytesValue)
+	default:
+		return string(av.appendJSON(nil))
+	}
+}
+
+func (av *AnyValue) appendJSON(dst []byte) []byte {
+	switch av.Type {
+	case AnyValueString:
+		return strconv.AppendQuote(dst, av.StringValue)
+	case AnyValueBool:
+		return strconv.AppendBool(dst, av.BoolValue)
+	case AnyValueInt:
+		return strconv.AppendInt(dst, av.IntValue, 10)
+	case AnyValueDouble:
+		v := av.DoubleValue
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			// JSON doesn't support these values, so quote them.
+			return strconv.AppendQuote(dst, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		return strconv.AppendFloat(dst, v, 'g', -1, 64)
+	case AnyValueArray:
+		dst = append(dst, '[')
+		for i := range av.ArrayValue {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = av.ArrayValue[i].appendJSON(dst)
+		}
+		return append(dst, ']')
+	case AnyValueKeyValueList:
+		dst = append(dst, '{')
+		for i := range av.KeyValueList {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			kv := &av.KeyValueList[i]
+			dst = strconv.AppendQuote(dst, kv.Key)
+			dst = append(dst, ':')
+			dst = kv.Value.appendJSON(dst)
+		}
+		return append(dst, '}')
+	case AnyValueBytes:
+		return strconv.AppendQuote(dst, base64.StdEncoding.EncodeToString(av.BytesValue))
+	default:
+		return append(dst, "null"...)
+	}
+}
+
+// Metric represents the corresponding OTLP protobuf message.
+//
+// At most a single data field is set. All the fields are nil
+// for unsupported data types such as exponential histograms.
+type Metric struct {
+	Name        string
+	Description string
+	Unit        string
+
+	Gauge     *Gauge
+	Sum       *Sum
+	Histogram *Histogram
+	Summary   *Summary
+}
+
+func (m *Metric) unmarshal(src []byte) error {
+	// message Metric {
+	//   string name = 1;
+	//   string description = 2;
+	//   string unit = 3;
+	//   oneof data {
+	//     Gauge gauge = 5;
+	//     Sum sum = 7;
+	//     Histogram histogram = 9;
+	//     ExponentialHistogram exponential_histogram = 10;
+	//     Summary summary = 11;
+	//   }
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in Metric: %s", err)
+		}
+		src = tail
+		switch fc.num {
+		case 1:
+			if m.Name, err = fc.getString(); err != nil {
+				return fmt.Errorf("cannot read metric name: %s", err)
+			}
+		case 2:
+			if m.Description, err = fc.getString(); err != nil {
+				return fmt.Errorf("cannot read metric description: %s", err)
+			}
+		case 3:
+			if m.Unit, err = fc.getString(); err != nil {
+				return fmt.Errorf("cannot read metric unit: %s", err)
+			}
+		case 5:
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read Gauge for metric %q: %s", m.Name, err)
+			}
+			m.Gauge = &Gauge{}
+			if err := m.Gauge.unmarshal(data); err != nil {
+				return fmt.Errorf("cannot unmarshal Gauge for metric %q: %s", m.Name, err)
+			}
+		case 7:
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read Sum for metric %q: %s", m.Name, err)
+			}
+			m.Sum = &Sum{}
+			if err := m.Sum.unmarshal(data); err != nil {
+				return fmt.Errorf("cannot unmarshal Sum for metric %q: %s", m.Name, err)
+			}
+		case 9:
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read Histogram for metric %q: %s", m.Name, err)
+			}
+			m.Histogram = &Histogram{}
+			if err := m.Histogram.unmarshal(data); err != nil {
+				return fmt.Errorf("cannot unmarshal Histogram for metric %q: %s", m.Name, err)
+			}
+		case 11:
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read Summary for metric %q: %s", m.Name, err)
+			}
+			m.Summary = &Summary{}
+			if err := m.Summary.unmarshal(data); err != nil {
+				return fmt.Errorf("cannot unmarshal Summary for metric %q: %s", m.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// AggregationTemporality is the temporality for Sum and Histogram data points.
+type AggregationTemporality int
+
+// AggregationTemporality values.
+const (
+	AggregationTemporalityUnspecified = AggregationTemporality(0)
+	AggregationTemporalityDelta       = AggregationTemporality(1)
+	AggregationTemporalityCumulative  = AggregationTemporality(2)
+)
+
+// DataPointFlagNoRecordedValue is set in data point flags if the data point has no recorded value.
+//
+// Such data points are sent when the series disappears.
+const DataPointFlagNoRecordedValue = 1
+
+// Gauge represents the corresponding OTLP protobuf message.
+type Gauge struct {
+	DataPoints []NumberDataPoint
+}
+
+func (g *Gauge) unmarshal(src []byte) error {
+	// message Gauge {
+	//   repeated NumberDataPoint data_points = 1;
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in Gauge: %s", err)
+		}
+		src = tail
+		if fc.num == 1 {
+			if g.DataPoints, err = appendNumberDataPoint(g.DataPoints, fc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Sum represents the corresponding OTLP protobuf message.
+type Sum struct {
+	DataPoints             []NumberDataPoint
+	AggregationTemporality AggregationTemporality
+	IsMonotonic            bool
+}
+
+func (s *Sum) unmarshal(src []byte) error {
+	// message Sum {
+	//   repeated NumberDataPoint data_points = 1;
+	//   AggregationTemporality aggregation_temporality = 2;
+	//   bool is_monotonic = 3;
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in Sum: %s", err)
+		}
+		src = tail
+		switch fc.num {
+		case 1:
+			if s.DataPoints, err = appendNumberDataPoint(s.DataPoints, fc); err != nil {
+				return err
+			}
+		case 2:
+			v, err := fc.getUint64()
+			if err != nil {
+				return fmt.Errorf("cannot read aggregation_temporality: %s", err)
+			}
+			s.AggregationTemporality = AggregationTemporality(v)
+		case 3:
+			v, err := fc.getUint64()
+			if err != nil {
+				return fmt.Errorf("cannot read is_monotonic: %s", err)
+			}
+			s.IsMonotonic = v != 0
+		}
+	}
+	return nil
+}
+
+// NumberDataPoint represents the corresponding OTLP protobuf message.
+type NumberDataPoint struct {
+	Attributes        []KeyValue
+	TimeUnixNano      uint64
+	StartTimeUnixNano uint64
+	Value             float64
+	Flags             uint64
+}
+
+func appendNumberDataPoint(dst []NumberDataPoint, fc *field) ([]NumberDataPoint, error) {
+	data, err := fc.getMessageData()
+	if err != nil {
+		return dst, fmt.Errorf("cannot read NumberDataPoint: %s", err)
+	}
+	dst = append(dst, NumberDataPoint{})
+	dp := &dst[len(dst)-1]
+	if err := dp.unmarshal(data); err != nil {
+		return dst, fmt.Errorf("cannot unmarshal NumberDataPoint: %s", err)
+	}
+	return dst, nil
+}
+
+func (dp *NumberDataPoint) unmarshal(src []byte) error {
+	// message NumberDataPoint {
+	//   repeated KeyValue attributes = 7;
+	//   fixed64 start_time_unix_nano = 2;
+	//   fixed64 time_unix_nano = 3;
+	//   oneof value {
+	//     double as_double = 4;
+	//     sfixed64 as_int = 6;
+	//   }
+	//   uint32 flags = 8;
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in NumberDataPoint: %s", err)
+		}
+		src = tail
+		switch fc.num {
+		case 2:
+			if dp.StartTimeUnixNano, err = fc.getFixed64(); err != nil {
+				return fmt.Errorf("cannot read start_time_unix_nano: %s", err)
+			}
+		case 3:
+			if dp.TimeUnixNano, err = fc.getFixed64(); err != nil {
+				return fmt.Errorf("cannot read time_unix_nano: %s", err)
+			}
+		case 4:
+			if dp.Value, err = fc.getDouble(); err != nil {
+				return fmt.Errorf("cannot read as_double: %s", err)
+			}
+		case 6:
+			v, err := fc.getFixed64()
+			if err != nil {
+				return fmt.Errorf("cannot read as_int: %s", err)
+			}
+			dp.Value = float64(int64(v))
+		case 7:
+			if dp.Attributes, err = appendKeyValue(dp.Attributes, fc); err != nil {
+				return err
+			}
+		case 8:
+			if dp.Flags, err = fc.getUint64(); err != nil {
+				return fmt.Errorf("cannot read flags: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Histogram represents the corresponding OTLP protobuf message.
+type Histogram struct {
+	DataPoints             []HistogramDataPoint
+	AggregationTemporality AggregationTemporality
+}
+
+func (h *Histogram) unmarshal(src []byte) error {
+	// message Histogram {
+	//   repeated HistogramDataPoint data_points = 1;
+	//   AggregationTemporality aggregation_temporality = 2;
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in Histogram: %s", err)
+		}
+		src = tail
+		switch fc.num {
+		case 1:
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read HistogramDataPoint: %s", err)
+			}
+			h.DataPoints = append(h.DataPoints, HistogramDataPoint{})
+			dp := &h.DataPoints[len(h.DataPoints)-1]
+			if err := dp.unmarshal(data); err != nil {
+				return fmt.Errorf("cannot unmarshal HistogramDataPoint: %s", err)
+			}
+		case 2:
+			v, err := fc.getUint64()
+			if err != nil {
+				return fmt.Errorf("cannot read aggregation_temporality: %s", err)
+			}
+			h.AggregationTemporality = AggregationTemporality(v)
+		}
+	}
+	return nil
+}
+
+// HistogramDataPoint represents the corresponding OTLP protobuf message.
+type HistogramDataPoint struct {
+	Attributes        []KeyValue
+	TimeUnixNano      uint64
+	StartTimeUnixNano uint64
+	Count             uint64
+	Sum               float64
+	HasSum            bool
+	BucketCounts      []uint64
+	ExplicitBounds    []float64
+	Flags             uint64
+}
+
+func (dp *HistogramDataPoint) unmarshal(src []byte) error {
+	// message HistogramDataPoint {
+	//   repeated KeyValue attributes = 9;
+	//   fixed64 start_time_unix_nano = 2;
+	//   fixed64 time_unix_nano = 3;
+	//   fixed64 count = 4;
+	//   optional double sum = 5;
+	//   repeated fixed64 bucket_counts = 6;
+	//   repeated double explicit_bounds = 7;
+	//   uint32 flags = 10;
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in HistogramDataPoint: %s", err)
+		}
+		src = tail
+		switch fc.num {
+		case 2:
+			if dp.StartTimeUnixNano, err = fc.getFixed64(); err != nil {
+				return fmt.Errorf("cannot read start_time_unix_nano: %s", err)
+			}
+		case 3:
+			if dp.TimeUnixNano, err = fc.getFixed64(); err != nil {
+				return fmt.Errorf("cannot read time_unix_nano: %s", err)
+			}
+		case 4:
+			if dp.Count, err = fc.getFixed64(); err != nil {
+				return fmt.Errorf("cannot read count: %s", err)
+			}
+		case 5:
+			if dp.Sum, err = fc.getDouble(); err != nil {
+				return fmt.Errorf("cannot read sum: %s", err)
+			}
+			dp.HasSum = true
+		case 6:
+			if dp.BucketCounts, err = fc.appendFixed64s(dp.BucketCounts); err != nil {
+				return fmt.Errorf("cannot read bucket_counts: %s", err)
+			}
+		case 7:
+			if dp.ExplicitBounds, err = fc.appendDoubles(dp.ExplicitBounds); err != nil {
+				return fmt.Errorf("cannot read explicit_bounds: %s", err)
+			}
+		case 9:
+			if dp.Attributes, err = appendKeyValue(dp.Attributes, fc); err != nil {
+				return err
+			}
+		case 10:
+			if dp.Flags, err = fc.getUint64(); err != nil {
+				return fmt.Errorf("cannot read flags: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Summary represents the corresponding OTLP protobuf message.
+type Summary struct {
+	DataPoints []SummaryDataPoint
+}
+
+func (s *Summary) unmarshal(src []byte) error {
+	// message Summary {
+	//   repeated SummaryDataPoint data_points = 1;
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in Summary: %s", err)
+		}
+		src = tail
+		if fc.num == 1 {
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read SummaryDataPoint: %s", err)
+			}
+			s.DataPoints = append(s.DataPoints, SummaryDataPoint{})
+			dp := &s.DataPoints[len(s.DataPoints)-1]
+			if err := dp.unmarshal(data); err != nil {
+				return fmt.Errorf("cannot unmarshal SummaryDataPoint: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+// SummaryDataPoint represents the corresponding OTLP protobuf message.
+type SummaryDataPoint struct {
+	Attributes        []KeyValue
+	TimeUnixNano      uint64
+	StartTimeUnixNano uint64
+	Count             uint64
+	Sum               float64
+	QuantileValues    []ValueAtQuantile
+	Flags             uint64
+}
+
+func (dp *SummaryDataPoint) unmarshal(src []byte) error {
+	// message SummaryDataPoint {
+	//   repeated KeyValue attributes = 7;
+	//   fixed64 start_time_unix_nano = 2;
+	//   fixed64 time_unix_nano = 3;
+	//   fixed64 count = 4;
+	//   double sum = 5;
+	//   repeated ValueAtQuantile quantile_values = 6;
+	//   uint32 flags = 8;
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in SummaryDataPoint: %s", err)
+		}
+		src = tail
+		switch fc.num {
+		case 2:
+			if dp.StartTimeUnixNano, err = fc.getFixed64(); err != nil {
+				return fmt.Errorf("cannot read start_time_unix_nano: %s", err)
+			}
+		case 3:
+			if dp.TimeUnixNano, err = fc.getFixed64(); err != nil {
+				return fmt.Errorf("cannot read time_unix_nano: %s", err)
+			}
+		case 4:
+			if dp.Count, err = fc.getFixed64(); err != nil {
+				return fmt.Errorf("cannot read count: %s", err)
+			}
+		case 5:
+			if dp.Sum, err = fc.getDouble(); err != nil {
+				return fmt.Errorf("cannot read sum: %s", err)
+			}
+		case 6:
+			data, err := fc.getMessageData()
+			if err != nil {
+				return fmt.Errorf("cannot read ValueAtQuantile: %s", err)
+			}
+			dp.QuantileValues = append(dp.QuantileValues, ValueAtQuantile{})
+			q := &dp.QuantileValues[len(dp.QuantileValues)-1]
+			if err := q.unmarshal(data); err != nil {
+				return fmt.Errorf("cannot unmarshal ValueAtQuantile: %s", err)
+			}
+		case 7:
+			if dp.Attributes, err = appendKeyValue(dp.Attributes, fc); err != nil {
+				return err
+			}
+		case 8:
+			if dp.Flags, err = fc.getUint64(); err != nil {
+				return fmt.Errorf("cannot read flags: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+// ValueAtQuantile represents the corresponding OTLP protobuf message.
+type ValueAtQuantile struct {
+	Quantile float64
+	Value    float64
+}
+
+func (q *ValueAtQuantile) unmarshal(src []byte) error {
+	// message ValueAtQuantile {
+	//   double quantile = 1;
+	//   double value = 2;
+	// }
+	for len(src) > 0 {
+		tail, fc, err := nextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in ValueAtQuantile: %s", err)
+		}
+		src = tail
+		switch fc.num {
+		case 1:
+			if q.Quantile, err = fc.getDouble(); err != nil {
+				return fmt.Errorf("cannot read quantile: %s", err)
+			}
+		case 2:
+			if q.Value, err = fc.getDouble(); err != nil {
+				return fmt.Errorf("cannot read value: %s", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package pb
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func appendUvarint(dst []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	return append(dst, b[:n]...)
+}
+
+func appendUint64(dst []byte, v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(dst, b[:]...)
+}
+
+func appendTag(dst []byte, num, wireType uint64) []byte {
+	return appendUvarint(dst, num<<3|wireType)
+}
+
+func appendMessage(dst []byte, num uint64, data []byte) []byte {
+	dst = appendTag(dst, num, wireTypeLen)
+	dst = appendUvarint(dst, uint64(len(data)))
+	return append(dst, data...)
+}
+
+func appendString(dst []byte, num uint64, s string) []byte {
+	return appendMessage(dst, num, []byte(s))
+}
+
+func appendVarint(dst []byte, num, v uint64) []byte {
+	dst = appendTag(dst, num, wireTypeVarint)
+	return appendUvarint(dst, v)
+}
+
+func appendFixed64(dst []byte, num, v uint64) []byte {
+	dst = appendTag(dst, num, wireTypeI64)
+	return appendUint64(dst, v)
+}
+
+func appendDouble(dst []byte, num uint64, v float64) []byte {
+	return appendFixed64(dst, num, math.Float64bits(v))
+}
+
+func marshalKeyValue(key string, value []byte) []byte {
+	var dst []byte
+	dst = appendString(dst, 1, key)
+	return appendMessage(dst, 2, value)
+}
+
+func TestExportMetricsServiceRequestUnmarshalSuccess(t *testing.T) {
+	// Resource with string, int, bool and array attributes.
+	var resource []byte
+	resource = appendMessage(resource, 1, marshalKeyValue("service.name", appendString(nil, 1, "foo")))
+	resource = appendMessage(resource, 1, marshalKeyValue("pid", appendVarint(nil, 3, 123)))
+	resource = appendMessage(resource, 1, marshalKeyValue("enabled", appendVarint(nil, 2, 1)))
+	var arr []byte
+	arr = appendMessage(arr, 1, appendString(nil, 1, "a"))
+	arr = appendMessage(arr, 1, appendDouble(nil, 4, 1.5))
+	resource = appendMessage(resource, 1, marshalKeyValue("arr", appendMessage(nil, 5, arr)))
+
+	var scope []byte
+	scope = appendString(scope, 1, "lib")
+	scope = appendString(scope, 2, "v1.2")
+
+	// Gauge with as_double and unknown field, which must be skipped.
+	var ndp []byte
+	ndp = appendMessage(ndp, 7, marshalKeyValue("host", appendString(nil, 1, "h1")))
+	ndp = appendFixed64(ndp, 3, 1234567890123456789)
+	ndp = appendDouble(ndp, 4, 12.5)
+	ndp = appendVarint(ndp, 100, 42)
+	gauge := appendMessage(nil, 1, ndp)
+	var gaugeMetric []byte
+	gaugeMetric = appendString(gaugeMetric, 1, "cpu.usage")
+	gaugeMetric = appendString(gaugeMetric, 3, "1")
+	gaugeMetric = appendMessage(gaugeMetric, 5, gauge)
+
+	// Sum with as_int.
+	ndp = appendFixed64(nil, 3, 2e18)
+	ndp = appendFixed64(ndp, 6, uint64(0xffffffffffffffff)) // -1
+	var sum []byte
+	sum = appendMessage(sum, 1, ndp)
+	sum = appendVarint(sum, 2, uint64(AggregationTemporalityDelta))
+	sum = appendVarint(sum, 3, 1)
+	var sumMetric []byte
+	sumMetric = appendString(sumMetric, 1, "requests")
+	sumMetric = appendMessage(sumMetric, 7, sum)
+
+	// Histogram with packed bucket_counts and explicit_bounds.
+	var hdp []byte
+	hdp = appendFixed64(hdp, 3, 3e18)
+	hdp = appendFixed64(hdp, 4, 6)
+	hdp = appendDouble(hdp, 5, 7.5)
+	var packedCounts []byte
+	for _, v := range []uint64{1, 2, 3} {
+		packedCounts = appendUint64(packedCounts, v)
+	}
+	hdp = appendMessage(hdp, 6, packedCounts)
+	var packedBounds []byte
+	for _, v := range []float64{0.5, 1} {
+		packedBounds = appendUint64(packedBounds, math.Float64bits(v))
+	}
+	hdp = appendMessage(hdp, 7, packedBounds)
+	hdp = appendVarint(hdp, 10, DataPointFlagNoRecordedValue)
+	var histogram []byte
+	histogram = appendMessage(histogram, 1, hdp)
+	histogram = appendVarint(histogram, 2, uint64(AggregationTemporalityCumulative))
+	var histogramMetric []byte
+	histogramMetric = appendString(histogramMetric, 1, "latency")
+	histogramMetric = appendMessage(histogramMetric, 9, histogram)
+
+	// Summary.
+	var sdp []byte
+	sdp = appendFixed64(sdp, 4, 10)
+	sdp = appendDouble(sdp, 5, 20)
+	var q []byte
+	q = appendDouble(q, 1, 0.99)
+	q = appendDouble(q, 2, 3)
+	sdp = appendMessage(sdp, 6, q)
+	var summaryMetric []byte
+	summaryMetric = appendString(summaryMetric, 1, "rpc")
+	summaryMetric = appendMessage(summaryMetric, 11, appendMessage(nil, 1, sdp))
+
+	// Exponential histogram isn't supported.
+	var expMetric []byte
+	expMetric = appendString(expMetric, 1, "exp")
+	expMetric = appendMessage(expMetric, 10, nil)
+
+	var sm []byte
+	sm = appendMessage(sm, 1, scope)
+	sm = appendMessage(sm, 2, gaugeMetric)
+	sm = appendMessage(sm, 2, sumMetric)
+	sm = appendMessage(sm, 2, histogramMetric)
+	sm = appendMessage(sm, 2, summaryMetric)
+	sm = appendMessage(sm, 2, expMetric)
+
+	var rm []byte
+	rm = appendMessage(rm, 1, resource)
+	rm = appendMessage(rm, 2, sm)
+	rm = appendString(rm, 3, "https://opentelemetry.io/schemas/1.9.0")
+
+	data := appendMessage(nil, 1, rm)
+
+	var req ExportMetricsServiceRequest
+	if err := req.Unmarshal(data); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	reqExpected := ExportMetricsServiceRequest{
+		ResourceMetrics: []ResourceMetrics{{
+			Resource: Resource{
+				Attributes: []KeyValue{
+					{Key: "service.name", Value: AnyValue{Type: AnyValueString, StringValue: "foo"}},
+					{Key: "pid", Value: AnyValue{Type: AnyValueInt, IntValue: 123}},
+					{Key: "enabled", Value: AnyValue{Type: AnyValueBool, BoolValue: true}},
+					{Key: "arr", Value: AnyValue{Type: AnyValueArray, ArrayValue: []AnyValue{
+						{Type: AnyValueString, StringValue: "a"},
+						{Type: AnyValueDouble, DoubleValue: 1.5},
+					}}},
+				},
+			},
+			ScopeMetrics: []ScopeMetrics{{
+				Scope: InstrumentationScope{
+					Name:    "lib",
+					Version: "v1.2",
+				},
+				Metrics: []Metric{
+					{
+						Name: "cpu.usage",
+						Unit: "1",
+						Gauge: &Gauge{
+							DataPoints: []NumberDataPoint{{
+								Attributes: []KeyValue{
+									{Key: "host", Value: AnyValue{Type: AnyValueString, StringValue: "h1"}},
+								},
+								TimeUnixNano: 1234567890123456789,
+								Value:        12.5,
+							}},
+						},
+					},
+					{
+						Name: "requests",
+						Sum: &Sum{
+							DataPoints: []NumberDataPoint{{
+								TimeUnixNano: 2e18,
+								Value:        -1,
+							}},
+							AggregationTemporality: AggregationTemporalityDelta,
+							IsMonotonic:            true,
+						},
+					},
+					{
+						Name: "latency",
+						Histogram: &Histogram{
+							DataPoints: []HistogramDataPoint{{
+								TimeUnixNano:   3e18,
+								Count:          6,
+								Sum:            7.5,
+								HasSum:         true,
+								BucketCounts:   []uint64{1, 2, 3},
+								ExplicitBounds: []float64{0.5, 1},
+								Flags:          DataPointFlagNoRecordedValue,
+							}},
+							AggregationTemporality: AggregationTemporalityCumulative,
+						},
+					},
+					{
+						Name: "rpc",
+						Summary: &Summary{
+							DataPoints: []SummaryDataPoint{{
+								Count: 10,
+								Sum:   20,
+								QuantileValues: []ValueAtQuantile{{
+									Quantile: 0.99,
+									Value:    3,
+								}},
+							}},
+						},
+					},
+					{
+						Name: "exp",
+					},
+				},
+			}},
+		}},
+	}
+	if !reflect.DeepEqual(&req, &reqExpected) {
+		t.Fatalf("unexpected request unmarshaled\ngot\n%+v\nwant\n%+v", &req, &reqExpected)
+	}
+
+	// Verify that the request may be reused.
+	if err := req.Unmarshal(nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(req.ResourceMetrics) != 0 {
+		t.Fatalf("unexpected non-empty ResourceMetrics after unmarshaling empty data: %+v", req.ResourceMetrics)
+	}
+}
+
+func TestExportMetricsServiceRequestUnmarshalFailure(t *testing.T) {
+	f := func(data []byte) {
+		t.Helper()
+		var req ExportMetricsServiceRequest
+		if err := req.Unmarshal(data); err == nil {
+			t.Fatalf("expecting non-nil error when unmarshaling %X", data)
+		}
+	}
+
+	// Truncated tag
+	f([]byte{0x80})
+
+	// Zero field number
+	f([]byte{0x00, 0x00})
+
+	// Unsupported wire type
+	f([]byte{0x0b})
+
+	// Too short message
+	f([]byte{0x0a, 0x05, 0x01})
+
+	// Invalid wire type for resource_metrics
+	f(appendVarint(nil, 1, 123))
+
+	// Invalid metric name wire type
+	metric := appendVarint(nil, 1, 1)
+	sm := appendMessage(nil, 2, metric)
+	rm := appendMessage(nil, 2, sm)
+	f(appendMessage(nil, 1, rm))
+
+	// Invalid packed bucket_counts length
+	hdp := appendMessage(nil, 6, []byte{1, 2, 3})
+	metric = appendMessage(nil, 9, appendMessage(nil, 1, hdp))
+	sm = appendMessage(nil, 2, metric)
+	rm = appendMessage(nil, 2, sm)
+	f(appendMessage(nil, 1, rm))
+}
+
+func TestAnyValueFormatString(t *testing.T) {
+	f := func(av *AnyValue, resultExpected string) {
+		t.Helper()
+		result := av.FormatString()
+		if result != resultExpected {
+			t.Fatalf("unexpected result; got %q; want %q", result, resultExpected)
+		}
+	}
+	f(&AnyValue{}, "null")
+	f(&AnyValue{Type: AnyValueString, StringValue: "foo bar"}, "foo bar")
+	f(&AnyValue{Type: AnyValueBool, BoolValue: true}, "true")
+	f(&AnyValue{Type: AnyValueInt, IntValue: -42}, "-42")
+	f(&AnyValue{Type: AnyValueDouble, DoubleValue: 1.25}, "1.25")
+	f(&AnyValue{Type: AnyValueDouble, DoubleValue: math.Inf(1)}, `"+Inf"`)
+	f(&AnyValue{Type: AnyValueBytes, BytesValue: []byte("foo")}, "Zm9v")
+	f(&AnyValue{Type: AnyValueArray, ArrayValue: []AnyValue{
+		{Type: AnyValueString, StringValue: `a"b`},
+		{Type: AnyValueInt, IntValue: 1},
+	}}, `["a\"b",1]`)
+	f(&AnyValue{Type: AnyValueKeyValueList, KeyValueList: []KeyValue{
+		{Key: "x", Value: AnyValue{Type: AnyValueBool}},
+		{Key: "y", Value: AnyValue{Type: AnyValueArray}},
+	}}, `{"x":false,"y":[]}`)
+}
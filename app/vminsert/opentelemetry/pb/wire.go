@@ -0,0 +1,166 @@
+package pb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Protobuf wire types.
+//
+// See https://developers.google.com/protocol-buffers/docs/encoding#structure
+const (
+	wireTypeVarint = 0
+	wireTypeI64    = 1
+	wireTypeLen    = 2
+	wireTypeI32    = 5
+)
+
+// field is a single protobuf field read by nextField.
+type field struct {
+	num      uint64
+	wireType uint64
+
+	// intValue contains the value for varint, i64 and i32 wire types.
+	intValue uint64
+
+	// data contains the value for len wire type.
+	data []byte
+}
+
+// nextField reads the next field from src.
+//
+// It returns the tail left after the field.
+func nextField(src []byte) ([]byte, *field, error) {
+	var fc field
+	tag, n := binary.Uvarint(src)
+	if n <= 0 {
+		return src, nil, fmt.Errorf("cannot read field tag")
+	}
+	src = src[n:]
+	fc.num = tag >> 3
+	fc.wireType = tag & 0x7
+	if fc.num == 0 {
+		return src, nil, fmt.Errorf("invalid field number: 0")
+	}
+	switch fc.wireType {
+	case wireTypeVarint:
+		v, n := binary.Uvarint(src)
+		if n <= 0 {
+			return src, nil, fmt.Errorf("cannot read varint for field #%d", fc.num)
+		}
+		src = src[n:]
+		fc.intValue = v
+	case wireTypeI64:
+		if len(src) < 8 {
+			return src, nil, fmt.Errorf("cannot read i64 for field #%d", fc.num)
+		}
+		fc.intValue = binary.LittleEndian.Uint64(src)
+		src = src[8:]
+	case wireTypeLen:
+		size, n := binary.Uvarint(src)
+		if n <= 0 {
+			return src, nil, fmt.Errorf("cannot read data length for field #%d", fc.num)
+		}
+		src = src[n:]
+		if uint64(len(src)) < size {
+			return src, nil, fmt.Errorf("too short data for field #%d; got %d bytes; want %d bytes", fc.num, len(src), size)
+		}
+		fc.data = src[:size]
+		src = src[size:]
+	case wireTypeI32:
+		if len(src) < 4 {
+			return src, nil, fmt.Errorf("cannot read i32 for field #%d", fc.num)
+		}
+		fc.intValue = uint64(binary.LittleEndian.Uint32(src))
+		src = src[4:]
+	default:
+		return src, nil, fmt.Errorf("unsupported wire type %d for field #%d", fc.wireType, fc.num)
+	}
+	return src, &fc, nil
+}
+
+func (fc *field) checkWireType(wireType uint64) error {
+	if fc.wireType != wireType {
+		return fmt.Errorf("unexpected wire type for field #%d; got %d; want %d", fc.num, fc.wireType, wireType)
+	}
+	return nil
+}
+
+func (fc *field) getString() (string, error) {
+	if err := fc.checkWireType(wireTypeLen); err != nil {
+		return "", err
+	}
+	return string(fc.data), nil
+}
+
+func (fc *field) getMessageData() ([]byte, error) {
+	if err := fc.checkWireType(wireTypeLen); err != nil {
+		return nil, err
+	}
+	return fc.data, nil
+}
+
+func (fc *field) getUint64() (uint64, error) {
+	if err := fc.checkWireType(wireTypeVarint); err != nil {
+		return 0, err
+	}
+	return fc.intValue, nil
+}
+
+func (fc *field) getFixed64() (uint64, error) {
+	if err := fc.checkWireType(wireTypeI64); err != nil {
+		return 0, err
+	}
+	return fc.intValue, nil
+}
+
+func (fc *field) getDouble() (float64, error) {
+	v, err := fc.getFixed64()
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(v), nil
+}
+
+// appendFixed64s appends repeated fixed64 values from fc to dst.
+//
+// Both packed and unpacked encodings are supported.
+func (fc *field) appendFixed64s(dst []uint64) ([]uint64, error) {
+	if fc.wireType == wireTypeI64 {
+		return append(dst, fc.intValue), nil
+	}
+	if err := fc.checkWireType(wireTypeLen); err != nil {
+		return dst, err
+	}
+	data := fc.data
+	if len(data)%8 != 0 {
+		return dst, fmt.Errorf("invalid length for packed fixed64 field #%d: %d bytes; must be multiple of 8", fc.num, len(data))
+	}
+	for len(data) > 0 {
+		dst = append(dst, binary.LittleEndian.Uint64(data))
+		data = data[8:]
+	}
+	return dst, nil
+}
+
+// appendDoubles appends repeated double values from fc to dst.
+//
+// Both packed and unpacked encodings are supported.
+func (fc *field) appendDoubles(dst []float64) ([]float64, error) {
+	if fc.wireType == wireTypeI64 {
+		return append(dst, math.Float64frombits(fc.intValue)), nil
+	}
+	if err := fc.checkWireType(wireTypeLen); err != nil {
+		return dst, err
+	}
+	data := fc.data
+	if len(data)%8 != 0 {
+		return dst, fmt.Errorf("invalid length for packed double field #%d: %d bytes; must be multiple of 8", fc.num, len(data))
+	}
+	for len(data) > 0 {
+		dst = append(dst, math.Float64frombits(binary.LittleEndian.Uint64(data)))
+		data = data[8:]
+	}
+	return dst, nil
+}
@@ -0,0 +1,168 @@
+package opentelemetry
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/concurrencylimiter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/opentelemetry/pb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	rowsInserted       = metrics.NewCounter(`vm_rows_inserted_total{type="opentelemetry"}`)
+	rowsRejected       = common.NewRejectedRowsCounters("opentelemetry")
+	unsupportedMetrics = metrics.NewCounter(`vm_opentelemetry_unsupported_metrics_total`)
+)
+
+// InsertHandler processes OTLP/HTTP `/opentelemetry/v1/metrics` request for the given tenant.
+//
+// The request body must contain protobuf-encoded ExportMetricsServiceRequest.
+// JSON encoding isn't supported.
+//
+// See https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/protocol/otlp.md#otlphttp
+func InsertHandler(at *auth.Token, req *http.Request, maxSize int64) error {
+	contentType := req.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "application/json") {
+		return fmt.Errorf("JSON-encoded OpenTelemetry data isn't supported; send protobuf-encoded data with `Content-Type: application/x-protobuf` instead")
+	}
+	return concurrencylimiter.Do(func() error {
+		return insertHandlerInternal(at, req, maxSize)
+	})
+}
+
+func insertHandlerInternal(at *auth.Token, req *http.Request, maxSize int64) error {
+	ctx := getPushCtx()
+	defer putPushCtx(ctx)
+	if err := ctx.Read(req, maxSize); err != nil {
+		return err
+	}
+	return ctx.InsertRows(at)
+}
+
+func (ctx *pushCtx) InsertRows(at *auth.Token) error {
+	rows := ctx.Rows.Rows
+	ic := &ctx.Common
+	ic.Reset(len(rows), rowsRejected)
+	for i := range rows {
+		r := &rows[i]
+		ic.Labels = ic.Labels[:0]
+		ic.AddLabel("", r.Metric)
+		for j := range r.Tags {
+			tag := &r.Tags[j]
+			ic.AddLabel(tag.Key, tag.Value)
+		}
+		ic.WriteDataPoint(at, nil, ic.Labels, r.Timestamp, r.Value)
+	}
+	rowsInserted.Add(len(rows))
+	unsupportedMetrics.Add(ctx.Rows.UnsupportedMetrics)
+	return ic.FlushBufs()
+}
+
+func (ctx *pushCtx) Read(req *http.Request, maxSize int64) error {
+	opentelemetryReadCalls.Inc()
+
+	r := req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := getGzipReader(r)
+		if err != nil {
+			opentelemetryReadErrors.Inc()
+			return fmt.Errorf("cannot read gzipped OpenTelemetry data: %s", err)
+		}
+		defer putGzipReader(zr)
+		r = zr
+	}
+
+	lr := io.LimitReader(r, maxSize+1)
+	bb := bytes.NewBuffer(ctx.reqBuf[:0])
+	_, err := bb.ReadFrom(lr)
+	ctx.reqBuf = bb.Bytes()
+	if err != nil {
+		opentelemetryReadErrors.Inc()
+		return fmt.Errorf("cannot read OpenTelemetry data: %s", err)
+	}
+	if int64(len(ctx.reqBuf)) > maxSize {
+		opentelemetryReadErrors.Inc()
+		return fmt.Errorf("too big request; it mustn't exceed %d bytes", maxSize)
+	}
+	if err := ctx.req.Unmarshal(ctx.reqBuf); err != nil {
+		opentelemetryUnmarshalErrors.Inc()
+		return fmt.Errorf("cannot unmarshal ExportMetricsServiceRequest with size %d bytes: %s", len(ctx.reqBuf), err)
+	}
+	currentTimestamp := time.Now().UnixNano() / 1e6
+	ctx.Rows.Init(&ctx.req, currentTimestamp)
+	return nil
+}
+
+func getGzipReader(r io.Reader) (*gzip.Reader, error) {
+	v := gzipReaderPool.Get()
+	if v == nil {
+		return gzip.NewReader(r)
+	}
+	zr := v.(*gzip.Reader)
+	if err := zr.Reset(r); err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
+func putGzipReader(zr *gzip.Reader) {
+	_ = zr.Close()
+	gzipReaderPool.Put(zr)
+}
+
+var gzipReaderPool sync.Pool
+
+type pushCtx struct {
+	Rows   Rows
+	Common common.InsertCtx
+
+	req    pb.ExportMetricsServiceRequest
+	reqBuf []byte
+}
+
+func (ctx *pushCtx) reset() {
+	ctx.Rows.Reset()
+	ctx.Common.Reset(0, nil)
+	ctx.req.Reset()
+	ctx.reqBuf = ctx.reqBuf[:0]
+}
+
+var (
+	opentelemetryReadCalls       = metrics.NewCounter(`vm_read_calls_total{name="opentelemetry"}`)
+	opentelemetryReadErrors      = metrics.NewCounter(`vm_read_errors_total{name="opentelemetry"}`)
+	opentelemetryUnmarshalErrors = metrics.NewCounter(`vm_unmarshal_errors_total{name="opentelemetry"}`)
+)
+
+func getPushCtx() *pushCtx {
+	select {
+	case ctx := <-pushCtxPoolCh:
+		return ctx
+	default:
+		if v := pushCtxPool.Get(); v != nil {
+			return v.(*pushCtx)
+		}
+		return &pushCtx{}
+	}
+}
+
+func putPushCtx(ctx *pushCtx) {
+	ctx.reset()
+	select {
+	case pushCtxPoolCh <- ctx:
+	default:
+		pushCtxPool.Put(ctx)
+	}
+}
+
+var pushCtxPool sync.Pool
+var pushCtxPoolCh = make(chan *pushCtx, runtime.GOMAXPROCS(-1))
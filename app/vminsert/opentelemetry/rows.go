@@ -0,0 +1,318 @@
+package opentelemetry
+
+import (
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/opentelemetry/pb"
+)
+
+// Rows contains rows obtained from OTLP ExportMetricsServiceRequest.
+type Rows struct {
+	Rows []Row
+
+	// UnsupportedMetrics is the number of metrics with unsupported data types such as exponential histograms
+	// or with unsupported delta temporality.
+	UnsupportedMetrics int
+
+	tagsPool []Tag
+}
+
+// Reset resets rs.
+func (rs *Rows) Reset() {
+	// Reset items, so they can be GC'ed
+
+	for i := range rs.Rows {
+		rs.Rows[i].reset()
+	}
+	rs.Rows = rs.Rows[:0]
+	rs.UnsupportedMetrics = 0
+
+	for i := range rs.tagsPool {
+		rs.tagsPool[i].reset()
+	}
+	rs.tagsPool = rs.tagsPool[:0]
+}
+
+// Init initializes rs from req.
+//
+// Metric names and label names are normalized to Prometheus conventions.
+// Resource attributes, instrumentation scope attributes and data point attributes
+// are converted to labels. Data point attributes override scope attributes,
+// while scope attributes override resource attributes with the same names.
+//
+// Data points without timestamps get currentTimestamp in milliseconds.
+//
+// rs refers to req, so req must be unchanged until rs is in use.
+func (rs *Rows) Init(req *pb.ExportMetricsServiceRequest, currentTimestamp int64) {
+	rs.Reset()
+	for i := range req.ResourceMetrics {
+		rm := &req.ResourceMetrics[i]
+		for j := range rm.ScopeMetrics {
+			sm := &rm.ScopeMetrics[j]
+			for k := range sm.Metrics {
+				rs.appendMetric(&rm.Resource, &sm.Scope, &sm.Metrics[k], currentTimestamp)
+			}
+		}
+	}
+}
+
+func (rs *Rows) appendMetric(r *pb.Resource, scope *pb.InstrumentationScope, m *pb.Metric, currentTimestamp int64) {
+	metricName := sanitizeMetricName(m.Name)
+	switch {
+	case m.Gauge != nil:
+		rs.appendNumberDataPoints(r, scope, metricName, m.Gauge.DataPoints, currentTimestamp)
+	case m.Sum != nil:
+		if m.Sum.AggregationTemporality == pb.AggregationTemporalityDelta {
+			// Delta sums contain per-interval increments, so they would break rate() and increase()
+			// if stored as counters. They cannot be converted to running totals here,
+			// since data points for the same series may be sent to distinct vminsert nodes.
+			rs.UnsupportedMetrics++
+			return
+		}
+		rs.appendNumberDataPoints(r, scope, metricName, m.Sum.DataPoints, currentTimestamp)
+	case m.Histogram != nil:
+		if m.Histogram.AggregationTemporality == pb.AggregationTemporalityDelta {
+			// Delta histograms are skipped for the same reason as delta sums.
+			rs.UnsupportedMetrics++
+			return
+		}
+		rs.appendHistogramDataPoints(r, scope, metricName, m.Histogram.DataPoints, currentTimestamp)
+	case m.Summary != nil:
+		rs.appendSummaryDataPoints(r, scope, metricName, m.Summary.DataPoints, currentTimestamp)
+	default:
+		rs.UnsupportedMetrics++
+	}
+}
+
+func (rs *Rows) appendNumberDataPoints(r *pb.Resource, scope *pb.InstrumentationScope, metricName string, dps []pb.NumberDataPoint, currentTimestamp int64) {
+	for i := range dps {
+		dp := &dps[i]
+		if dp.Flags&pb.DataPointFlagNoRecordedValue != 0 {
+			continue
+		}
+		tags := rs.appendTags(r, scope, dp.Attributes)
+		timestamp := getTimestamp(dp.TimeUnixNano, currentTimestamp)
+		rs.addRow(metricName, tags, timestamp, dp.Value)
+	}
+}
+
+func (rs *Rows) appendHistogramDataPoints(r *pb.Resource, scope *pb.InstrumentationScope, metricName string, dps []pb.HistogramDataPoint, currentTimestamp int64) {
+	for i := range dps {
+		dp := &dps[i]
+		if dp.Flags&pb.DataPointFlagNoRecordedValue != 0 {
+			continue
+		}
+		tags := rs.appendTags(r, scope, dp.Attributes)
+		timestamp := getTimestamp(dp.TimeUnixNano, currentTimestamp)
+		rs.addRow(metricName+"_count", tags, timestamp, float64(dp.Count))
+		if dp.HasSum {
+			rs.addRow(metricName+"_sum", tags, timestamp, dp.Sum)
+		}
+		if len(dp.BucketCounts) == 0 || len(dp.BucketCounts) != len(dp.ExplicitBounds)+1 {
+			// The data point has no buckets or it is malformed.
+			continue
+		}
+		// OTLP bucket counts aren't cumulative, while Prometheus buckets are cumulative.
+		bucketName := metricName + "_bucket"
+		cumulativeCount := uint64(0)
+		for j, bound := range dp.ExplicitBounds {
+			cumulativeCount += dp.BucketCounts[j]
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			bucketTags := rs.appendTagsWithExtra(tags, "le", le)
+			rs.addRow(bucketName, bucketTags, timestamp, float64(cumulativeCount))
+		}
+		cumulativeCount += dp.BucketCounts[len(dp.BucketCounts)-1]
+		bucketTags := rs.appendTagsWithExtra(tags, "le", "+Inf")
+		rs.addRow(bucketName, bucketTags, timestamp, float64(cumulativeCount))
+	}
+}
+
+func (rs *Rows) appendSummaryDataPoints(r *pb.Resource, scope *pb.InstrumentationScope, metricName string, dps []pb.SummaryDataPoint, currentTimestamp int64) {
+	for i := range dps {
+		dp := &dps[i]
+		if dp.Flags&pb.DataPointFlagNoRecordedValue != 0 {
+			continue
+		}
+		tags := rs.appendTags(r, scope, dp.Attributes)
+		timestamp := getTimestamp(dp.TimeUnixNano, currentTimestamp)
+		rs.addRow(metricName+"_count", tags, timestamp, float64(dp.Count))
+		rs.addRow(metricName+"_sum", tags, timestamp, dp.Sum)
+		for j := range dp.QuantileValues {
+			q := &dp.QuantileValues[j]
+			quantile := strconv.FormatFloat(q.Quantile, 'g', -1, 64)
+			quantileTags := rs.appendTagsWithExtra(tags, "quantile", quantile)
+			rs.addRow(metricName, quantileTags, timestamp, q.Value)
+		}
+	}
+}
+
+func getTimestamp(timeUnixNano uint64, currentTimestamp int64) int64 {
+	if timeUnixNano == 0 {
+		return currentTimestamp
+	}
+	return int64(timeUnixNano / 1e6)
+}
+
+func (rs *Rows) addRow(metricName string, tags []Tag, timestamp int64, value float64) {
+	if cap(rs.Rows) > len(rs.Rows) {
+		rs.Rows = rs.Rows[:len(rs.Rows)+1]
+	} else {
+		rs.Rows = append(rs.Rows, Row{})
+	}
+	row := &rs.Rows[len(rs.Rows)-1]
+	row.Metric = metricName
+	row.Tags = tags
+	row.Value = value
+	row.Timestamp = timestamp
+}
+
+// appendTags appends tags for the given resource, scope and data point attributes to rs.tagsPool
+// and returns the appended tags.
+func (rs *Rows) appendTags(r *pb.Resource, scope *pb.InstrumentationScope, attributes []pb.KeyValue) []Tag {
+	tagsStart := len(rs.tagsPool)
+	rs.addAttributes(tagsStart, r.Attributes)
+	if len(scope.Name) > 0 {
+		rs.setTag(tagsStart, "otel_scope_name", scope.Name)
+	}
+	if len(scope.Version) > 0 {
+		rs.setTag(tagsStart, "otel_scope_version", scope.Version)
+	}
+	rs.addAttributes(tagsStart, scope.Attributes)
+	rs.addAttributes(tagsStart, attributes)
+	tags := rs.tagsPool[tagsStart:]
+	return tags[:len(tags):len(tags)]
+}
+
+// appendTagsWithExtra appends a copy of tags with the additional (key, value) tag to rs.tagsPool
+// and returns the appended tags.
+func (rs *Rows) appendTagsWithExtra(tags []Tag, key, value string) []Tag {
+	tagsStart := len(rs.tagsPool)
+	for i := range tags {
+		rs.addTag(tags[i].Key, tags[i].Value)
+	}
+	rs.setTag(tagsStart, key, value)
+	newTags := rs.tagsPool[tagsStart:]
+	return newTags[:len(newTags):len(newTags)]
+}
+
+func (rs *Rows) addAttributes(tagsStart int, attributes []pb.KeyValue) {
+	for i := range attributes {
+		kv := &attributes[i]
+		key := sanitizeLabelName(kv.Key)
+		if len(key) == 0 {
+			continue
+		}
+		value := kv.Value.FormatString()
+		if len(value) == 0 {
+			// Skip empty labels, since they are equivalent to missing labels.
+			continue
+		}
+		rs.setTag(tagsStart, key, value)
+	}
+}
+
+// setTag sets the tag with the given key to value in rs.tagsPool[tagsStart:].
+//
+// The tag is added if it is missing.
+func (rs *Rows) setTag(tagsStart int, key, value string) {
+	tags := rs.tagsPool[tagsStart:]
+	for i := range tags {
+		if tags[i].Key == key {
+			tags[i].Value = value
+			return
+		}
+	}
+	rs.addTag(key, value)
+}
+
+func (rs *Rows) addTag(key, value string) {
+	if cap(rs.tagsPool) > len(rs.tagsPool) {
+		rs.tagsPool = rs.tagsPool[:len(rs.tagsPool)+1]
+	} else {
+		rs.tagsPool = append(rs.tagsPool, Tag{})
+	}
+	tag := &rs.tagsPool[len(rs.tagsPool)-1]
+	tag.Key = key
+	tag.Value = value
+}
+
+// sanitizeMetricName converts name to Prometheus-compatible metric name.
+//
+// Chars outside [a-zA-Z0-9_:] are replaced with `_`. The `_` prefix is added
+// to names starting with a digit.
+func sanitizeMetricName(name string) string {
+	return sanitizeName(name, true)
+}
+
+// sanitizeLabelName converts name to Prometheus-compatible label name.
+//
+// Chars outside [a-zA-Z0-9_] are replaced with `_`. The `_` prefix is added
+// to names starting with a digit.
+func sanitizeLabelName(name string) string {
+	return sanitizeName(name, false)
+}
+
+func sanitizeName(name string, allowColon bool) string {
+	if isValidName(name, allowColon) {
+		// Fast path - the name is already valid.
+		return name
+	}
+	b := make([]byte, 0, len(name)+1)
+	if name[0] >= '0' && name[0] <= '9' {
+		b = append(b, '_')
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if isValidNameChar(c, allowColon) {
+			b = append(b, c)
+		} else {
+			b = append(b, '_')
+		}
+	}
+	return string(b)
+}
+
+func isValidName(name string, allowColon bool) bool {
+	if len(name) == 0 {
+		return true
+	}
+	if name[0] >= '0' && name[0] <= '9' {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isValidNameChar(name[i], allowColon) {
+			return false
+		}
+	}
+	return true
+}
+
+func isValidNameChar(c byte, allowColon bool) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == ':' && allowColon
+}
+
+// Row is a single row obtained from OTLP data point.
+type Row struct {
+	Metric    string
+	Tags      []Tag
+	Value     float64
+	Timestamp int64
+}
+
+func (r *Row) reset() {
+	r.Metric = ""
+	r.Tags = nil
+	r.Value = 0
+	r.Timestamp = 0
+}
+
+// Tag is a single label for OTLP row.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+func (t *Tag) reset() {
+	t.Key = ""
+	t.Value = ""
+}
@@ -0,0 +1,204 @@
+package opentelemetry
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/opentelemetry/pb"
+)
+
+func TestSanitizeName(t *testing.T) {
+	f := func(name, metricNameExpected, labelNameExpected string) {
+		t.Helper()
+		metricName := sanitizeMetricName(name)
+		if metricName != metricNameExpected {
+			t.Fatalf("unexpected metric name for %q; got %q; want %q", name, metricName, metricNameExpected)
+		}
+		labelName := sanitizeLabelName(name)
+		if labelName != labelNameExpected {
+			t.Fatalf("unexpected label name for %q; got %q; want %q", name, labelName, labelNameExpected)
+		}
+	}
+	f("", "", "")
+	f("foo_Bar9", "foo_Bar9", "foo_Bar9")
+	f("foo:bar", "foo:bar", "foo_bar")
+	f("service.name", "service_name", "service_name")
+	f("http.server.request-duration", "http_server_request_duration", "http_server_request_duration")
+	f("9lives", "_9lives", "_9lives")
+	f("фу", "____", "____")
+}
+
+func stringAttr(key, value string) pb.KeyValue {
+	return pb.KeyValue{
+		Key: key,
+		Value: pb.AnyValue{
+			Type:        pb.AnyValueString,
+			StringValue: value,
+		},
+	}
+}
+
+func TestRowsInit(t *testing.T) {
+	f := func(req *pb.ExportMetricsServiceRequest, resultExpected string, unsupportedMetricsExpected int) {
+		t.Helper()
+		var rs Rows
+		rs.Init(req, 1000)
+		var a []string
+		for _, r := range rs.Rows {
+			var tags []string
+			for _, tag := range r.Tags {
+				tags = append(tags, fmt.Sprintf("%s=%q", tag.Key, tag.Value))
+			}
+			a = append(a, fmt.Sprintf("%s{%s} %v %d", r.Metric, strings.Join(tags, ","), r.Value, r.Timestamp))
+		}
+		result := strings.Join(a, "\n")
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+		if rs.UnsupportedMetrics != unsupportedMetricsExpected {
+			t.Fatalf("unexpected number of unsupported metrics; got %d; want %d", rs.UnsupportedMetrics, unsupportedMetricsExpected)
+		}
+	}
+
+	// Empty request
+	f(&pb.ExportMetricsServiceRequest{}, "", 0)
+
+	newRequest := func(metrics ...pb.Metric) *pb.ExportMetricsServiceRequest {
+		return &pb.ExportMetricsServiceRequest{
+			ResourceMetrics: []pb.ResourceMetrics{{
+				Resource: pb.Resource{
+					Attributes: []pb.KeyValue{
+						stringAttr("service.name", "api"),
+						stringAttr("host", "resource-host"),
+						{Key: "pid", Value: pb.AnyValue{Type: pb.AnyValueInt, IntValue: 42}},
+						stringAttr("empty", ""),
+					},
+				},
+				ScopeMetrics: []pb.ScopeMetrics{{
+					Scope: pb.InstrumentationScope{
+						Name:    "io.lib",
+						Version: "1.0",
+					},
+					Metrics: metrics,
+				}},
+			}},
+		}
+	}
+
+	// Gauge and sums with data point attributes overriding resource attributes.
+	f(newRequest(pb.Metric{
+		Name: "system.cpu.utilization",
+		Gauge: &pb.Gauge{
+			DataPoints: []pb.NumberDataPoint{
+				{
+					Attributes:   []pb.KeyValue{stringAttr("host", "dp-host"), stringAttr("cpu.id", "0")},
+					TimeUnixNano: 2e9 + 123456,
+					Value:        0.5,
+				},
+				{
+					Value: 0.25,
+				},
+				{
+					// Data point without recorded value must be skipped.
+					Flags: pb.DataPointFlagNoRecordedValue,
+					Value: 123,
+				},
+			},
+		},
+	}, pb.Metric{
+		Name: "requests",
+		Sum: &pb.Sum{
+			DataPoints: []pb.NumberDataPoint{{
+				TimeUnixNano: 3e9,
+				Value:        10,
+			}},
+			AggregationTemporality: pb.AggregationTemporalityCumulative,
+			IsMonotonic:            true,
+		},
+	}, pb.Metric{
+		// Delta sum must be skipped, since it cannot be stored as a counter.
+		Name: "delta",
+		Sum: &pb.Sum{
+			DataPoints: []pb.NumberDataPoint{{
+				TimeUnixNano: 4e9,
+				Value:        3,
+			}},
+			AggregationTemporality: pb.AggregationTemporalityDelta,
+		},
+	}), `system_cpu_utilization{service_name="api",host="dp-host",pid="42",otel_scope_name="io.lib",otel_scope_version="1.0",cpu_id="0"} 0.5 2000
+system_cpu_utilization{service_name="api",host="resource-host",pid="42",otel_scope_name="io.lib",otel_scope_version="1.0"} 0.25 1000
+requests{service_name="api",host="resource-host",pid="42",otel_scope_name="io.lib",otel_scope_version="1.0"} 10 3000`, 1)
+
+	// Histogram, summary, unsupported delta histogram and unsupported exponential histogram.
+	req := &pb.ExportMetricsServiceRequest{
+		ResourceMetrics: []pb.ResourceMetrics{{
+			ScopeMetrics: []pb.ScopeMetrics{{
+				Metrics: []pb.Metric{
+					{
+						Name: "http.duration",
+						Histogram: &pb.Histogram{
+							DataPoints: []pb.HistogramDataPoint{
+								{
+									Attributes:     []pb.KeyValue{stringAttr("path", "/")},
+									TimeUnixNano:   5e9,
+									Count:          6,
+									Sum:            7.5,
+									HasSum:         true,
+									BucketCounts:   []uint64{1, 2, 3},
+									ExplicitBounds: []float64{0.5, 1},
+								},
+								{
+									// Histogram without buckets and sum.
+									TimeUnixNano: 6e9,
+									Count:        2,
+								},
+							},
+							AggregationTemporality: pb.AggregationTemporalityCumulative,
+						},
+					},
+					{
+						Name: "rpc",
+						Summary: &pb.Summary{
+							DataPoints: []pb.SummaryDataPoint{{
+								TimeUnixNano: 7e9,
+								Count:        10,
+								Sum:          20,
+								QuantileValues: []pb.ValueAtQuantile{
+									{Quantile: 0.5, Value: 1},
+									{Quantile: 0.99, Value: 3},
+								},
+							}},
+						},
+					},
+					{
+						// Delta histogram must be skipped.
+						Name: "delta.duration",
+						Histogram: &pb.Histogram{
+							DataPoints: []pb.HistogramDataPoint{{
+								TimeUnixNano:   8e9,
+								Count:          1,
+								BucketCounts:   []uint64{1, 0},
+								ExplicitBounds: []float64{1},
+							}},
+							AggregationTemporality: pb.AggregationTemporalityDelta,
+						},
+					},
+					{
+						Name: "exp",
+					},
+				},
+			}},
+		}},
+	}
+	f(req, `http_duration_count{path="/"} 6 5000
+http_duration_sum{path="/"} 7.5 5000
+http_duration_bucket{path="/",le="0.5"} 1 5000
+http_duration_bucket{path="/",le="1"} 3 5000
+http_duration_bucket{path="/",le="+Inf"} 6 5000
+http_duration_count{} 2 6000
+rpc_count{} 10 7000
+rpc_sum{} 20 7000
+rpc{quantile="0.5"} 1 7000
+rpc{quantile="0.99"} 3 7000`, 2)
+}
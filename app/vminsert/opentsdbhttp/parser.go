@@ -0,0 +1,223 @@
+package opentsdbhttp
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/valyala/fastjson"
+)
+
+// Rows contains parsed OpenTSDB rows from `/api/put` request.
+type Rows struct {
+	Rows []Row
+
+	// Errors contains datapoints, which couldn't be parsed.
+	Errors []DatapointError
+
+	p        fastjson.Parser
+	tagsPool []Tag
+}
+
+// Reset resets rs.
+func (rs *Rows) Reset() {
+	// Release references to objects, so they can be GC'ed.
+
+	for i := range rs.Rows {
+		rs.Rows[i].reset()
+	}
+	rs.Rows = rs.Rows[:0]
+
+	for i := range rs.Errors {
+		rs.Errors[i].reset()
+	}
+	rs.Errors = rs.Errors[:0]
+
+	for i := range rs.tagsPool {
+		rs.tagsPool[i].reset()
+	}
+	rs.tagsPool = rs.tagsPool[:0]
+}
+
+// Unmarshal unmarshals OpenTSDB `/api/put` request body from s.
+//
+// s may contain either a single datapoint object or an array of datapoint objects.
+// Invalid datapoints are put into rs.Errors, while the error is returned
+// only if s isn't valid JSON.
+//
+// See http://opentsdb.net/docs/build/html/api_http/put.html
+//
+// s must be unchanged until rs is in use.
+func (rs *Rows) Unmarshal(s string) error {
+	rs.Reset()
+	v, err := rs.p.Parse(s)
+	if err != nil {
+		return fmt.Errorf("cannot parse json: %s", err)
+	}
+	switch v.Type() {
+	case fastjson.TypeObject:
+		rs.unmarshalDatapoint(v)
+	case fastjson.TypeArray:
+		a, _ := v.Array()
+		for _, v := range a {
+			rs.unmarshalDatapoint(v)
+		}
+	default:
+		return fmt.Errorf("json must be either object or array; got %s", v.Type())
+	}
+	return nil
+}
+
+func (rs *Rows) unmarshalDatapoint(v *fastjson.Value) {
+	if cap(rs.Rows) > len(rs.Rows) {
+		rs.Rows = rs.Rows[:len(rs.Rows)+1]
+	} else {
+		rs.Rows = append(rs.Rows, Row{})
+	}
+	r := &rs.Rows[len(rs.Rows)-1]
+	var err error
+	rs.tagsPool, err = r.unmarshal(v, rs.tagsPool)
+	if err == nil {
+		return
+	}
+	rs.Rows = rs.Rows[:len(rs.Rows)-1]
+	rs.Errors = append(rs.Errors, DatapointError{
+		Datapoint: v.String(),
+		Error:     err.Error(),
+	})
+}
+
+// Row is a single OpenTSDB datapoint.
+type Row struct {
+	Metric string
+	Tags   []Tag
+	Value  float64
+
+	// Timestamp is in milliseconds. It is set to 0 if the datapoint has no timestamp.
+	Timestamp int64
+}
+
+func (r *Row) reset() {
+	r.Metric = ""
+	r.Tags = nil
+	r.Value = 0
+	r.Timestamp = 0
+}
+
+func (r *Row) unmarshal(v *fastjson.Value, tagsPool []Tag) ([]Tag, error) {
+	r.reset()
+	if v.Type() != fastjson.TypeObject {
+		return tagsPool, fmt.Errorf("datapoint must be object; got %s", v.Type())
+	}
+
+	// Unmarshal metric
+	metric := v.Get("metric")
+	if metric == nil {
+		return tagsPool, fmt.Errorf("missing `metric` field")
+	}
+	b, err := metric.StringBytes()
+	if err != nil {
+		return tagsPool, fmt.Errorf("`metric` must be string: %s", err)
+	}
+	if len(b) == 0 {
+		return tagsPool, fmt.Errorf("`metric` cannot be empty")
+	}
+	r.Metric = bytesutil.ToUnsafeString(b)
+
+	// Unmarshal timestamp
+	if ts := v.Get("timestamp"); ts != nil {
+		n, err := ts.Int64()
+		if err != nil {
+			return tagsPool, fmt.Errorf("cannot unmarshal `timestamp`: %s", err)
+		}
+		if n < 0 {
+			return tagsPool, fmt.Errorf("`timestamp` cannot be negative; got %d", n)
+		}
+		if n < 1<<32 {
+			// Timestamps in seconds have no bits set in the upper 32 bits like OpenTSDB expects.
+			n *= 1e3
+		}
+		r.Timestamp = n
+	}
+
+	// Unmarshal value
+	value := v.Get("value")
+	if value == nil {
+		return tagsPool, fmt.Errorf("missing `value` field")
+	}
+	switch value.Type() {
+	case fastjson.TypeNumber:
+		r.Value, _ = value.Float64()
+	case fastjson.TypeString:
+		b, _ := value.StringBytes()
+		f, err := strconv.ParseFloat(bytesutil.ToUnsafeString(b), 64)
+		if err != nil {
+			return tagsPool, fmt.Errorf("cannot parse `value`: %s", err)
+		}
+		r.Value = f
+	default:
+		return tagsPool, fmt.Errorf("`value` must be either number or string; got %s", value.Type())
+	}
+
+	// Unmarshal tags
+	tagsStart := len(tagsPool)
+	if tags := v.Get("tags"); tags != nil {
+		o, err := tags.Object()
+		if err != nil {
+			return tagsPool, fmt.Errorf("`tags` must be object: %s", err)
+		}
+		o.Visit(func(key []byte, v *fastjson.Value) {
+			if err != nil {
+				return
+			}
+			if len(key) == 0 {
+				err = fmt.Errorf("tag name cannot be empty")
+				return
+			}
+			value, errLocal := v.StringBytes()
+			if errLocal != nil {
+				err = fmt.Errorf("value for tag %q must be string: %s", key, errLocal)
+				return
+			}
+			if cap(tagsPool) > len(tagsPool) {
+				tagsPool = tagsPool[:len(tagsPool)+1]
+			} else {
+				tagsPool = append(tagsPool, Tag{})
+			}
+			tag := &tagsPool[len(tagsPool)-1]
+			tag.Key = bytesutil.ToUnsafeString(key)
+			tag.Value = bytesutil.ToUnsafeString(value)
+		})
+		if err != nil {
+			return tagsPool[:tagsStart], err
+		}
+	}
+	if tags := tagsPool[tagsStart:]; len(tags) > 0 {
+		r.Tags = tags[:len(tags):len(tags)]
+	}
+	return tagsPool, nil
+}
+
+// Tag is an OpenTSDB tag.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+func (t *Tag) reset() {
+	t.Key = ""
+	t.Value = ""
+}
+
+// DatapointError describes a datapoint, which couldn't be parsed.
+type DatapointError struct {
+	// Datapoint is the original datapoint in JSON.
+	Datapoint string
+
+	Error string
+}
+
+func (de *DatapointError) reset() {
+	de.Datapoint = ""
+	de.Error = ""
+}
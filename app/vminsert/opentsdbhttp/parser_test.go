@@ -0,0 +1,148 @@
+package opentsdbhttp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRowsUnmarshalFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		var rows Rows
+		if err := rows.Unmarshal(s); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", s)
+		}
+
+		// Try again
+		if err := rows.Unmarshal(s); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", s)
+		}
+	}
+
+	// Invalid json
+	f("")
+	f("{")
+	f(`[{"metric":"foo"}`)
+
+	// Invalid top-level type
+	f("123")
+	f(`"foo"`)
+}
+
+func TestRowsUnmarshalDatapointErrors(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		var rows Rows
+		if err := rows.Unmarshal(s); err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		if len(rows.Rows) != 0 {
+			t.Fatalf("expecting zero rows when parsing %q; got %+v", s, rows.Rows)
+		}
+		if len(rows.Errors) != 1 {
+			t.Fatalf("expecting a single datapoint error when parsing %q; got %+v", s, rows.Errors)
+		}
+	}
+
+	// Datapoint isn't an object
+	f(`[123]`)
+
+	// Missing metric
+	f(`{"timestamp":1,"value":2,"tags":{"a":"b"}}`)
+	f(`{"metric":"","value":2}`)
+	f(`{"metric":123,"value":2}`)
+
+	// Invalid timestamp
+	f(`{"metric":"foo","timestamp":"bar","value":2}`)
+	f(`{"metric":"foo","timestamp":-1,"value":2}`)
+	f(`{"metric":"foo","timestamp":1.5,"value":2}`)
+
+	// Invalid value
+	f(`{"metric":"foo","timestamp":1}`)
+	f(`{"metric":"foo","value":"bar"}`)
+	f(`{"metric":"foo","value":true}`)
+
+	// Invalid tags
+	f(`{"metric":"foo","value":1,"tags":"bar"}`)
+	f(`{"metric":"foo","value":1,"tags":{"":"bar"}}`)
+	f(`{"metric":"foo","value":1,"tags":{"a":1}}`)
+}
+
+func TestRowsUnmarshalSuccess(t *testing.T) {
+	f := func(s string, rowsExpected []Row, errorsCountExpected int) {
+		t.Helper()
+		var rows Rows
+		if err := rows.Unmarshal(s); err != nil {
+			t.Fatalf("cannot unmarshal %q: %s", s, err)
+		}
+		if !reflect.DeepEqual(rows.Rows, rowsExpected) {
+			t.Fatalf("unexpected rows;\ngot\n%+v;\nwant\n%+v", rows.Rows, rowsExpected)
+		}
+		if len(rows.Errors) != errorsCountExpected {
+			t.Fatalf("unexpected number of datapoint errors; got %d; want %d", len(rows.Errors), errorsCountExpected)
+		}
+
+		// Try unmarshaling again
+		if err := rows.Unmarshal(s); err != nil {
+			t.Fatalf("cannot unmarshal %q: %s", s, err)
+		}
+		if !reflect.DeepEqual(rows.Rows, rowsExpected) {
+			t.Fatalf("unexpected rows on the second unmarshal;\ngot\n%+v;\nwant\n%+v", rows.Rows, rowsExpected)
+		}
+	}
+
+	// Empty array
+	f(`[]`, nil, 0)
+
+	// Single object with timestamp in seconds
+	f(`{"metric":"sys.cpu.nice","timestamp":1346846400,"value":18,"tags":{"host":"web01","dc":"lga"}}`, []Row{{
+		Metric: "sys.cpu.nice",
+		Tags: []Tag{
+			{
+				Key:   "host",
+				Value: "web01",
+			},
+			{
+				Key:   "dc",
+				Value: "lga",
+			},
+		},
+		Value:     18,
+		Timestamp: 1346846400000,
+	}}, 0)
+
+	// Timestamp in milliseconds, string value and missing tags
+	f(`{"metric":"foo","timestamp":1346846400123,"value":"-1.5e3"}`, []Row{{
+		Metric:    "foo",
+		Value:     -1500,
+		Timestamp: 1346846400123,
+	}}, 0)
+
+	// Missing timestamp
+	f(`{"metric":"foo","value":1.25,"tags":{"a":"b"}}`, []Row{{
+		Metric: "foo",
+		Tags: []Tag{{
+			Key:   "a",
+			Value: "b",
+		}},
+		Value: 1.25,
+	}}, 0)
+
+	// Array with an invalid datapoint in the middle
+	f(`[{"metric":"foo","timestamp":1,"value":2,"tags":{"x":"y"}},{"metric":"bar","value":"baz","tags":{"a":"b"}},{"metric":"baz","timestamp":3,"value":4}]`, []Row{
+		{
+			Metric: "foo",
+			Tags: []Tag{{
+				Key:   "x",
+				Value: "y",
+			}},
+			Value:     2,
+			Timestamp: 1000,
+		},
+		{
+			Metric:    "baz",
+			Value:     4,
+			Timestamp: 3000,
+		},
+	}, 1)
+}
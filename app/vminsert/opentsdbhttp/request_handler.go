@@ -0,0 +1,189 @@
+package opentsdbhttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/concurrencylimiter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	rowsInserted = metrics.NewCounter(`vm_rows_inserted_total{type="opentsdbhttp"}`)
+	rowsRejected = common.NewRejectedRowsCounters("opentsdbhttp")
+)
+
+// InsertHandler processes OpenTSDB `/api/put` request for the given tenant.
+//
+// The response is written to w on success. Invalid datapoints are reported
+// in the response if `summary` or `details` query arg is set.
+// Otherwise an error is returned if at least a single datapoint is invalid.
+//
+// See http://opentsdb.net/docs/build/html/api_http/put.html
+func InsertHandler(at *auth.Token, w http.ResponseWriter, req *http.Request, maxSize int64) error {
+	return concurrencylimiter.Do(func() error {
+		return insertHandlerInternal(at, w, req, maxSize)
+	})
+}
+
+func insertHandlerInternal(at *auth.Token, w http.ResponseWriter, req *http.Request, maxSize int64) error {
+	ctx := getPushCtx()
+	defer putPushCtx(ctx)
+	if err := ctx.Read(req, maxSize); err != nil {
+		return err
+	}
+	if err := ctx.InsertRows(at); err != nil {
+		return err
+	}
+
+	rows := &ctx.Rows
+	details := req.URL.Query()["details"] != nil
+	summary := details || req.URL.Query()["summary"] != nil
+	if !summary {
+		if len(rows.Errors) > 0 {
+			de := &rows.Errors[0]
+			return fmt.Errorf("cannot parse %d out of %d datapoints; the first error: %s; datapoint: %s",
+				len(rows.Errors), len(rows.Errors)+len(rows.Rows), de.Error, de.Datapoint)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if len(rows.Errors) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if details {
+		WritePutDetailsResponse(w, rows)
+	} else {
+		WritePutSummaryResponse(w, rows)
+	}
+	return nil
+}
+
+func (ctx *pushCtx) InsertRows(at *auth.Token) error {
+	rows := ctx.Rows.Rows
+	ic := &ctx.Common
+	ic.Reset(len(rows), rowsRejected)
+	currentTimestamp := time.Now().UnixNano() / 1e6
+	for i := range rows {
+		r := &rows[i]
+		ic.Labels = ic.Labels[:0]
+		ic.AddLabel("", r.Metric)
+		for j := range r.Tags {
+			tag := &r.Tags[j]
+			ic.AddLabel(tag.Key, tag.Value)
+		}
+		timestamp := r.Timestamp
+		if timestamp == 0 {
+			timestamp = currentTimestamp
+		}
+		ic.WriteDataPoint(at, nil, ic.Labels, timestamp, r.Value)
+	}
+	rowsInserted.Add(len(rows))
+	return ic.FlushBufs()
+}
+
+func (ctx *pushCtx) Read(req *http.Request, maxSize int64) error {
+	opentsdbhttpReadCalls.Inc()
+
+	r := req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := getGzipReader(r)
+		if err != nil {
+			opentsdbhttpReadErrors.Inc()
+			return fmt.Errorf("cannot read gzipped OpenTSDB data: %s", err)
+		}
+		defer putGzipReader(zr)
+		r = zr
+	}
+
+	lr := io.LimitReader(r, maxSize+1)
+	bb := bytes.NewBuffer(ctx.reqBuf[:0])
+	_, err := bb.ReadFrom(lr)
+	ctx.reqBuf = bb.Bytes()
+	if err != nil {
+		opentsdbhttpReadErrors.Inc()
+		return fmt.Errorf("cannot read OpenTSDB data: %s", err)
+	}
+	if int64(len(ctx.reqBuf)) > maxSize {
+		opentsdbhttpReadErrors.Inc()
+		return fmt.Errorf("too big request; it mustn't exceed %d bytes", maxSize)
+	}
+	if err := ctx.Rows.Unmarshal(bytesutil.ToUnsafeString(ctx.reqBuf)); err != nil {
+		opentsdbhttpUnmarshalErrors.Inc()
+		return fmt.Errorf("cannot unmarshal OpenTSDB data with size %d: %s", len(ctx.reqBuf), err)
+	}
+	opentsdbhttpUnmarshalErrors.Add(len(ctx.Rows.Errors))
+	return nil
+}
+
+func getGzipReader(r io.Reader) (*gzip.Reader, error) {
+	v := gzipReaderPool.Get()
+	if v == nil {
+		return gzip.NewReader(r)
+	}
+	zr := v.(*gzip.Reader)
+	if err := zr.Reset(r); err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
+func putGzipReader(zr *gzip.Reader) {
+	_ = zr.Close()
+	gzipReaderPool.Put(zr)
+}
+
+var gzipReaderPool sync.Pool
+
+type pushCtx struct {
+	Rows   Rows
+	Common common.InsertCtx
+
+	reqBuf []byte
+}
+
+func (ctx *pushCtx) reset() {
+	ctx.Rows.Reset()
+	ctx.Common.Reset(0, nil)
+	ctx.reqBuf = ctx.reqBuf[:0]
+}
+
+var (
+	opentsdbhttpReadCalls       = metrics.NewCounter(`vm_read_calls_total{name="opentsdbhttp"}`)
+	opentsdbhttpReadErrors      = metrics.NewCounter(`vm_read_errors_total{name="opentsdbhttp"}`)
+	opentsdbhttpUnmarshalErrors = metrics.NewCounter(`vm_unmarshal_errors_total{name="opentsdbhttp"}`)
+)
+
+func getPushCtx() *pushCtx {
+	select {
+	case ctx := <-pushCtxPoolCh:
+		return ctx
+	default:
+		if v := pushCtxPool.Get(); v != nil {
+			return v.(*pushCtx)
+		}
+		return &pushCtx{}
+	}
+}
+
+func putPushCtx(ctx *pushCtx) {
+	ctx.reset()
+	select {
+	case pushCtxPoolCh <- ctx:
+	default:
+		pushCtxPool.Put(ctx)
+	}
+}
+
+var pushCtxPool sync.Pool
+var pushCtxPoolCh = make(chan *pushCtx, runtime.GOMAXPROCS(-1))
@@ -8,38 +8,61 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/concurrencylimiter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
 	"github.com/VictoriaMetrics/metrics"
 )
 
-var rowsInserted = metrics.NewCounter(`vm_rows_inserted_total{type="prometheus"}`)
+var (
+	rowsInserted           = metrics.NewCounter(`vm_rows_inserted_total{type="prometheus"}`)
+	promscrapeRowsInserted = metrics.NewCounter(`vm_rows_inserted_total{type="promscrape"}`)
+	rowsRejected           = common.NewRejectedRowsCounters("prometheus")
+	promscrapeRowsRejected = common.NewRejectedRowsCounters("promscrape")
+)
 
-// InsertHandler processes remote write for prometheus.
-func InsertHandler(r *http.Request, maxSize int64) error {
+// InsertHandler processes remote write for prometheus for the given tenant.
+func InsertHandler(at *auth.Token, r *http.Request, maxSize int64) error {
 	return concurrencylimiter.Do(func() error {
-		return insertHandlerInternal(r, maxSize)
+		return insertHandlerInternal(at, r, maxSize)
 	})
 }
 
-func insertHandlerInternal(r *http.Request, maxSize int64) error {
+func insertHandlerInternal(at *auth.Token, r *http.Request, maxSize int64) error {
 	ctx := getPushCtx()
 	defer putPushCtx(ctx)
 	if err := ctx.Read(r, maxSize); err != nil {
 		return err
 	}
-	timeseries := ctx.req.Timeseries
+	return insertTimeseries(&ctx.Common, at, ctx.req.Timeseries, rowsInserted, rowsRejected)
+}
+
+// Push pushes wr scraped by lib/promscrape to the storage for the default tenant.
+//
+// wr may be reused by the caller after Push returns.
+func Push(wr *prompb.WriteRequest) {
+	err := concurrencylimiter.Do(func() error {
+		ctx := getPushCtx()
+		defer putPushCtx(ctx)
+		return insertTimeseries(&ctx.Common, auth.DefaultToken, wr.Timeseries, promscrapeRowsInserted, promscrapeRowsRejected)
+	})
+	if err != nil {
+		logger.Errorf("cannot push scraped data: %s", err)
+	}
+}
+
+func insertTimeseries(ic *common.InsertCtx, at *auth.Token, timeseries []prompb.TimeSeries, rowsInserted *metrics.Counter, rowsRejected *common.RejectedRowsCounters) error {
 	rowsLen := 0
 	for i := range timeseries {
 		rowsLen += len(timeseries[i].Samples)
 	}
-	ic := &ctx.Common
-	ic.Reset(rowsLen)
+	ic.Reset(rowsLen, rowsRejected)
 	for i := range timeseries {
 		ts := &timeseries[i]
 		var metricNameRaw []byte
 		for i := range ts.Samples {
 			r := &ts.Samples[i]
-			metricNameRaw = ic.WriteDataPointExt(metricNameRaw, ts.Labels, r.Timestamp, r.Value)
+			metricNameRaw = ic.WriteDataPointExt(at, metricNameRaw, ts.Labels, r.Timestamp, r.Value)
 		}
 		rowsInserted.Add(len(ts.Samples))
 	}
@@ -54,7 +77,7 @@ type pushCtx struct {
 }
 
 func (ctx *pushCtx) reset() {
-	ctx.Common.Reset(0)
+	ctx.Common.Reset(0, nil)
 	ctx.req.Reset()
 	ctx.reqBuf = ctx.reqBuf[:0]
 }
@@ -0,0 +1,226 @@
+package prometheusimport
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/concurrencylimiter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	rowsInserted = metrics.NewCounter(`vm_rows_inserted_total{type="prometheus_import"}`)
+	rowsRejected = common.NewRejectedRowsCounters("prometheus_import")
+)
+
+// InsertHandler processes `/api/v1/import/prometheus` request for the given tenant.
+//
+// The request body must contain data in Prometheus text exposition format.
+// Optional `extra_label=name=value` query args are added to all the imported rows.
+//
+// See https://github.com/prometheus/docs/blob/master/content/docs/instrumenting/exposition_formats.md#text-based-format
+func InsertHandler(at *auth.Token, req *http.Request) error {
+	extraLabels, err := getExtraLabels(req)
+	if err != nil {
+		return err
+	}
+	return concurrencylimiter.Do(func() error {
+		return insertHandlerInternal(at, req, extraLabels)
+	})
+}
+
+// extraLabel is a label passed via `extra_label` query arg.
+type extraLabel struct {
+	Name  string
+	Value string
+}
+
+func getExtraLabels(req *http.Request) ([]extraLabel, error) {
+	// Do not use req.FormValue, since it may consume the request body with the data.
+	var extraLabels []extraLabel
+	for _, s := range req.URL.Query()["extra_label"] {
+		n := strings.IndexByte(s, '=')
+		if n <= 0 {
+			return nil, fmt.Errorf("missing label name in `extra_label`=%q; it must be in the form `name=value`", s)
+		}
+		extraLabels = append(extraLabels, extraLabel{
+			Name:  s[:n],
+			Value: s[n+1:],
+		})
+	}
+	return extraLabels, nil
+}
+
+func insertHandlerInternal(at *auth.Token, req *http.Request, extraLabels []extraLabel) error {
+	prometheusImportReadCalls.Inc()
+
+	r := req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := getGzipReader(r)
+		if err != nil {
+			return fmt.Errorf("cannot read gzipped Prometheus text exposition data: %s", err)
+		}
+		defer putGzipReader(zr)
+		r = zr
+	}
+
+	ctx := getPushCtx()
+	defer putPushCtx(ctx)
+	for ctx.Read(r) {
+		if err := ctx.InsertRows(at, extraLabels); err != nil {
+			return err
+		}
+	}
+	return ctx.Error()
+}
+
+func (ctx *pushCtx) InsertRows(at *auth.Token, extraLabels []extraLabel) error {
+	rows := ctx.Rows.Rows
+	ic := &ctx.Common
+	ic.Reset(len(rows), rowsRejected)
+	for i := range rows {
+		r := &rows[i]
+		ic.Labels = ic.Labels[:0]
+		ic.AddLabel("", r.Metric)
+		for j := range r.Tags {
+			tag := &r.Tags[j]
+			if hasExtraLabel(extraLabels, tag.Key) {
+				// Extra labels override labels with the same names.
+				continue
+			}
+			ic.AddLabel(tag.Key, tag.Value)
+		}
+		for j := range extraLabels {
+			label := &extraLabels[j]
+			ic.AddLabel(label.Name, label.Value)
+		}
+		ic.WriteDataPoint(at, nil, ic.Labels, r.Timestamp, r.Value)
+	}
+	rowsInserted.Add(len(rows))
+	return ic.FlushBufs()
+}
+
+func hasExtraLabel(extraLabels []extraLabel, name string) bool {
+	for i := range extraLabels {
+		if extraLabels[i].Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func getGzipReader(r io.Reader) (*gzip.Reader, error) {
+	v := gzipReaderPool.Get()
+	if v == nil {
+		return gzip.NewReader(r)
+	}
+	zr := v.(*gzip.Reader)
+	if err := zr.Reset(r); err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
+func putGzipReader(zr *gzip.Reader) {
+	_ = zr.Close()
+	gzipReaderPool.Put(zr)
+}
+
+var gzipReaderPool sync.Pool
+
+func (ctx *pushCtx) Read(r io.Reader) bool {
+	if ctx.err != nil {
+		return false
+	}
+	ctx.reqBuf, ctx.tailBuf, ctx.err = common.ReadLinesBlock(r, ctx.reqBuf, ctx.tailBuf)
+	if ctx.err != nil {
+		if ctx.err != io.EOF {
+			prometheusImportReadErrors.Inc()
+			ctx.err = fmt.Errorf("cannot read Prometheus text exposition data: %s", ctx.err)
+		}
+		return false
+	}
+	if err := ctx.Rows.Unmarshal(bytesutil.ToUnsafeString(ctx.reqBuf)); err != nil {
+		prometheusImportUnmarshalErrors.Inc()
+		ctx.err = fmt.Errorf("cannot unmarshal Prometheus text exposition data with size %d: %s", len(ctx.reqBuf), err)
+		return false
+	}
+
+	// Fill missing timestamps with the current timestamp.
+	currentTimestamp := time.Now().UnixNano() / 1e6
+	rows := ctx.Rows.Rows
+	for i := range rows {
+		r := &rows[i]
+		if r.Timestamp == 0 {
+			r.Timestamp = currentTimestamp
+		}
+	}
+	return true
+}
+
+var (
+	prometheusImportReadCalls       = metrics.NewCounter(`vm_read_calls_total{name="prometheus_import"}`)
+	prometheusImportReadErrors      = metrics.NewCounter(`vm_read_errors_total{name="prometheus_import"}`)
+	prometheusImportUnmarshalErrors = metrics.NewCounter(`vm_unmarshal_errors_total{name="prometheus_import"}`)
+)
+
+type pushCtx struct {
+	Rows   promscrape.Rows
+	Common common.InsertCtx
+
+	reqBuf  []byte
+	tailBuf []byte
+
+	err error
+}
+
+func (ctx *pushCtx) Error() error {
+	if ctx.err == io.EOF {
+		return nil
+	}
+	return ctx.err
+}
+
+func (ctx *pushCtx) reset() {
+	ctx.Rows.Reset()
+	ctx.Common.Reset(0, nil)
+
+	ctx.reqBuf = ctx.reqBuf[:0]
+	ctx.tailBuf = ctx.tailBuf[:0]
+
+	ctx.err = nil
+}
+
+func getPushCtx() *pushCtx {
+	select {
+	case ctx := <-pushCtxPoolCh:
+		return ctx
+	default:
+		if v := pushCtxPool.Get(); v != nil {
+			return v.(*pushCtx)
+		}
+		return &pushCtx{}
+	}
+}
+
+func putPushCtx(ctx *pushCtx) {
+	ctx.reset()
+	select {
+	case pushCtxPoolCh <- ctx:
+	default:
+		pushCtxPool.Put(ctx)
+	}
+}
+
+var pushCtxPool sync.Pool
+var pushCtxPoolCh = make(chan *pushCtx, runtime.GOMAXPROCS(-1))
@@ -0,0 +1,63 @@
+package prometheusimport
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestGetExtraLabelsSuccess(t *testing.T) {
+	f := func(query string, extraLabelsExpected []extraLabel) {
+		t.Helper()
+		req := &http.Request{
+			URL: &url.URL{
+				RawQuery: query,
+			},
+		}
+		extraLabels, err := getExtraLabels(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(extraLabels, extraLabelsExpected) {
+			t.Fatalf("unexpected extra labels;\ngot\n%+v\nwant\n%+v", extraLabels, extraLabelsExpected)
+		}
+	}
+	f("", nil)
+	f("foo=bar", nil)
+	f("extra_label=job=batch", []extraLabel{{
+		Name:  "job",
+		Value: "batch",
+	}})
+	f("extra_label=job=batch&extra_label=instance=&extra_label=a%3Db=c%3Dd", []extraLabel{
+		{
+			Name:  "job",
+			Value: "batch",
+		},
+		{
+			Name:  "instance",
+			Value: "",
+		},
+		{
+			Name:  "a",
+			Value: "b=c=d",
+		},
+	})
+}
+
+func TestGetExtraLabelsFailure(t *testing.T) {
+	f := func(query string) {
+		t.Helper()
+		req := &http.Request{
+			URL: &url.URL{
+				RawQuery: query,
+			},
+		}
+		if _, err := getExtraLabels(req); err == nil {
+			t.Fatalf("expecting non-nil error for query %q", query)
+		}
+	}
+	f("extra_label=foo")
+	f("extra_label==bar")
+	f("extra_label=")
+}
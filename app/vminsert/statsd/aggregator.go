@@ -0,0 +1,213 @@
+package statsd
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/valyala/histogram"
+)
+
+// timerQuantiles contains quantiles calculated for timers on every flush.
+var timerQuantiles = []float64{0.5, 0.9, 0.99}
+
+// aggregator aggregates statsd rows in memory between flushes.
+type aggregator struct {
+	mu     sync.Mutex
+	m      map[string]*aggrSeries
+	keyBuf []byte
+
+	// gaugeMaxIdleFlushes is the number of flushes without updates
+	// after which a gauge is deleted.
+	gaugeMaxIdleFlushes int
+}
+
+// newAggregator returns new aggregator.
+//
+// Gauges without updates are emitted with the last value during gaugeMaxIdleFlushes flushes.
+func newAggregator(gaugeMaxIdleFlushes int) *aggregator {
+	return &aggregator{
+		m:                   make(map[string]*aggrSeries),
+		gaugeMaxIdleFlushes: gaugeMaxIdleFlushes,
+	}
+}
+
+// aggrSeries contains aggregate state for a single statsd series.
+type aggrSeries struct {
+	metric string
+	tags   []Tag
+	typ    MetricType
+
+	// updated is set if the series has been updated since the last flush.
+	updated bool
+
+	// idleFlushes is the number of flushes without updates in a row.
+	idleFlushes int
+
+	// value contains the sum for counters and the last value for gauges.
+	value float64
+
+	// count, sum and h are used for timers.
+	count float64
+	sum   float64
+	h     *histogram.Fast
+}
+
+// Update updates a with rows.
+//
+// Tags in rows are sorted by keys. rows may be reused after Update returns.
+func (a *aggregator) Update(rows []Row) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := range rows {
+		r := &rows[i]
+		tags := r.Tags
+		sort.Slice(tags, func(i, j int) bool {
+			return tags[i].Key < tags[j].Key
+		})
+		a.keyBuf = marshalSeriesKey(a.keyBuf[:0], r)
+		s := a.m[string(a.keyBuf)]
+		if s == nil {
+			s = newAggrSeries(r)
+			a.m[string(a.keyBuf)] = s
+		}
+		s.update(r)
+	}
+}
+
+func marshalSeriesKey(dst []byte, r *Row) []byte {
+	dst = encoding.MarshalVarUint64(dst, uint64(r.Type))
+	dst = encoding.MarshalBytes(dst, bytesutil.ToUnsafeBytes(r.Metric))
+	for i := range r.Tags {
+		tag := &r.Tags[i]
+		dst = encoding.MarshalBytes(dst, bytesutil.ToUnsafeBytes(tag.Key))
+		dst = encoding.MarshalBytes(dst, bytesutil.ToUnsafeBytes(tag.Value))
+	}
+	return dst
+}
+
+func newAggrSeries(r *Row) *aggrSeries {
+	// Copy metric and tags, since they refer to the buffer, which may be reused after Update.
+	tags := make([]Tag, len(r.Tags))
+	for i := range r.Tags {
+		tags[i].Key = string(append([]byte{}, r.Tags[i].Key...))
+		tags[i].Value = string(append([]byte{}, r.Tags[i].Value...))
+	}
+	s := &aggrSeries{
+		metric: string(append([]byte{}, r.Metric...)),
+		tags:   tags,
+		typ:    r.Type,
+	}
+	if s.typ == MetricTypeTimer {
+		s.h = histogram.GetFast()
+	}
+	return s
+}
+
+func (s *aggrSeries) update(r *Row) {
+	s.updated = true
+	s.idleFlushes = 0
+	switch s.typ {
+	case MetricTypeCounter:
+		s.value += r.Value / r.SampleRate
+	case MetricTypeGauge:
+		if r.IsGaugeDelta {
+			s.value += r.Value
+		} else {
+			s.value = r.Value
+		}
+	case MetricTypeTimer:
+		// Scale both count and sum by the sample rate, so sum/count remains the average value.
+		s.count += 1 / r.SampleRate
+		s.sum += r.Value / r.SampleRate
+		s.h.Update(r.Value)
+	}
+}
+
+// aggrRow is a row produced by aggregator.Flush.
+type aggrRow struct {
+	Metric string
+	Tags   []Tag
+	Value  float64
+}
+
+// Flush appends rows for series updated since the previous Flush call to dst and returns the result.
+//
+// Counters are emitted as the sum of values over the flush interval.
+// Gauges are emitted as the last value. Gauges keep the last value across flushes,
+// so they are emitted even without updates until they stay idle for gaugeMaxIdleFlushes flushes.
+// Timers are emitted as `<metric>_count`, `<metric>_sum` and `<metric>{quantile="..."}` rows.
+//
+// Counters and timers without updates since the previous Flush call are deleted.
+func (a *aggregator) Flush(dst []aggrRow) []aggrRow {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for key, s := range a.m {
+		if !s.updated {
+			if s.typ == MetricTypeGauge && s.idleFlushes < a.gaugeMaxIdleFlushes {
+				s.idleFlushes++
+				dst = s.appendRows(dst)
+				continue
+			}
+			if s.h != nil {
+				histogram.PutFast(s.h)
+			}
+			delete(a.m, key)
+			continue
+		}
+		dst = s.appendRows(dst)
+		s.reset()
+	}
+	return dst
+}
+
+func (s *aggrSeries) appendRows(dst []aggrRow) []aggrRow {
+	switch s.typ {
+	case MetricTypeCounter, MetricTypeGauge:
+		dst = append(dst, aggrRow{
+			Metric: s.metric,
+			Tags:   s.tags,
+			Value:  s.value,
+		})
+	case MetricTypeTimer:
+		dst = append(dst, aggrRow{
+			Metric: s.metric + "_count",
+			Tags:   s.tags,
+			Value:  s.count,
+		}, aggrRow{
+			Metric: s.metric + "_sum",
+			Tags:   s.tags,
+			Value:  s.sum,
+		})
+		for _, phi := range timerQuantiles {
+			tags := make([]Tag, 0, len(s.tags)+1)
+			tags = append(tags, s.tags...)
+			tags = append(tags, Tag{
+				Key:   "quantile",
+				Value: strconv.FormatFloat(phi, 'g', -1, 64),
+			})
+			dst = append(dst, aggrRow{
+				Metric: s.metric,
+				Tags:   tags,
+				Value:  s.h.Quantile(phi),
+			})
+		}
+	}
+	return dst
+}
+
+func (s *aggrSeries) reset() {
+	s.updated = false
+	switch s.typ {
+	case MetricTypeCounter:
+		s.value = 0
+	case MetricTypeTimer:
+		s.count = 0
+		s.sum = 0
+		s.h.Reset()
+	}
+}
@@ -0,0 +1,82 @@
+package statsd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestAggregator(t *testing.T) {
+	a := newAggregator(2)
+	f := func(data string, resultExpected string) {
+		t.Helper()
+		var rows Rows
+		if err := rows.Unmarshal(data); err != nil {
+			t.Fatalf("cannot unmarshal %q: %s", data, err)
+		}
+		a.Update(rows.Rows)
+		aggrRows := a.Flush(nil)
+		var lines []string
+		for _, r := range aggrRows {
+			var tags []string
+			for _, tag := range r.Tags {
+				tags = append(tags, fmt.Sprintf("%s=%q", tag.Key, tag.Value))
+			}
+			lines = append(lines, fmt.Sprintf("%s{%s} %v", r.Metric, strings.Join(tags, ","), r.Value))
+		}
+		sort.Strings(lines)
+		result := strings.Join(lines, "\n")
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	// Nothing to flush
+	f("", "")
+
+	// Counters are summed with sample rates applied. Tags order doesn't matter.
+	f("c:1|c|#b:2,a:1\nc:2|c|@0.5|#a:1,b:2\nc:10|c", `c{a="1",b="2"} 5
+c{} 10`)
+
+	// Counters without updates aren't flushed.
+	f("", "")
+
+	// Gauges store the last value and apply deltas.
+	f("g:10|g\ng:+5|g\ng:-3|g\nh:1|g\nh:7|g", `g{} 12
+h{} 7`)
+	f("g:+1|g", `g{} 13
+h{} 7`)
+
+	// Gauges without updates keep the last value until they stay idle for gaugeMaxIdleFlushes flushes.
+	f("", `g{} 13
+h{} 7`)
+	f("g:-1|g", `g{} 12`)
+	f("", `g{} 12`)
+	f("", `g{} 12`)
+
+	// Idle gauges are deleted, so deltas start from zero.
+	f("", "")
+	f("g:-1|g", `g{} -1`)
+	f("", `g{} -1`)
+	f("", `g{} -1`)
+	f("", "")
+
+	// Timers
+	f("t:1|ms|#x:y\nt:2|ms|#x:y\nt:3|ms|@0.5|#x:y", `t_count{x="y"} 4
+t_sum{x="y"} 9
+t{x="y",quantile="0.5"} 2
+t{x="y",quantile="0.9"} 3
+t{x="y",quantile="0.99"} 3`)
+
+	// Timer state is reset after flush.
+	f("t:5|ms|#x:y", `t_count{x="y"} 1
+t_sum{x="y"} 5
+t{x="y",quantile="0.5"} 5
+t{x="y",quantile="0.9"} 5
+t{x="y",quantile="0.99"} 5`)
+
+	// The same metric name with distinct types results in distinct series.
+	f("m:1|c\nm:2|g", `m{} 1
+m{} 2`)
+}
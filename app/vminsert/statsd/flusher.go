@@ -0,0 +1,75 @@
+package statsd
+
+import (
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/concurrencylimiter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	rowsInserted = metrics.NewCounter(`vm_rows_inserted_total{type="statsd"}`)
+	rowsRejected = common.NewRejectedRowsCounters("statsd")
+	flushErrors  = metrics.NewCounter(`vm_statsd_flush_errors_total`)
+)
+
+func runFlusher(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(*flushInterval)
+	defer ticker.Stop()
+
+	var fc flushCtx
+	for {
+		select {
+		case <-stopCh:
+			fc.flush()
+			return
+		case <-ticker.C:
+			fc.flush()
+		}
+	}
+}
+
+type flushCtx struct {
+	rows   []aggrRow
+	Common common.InsertCtx
+}
+
+func (fc *flushCtx) flush() {
+	fc.rows = aggr.Flush(fc.rows[:0])
+	if len(fc.rows) == 0 {
+		return
+	}
+	timestamp := time.Now().UnixNano() / 1e6
+	err := concurrencylimiter.Do(func() error {
+		return fc.insertRows(timestamp)
+	})
+	if err != nil {
+		flushErrors.Inc()
+		logger.Errorf("cannot flush aggregated StatsD data: %s", err)
+	}
+	for i := range fc.rows {
+		fc.rows[i] = aggrRow{}
+	}
+}
+
+func (fc *flushCtx) insertRows(timestamp int64) error {
+	rows := fc.rows
+	ic := &fc.Common
+	ic.Reset(len(rows), rowsRejected)
+	for i := range rows {
+		r := &rows[i]
+		ic.Labels = ic.Labels[:0]
+		ic.AddLabel("", r.Metric)
+		for j := range r.Tags {
+			tag := &r.Tags[j]
+			ic.AddLabel(tag.Key, tag.Value)
+		}
+		// StatsD protocol has no room for the tenant, so write data to the default tenant.
+		ic.WriteDataPoint(auth.DefaultToken, nil, ic.Labels, timestamp, r.Value)
+	}
+	rowsInserted.Add(len(rows))
+	return ic.FlushBufs()
+}
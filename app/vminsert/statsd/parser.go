@@ -0,0 +1,227 @@
+package statsd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rows contains parsed statsd rows.
+type Rows struct {
+	Rows []Row
+
+	tagsPool []Tag
+}
+
+// Reset resets rs.
+func (rs *Rows) Reset() {
+	// Reset items, so they can be GC'ed
+
+	for i := range rs.Rows {
+		rs.Rows[i].reset()
+	}
+	rs.Rows = rs.Rows[:0]
+
+	for i := range rs.tagsPool {
+		rs.tagsPool[i].reset()
+	}
+	rs.tagsPool = rs.tagsPool[:0]
+}
+
+// Unmarshal unmarshals statsd lines from s.
+//
+// Each line must have the following format:
+//
+//	<metric>:<value>|<type>[|@<sample_rate>][|#<tag1>:<value1>,...,<tagN>:<valueN>]
+//
+// The following types are supported: c (counter), g (gauge), ms, h and d (timer).
+// Gauge value starting with `+` or `-` is a delta for the previous gauge value.
+// Tags are supported in DogStatsD format. DogStatsD events and service checks are skipped.
+//
+// See https://github.com/statsd/statsd/blob/master/docs/metric_types.md
+// and https://docs.datadoghq.com/developers/dogstatsd/datagram_shell/
+//
+// s must be unchanged until rs is in use.
+func (rs *Rows) Unmarshal(s string) error {
+	rs.Reset()
+	for len(s) > 0 {
+		n := strings.IndexByte(s, '\n')
+		line := s
+		if n >= 0 {
+			line = s[:n]
+			s = s[n+1:]
+		} else {
+			s = ""
+		}
+		line = strings.TrimSuffix(line, "\r")
+		if len(line) == 0 {
+			// Skip empty lines.
+			continue
+		}
+		if strings.HasPrefix(line, "_e{") || strings.HasPrefix(line, "_sc|") {
+			// Skip DogStatsD events and service checks.
+			continue
+		}
+		if err := rs.unmarshalLine(line); err != nil {
+			return fmt.Errorf("cannot unmarshal statsd line %q: %s", line, err)
+		}
+	}
+	return nil
+}
+
+func (rs *Rows) unmarshalLine(line string) error {
+	n := strings.IndexByte(line, '|')
+	if n < 0 {
+		return fmt.Errorf("cannot find metric type delimiter `|`")
+	}
+	metricAndValue := line[:n]
+	tail := line[n+1:]
+	n = strings.IndexByte(metricAndValue, ':')
+	if n < 0 {
+		return fmt.Errorf("cannot find `:` between metric and value")
+	}
+	metric := metricAndValue[:n]
+	if len(metric) == 0 {
+		return fmt.Errorf("metric cannot be empty")
+	}
+	valueStr := metricAndValue[n+1:]
+
+	typeStr := tail
+	n = strings.IndexByte(tail, '|')
+	if n >= 0 {
+		typeStr = tail[:n]
+		tail = tail[n+1:]
+	} else {
+		tail = ""
+	}
+	var typ MetricType
+	switch typeStr {
+	case "c":
+		typ = MetricTypeCounter
+	case "g":
+		typ = MetricTypeGauge
+	case "ms", "h", "d":
+		typ = MetricTypeTimer
+	default:
+		return fmt.Errorf("unsupported metric type %q; supported types: c, g, ms, h, d", typeStr)
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return fmt.Errorf("cannot parse value: %s", err)
+	}
+
+	tagsStart := len(rs.tagsPool)
+	sampleRate := 1.0
+	for len(tail) > 0 {
+		section := tail
+		n := strings.IndexByte(tail, '|')
+		if n >= 0 {
+			section = tail[:n]
+			tail = tail[n+1:]
+		} else {
+			tail = ""
+		}
+		switch {
+		case strings.HasPrefix(section, "@"):
+			sampleRate, err = strconv.ParseFloat(section[1:], 64)
+			if err != nil {
+				return fmt.Errorf("cannot parse sample rate: %s", err)
+			}
+			if sampleRate <= 0 || sampleRate > 1 {
+				return fmt.Errorf("sample rate must be in the range (0..1]; got %v", sampleRate)
+			}
+		case strings.HasPrefix(section, "#"):
+			rs.unmarshalTags(section[1:])
+		default:
+			// Skip unknown sections such as DogStatsD container id or timestamp.
+		}
+	}
+	tags := rs.tagsPool[tagsStart:]
+	if len(tags) == 0 {
+		tags = nil
+	}
+
+	if cap(rs.Rows) > len(rs.Rows) {
+		rs.Rows = rs.Rows[:len(rs.Rows)+1]
+	} else {
+		rs.Rows = append(rs.Rows, Row{})
+	}
+	r := &rs.Rows[len(rs.Rows)-1]
+	r.Metric = metric
+	r.Tags = tags[:len(tags):len(tags)]
+	r.Type = typ
+	r.Value = value
+	r.SampleRate = sampleRate
+	r.IsGaugeDelta = typ == MetricTypeGauge && (valueStr[0] == '+' || valueStr[0] == '-')
+	return nil
+}
+
+func (rs *Rows) unmarshalTags(s string) {
+	for len(s) > 0 {
+		tagStr := s
+		n := strings.IndexByte(s, ',')
+		if n >= 0 {
+			tagStr = s[:n]
+			s = s[n+1:]
+		} else {
+			s = ""
+		}
+		n = strings.IndexByte(tagStr, ':')
+		if n <= 0 || n == len(tagStr)-1 {
+			// Skip tags without names or values, since they cannot be stored as labels.
+			continue
+		}
+		if cap(rs.tagsPool) > len(rs.tagsPool) {
+			rs.tagsPool = rs.tagsPool[:len(rs.tagsPool)+1]
+		} else {
+			rs.tagsPool = append(rs.tagsPool, Tag{})
+		}
+		tag := &rs.tagsPool[len(rs.tagsPool)-1]
+		tag.Key = tagStr[:n]
+		tag.Value = tagStr[n+1:]
+	}
+}
+
+// MetricType is statsd metric type.
+type MetricType int
+
+// Supported statsd metric types.
+const (
+	MetricTypeCounter = MetricType(iota)
+	MetricTypeGauge
+	MetricTypeTimer
+)
+
+// Row is a single statsd row.
+type Row struct {
+	Metric string
+	Tags   []Tag
+	Type   MetricType
+	Value  float64
+
+	// SampleRate is the sample rate for counters and timers. It is in the range (0..1].
+	SampleRate float64
+
+	// IsGaugeDelta is set if Value must be added to the previous gauge value.
+	IsGaugeDelta bool
+}
+
+func (r *Row) reset() {
+	r.Metric = ""
+	r.Tags = nil
+	r.Type = 0
+	r.Value = 0
+	r.SampleRate = 0
+	r.IsGaugeDelta = false
+}
+
+// Tag is a statsd tag.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+func (t *Tag) reset() {
+	t.Key = ""
+	t.Value = ""
+}
@@ -0,0 +1,157 @@
+package statsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRowsUnmarshalFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		var rows Rows
+		if err := rows.Unmarshal(s); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", s)
+		}
+
+		// Try again
+		if err := rows.Unmarshal(s); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", s)
+		}
+	}
+
+	// Missing type
+	f("foo:1")
+
+	// Missing value
+	f("foo|c")
+
+	// Empty metric
+	f(":1|c")
+
+	// Invalid value
+	f("foo:bar|c")
+	f("foo:|c")
+
+	// Unsupported type
+	f("foo:1|s")
+	f("foo:1|")
+	f("foo:1|cc")
+
+	// Invalid sample rate
+	f("foo:1|c|@")
+	f("foo:1|c|@bar")
+	f("foo:1|c|@0")
+	f("foo:1|c|@1.5")
+
+	// Invalid line after valid line
+	f("foo:1|c\nbar")
+}
+
+func TestRowsUnmarshalSuccess(t *testing.T) {
+	f := func(s string, rowsExpected *Rows) {
+		t.Helper()
+		var rows Rows
+		if err := rows.Unmarshal(s); err != nil {
+			t.Fatalf("cannot unmarshal %q: %s", s, err)
+		}
+		if !reflect.DeepEqual(rows.Rows, rowsExpected.Rows) {
+			t.Fatalf("unexpected rows;\ngot\n%+v;\nwant\n%+v", rows.Rows, rowsExpected.Rows)
+		}
+
+		// Try unmarshaling again
+		if err := rows.Unmarshal(s); err != nil {
+			t.Fatalf("cannot unmarshal %q: %s", s, err)
+		}
+		if !reflect.DeepEqual(rows.Rows, rowsExpected.Rows) {
+			t.Fatalf("unexpected rows;\ngot\n%+v;\nwant\n%+v", rows.Rows, rowsExpected.Rows)
+		}
+
+		rows.Reset()
+		if len(rows.Rows) != 0 {
+			t.Fatalf("non-empty rows after reset: %+v", rows.Rows)
+		}
+	}
+
+	// Empty line
+	f("", &Rows{})
+	f("\n\r\n", &Rows{})
+
+	// DogStatsD events and service checks are skipped
+	f("_e{5,4}:title|text\n_sc|redis.can_connect|0", &Rows{})
+
+	// Counter
+	f("foo.bar:123|c", &Rows{
+		Rows: []Row{{
+			Metric:     "foo.bar",
+			Type:       MetricTypeCounter,
+			Value:      123,
+			SampleRate: 1,
+		}},
+	})
+
+	// Counter with sample rate and tags
+	f("foo:2|c|@0.1|#env:prod,host:h1,novalue,:noname", &Rows{
+		Rows: []Row{{
+			Metric: "foo",
+			Tags: []Tag{
+				{Key: "env", Value: "prod"},
+				{Key: "host", Value: "h1"},
+			},
+			Type:       MetricTypeCounter,
+			Value:      2,
+			SampleRate: 0.1,
+		}},
+	})
+
+	// Gauges
+	f("g1:-1.5|g\ng2:+3|g\r\ng3:42|g|#a:b", &Rows{
+		Rows: []Row{
+			{
+				Metric:       "g1",
+				Type:         MetricTypeGauge,
+				Value:        -1.5,
+				SampleRate:   1,
+				IsGaugeDelta: true,
+			},
+			{
+				Metric:       "g2",
+				Type:         MetricTypeGauge,
+				Value:        3,
+				SampleRate:   1,
+				IsGaugeDelta: true,
+			},
+			{
+				Metric:     "g3",
+				Tags:       []Tag{{Key: "a", Value: "b"}},
+				Type:       MetricTypeGauge,
+				Value:      42,
+				SampleRate: 1,
+			},
+		},
+	})
+
+	// Timers with unknown sections
+	f("t1:320|ms|#x:y|c:container-id|T1656581400\nt2:1|h\nt3:2.5|d|@0.5", &Rows{
+		Rows: []Row{
+			{
+				Metric:     "t1",
+				Tags:       []Tag{{Key: "x", Value: "y"}},
+				Type:       MetricTypeTimer,
+				Value:      320,
+				SampleRate: 1,
+			},
+			{
+				Metric:     "t2",
+				Type:       MetricTypeTimer,
+				Value:      1,
+				SampleRate: 1,
+			},
+			{
+				Metric:     "t3",
+				Type:       MetricTypeTimer,
+				Value:      2.5,
+				SampleRate: 0.5,
+			},
+		},
+	})
+}
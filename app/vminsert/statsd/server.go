@@ -0,0 +1,122 @@
+package statsd
+
+import (
+	"flag"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	flushInterval    = flag.Duration("statsdFlushInterval", 10*time.Second, "Interval for flushing StatsD data aggregated in memory to the storage")
+	gaugeIdleTimeout = flag.Duration("statsdGaugeIdleTimeout", 5*time.Minute, "Gauges without updates are stored with the last value until they stay idle for this duration")
+)
+
+var (
+	writeRequestsUDP = metrics.NewCounter(`vm_statsd_requests_total{name="write", net="udp"}`)
+	writeErrorsUDP   = metrics.NewCounter(`vm_statsd_request_errors_total{name="write", net="udp"}`)
+
+	rowsReceived    = metrics.NewCounter(`vm_statsd_rows_received_total`)
+	unmarshalErrors = metrics.NewCounter(`vm_unmarshal_errors_total{name="statsd"}`)
+)
+
+// Serve starts StatsD server on the given addr.
+//
+// The server accepts StatsD data over UDP, aggregates it in memory
+// and flushes the aggregated data to the storage every -statsdFlushInterval.
+func Serve(addr string) {
+	logger.Infof("starting UDP StatsD server at %q", addr)
+	lnUDP, err := net.ListenPacket("udp4", addr)
+	if err != nil {
+		logger.Fatalf("cannot start UDP StatsD server at %q: %s", addr, err)
+	}
+	listenerUDP = lnUDP
+	aggr = newAggregator(getGaugeMaxIdleFlushes())
+
+	flusherWG.Add(1)
+	go func() {
+		defer flusherWG.Done()
+		runFlusher(flusherStopCh)
+	}()
+
+	serveUDP(listenerUDP)
+	logger.Infof("stopped UDP StatsD server at %q", addr)
+}
+
+func serveUDP(ln net.PacketConn) {
+	gomaxprocs := runtime.GOMAXPROCS(-1)
+	var wg sync.WaitGroup
+	for i := 0; i < gomaxprocs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var bb bytesutil.ByteBuffer
+			bb.B = bytesutil.Resize(bb.B, 64*1024)
+			var rows Rows
+			for {
+				bb.Reset()
+				bb.B = bb.B[:cap(bb.B)]
+				n, addr, err := ln.ReadFrom(bb.B)
+				if err != nil {
+					writeErrorsUDP.Inc()
+					if ne, ok := err.(net.Error); ok {
+						if ne.Temporary() {
+							time.Sleep(time.Second)
+							continue
+						}
+						if strings.Contains(err.Error(), "use of closed network connection") {
+							break
+						}
+					}
+					logger.Errorf("cannot read StatsD UDP data: %s", err)
+					continue
+				}
+				bb.B = bb.B[:n]
+				writeRequestsUDP.Inc()
+				if err := rows.Unmarshal(bytesutil.ToUnsafeString(bb.B)); err != nil {
+					writeErrorsUDP.Inc()
+					unmarshalErrors.Inc()
+					logger.Errorf("error in UDP StatsD conn %q<->%q: %s", ln.LocalAddr(), addr, err)
+					continue
+				}
+				aggr.Update(rows.Rows)
+				rowsReceived.Add(len(rows.Rows))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+var (
+	listenerUDP net.PacketConn
+
+	aggr *aggregator
+
+	flusherStopCh = make(chan struct{})
+	flusherWG     sync.WaitGroup
+)
+
+func getGaugeMaxIdleFlushes() int {
+	if *flushInterval <= 0 {
+		logger.Fatalf("-statsdFlushInterval must be positive; got %s", *flushInterval)
+	}
+	return int(*gaugeIdleTimeout / *flushInterval)
+}
+
+// Stop stops the server.
+//
+// The data aggregated since the last flush is flushed to the storage.
+func Stop() {
+	logger.Infof("stopping UDP StatsD server at %q...", listenerUDP.LocalAddr())
+	if err := listenerUDP.Close(); err != nil {
+		logger.Errorf("cannot close UDP StatsD server: %s", err)
+	}
+	close(flusherStopCh)
+	flusherWG.Wait()
+}
@@ -0,0 +1,166 @@
+package vmimport
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRowsUnmarshalFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		var rows Rows
+		if err := rows.Unmarshal(s); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", s)
+		}
+
+		// Try again
+		if err := rows.Unmarshal(s); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", s)
+		}
+	}
+
+	// Invalid json line
+	f("foo\nbar")
+	f(`{"metric":{"__name__":"foo"},"values":[1,2],"timestamps":[3,4]`)
+
+	// Missing metric
+	f(`{"values":[1,2],"timestamps":[3,4]}`)
+
+	// Missing tags
+	f(`{"metric":{},"values":[1,2],"timestamps":[3,4]}`)
+
+	// Invalid tag value
+	f(`{"metric":{"__name__":123},"values":[1,2],"timestamps":[3,4]}`)
+
+	// Missing values
+	f(`{"metric":{"__name__":"xxx"},"timestamps":[3,4]}`)
+
+	// Invalid values
+	f(`{"metric":{"__name__":"xxx"},"values":["foo"],"timestamps":[3]}`)
+
+	// Missing timestamps
+	f(`{"metric":{"__name__":"xxx"},"values":[1,2]}`)
+
+	// Invalid timestamps
+	f(`{"metric":{"__name__":"xxx"},"values":[1],"timestamps":[1.5]}`)
+
+	// values and timestamps count mismatch
+	f(`{"metric":{"__name__":"xxx"},"values":[],"timestamps":[]}`)
+	f(`{"metric":{"__name__":"xxx"},"values":[1],"timestamps":[3,4]}`)
+	f(`{"metric":{"__name__":"xxx"},"values":[1,2],"timestamps":[3]}`)
+
+	// Garbage after the line
+	f(`{"metric":{"__name__":"xxx"},"values":[1],"timestamps":[3]}{}`)
+}
+
+func TestRowsUnmarshalSuccess(t *testing.T) {
+	f := func(s string, rowsExpected *Rows) {
+		t.Helper()
+		var rows Rows
+		if err := rows.Unmarshal(s); err != nil {
+			t.Fatalf("cannot unmarshal %q: %s", s, err)
+		}
+		if !reflect.DeepEqual(rows.Rows, rowsExpected.Rows) {
+			t.Fatalf("unexpected rows;\ngot\n%+v;\nwant\n%+v", rows.Rows, rowsExpected.Rows)
+		}
+
+		// Try unmarshaling again
+		if err := rows.Unmarshal(s); err != nil {
+			t.Fatalf("cannot unmarshal %q: %s", s, err)
+		}
+		if !reflect.DeepEqual(rows.Rows, rowsExpected.Rows) {
+			t.Fatalf("unexpected rows;\ngot\n%+v;\nwant\n%+v", rows.Rows, rowsExpected.Rows)
+		}
+
+		rows.Reset()
+		if len(rows.Rows) != 0 {
+			t.Fatalf("non-empty rows after reset: %+v", rows.Rows)
+		}
+	}
+
+	// Empty line
+	f("", &Rows{})
+	f("\n\n", &Rows{})
+	f("\n\r\n", &Rows{})
+
+	// Single line with a single tag
+	f(`{"metric":{"__name__":"foo"},"values":[1.23],"timestamps":[456]}`, &Rows{
+		Rows: []Row{{
+			Tags: []Tag{{
+				Key:   []byte("__name__"),
+				Value: []byte("foo"),
+			}},
+			Values:     []float64{1.23},
+			Timestamps: []int64{456},
+		}},
+	})
+
+	// Trailing CRLF
+	f("{\"metric\":{\"__name__\":\"foo\"},\"values\":[1.23],\"timestamps\":[456]}\r\n", &Rows{
+		Rows: []Row{{
+			Tags: []Tag{{
+				Key:   []byte("__name__"),
+				Value: []byte("foo"),
+			}},
+			Values:     []float64{1.23},
+			Timestamps: []int64{456},
+		}},
+	})
+
+	// Multiple tags
+	f(`{"metric":{"__name__":"foo","tag1":"v1","tag2":"v2"},"values":[1.23],"timestamps":[456]}`, &Rows{
+		Rows: []Row{{
+			Tags: []Tag{
+				{
+					Key:   []byte("__name__"),
+					Value: []byte("foo"),
+				},
+				{
+					Key:   []byte("tag1"),
+					Value: []byte("v1"),
+				},
+				{
+					Key:   []byte("tag2"),
+					Value: []byte("v2"),
+				},
+			},
+			Values:     []float64{1.23},
+			Timestamps: []int64{456},
+		}},
+	})
+
+	// Multiple lines
+	f(`{"metric":{"__name__":"foo","tag1":"v1"},"values":[1.23, -2.3, 42],"timestamps":[456,789,123]}
+{"metric":{"__name__":"bar","x":"y"},"values":[0],"timestamps":[-1]}`, &Rows{
+		Rows: []Row{
+			{
+				Tags: []Tag{
+					{
+						Key:   []byte("__name__"),
+						Value: []byte("foo"),
+					},
+					{
+						Key:   []byte("tag1"),
+						Value: []byte("v1"),
+					},
+				},
+				Values:     []float64{1.23, -2.3, 42},
+				Timestamps: []int64{456, 789, 123},
+			},
+			{
+				Tags: []Tag{
+					{
+						Key:   []byte("__name__"),
+						Value: []byte("bar"),
+					},
+					{
+						Key:   []byte("x"),
+						Value: []byte("y"),
+					},
+				},
+				Values:     []float64{0},
+				Timestamps: []int64{-1},
+			},
+		},
+	})
+}
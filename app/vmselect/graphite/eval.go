@@ -0,0 +1,180 @@
+package graphite
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+// evalConfig is the configuration for render target evaluation.
+type evalConfig struct {
+	at *auth.Token
+
+	// startTime and endTime are in milliseconds.
+	startTime int64
+	endTime   int64
+
+	// storageStep is the interval between points in the fetched series in milliseconds.
+	storageStep int64
+
+	deadline netstorage.Deadline
+}
+
+// series is a single Graphite series.
+type series struct {
+	Name string
+	Tags map[string]string
+
+	// pathExpression is used for naming series returned from aggregate functions.
+	pathExpression string
+
+	Timestamps []int64
+	Values     []float64
+}
+
+// newSeries returns series with the given name, timestamps and values.
+func newSeries(name string, timestamps []int64, values []float64) *series {
+	return &series{
+		Name: name,
+		Tags: map[string]string{
+			"name": name,
+		},
+		pathExpression: name,
+		Timestamps:     timestamps,
+		Values:         values,
+	}
+}
+
+// evalExpr evaluates e with the given ec.
+func evalExpr(ec *evalConfig, e expr) ([]*series, error) {
+	switch t := e.(type) {
+	case *pathExpr:
+		return fetchSeries(ec, t.Query)
+	case *funcExpr:
+		tf := getTransformFunc(t.Name)
+		if tf == nil {
+			return nil, fmt.Errorf("unsupported function %q", t.Name)
+		}
+		ss, err := tf(ec, t.Args)
+		if err != nil {
+			return nil, fmt.Errorf("cannot evaluate %s: %s", t, err)
+		}
+		return ss, nil
+	default:
+		return nil, fmt.Errorf("unexpected expression %s; expecting path or function call", e)
+	}
+}
+
+// fetchSeries returns series matching the given path query on the time range from ec.
+//
+// The returned series are aligned to ec.storageStep grid. The value at every
+// point is the last raw sample on the interval [timestamp ... timestamp+storageStep).
+func fetchSeries(ec *evalConfig, query string) ([]*series, error) {
+	tf, err := getPathTagFilter(query, false)
+	if err != nil {
+		return nil, err
+	}
+	timestamps := getTimestamps(ec)
+	if len(timestamps) == 0 {
+		return nil, nil
+	}
+	sq := &storage.SearchQuery{
+		AccountID:    ec.at.AccountID,
+		ProjectID:    ec.at.ProjectID,
+		MinTimestamp: timestamps[0],
+		MaxTimestamp: timestamps[len(timestamps)-1] + ec.storageStep - 1,
+		TagFilterss:  [][]storage.TagFilter{{*tf}},
+	}
+	rss, err := netstorage.ProcessSearchQuery(sq, ec.deadline)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch data for %q: %s", query, err)
+	}
+	var ss []*series
+	var ssLock sync.Mutex
+	err = rss.RunParallel(func(rs *netstorage.Result) {
+		name := getSeriesName(&rs.MetricName)
+		s := &series{
+			Name:           name,
+			Tags:           getSeriesTags(&rs.MetricName),
+			pathExpression: query,
+			Timestamps:     timestamps,
+			Values:         alignValues(rs.Timestamps, rs.Values, timestamps, ec.storageStep),
+		}
+		ssLock.Lock()
+		ss = append(ss, s)
+		ssLock.Unlock()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error when fetching data for %q: %s", query, err)
+	}
+	sort.Slice(ss, func(i, j int) bool {
+		return ss[i].Name < ss[j].Name
+	})
+	return ss, nil
+}
+
+// getTimestamps returns timestamps for ec.storageStep grid on the time range from ec.
+func getTimestamps(ec *evalConfig) []int64 {
+	step := ec.storageStep
+	start := ec.startTime - ec.startTime%step
+	if start < ec.startTime {
+		start += step
+	}
+	var timestamps []int64
+	for ts := start; ts <= ec.endTime; ts += step {
+		timestamps = append(timestamps, ts)
+	}
+	return timestamps
+}
+
+// alignValues returns values for the given timestamps grid from raw samples.
+//
+// Points without samples are set to NaN.
+func alignValues(srcTimestamps []int64, srcValues []float64, timestamps []int64, step int64) []float64 {
+	values := make([]float64, len(timestamps))
+	j := 0
+	for i, ts := range timestamps {
+		v := nan
+		for j < len(srcTimestamps) && srcTimestamps[j] < ts+step {
+			if srcTimestamps[j] >= ts {
+				v = srcValues[j]
+			}
+			j++
+		}
+		values[i] = v
+	}
+	return values
+}
+
+var nan = math.NaN()
+
+// getSeriesName returns Graphite name for mn.
+//
+// Tags are appended to the name in the `name;tag1=value1;tag2=value2` form.
+func getSeriesName(mn *storage.MetricName) string {
+	var b strings.Builder
+	b.Write(mn.MetricGroup)
+	for _, tag := range mn.Tags {
+		b.WriteByte(';')
+		b.Write(tag.Key)
+		b.WriteByte('=')
+		b.Write(tag.Value)
+	}
+	return b.String()
+}
+
+// getSeriesTags returns Graphite tags for mn.
+func getSeriesTags(mn *storage.MetricName) map[string]string {
+	tags := make(map[string]string, len(mn.Tags)+1)
+	tags["name"] = string(mn.MetricGroup)
+	for _, tag := range mn.Tags {
+		tags[string(tag.Key)] = string(tag.Value)
+	}
+	return tags
+}
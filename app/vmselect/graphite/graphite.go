@@ -0,0 +1,315 @@
+package graphite
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var storageStep = flag.Duration("search.graphiteStorageStep", 10*time.Second, "The interval between datapoints returned from Graphite /render API. "+
+	"It should match the interval between samples ingested via Graphite protocol")
+
+// pathEntry is a single entry returned from /metrics/find and /metrics/expand.
+type pathEntry struct {
+	Path   string
+	IsLeaf bool
+}
+
+// FindHandler processes /metrics/find request.
+//
+// See https://graphite-api.readthedocs.io/en/latest/api.html#metrics-find
+func FindHandler(at *auth.Token, w http.ResponseWriter, r *http.Request) error {
+	startTime := time.Now()
+	deadline := searchutils.GetDeadline(r)
+	query := r.FormValue("query")
+	if len(query) == 0 {
+		return fmt.Errorf("missing `query` arg")
+	}
+	format := r.FormValue("format")
+	if format == "completer" && !strings.HasSuffix(query, "*") {
+		// Graphite performs prefix match for completer requests.
+		query += "*"
+	}
+	if format != "" && format != "treejson" && format != "completer" {
+		return fmt.Errorf("unsupported `format`=%q; supported values: treejson, completer", format)
+	}
+	entries, err := findPaths(at, query, deadline)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if format == "completer" {
+		WriteFindCompleterResponse(w, entries)
+	} else {
+		WriteFindTreeJSONResponse(w, entries)
+	}
+	findDuration.UpdateDuration(startTime)
+	return nil
+}
+
+var findDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/metrics/find"}`)
+
+// ExpandHandler processes /metrics/expand request.
+//
+// See https://graphite-api.readthedocs.io/en/latest/api.html#metrics-expand
+func ExpandHandler(at *auth.Token, w http.ResponseWriter, r *http.Request) error {
+	startTime := time.Now()
+	deadline := searchutils.GetDeadline(r)
+	if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("cannot parse form values: %s", err)
+	}
+	queries := r.Form["query"]
+	if len(queries) == 0 {
+		return fmt.Errorf("missing `query` arg")
+	}
+	leavesOnly := getBool(r, "leavesOnly")
+	m := make(map[string]struct{})
+	for _, query := range queries {
+		entries, err := findPaths(at, query, deadline)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if leavesOnly && !e.IsLeaf {
+				continue
+			}
+			m[e.Path] = struct{}{}
+		}
+	}
+	paths := make([]string, 0, len(m))
+	for path := range m {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	w.Header().Set("Content-Type", "application/json")
+	WriteExpandResponse(w, paths)
+	expandDuration.UpdateDuration(startTime)
+	return nil
+}
+
+var expandDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/metrics/expand"}`)
+
+// RenderHandler processes /render request.
+//
+// Only `format=json` is supported.
+//
+// See https://graphite.readthedocs.io/en/latest/render_api.html
+func RenderHandler(at *auth.Token, w http.ResponseWriter, r *http.Request) error {
+	startTime := time.Now()
+	ct := currentTime()
+	deadline := searchutils.GetDeadline(r)
+	if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("cannot parse form values: %s", err)
+	}
+	targets := r.Form["target"]
+	if len(targets) == 0 {
+		return fmt.Errorf("missing `target` arg")
+	}
+	if format := r.FormValue("format"); format != "" && format != "json" {
+		return fmt.Errorf("unsupported `format`=%q; only `json` is supported", format)
+	}
+	start, err := getTime(r, "from", ct, ct-24*3600*1000)
+	if err != nil {
+		return err
+	}
+	end, err := getTime(r, "until", ct, ct)
+	if err != nil {
+		return err
+	}
+	if start >= end {
+		return fmt.Errorf("`from`=%d must be smaller than `until`=%d", start/1e3, end/1e3)
+	}
+	maxDataPoints := 0
+	if s := r.FormValue("maxDataPoints"); len(s) > 0 {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("cannot parse `maxDataPoints`=%q; it must be positive integer", s)
+		}
+		maxDataPoints = n
+	}
+	step := int64(*storageStep / time.Millisecond)
+	if step <= 0 {
+		return fmt.Errorf("-search.graphiteStorageStep must be positive; got %s", *storageStep)
+	}
+	ec := &evalConfig{
+		at:          at,
+		startTime:   start,
+		endTime:     end,
+		storageStep: step,
+		deadline:    deadline,
+	}
+	var ss []*series
+	for _, target := range targets {
+		e, err := parseTarget(target)
+		if err != nil {
+			return fmt.Errorf("cannot parse `target`=%q: %s", target, err)
+		}
+		ssTarget, err := evalExpr(ec, e)
+		if err != nil {
+			return fmt.Errorf("cannot evaluate `target`=%q: %s", target, err)
+		}
+		ss = append(ss, ssTarget...)
+	}
+	if maxDataPoints > 0 {
+		for _, s := range ss {
+			consolidateSeries(s, maxDataPoints)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	WriteRenderJSONResponse(w, ss)
+	renderDuration.UpdateDuration(startTime)
+	return nil
+}
+
+var renderDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/render"}`)
+
+// consolidateSeries averages adjacent points in s, so it contains no more than maxDataPoints points.
+func consolidateSeries(s *series, maxDataPoints int) {
+	if len(s.Values) <= maxDataPoints {
+		return
+	}
+	pointsPerBucket := (len(s.Values) + maxDataPoints - 1) / maxDataPoints
+	var timestamps []int64
+	var values []float64
+	for i := 0; i < len(s.Values); i += pointsPerBucket {
+		n := i + pointsPerBucket
+		if n > len(s.Values) {
+			n = len(s.Values)
+		}
+		timestamps = append(timestamps, s.Timestamps[i])
+		values = append(values, aggrAvg(s.Values[i:n]))
+	}
+	s.Timestamps = timestamps
+	s.Values = values
+}
+
+// findPaths returns path entries matching the given Graphite query.
+//
+// Both leaves and branches are returned. A path may be returned twice
+// if it is a leaf and a branch at the same time.
+func findPaths(at *auth.Token, query string, deadline netstorage.Deadline) ([]pathEntry, error) {
+	getSuffixes := func(prefix string) ([]string, error) {
+		return netstorage.GetTagValueSuffixes(at, "__name__", prefix, '.', deadline)
+	}
+	m := make(map[pathEntry]struct{})
+	for _, q := range expandDottedAlternations(query) {
+		nodes := getNodes(q)
+		if err := findPathsForNodes(m, getSuffixes, "", nodes); err != nil {
+			return nil, fmt.Errorf("cannot find paths for `query`=%q: %s", query, err)
+		}
+	}
+	entries := make([]pathEntry, 0, len(m))
+	for e := range m {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := &entries[i], &entries[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return !a.IsLeaf && b.IsLeaf
+	})
+	return entries, nil
+}
+
+// findPathsForNodes adds paths starting with prefix and matching nodes to m.
+//
+// Nodes are matched one by one against the next path nodes returned by getSuffixes,
+// so only the paths matching the previous nodes are scanned.
+func findPathsForNodes(m map[pathEntry]struct{}, getSuffixes func(prefix string) ([]string, error), prefix string, nodes []string) error {
+	node := nodes[0]
+	var nodeRe *regexp.Regexp
+	nodePrefix := node
+	if isGlob(node) {
+		re, err := globToRegexp(node)
+		if err != nil {
+			return err
+		}
+		nodeRe = regexp.MustCompile("^(?:" + re + ")$")
+		nodePrefix = node[:strings.IndexAny(node, "*?[{")]
+	}
+	suffixes, err := getSuffixes(prefix + nodePrefix)
+	if err != nil {
+		return err
+	}
+	for _, suffix := range suffixes {
+		isLeaf := !strings.HasSuffix(suffix, ".")
+		name := nodePrefix + strings.TrimSuffix(suffix, ".")
+		if nodeRe == nil {
+			if name != node {
+				continue
+			}
+		} else if !nodeRe.MatchString(name) {
+			continue
+		}
+		path := prefix + name
+		if len(nodes) == 1 {
+			m[pathEntry{Path: path, IsLeaf: isLeaf}] = struct{}{}
+			continue
+		}
+		if isLeaf {
+			continue
+		}
+		if err := findPathsForNodes(m, getSuffixes, path+".", nodes[1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getTime(r *http.Request, argKey string, currentTime, defaultValue int64) (int64, error) {
+	argValue := r.FormValue(argKey)
+	if len(argValue) == 0 {
+		return defaultValue, nil
+	}
+	t, err := parseTime(argValue, currentTime)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %q=%q: %s", argKey, argValue, err)
+	}
+	return t, nil
+}
+
+func getBool(r *http.Request, argKey string) bool {
+	argValue := r.FormValue(argKey)
+	switch strings.ToLower(argValue) {
+	case "", "0", "f", "false", "no":
+		return false
+	default:
+		return true
+	}
+}
+
+func currentTime() int64 {
+	return int64(time.Now().UTC().Unix()) * 1e3
+}
+
+// getLastNode returns the last node of the given path.
+func getLastNode(path string) string {
+	if n := strings.LastIndexByte(path, '.'); n >= 0 {
+		return path[n+1:]
+	}
+	return path
+}
+
+// getSortedTagKeys returns sorted keys for the given tags.
+func getSortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,75 @@
+package graphite
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestFindPathsForNodes(t *testing.T) {
+	names := []string{
+		"foo.bar.baz",
+		"foo.bar.qux",
+		"foo.bax",
+		"foo.bax.x",
+		"foo.x-y.z",
+		"abc.def",
+		"abcd",
+	}
+	var prefixes []string
+	getSuffixes := func(prefix string) ([]string, error) {
+		prefixes = append(prefixes, prefix)
+		m := make(map[string]struct{})
+		for _, name := range names {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			suffix := name[len(prefix):]
+			if n := strings.IndexByte(suffix, '.'); n >= 0 {
+				suffix = suffix[:n+1]
+			}
+			m[suffix] = struct{}{}
+		}
+		var suffixes []string
+		for suffix := range m {
+			suffixes = append(suffixes, suffix)
+		}
+		sort.Strings(suffixes)
+		return suffixes, nil
+	}
+	f := func(query string, pathsExpected []string, prefixesExpected []string) {
+		t.Helper()
+		prefixes = nil
+		m := make(map[pathEntry]struct{})
+		for _, q := range expandDottedAlternations(query) {
+			if err := findPathsForNodes(m, getSuffixes, "", getNodes(q)); err != nil {
+				t.Fatalf("unexpected error for %q: %s", query, err)
+			}
+		}
+		var paths []string
+		for e := range m {
+			paths = append(paths, fmt.Sprintf("%s %v", e.Path, e.IsLeaf))
+		}
+		sort.Strings(paths)
+		if !reflect.DeepEqual(paths, pathsExpected) {
+			t.Fatalf("unexpected paths for %q;\ngot\n%q\nwant\n%q", query, paths, pathsExpected)
+		}
+		if !reflect.DeepEqual(prefixes, prefixesExpected) {
+			t.Fatalf("unexpected prefixes for %q;\ngot\n%q\nwant\n%q", query, prefixes, prefixesExpected)
+		}
+	}
+
+	// Children of the matching nodes aren't scanned.
+	f("*", []string{"abc false", "abcd true", "foo false"}, []string{""})
+	f("abc*", []string{"abc false", "abcd true"}, []string{"abc"})
+	f("foo.*", []string{"foo.bar false", "foo.bax false", "foo.bax true", "foo.x-y false"}, []string{"foo", "foo."})
+	f("foo.ba?.*", []string{"foo.bar.baz true", "foo.bar.qux true", "foo.bax.x true"}, []string{"foo", "foo.ba", "foo.bar.", "foo.bax."})
+	f("foo.bar", []string{"foo.bar false"}, []string{"foo", "foo.bar"})
+	f("foo.{bar,x-y}.*", []string{"foo.bar.baz true", "foo.bar.qux true", "foo.x-y.z true"}, []string{"foo", "foo.", "foo.bar.", "foo.x-y."})
+	f("{foo.bar,abc}.*", []string{"abc.def true", "foo.bar.baz true", "foo.bar.qux true"}, []string{"foo", "foo.bar", "foo.bar.", "abc", "abc."})
+	f("foo.[b.]ar", []string{"foo.bar false"}, []string{"foo", "foo."})
+	f("foo.ba[r.]baz", nil, []string{"foo", "foo.ba"})
+	f("missing.*", nil, []string{"missing"})
+}
@@ -0,0 +1,227 @@
+package graphite
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// expr is Graphite render target expression.
+type expr interface {
+	// String returns string representation of expr.
+	String() string
+}
+
+// funcExpr is a function call such as `sumSeries(foo.*)`.
+type funcExpr struct {
+	Name string
+	Args []expr
+}
+
+// String implements expr interface.
+func (fe *funcExpr) String() string {
+	args := make([]string, len(fe.Args))
+	for i, arg := range fe.Args {
+		args[i] = arg.String()
+	}
+	return fe.Name + "(" + strings.Join(args, ",") + ")"
+}
+
+// pathExpr is a path query such as `foo.bar.*`.
+type pathExpr struct {
+	Query string
+}
+
+// String implements expr interface.
+func (pe *pathExpr) String() string {
+	return pe.Query
+}
+
+// numberExpr is a numeric literal.
+type numberExpr struct {
+	N float64
+	S string
+}
+
+// String implements expr interface.
+func (ne *numberExpr) String() string {
+	return ne.S
+}
+
+// stringExpr is a quoted string literal.
+type stringExpr struct {
+	S string
+}
+
+// String implements expr interface.
+func (se *stringExpr) String() string {
+	return strconv.Quote(se.S)
+}
+
+// boolExpr is either `true` or `false`.
+type boolExpr struct {
+	B bool
+}
+
+// String implements expr interface.
+func (be *boolExpr) String() string {
+	return strconv.FormatBool(be.B)
+}
+
+// parseTarget parses Graphite render target.
+//
+// See https://graphite.readthedocs.io/en/latest/render_api.html#target
+func parseTarget(s string) (expr, error) {
+	e, tail, err := parseExpr(s)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %q: %s", s, err)
+	}
+	tail = skipSpaces(tail)
+	if len(tail) > 0 {
+		return nil, fmt.Errorf("unparsed tail left after parsing %q: %q", s, tail)
+	}
+	return e, nil
+}
+
+func parseExpr(s string) (expr, string, error) {
+	s = skipSpaces(s)
+	if len(s) == 0 {
+		return nil, s, fmt.Errorf("missing expression")
+	}
+	if s[0] == '"' || s[0] == '\'' {
+		return parseString(s)
+	}
+	token, tail := readToken(s)
+	if len(token) == 0 {
+		return nil, s, fmt.Errorf("unexpected char %q", s[0])
+	}
+	tail = skipSpaces(tail)
+	if len(tail) > 0 && tail[0] == '(' {
+		if !isFuncName(token) {
+			return nil, s, fmt.Errorf("invalid function name %q", token)
+		}
+		args, tail, err := parseArgs(tail[1:])
+		if err != nil {
+			return nil, s, fmt.Errorf("cannot parse args for %q: %s", token, err)
+		}
+		fe := &funcExpr{
+			Name: token,
+			Args: args,
+		}
+		return fe, tail, nil
+	}
+	if isNumberStart(token[0]) {
+		if n, err := strconv.ParseFloat(token, 64); err == nil {
+			ne := &numberExpr{
+				N: n,
+				S: token,
+			}
+			return ne, tail, nil
+		}
+	}
+	switch strings.ToLower(token) {
+	case "true":
+		return &boolExpr{B: true}, tail, nil
+	case "false":
+		return &boolExpr{B: false}, tail, nil
+	}
+	pe := &pathExpr{
+		Query: token,
+	}
+	return pe, tail, nil
+}
+
+func parseArgs(s string) ([]expr, string, error) {
+	var args []expr
+	s = skipSpaces(s)
+	if len(s) > 0 && s[0] == ')' {
+		return args, s[1:], nil
+	}
+	for {
+		arg, tail, err := parseExpr(s)
+		if err != nil {
+			return nil, s, err
+		}
+		args = append(args, arg)
+		s = skipSpaces(tail)
+		if len(s) == 0 {
+			return nil, s, fmt.Errorf("missing `)`")
+		}
+		switch s[0] {
+		case ',':
+			s = s[1:]
+		case ')':
+			return args, s[1:], nil
+		default:
+			return nil, s, fmt.Errorf("unexpected char %q; expecting `,` or `)`", s[0])
+		}
+	}
+}
+
+func parseString(s string) (expr, string, error) {
+	quote := s[0]
+	var b []byte
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case quote:
+			se := &stringExpr{
+				S: string(b),
+			}
+			return se, s[i+1:], nil
+		case '\\':
+			i++
+			if i >= len(s) {
+				return nil, s, fmt.Errorf("missing closing quote")
+			}
+			b = append(b, s[i])
+		default:
+			b = append(b, c)
+		}
+	}
+	return nil, s, fmt.Errorf("missing closing quote")
+}
+
+// readToken reads function name, number, bool or path query from s.
+//
+// Commas inside `{...}` are considered as a part of the path query.
+func readToken(s string) (string, string) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth <= 0 {
+				return s[:i], s[i:]
+			}
+		case '(', ')', ' ', '\t', '\n', '"', '\'':
+			return s[:i], s[i:]
+		}
+	}
+	return s, ""
+}
+
+func isFuncName(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || i > 0 && c >= '0' && c <= '9' {
+			continue
+		}
+		return false
+	}
+	return len(s) > 0
+}
+
+func isNumberStart(c byte) bool {
+	return c >= '0' && c <= '9' || c == '-' || c == '+' || c == '.'
+}
+
+func skipSpaces(s string) string {
+	for len(s) > 0 && (s[0] == ' ' || s[0] == '\t' || s[0] == '\n') {
+		s = s[1:]
+	}
+	return s
+}
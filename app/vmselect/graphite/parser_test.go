@@ -0,0 +1,55 @@
+package graphite
+
+import (
+	"testing"
+)
+
+func TestParseTargetSuccess(t *testing.T) {
+	f := func(s, resultExpected string) {
+		t.Helper()
+		e, err := parseTarget(s)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		result := e.String()
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %q; got %q; want %q", s, result, resultExpected)
+		}
+	}
+
+	// Path queries
+	f("foo", "foo")
+	f("foo.bar.*", "foo.bar.*")
+	f("  foo.{bar,baz}.x  ", "foo.{bar,baz}.x")
+	f("foo.[a-z]?.1m", "foo.[a-z]?.1m")
+
+	// Functions
+	f("sumSeries(foo.*)", "sumSeries(foo.*)")
+	f("sumSeries(foo.*, bar.{a,b})", "sumSeries(foo.*,bar.{a,b})")
+	f("scale(foo.bar, -1.5e3)", "scale(foo.bar,-1.5e3)")
+	f("alias(foo, 'a b')", `alias(foo,"a b")`)
+	f(`alias(foo, "a\"b")`, `alias(foo,"a\"b")`)
+	f("aliasByNode(sumSeries(foo.*.bar), 1, -1)", "aliasByNode(sumSeries(foo.*.bar),1,-1)")
+	f("summarize(foo, '1h', 'sum', True)", `summarize(foo,"1h","sum",true)`)
+	f("foo ( )", "foo()")
+}
+
+func TestParseTargetFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		if _, err := parseTarget(s); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", s)
+		}
+	}
+	f("")
+	f("(")
+	f(")")
+	f("foo(")
+	f("foo(bar")
+	f("foo(bar,")
+	f("foo(bar baz)")
+	f("foo bar")
+	f("foo.bar(baz)")
+	f("alias(foo, 'bar)")
+	f("foo(),")
+}
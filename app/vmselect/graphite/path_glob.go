@@ -0,0 +1,256 @@
+package graphite
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+// isGlob returns true if the Graphite path query contains glob chars.
+func isGlob(query string) bool {
+	return strings.ContainsAny(query, "*?[{")
+}
+
+// globToRegexp converts Graphite path query with globs to regexp.
+//
+// The following globs are supported:
+//
+//   - `*` matches zero or more chars inside a single path node
+//   - `?` matches a single char inside a single path node
+//   - `[...]` and `[!...]` match a char from the given set except of `.`
+//   - `{a,b}` matches any of the given alternatives
+//
+// The returned regexp isn't anchored.
+func globToRegexp(query string) (string, error) {
+	var b strings.Builder
+	inAlternation := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch c {
+		case '*':
+			b.WriteString(`[^.]*`)
+		case '?':
+			b.WriteString(`[^.]`)
+		case '[':
+			n := strings.IndexByte(query[i+1:], ']')
+			if n < 0 {
+				return "", fmt.Errorf("missing `]` in %q", query)
+			}
+			class := query[i+1 : i+1+n]
+			if len(class) == 0 {
+				return "", fmt.Errorf("empty `[]` in %q", query)
+			}
+			re, err := charClassToRegexp(class)
+			if err != nil {
+				return "", fmt.Errorf("cannot parse `[%s]` in %q: %s", class, query, err)
+			}
+			b.WriteString(re)
+			i += n + 1
+		case '{':
+			if inAlternation {
+				return "", fmt.Errorf("nested `{` isn't supported in %q", query)
+			}
+			inAlternation = true
+			b.WriteString(`(?:`)
+		case '}':
+			if !inAlternation {
+				return "", fmt.Errorf("unexpected `}` in %q", query)
+			}
+			inAlternation = false
+			b.WriteByte(')')
+		case ',':
+			if inAlternation {
+				b.WriteByte('|')
+			} else {
+				b.WriteByte(',')
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(query[i : i+1]))
+		}
+	}
+	if inAlternation {
+		return "", fmt.Errorf("missing `}` in %q", query)
+	}
+	re := b.String()
+	if _, err := regexp.Compile(re); err != nil {
+		return "", fmt.Errorf("cannot compile regexp %q obtained from %q: %s", re, query, err)
+	}
+	return re, nil
+}
+
+// charClassToRegexp converts the contents of Graphite `[...]` glob to regexp char class.
+//
+// The returned char class never matches `.`, so it cannot match chars from distinct path nodes.
+func charClassToRegexp(class string) (string, error) {
+	negate := false
+	if class[0] == '!' {
+		negate = true
+		class = class[1:]
+	}
+	re, err := syntax.Parse("["+strings.Replace(class, `\`, `\\`, -1)+"]", syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	var ranges []rune
+	switch {
+	case re.Op == syntax.OpCharClass:
+		ranges = re.Rune
+	case re.Op == syntax.OpLiteral && len(re.Rune) == 1:
+		// Single-char class is parsed as a literal.
+		ranges = []rune{re.Rune[0], re.Rune[0]}
+	default:
+		return "", fmt.Errorf("unexpected regexp op %s; want %s", re.Op, syntax.OpCharClass)
+	}
+	var b strings.Builder
+	if negate {
+		b.WriteString(`[^`)
+		writeCharRanges(&b, ranges)
+		b.WriteString(`\.]`)
+		return b.String(), nil
+	}
+
+	// Exclude `.` from the ranges.
+	var rs []rune
+	for i := 0; i < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		if lo > '.' || hi < '.' {
+			rs = append(rs, lo, hi)
+			continue
+		}
+		if lo < '.' {
+			rs = append(rs, lo, '.'-1)
+		}
+		if hi > '.' {
+			rs = append(rs, '.'+1, hi)
+		}
+	}
+	if len(rs) == 0 {
+		return "", fmt.Errorf("the class matches only `.`")
+	}
+	b.WriteByte('[')
+	writeCharRanges(&b, rs)
+	b.WriteByte(']')
+	return b.String(), nil
+}
+
+func writeCharRanges(b *strings.Builder, rs []rune) {
+	for i := 0; i < len(rs); i += 2 {
+		lo, hi := rs[i], rs[i+1]
+		writeClassChar(b, lo)
+		if hi > lo+1 {
+			b.WriteByte('-')
+		}
+		if hi > lo {
+			writeClassChar(b, hi)
+		}
+	}
+}
+
+func writeClassChar(b *strings.Builder, r rune) {
+	if r == '-' {
+		b.WriteString(`\-`)
+		return
+	}
+	b.WriteString(regexp.QuoteMeta(string(r)))
+}
+
+// getNodes splits Graphite path query into nodes.
+//
+// Dots inside `{...}` and `[...]` aren't treated as node separators.
+func getNodes(query string) []string {
+	var nodes []string
+	depth := 0
+	start := 0
+	for i := 0; i < len(query); i++ {
+		switch query[i] {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case '.':
+			if depth == 0 {
+				nodes = append(nodes, query[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(nodes, query[start:])
+}
+
+// expandDottedAlternations expands `{...}` alternations containing dots in the query,
+// so every returned query contains only alternations inside a single path node.
+//
+// For example, `foo.{bar.baz,x}.y` is expanded to `foo.bar.baz.y` and `foo.x.y`.
+func expandDottedAlternations(query string) []string {
+	n := strings.IndexByte(query, '{')
+	for n >= 0 {
+		m := strings.IndexByte(query[n:], '}')
+		if m < 0 {
+			break
+		}
+		m += n
+		alternation := query[n+1 : m]
+		if strings.IndexByte(alternation, '.') < 0 {
+			k := strings.IndexByte(query[m:], '{')
+			if k < 0 {
+				break
+			}
+			n = m + k
+			continue
+		}
+		var queries []string
+		for _, alt := range strings.Split(alternation, ",") {
+			queries = append(queries, expandDottedAlternations(query[:n]+alt+query[m+1:])...)
+		}
+		return queries
+	}
+	return []string{query}
+}
+
+// getPathTagFilter returns tag filter on `__name__` matching the given Graphite path query.
+//
+// If withChildren is set, then the filter also matches children of the matching paths.
+func getPathTagFilter(query string, withChildren bool) (*storage.TagFilter, error) {
+	if !isGlob(query) && !withChildren {
+		return &storage.TagFilter{
+			Key:   nil,
+			Value: []byte(query),
+		}, nil
+	}
+	re, err := globToRegexp(query)
+	if err != nil {
+		return nil, err
+	}
+	if withChildren {
+		re += `(?:\..*)?`
+	}
+	return &storage.TagFilter{
+		Key:      nil,
+		Value:    []byte(re),
+		IsRegexp: true,
+	}, nil
+}
+
+// getNodesCount returns the number of nodes in Graphite path query.
+//
+// Dots inside `{...}` and `[...]` aren't counted.
+func getNodesCount(query string) int {
+	n := 1
+	depth := 0
+	for i := 0; i < len(query); i++ {
+		switch query[i] {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case '.':
+			if depth == 0 {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,115 @@
+package graphite
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestGlobToRegexpSuccess(t *testing.T) {
+	f := func(query, reExpected string) {
+		t.Helper()
+		re, err := globToRegexp(query)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", query, err)
+		}
+		if re != reExpected {
+			t.Fatalf("unexpected regexp for %q; got %q; want %q", query, re, reExpected)
+		}
+	}
+	f("", "")
+	f("foo", "foo")
+	f("foo.bar", `foo\.bar`)
+	f("foo.*", `foo\.[^.]*`)
+	f("foo.b?r", `foo\.b[^.]r`)
+	f("foo.[ab]c", `foo\.[ab]c`)
+	f("foo.[!ab]c", `foo\.[^ab\.]c`)
+	f("foo.[a]c", `foo\.[a]c`)
+	f("foo.[a-z.]c", `foo\.[a-z]c`)
+	f("foo.[+-/]c", `foo\.[\+-\-/]c`)
+	f("foo.{bar,baz*}.x", `foo\.(?:bar|baz[^.]*)\.x`)
+	f("foo-bar+1", `foo-bar\+1`)
+}
+
+func TestGlobToRegexpFailure(t *testing.T) {
+	f := func(query string) {
+		t.Helper()
+		if _, err := globToRegexp(query); err == nil {
+			t.Fatalf("expecting non-nil error for %q", query)
+		}
+	}
+	f("foo[")
+	f("foo[]")
+	f("foo{a,b")
+	f("foo}")
+	f("foo{a,{b,c}}")
+	f("foo.[.]")
+}
+
+func TestGlobToRegexpMatch(t *testing.T) {
+	f := func(query, path string, matchExpected bool) {
+		t.Helper()
+		re, err := globToRegexp(query)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", query, err)
+		}
+		ok := regexp.MustCompile("^(?:" + re + ")$").MatchString(path)
+		if ok != matchExpected {
+			t.Fatalf("unexpected match result for query=%q, path=%q; got %v; want %v", query, path, ok, matchExpected)
+		}
+	}
+	f("foo.*", "foo.bar", true)
+	f("foo.*", "foo.bar.baz", false)
+	f("foo.*.baz", "foo.bar.baz", true)
+	f("foo.{a,b}", "foo.b", true)
+	f("foo.{a,b}", "foo.c", false)
+	f("foo.[0-9]", "foo.5", true)
+	f("foo.[!0-9]", "foo.5", false)
+	f("a.[b.]c", "a.bc", true)
+	f("a.[b.]c", "a..c", false)
+	f("a.[!b]c", "a..c", false)
+	f("a.[+-/]c", "a.,c", true)
+	f("a.[+-/]c", "a..c", false)
+}
+
+func TestGetNodesCount(t *testing.T) {
+	f := func(query string, nExpected int) {
+		t.Helper()
+		n := getNodesCount(query)
+		if n != nExpected {
+			t.Fatalf("unexpected nodes count for %q; got %d; want %d", query, n, nExpected)
+		}
+	}
+	f("foo", 1)
+	f("foo.bar.*", 3)
+	f("foo.{a.b,c}", 2)
+}
+
+func TestGetNodes(t *testing.T) {
+	f := func(query string, nodesExpected []string) {
+		t.Helper()
+		nodes := getNodes(query)
+		if !reflect.DeepEqual(nodes, nodesExpected) {
+			t.Fatalf("unexpected nodes for %q; got %q; want %q", query, nodes, nodesExpected)
+		}
+	}
+	f("", []string{""})
+	f("foo", []string{"foo"})
+	f("foo.b*r.[a.b]", []string{"foo", "b*r", "[a.b]"})
+	f("foo.{a.b,c}.d", []string{"foo", "{a.b,c}", "d"})
+}
+
+func TestExpandDottedAlternations(t *testing.T) {
+	f := func(query string, queriesExpected []string) {
+		t.Helper()
+		queries := expandDottedAlternations(query)
+		if !reflect.DeepEqual(queries, queriesExpected) {
+			t.Fatalf("unexpected queries for %q; got %q; want %q", query, queries, queriesExpected)
+		}
+	}
+	f("foo.*", []string{"foo.*"})
+	f("foo.{a,b}.c", []string{"foo.{a,b}.c"})
+	f("foo.{a.b,c}.d", []string{"foo.a.b.d", "foo.c.d"})
+	f("{x,y}.{a.b,c}", []string{"{x,y}.a.b", "{x,y}.c"})
+	f("{x.y,z}.{a.b,c}", []string{"x.y.a.b", "x.y.c", "z.a.b", "z.c"})
+}
@@ -0,0 +1,101 @@
+package graphite
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseTime parses Graphite time from `from` and `until` query args.
+//
+// The following formats are supported:
+//
+//   - `now`
+//   - relative time such as `-1h`, `now-1d` or `now+5min`
+//   - unix timestamp in seconds
+//   - `HH:MM_YYYYMMDD` and `YYYYMMDD`
+//
+// currentTime and the returned time are in milliseconds.
+//
+// See https://graphite.readthedocs.io/en/latest/render_api.html#from-until
+func parseTime(s string, currentTime int64) (int64, error) {
+	if s == "now" {
+		return currentTime, nil
+	}
+	if strings.HasPrefix(s, "now") {
+		s = s[len("now"):]
+	}
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		offset, err := parseInterval(s)
+		if err != nil {
+			return 0, err
+		}
+		return currentTime + offset, nil
+	}
+	if len(s) != len("YYYYMMDD") {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n * 1e3, nil
+		}
+	}
+	for _, layout := range []string{"15:04_20060102", "20060102"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UnixNano() / 1e6, nil
+		}
+	}
+	return 0, fmt.Errorf("cannot parse time %q", s)
+}
+
+// parseInterval parses Graphite interval such as `5min`, `-1h` or `2weeks`.
+//
+// The returned interval is in milliseconds.
+func parseInterval(s string) (int64, error) {
+	sOrig := s
+	sign := int64(1)
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+		s = s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("missing number in interval %q", sOrig)
+	}
+	v, err := strconv.ParseInt(s[:n], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse number in interval %q: %s", sOrig, err)
+	}
+	unit, err := getUnitMsecs(s[n:])
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse interval %q: %s", sOrig, err)
+	}
+	return sign * v * unit, nil
+}
+
+// getUnitMsecs returns the duration for the given Graphite time unit in milliseconds.
+//
+// Units are matched by prefix like Graphite does, i.e. `m`, `min` and `minutes` are equivalent.
+func getUnitMsecs(unit string) (int64, error) {
+	switch {
+	case strings.HasPrefix(unit, "s"):
+		return 1e3, nil
+	case strings.HasPrefix(unit, "mon"):
+		return 30 * 24 * 3600 * 1e3, nil
+	case strings.HasPrefix(unit, "m"):
+		return 60 * 1e3, nil
+	case strings.HasPrefix(unit, "h"):
+		return 3600 * 1e3, nil
+	case strings.HasPrefix(unit, "d"):
+		return 24 * 3600 * 1e3, nil
+	case strings.HasPrefix(unit, "w"):
+		return 7 * 24 * 3600 * 1e3, nil
+	case strings.HasPrefix(unit, "y"):
+		return 365 * 24 * 3600 * 1e3, nil
+	default:
+		return 0, fmt.Errorf("unsupported time unit %q; supported units: s, min, h, d, w, mon, y", unit)
+	}
+}
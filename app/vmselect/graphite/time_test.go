@@ -0,0 +1,66 @@
+package graphite
+
+import (
+	"testing"
+)
+
+func TestParseTimeSuccess(t *testing.T) {
+	const currentTime = 1565000000000
+	f := func(s string, resultExpected int64) {
+		t.Helper()
+		result, err := parseTime(s, currentTime)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %q; got %d; want %d", s, result, resultExpected)
+		}
+	}
+	f("now", currentTime)
+	f("-1h", currentTime-3600*1e3)
+	f("now-5min", currentTime-300*1e3)
+	f("now+2d", currentTime+2*24*3600*1e3)
+	f("-10s", currentTime-10*1e3)
+	f("-1w", currentTime-7*24*3600*1e3)
+	f("-1mon", currentTime-30*24*3600*1e3)
+	f("1564000000", 1564000000*1e3)
+	f("20190805", 1564963200*1e3)
+	f("10:20_20190805", 1565000400*1e3)
+}
+
+func TestParseTimeFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		if _, err := parseTime(s, 0); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", s)
+		}
+	}
+	f("")
+	f("foo")
+	f("-h")
+	f("-1foo")
+	f("now-")
+	f("2019-08-05")
+}
+
+func TestParseIntervalSuccess(t *testing.T) {
+	f := func(s string, resultExpected int64) {
+		t.Helper()
+		result, err := parseInterval(s)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %q; got %d; want %d", s, result, resultExpected)
+		}
+	}
+	f("1s", 1e3)
+	f("30sec", 30*1e3)
+	f("5m", 5*60*1e3)
+	f("5min", 5*60*1e3)
+	f("5minutes", 5*60*1e3)
+	f("1h", 3600*1e3)
+	f("+2hours", 2*3600*1e3)
+	f("-1d", -24*3600*1e3)
+	f("1y", 365*24*3600*1e3)
+}
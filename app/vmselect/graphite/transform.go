@@ -0,0 +1,578 @@
+package graphite
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// transformFunc evaluates Graphite function with the given args.
+type transformFunc func(ec *evalConfig, args []expr) ([]*series, error)
+
+// transformFuncs contains the supported Graphite functions.
+//
+// See https://graphite.readthedocs.io/en/latest/functions.html
+//
+// It is initialized in init, since the functions refer to it via evalExpr.
+var transformFuncs map[string]transformFunc
+
+func init() {
+	transformFuncs = map[string]transformFunc{
+		"absolute":              newTransformFuncPerPoint("absolute", math.Abs),
+		"alias":                 transformAlias,
+		"aliasByNode":           transformAliasByNode,
+		"averageSeries":         newTransformFuncAggregate("averageSeries", aggrAvg),
+		"avg":                   newTransformFuncAggregate("averageSeries", aggrAvg),
+		"derivative":            transformDerivative,
+		"maxSeries":             newTransformFuncAggregate("maxSeries", aggrMax),
+		"minSeries":             newTransformFuncAggregate("minSeries", aggrMin),
+		"movingAverage":         transformMovingAverage,
+		"nonNegativeDerivative": transformNonNegativeDerivative,
+		"offset":                transformOffset,
+		"scale":                 transformScale,
+		"sum":                   newTransformFuncAggregate("sumSeries", aggrSum),
+		"sumSeries":             newTransformFuncAggregate("sumSeries", aggrSum),
+		"summarize":             transformSummarize,
+	}
+}
+
+func getTransformFunc(name string) transformFunc {
+	return transformFuncs[name]
+}
+
+// aggrFunc returns an aggregate for the given values.
+//
+// It must return NaN if values contain only NaNs.
+type aggrFunc func(values []float64) float64
+
+var aggrFuncs = map[string]aggrFunc{
+	"avg":     aggrAvg,
+	"average": aggrAvg,
+	"count":   aggrCount,
+	"last":    aggrLast,
+	"max":     aggrMax,
+	"min":     aggrMin,
+	"sum":     aggrSum,
+	"total":   aggrSum,
+}
+
+func aggrAvg(values []float64) float64 {
+	sum := float64(0)
+	n := 0
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		sum += v
+		n++
+	}
+	if n == 0 {
+		return nan
+	}
+	return sum / float64(n)
+}
+
+func aggrCount(values []float64) float64 {
+	n := 0
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			n++
+		}
+	}
+	if n == 0 {
+		return nan
+	}
+	return float64(n)
+}
+
+func aggrLast(values []float64) float64 {
+	for i := len(values) - 1; i >= 0; i-- {
+		if !math.IsNaN(values[i]) {
+			return values[i]
+		}
+	}
+	return nan
+}
+
+func aggrMax(values []float64) float64 {
+	result := nan
+	for _, v := range values {
+		if math.IsNaN(result) || v > result {
+			result = v
+		}
+	}
+	return result
+}
+
+func aggrMin(values []float64) float64 {
+	result := nan
+	for _, v := range values {
+		if math.IsNaN(result) || v < result {
+			result = v
+		}
+	}
+	return result
+}
+
+func aggrSum(values []float64) float64 {
+	sum := float64(0)
+	n := 0
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		sum += v
+		n++
+	}
+	if n == 0 {
+		return nan
+	}
+	return sum
+}
+
+// newTransformFuncAggregate returns transformFunc, which aggregates all the series
+// from args into a single series named `name(pathExpressions)`.
+func newTransformFuncAggregate(name string, af aggrFunc) transformFunc {
+	return func(ec *evalConfig, args []expr) ([]*series, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("expecting at least one arg")
+		}
+		var ss []*series
+		for i := range args {
+			ssArg, err := getSeriesListArg(ec, args, i)
+			if err != nil {
+				return nil, err
+			}
+			ss = append(ss, ssArg...)
+		}
+		if len(ss) == 0 {
+			return nil, nil
+		}
+		timestamps := getCommonTimestamps(ss)
+		values := make([]float64, len(timestamps))
+		points := make([]float64, len(ss))
+		for i, ts := range timestamps {
+			for j, s := range ss {
+				points[j] = getValueAt(s, ts)
+			}
+			values[i] = af(points)
+		}
+		var pathExpressions []string
+		seen := make(map[string]bool)
+		for _, s := range ss {
+			if !seen[s.pathExpression] {
+				seen[s.pathExpression] = true
+				pathExpressions = append(pathExpressions, s.pathExpression)
+			}
+		}
+		sr := newSeries(name+"("+strings.Join(pathExpressions, ",")+")", timestamps, values)
+		return []*series{sr}, nil
+	}
+}
+
+// getCommonTimestamps returns sorted union of timestamps for ss.
+func getCommonTimestamps(ss []*series) []int64 {
+	m := make(map[int64]struct{})
+	for _, s := range ss {
+		for _, ts := range s.Timestamps {
+			m[ts] = struct{}{}
+		}
+	}
+	timestamps := make([]int64, 0, len(m))
+	for ts := range m {
+		timestamps = append(timestamps, ts)
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i] < timestamps[j]
+	})
+	return timestamps
+}
+
+// getValueAt returns s value at the given ts or NaN if s has no point at ts.
+func getValueAt(s *series, ts int64) float64 {
+	n := sort.Search(len(s.Timestamps), func(i int) bool {
+		return s.Timestamps[i] >= ts
+	})
+	if n < len(s.Timestamps) && s.Timestamps[n] == ts {
+		return s.Values[n]
+	}
+	return nan
+}
+
+// newTransformFuncPerPoint returns transformFunc, which applies f to every point
+// of the series from the first arg.
+func newTransformFuncPerPoint(name string, f func(v float64) float64) transformFunc {
+	return func(ec *evalConfig, args []expr) ([]*series, error) {
+		if err := expectArgsCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		ss, err := getSeriesListArg(ec, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range ss {
+			for i, v := range s.Values {
+				s.Values[i] = f(v)
+			}
+			setSeriesName(s, fmt.Sprintf("%s(%s)", name, s.Name))
+		}
+		return ss, nil
+	}
+}
+
+// transformScale implements scale(seriesList, factor)
+func transformScale(ec *evalConfig, args []expr) ([]*series, error) {
+	if err := expectArgsCount(args, 2, 2); err != nil {
+		return nil, err
+	}
+	ss, err := getSeriesListArg(ec, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	factor, err := getNumberArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		for i, v := range s.Values {
+			s.Values[i] = v * factor
+		}
+		setSeriesName(s, fmt.Sprintf("scale(%s,%s)", s.Name, formatNumber(factor)))
+	}
+	return ss, nil
+}
+
+// transformOffset implements offset(seriesList, factor)
+func transformOffset(ec *evalConfig, args []expr) ([]*series, error) {
+	if err := expectArgsCount(args, 2, 2); err != nil {
+		return nil, err
+	}
+	ss, err := getSeriesListArg(ec, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	factor, err := getNumberArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		for i, v := range s.Values {
+			s.Values[i] = v + factor
+		}
+		setSeriesName(s, fmt.Sprintf("offset(%s,%s)", s.Name, formatNumber(factor)))
+	}
+	return ss, nil
+}
+
+// transformAlias implements alias(seriesList, newName)
+func transformAlias(ec *evalConfig, args []expr) ([]*series, error) {
+	if err := expectArgsCount(args, 2, 2); err != nil {
+		return nil, err
+	}
+	ss, err := getSeriesListArg(ec, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	newName, err := getStringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		s.Name = newName
+	}
+	return ss, nil
+}
+
+// transformAliasByNode implements aliasByNode(seriesList, *nodes)
+//
+// Negative nodes are counted from the end of the path.
+func transformAliasByNode(ec *evalConfig, args []expr) ([]*series, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("expecting at least 2 args; got %d args", len(args))
+	}
+	ss, err := getSeriesListArg(ec, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []int
+	for i := 1; i < len(args); i++ {
+		n, err := getNumberArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, int(n))
+	}
+	for _, s := range ss {
+		parts := strings.Split(getPathFromName(s.Name), ".")
+		var a []string
+		for _, n := range nodes {
+			if n < 0 {
+				n += len(parts)
+			}
+			if n < 0 || n >= len(parts) {
+				continue
+			}
+			a = append(a, parts[n])
+		}
+		s.Name = strings.Join(a, ".")
+	}
+	return ss, nil
+}
+
+// getPathFromName returns the innermost path from series name such as `scale(foo.bar,2)`.
+//
+// Tags are stripped from the returned path.
+func getPathFromName(name string) string {
+	if n := strings.LastIndexByte(name, '('); n >= 0 {
+		name = name[n+1:]
+	}
+	if n := strings.IndexAny(name, ",);"); n >= 0 {
+		name = name[:n]
+	}
+	return name
+}
+
+// transformSummarize implements summarize(seriesList, intervalString, func='sum', alignToFrom=False)
+func transformSummarize(ec *evalConfig, args []expr) ([]*series, error) {
+	if err := expectArgsCount(args, 2, 4); err != nil {
+		return nil, err
+	}
+	ss, err := getSeriesListArg(ec, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	intervalString, err := getStringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := parseInterval(intervalString)
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive; got %q", intervalString)
+	}
+	funcName := "sum"
+	if len(args) > 2 {
+		if funcName, err = getStringArg(args, 2); err != nil {
+			return nil, err
+		}
+	}
+	af := aggrFuncs[funcName]
+	if af == nil {
+		return nil, fmt.Errorf("unsupported aggregate function %q", funcName)
+	}
+	alignToFrom := false
+	if len(args) > 3 {
+		if alignToFrom, err = getBoolArg(args, 3); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range ss {
+		var timestamps []int64
+		var values []float64
+		var bucket []float64
+		bucketStart := int64(0)
+		for i, ts := range s.Timestamps {
+			var start int64
+			if alignToFrom {
+				start = ec.startTime + (ts-ec.startTime)/interval*interval
+			} else {
+				start = ts - ts%interval
+			}
+			if i > 0 && start != bucketStart {
+				timestamps = append(timestamps, bucketStart)
+				values = append(values, af(bucket))
+				bucket = bucket[:0]
+			}
+			bucketStart = start
+			bucket = append(bucket, s.Values[i])
+		}
+		if len(s.Timestamps) > 0 {
+			timestamps = append(timestamps, bucketStart)
+			values = append(values, af(bucket))
+		}
+		s.Timestamps = timestamps
+		s.Values = values
+		suffix := ""
+		if alignToFrom {
+			suffix = ", true"
+		}
+		setSeriesName(s, fmt.Sprintf("summarize(%s, %q, %q%s)", s.Name, intervalString, funcName, suffix))
+	}
+	return ss, nil
+}
+
+// transformMovingAverage implements movingAverage(seriesList, windowSize)
+//
+// windowSize may be either the number of points or an interval string such as `5min`.
+// Like in Graphite, the window preceding every point doesn't include the point itself.
+func transformMovingAverage(ec *evalConfig, args []expr) ([]*series, error) {
+	if err := expectArgsCount(args, 2, 2); err != nil {
+		return nil, err
+	}
+	var window int64
+	var windowString string
+	switch t := args[1].(type) {
+	case *numberExpr:
+		window = int64(t.N) * ec.storageStep
+		windowString = formatNumber(t.N)
+	case *stringExpr:
+		interval, err := parseInterval(t.S)
+		if err != nil {
+			return nil, err
+		}
+		window = interval
+		windowString = strconv.Quote(t.S)
+	default:
+		return nil, fmt.Errorf("windowSize must be either number or string; got %s", args[1])
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("windowSize must be positive; got %s", args[1])
+	}
+
+	// Fetch additional points preceding ec.startTime, so the first points have full windows.
+	ecCopy := *ec
+	ecCopy.startTime -= window
+	ss, err := getSeriesListArg(&ecCopy, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		var timestamps []int64
+		var values []float64
+		var points []float64
+		j := 0
+		for i, ts := range s.Timestamps {
+			if ts < ec.startTime {
+				continue
+			}
+			for j < i && s.Timestamps[j] < ts-window {
+				j++
+			}
+			points = append(points[:0], s.Values[j:i]...)
+			timestamps = append(timestamps, ts)
+			values = append(values, aggrAvg(points))
+		}
+		s.Timestamps = timestamps
+		s.Values = values
+		setSeriesName(s, fmt.Sprintf("movingAverage(%s,%s)", s.Name, windowString))
+	}
+	return ss, nil
+}
+
+// transformDerivative implements derivative(seriesList)
+func transformDerivative(ec *evalConfig, args []expr) ([]*series, error) {
+	if err := expectArgsCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+	ss, err := getSeriesListArg(ec, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		prev := nan
+		for i, v := range s.Values {
+			s.Values[i] = v - prev
+			prev = v
+		}
+		setSeriesName(s, fmt.Sprintf("derivative(%s)", s.Name))
+	}
+	return ss, nil
+}
+
+// transformNonNegativeDerivative implements nonNegativeDerivative(seriesList, maxValue=None)
+//
+// Negative deltas are considered as counter wraparound if maxValue is set.
+// Otherwise they are replaced with nulls.
+func transformNonNegativeDerivative(ec *evalConfig, args []expr) ([]*series, error) {
+	if err := expectArgsCount(args, 1, 2); err != nil {
+		return nil, err
+	}
+	ss, err := getSeriesListArg(ec, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	maxValue := nan
+	if len(args) > 1 {
+		if maxValue, err = getNumberArg(args, 1); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range ss {
+		prev := nan
+		for i, v := range s.Values {
+			if math.IsNaN(v) {
+				// Skip gaps like Graphite does.
+				s.Values[i] = nan
+				continue
+			}
+			d := v - prev
+			if d < 0 {
+				if !math.IsNaN(maxValue) && v <= maxValue {
+					d = maxValue - prev + v + 1
+				} else {
+					d = nan
+				}
+			}
+			s.Values[i] = d
+			prev = v
+		}
+		setSeriesName(s, fmt.Sprintf("nonNegativeDerivative(%s)", s.Name))
+	}
+	return ss, nil
+}
+
+func setSeriesName(s *series, name string) {
+	s.Name = name
+	s.pathExpression = name
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
+
+func expectArgsCount(args []expr, minArgs, maxArgs int) error {
+	if len(args) < minArgs || len(args) > maxArgs {
+		if minArgs == maxArgs {
+			return fmt.Errorf("unexpected number of args; got %d; want %d", len(args), minArgs)
+		}
+		return fmt.Errorf("unexpected number of args; got %d; want from %d to %d", len(args), minArgs, maxArgs)
+	}
+	return nil
+}
+
+func getSeriesListArg(ec *evalConfig, args []expr, n int) ([]*series, error) {
+	switch t := args[n].(type) {
+	case *pathExpr, *funcExpr:
+		return evalExpr(ec, t)
+	default:
+		return nil, fmt.Errorf("arg #%d must be series list; got %s", n+1, args[n])
+	}
+}
+
+func getNumberArg(args []expr, n int) (float64, error) {
+	ne, ok := args[n].(*numberExpr)
+	if !ok {
+		return 0, fmt.Errorf("arg #%d must be number; got %s", n+1, args[n])
+	}
+	return ne.N, nil
+}
+
+func getStringArg(args []expr, n int) (string, error) {
+	se, ok := args[n].(*stringExpr)
+	if !ok {
+		return "", fmt.Errorf("arg #%d must be string; got %s", n+1, args[n])
+	}
+	return se.S, nil
+}
+
+func getBoolArg(args []expr, n int) (bool, error) {
+	be, ok := args[n].(*boolExpr)
+	if !ok {
+		return false, fmt.Errorf("arg #%d must be bool; got %s", n+1, args[n])
+	}
+	return be.B, nil
+}
@@ -0,0 +1,113 @@
+package graphite
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestGetTimestamps(t *testing.T) {
+	f := func(start, end, step int64, timestampsExpected []int64) {
+		t.Helper()
+		ec := &evalConfig{
+			startTime:   start,
+			endTime:     end,
+			storageStep: step,
+		}
+		timestamps := getTimestamps(ec)
+		if !reflect.DeepEqual(timestamps, timestampsExpected) {
+			t.Fatalf("unexpected timestamps; got %v; want %v", timestamps, timestampsExpected)
+		}
+	}
+	f(1000, 1000, 10, []int64{1000})
+	f(1000, 1030, 10, []int64{1000, 1010, 1020, 1030})
+	f(1001, 1029, 10, []int64{1010, 1020})
+	f(1001, 1009, 10, nil)
+}
+
+func TestAlignValues(t *testing.T) {
+	f := func(srcTimestamps []int64, srcValues []float64, timestamps []int64, valuesExpected []float64) {
+		t.Helper()
+		values := alignValues(srcTimestamps, srcValues, timestamps, 10)
+		if !equalValues(values, valuesExpected) {
+			t.Fatalf("unexpected values; got %v; want %v", values, valuesExpected)
+		}
+	}
+	f(nil, nil, []int64{10, 20}, []float64{nan, nan})
+	f([]int64{10, 20}, []float64{1, 2}, []int64{10, 20}, []float64{1, 2})
+	f([]int64{5, 11, 19, 35}, []float64{1, 2, 3, 4}, []int64{10, 20, 30}, []float64{3, nan, 4})
+	f([]int64{41, 42}, []float64{1, 2}, []int64{10, 20}, []float64{nan, nan})
+}
+
+func TestAggrFuncs(t *testing.T) {
+	f := func(af aggrFunc, values []float64, resultExpected float64) {
+		t.Helper()
+		result := af(values)
+		if !equalValues([]float64{result}, []float64{resultExpected}) {
+			t.Fatalf("unexpected result for %v; got %v; want %v", values, result, resultExpected)
+		}
+	}
+	values := []float64{nan, 3, 1, nan, 2, nan}
+	f(aggrSum, values, 6)
+	f(aggrAvg, values, 2)
+	f(aggrMin, values, 1)
+	f(aggrMax, values, 3)
+	f(aggrLast, values, 2)
+	f(aggrCount, values, 3)
+
+	nans := []float64{nan, nan}
+	for _, af := range aggrFuncs {
+		f(af, nans, nan)
+		f(af, nil, nan)
+	}
+}
+
+func TestConsolidateSeries(t *testing.T) {
+	f := func(maxDataPoints int, timestampsExpected []int64, valuesExpected []float64) {
+		t.Helper()
+		s := &series{
+			Timestamps: []int64{10, 20, 30, 40, 50},
+			Values:     []float64{1, 2, nan, 4, 5},
+		}
+		consolidateSeries(s, maxDataPoints)
+		if !reflect.DeepEqual(s.Timestamps, timestampsExpected) {
+			t.Fatalf("unexpected timestamps; got %v; want %v", s.Timestamps, timestampsExpected)
+		}
+		if !equalValues(s.Values, valuesExpected) {
+			t.Fatalf("unexpected values; got %v; want %v", s.Values, valuesExpected)
+		}
+	}
+	f(10, []int64{10, 20, 30, 40, 50}, []float64{1, 2, nan, 4, 5})
+	f(5, []int64{10, 20, 30, 40, 50}, []float64{1, 2, nan, 4, 5})
+	f(3, []int64{10, 30, 50}, []float64{1.5, 4, 5})
+	f(1, []int64{10}, []float64{3})
+}
+
+func TestGetPathFromName(t *testing.T) {
+	f := func(name, pathExpected string) {
+		t.Helper()
+		path := getPathFromName(name)
+		if path != pathExpected {
+			t.Fatalf("unexpected path for %q; got %q; want %q", name, path, pathExpected)
+		}
+	}
+	f("foo.bar", "foo.bar")
+	f("foo.bar;tag=value", "foo.bar")
+	f("scale(foo.bar,2)", "foo.bar")
+	f(`summarize(movingAverage(foo.bar.baz,5), "1h", "sum")`, "foo.bar.baz")
+}
+
+func equalValues(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.IsNaN(a[i]) != math.IsNaN(b[i]) {
+			return false
+		}
+		if !math.IsNaN(a[i]) && a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
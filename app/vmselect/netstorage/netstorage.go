@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
@@ -254,7 +255,7 @@ func mergeSortBlocks(dst *Result, sbh sortBlocksHeap) {
 			dst.Timestamps = append(dst.Timestamps, top.Timestamps[top.NextIdx:]...)
 			dst.Values = append(dst.Values, top.Values[top.NextIdx:]...)
 			putSortBlock(top)
-			return
+			break
 		}
 		sbNext := sbh[0]
 		tsNext := sbNext.Timestamps[sbNext.NextIdx]
@@ -275,8 +276,15 @@ func mergeSortBlocks(dst *Result, sbh sortBlocksHeap) {
 			putSortBlock(top)
 		}
 	}
+	timestamps, values := storage.DeduplicateSamples(dst.Timestamps, dst.Values)
+	dedups := len(dst.Timestamps) - len(timestamps)
+	dedupsDuringSelect.Add(dedups)
+	dst.Timestamps = timestamps
+	dst.Values = values
 }
 
+var dedupsDuringSelect = metrics.NewCounter(`vm_deduplicated_samples_total{type="select"}`)
+
 type sortBlock struct {
 	// b is used as a temporary storage for unpacked rows before they
 	// go to Timestamps and Values.
@@ -353,19 +361,99 @@ func (sbh *sortBlocksHeap) Pop() interface{} {
 }
 
 // DeleteSeries deletes time series matching the given tagFilterss.
-func DeleteSeries(sq *storage.SearchQuery) (int, error) {
-	tfss, err := setupTfss(sq.TagFilterss)
+func DeleteSeries(sq *storage.SearchQuery, deadline Deadline) (int, error) {
+	if len(storageNodes) > 0 {
+		return deleteMetricsRemote(sq, deadline, (*storageNode).deleteMetrics)
+	}
+	tfss, err := setupTfss(sq.AccountID, sq.ProjectID, sq.TagFilterss)
 	if err != nil {
 		return 0, err
 	}
 	return vmstorage.DeleteMetrics(tfss)
 }
 
-// GetLabels returns labels until the given deadline.
-func GetLabels(deadline Deadline) ([]string, error) {
-	labels, err := vmstorage.SearchTagKeys(*maxTagKeysPerSearch)
+// DeleteSamples deletes samples on the time range from sq for time series matching the given tagFilterss.
+//
+// Returns the number of time series with deleted samples.
+func DeleteSamples(sq *storage.SearchQuery, deadline Deadline) (int, error) {
+	if len(storageNodes) > 0 {
+		return deleteMetricsRemote(sq, deadline, (*storageNode).deleteSamples)
+	}
+	tfss, err := setupTfss(sq.AccountID, sq.ProjectID, sq.TagFilterss)
 	if err != nil {
-		return nil, fmt.Errorf("error during labels search: %s", err)
+		return 0, err
+	}
+	tr := storage.TimeRange{
+		MinTimestamp: sq.MinTimestamp,
+		MaxTimestamp: sq.MaxTimestamp,
+	}
+	return vmstorage.DeleteSamples(tfss, tr)
+}
+
+func deleteMetricsRemote(sq *storage.SearchQuery, deadline Deadline, f func(sn *storageNode, sq *storage.SearchQuery, deadline Deadline) (int, error)) (int, error) {
+	type nodeResult struct {
+		deletedCount int
+		err          error
+	}
+	resultsCh := make(chan nodeResult, len(storageNodes))
+	for _, sn := range storageNodes {
+		go func(sn *storageNode) {
+			deletedCount, err := f(sn, sq, deadline)
+			resultsCh <- nodeResult{
+				deletedCount: deletedCount,
+				err:          err,
+			}
+		}(sn)
+	}
+
+	// Collect results
+	deletedTotal := 0
+	var errors []error
+	for i := 0; i < len(storageNodes); i++ {
+		nr := <-resultsCh
+		if nr.err != nil {
+			errors = append(errors, nr.err)
+			continue
+		}
+		deletedTotal += nr.deletedCount
+	}
+	if len(errors) > 0 {
+		// Return only the first error, since it has no sense in returning all errors.
+		return deletedTotal, fmt.Errorf("error occurred during deleting time series: %s", errors[0])
+	}
+	return deletedTotal, nil
+}
+
+// GetLabels returns labels for the given tenant until the given deadline.
+func GetLabels(at *auth.Token, deadline Deadline) ([]string, error) {
+	var labels []string
+	if len(storageNodes) > 0 {
+		labelsSet := make(map[string]struct{})
+		var mLock sync.Mutex
+		err := execOnStorageNodes(func(sn *storageNode) error {
+			ls, err := sn.getLabels(at.AccountID, at.ProjectID, deadline)
+			if err != nil {
+				return err
+			}
+			mLock.Lock()
+			for _, label := range ls {
+				labelsSet[label] = struct{}{}
+			}
+			mLock.Unlock()
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error occurred during labels search: %s", err)
+		}
+		for label := range labelsSet {
+			labels = append(labels, label)
+		}
+	} else {
+		var err error
+		labels, err = vmstorage.SearchTagKeys(at.AccountID, at.ProjectID, *maxTagKeysPerSearch)
+		if err != nil {
+			return nil, fmt.Errorf("error during labels search: %s", err)
+		}
 	}
 
 	// Substitute "" with "__name__"
@@ -381,17 +469,42 @@ func GetLabels(deadline Deadline) ([]string, error) {
 	return labels, nil
 }
 
-// GetLabelValues returns label values for the given labelName
+// GetLabelValues returns label values for the given tenant and labelName
 // until the given deadline.
-func GetLabelValues(labelName string, deadline Deadline) ([]string, error) {
+func GetLabelValues(at *auth.Token, labelName string, deadline Deadline) ([]string, error) {
 	if labelName == "__name__" {
 		labelName = ""
 	}
 
 	// Search for tag values
-	labelValues, err := vmstorage.SearchTagValues([]byte(labelName), *maxTagValuesPerSearch)
-	if err != nil {
-		return nil, fmt.Errorf("error during label values search for labelName=%q: %s", labelName, err)
+	var labelValues []string
+	if len(storageNodes) > 0 {
+		labelValuesSet := make(map[string]struct{})
+		var mLock sync.Mutex
+		err := execOnStorageNodes(func(sn *storageNode) error {
+			lvs, err := sn.getLabelValues(at.AccountID, at.ProjectID, labelName, deadline)
+			if err != nil {
+				return err
+			}
+			mLock.Lock()
+			for _, labelValue := range lvs {
+				labelValuesSet[labelValue] = struct{}{}
+			}
+			mLock.Unlock()
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error occurred during label values search for labelName=%q: %s", labelName, err)
+		}
+		for labelValue := range labelValuesSet {
+			labelValues = append(labelValues, labelValue)
+		}
+	} else {
+		var err error
+		labelValues, err = vmstorage.SearchTagValues(at.AccountID, at.ProjectID, []byte(labelName), *maxTagValuesPerSearch)
+		if err != nil {
+			return nil, fmt.Errorf("error during label values search for labelName=%q: %s", labelName, err)
+		}
 	}
 
 	// Sort labelValues like Prometheus does
@@ -400,11 +513,93 @@ func GetLabelValues(labelName string, deadline Deadline) ([]string, error) {
 	return labelValues, nil
 }
 
-// GetLabelEntries returns all the label entries until the given deadline.
-func GetLabelEntries(deadline Deadline) ([]storage.TagEntry, error) {
-	labelEntries, err := vmstorage.SearchTagEntries(*maxTagKeysPerSearch, *maxTagValuesPerSearch)
-	if err != nil {
-		return nil, fmt.Errorf("error during label entries request: %s", err)
+// GetTagValueSuffixes returns tag value suffixes for the given tenant, tagKey and tagValuePrefix until the given deadline.
+//
+// Suffixes are cut after the first delimiter, so they contain only the next node of hierarchical tag values
+// such as Graphite metric paths. Suffixes ending with delimiter have child nodes.
+func GetTagValueSuffixes(at *auth.Token, tagKey, tagValuePrefix string, delimiter byte, deadline Deadline) ([]string, error) {
+	if tagKey == "__name__" {
+		tagKey = ""
+	}
+
+	var suffixes []string
+	if len(storageNodes) > 0 {
+		suffixesSet := make(map[string]struct{})
+		var mLock sync.Mutex
+		err := execOnStorageNodes(func(sn *storageNode) error {
+			ss, err := sn.getTagValueSuffixes(at.AccountID, at.ProjectID, tagKey, tagValuePrefix, delimiter, deadline)
+			if err != nil {
+				return err
+			}
+			mLock.Lock()
+			for _, suffix := range ss {
+				suffixesSet[suffix] = struct{}{}
+			}
+			mLock.Unlock()
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error occurred during tag value suffixes search for tagKey=%q, tagValuePrefix=%q: %s", tagKey, tagValuePrefix, err)
+		}
+		for suffix := range suffixesSet {
+			suffixes = append(suffixes, suffix)
+		}
+	} else {
+		var err error
+		suffixes, err = vmstorage.SearchTagValueSuffixes(at.AccountID, at.ProjectID, []byte(tagKey), []byte(tagValuePrefix), delimiter, *maxTagValuesPerSearch)
+		if err != nil {
+			return nil, fmt.Errorf("error during tag value suffixes search for tagKey=%q, tagValuePrefix=%q: %s", tagKey, tagValuePrefix, err)
+		}
+	}
+	sort.Strings(suffixes)
+	return suffixes, nil
+}
+
+// GetLabelEntries returns all the label entries for the given tenant until the given deadline.
+func GetLabelEntries(at *auth.Token, deadline Deadline) ([]storage.TagEntry, error) {
+	var labelEntries []storage.TagEntry
+	if len(storageNodes) > 0 {
+		labelEntriesMap := make(map[string]map[string]struct{})
+		var mLock sync.Mutex
+		err := execOnStorageNodes(func(sn *storageNode) error {
+			tes, err := sn.getLabelEntries(at.AccountID, at.ProjectID, deadline)
+			if err != nil {
+				return err
+			}
+			mLock.Lock()
+			for _, te := range tes {
+				values := labelEntriesMap[te.Key]
+				if values == nil {
+					values = make(map[string]struct{}, len(te.Values))
+					labelEntriesMap[te.Key] = values
+				}
+				for _, value := range te.Values {
+					values[value] = struct{}{}
+				}
+			}
+			mLock.Unlock()
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error occurred during label entries request: %s", err)
+		}
+		for key, values := range labelEntriesMap {
+			te := storage.TagEntry{
+				Key:    key,
+				Values: make([]string, 0, len(values)),
+			}
+			for value := range values {
+				te.Values = append(te.Values, value)
+			}
+			sort.Strings(te.Values)
+			labelEntries = append(labelEntries, te)
+		}
+	} else {
+		var err error
+		labelEntries, err = vmstorage.SearchTagEntries(at.AccountID, at.ProjectID, *maxTagKeysPerSearch, *maxTagValuesPerSearch)
+		if err != nil {
+			return nil, fmt.Errorf("error during label entries request: %s", err)
+		}
 	}
 
 	// Substitute "" with "__name__"
@@ -430,15 +625,147 @@ func GetLabelEntries(deadline Deadline) ([]storage.TagEntry, error) {
 	return labelEntries, nil
 }
 
-// GetSeriesCount returns the number of unique series.
-func GetSeriesCount(deadline Deadline) (uint64, error) {
-	n, err := vmstorage.GetSeriesCount()
+// GetSeriesCount returns the number of unique series for the given tenant.
+func GetSeriesCount(at *auth.Token, deadline Deadline) (uint64, error) {
+	if len(storageNodes) > 0 {
+		var n uint64
+		err := execOnStorageNodes(func(sn *storageNode) error {
+			nn, err := sn.getSeriesCount(at.AccountID, at.ProjectID, deadline)
+			if err != nil {
+				return err
+			}
+			atomic.AddUint64(&n, nn)
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("error occurred during series count request: %s", err)
+		}
+		return n, nil
+	}
+	n, err := vmstorage.GetSeriesCount(at.AccountID, at.ProjectID)
 	if err != nil {
 		return 0, fmt.Errorf("error during series count request: %s", err)
 	}
 	return n, nil
 }
 
+// GetTSDBStatusForDate returns TSDB status for the given tenant and date.
+//
+// date is the number of days since the Unix epoch. The whole index is used if date is 0.
+// Counts from multiple storage nodes are summed, so they may be inaccurate for entries
+// outside topN on some nodes. LabelValueCountByLabelName is an upper bound, since the same
+// label values may be stored on multiple storage nodes.
+func GetTSDBStatusForDate(at *auth.Token, date uint64, topN int, deadline Deadline) (*storage.TSDBStatus, error) {
+	if len(storageNodes) > 0 {
+		var totalSeries uint64
+		seriesCountByMetricName := make(map[string]uint64)
+		labelValueCountByLabelName := make(map[string]uint64)
+		seriesCountByLabelValuePair := make(map[string]uint64)
+		var mLock sync.Mutex
+		err := execOnStorageNodes(func(sn *storageNode) error {
+			status, err := sn.getTSDBStatusForDate(at.AccountID, at.ProjectID, date, topN, deadline)
+			if err != nil {
+				return err
+			}
+			mLock.Lock()
+			totalSeries += status.TotalSeries
+			mergeTopHeapEntries(seriesCountByMetricName, status.SeriesCountByMetricName)
+			mergeTopHeapEntries(labelValueCountByLabelName, status.LabelValueCountByLabelName)
+			mergeTopHeapEntries(seriesCountByLabelValuePair, status.SeriesCountByLabelValuePair)
+			mLock.Unlock()
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error occurred during tsdb status request: %s", err)
+		}
+		status := &storage.TSDBStatus{
+			TotalSeries:                 totalSeries,
+			SeriesCountByMetricName:     storage.GetTopHeapEntries(seriesCountByMetricName, topN),
+			LabelValueCountByLabelName:  storage.GetTopHeapEntries(labelValueCountByLabelName, topN),
+			SeriesCountByLabelValuePair: storage.GetTopHeapEntries(seriesCountByLabelValuePair, topN),
+		}
+		return status, nil
+	}
+	status, err := vmstorage.GetTSDBStatusForDate(at.AccountID, at.ProjectID, date, topN, uint64(deadline.Deadline.Unix()))
+	if err != nil {
+		return nil, fmt.Errorf("error during tsdb status request: %s", err)
+	}
+	return status, nil
+}
+
+func mergeTopHeapEntries(m map[string]uint64, a []storage.TopHeapEntry) {
+	for _, e := range a {
+		m[e.Name] += e.Count
+	}
+}
+
+// SearchMetricNames returns metric names matching sq until the given deadline.
+//
+// Only the index is searched, so data blocks aren't read.
+func SearchMetricNames(sq *storage.SearchQuery, deadline Deadline) ([]storage.MetricName, error) {
+	if len(storageNodes) > 0 {
+		m := make(map[string]storage.MetricName)
+		var mLock sync.Mutex
+		err := execOnStorageNodes(func(sn *storageNode) error {
+			mns, err := sn.searchMetricNames(sq, deadline)
+			if err != nil {
+				return err
+			}
+			mLock.Lock()
+			for i := range mns {
+				m[string(mns[i].Marshal(nil))] = mns[i]
+			}
+			mLock.Unlock()
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error occurred during metric names search: %s", err)
+		}
+		mns := make([]storage.MetricName, 0, len(m))
+		for _, mn := range m {
+			mns = append(mns, mn)
+		}
+		return mns, nil
+	}
+
+	tfss, err := setupTfss(sq.AccountID, sq.ProjectID, sq.TagFilterss)
+	if err != nil {
+		return nil, err
+	}
+	tr := storage.TimeRange{
+		MinTimestamp: sq.MinTimestamp,
+		MaxTimestamp: sq.MaxTimestamp,
+	}
+	mns, err := vmstorage.SearchMetricNames(tfss, tr, *maxMetricsPerSearch)
+	if err != nil {
+		return nil, fmt.Errorf("error during metric names search: %s", err)
+	}
+	return mns, nil
+}
+
+// execOnStorageNodes runs f in parallel on all the storage nodes.
+//
+// It returns the first error returned from f.
+func execOnStorageNodes(f func(sn *storageNode) error) error {
+	errCh := make(chan error, len(storageNodes))
+	for _, sn := range storageNodes {
+		go func(sn *storageNode) {
+			errCh <- f(sn)
+		}(sn)
+	}
+	var errors []error
+	for i := 0; i < len(storageNodes); i++ {
+		if err := <-errCh; err != nil {
+			errors = append(errors, err)
+		}
+	}
+	if len(errors) > 0 {
+		// Return only the first error, since it has no sense in returning all errors.
+		return errors[0]
+	}
+	return nil
+}
+
 func getStorageSearch() *storage.Search {
 	v := ssPool.Get()
 	if v == nil {
@@ -458,12 +785,30 @@ var ssPool sync.Pool
 
 var missingMetricNamesForMetricID = metrics.NewCounter(`vm_missing_metric_names_for_metric_id_total`)
 
-// ProcessSearchQuery performs sq on storage nodes until the given deadline.
-func ProcessSearchQuery(sq *storage.SearchQuery, deadline Deadline) (*Results, error) {
-	// Setup search.
-	tfss, err := setupTfss(sq.TagFilterss)
+// ExportBlocks calls f for each MetricBlock matching sq until the given deadline.
+//
+// Blocks are passed to f in marshaled form without unpacking, so they may contain samples outside sq time range.
+// f is never called concurrently. mb passed to f is valid only until f returns.
+func ExportBlocks(sq *storage.SearchQuery, deadline Deadline, f func(mb *storage.MetricBlock) error) error {
+	if len(storageNodes) > 0 {
+		var fLock sync.Mutex
+		fc := func(mb *storage.MetricBlock) error {
+			fLock.Lock()
+			defer fLock.Unlock()
+			return f(mb)
+		}
+		err := execOnStorageNodes(func(sn *storageNode) error {
+			return sn.processSearchQuery(sq, fc, deadline)
+		})
+		if err != nil {
+			return fmt.Errorf("error occurred during search: %s", err)
+		}
+		return nil
+	}
+
+	tfss, err := setupTfss(sq.AccountID, sq.ProjectID, sq.TagFilterss)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	tr := storage.TimeRange{
 		MinTimestamp: sq.MinTimestamp,
@@ -477,24 +822,37 @@ func ProcessSearchQuery(sq *storage.SearchQuery, deadline Deadline) (*Results, e
 	defer putStorageSearch(sr)
 	sr.Init(vmstorage.Storage, tfss, tr, *maxMetricsPerSearch)
 
-	tbf := getTmpBlocksFile()
-	m := make(map[string][]tmpBlockAddr)
 	for sr.NextMetricBlock() {
-		addr, err := tbf.WriteBlock(sr.MetricBlock.Block)
-		if err != nil {
-			putTmpBlocksFile(tbf)
-			return nil, fmt.Errorf("cannot write data to temporary blocks file: %s", err)
+		if err := f(&sr.MetricBlock); err != nil {
+			return err
 		}
 		if time.Until(deadline.Deadline) < 0 {
-			putTmpBlocksFile(tbf)
-			return nil, fmt.Errorf("timeout exceeded while fetching data from storage: %s", deadline.Timeout)
+			return fmt.Errorf("timeout exceeded while fetching data from storage: %s", deadline.Timeout)
 		}
-		metricName := sr.MetricBlock.MetricName
-		m[string(metricName)] = append(m[string(metricName)], addr)
 	}
 	if err := sr.Error(); err != nil {
+		return fmt.Errorf("search error: %s", err)
+	}
+	return nil
+}
+
+// ProcessSearchQuery performs sq on storage nodes until the given deadline.
+func ProcessSearchQuery(sq *storage.SearchQuery, deadline Deadline) (*Results, error) {
+	tr := storage.TimeRange{
+		MinTimestamp: sq.MinTimestamp,
+		MaxTimestamp: sq.MaxTimestamp,
+	}
+	tbf := getTmpBlocksFile()
+	m := make(map[string][]tmpBlockAddr)
+	var err error
+	if len(storageNodes) > 0 {
+		err = processSearchQueryRemote(tbf, m, sq, deadline)
+	} else {
+		err = processSearchQueryLocal(tbf, m, sq, tr, deadline)
+	}
+	if err != nil {
 		putTmpBlocksFile(tbf)
-		return nil, fmt.Errorf("search error: %s", err)
+		return nil, err
 	}
 	if err := tbf.Finalize(); err != nil {
 		putTmpBlocksFile(tbf)
@@ -516,6 +874,61 @@ func ProcessSearchQuery(sq *storage.SearchQuery, deadline Deadline) (*Results, e
 	return &rss, nil
 }
 
+func processSearchQueryLocal(tbf *tmpBlocksFile, m map[string][]tmpBlockAddr, sq *storage.SearchQuery, tr storage.TimeRange, deadline Deadline) error {
+	// Setup search.
+	tfss, err := setupTfss(sq.AccountID, sq.ProjectID, sq.TagFilterss)
+	if err != nil {
+		return err
+	}
+
+	vmstorage.WG.Add(1)
+	defer vmstorage.WG.Done()
+
+	sr := getStorageSearch()
+	defer putStorageSearch(sr)
+	sr.Init(vmstorage.Storage, tfss, tr, *maxMetricsPerSearch)
+
+	for sr.NextMetricBlock() {
+		addr, err := tbf.WriteBlock(sr.MetricBlock.Block)
+		if err != nil {
+			return fmt.Errorf("cannot write data to temporary blocks file: %s", err)
+		}
+		if time.Until(deadline.Deadline) < 0 {
+			return fmt.Errorf("timeout exceeded while fetching data from storage: %s", deadline.Timeout)
+		}
+		metricName := sr.MetricBlock.MetricName
+		m[string(metricName)] = append(m[string(metricName)], addr)
+	}
+	if err := sr.Error(); err != nil {
+		return fmt.Errorf("search error: %s", err)
+	}
+	return nil
+}
+
+// processSearchQueryRemote sends sq to all the storage nodes in parallel
+// and merges the returned MetricBlocks into tbf and m.
+func processSearchQueryRemote(tbf *tmpBlocksFile, m map[string][]tmpBlockAddr, sq *storage.SearchQuery, deadline Deadline) error {
+	var mLock sync.Mutex
+	fc := func(mb *storage.MetricBlock) error {
+		mLock.Lock()
+		defer mLock.Unlock()
+		addr, err := tbf.WriteBlock(mb.Block)
+		if err != nil {
+			return fmt.Errorf("cannot write data to temporary blocks file: %s", err)
+		}
+		metricName := mb.MetricName
+		m[string(metricName)] = append(m[string(metricName)], addr)
+		return nil
+	}
+	err := execOnStorageNodes(func(sn *storageNode) error {
+		return sn.processSearchQuery(sq, fc, deadline)
+	})
+	if err != nil {
+		return fmt.Errorf("error occurred during search: %s", err)
+	}
+	return nil
+}
+
 func getResult() *Result {
 	v := rsPool.Get()
 	if v == nil {
@@ -535,10 +948,10 @@ func putResult(rs *Result) {
 
 var rsPool sync.Pool
 
-func setupTfss(tagFilterss [][]storage.TagFilter) ([]*storage.TagFilters, error) {
+func setupTfss(accountID, projectID uint32, tagFilterss [][]storage.TagFilter) ([]*storage.TagFilters, error) {
 	tfss := make([]*storage.TagFilters, 0, len(tagFilterss))
 	for _, tagFilters := range tagFilterss {
-		tfs := storage.NewTagFilters()
+		tfs := storage.NewTagFilters(accountID, projectID)
 		for i := range tagFilters {
 			tf := &tagFilters[i]
 			if err := tfs.Add(tf.Key, tf.Value, tf.IsNegative, tf.IsRegexp); err != nil {
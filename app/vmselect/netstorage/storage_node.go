@@ -0,0 +1,716 @@
+package netstorage
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/handshake"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// storageNode is a client sending vmselect requests to a single vmstorage node.
+type storageNode struct {
+	addr string
+
+	connsLock sync.Mutex
+	conns     []*handshake.BufferedConn
+
+	// The number of RPC requests to the node.
+	requests *metrics.Counter
+
+	// The number of RPC errors for the node.
+	requestErrors *metrics.Counter
+
+	// The number of MetricBlocks read from the node.
+	metricBlocksRead *metrics.Counter
+}
+
+var storageNodes []*storageNode
+
+// InitStorageNodes initializes storage nodes' connections to the given addrs.
+//
+// The local vmstorage is queried if addrs is empty.
+func InitStorageNodes(addrs []string) {
+	for _, addr := range addrs {
+		sn := &storageNode{
+			addr: addr,
+
+			requests:         metrics.NewCounter(fmt.Sprintf(`vm_rpc_requests_total{name="vmselect", addr=%q}`, addr)),
+			requestErrors:    metrics.NewCounter(fmt.Sprintf(`vm_rpc_request_errors_total{name="vmselect", addr=%q}`, addr)),
+			metricBlocksRead: metrics.NewCounter(fmt.Sprintf(`vm_metric_blocks_read_total{name="vmselect", addr=%q}`, addr)),
+		}
+		storageNodes = append(storageNodes, sn)
+	}
+	if len(storageNodes) > 0 {
+		logger.Infof("initialized %d vmstorage nodes for vmselect: %q", len(storageNodes), addrs)
+	}
+}
+
+// Stop gracefully stops netstorage.
+func Stop() {
+	for _, sn := range storageNodes {
+		sn.closeConns()
+	}
+}
+
+// remoteError is an error returned by vmstorage.
+//
+// The connection remains usable after remoteError.
+type remoteError struct {
+	msg string
+}
+
+func (re *remoteError) Error() string {
+	return re.msg
+}
+
+func (sn *storageNode) getConn() (*handshake.BufferedConn, error) {
+	sn.connsLock.Lock()
+	if n := len(sn.conns); n > 0 {
+		bc := sn.conns[n-1]
+		sn.conns[n-1] = nil
+		sn.conns = sn.conns[:n-1]
+		sn.connsLock.Unlock()
+		return bc, nil
+	}
+	sn.connsLock.Unlock()
+
+	c, err := net.DialTimeout("tcp4", sn.addr, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("cannot dial %q: %s", sn.addr, err)
+	}
+	bc, err := handshake.VMSelectClient(c)
+	if err != nil {
+		_ = c.Close()
+		return nil, fmt.Errorf("handshake error with %q: %s", sn.addr, err)
+	}
+	return bc, nil
+}
+
+func (sn *storageNode) putConn(bc *handshake.BufferedConn) {
+	sn.connsLock.Lock()
+	sn.conns = append(sn.conns, bc)
+	sn.connsLock.Unlock()
+}
+
+func (sn *storageNode) closeConns() {
+	sn.connsLock.Lock()
+	for _, bc := range sn.conns {
+		_ = bc.Close()
+	}
+	sn.conns = nil
+	sn.connsLock.Unlock()
+}
+
+// execOnConn sends rpcName to sn and calls f for sending request args
+// and reading the response.
+func (sn *storageNode) execOnConn(rpcName string, f func(bc *handshake.BufferedConn) error, deadline Deadline) error {
+	sn.requests.Inc()
+	err := sn.execOnConnInternal(rpcName, f, deadline)
+	if err != nil {
+		sn.requestErrors.Inc()
+		return fmt.Errorf("error when executing %q on vmstorage %q: %s", rpcName, sn.addr, err)
+	}
+	return nil
+}
+
+func (sn *storageNode) execOnConnInternal(rpcName string, f func(bc *handshake.BufferedConn) error, deadline Deadline) error {
+	bc, err := sn.getConn()
+	if err != nil {
+		return err
+	}
+	if err := bc.SetDeadline(deadline.Deadline); err != nil {
+		_ = bc.Close()
+		return fmt.Errorf("cannot set connection deadline: %s", err)
+	}
+	if err := writeBytes(bc, []byte(rpcName)); err != nil {
+		_ = bc.Close()
+		return fmt.Errorf("cannot send rpcName: %s", err)
+	}
+	if err := f(bc); err != nil {
+		if re, ok := err.(*remoteError); ok {
+			// The connection is in the consistent state after the remote error,
+			// so it may be re-used.
+			if bc.SetDeadline(time.Time{}) == nil {
+				sn.putConn(bc)
+				return re
+			}
+		}
+		_ = bc.Close()
+		return err
+	}
+	if err := bc.SetDeadline(time.Time{}); err != nil {
+		_ = bc.Close()
+		return fmt.Errorf("cannot reset connection deadline: %s", err)
+	}
+	sn.putConn(bc)
+	return nil
+}
+
+func (sn *storageNode) deleteMetrics(sq *storage.SearchQuery, deadline Deadline) (int, error) {
+	var deletedCount int
+	f := func(bc *handshake.BufferedConn) error {
+		n, err := deleteMetricsOnConn(bc, sq)
+		if err != nil {
+			return err
+		}
+		deletedCount = n
+		return nil
+	}
+	if err := sn.execOnConn("deleteMetrics_v1", f, deadline); err != nil {
+		return deletedCount, err
+	}
+	return deletedCount, nil
+}
+
+func (sn *storageNode) deleteSamples(sq *storage.SearchQuery, deadline Deadline) (int, error) {
+	var deletedCount int
+	f := func(bc *handshake.BufferedConn) error {
+		n, err := deleteMetricsOnConn(bc, sq)
+		if err != nil {
+			return err
+		}
+		deletedCount = n
+		return nil
+	}
+	if err := sn.execOnConn("deleteSamples_v1", f, deadline); err != nil {
+		return deletedCount, err
+	}
+	return deletedCount, nil
+}
+
+func (sn *storageNode) getLabels(accountID, projectID uint32, deadline Deadline) ([]string, error) {
+	var labels []string
+	f := func(bc *handshake.BufferedConn) error {
+		ls, err := getLabelsOnConn(bc, accountID, projectID)
+		if err != nil {
+			return err
+		}
+		labels = ls
+		return nil
+	}
+	if err := sn.execOnConn("labels", f, deadline); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+func (sn *storageNode) getLabelValues(accountID, projectID uint32, labelName string, deadline Deadline) ([]string, error) {
+	var labelValues []string
+	f := func(bc *handshake.BufferedConn) error {
+		lvs, err := getLabelValuesOnConn(bc, accountID, projectID, labelName)
+		if err != nil {
+			return err
+		}
+		labelValues = lvs
+		return nil
+	}
+	if err := sn.execOnConn("labelValues", f, deadline); err != nil {
+		return nil, err
+	}
+	return labelValues, nil
+}
+
+func (sn *storageNode) getTagValueSuffixes(accountID, projectID uint32, tagKey, tagValuePrefix string, delimiter byte, deadline Deadline) ([]string, error) {
+	var suffixes []string
+	f := func(bc *handshake.BufferedConn) error {
+		ss, err := getTagValueSuffixesOnConn(bc, accountID, projectID, tagKey, tagValuePrefix, delimiter)
+		if err != nil {
+			return err
+		}
+		suffixes = ss
+		return nil
+	}
+	if err := sn.execOnConn("tagValueSuffixes", f, deadline); err != nil {
+		return nil, err
+	}
+	return suffixes, nil
+}
+
+func (sn *storageNode) getLabelEntries(accountID, projectID uint32, deadline Deadline) ([]storage.TagEntry, error) {
+	var tagEntries []storage.TagEntry
+	f := func(bc *handshake.BufferedConn) error {
+		tes, err := getLabelEntriesOnConn(bc, accountID, projectID)
+		if err != nil {
+			return err
+		}
+		tagEntries = tes
+		return nil
+	}
+	if err := sn.execOnConn("labelEntries", f, deadline); err != nil {
+		return nil, err
+	}
+	return tagEntries, nil
+}
+
+func (sn *storageNode) getSeriesCount(accountID, projectID uint32, deadline Deadline) (uint64, error) {
+	var n uint64
+	f := func(bc *handshake.BufferedConn) error {
+		nn, err := getSeriesCountOnConn(bc, accountID, projectID)
+		if err != nil {
+			return err
+		}
+		n = nn
+		return nil
+	}
+	if err := sn.execOnConn("seriesCount", f, deadline); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (sn *storageNode) getTSDBStatusForDate(accountID, projectID uint32, date uint64, topN int, deadline Deadline) (*storage.TSDBStatus, error) {
+	var status *storage.TSDBStatus
+	f := func(bc *handshake.BufferedConn) error {
+		st, err := getTSDBStatusForDateOnConn(bc, accountID, projectID, date, topN, deadline)
+		if err != nil {
+			return err
+		}
+		status = st
+		return nil
+	}
+	if err := sn.execOnConn("tsdbStatus", f, deadline); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (sn *storageNode) searchMetricNames(sq *storage.SearchQuery, deadline Deadline) ([]storage.MetricName, error) {
+	var mns []storage.MetricName
+	f := func(bc *handshake.BufferedConn) error {
+		result, err := searchMetricNamesOnConn(bc, sq)
+		if err != nil {
+			return err
+		}
+		mns = result
+		return nil
+	}
+	if err := sn.execOnConn("searchMetricNames_v1", f, deadline); err != nil {
+		return nil, err
+	}
+	return mns, nil
+}
+
+func (sn *storageNode) processSearchQuery(sq *storage.SearchQuery, fc func(mb *storage.MetricBlock) error, deadline Deadline) error {
+	f := func(bc *handshake.BufferedConn) error {
+		blocksRead, err := processSearchQueryOnConn(bc, sq, fc)
+		sn.metricBlocksRead.Add(blocksRead)
+		return err
+	}
+	return sn.execOnConn("search_v1", f, deadline)
+}
+
+// maxErrorMessageSize is the maximum size of error message received
+// from vmstorage.
+const maxErrorMessageSize = 64 * 1024
+
+func readErrorMessage(bc *handshake.BufferedConn) error {
+	buf, err := readBytes(nil, bc, maxErrorMessageSize)
+	if err != nil {
+		return fmt.Errorf("cannot read error message: %s", err)
+	}
+	if len(buf) > 0 {
+		return &remoteError{
+			msg: string(buf),
+		}
+	}
+	return nil
+}
+
+func deleteMetricsOnConn(bc *handshake.BufferedConn, sq *storage.SearchQuery) (int, error) {
+	// Send the request to sn
+	if err := writeBytes(bc, sq.Marshal(nil)); err != nil {
+		return 0, fmt.Errorf("cannot send SearchQuery to the server: %s", err)
+	}
+	if err := bc.Flush(); err != nil {
+		return 0, fmt.Errorf("cannot flush request to conn: %s", err)
+	}
+
+	// Read the response
+	if err := readErrorMessage(bc); err != nil {
+		return 0, err
+	}
+	n, err := readUint64(bc)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read deletedCount value: %s", err)
+	}
+	return int(n), nil
+}
+
+const maxLabelSize = 16 * 1024 * 1024
+
+func getLabelsOnConn(bc *handshake.BufferedConn, accountID, projectID uint32) ([]string, error) {
+	// Send the request to sn.
+	if err := writeUint64(bc, uint64(accountID)); err != nil {
+		return nil, fmt.Errorf("cannot send accountID=%d to conn: %s", accountID, err)
+	}
+	if err := writeUint64(bc, uint64(projectID)); err != nil {
+		return nil, fmt.Errorf("cannot send projectID=%d to conn: %s", projectID, err)
+	}
+	if err := writeUint64(bc, uint64(*maxTagKeysPerSearch)); err != nil {
+		return nil, fmt.Errorf("cannot send maxTagKeys=%d to conn: %s", *maxTagKeysPerSearch, err)
+	}
+	if err := bc.Flush(); err != nil {
+		return nil, fmt.Errorf("cannot flush request to conn: %s", err)
+	}
+
+	// Read the response
+	if err := readErrorMessage(bc); err != nil {
+		return nil, err
+	}
+	return readStrings(bc, maxLabelSize)
+}
+
+const maxLabelValueSize = 16 * 1024 * 1024
+
+func getLabelValuesOnConn(bc *handshake.BufferedConn, accountID, projectID uint32, labelName string) ([]string, error) {
+	// Send the request to sn.
+	if err := writeUint64(bc, uint64(accountID)); err != nil {
+		return nil, fmt.Errorf("cannot send accountID=%d to conn: %s", accountID, err)
+	}
+	if err := writeUint64(bc, uint64(projectID)); err != nil {
+		return nil, fmt.Errorf("cannot send projectID=%d to conn: %s", projectID, err)
+	}
+	if err := writeBytes(bc, []byte(labelName)); err != nil {
+		return nil, fmt.Errorf("cannot send labelName=%q to conn: %s", labelName, err)
+	}
+	if err := writeUint64(bc, uint64(*maxTagValuesPerSearch)); err != nil {
+		return nil, fmt.Errorf("cannot send maxTagValues=%d to conn: %s", *maxTagValuesPerSearch, err)
+	}
+	if err := bc.Flush(); err != nil {
+		return nil, fmt.Errorf("cannot flush labelName to conn: %s", err)
+	}
+
+	// Read the response
+	if err := readErrorMessage(bc); err != nil {
+		return nil, err
+	}
+	return readStrings(bc, maxLabelValueSize)
+}
+
+func getTagValueSuffixesOnConn(bc *handshake.BufferedConn, accountID, projectID uint32, tagKey, tagValuePrefix string, delimiter byte) ([]string, error) {
+	// Send the request to sn.
+	if err := writeUint64(bc, uint64(accountID)); err != nil {
+		return nil, fmt.Errorf("cannot send accountID=%d to conn: %s", accountID, err)
+	}
+	if err := writeUint64(bc, uint64(projectID)); err != nil {
+		return nil, fmt.Errorf("cannot send projectID=%d to conn: %s", projectID, err)
+	}
+	if err := writeBytes(bc, []byte(tagKey)); err != nil {
+		return nil, fmt.Errorf("cannot send tagKey=%q to conn: %s", tagKey, err)
+	}
+	if err := writeBytes(bc, []byte(tagValuePrefix)); err != nil {
+		return nil, fmt.Errorf("cannot send tagValuePrefix=%q to conn: %s", tagValuePrefix, err)
+	}
+	if err := writeBytes(bc, []byte{delimiter}); err != nil {
+		return nil, fmt.Errorf("cannot send delimiter=%q to conn: %s", delimiter, err)
+	}
+	if err := writeUint64(bc, uint64(*maxTagValuesPerSearch)); err != nil {
+		return nil, fmt.Errorf("cannot send maxTagValueSuffixes=%d to conn: %s", *maxTagValuesPerSearch, err)
+	}
+	if err := bc.Flush(); err != nil {
+		return nil, fmt.Errorf("cannot flush request to conn: %s", err)
+	}
+
+	// Read the response
+	if err := readErrorMessage(bc); err != nil {
+		return nil, err
+	}
+	return readStrings(bc, maxLabelValueSize)
+}
+
+func getLabelEntriesOnConn(bc *handshake.BufferedConn, accountID, projectID uint32) ([]storage.TagEntry, error) {
+	// Send the request to sn.
+	if err := writeUint64(bc, uint64(accountID)); err != nil {
+		return nil, fmt.Errorf("cannot send accountID=%d to conn: %s", accountID, err)
+	}
+	if err := writeUint64(bc, uint64(projectID)); err != nil {
+		return nil, fmt.Errorf("cannot send projectID=%d to conn: %s", projectID, err)
+	}
+	if err := writeUint64(bc, uint64(*maxTagKeysPerSearch)); err != nil {
+		return nil, fmt.Errorf("cannot send maxTagKeys=%d to conn: %s", *maxTagKeysPerSearch, err)
+	}
+	if err := writeUint64(bc, uint64(*maxTagValuesPerSearch)); err != nil {
+		return nil, fmt.Errorf("cannot send maxTagValues=%d to conn: %s", *maxTagValuesPerSearch, err)
+	}
+	if err := bc.Flush(); err != nil {
+		return nil, fmt.Errorf("cannot flush request to conn: %s", err)
+	}
+
+	// Read the response
+	if err := readErrorMessage(bc); err != nil {
+		return nil, err
+	}
+	n, err := readUint64(bc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the number of label entries: %s", err)
+	}
+	var labelEntries []storage.TagEntry
+	for i := uint64(0); i < n; i++ {
+		buf, err := readBytes(nil, bc, maxLabelSize)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read label: %s", err)
+		}
+		label := string(buf)
+		values, err := readStrings(bc, maxLabelValueSize)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read values for label %q: %s", label, err)
+		}
+		labelEntries = append(labelEntries, storage.TagEntry{
+			Key:    label,
+			Values: values,
+		})
+	}
+	return labelEntries, nil
+}
+
+func getSeriesCountOnConn(bc *handshake.BufferedConn, accountID, projectID uint32) (uint64, error) {
+	// Send the request to sn.
+	if err := writeUint64(bc, uint64(accountID)); err != nil {
+		return 0, fmt.Errorf("cannot send accountID=%d to conn: %s", accountID, err)
+	}
+	if err := writeUint64(bc, uint64(projectID)); err != nil {
+		return 0, fmt.Errorf("cannot send projectID=%d to conn: %s", projectID, err)
+	}
+	if err := bc.Flush(); err != nil {
+		return 0, fmt.Errorf("cannot flush request to conn: %s", err)
+	}
+
+	// Read the response
+	if err := readErrorMessage(bc); err != nil {
+		return 0, err
+	}
+	n, err := readUint64(bc)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read series count: %s", err)
+	}
+	return n, nil
+}
+
+func getTSDBStatusForDateOnConn(bc *handshake.BufferedConn, accountID, projectID uint32, date uint64, topN int, deadline Deadline) (*storage.TSDBStatus, error) {
+	// Send the request to sn.
+	if err := writeUint64(bc, uint64(accountID)); err != nil {
+		return nil, fmt.Errorf("cannot send accountID=%d to conn: %s", accountID, err)
+	}
+	if err := writeUint64(bc, uint64(projectID)); err != nil {
+		return nil, fmt.Errorf("cannot send projectID=%d to conn: %s", projectID, err)
+	}
+	if err := writeUint64(bc, date); err != nil {
+		return nil, fmt.Errorf("cannot send date=%d to conn: %s", date, err)
+	}
+	if err := writeUint64(bc, uint64(topN)); err != nil {
+		return nil, fmt.Errorf("cannot send topN=%d to conn: %s", topN, err)
+	}
+	if err := writeUint64(bc, uint64(deadline.Deadline.Unix())); err != nil {
+		return nil, fmt.Errorf("cannot send deadline=%s to conn: %s", deadline.Deadline, err)
+	}
+	if err := bc.Flush(); err != nil {
+		return nil, fmt.Errorf("cannot flush request to conn: %s", err)
+	}
+
+	// Read the response
+	if err := readErrorMessage(bc); err != nil {
+		return nil, err
+	}
+	totalSeries, err := readUint64(bc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read totalSeries: %s", err)
+	}
+	seriesCountByMetricName, err := readTopHeapEntries(bc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read seriesCountByMetricName: %s", err)
+	}
+	labelValueCountByLabelName, err := readTopHeapEntries(bc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read labelValueCountByLabelName: %s", err)
+	}
+	seriesCountByLabelValuePair, err := readTopHeapEntries(bc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read seriesCountByLabelValuePair: %s", err)
+	}
+	status := &storage.TSDBStatus{
+		TotalSeries:                 totalSeries,
+		SeriesCountByMetricName:     seriesCountByMetricName,
+		LabelValueCountByLabelName:  labelValueCountByLabelName,
+		SeriesCountByLabelValuePair: seriesCountByLabelValuePair,
+	}
+	return status, nil
+}
+
+func readTopHeapEntries(bc *handshake.BufferedConn) ([]storage.TopHeapEntry, error) {
+	n, err := readUint64(bc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the number of entries: %s", err)
+	}
+	var a []storage.TopHeapEntry
+	for i := uint64(0); i < n; i++ {
+		buf, err := readBytes(nil, bc, maxLabelSize+maxLabelValueSize)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read entry name: %s", err)
+		}
+		count, err := readUint64(bc)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read entry count: %s", err)
+		}
+		a = append(a, storage.TopHeapEntry{
+			Name:  string(buf),
+			Count: count,
+		})
+	}
+	return a, nil
+}
+
+// maxMetricNameSize is the maximum size of serialized MetricName.
+const maxMetricNameSize = 64 * 1024
+
+func searchMetricNamesOnConn(bc *handshake.BufferedConn, sq *storage.SearchQuery) ([]storage.MetricName, error) {
+	// Send the request to sn.
+	if err := writeBytes(bc, sq.Marshal(nil)); err != nil {
+		return nil, fmt.Errorf("cannot write SearchQuery: %s", err)
+	}
+	if err := writeUint64(bc, uint64(*maxMetricsPerSearch)); err != nil {
+		return nil, fmt.Errorf("cannot send maxMetrics=%d to conn: %s", *maxMetricsPerSearch, err)
+	}
+	if err := bc.Flush(); err != nil {
+		return nil, fmt.Errorf("cannot flush SearchQuery to conn: %s", err)
+	}
+
+	// Read the response
+	if err := readErrorMessage(bc); err != nil {
+		return nil, err
+	}
+	n, err := readUint64(bc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the number of metric names: %s", err)
+	}
+	mns := make([]storage.MetricName, n)
+	var buf []byte
+	for i := range mns {
+		buf, err = readBytes(buf[:0], bc, maxMetricNameSize)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read metric name #%d: %s", i, err)
+		}
+		if err := mns[i].Unmarshal(buf); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal metric name #%d: %s", i, err)
+		}
+	}
+	return mns, nil
+}
+
+// maxMetricBlockSize is the maximum size of serialized MetricBlock.
+const maxMetricBlockSize = 16 * 1024 * 1024
+
+func processSearchQueryOnConn(bc *handshake.BufferedConn, sq *storage.SearchQuery, fc func(mb *storage.MetricBlock) error) (int, error) {
+	// Send the request to sn.
+	if err := writeBytes(bc, sq.Marshal(nil)); err != nil {
+		return 0, fmt.Errorf("cannot write SearchQuery: %s", err)
+	}
+	if err := writeUint64(bc, uint64(*maxMetricsPerSearch)); err != nil {
+		return 0, fmt.Errorf("cannot send maxMetrics=%d to conn: %s", *maxMetricsPerSearch, err)
+	}
+	if err := bc.Flush(); err != nil {
+		return 0, fmt.Errorf("cannot flush SearchQuery to conn: %s", err)
+	}
+
+	// Read response. It may consist of multiple MetricBlocks
+	// followed by an empty block and the error message.
+	blocksRead := 0
+	var buf []byte
+	var mb storage.MetricBlock
+	mb.Block = &storage.Block{}
+	for {
+		var err error
+		buf, err = readBytes(buf[:0], bc, maxMetricBlockSize)
+		if err != nil {
+			return blocksRead, fmt.Errorf("cannot read MetricBlock #%d: %s", blocksRead, err)
+		}
+		if len(buf) == 0 {
+			// Reached the end of the response
+			return blocksRead, readErrorMessage(bc)
+		}
+		tail, err := mb.Unmarshal(buf)
+		if err != nil {
+			return blocksRead, fmt.Errorf("cannot unmarshal MetricBlock #%d: %s", blocksRead, err)
+		}
+		if len(tail) != 0 {
+			return blocksRead, fmt.Errorf("non-empty tail after unmarshaling MetricBlock #%d: (len=%d) %q", blocksRead, len(tail), tail)
+		}
+		blocksRead++
+		if err := fc(&mb); err != nil {
+			return blocksRead, fmt.Errorf("cannot process MetricBlock #%d: %s", blocksRead, err)
+		}
+	}
+}
+
+func readStrings(bc *handshake.BufferedConn, maxItemSize int) ([]string, error) {
+	n, err := readUint64(bc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the number of items: %s", err)
+	}
+	a := make([]string, 0, n)
+	for i := uint64(0); i < n; i++ {
+		buf, err := readBytes(nil, bc, maxItemSize)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read item #%d: %s", i, err)
+		}
+		a = append(a, string(buf))
+	}
+	return a, nil
+}
+
+func writeBytes(w io.Writer, buf []byte) error {
+	if err := writeUint64(w, uint64(len(buf))); err != nil {
+		return fmt.Errorf("cannot write data size: %s", err)
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+	if _, err := w.Write(buf); err != nil {
+		return fmt.Errorf("cannot write data with size %d: %s", len(buf), err)
+	}
+	return nil
+}
+
+func writeUint64(w io.Writer, n uint64) error {
+	var sizeBuf [8]byte
+	if _, err := w.Write(encoding.MarshalUint64(sizeBuf[:0], n)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func readBytes(buf []byte, r io.Reader, maxDataSize int) ([]byte, error) {
+	n, err := readUint64(r)
+	if err != nil {
+		return buf, fmt.Errorf("cannot read data size: %s", err)
+	}
+	if n > uint64(maxDataSize) {
+		return buf, fmt.Errorf("too big data size: %d; it mustn't exceed %d bytes", n, maxDataSize)
+	}
+	if n == 0 {
+		return buf, nil
+	}
+	bufLen := len(buf)
+	if m := bufLen + int(n) - cap(buf); m > 0 {
+		buf = append(buf[:cap(buf)], make([]byte, m)...)
+	}
+	buf = buf[:bufLen+int(n)]
+	if _, err := io.ReadFull(r, buf[bufLen:]); err != nil {
+		return buf, fmt.Errorf("cannot read data with size %d: %s", n, err)
+	}
+	return buf, nil
+}
+
+func readUint64(r io.Reader) (uint64, error) {
+	var sizeBuf [8]byte
+	if _, err := io.ReadFull(r, sizeBuf[:]); err != nil {
+		return 0, err
+	}
+	return encoding.UnmarshalUint64(sizeBuf[:]), nil
+}
@@ -8,18 +8,24 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/promql"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 	"github.com/VictoriaMetrics/metrics"
+	"github.com/golang/snappy"
 	"github.com/valyala/quicktemplate"
 )
 
 var (
-	maxQueryDuration = flag.Duration("search.maxQueryDuration", time.Second*30, "The maximum time for search query execution")
-	maxQueryLen      = flag.Int("search.maxQueryLen", 16*1024, "The maximum search query length in bytes")
+	maxQueryLen              = flag.Int("search.maxQueryLen", 16*1024, "The maximum search query length in bytes")
+	maxRemoteReadRequestSize = flag.Int("search.maxRemoteReadRequestSize", 1024*1024, "The maximum size in bytes of a single remote read request to /api/v1/read")
 )
 
 // Default step used if not set.
@@ -30,7 +36,7 @@ const defaultStep = 5 * 60 * 1000
 const latencyOffset = 60 * 1000
 
 // FederateHandler implements /federate . See https://prometheus.io/docs/prometheus/latest/federation/
-func FederateHandler(w http.ResponseWriter, r *http.Request) error {
+func FederateHandler(at *auth.Token, w http.ResponseWriter, r *http.Request) error {
 	startTime := time.Now()
 	ct := currentTime()
 	if err := r.ParseForm(); err != nil {
@@ -40,7 +46,7 @@ func FederateHandler(w http.ResponseWriter, r *http.Request) error {
 	if len(matches) == 0 {
 		return fmt.Errorf("missing `match[]` arg")
 	}
-	maxLookback, err := getDuration(r, "max_lookback", defaultStep)
+	maxLookback, err := searchutils.GetDuration(r, "max_lookback", defaultStep)
 	if err != nil {
 		return err
 	}
@@ -52,7 +58,7 @@ func FederateHandler(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
-	deadline := getDeadline(r)
+	deadline := searchutils.GetDeadline(r)
 	if start >= end {
 		start = end - defaultStep
 	}
@@ -61,6 +67,8 @@ func FederateHandler(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 	sq := &storage.SearchQuery{
+		AccountID:    at.AccountID,
+		ProjectID:    at.ProjectID,
 		MinTimestamp: start,
 		MaxTimestamp: end,
 		TagFilterss:  tagFilterss,
@@ -74,6 +82,10 @@ func FederateHandler(w http.ResponseWriter, r *http.Request) error {
 	doneCh := make(chan error)
 	go func() {
 		err := rss.RunParallel(func(rs *netstorage.Result) {
+			if len(rs.Values) > 0 && decimal.IsStaleNaN(rs.Values[len(rs.Values)-1]) {
+				// Do not federate stale series like Prometheus does.
+				return
+			}
 			bb := quicktemplate.AcquireByteBuffer()
 			WriteFederate(bb, rs)
 			resultsCh <- bb
@@ -99,44 +111,103 @@ func FederateHandler(w http.ResponseWriter, r *http.Request) error {
 var federateDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/federate"}`)
 
 // ExportHandler exports data in raw format from /api/v1/export.
-func ExportHandler(w http.ResponseWriter, r *http.Request) error {
+func ExportHandler(at *auth.Token, w http.ResponseWriter, r *http.Request) error {
+	startTime := time.Now()
+	matches, start, end, err := getExportParams(r)
+	if err != nil {
+		return err
+	}
+	format := r.FormValue("format")
+	deadline := searchutils.GetDeadline(r)
+	if err := exportHandler(at, w, matches, start, end, format, deadline); err != nil {
+		return err
+	}
+	exportDuration.UpdateDuration(startTime)
+	return nil
+}
+
+var exportDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/export"}`)
+
+// ExportNativeHandler exports data in native format from /api/v1/export/native.
+//
+// The exported data may be imported via /api/v1/import/native.
+func ExportNativeHandler(at *auth.Token, w http.ResponseWriter, r *http.Request) error {
 	startTime := time.Now()
+	matches, start, end, err := getExportParams(r)
+	if err != nil {
+		return err
+	}
+	deadline := searchutils.GetDeadline(r)
+	tagFilterss, err := getTagFilterssFromMatches(matches)
+	if err != nil {
+		return err
+	}
+	sq := &storage.SearchQuery{
+		AccountID:    at.AccountID,
+		ProjectID:    at.ProjectID,
+		MinTimestamp: start,
+		MaxTimestamp: end,
+		TagFilterss:  tagFilterss,
+	}
+	tr := storage.TimeRange{
+		MinTimestamp: start,
+		MaxTimestamp: end,
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	bb := quicktemplate.AcquireByteBuffer()
+	defer quicktemplate.ReleaseByteBuffer(bb)
+	bb.B = storage.MarshalNativeHeader(bb.B[:0], tr)
+	err = netstorage.ExportBlocks(sq, deadline, func(mb *storage.MetricBlock) error {
+		bb.B = storage.MarshalNativeMetricBlock(bb.B, mb)
+		if len(bb.B) < 64*1024 {
+			return nil
+		}
+		_, err := w.Write(bb.B)
+		bb.B = bb.B[:0]
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error during data export: %s", err)
+	}
+	if _, err := w.Write(bb.B); err != nil {
+		return fmt.Errorf("cannot send exported data to the client: %s", err)
+	}
+	exportNativeDuration.UpdateDuration(startTime)
+	return nil
+}
+
+var exportNativeDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/export/native"}`)
+
+func getExportParams(r *http.Request) ([]string, int64, int64, error) {
 	ct := currentTime()
 	if err := r.ParseForm(); err != nil {
-		return fmt.Errorf("cannot parse request form values: %s", err)
+		return nil, 0, 0, fmt.Errorf("cannot parse request form values: %s", err)
 	}
 	matches := r.Form["match[]"]
 	if len(matches) == 0 {
 		// Maintain backwards compatibility
 		match := r.FormValue("match")
 		if len(match) == 0 {
-			return fmt.Errorf("missing `match[]` arg")
+			return nil, 0, 0, fmt.Errorf("missing `match[]` arg")
 		}
 		matches = []string{match}
 	}
 	start, err := getTime(r, "start", 0)
 	if err != nil {
-		return err
+		return nil, 0, 0, err
 	}
 	end, err := getTime(r, "end", ct)
 	if err != nil {
-		return err
+		return nil, 0, 0, err
 	}
-	format := r.FormValue("format")
-	deadline := getDeadline(r)
 	if start >= end {
 		start = end - defaultStep
 	}
-	if err := exportHandler(w, matches, start, end, format, deadline); err != nil {
-		return err
-	}
-	exportDuration.UpdateDuration(startTime)
-	return nil
+	return matches, start, end, nil
 }
 
-var exportDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/export"}`)
-
-func exportHandler(w http.ResponseWriter, matches []string, start, end int64, format string, deadline netstorage.Deadline) error {
+func exportHandler(at *auth.Token, w http.ResponseWriter, matches []string, start, end int64, format string, deadline netstorage.Deadline) error {
 	writeResponseFunc := WriteExportStdResponse
 	writeLineFunc := WriteExportJSONLine
 	contentType := "application/json"
@@ -153,6 +224,8 @@ func exportHandler(w http.ResponseWriter, matches []string, start, end int64, fo
 		return err
 	}
 	sq := &storage.SearchQuery{
+		AccountID:    at.AccountID,
+		ProjectID:    at.ProjectID,
 		MinTimestamp: start,
 		MaxTimestamp: end,
 		TagFilterss:  tagFilterss,
@@ -166,6 +239,7 @@ func exportHandler(w http.ResponseWriter, matches []string, start, end int64, fo
 	doneCh := make(chan error)
 	go func() {
 		err := rss.RunParallel(func(rs *netstorage.Result) {
+			dropStaleNaNs(rs)
 			bb := quicktemplate.AcquireByteBuffer()
 			writeLineFunc(bb, rs)
 			resultsCh <- bb
@@ -189,17 +263,165 @@ func exportHandler(w http.ResponseWriter, matches []string, start, end int64, fo
 	return nil
 }
 
+// dropStaleNaNs removes Prometheus staleness marks from rs, since text export formats cannot represent them.
+//
+// Staleness marks are preserved by /api/v1/export/native and /api/v1/read.
+func dropStaleNaNs(rs *netstorage.Result) {
+	hasStaleNaNs := false
+	for _, v := range rs.Values {
+		if decimal.IsStaleNaN(v) {
+			hasStaleNaNs = true
+			break
+		}
+	}
+	if !hasStaleNaNs {
+		// Fast path - nothing to drop.
+		return
+	}
+	values := rs.Values[:0]
+	timestamps := rs.Timestamps[:0]
+	for i, v := range rs.Values {
+		if decimal.IsStaleNaN(v) {
+			continue
+		}
+		values = append(values, v)
+		timestamps = append(timestamps, rs.Timestamps[i])
+	}
+	rs.Values = values
+	rs.Timestamps = timestamps
+}
+
+// RemoteReadHandler processes /api/v1/read request from Prometheus.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#remote_read
+func RemoteReadHandler(at *auth.Token, w http.ResponseWriter, r *http.Request) error {
+	startTime := time.Now()
+	reqBuf, err := prompb.ReadSnappy(nil, r.Body, int64(*maxRemoteReadRequestSize))
+	if err != nil {
+		return fmt.Errorf("cannot read prompb.ReadRequest: %s", err)
+	}
+	var req prompb.ReadRequest
+	if err := req.Unmarshal(reqBuf); err != nil {
+		return fmt.Errorf("cannot unmarshal prompb.ReadRequest with size %d bytes: %s", len(reqBuf), err)
+	}
+	deadline := searchutils.GetDeadline(r)
+	resp := &prompb.ReadResponse{
+		Results: make([]prompb.QueryResult, len(req.Queries)),
+	}
+	for i := range req.Queries {
+		q := &req.Queries[i]
+		if err := remoteReadQuery(at, &resp.Results[i], q, deadline); err != nil {
+			return err
+		}
+	}
+
+	respBuf := resp.Marshal(nil)
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set("Content-Encoding", "snappy")
+	w.Write(snappy.Encode(nil, respBuf))
+	remoteReadDuration.UpdateDuration(startTime)
+	return nil
+}
+
+var remoteReadDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/read"}`)
+
+func remoteReadQuery(at *auth.Token, dst *prompb.QueryResult, q *prompb.Query, deadline netstorage.Deadline) error {
+	tagFilters, err := getTagFiltersFromMatchers(q.Matchers)
+	if err != nil {
+		return err
+	}
+	sq := &storage.SearchQuery{
+		AccountID:    at.AccountID,
+		ProjectID:    at.ProjectID,
+		MinTimestamp: q.StartTimestampMs,
+		MaxTimestamp: q.EndTimestampMs,
+		TagFilterss:  [][]storage.TagFilter{tagFilters},
+	}
+	rss, err := netstorage.ProcessSearchQuery(sq, deadline)
+	if err != nil {
+		return fmt.Errorf("cannot fetch data for %q: %s", sq, err)
+	}
+
+	var mu sync.Mutex
+	err = rss.RunParallel(func(rs *netstorage.Result) {
+		if len(rs.Timestamps) == 0 {
+			return
+		}
+		var ts prompb.TimeSeries
+		ts.Labels = appendPrompbLabels(ts.Labels[:0], &rs.MetricName)
+		ts.Samples = make([]prompb.Sample, len(rs.Timestamps))
+		for i, timestamp := range rs.Timestamps {
+			s := &ts.Samples[i]
+			s.Timestamp = timestamp
+			s.Value = rs.Values[i]
+		}
+		mu.Lock()
+		dst.Timeseries = append(dst.Timeseries, ts)
+		mu.Unlock()
+	})
+	if err != nil {
+		return fmt.Errorf("error during data fetching: %s", err)
+	}
+	return nil
+}
+
+// appendPrompbLabels appends labels from mn to dst and returns the result.
+//
+// The returned labels don't refer to mn, so mn may be re-used.
+func appendPrompbLabels(dst []prompb.Label, mn *storage.MetricName) []prompb.Label {
+	dst = append(dst, prompb.Label{
+		Name:  []byte("__name__"),
+		Value: append([]byte{}, mn.MetricGroup...),
+	})
+	for i := range mn.Tags {
+		tag := &mn.Tags[i]
+		dst = append(dst, prompb.Label{
+			Name:  append([]byte{}, tag.Key...),
+			Value: append([]byte{}, tag.Value...),
+		})
+	}
+	return dst
+}
+
+func getTagFiltersFromMatchers(matchers []prompb.LabelMatcher) ([]storage.TagFilter, error) {
+	if len(matchers) == 0 {
+		return nil, fmt.Errorf("matchers cannot be empty")
+	}
+	tagFilters := make([]storage.TagFilter, 0, len(matchers))
+	for i := range matchers {
+		m := &matchers[i]
+		var tf storage.TagFilter
+		if string(m.Name) != "__name__" {
+			// MetricGroup must be encoded with nil key for storage.Search.
+			tf.Key = m.Name
+		}
+		tf.Value = m.Value
+		switch m.Type {
+		case prompb.LabelMatcher_EQ:
+		case prompb.LabelMatcher_NEQ:
+			tf.IsNegative = true
+		case prompb.LabelMatcher_RE:
+			tf.IsRegexp = true
+		case prompb.LabelMatcher_NRE:
+			tf.IsNegative = true
+			tf.IsRegexp = true
+		default:
+			return nil, fmt.Errorf("unsupported label matcher type %s for %q", m.Type, m.Name)
+		}
+		tagFilters = append(tagFilters, tf)
+	}
+	return tagFilters, nil
+}
+
 // DeleteHandler processes /api/v1/admin/tsdb/delete_series prometheus API request.
 //
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#delete-series
-func DeleteHandler(r *http.Request) error {
+func DeleteHandler(at *auth.Token, r *http.Request) error {
 	startTime := time.Now()
+	deadline := searchutils.GetDeadline(r)
 	if err := r.ParseForm(); err != nil {
 		return fmt.Errorf("cannot parse request form values: %s", err)
 	}
-	if r.FormValue("start") != "" || r.FormValue("end") != "" {
-		return fmt.Errorf("start and end aren't supported. Remove these args from the query in order to delete all the matching metrics")
-	}
 	matches := r.Form["match[]"]
 	if len(matches) == 0 {
 		return fmt.Errorf("missing `match[]` arg")
@@ -209,11 +431,36 @@ func DeleteHandler(r *http.Request) error {
 		return err
 	}
 	sq := &storage.SearchQuery{
+		AccountID:   at.AccountID,
+		ProjectID:   at.ProjectID,
 		TagFilterss: tagFilterss,
 	}
-	deletedCount, err := netstorage.DeleteSeries(sq)
-	if err != nil {
-		return fmt.Errorf("cannot delete time series matching %q: %s", matches, err)
+	var deletedCount int
+	if r.FormValue("start") == "" && r.FormValue("end") == "" {
+		// Delete all the matching time series.
+		deletedCount, err = netstorage.DeleteSeries(sq, deadline)
+		if err != nil {
+			return fmt.Errorf("cannot delete time series matching %q: %s", matches, err)
+		}
+	} else {
+		// Delete samples on the given time range for the matching time series.
+		start, err := getTime(r, "start", minTimeMsecs)
+		if err != nil {
+			return err
+		}
+		end, err := getTime(r, "end", maxTimeMsecs)
+		if err != nil {
+			return err
+		}
+		if start > end {
+			return fmt.Errorf("start=%d cannot exceed end=%d", start, end)
+		}
+		sq.MinTimestamp = start
+		sq.MaxTimestamp = end
+		deletedCount, err = netstorage.DeleteSamples(sq, deadline)
+		if err != nil {
+			return fmt.Errorf("cannot delete samples on the time range [%d..%d] for time series matching %q: %s", start, end, matches, err)
+		}
 	}
 	if deletedCount > 0 {
 		promql.ResetRollupResultCache()
@@ -227,10 +474,10 @@ var deleteDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/
 // LabelValuesHandler processes /api/v1/label/<labelName>/values request.
 //
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#querying-label-values
-func LabelValuesHandler(labelName string, w http.ResponseWriter, r *http.Request) error {
+func LabelValuesHandler(at *auth.Token, labelName string, w http.ResponseWriter, r *http.Request) error {
 	startTime := time.Now()
-	deadline := getDeadline(r)
-	labelValues, err := netstorage.GetLabelValues(labelName, deadline)
+	deadline := searchutils.GetDeadline(r)
+	labelValues, err := netstorage.GetLabelValues(at, labelName, deadline)
 	if err != nil {
 		return fmt.Errorf(`cannot obtain label values for %q: %s`, labelName, err)
 	}
@@ -244,10 +491,10 @@ func LabelValuesHandler(labelName string, w http.ResponseWriter, r *http.Request
 var labelValuesDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/label/{}/values"}`)
 
 // LabelsCountHandler processes /api/v1/labels/count request.
-func LabelsCountHandler(w http.ResponseWriter, r *http.Request) error {
+func LabelsCountHandler(at *auth.Token, w http.ResponseWriter, r *http.Request) error {
 	startTime := time.Now()
-	deadline := getDeadline(r)
-	labelEntries, err := netstorage.GetLabelEntries(deadline)
+	deadline := searchutils.GetDeadline(r)
+	labelEntries, err := netstorage.GetLabelEntries(at, deadline)
 	if err != nil {
 		return fmt.Errorf(`cannot obtain label entries: %s`, err)
 	}
@@ -263,10 +510,10 @@ var labelsCountDuration = metrics.NewSummary(`vm_request_duration_seconds{path="
 // LabelsHandler processes /api/v1/labels request.
 //
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#getting-label-names
-func LabelsHandler(w http.ResponseWriter, r *http.Request) error {
+func LabelsHandler(at *auth.Token, w http.ResponseWriter, r *http.Request) error {
 	startTime := time.Now()
-	deadline := getDeadline(r)
-	labels, err := netstorage.GetLabels(deadline)
+	deadline := searchutils.GetDeadline(r)
+	labels, err := netstorage.GetLabels(at, deadline)
 	if err != nil {
 		return fmt.Errorf("cannot obtain labels: %s", err)
 	}
@@ -280,10 +527,10 @@ func LabelsHandler(w http.ResponseWriter, r *http.Request) error {
 var labelsDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/labels"}`)
 
 // SeriesCountHandler processes /api/v1/series/count request.
-func SeriesCountHandler(w http.ResponseWriter, r *http.Request) error {
+func SeriesCountHandler(at *auth.Token, w http.ResponseWriter, r *http.Request) error {
 	startTime := time.Now()
-	deadline := getDeadline(r)
-	n, err := netstorage.GetSeriesCount(deadline)
+	deadline := searchutils.GetDeadline(r)
+	n, err := netstorage.GetSeriesCount(at, deadline)
 	if err != nil {
 		return fmt.Errorf("cannot obtain series count: %s", err)
 	}
@@ -295,10 +542,59 @@ func SeriesCountHandler(w http.ResponseWriter, r *http.Request) error {
 
 var seriesCountDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/series/count"}`)
 
+// TSDBStatusHandler processes /api/v1/status/tsdb request.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#tsdb-stats
+//
+// It accepts the following optional query args:
+//   - date=YYYY-MM-DD - return stats only for series with samples for the given date. Stats for the whole index are returned by default.
+//   - topN=N - the number of top entries to return in each list. By default 10 entries are returned.
+func TSDBStatusHandler(at *auth.Token, w http.ResponseWriter, r *http.Request) error {
+	startTime := time.Now()
+	deadline := searchutils.GetDeadline(r)
+	if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("cannot parse form values: %s", err)
+	}
+	var date uint64
+	if dateStr := r.FormValue("date"); len(dateStr) > 0 {
+		t, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			return fmt.Errorf("cannot parse `date` arg %q: %s", dateStr, err)
+		}
+		if t.Unix() <= 0 {
+			return fmt.Errorf("`date` arg must be bigger than 1970-01-01; got %q", dateStr)
+		}
+		date = uint64(t.Unix()) / (24 * 3600)
+	}
+	topN := 10
+	if topNStr := r.FormValue("topN"); len(topNStr) > 0 {
+		n, err := strconv.Atoi(topNStr)
+		if err != nil {
+			return fmt.Errorf("cannot parse `topN` arg %q: %s", topNStr, err)
+		}
+		if n <= 0 || n > maxTSDBStatusTopN {
+			return fmt.Errorf("`topN` arg must be in the range [1..%d]; got %d", maxTSDBStatusTopN, n)
+		}
+		topN = n
+	}
+	status, err := netstorage.GetTSDBStatusForDate(at, date, topN, deadline)
+	if err != nil {
+		return fmt.Errorf("cannot obtain tsdb status for date=%d, topN=%d: %s", date, topN, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	WriteTSDBStatusResponse(w, status)
+	tsdbStatusDuration.UpdateDuration(startTime)
+	return nil
+}
+
+const maxTSDBStatusTopN = 1000
+
+var tsdbStatusDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/status/tsdb"}`)
+
 // SeriesHandler processes /api/v1/series request.
 //
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#finding-series-by-label-matchers
-func SeriesHandler(w http.ResponseWriter, r *http.Request) error {
+func SeriesHandler(at *auth.Token, w http.ResponseWriter, r *http.Request) error {
 	startTime := time.Now()
 	ct := currentTime()
 
@@ -317,7 +613,7 @@ func SeriesHandler(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
-	deadline := getDeadline(r)
+	deadline := searchutils.GetDeadline(r)
 
 	tagFilterss, err := getTagFilterssFromMatches(matches)
 	if err != nil {
@@ -327,6 +623,8 @@ func SeriesHandler(w http.ResponseWriter, r *http.Request) error {
 		start = end - defaultStep
 	}
 	sq := &storage.SearchQuery{
+		AccountID:    at.AccountID,
+		ProjectID:    at.ProjectID,
 		MinTimestamp: start,
 		MaxTimestamp: end,
 		TagFilterss:  tagFilterss,
@@ -369,7 +667,7 @@ var seriesDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/
 // QueryHandler processes /api/v1/query request.
 //
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#instant-queries
-func QueryHandler(w http.ResponseWriter, r *http.Request) error {
+func QueryHandler(at *auth.Token, w http.ResponseWriter, r *http.Request) error {
 	startTime := time.Now()
 	ct := currentTime()
 
@@ -381,11 +679,11 @@ func QueryHandler(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
-	step, err := getDuration(r, "step", latencyOffset)
+	step, err := searchutils.GetDuration(r, "step", latencyOffset)
 	if err != nil {
 		return err
 	}
-	deadline := getDeadline(r)
+	deadline := searchutils.GetDeadline(r)
 
 	if len(query) > *maxQueryLen {
 		return fmt.Errorf(`too long query; got %d bytes; mustn't exceed %d bytes`, len(query), *maxQueryLen)
@@ -413,7 +711,7 @@ func QueryHandler(w http.ResponseWriter, r *http.Request) error {
 		start -= offset
 		end := start
 		start = end - window
-		if err := exportHandler(w, []string{childQuery}, start, end, "promapi", deadline); err != nil {
+		if err := exportHandler(at, w, []string{childQuery}, start, end, "promapi", deadline); err != nil {
 			return err
 		}
 		queryDuration.UpdateDuration(startTime)
@@ -421,10 +719,11 @@ func QueryHandler(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	ec := promql.EvalConfig{
-		Start:    start,
-		End:      start,
-		Step:     step,
-		Deadline: deadline,
+		AuthToken: at,
+		Start:     start,
+		End:       start,
+		Step:      step,
+		Deadline:  deadline,
 	}
 	result, err := promql.Exec(&ec, query, true)
 	if err != nil {
@@ -442,7 +741,7 @@ var queryDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v
 // QueryRangeHandler processes /api/v1/query_range request.
 //
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#range-queries
-func QueryRangeHandler(w http.ResponseWriter, r *http.Request) error {
+func QueryRangeHandler(at *auth.Token, w http.ResponseWriter, r *http.Request) error {
 	startTime := time.Now()
 	ct := currentTime()
 
@@ -458,11 +757,11 @@ func QueryRangeHandler(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
-	step, err := getDuration(r, "step", defaultStep)
+	step, err := searchutils.GetDuration(r, "step", defaultStep)
 	if err != nil {
 		return err
 	}
-	deadline := getDeadline(r)
+	deadline := searchutils.GetDeadline(r)
 	mayCache := !getBool(r, "nocache")
 
 	// Validate input args.
@@ -478,11 +777,12 @@ func QueryRangeHandler(w http.ResponseWriter, r *http.Request) error {
 	start, end = promql.AdjustStartEnd(start, end, step)
 
 	ec := promql.EvalConfig{
-		Start:    start,
-		End:      end,
-		Step:     step,
-		Deadline: deadline,
-		MayCache: mayCache,
+		AuthToken: at,
+		Start:     start,
+		End:       end,
+		Step:      step,
+		Deadline:  deadline,
+		MayCache:  mayCache,
 	}
 	result, err := promql.Exec(&ec, query, false)
 	if err != nil {
@@ -566,42 +866,6 @@ const (
 	maxTimeMsecs = int64(1<<63-1) / 1e6
 )
 
-func getDuration(r *http.Request, argKey string, defaultValue int64) (int64, error) {
-	argValue := r.FormValue(argKey)
-	if len(argValue) == 0 {
-		return defaultValue, nil
-	}
-	secs, err := strconv.ParseFloat(argValue, 64)
-	if err != nil {
-		// Try parsing string format
-		d, err := time.ParseDuration(argValue)
-		if err != nil {
-			return 0, fmt.Errorf("cannot parse %q=%q: %s", argKey, argValue, err)
-		}
-		secs = d.Seconds()
-	}
-	msecs := int64(secs * 1e3)
-	if msecs <= 0 || msecs > maxDurationMsecs {
-		return 0, fmt.Errorf("%q=%dms is out of allowed range [%d ... %d]", argKey, msecs, 0, int64(maxDurationMsecs))
-	}
-	return msecs, nil
-}
-
-const maxDurationMsecs = 100 * 365 * 24 * 3600 * 1000
-
-func getDeadline(r *http.Request) netstorage.Deadline {
-	d, err := getDuration(r, "timeout", 0)
-	if err != nil {
-		d = 0
-	}
-	dMax := int64(maxQueryDuration.Seconds() * 1e3)
-	if d <= 0 || d > dMax {
-		d = dMax
-	}
-	timeout := time.Duration(d) * time.Millisecond
-	return netstorage.NewDeadline(timeout)
-}
-
 func getBool(r *http.Request, argKey string) bool {
 	argValue := r.FormValue(argKey)
 	switch strings.ToLower(argValue) {
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 )
 
@@ -56,6 +57,10 @@ func TestExecSuccess(t *testing.T) {
 	f := func(q string, resultExpected []netstorage.Result) {
 		t.Helper()
 		ec := &EvalConfig{
+			AuthToken: &auth.Token{
+				AccountID: 123,
+				ProjectID: 567,
+			},
 			Start:    start,
 			End:      end,
 			Step:     step,
@@ -3674,6 +3679,10 @@ func TestExecError(t *testing.T) {
 	f := func(q string) {
 		t.Helper()
 		ec := &EvalConfig{
+			AuthToken: &auth.Token{
+				AccountID: 123,
+				ProjectID: 567,
+			},
 			Start:    1000,
 			End:      2000,
 			Step:     100,
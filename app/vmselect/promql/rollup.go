@@ -108,6 +108,9 @@ type rollupFuncArg struct {
 	values        []float64
 	timestamps    []int64
 
+	// isStale is set if the last sample at or before the current point is Prometheus staleness mark.
+	isStale bool
+
 	idx  int
 	step int64
 }
@@ -117,6 +120,7 @@ func (rfa *rollupFuncArg) reset() {
 	rfa.prevTimestamp = 0
 	rfa.values = nil
 	rfa.timestamps = nil
+	rfa.isStale = false
 	rfa.idx = 0
 	rfa.step = 0
 }
@@ -124,6 +128,7 @@ func (rfa *rollupFuncArg) reset() {
 // rollupFunc must return rollup value for the given rfa.
 //
 // prevValue may be nan, values and timestamps may be empty.
+// values cannot contain Prometheus staleness marks.
 type rollupFunc func(rfa *rollupFuncArg) float64
 
 type rollupConfig struct {
@@ -161,6 +166,10 @@ const maxSilenceInterval = 5 * 60 * 1000
 //
 // timestamps must cover time range [rc.Start - rc.Window - maxSilenceInterval ... rc.End + rc.Step].
 //
+// values may contain Prometheus staleness marks. They aren't passed to rc.Func,
+// but samples preceding them aren't used for filling gaps after them.
+// See https://prometheus.io/docs/prometheus/latest/querying/basics/#staleness
+//
 // Cannot be called from concurrent goroutines.
 func (rc *rollupConfig) Do(dstValues []float64, values []float64, timestamps []int64) []float64 {
 	// Sanity checks.
@@ -180,40 +189,54 @@ func (rc *rollupConfig) Do(dstValues []float64, values []float64, timestamps []i
 	// Extend dstValues in order to remove mallocs below.
 	dstValues = decimal.ExtendFloat64sCapacity(dstValues, len(rc.Timestamps))
 
-	maxPrevInterval := getMaxPrevInterval(timestamps)
-	window := rc.Window
-	if window <= 0 {
-		window = rc.Step
-	}
-	if rc.MayAdjustWindow && window < maxPrevInterval {
-		window = maxPrevInterval
-	}
+	values, timestamps, staleTimestamps := dropStaleNaNs(values, timestamps)
+	defaultMaxPrevInterval := getMaxPrevInterval(timestamps)
 	rfa := getRollupFuncArg()
 	rfa.idx = 0
 	rfa.step = rc.Step
 
-	i := 0
 	j := 0
+	kEnd := 0
 	for _, tEnd := range rc.Timestamps {
-		tStart := tEnd - window
-		n := sort.Search(len(timestamps)-i, func(n int) bool {
-			return timestamps[i+n] > tStart
-		})
-		i += n
-		if j < i {
-			j = i
-		}
-		n = sort.Search(len(timestamps)-j, func(n int) bool {
+		n := sort.Search(len(timestamps)-j, func(n int) bool {
 			return timestamps[j+n] > tEnd
 		})
 		j += n
 
+		// The interval between samples may change over time, i.e. for downsampled old data
+		// followed by recent data with the original resolution. So the interval is calculated
+		// from the samples preceding tEnd.
+		maxPrevInterval := getLocalMaxPrevInterval(timestamps[:j], defaultMaxPrevInterval)
+		window := rc.Window
+		if window <= 0 {
+			window = rc.Step
+		}
+		if rc.MayAdjustWindow && window < maxPrevInterval {
+			window = maxPrevInterval
+		}
+		tStart := tEnd - window
+		i := countTimestampsUpTo(timestamps[:j], tStart)
+
 		rfa.prevValue = nan
 		rfa.prevTimestamp = tStart - maxPrevInterval
 		if i > 0 && timestamps[i-1] > rfa.prevTimestamp {
 			rfa.prevValue = values[i-1]
 			rfa.prevTimestamp = timestamps[i-1]
 		}
+		rfa.isStale = false
+		if len(staleTimestamps) > 0 {
+			kStart := countTimestampsUpTo(staleTimestamps, tStart)
+			if kStart > 0 && i > 0 && staleTimestamps[kStart-1] >= timestamps[i-1] {
+				// The series has been marked as stale after the previous sample,
+				// so the previous sample mustn't be used for filling the gap.
+				rfa.prevValue = nan
+				rfa.prevTimestamp = tStart - maxPrevInterval
+			}
+			kEnd += countTimestampsUpTo(staleTimestamps[kEnd:], tEnd)
+			if kEnd > 0 && (j == 0 || staleTimestamps[kEnd-1] >= timestamps[j-1]) {
+				rfa.isStale = true
+			}
+		}
 
 		rfa.values = values[i:j]
 		rfa.timestamps = timestamps[i:j]
@@ -226,6 +249,45 @@ func (rc *rollupConfig) Do(dstValues []float64, values []float64, timestamps []i
 	return dstValues
 }
 
+// dropStaleNaNs returns values and timestamps without Prometheus staleness marks
+// and timestamps for the dropped staleness marks.
+//
+// The original values and timestamps aren't modified.
+func dropStaleNaNs(values []float64, timestamps []int64) ([]float64, []int64, []int64) {
+	hasStaleNaNs := false
+	for _, v := range values {
+		if decimal.IsStaleNaN(v) {
+			hasStaleNaNs = true
+			break
+		}
+	}
+	if !hasStaleNaNs {
+		// Fast path - nothing to drop.
+		return values, timestamps, nil
+	}
+	dstValues := make([]float64, 0, len(values))
+	dstTimestamps := make([]int64, 0, len(timestamps))
+	var staleTimestamps []int64
+	for i, v := range values {
+		if decimal.IsStaleNaN(v) {
+			staleTimestamps = append(staleTimestamps, timestamps[i])
+			continue
+		}
+		dstValues = append(dstValues, v)
+		dstTimestamps = append(dstTimestamps, timestamps[i])
+	}
+	return dstValues, dstTimestamps, staleTimestamps
+}
+
+// countTimestampsUpTo returns the number of timestamps not exceeding t.
+//
+// timestamps must be sorted.
+func countTimestampsUpTo(timestamps []int64, t int64) int {
+	return sort.Search(len(timestamps), func(n int) bool {
+		return timestamps[n] > t
+	})
+}
+
 func getMaxPrevInterval(timestamps []int64) int64 {
 	if len(timestamps) < 2 {
 		return int64(maxSilenceInterval)
@@ -238,15 +300,61 @@ func getMaxPrevInterval(timestamps []int64) int64 {
 	return d + (d / 16)
 }
 
+// getLocalMaxPrevInterval returns the max interval between samples at the end of timestamps.
+//
+// The median interval over the last maxPrevIntervalSamples samples is used,
+// so occasional gaps between samples do not increase the interval.
+// defaultInterval is returned if timestamps contain less than 2 samples.
+func getLocalMaxPrevInterval(timestamps []int64, defaultInterval int64) int64 {
+	if len(timestamps) < 2 {
+		return defaultInterval
+	}
+	if len(timestamps) > maxPrevIntervalSamples {
+		timestamps = timestamps[len(timestamps)-maxPrevIntervalSamples:]
+	}
+	d := getMedianInterval(timestamps)
+	if d <= 0 {
+		return 1
+	}
+	// Slightly increase d in order to handle possible jitter in scrape interval.
+	return d + (d / 16)
+}
+
+// maxPrevIntervalSamples is the number of samples used for calculating the median interval in getLocalMaxPrevInterval.
+const maxPrevIntervalSamples = 16
+
+func getMedianInterval(timestamps []int64) int64 {
+	var buf [maxPrevIntervalSamples - 1]int64
+	ds := buf[:0]
+	for i := 1; i < len(timestamps); i++ {
+		d := timestamps[i] - timestamps[i-1]
+		// Insertion sort is fast enough for the small number of items.
+		j := len(ds)
+		ds = append(ds, d)
+		for j > 0 && ds[j-1] > d {
+			ds[j] = ds[j-1]
+			j--
+		}
+		ds[j] = d
+	}
+	return ds[len(ds)/2]
+}
+
 func removeCounterResets(values []float64) {
-	// There is no need in handling NaNs here, since they are impossible
-	// on values from vmstorage.
+	// Values from vmstorage may contain only NaNs for Prometheus staleness marks.
+	// Skip them, so counter resets are detected across staleness marks.
 	if len(values) == 0 {
 		return
 	}
 	var correction float64
-	prevValue := values[0]
+	prevValue := nan
 	for i, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		if math.IsNaN(prevValue) {
+			prevValue = v
+		}
 		d := v - prevValue
 		if d < 0 {
 			if (-d * 8) < prevValue {
@@ -766,7 +874,13 @@ func rollupFirst(rfa *rollupFuncArg) float64 {
 	return values[0]
 }
 
-var rollupDefault = rollupLast
+func rollupDefault(rfa *rollupFuncArg) float64 {
+	if rfa.isStale {
+		// The series has been marked as stale by Prometheus, so it mustn't be extended.
+		return nan
+	}
+	return rollupLast(rfa)
+}
 
 func rollupLast(rfa *rollupFuncArg) float64 {
 	// There is no need in handling NaNs here, since they must be cleaned up
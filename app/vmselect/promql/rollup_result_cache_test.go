@@ -3,6 +3,7 @@ package promql
 import (
 	"testing"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 )
 
@@ -11,6 +12,10 @@ func TestRollupResultCache(t *testing.T) {
 	funcName := "foo"
 	window := int64(456)
 	ec := &EvalConfig{
+		AuthToken: &auth.Token{
+			AccountID: 333,
+			ProjectID: 843,
+		},
 		Start: 1000,
 		End:   2000,
 		Step:  200,
@@ -56,6 +61,20 @@ func TestRollupResultCache(t *testing.T) {
 			},
 		}
 		testTimeseriesEqual(t, tss, tssExpected)
+
+		// The cached results mustn't be visible from another tenant.
+		ecOther := newEvalConfig(ec)
+		ecOther.AuthToken = &auth.Token{
+			AccountID: 333,
+			ProjectID: 844,
+		}
+		tss, newStart = rollupResultCacheV.Get(funcName, ecOther, me, window)
+		if newStart != ecOther.Start {
+			t.Fatalf("unexpected newStart for another tenant; got %d; want %d", newStart, ecOther.Start)
+		}
+		if len(tss) != 0 {
+			t.Fatalf("got %d timeseries for another tenant, while expecting zero", len(tss))
+		}
 	})
 
 	// Store timeseries overlapping with end
@@ -3,6 +3,8 @@ package promql
 import (
 	"math"
 	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
 )
 
 var (
@@ -72,6 +74,92 @@ func TestRollupIderivDuplicateTimestamps(t *testing.T) {
 	}
 }
 
+func TestGetMaxPrevInterval(t *testing.T) {
+	f := func(timestamps []int64, dExpected int64) {
+		t.Helper()
+		d := getMaxPrevInterval(timestamps)
+		if d != dExpected {
+			t.Fatalf("unexpected maxPrevInterval for timestamps=%d; got %d; want %d", timestamps, d, dExpected)
+		}
+	}
+	f(nil, int64(maxSilenceInterval))
+	f([]int64{123}, int64(maxSilenceInterval))
+	f([]int64{10, 10}, 1)
+	f([]int64{10, 26, 42, 58}, 17)
+}
+
+func TestGetLocalMaxPrevInterval(t *testing.T) {
+	f := func(timestamps []int64, dExpected int64) {
+		t.Helper()
+		d := getLocalMaxPrevInterval(timestamps, 123)
+		if d != dExpected {
+			t.Fatalf("unexpected maxPrevInterval for timestamps=%d; got %d; want %d", timestamps, d, dExpected)
+		}
+	}
+	f(nil, 123)
+	f([]int64{10}, 123)
+	f([]int64{10, 10}, 1)
+	f([]int64{10, 26, 42, 58}, 17)
+
+	// Downsampled data with 1h interval followed by recent data with 15s interval.
+	var timestamps []int64
+	for i := 0; i < 20; i++ {
+		timestamps = append(timestamps, int64(i)*3600e3)
+	}
+	f(timestamps, 3600000+3600000/16)
+	for i := 1; i <= 32; i++ {
+		timestamps = append(timestamps, 19*3600e3+int64(i)*15e3)
+	}
+	f(timestamps, 15000+15000/16)
+	f(timestamps[:25], 3600000+3600000/16)
+
+	// Occasional gaps mustn't increase the interval.
+	timestamps = timestamps[:0]
+	for i := 0; i < 100; i++ {
+		timestamps = append(timestamps, int64(i)*15e3)
+	}
+	timestampsWithGap := append([]int64{}, timestamps[:90]...)
+	timestampsWithGap = append(timestampsWithGap, timestamps[95:]...)
+	f(timestamps, 15000+15000/16)
+	f(timestampsWithGap, 15000+15000/16)
+}
+
+func TestRollupDownsampledSeriesStopped(t *testing.T) {
+	// The series with downsampled data with 1h interval followed by recent data with 15s interval,
+	// which stopped receiving new samples.
+	var timestamps []int64
+	var values []float64
+	for i := 0; i < 20; i++ {
+		timestamps = append(timestamps, int64(i)*3600e3)
+		values = append(values, 1)
+	}
+	lastTimestamp := int64(19 * 3600e3)
+	for i := 1; i <= 32; i++ {
+		lastTimestamp += 15e3
+		timestamps = append(timestamps, lastTimestamp)
+		values = append(values, 2)
+	}
+	rc := rollupConfig{
+		Func:            rollupDefault,
+		Start:           lastTimestamp - 60e3,
+		End:             lastTimestamp + 120e3,
+		Step:            60e3,
+		MayAdjustWindow: true,
+	}
+	rc.Timestamps = getTimestamps(rc.Start, rc.End, rc.Step)
+	gotValues := rc.Do(nil, values, timestamps)
+	// The series must disappear shortly after the last sample, like series with the original resolution do.
+	valuesExpected := []float64{2, 2, 2, nan}
+	testRowsEqual(t, gotValues, rc.Timestamps, valuesExpected, rc.Timestamps)
+
+	// Downsampled data in the past must still be visible with the lookback for the downsampled data.
+	rc.Start = 10*3600e3 + 1800e3
+	rc.End = rc.Start
+	rc.Timestamps = getTimestamps(rc.Start, rc.End, rc.Step)
+	gotValues = rc.Do(nil, values, timestamps)
+	testRowsEqual(t, gotValues, rc.Timestamps, []float64{1}, rc.Timestamps)
+}
+
 func TestRemoveCounterResets(t *testing.T) {
 	removeCounterResets(nil)
 
@@ -93,6 +181,13 @@ func TestRemoveCounterResets(t *testing.T) {
 	valuesExpected = []float64{100, 100, 120, 140, 140, 190}
 	timestampsExpected = []int64{0, 1, 2, 3, 4, 5}
 	testRowsEqual(t, values, timestampsExpected, valuesExpected, timestampsExpected)
+
+	// verify counter resets are detected across staleness marks
+	values = []float64{10, 20, decimal.StaleNaN, 5, 7}
+	removeCounterResets(values)
+	valuesExpected = []float64{10, 20, nan, 25, 27}
+	timestampsExpected = []int64{0, 1, 2, 3, 4}
+	testRowsEqual(t, values, timestampsExpected, valuesExpected, timestampsExpected)
 }
 
 func TestDeltaValues(t *testing.T) {
@@ -429,6 +524,58 @@ func TestRollupNoWindowPartialPoints(t *testing.T) {
 	})
 }
 
+func TestRollupStaleNaNs(t *testing.T) {
+	values := []float64{1, 2, decimal.StaleNaN, 5, 6}
+	timestamps := []int64{10, 20, 30, 60, 70}
+	t.Run("default_rollup", func(t *testing.T) {
+		rc := rollupConfig{
+			Func:   rollupDefault,
+			Start:  0,
+			End:    80,
+			Step:   10,
+			Window: 0,
+		}
+		rc.Timestamps = getTimestamps(rc.Start, rc.End, rc.Step)
+		gotValues := rc.Do(nil, values, timestamps)
+		valuesExpected := []float64{nan, 1, 2, nan, nan, nan, 5, 6, 6}
+		timestampsExpected := []int64{0, 10, 20, 30, 40, 50, 60, 70, 80}
+		testRowsEqual(t, gotValues, rc.Timestamps, valuesExpected, timestampsExpected)
+	})
+	t.Run("sum_over_time", func(t *testing.T) {
+		rc := rollupConfig{
+			Func:   rollupSum,
+			Start:  40,
+			End:    70,
+			Step:   30,
+			Window: 30,
+		}
+		rc.Timestamps = getTimestamps(rc.Start, rc.End, rc.Step)
+		gotValues := rc.Do(nil, values, timestamps)
+		valuesExpected := []float64{2, 11}
+		timestampsExpected := []int64{40, 70}
+		testRowsEqual(t, gotValues, rc.Timestamps, valuesExpected, timestampsExpected)
+	})
+	t.Run("delta", func(t *testing.T) {
+		rc := rollupConfig{
+			Func:   rollupDelta,
+			Start:  60,
+			End:    70,
+			Step:   10,
+			Window: 30,
+		}
+		rc.Timestamps = getTimestamps(rc.Start, rc.End, rc.Step)
+		gotValues := rc.Do(nil, values, timestamps)
+		valuesExpected := []float64{0, 1}
+		timestampsExpected := []int64{60, 70}
+		testRowsEqual(t, gotValues, rc.Timestamps, valuesExpected, timestampsExpected)
+	})
+
+	// The original values mustn't be modified.
+	if !decimal.IsStaleNaN(values[2]) {
+		t.Fatalf("the staleness mark has been modified in the original values: %v", values)
+	}
+}
+
 func TestRollupWindowPartialPoints(t *testing.T) {
 	t.Run("beforeStart", func(t *testing.T) {
 		rc := rollupConfig{
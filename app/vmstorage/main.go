@@ -22,6 +22,24 @@ var (
 
 	precisionBits = flag.Int("precisionBits", 64, "The number of precision bits to store per each value. Lower precision bits improves data compression at the cost of precision loss")
 
+	minScrapeInterval = flag.Duration("dedup.minScrapeInterval", 0, "Leave only the last sample per each interval with this duration for each time series, like -downsampling.period does. "+
+		"This may be useful for reducing overhead when multiple identically configured Prometheus instances write data to the same VictoriaMetrics. "+
+		"Deduplication is disabled if the -dedup.minScrapeInterval is 0")
+	downsamplingPeriod = flag.String("downsampling.period", "", "Comma-separated list of `offset:interval` pairs for the downsampling of old data during background merges. "+
+		"For example, `30d:5m,180d:1h` leaves a single sample per 5 minutes for data older than 30 days and a single sample per hour for data older than 180 days. "+
+		"Supported suffixes: ms, s, m, h, d, w, y. Downsampling is disabled if the -downsampling.period is empty")
+
+	maxHourlySeries = flag.Int("storage.maxHourlySeries", 0, "The maximum number of unique series can be added to the storage during the last hour. "+
+		"Excess series are logged and dropped. This may be useful for limiting series cardinality. See also -storage.maxDailySeries")
+	maxDailySeries = flag.Int("storage.maxDailySeries", 0, "The maximum number of unique series can be added to the storage during the last 24 hours. "+
+		"Excess series are logged and dropped. This may be useful for limiting series churn rate. See also -storage.maxHourlySeries")
+
+	enableWAL = flag.Bool("storage.enableWAL", false, "Whether to write recently added rows to write-ahead log, so they aren't lost on unclean shutdown. "+
+		"The write-ahead log is stored at <-storageDataPath>/wal. See also -storage.walSyncPolicy")
+	walSyncPolicy = flag.String("storage.walSyncPolicy", "interval", "When to sync the write-ahead log to disk if -storage.enableWAL is set. Supported values: "+
+		"`always` - after each write; `interval` - every -storage.walSyncInterval; `none` - never, so the data is protected from process crash, but not from power loss")
+	walSyncInterval = flag.Duration("storage.walSyncInterval", time.Second, "The interval for syncing the write-ahead log to disk if -storage.walSyncPolicy=interval")
+
 	// DataPath is a path to storage data.
 	DataPath = flag.String("storageDataPath", "victoria-metrics-data", "Path to storage data")
 )
@@ -31,6 +49,29 @@ func Init() {
 	if err := encoding.CheckPrecisionBits(uint8(*precisionBits)); err != nil {
 		logger.Fatalf("invalid `-precisionBits`: %s", err)
 	}
+	storage.SetMinScrapeIntervalForDeduplication(*minScrapeInterval)
+	dps, err := storage.ParseDownsamplingPeriods(*downsamplingPeriod)
+	if err != nil {
+		logger.Fatalf("invalid `-downsampling.period`: %s", err)
+	}
+	storage.SetDownsamplingPeriods(dps)
+	if *maxHourlySeries < 0 {
+		logger.Fatalf("`-storage.maxHourlySeries` cannot be negative; got %d", *maxHourlySeries)
+	}
+	if *maxDailySeries < 0 {
+		logger.Fatalf("`-storage.maxDailySeries` cannot be negative; got %d", *maxDailySeries)
+	}
+	storage.SetMaxSeriesLimits(*maxHourlySeries, *maxDailySeries)
+	if *enableWAL {
+		policy, err := storage.ParseWALSyncPolicy(*walSyncPolicy)
+		if err != nil {
+			logger.Fatalf("invalid `-storage.walSyncPolicy`: %s", err)
+		}
+		if policy == storage.WALSyncInterval && *walSyncInterval <= 0 {
+			logger.Fatalf("`-storage.walSyncInterval` must be positive; got %s", *walSyncInterval)
+		}
+		storage.SetWAL(policy, *walSyncInterval)
+	}
 	logger.Infof("opening storage at %q with retention period %d months", *DataPath, *retentionPeriod)
 	startTime := time.Now()
 	strg, err := storage.OpenStorage(*DataPath, *retentionPeriod)
@@ -81,38 +122,72 @@ func DeleteMetrics(tfss []*storage.TagFilters) (int, error) {
 	return n, err
 }
 
-// SearchTagKeys searches for tag keys
-func SearchTagKeys(maxTagKeys int) ([]string, error) {
+// DeleteSamples deletes samples on the given tr for metrics matching tfss.
+//
+// Returns the number of metrics with deleted samples.
+func DeleteSamples(tfss []*storage.TagFilters, tr storage.TimeRange) (int, error) {
+	WG.Add(1)
+	n, err := Storage.DeleteSamples(tfss, tr)
+	WG.Done()
+	return n, err
+}
+
+// SearchMetricNames returns metric names matching the given tfss on the given tr.
+func SearchMetricNames(tfss []*storage.TagFilters, tr storage.TimeRange, maxMetrics int) ([]storage.MetricName, error) {
+	WG.Add(1)
+	mns, err := Storage.SearchMetricNames(tfss, tr, maxMetrics)
+	WG.Done()
+	return mns, err
+}
+
+// SearchTagKeys searches for tag keys for the given (accountID, projectID).
+func SearchTagKeys(accountID, projectID uint32, maxTagKeys int) ([]string, error) {
 	WG.Add(1)
-	keys, err := Storage.SearchTagKeys(maxTagKeys)
+	keys, err := Storage.SearchTagKeys(accountID, projectID, maxTagKeys)
 	WG.Done()
 	return keys, err
 }
 
-// SearchTagValues searches for tag values for the given tagKey
-func SearchTagValues(tagKey []byte, maxTagValues int) ([]string, error) {
+// SearchTagValues searches for tag values for the given tagKey in (accountID, projectID).
+func SearchTagValues(accountID, projectID uint32, tagKey []byte, maxTagValues int) ([]string, error) {
 	WG.Add(1)
-	values, err := Storage.SearchTagValues(tagKey, maxTagValues)
+	values, err := Storage.SearchTagValues(accountID, projectID, tagKey, maxTagValues)
 	WG.Done()
 	return values, err
 }
 
-// SearchTagEntries searches for tag entries.
-func SearchTagEntries(maxTagKeys, maxTagValues int) ([]storage.TagEntry, error) {
+// SearchTagValueSuffixes searches for tag value suffixes for the given tagKey and tagValuePrefix in (accountID, projectID).
+func SearchTagValueSuffixes(accountID, projectID uint32, tagKey, tagValuePrefix []byte, delimiter byte, maxTagValueSuffixes int) ([]string, error) {
 	WG.Add(1)
-	tagEntries, err := Storage.SearchTagEntries(maxTagKeys, maxTagValues)
+	suffixes, err := Storage.SearchTagValueSuffixes(accountID, projectID, tagKey, tagValuePrefix, delimiter, maxTagValueSuffixes)
+	WG.Done()
+	return suffixes, err
+}
+
+// SearchTagEntries searches for tag entries for the given (accountID, projectID).
+func SearchTagEntries(accountID, projectID uint32, maxTagKeys, maxTagValues int) ([]storage.TagEntry, error) {
+	WG.Add(1)
+	tagEntries, err := Storage.SearchTagEntries(accountID, projectID, maxTagKeys, maxTagValues)
 	WG.Done()
 	return tagEntries, err
 }
 
-// GetSeriesCount returns the number of time series in the storage.
-func GetSeriesCount() (uint64, error) {
+// GetSeriesCount returns the number of time series in the storage for the given (accountID, projectID).
+func GetSeriesCount(accountID, projectID uint32) (uint64, error) {
 	WG.Add(1)
-	n, err := Storage.GetSeriesCount()
+	n, err := Storage.GetSeriesCount(accountID, projectID)
 	WG.Done()
 	return n, err
 }
 
+// GetTSDBStatusForDate returns TSDB status for the given (accountID, projectID) and date.
+func GetTSDBStatusForDate(accountID, projectID uint32, date uint64, topN int, deadline uint64) (*storage.TSDBStatus, error) {
+	WG.Add(1)
+	status, err := Storage.GetTSDBStatusForDate(accountID, projectID, date, topN, deadline)
+	WG.Done()
+	return status, err
+}
+
 // Stop stops the vmstorage
 func Stop() {
 	logger.Infof("gracefully closing the storage at %s", *DataPath)
@@ -489,6 +564,52 @@ func registerStorageMetrics(strg *storage.Storage) {
 		return float64(idbm().DeletedMetricsCount)
 	})
 
+	metrics.NewGauge(`vm_deduplicated_samples_total{type="merge"}`, func() float64 {
+		return float64(m().DedupsDuringMerge)
+	})
+	metrics.NewGauge(`vm_downsampled_samples_total`, func() float64 {
+		return float64(m().DownsampledRows)
+	})
+
+	metrics.NewGauge(`vm_too_small_timestamp_rows_total`, func() float64 {
+		return float64(m().TooSmallTimestampRows)
+	})
+	metrics.NewGauge(`vm_too_big_timestamp_rows_total`, func() float64 {
+		return float64(m().TooBigTimestampRows)
+	})
+
+	if *enableWAL {
+		metrics.NewGauge(`vm_wal_segments`, func() float64 {
+			return float64(m().WALSegmentsCount)
+		})
+		metrics.NewGauge(`vm_wal_size_bytes`, func() float64 {
+			return float64(m().WALSizeBytes)
+		})
+	}
+
+	if *maxHourlySeries > 0 {
+		metrics.NewGauge(`vm_hourly_series_limit_rows_dropped_total`, func() float64 {
+			return float64(m().HourlySeriesLimitRowsDropped)
+		})
+		metrics.NewGauge(`vm_hourly_series_limit_max_series`, func() float64 {
+			return float64(m().HourlySeriesLimitMaxSeries)
+		})
+		metrics.NewGauge(`vm_hourly_series_limit_current_series`, func() float64 {
+			return float64(m().HourlySeriesLimitCurrentSeries)
+		})
+	}
+	if *maxDailySeries > 0 {
+		metrics.NewGauge(`vm_daily_series_limit_rows_dropped_total`, func() float64 {
+			return float64(m().DailySeriesLimitRowsDropped)
+		})
+		metrics.NewGauge(`vm_daily_series_limit_max_series`, func() float64 {
+			return float64(m().DailySeriesLimitMaxSeries)
+		})
+		metrics.NewGauge(`vm_daily_series_limit_current_series`, func() float64 {
+			return float64(m().DailySeriesLimitCurrentSeries)
+		})
+	}
+
 	metrics.NewGauge(`vm_cache_collisions_total{type="storage/tsid"}`, func() float64 {
 		return float64(m().TSIDCacheCollisions)
 	})
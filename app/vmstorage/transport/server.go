@@ -0,0 +1,914 @@
+package transport
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/handshake"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/netutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// maxInsertPacketSize is the maximum size of a single packet with rows from vminsert.
+const maxInsertPacketSize = 100 * 1024 * 1024
+
+// Server processes connections from vminsert and vmselect.
+type Server struct {
+	vminsertLN net.Listener
+	vmselectLN net.Listener
+
+	vminsertWG sync.WaitGroup
+	vmselectWG sync.WaitGroup
+
+	vminsertConnsMap connsMap
+	vmselectConnsMap connsMap
+
+	stopFlag uint64
+}
+
+type connsMap struct {
+	mu sync.Mutex
+	m  map[net.Conn]struct{}
+}
+
+func (cm *connsMap) Init() {
+	cm.m = make(map[net.Conn]struct{})
+}
+
+func (cm *connsMap) Add(c net.Conn) {
+	cm.mu.Lock()
+	cm.m[c] = struct{}{}
+	cm.mu.Unlock()
+}
+
+func (cm *connsMap) Delete(c net.Conn) {
+	cm.mu.Lock()
+	delete(cm.m, c)
+	cm.mu.Unlock()
+}
+
+func (cm *connsMap) CloseAll() {
+	cm.mu.Lock()
+	for c := range cm.m {
+		_ = c.Close()
+	}
+	cm.mu.Unlock()
+}
+
+// NewServer returns new Server listening for vminsert connections on vminsertAddr
+// and for vmselect connections on vmselectAddr.
+func NewServer(vminsertAddr, vmselectAddr string) (*Server, error) {
+	vminsertLN, err := netutil.NewTCPListener("vminsert", vminsertAddr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen vminsertAddr %s: %s", vminsertAddr, err)
+	}
+	vmselectLN, err := netutil.NewTCPListener("vmselect", vmselectAddr)
+	if err != nil {
+		_ = vminsertLN.Close()
+		return nil, fmt.Errorf("unable to listen vmselectAddr %s: %s", vmselectAddr, err)
+	}
+	s := &Server{
+		vminsertLN: vminsertLN,
+		vmselectLN: vmselectLN,
+	}
+	s.vminsertConnsMap.Init()
+	s.vmselectConnsMap.Init()
+	return s, nil
+}
+
+// RunVMInsert runs a server accepting connections from vminsert.
+func (s *Server) RunVMInsert() {
+	logger.Infof("accepting vminsert conns at %s", s.vminsertLN.Addr())
+	s.run(s.vminsertLN, "vminsert", handshake.VMInsertServer, &s.vminsertWG, &s.vminsertConnsMap, s.processVMInsertConn)
+}
+
+// RunVMSelect runs a server accepting connections from vmselect.
+func (s *Server) RunVMSelect() {
+	logger.Infof("accepting vmselect conns at %s", s.vmselectLN.Addr())
+	s.run(s.vmselectLN, "vmselect", handshake.VMSelectServer, &s.vmselectWG, &s.vmselectConnsMap, s.processVMSelectConn)
+}
+
+func (s *Server) run(ln net.Listener, name string, hsFunc handshake.Func, wg *sync.WaitGroup, cm *connsMap, f func(bc *handshake.BufferedConn) error) {
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			if pe, ok := err.(net.Error); ok && pe.Temporary() {
+				continue
+			}
+			if s.isStopping() {
+				return
+			}
+			logger.Panicf("FATAL: cannot process %s conns at %s: %s", name, ln.Addr(), err)
+		}
+		logger.Infof("accepted %s conn from %s", name, c.RemoteAddr())
+
+		cm.Add(c)
+		wg.Add(1)
+		go func() {
+			defer func() {
+				cm.Delete(c)
+				_ = c.Close()
+				wg.Done()
+			}()
+
+			bc, err := hsFunc(c)
+			if err != nil {
+				logger.Errorf("cannot perform %s handshake with client %q: %s", name, c.RemoteAddr(), err)
+				return
+			}
+			if err := f(bc); err != nil {
+				if s.isStopping() {
+					return
+				}
+				logger.Errorf("cannot process %s conn from %s: %s", name, c.RemoteAddr(), err)
+				return
+			}
+			logger.Infof("closing %s conn from %s", name, c.RemoteAddr())
+		}()
+	}
+}
+
+// MustClose gracefully closes the server,
+// so it no longer touches vmstorage after returning.
+func (s *Server) MustClose() {
+	// Mark the server as stoping.
+	atomic.StoreUint64(&s.stopFlag, 1)
+
+	// Stop accepting new connections from vminsert and vmselect.
+	if err := s.vminsertLN.Close(); err != nil {
+		logger.Panicf("FATAL: cannot close vminsert listener: %s", err)
+	}
+	if err := s.vmselectLN.Close(); err != nil {
+		logger.Panicf("FATAL: cannot close vmselect listener: %s", err)
+	}
+
+	// Close existing connections from vminsert, so the storage won't accept
+	// new data from vminsert.
+	s.vminsertConnsMap.CloseAll()
+	s.vminsertWG.Wait()
+
+	// Close existing connections from vmselect, so the storage won't accept
+	// new queries from vmselect.
+	s.vmselectConnsMap.CloseAll()
+	s.vmselectWG.Wait()
+}
+
+func (s *Server) isStopping() bool {
+	return atomic.LoadUint64(&s.stopFlag) != 0
+}
+
+func (s *Server) processVMInsertConn(bc *handshake.BufferedConn) error {
+	var buf []byte
+	var mrs []storage.MetricRow
+	for {
+		var err error
+		buf, err = readBytes(buf[:0], bc, maxInsertPacketSize)
+		if err != nil {
+			if err == io.EOF {
+				// Remote end gracefully closed the connection.
+				return nil
+			}
+			return fmt.Errorf("cannot read packet with rows: %s", err)
+		}
+		vminsertPacketsRead.Inc()
+
+		mrs, err = unmarshalMetricRows(mrs[:0], buf)
+		if err == nil {
+			vminsertMetricsRead.Add(len(mrs))
+			err = vmstorage.AddRows(mrs)
+		}
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if err := writeErrorMessage(bc, errMsg); err != nil {
+			return fmt.Errorf("cannot send response to vminsert: %s", err)
+		}
+		if err := bc.Flush(); err != nil {
+			return fmt.Errorf("cannot flush response to vminsert: %s", err)
+		}
+	}
+}
+
+var (
+	vminsertPacketsRead = metrics.NewCounter(`vm_vminsert_packets_read_total`)
+	vminsertMetricsRead = metrics.NewCounter(`vm_vminsert_metrics_read_total`)
+)
+
+func unmarshalMetricRows(dst []storage.MetricRow, src []byte) ([]storage.MetricRow, error) {
+	for len(src) > 0 {
+		if cap(dst) > len(dst) {
+			dst = dst[:len(dst)+1]
+		} else {
+			dst = append(dst, storage.MetricRow{})
+		}
+		mr := &dst[len(dst)-1]
+		tail, err := mr.Unmarshal(src)
+		if err != nil {
+			return dst, fmt.Errorf("cannot unmarshal MetricRow: %s", err)
+		}
+		src = tail
+	}
+	return dst, nil
+}
+
+func (s *Server) processVMSelectConn(bc *handshake.BufferedConn) error {
+	ctx := &vmselectRequestCtx{
+		bc: bc,
+	}
+	for {
+		err := s.processVMSelectRequest(ctx)
+		if err != nil {
+			if err == io.EOF {
+				// Remote client gracefully closed the connection.
+				return nil
+			}
+			return fmt.Errorf("cannot process vmselect request: %s", err)
+		}
+		if err := bc.Flush(); err != nil {
+			return fmt.Errorf("cannot flush buffers: %s", err)
+		}
+	}
+}
+
+type vmselectRequestCtx struct {
+	bc      *handshake.BufferedConn
+	dataBuf []byte
+
+	sq   storage.SearchQuery
+	tfss []*storage.TagFilters
+	sr   storage.Search
+}
+
+func (ctx *vmselectRequestCtx) readUint32() (uint32, error) {
+	n, err := ctx.readUint64()
+	if err != nil {
+		return 0, err
+	}
+	if n > 1<<32-1 {
+		return 0, fmt.Errorf("too big uint32 value: %d", n)
+	}
+	return uint32(n), nil
+}
+
+func (ctx *vmselectRequestCtx) readUint64() (uint64, error) {
+	return readUint64(ctx.bc)
+}
+
+// readLimit reads a limit on the number of returned items passed by vmselect.
+func (ctx *vmselectRequestCtx) readLimit() (int, error) {
+	n, err := ctx.readUint32()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 || n > 1<<31-1 {
+		return 0, fmt.Errorf("invalid limit: %d; it must be in the range [1..%d]", n, 1<<31-1)
+	}
+	return int(n), nil
+}
+
+func (ctx *vmselectRequestCtx) readDataBufBytes(maxDataSize int) error {
+	var err error
+	ctx.dataBuf, err = readBytes(ctx.dataBuf[:0], ctx.bc, maxDataSize)
+	return err
+}
+
+func (ctx *vmselectRequestCtx) writeDataBufBytes() error {
+	return writeBytes(ctx.bc, ctx.dataBuf)
+}
+
+func (ctx *vmselectRequestCtx) writeString(s string) error {
+	ctx.dataBuf = append(ctx.dataBuf[:0], s...)
+	return ctx.writeDataBufBytes()
+}
+
+func (ctx *vmselectRequestCtx) writeUint64(n uint64) error {
+	return writeUint64(ctx.bc, n)
+}
+
+func (ctx *vmselectRequestCtx) readSearchQuery() error {
+	if err := ctx.readDataBufBytes(maxSearchQuerySize); err != nil {
+		return fmt.Errorf("cannot read searchQuery: %s", err)
+	}
+	tail, err := ctx.sq.Unmarshal(ctx.dataBuf)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal SearchQuery: %s", err)
+	}
+	if len(tail) > 0 {
+		return fmt.Errorf("unexpected non-zero tail left after unmarshaling SearchQuery: (len=%d) %q", len(tail), tail)
+	}
+	return nil
+}
+
+func (ctx *vmselectRequestCtx) setupTfss() error {
+	tfss := ctx.tfss[:0]
+	for _, tagFilters := range ctx.sq.TagFilterss {
+		if len(tfss) < cap(tfss) {
+			tfss = tfss[:len(tfss)+1]
+		} else {
+			tfss = append(tfss, &storage.TagFilters{})
+		}
+		tfs := tfss[len(tfss)-1]
+		tfs.Reset(ctx.sq.AccountID, ctx.sq.ProjectID)
+		for i := range tagFilters {
+			tf := &tagFilters[i]
+			if err := tfs.Add(tf.Key, tf.Value, tf.IsNegative, tf.IsRegexp); err != nil {
+				return fmt.Errorf("cannot parse tag filter %s: %s", tf, err)
+			}
+		}
+	}
+	ctx.tfss = tfss
+	return nil
+}
+
+const maxRPCNameSize = 128
+
+const maxSearchQuerySize = 1024 * 1024
+
+const maxTagKeySize = 64 * 1024
+
+func (s *Server) processVMSelectRequest(ctx *vmselectRequestCtx) error {
+	// Read rpcName
+	// Do not set deadline on reading rpcName, since it may take a
+	// lot of time for idle connection.
+	if err := ctx.readDataBufBytes(maxRPCNameSize); err != nil {
+		if err == io.EOF {
+			// Remote client gracefully closed the connection.
+			return err
+		}
+		return fmt.Errorf("cannot read rpcName: %s", err)
+	}
+
+	// Limit the time required for reading request args.
+	if err := ctx.bc.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		return fmt.Errorf("cannot set read deadline for reading request args: %s", err)
+	}
+	defer func() {
+		_ = ctx.bc.SetReadDeadline(time.Time{})
+	}()
+
+	switch string(ctx.dataBuf) {
+	case "search_v1":
+		return s.processVMSelectSearchQuery(ctx)
+	case "searchMetricNames_v1":
+		return s.processVMSelectSearchMetricNames(ctx)
+	case "labels":
+		return s.processVMSelectLabels(ctx)
+	case "labelValues":
+		return s.processVMSelectLabelValues(ctx)
+	case "labelEntries":
+		return s.processVMSelectLabelEntries(ctx)
+	case "tagValueSuffixes":
+		return s.processVMSelectTagValueSuffixes(ctx)
+	case "seriesCount":
+		return s.processVMSelectSeriesCount(ctx)
+	case "tsdbStatus":
+		return s.processVMSelectTSDBStatus(ctx)
+	case "deleteMetrics_v1":
+		return s.processVMSelectDeleteMetrics(ctx)
+	case "deleteSamples_v1":
+		return s.processVMSelectDeleteSamples(ctx)
+	default:
+		return fmt.Errorf("unsupported rpcName: %q", ctx.dataBuf)
+	}
+}
+
+func (s *Server) processVMSelectDeleteMetrics(ctx *vmselectRequestCtx) error {
+	vmselectDeleteMetricsRequests.Inc()
+
+	// Read request
+	if err := ctx.readSearchQuery(); err != nil {
+		return err
+	}
+
+	// Setup ctx.tfss
+	if err := ctx.setupTfss(); err != nil {
+		return ctx.writeErrorMessage(err)
+	}
+
+	// Delete the given metrics.
+	deletedCount, err := vmstorage.DeleteMetrics(ctx.tfss)
+	if err != nil {
+		return ctx.writeErrorMessage(err)
+	}
+
+	// Send an empty error message to vmselect.
+	if err := ctx.writeString(""); err != nil {
+		return fmt.Errorf("cannot send empty error message: %s", err)
+	}
+	// Send deletedCount to vmselect.
+	if err := ctx.writeUint64(uint64(deletedCount)); err != nil {
+		return fmt.Errorf("cannot send deletedCount=%d: %s", deletedCount, err)
+	}
+	return nil
+}
+
+func (s *Server) processVMSelectDeleteSamples(ctx *vmselectRequestCtx) error {
+	vmselectDeleteSamplesRequests.Inc()
+
+	// Read request
+	if err := ctx.readSearchQuery(); err != nil {
+		return err
+	}
+
+	// Setup ctx.tfss
+	if err := ctx.setupTfss(); err != nil {
+		return ctx.writeErrorMessage(err)
+	}
+
+	// Delete samples on the given time range.
+	tr := storage.TimeRange{
+		MinTimestamp: ctx.sq.MinTimestamp,
+		MaxTimestamp: ctx.sq.MaxTimestamp,
+	}
+	deletedCount, err := vmstorage.DeleteSamples(ctx.tfss, tr)
+	if err != nil {
+		return ctx.writeErrorMessage(err)
+	}
+
+	// Send an empty error message to vmselect.
+	if err := ctx.writeString(""); err != nil {
+		return fmt.Errorf("cannot send empty error message: %s", err)
+	}
+	// Send deletedCount to vmselect.
+	if err := ctx.writeUint64(uint64(deletedCount)); err != nil {
+		return fmt.Errorf("cannot send deletedCount=%d: %s", deletedCount, err)
+	}
+	return nil
+}
+
+func (s *Server) processVMSelectLabels(ctx *vmselectRequestCtx) error {
+	vmselectLabelsRequests.Inc()
+
+	// Read request
+	accountID, err := ctx.readUint32()
+	if err != nil {
+		return fmt.Errorf("cannot read accountID: %s", err)
+	}
+	projectID, err := ctx.readUint32()
+	if err != nil {
+		return fmt.Errorf("cannot read projectID: %s", err)
+	}
+	maxTagKeys, err := ctx.readLimit()
+	if err != nil {
+		return fmt.Errorf("cannot read maxTagKeys: %s", err)
+	}
+
+	// Search for tag keys
+	labels, err := vmstorage.SearchTagKeys(accountID, projectID, maxTagKeys)
+	if err != nil {
+		return ctx.writeErrorMessage(err)
+	}
+
+	// Send an empty error message to vmselect.
+	if err := ctx.writeString(""); err != nil {
+		return fmt.Errorf("cannot send empty error message: %s", err)
+	}
+
+	// Send labels to vmselect
+	if err := ctx.writeStrings(labels); err != nil {
+		return fmt.Errorf("cannot write labels: %s", err)
+	}
+	return nil
+}
+
+func (s *Server) processVMSelectLabelValues(ctx *vmselectRequestCtx) error {
+	vmselectLabelValuesRequests.Inc()
+
+	// Read request
+	accountID, err := ctx.readUint32()
+	if err != nil {
+		return fmt.Errorf("cannot read accountID: %s", err)
+	}
+	projectID, err := ctx.readUint32()
+	if err != nil {
+		return fmt.Errorf("cannot read projectID: %s", err)
+	}
+	if err := ctx.readDataBufBytes(maxTagKeySize); err != nil {
+		return fmt.Errorf("cannot read labelName: %s", err)
+	}
+	labelName := append([]byte{}, ctx.dataBuf...)
+	maxTagValues, err := ctx.readLimit()
+	if err != nil {
+		return fmt.Errorf("cannot read maxTagValues: %s", err)
+	}
+
+	// Search for tag values
+	labelValues, err := vmstorage.SearchTagValues(accountID, projectID, labelName, maxTagValues)
+	if err != nil {
+		return ctx.writeErrorMessage(err)
+	}
+
+	// Send an empty error message to vmselect.
+	if err := ctx.writeString(""); err != nil {
+		return fmt.Errorf("cannot send empty error message: %s", err)
+	}
+
+	// Send labelValues to vmselect
+	if err := ctx.writeStrings(labelValues); err != nil {
+		return fmt.Errorf("cannot write labelValues: %s", err)
+	}
+	return nil
+}
+
+const maxTagValueSize = 16 * 1024 * 1024
+
+func (s *Server) processVMSelectTagValueSuffixes(ctx *vmselectRequestCtx) error {
+	vmselectTagValueSuffixesRequests.Inc()
+
+	// Read request
+	accountID, err := ctx.readUint32()
+	if err != nil {
+		return fmt.Errorf("cannot read accountID: %s", err)
+	}
+	projectID, err := ctx.readUint32()
+	if err != nil {
+		return fmt.Errorf("cannot read projectID: %s", err)
+	}
+	if err := ctx.readDataBufBytes(maxTagKeySize); err != nil {
+		return fmt.Errorf("cannot read tagKey: %s", err)
+	}
+	tagKey := append([]byte{}, ctx.dataBuf...)
+	if err := ctx.readDataBufBytes(maxTagValueSize); err != nil {
+		return fmt.Errorf("cannot read tagValuePrefix: %s", err)
+	}
+	tagValuePrefix := append([]byte{}, ctx.dataBuf...)
+	if err := ctx.readDataBufBytes(1); err != nil {
+		return fmt.Errorf("cannot read delimiter: %s", err)
+	}
+	if len(ctx.dataBuf) != 1 {
+		return fmt.Errorf("unexpected delimiter size; got %d bytes; want 1 byte", len(ctx.dataBuf))
+	}
+	delimiter := ctx.dataBuf[0]
+	maxSuffixes, err := ctx.readLimit()
+	if err != nil {
+		return fmt.Errorf("cannot read maxTagValueSuffixes: %s", err)
+	}
+
+	// Search for tag value suffixes
+	suffixes, err := vmstorage.SearchTagValueSuffixes(accountID, projectID, tagKey, tagValuePrefix, delimiter, maxSuffixes)
+	if err != nil {
+		return ctx.writeErrorMessage(err)
+	}
+
+	// Send an empty error message to vmselect.
+	if err := ctx.writeString(""); err != nil {
+		return fmt.Errorf("cannot send empty error message: %s", err)
+	}
+
+	// Send suffixes to vmselect
+	if err := ctx.writeStrings(suffixes); err != nil {
+		return fmt.Errorf("cannot write tag value suffixes: %s", err)
+	}
+	return nil
+}
+
+func (s *Server) processVMSelectLabelEntries(ctx *vmselectRequestCtx) error {
+	vmselectLabelEntriesRequests.Inc()
+
+	// Read request
+	accountID, err := ctx.readUint32()
+	if err != nil {
+		return fmt.Errorf("cannot read accountID: %s", err)
+	}
+	projectID, err := ctx.readUint32()
+	if err != nil {
+		return fmt.Errorf("cannot read projectID: %s", err)
+	}
+	maxTagKeys, err := ctx.readLimit()
+	if err != nil {
+		return fmt.Errorf("cannot read maxTagKeys: %s", err)
+	}
+	maxTagValues, err := ctx.readLimit()
+	if err != nil {
+		return fmt.Errorf("cannot read maxTagValues: %s", err)
+	}
+
+	// Perform the request
+	labelEntries, err := vmstorage.SearchTagEntries(accountID, projectID, maxTagKeys, maxTagValues)
+	if err != nil {
+		return ctx.writeErrorMessage(err)
+	}
+
+	// Send an empty error message to vmselect.
+	if err := ctx.writeString(""); err != nil {
+		return fmt.Errorf("cannot send empty error message: %s", err)
+	}
+
+	// Send labelEntries to vmselect
+	if err := ctx.writeUint64(uint64(len(labelEntries))); err != nil {
+		return fmt.Errorf("cannot send labelEntries count: %s", err)
+	}
+	for i := range labelEntries {
+		e := &labelEntries[i]
+		if err := ctx.writeString(e.Key); err != nil {
+			return fmt.Errorf("cannot write label %q: %s", e.Key, err)
+		}
+		if err := ctx.writeStrings(e.Values); err != nil {
+			return fmt.Errorf("cannot write label values for %q: %s", e.Key, err)
+		}
+	}
+	return nil
+}
+
+func (s *Server) processVMSelectSeriesCount(ctx *vmselectRequestCtx) error {
+	vmselectSeriesCountRequests.Inc()
+
+	// Read request
+	accountID, err := ctx.readUint32()
+	if err != nil {
+		return fmt.Errorf("cannot read accountID: %s", err)
+	}
+	projectID, err := ctx.readUint32()
+	if err != nil {
+		return fmt.Errorf("cannot read projectID: %s", err)
+	}
+
+	// Execute the request
+	n, err := vmstorage.GetSeriesCount(accountID, projectID)
+	if err != nil {
+		return ctx.writeErrorMessage(err)
+	}
+
+	// Send an empty error message to vmselect.
+	if err := ctx.writeString(""); err != nil {
+		return fmt.Errorf("cannot send empty error message: %s", err)
+	}
+
+	// Send series count to vmselect.
+	if err := ctx.writeUint64(n); err != nil {
+		return fmt.Errorf("cannot write series count to vmselect: %s", err)
+	}
+	return nil
+}
+
+func (s *Server) processVMSelectTSDBStatus(ctx *vmselectRequestCtx) error {
+	vmselectTSDBStatusRequests.Inc()
+
+	// Read request
+	accountID, err := ctx.readUint32()
+	if err != nil {
+		return fmt.Errorf("cannot read accountID: %s", err)
+	}
+	projectID, err := ctx.readUint32()
+	if err != nil {
+		return fmt.Errorf("cannot read projectID: %s", err)
+	}
+	date, err := ctx.readUint64()
+	if err != nil {
+		return fmt.Errorf("cannot read date: %s", err)
+	}
+	topN, err := ctx.readLimit()
+	if err != nil {
+		return fmt.Errorf("cannot read topN: %s", err)
+	}
+	deadline, err := ctx.readUint64()
+	if err != nil {
+		return fmt.Errorf("cannot read deadline: %s", err)
+	}
+
+	// Execute the request
+	status, err := vmstorage.GetTSDBStatusForDate(accountID, projectID, date, topN, deadline)
+	if err != nil {
+		return ctx.writeErrorMessage(err)
+	}
+
+	// Send an empty error message to vmselect.
+	if err := ctx.writeString(""); err != nil {
+		return fmt.Errorf("cannot send empty error message: %s", err)
+	}
+
+	// Send status to vmselect.
+	if err := ctx.writeUint64(status.TotalSeries); err != nil {
+		return fmt.Errorf("cannot write totalSeries to vmselect: %s", err)
+	}
+	if err := ctx.writeTopHeapEntries(status.SeriesCountByMetricName); err != nil {
+		return fmt.Errorf("cannot write seriesCountByMetricName to vmselect: %s", err)
+	}
+	if err := ctx.writeTopHeapEntries(status.LabelValueCountByLabelName); err != nil {
+		return fmt.Errorf("cannot write labelValueCountByLabelName to vmselect: %s", err)
+	}
+	if err := ctx.writeTopHeapEntries(status.SeriesCountByLabelValuePair); err != nil {
+		return fmt.Errorf("cannot write seriesCountByLabelValuePair to vmselect: %s", err)
+	}
+	return nil
+}
+
+func (ctx *vmselectRequestCtx) writeTopHeapEntries(a []storage.TopHeapEntry) error {
+	if err := ctx.writeUint64(uint64(len(a))); err != nil {
+		return fmt.Errorf("cannot write entries count: %s", err)
+	}
+	for i := range a {
+		e := &a[i]
+		if err := ctx.writeString(e.Name); err != nil {
+			return fmt.Errorf("cannot write entry name %q: %s", e.Name, err)
+		}
+		if err := ctx.writeUint64(e.Count); err != nil {
+			return fmt.Errorf("cannot write count for entry %q: %s", e.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *Server) processVMSelectSearchMetricNames(ctx *vmselectRequestCtx) error {
+	vmselectSearchMetricNamesRequests.Inc()
+
+	// Read request
+	if err := ctx.readSearchQuery(); err != nil {
+		return err
+	}
+	maxMetrics, err := ctx.readLimit()
+	if err != nil {
+		return fmt.Errorf("cannot read maxMetrics: %s", err)
+	}
+
+	// Setup ctx.tfss
+	if err := ctx.setupTfss(); err != nil {
+		return ctx.writeErrorMessage(err)
+	}
+
+	// Search for metric names
+	tr := storage.TimeRange{
+		MinTimestamp: ctx.sq.MinTimestamp,
+		MaxTimestamp: ctx.sq.MaxTimestamp,
+	}
+	mns, err := vmstorage.SearchMetricNames(ctx.tfss, tr, maxMetrics)
+	if err != nil {
+		return ctx.writeErrorMessage(err)
+	}
+
+	// Send an empty error message to vmselect.
+	if err := ctx.writeString(""); err != nil {
+		return fmt.Errorf("cannot send empty error message: %s", err)
+	}
+
+	// Send metric names to vmselect.
+	if err := ctx.writeUint64(uint64(len(mns))); err != nil {
+		return fmt.Errorf("cannot send metric names count: %s", err)
+	}
+	for i := range mns {
+		ctx.dataBuf = mns[i].Marshal(ctx.dataBuf[:0])
+		if err := ctx.writeDataBufBytes(); err != nil {
+			return fmt.Errorf("cannot send metric name: %s", err)
+		}
+	}
+	return nil
+}
+
+func (s *Server) processVMSelectSearchQuery(ctx *vmselectRequestCtx) error {
+	vmselectSearchQueryRequests.Inc()
+
+	// Read search query.
+	if err := ctx.readSearchQuery(); err != nil {
+		return err
+	}
+	maxMetrics, err := ctx.readLimit()
+	if err != nil {
+		return fmt.Errorf("cannot read maxMetrics: %s", err)
+	}
+
+	// Setup search.
+	if err := ctx.setupTfss(); err != nil {
+		return ctx.writeSearchError(err)
+	}
+	tr := storage.TimeRange{
+		MinTimestamp: ctx.sq.MinTimestamp,
+		MaxTimestamp: ctx.sq.MaxTimestamp,
+	}
+
+	vmstorage.WG.Add(1)
+	defer vmstorage.WG.Done()
+
+	ctx.sr.Init(vmstorage.Storage, ctx.tfss, tr, maxMetrics)
+	defer ctx.sr.MustClose()
+
+	// Send found blocks to vmselect.
+	for ctx.sr.NextMetricBlock() {
+		mb := &ctx.sr.MetricBlock
+
+		vmselectMetricBlocksRead.Inc()
+		vmselectMetricRowsRead.Add(mb.Block.RowsCount())
+
+		ctx.dataBuf = mb.Marshal(ctx.dataBuf[:0])
+		if err := ctx.writeDataBufBytes(); err != nil {
+			return fmt.Errorf("cannot send MetricBlock: %s", err)
+		}
+	}
+	return ctx.writeSearchError(ctx.sr.Error())
+}
+
+// writeSearchError finishes the stream of MetricBlocks for search_v1 rpc
+// and sends the given err to vmselect.
+func (ctx *vmselectRequestCtx) writeSearchError(err error) error {
+	// Send 'end of response' marker
+	if err := ctx.writeString(""); err != nil {
+		return fmt.Errorf("cannot send 'end of response' marker")
+	}
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	}
+	return ctx.writeString(errMsg)
+}
+
+// writeErrorMessage sends err to vmselect as the response.
+func (ctx *vmselectRequestCtx) writeErrorMessage(err error) error {
+	errMsg := err.Error()
+	if err := ctx.writeString(errMsg); err != nil {
+		return fmt.Errorf("cannot send error message %q to client: %s", errMsg, err)
+	}
+	return nil
+}
+
+func (ctx *vmselectRequestCtx) writeStrings(a []string) error {
+	if err := ctx.writeUint64(uint64(len(a))); err != nil {
+		return fmt.Errorf("cannot write items count: %s", err)
+	}
+	for _, s := range a {
+		if err := ctx.writeString(s); err != nil {
+			return fmt.Errorf("cannot write item %q: %s", s, err)
+		}
+	}
+	return nil
+}
+
+var (
+	vmselectDeleteMetricsRequests     = metrics.NewCounter(`vm_vmselect_rpc_requests_total{name="delete_metrics"}`)
+	vmselectDeleteSamplesRequests     = metrics.NewCounter(`vm_vmselect_rpc_requests_total{name="delete_samples"}`)
+	vmselectLabelsRequests            = metrics.NewCounter(`vm_vmselect_rpc_requests_total{name="labels"}`)
+	vmselectLabelValuesRequests       = metrics.NewCounter(`vm_vmselect_rpc_requests_total{name="label_values"}`)
+	vmselectLabelEntriesRequests      = metrics.NewCounter(`vm_vmselect_rpc_requests_total{name="label_entries"}`)
+	vmselectTagValueSuffixesRequests  = metrics.NewCounter(`vm_vmselect_rpc_requests_total{name="tag_value_suffixes"}`)
+	vmselectSeriesCountRequests       = metrics.NewCounter(`vm_vmselect_rpc_requests_total{name="series_count"}`)
+	vmselectTSDBStatusRequests        = metrics.NewCounter(`vm_vmselect_rpc_requests_total{name="tsdb_status"}`)
+	vmselectSearchQueryRequests       = metrics.NewCounter(`vm_vmselect_rpc_requests_total{name="search_query"}`)
+	vmselectSearchMetricNamesRequests = metrics.NewCounter(`vm_vmselect_rpc_requests_total{name="search_metric_names"}`)
+	vmselectMetricBlocksRead          = metrics.NewCounter(`vm_vmselect_metric_blocks_read_total`)
+	vmselectMetricRowsRead            = metrics.NewCounter(`vm_vmselect_metric_rows_read_total`)
+)
+
+func writeErrorMessage(w io.Writer, errMsg string) error {
+	return writeBytes(w, []byte(errMsg))
+}
+
+func writeBytes(w io.Writer, buf []byte) error {
+	if err := writeUint64(w, uint64(len(buf))); err != nil {
+		return fmt.Errorf("cannot write data size: %s", err)
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+	if _, err := w.Write(buf); err != nil {
+		return fmt.Errorf("cannot write data with size %d: %s", len(buf), err)
+	}
+	return nil
+}
+
+func writeUint64(w io.Writer, n uint64) error {
+	var sizeBuf [8]byte
+	if _, err := w.Write(encoding.MarshalUint64(sizeBuf[:0], n)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func readBytes(buf []byte, r io.Reader, maxDataSize int) ([]byte, error) {
+	n, err := readUint64(r)
+	if err != nil {
+		if err == io.EOF {
+			return buf, err
+		}
+		return buf, fmt.Errorf("cannot read data size: %s", err)
+	}
+	if n > uint64(maxDataSize) {
+		return buf, fmt.Errorf("too big data size: %d; it mustn't exceed %d bytes", n, maxDataSize)
+	}
+	if n == 0 {
+		return buf, nil
+	}
+	bufLen := len(buf)
+	if m := bufLen + int(n) - cap(buf); m > 0 {
+		buf = append(buf[:cap(buf)], make([]byte, m)...)
+	}
+	buf = buf[:bufLen+int(n)]
+	if _, err := io.ReadFull(r, buf[bufLen:]); err != nil {
+		return buf, fmt.Errorf("cannot read data with size %d: %s", n, err)
+	}
+	return buf, nil
+}
+
+func readUint64(r io.Reader) (uint64, error) {
+	var sizeBuf [8]byte
+	if _, err := io.ReadFull(r, sizeBuf[:]); err != nil {
+		return 0, err
+	}
+	return encoding.UnmarshalUint64(sizeBuf[:]), nil
+}
@@ -11,6 +11,7 @@ require (
 	github.com/valyala/histogram v1.0.1
 	github.com/valyala/quicktemplate v1.1.1
 	golang.org/x/sys v0.0.0-20190616124812-15dcb6c0061f
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 go 1.12
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Token contains the tenant for request processing.
+type Token struct {
+	AccountID uint32
+	ProjectID uint32
+}
+
+// String returns string representation of t.
+func (t *Token) String() string {
+	return fmt.Sprintf("%d:%d", t.AccountID, t.ProjectID)
+}
+
+// DefaultToken is the token for requests without tenant in the path.
+var DefaultToken = &Token{}
+
+// NewToken returns new Token for the given authToken.
+//
+// authToken must be in the form `accountID[:projectID]`. projectID is set to 0 if it is missing.
+func NewToken(authToken string) (*Token, error) {
+	tmp := strings.Split(authToken, ":")
+	if len(tmp) > 2 {
+		return nil, fmt.Errorf("unexpected number of items in authToken %q; got %d; want 1 or 2", authToken, len(tmp))
+	}
+	var at Token
+	accountID, err := strconv.ParseUint(tmp[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse accountID from %q: %s", tmp[0], err)
+	}
+	at.AccountID = uint32(accountID)
+	if len(tmp) > 1 {
+		projectID, err := strconv.ParseUint(tmp[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse projectID from %q: %s", tmp[1], err)
+		}
+		at.ProjectID = uint32(projectID)
+	}
+	return &at, nil
+}
+
+// ParsePath extracts Token from the path in the form `<prefix><accountID[:projectID]>/<suffix>`.
+//
+// It returns the Token and `/<suffix>`. DefaultToken and unmodified path
+// are returned if path doesn't start with prefix.
+func ParsePath(path, prefix string) (*Token, string, error) {
+	if !strings.HasPrefix(path, prefix) {
+		return DefaultToken, path, nil
+	}
+	s := path[len(prefix):]
+	n := strings.IndexByte(s, '/')
+	if n < 0 {
+		return nil, "", fmt.Errorf("missing path after the tenant in %q; expecting `%s<accountID[:projectID]>/<path>`", path, prefix)
+	}
+	at, err := NewToken(s[:n])
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot parse tenant from %q: %s", path, err)
+	}
+	return at, s[n:], nil
+}
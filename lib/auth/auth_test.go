@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"testing"
+)
+
+func TestNewTokenSuccess(t *testing.T) {
+	f := func(authToken string, accountID, projectID uint32) {
+		t.Helper()
+		at, err := NewToken(authToken)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", authToken, err)
+		}
+		if at.AccountID != accountID {
+			t.Fatalf("unexpected accountID for %q; got %d; want %d", authToken, at.AccountID, accountID)
+		}
+		if at.ProjectID != projectID {
+			t.Fatalf("unexpected projectID for %q; got %d; want %d", authToken, at.ProjectID, projectID)
+		}
+	}
+	f("0", 0, 0)
+	f("123", 123, 0)
+	f("123:0", 123, 0)
+	f("123:456", 123, 456)
+	f("4294967295:4294967295", 4294967295, 4294967295)
+}
+
+func TestNewTokenFailure(t *testing.T) {
+	f := func(authToken string) {
+		t.Helper()
+		at, err := NewToken(authToken)
+		if err == nil {
+			t.Fatalf("expecting non-nil error for %q", authToken)
+		}
+		if at != nil {
+			t.Fatalf("expecting nil token for %q; got %+v", authToken, at)
+		}
+	}
+	f("")
+	f(":")
+	f("foo")
+	f("-1")
+	f("123:")
+	f("123:foo")
+	f("123:456:789")
+	f("4294967296")
+}
+
+func TestParsePath(t *testing.T) {
+	f := func(path, prefix string, accountID, projectID uint32, suffixExpected string) {
+		t.Helper()
+		at, suffix, err := ParsePath(path, prefix)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", path, err)
+		}
+		if at.AccountID != accountID || at.ProjectID != projectID {
+			t.Fatalf("unexpected token for %q; got %s; want %d:%d", path, at, accountID, projectID)
+		}
+		if suffix != suffixExpected {
+			t.Fatalf("unexpected suffix for %q; got %q; want %q", path, suffix, suffixExpected)
+		}
+	}
+	f("/api/v1/write", "/insert/", 0, 0, "/api/v1/write")
+	f("/insert/12/api/v1/write", "/insert/", 12, 0, "/api/v1/write")
+	f("/select/12:34/api/v1/query", "/select/", 12, 34, "/api/v1/query")
+
+	// Invalid paths
+	for _, path := range []string{"/insert/12", "/insert/foo/api/v1/write", "/insert//api/v1/write"} {
+		if _, _, err := ParsePath(path, "/insert/"); err == nil {
+			t.Fatalf("expecting non-nil error for %q", path)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package bloomfilter
+
+import (
+	"sync/atomic"
+)
+
+// bitsPerItem is the number of bits per item in the filter.
+//
+// 16 bits per item with 4 hashes gives false positive rate of ~0.24%.
+const bitsPerItem = 16
+
+// hashesCount is the number of hashes per item.
+const hashesCount = 4
+
+// filter is a bloom filter for uint64 hashes.
+//
+// It is safe calling filter methods from concurrent goroutines.
+type filter struct {
+	bits []uint64
+}
+
+func newFilter(maxItems int) *filter {
+	bitsCount := maxItems * bitsPerItem
+	wordsCount := (bitsCount + 63) / 64
+	if wordsCount < 1 {
+		wordsCount = 1
+	}
+	return &filter{
+		bits: make([]uint64, wordsCount),
+	}
+}
+
+// Has returns true if h may be in f.
+func (f *filter) Has(h uint64) bool {
+	bits := f.bits
+	maxBits := uint64(len(bits)) * 64
+	h1, h2 := splitHash(h)
+	for i := uint64(0); i < hashesCount; i++ {
+		bitIdx := (h1 + i*h2) % maxBits
+		wordIdx := bitIdx / 64
+		mask := uint64(1) << (bitIdx % 64)
+		if atomic.LoadUint64(&bits[wordIdx])&mask == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Add adds h to f.
+//
+// It returns true if f didn't contain h before the call.
+func (f *filter) Add(h uint64) bool {
+	bits := f.bits
+	maxBits := uint64(len(bits)) * 64
+	h1, h2 := splitHash(h)
+	isNew := false
+	for i := uint64(0); i < hashesCount; i++ {
+		bitIdx := (h1 + i*h2) % maxBits
+		wordIdx := bitIdx / 64
+		mask := uint64(1) << (bitIdx % 64)
+		p := &bits[wordIdx]
+		for {
+			w := atomic.LoadUint64(p)
+			if w&mask != 0 {
+				break
+			}
+			if atomic.CompareAndSwapUint64(p, w, w|mask) {
+				isNew = true
+				break
+			}
+		}
+	}
+	return isNew
+}
+
+// splitHash derives two hashes from h for double hashing.
+//
+// See https://www.eecs.harvard.edu/~michaelm/postscripts/rsa2008.pdf
+func splitHash(h uint64) (uint64, uint64) {
+	h1 := h
+	// Mix h with a multiplicative hash in order to obtain the second hash.
+	h2 := (h ^ (h >> 33)) * 0xff51afd7ed558ccd
+	h2 ^= h2 >> 33
+	// The second hash mustn't be zero, otherwise all the hashes for the item are equal.
+	h2 |= 1
+	return h1, h2
+}
@@ -0,0 +1,55 @@
+package bloomfilter
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	for _, maxItems := range []int{0, 1, 10, 1000, 100000} {
+		testFilter(t, maxItems)
+	}
+}
+
+func testFilter(t *testing.T, maxItems int) {
+	t.Helper()
+	f := newFilter(maxItems)
+	r := rand.New(rand.NewSource(1))
+
+	// Add items
+	items := make(map[uint64]struct{})
+	for len(items) < maxItems {
+		h := r.Uint64()
+		items[h] = struct{}{}
+		f.Add(h)
+	}
+
+	// Verify there are no false negatives.
+	for h := range items {
+		if !f.Has(h) {
+			t.Fatalf("cannot find item %d in the filter with maxItems=%d", h, maxItems)
+		}
+		if f.Add(h) {
+			t.Fatalf("unexpected new item %d in the filter with maxItems=%d", h, maxItems)
+		}
+	}
+
+	// Verify the false positive rate.
+	if maxItems < 1000 {
+		return
+	}
+	falsePositives := 0
+	for i := 0; i < maxItems; i++ {
+		h := r.Uint64()
+		if _, ok := items[h]; ok {
+			continue
+		}
+		if f.Has(h) {
+			falsePositives++
+		}
+	}
+	p := float64(falsePositives) / float64(maxItems)
+	if p > 0.01 {
+		t.Fatalf("too big false positive rate for maxItems=%d: %.4f", maxItems, p)
+	}
+}
@@ -0,0 +1,137 @@
+package bloomfilter
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limiter limits the number of unique items passed to Add during refreshInterval.
+//
+// It uses a bloom filter for tracking the added items, so it needs ~2 bytes per item.
+// Items registered during the previous refreshInterval are always accepted,
+// so the limit isn't applied to the existing items right after the refresh.
+// The number of unique items may slightly exceed maxItems because of bloom filter
+// false positives, concurrent Add calls and the items from the previous refreshInterval.
+//
+// It is safe calling Limiter methods from concurrent goroutines.
+type Limiter struct {
+	maxItems int
+	v        atomic.Value
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewLimiter returns new Limiter for up to maxItems unique items during refreshInterval.
+//
+// MustStop must be called when the Limiter is no longer needed.
+func NewLimiter(maxItems int, refreshInterval time.Duration) *Limiter {
+	l := &Limiter{
+		maxItems: maxItems,
+		stopCh:   make(chan struct{}),
+	}
+	l.v.Store(newLimiter(maxItems, nil))
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		t := time.NewTicker(refreshInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				l.refresh()
+			case <-l.stopCh:
+				return
+			}
+		}
+	}()
+	return l
+}
+
+// MustStop stops the given limiter.
+//
+// It is expected that nobody access the limiter at MustStop call.
+func (l *Limiter) MustStop() {
+	close(l.stopCh)
+	l.wg.Wait()
+}
+
+// refresh starts new refreshInterval for l.
+//
+// Items from the current refreshInterval become items from the previous refreshInterval.
+func (l *Limiter) refresh() {
+	lm := l.v.Load().(*limiter)
+	l.v.Store(newLimiter(l.maxItems, lm.f))
+}
+
+// MaxItems returns the maxItems passed to NewLimiter.
+func (l *Limiter) MaxItems() int {
+	return l.maxItems
+}
+
+// CurrentItems returns the number of unique items registered in l during the current refreshInterval.
+func (l *Limiter) CurrentItems() int {
+	lm := l.v.Load().(*limiter)
+	n := atomic.LoadUint64(&lm.currentItems)
+	return int(n)
+}
+
+// Fits returns true if Add(h) would accept h.
+//
+// This allows checking h against multiple limiters before registering it in any of them.
+func (l *Limiter) Fits(h uint64) bool {
+	lm := l.v.Load().(*limiter)
+	return lm.Fits(h)
+}
+
+// Add adds h to l and returns true if h fits the limit.
+//
+// False is returned if h is a new item and the limit on the number
+// of unique items is already reached.
+func (l *Limiter) Add(h uint64) bool {
+	lm := l.v.Load().(*limiter)
+	return lm.Add(h)
+}
+
+type limiter struct {
+	// currentItems must be the first field in the struct for proper alignment of atomic ops on 32-bit arches.
+	currentItems uint64
+	maxItems     uint64
+	f            *filter
+
+	// prevF contains items registered during the previous refreshInterval.
+	// It is nil during the first refreshInterval.
+	prevF *filter
+}
+
+func newLimiter(maxItems int, prevF *filter) *limiter {
+	return &limiter{
+		maxItems: uint64(maxItems),
+		f:        newFilter(maxItems),
+		prevF:    prevF,
+	}
+}
+
+func (l *limiter) Fits(h uint64) bool {
+	return l.f.Has(h) || l.hasPrev(h) || atomic.LoadUint64(&l.currentItems) < l.maxItems
+}
+
+func (l *limiter) Add(h uint64) bool {
+	if l.f.Has(h) {
+		return true
+	}
+	if atomic.LoadUint64(&l.currentItems) >= l.maxItems && !l.hasPrev(h) {
+		return false
+	}
+	// Items from the previous refreshInterval are moved to the current refreshInterval,
+	// so they remain accepted after the next refresh.
+	if l.f.Add(h) {
+		atomic.AddUint64(&l.currentItems, 1)
+	}
+	return true
+}
+
+func (l *limiter) hasPrev(h uint64) bool {
+	return l.prevF != nil && l.prevF.Has(h)
+}
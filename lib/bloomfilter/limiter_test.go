@@ -0,0 +1,133 @@
+package bloomfilter
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	for _, maxItems := range []int{1, 10, 1000, 10000} {
+		testLimiter(t, maxItems)
+	}
+}
+
+func testLimiter(t *testing.T, maxItems int) {
+	t.Helper()
+	l := NewLimiter(maxItems, time.Hour)
+	defer l.MustStop()
+
+	if n := l.MaxItems(); n != maxItems {
+		t.Fatalf("unexpected MaxItems; got %d; want %d", n, maxItems)
+	}
+
+	// Fill the limiter.
+	for i := 0; i < maxItems; i++ {
+		if !l.Add(uint64(i)) {
+			t.Fatalf("item %d must fit maxItems=%d", i, maxItems)
+		}
+	}
+	if n := l.CurrentItems(); n > maxItems {
+		t.Fatalf("too big CurrentItems; got %d; mustn't exceed %d", n, maxItems)
+	}
+
+	// Existing items must be accepted.
+	for i := 0; i < maxItems; i++ {
+		if !l.Add(uint64(i)) {
+			t.Fatalf("existing item %d must be accepted for maxItems=%d", i, maxItems)
+		}
+	}
+
+	// The majority of new items must be rejected. Some of them may be accepted because of false positives.
+	accepted := 0
+	for i := maxItems; i < 2*maxItems; i++ {
+		if l.Add(uint64(i) * 0x9e3779b97f4a7c15) {
+			accepted++
+		}
+	}
+	if accepted > maxItems/100 {
+		t.Fatalf("too many new items accepted for maxItems=%d: %d", maxItems, accepted)
+	}
+}
+
+func TestLimiterRefresh(t *testing.T) {
+	l := NewLimiter(1, 10*time.Millisecond)
+	defer l.MustStop()
+
+	if !l.Add(1) {
+		t.Fatalf("the first item must be accepted")
+	}
+	if l.Add(2) {
+		t.Fatalf("the second item must be rejected")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !l.Add(2) {
+		if time.Now().After(deadline) {
+			t.Fatalf("the second item must be accepted after the limiter refresh")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLimiterPrevItems(t *testing.T) {
+	l := NewLimiter(1, time.Hour)
+	defer l.MustStop()
+
+	if !l.Add(1) {
+		t.Fatalf("the first item must be accepted")
+	}
+	l.refresh()
+	if !l.Add(2) {
+		t.Fatalf("new item must be accepted after the refresh")
+	}
+	if !l.Add(1) {
+		t.Fatalf("the item from the previous interval must be accepted after the refresh")
+	}
+	if l.Add(3) {
+		t.Fatalf("new item over the limit must be rejected")
+	}
+
+	// The item from the previous interval must be moved to the current interval.
+	l.refresh()
+	if !l.Fits(1) || !l.Fits(2) {
+		t.Fatalf("items from the previous interval must fit the limit")
+	}
+	if !l.Add(1) {
+		t.Fatalf("the item from the previous interval must be accepted")
+	}
+	if l.Fits(3) || l.Add(3) {
+		t.Fatalf("new item over the limit must be rejected")
+	}
+
+	// Items missing during the whole interval must be forgotten.
+	l.refresh()
+	l.refresh()
+	if !l.Add(3) {
+		t.Fatalf("new item must be accepted after forgetting old items")
+	}
+	if l.Fits(1) || l.Add(1) {
+		t.Fatalf("forgotten item over the limit must be rejected")
+	}
+}
+
+func TestLimiterConcurrent(t *testing.T) {
+	const maxItems = 1000
+	l := NewLimiter(maxItems, time.Hour)
+	defer l.MustStop()
+
+	const concurrency = 4
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10*maxItems; j++ {
+				l.Add(uint64(j))
+			}
+		}()
+	}
+	wg.Wait()
+	if n := l.CurrentItems(); n > maxItems+concurrency {
+		t.Fatalf("too big CurrentItems; got %d; mustn't exceed %d", n, maxItems+concurrency)
+	}
+}
@@ -27,6 +27,10 @@ func CalibrateScale(a []int64, ae int16, b []int64, be int16) (e int16) {
 	upExp := ae - be
 	downExp := int16(0)
 	for _, v := range a {
+		if v == vStaleNaN {
+			// Staleness marks are stored as is, so they do not limit upExp.
+			continue
+		}
 		maxUpExp := maxUpExponent(v)
 		if upExp-maxUpExp > downExp {
 			downExp = upExp - maxUpExp
@@ -34,6 +38,9 @@ func CalibrateScale(a []int64, ae int16, b []int64, be int16) (e int16) {
 	}
 	upExp -= downExp
 	for i, v := range a {
+		if v == vStaleNaN {
+			continue
+		}
 		adjExp := upExp
 		for adjExp > 0 {
 			v *= 10
@@ -43,7 +50,7 @@ func CalibrateScale(a []int64, ae int16, b []int64, be int16) (e int16) {
 	}
 	if downExp > 0 {
 		for i, v := range b {
-			if v == vInfPos || v == vInfNeg {
+			if IsSpecialValue(v) {
 				// Special case for these values - do not touch them.
 				continue
 			}
@@ -92,6 +99,8 @@ func AppendDecimalToFloat(dst []float64, va []int64, e int16) []float64 {
 			f = infPos
 		} else if v == vInfNeg {
 			f = infNeg
+		} else if v == vStaleNaN {
+			f = StaleNaN
 		} else {
 			f = float64(v) * e10
 		}
@@ -124,23 +133,29 @@ func AppendFloatToDecimal(dst []int64, src []float64) (va []int64, e int16) {
 	vae.ea = vae.ea[:0]
 
 	// Determine the minimum exponent across all src items.
-	v, exp := FromFloat(src[0])
-	vae.va = append(vae.va, v)
-	vae.ea = append(vae.ea, exp)
-	minExp := exp
-	for _, f := range src[1:] {
+	// Staleness marks are skipped, since they are stored as is
+	// and mustn't reduce the precision for the remaining items.
+	minExp := int16(math.MaxInt16)
+	for _, f := range src {
 		v, exp := FromFloat(f)
 		vae.va = append(vae.va, v)
 		vae.ea = append(vae.ea, exp)
-		if exp < minExp {
+		if exp < minExp && v != vStaleNaN {
 			minExp = exp
 		}
 	}
+	if minExp == math.MaxInt16 {
+		// All the src items are staleness marks.
+		minExp = 0
+	}
 
 	// Determine whether all the src items may be upscaled to minExp.
 	// If not, adjust minExp accordingly.
 	downExp := int16(0)
 	for i, v := range vae.va {
+		if v == vStaleNaN {
+			continue
+		}
 		exp := vae.ea[i]
 		upExp := exp - minExp
 		maxUpExp := maxUpExponent(v)
@@ -152,6 +167,11 @@ func AppendFloatToDecimal(dst []int64, src []float64) (va []int64, e int16) {
 
 	// Scale each item in src to minExp and append it to dst.
 	for i, v := range vae.va {
+		if IsSpecialValue(v) {
+			// Special case for these values - do not touch them.
+			dst = append(dst, v)
+			continue
+		}
 		exp := vae.ea[i]
 		adjExp := exp - minExp
 		for adjExp > 0 {
@@ -243,12 +263,16 @@ func ToFloat(v int64, e int16) float64 {
 	if v == vInfNeg {
 		return infNeg
 	}
+	if v == vStaleNaN {
+		return StaleNaN
+	}
 	return float64(v) * math.Pow10(int(e))
 }
 
 const (
-	vInfPos = 1<<63 - 1
-	vInfNeg = -1 << 63
+	vInfPos   = 1<<63 - 1
+	vInfNeg   = -1 << 63
+	vStaleNaN = 1<<63 - 2
 
 	vMax = 1<<63 - 3
 	vMin = -1<<63 + 1
@@ -259,13 +283,38 @@ var (
 	infNeg = math.Inf(-1)
 )
 
+// StaleNaN is a special NaN value, which is used as Prometheus staleness mark.
+//
+// Prometheus sends it when the series disappears from the scrape target.
+// See https://prometheus.io/docs/prometheus/latest/querying/basics/#staleness
+var StaleNaN = math.Float64frombits(staleNaNBits)
+
+const staleNaNBits = 0x7ff0000000000002
+
+// IsStaleNaN returns true if f is Prometheus staleness mark.
+func IsStaleNaN(f float64) bool {
+	return math.Float64bits(f) == staleNaNBits
+}
+
+// IsSpecialValue returns true if v is a decimal representation of a special value
+// such as Inf or Prometheus staleness mark.
+//
+// Special values must be stored without precision loss.
+func IsSpecialValue(v int64) bool {
+	return v == vInfPos || v == vInfNeg || v == vStaleNaN
+}
+
 // FromFloat converts f to v*10^e.
 //
 // It tries minimizing v.
 // For instance, for f = -1.234 it returns v = -1234, e = -3.
 //
-// FromFloat doesn't work properly with NaN values, so don't pass them here.
+// FromFloat doesn't work properly with NaN values other than StaleNaN, so don't pass them here.
 func FromFloat(f float64) (v int64, e int16) {
+	if IsStaleNaN(f) {
+		// Special case for Prometheus staleness mark
+		return vStaleNaN, 0
+	}
 	if math.IsInf(f, 0) {
 		// Special case for Inf
 		if math.IsInf(f, 1) {
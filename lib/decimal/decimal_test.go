@@ -58,6 +58,8 @@ func TestCalibrateScale(t *testing.T) {
 	testCalibrateScale(t, []int64{vInfPos, 1200}, []int64{35, 1}, 0, 40, []int64{vInfPos, 0}, []int64{35e17, 1e17}, 23)
 	testCalibrateScale(t, []int64{vInfPos, 1200}, []int64{35, 1}, 40, 0, []int64{vInfPos, 1200}, []int64{0, 0}, 40)
 	testCalibrateScale(t, []int64{vInfNeg, 1200}, []int64{35, 1}, 35, -5, []int64{vInfNeg, 1200}, []int64{0, 0}, 35)
+	testCalibrateScale(t, []int64{vStaleNaN, 1200}, []int64{35, 1}, 0, 40, []int64{vStaleNaN, 0}, []int64{35e17, 1e17}, 23)
+	testCalibrateScale(t, []int64{vStaleNaN, 1200}, []int64{35, 1}, 40, 0, []int64{vStaleNaN, 1200e15}, []int64{0, 0}, 25)
 	testCalibrateScale(t, []int64{vMax, vMin, 123}, []int64{100}, 0, 3, []int64{vMax, vMin, 123}, []int64{100e3}, 0)
 	testCalibrateScale(t, []int64{vMax, vMin, 123}, []int64{100}, 3, 0, []int64{vMax, vMin, 123}, []int64{0}, 3)
 	testCalibrateScale(t, []int64{vMax, vMin, 123}, []int64{100}, 0, 30, []int64{92233, -92233, 0}, []int64{100e16}, 14)
@@ -177,6 +179,11 @@ func TestAppendFloatToDecimal(t *testing.T) {
 	// downExp
 	testAppendFloatToDecimal(t, []float64{3e17, 7e-2, 5e-7, 45, 7e-1}, []int64{3e18, 0, 0, 450, 7}, -1)
 	testAppendFloatToDecimal(t, []float64{3e18, 1, 0.1, 13}, []int64{3e18, 1, 0, 13}, 0)
+
+	// special values
+	testAppendFloatToDecimal(t, []float64{StaleNaN, 1.5, 3}, []int64{vStaleNaN, 15, 30}, -1)
+	testAppendFloatToDecimal(t, []float64{StaleNaN, StaleNaN}, []int64{vStaleNaN, vStaleNaN}, 0)
+	testAppendFloatToDecimal(t, []float64{math.Inf(1), 5e18, 1e19}, []int64{vInfPos, 5e17, 1e18}, 1)
 }
 
 func testAppendFloatToDecimal(t *testing.T, fa []float64, daExpected []int64, eExpected int16) {
@@ -237,6 +244,7 @@ func TestFloatToDecimal(t *testing.T) {
 
 	testFloatToDecimal(t, math.Inf(1), vInfPos, 0)
 	testFloatToDecimal(t, math.Inf(-1), vInfNeg, 0)
+	testFloatToDecimal(t, StaleNaN, vStaleNaN, 0)
 	testFloatToDecimal(t, 1<<63-1, 922337203685, 7)
 	testFloatToDecimal(t, -1<<63, -922337203685, 7)
 }
@@ -253,6 +261,31 @@ func testFloatToDecimal(t *testing.T, f float64, vExpected int64, eExpected int1
 	}
 }
 
+func TestStaleNaN(t *testing.T) {
+	if !IsStaleNaN(StaleNaN) {
+		t.Fatalf("IsStaleNaN must return true for StaleNaN")
+	}
+	if !math.IsNaN(StaleNaN) {
+		t.Fatalf("StaleNaN must be NaN")
+	}
+	if IsStaleNaN(math.NaN()) {
+		t.Fatalf("IsStaleNaN must return false for ordinary NaN")
+	}
+	if IsStaleNaN(0) {
+		t.Fatalf("IsStaleNaN must return false for 0")
+	}
+
+	v, e := FromFloat(StaleNaN)
+	if f := ToFloat(v, e); !IsStaleNaN(f) {
+		t.Fatalf("unexpected ToFloat result for StaleNaN; got %v", f)
+	}
+	va, e := AppendFloatToDecimal(nil, []float64{1.25, StaleNaN, -3})
+	fa := AppendDecimalToFloat(nil, va, e)
+	if len(fa) != 3 || fa[0] != 1.25 || !IsStaleNaN(fa[1]) || fa[2] != -3 {
+		t.Fatalf("unexpected roundtrip result for values with StaleNaN; got %v", fa)
+	}
+}
+
 func TestFloatToDecimalRoundtrip(t *testing.T) {
 	testFloatToDecimalRoundtrip(t, 0)
 	testFloatToDecimalRoundtrip(t, 1)
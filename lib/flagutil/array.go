@@ -0,0 +1,19 @@
+package flagutil
+
+import (
+	"strings"
+)
+
+// ParseArray returns items from comma-separated s.
+//
+// Leading and trailing whitespace is removed from items. Empty items are skipped.
+func ParseArray(s string) []string {
+	var a []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) > 0 {
+			a = append(a, item)
+		}
+	}
+	return a
+}
@@ -0,0 +1,21 @@
+package flagutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArray(t *testing.T) {
+	f := func(s string, aExpected []string) {
+		t.Helper()
+		a := ParseArray(s)
+		if !reflect.DeepEqual(a, aExpected) {
+			t.Fatalf("unexpected result for ParseArray(%q); got %q; want %q", s, a, aExpected)
+		}
+	}
+	f("", nil)
+	f(" , ,", nil)
+	f("foo", []string{"foo"})
+	f("vmstorage-1:8400,vmstorage-2:8400", []string{"vmstorage-1:8400", "vmstorage-2:8400"})
+	f(" vmstorage-1:8400 ,, vmstorage-2:8400, ", []string{"vmstorage-1:8400", "vmstorage-2:8400"})
+}
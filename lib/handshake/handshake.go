@@ -0,0 +1,126 @@
+package handshake
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	vminsertHello = "vminsert.01"
+	vmselectHello = "vmselect.01"
+
+	successResponse = "ok"
+)
+
+// Func must perform handshake on the given c.
+type Func func(c net.Conn) (*BufferedConn, error)
+
+// VMInsertClient performs client-side handshake for vminsert protocol.
+func VMInsertClient(c net.Conn) (*BufferedConn, error) {
+	return genericClient(c, vminsertHello)
+}
+
+// VMInsertServer performs server-side handshake for vminsert protocol.
+func VMInsertServer(c net.Conn) (*BufferedConn, error) {
+	return genericServer(c, vminsertHello)
+}
+
+// VMSelectClient performs client-side handshake for vmselect protocol.
+func VMSelectClient(c net.Conn) (*BufferedConn, error) {
+	return genericClient(c, vmselectHello)
+}
+
+// VMSelectServer performs server-side handshake for vmselect protocol.
+func VMSelectServer(c net.Conn) (*BufferedConn, error) {
+	return genericServer(c, vmselectHello)
+}
+
+func genericServer(c net.Conn, msg string) (*BufferedConn, error) {
+	if err := readMessage(c, msg); err != nil {
+		return nil, fmt.Errorf("cannot read hello: %s", err)
+	}
+	if err := writeMessage(c, successResponse); err != nil {
+		return nil, fmt.Errorf("cannot write success response on hello: %s", err)
+	}
+	return newBufferedConn(c), nil
+}
+
+func genericClient(c net.Conn, msg string) (*BufferedConn, error) {
+	if err := writeMessage(c, msg); err != nil {
+		return nil, fmt.Errorf("cannot write hello: %s", err)
+	}
+	if err := readMessage(c, successResponse); err != nil {
+		return nil, fmt.Errorf("cannot read success response after sending hello: %s", err)
+	}
+	return newBufferedConn(c), nil
+}
+
+func writeMessage(c net.Conn, msg string) error {
+	if err := c.SetWriteDeadline(time.Now().Add(time.Second)); err != nil {
+		return fmt.Errorf("cannot set write deadline: %s", err)
+	}
+	if _, err := io.WriteString(c, msg); err != nil {
+		return fmt.Errorf("cannot write %q to server: %s", msg, err)
+	}
+	if err := c.SetWriteDeadline(time.Time{}); err != nil {
+		return fmt.Errorf("cannot reset write deadline: %s", err)
+	}
+	return nil
+}
+
+func readMessage(c net.Conn, msg string) error {
+	if err := c.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		return fmt.Errorf("cannot set read deadline: %s", err)
+	}
+	buf := make([]byte, len(msg))
+	if _, err := io.ReadFull(c, buf); err != nil {
+		return fmt.Errorf("cannot read message %q: %s", msg, err)
+	}
+	if string(buf) != msg {
+		return fmt.Errorf("unexpected message obtained; got %q; want %q", buf, msg)
+	}
+	if err := c.SetReadDeadline(time.Time{}); err != nil {
+		return fmt.Errorf("cannot reset read deadline: %s", err)
+	}
+	return nil
+}
+
+// BufferedConn is a net.Conn with buffered Read and Write.
+//
+// Call Flush after the message is written to BufferedConn.
+type BufferedConn struct {
+	net.Conn
+
+	br *bufio.Reader
+	bw *bufio.Writer
+}
+
+const bufferSize = 64 * 1024
+
+func newBufferedConn(c net.Conn) *BufferedConn {
+	return &BufferedConn{
+		Conn: c,
+		br:   bufio.NewReaderSize(c, bufferSize),
+		bw:   bufio.NewWriterSize(c, bufferSize),
+	}
+}
+
+// Read reads up to len(p) bytes from bc to p.
+func (bc *BufferedConn) Read(p []byte) (int, error) {
+	return bc.br.Read(p)
+}
+
+// Write writes p to bc.
+//
+// Do not forget to call Flush if needed.
+func (bc *BufferedConn) Write(p []byte) (int, error) {
+	return bc.bw.Write(p)
+}
+
+// Flush flushes buffered data to the underlying connection.
+func (bc *BufferedConn) Flush() error {
+	return bc.bw.Flush()
+}
@@ -0,0 +1,104 @@
+package handshake
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestVMInsertHandshake(t *testing.T) {
+	testHandshake(t, VMInsertClient, VMInsertServer)
+}
+
+func TestVMSelectHandshake(t *testing.T) {
+	testHandshake(t, VMSelectClient, VMSelectServer)
+}
+
+func TestHandshakeMismatch(t *testing.T) {
+	c, s := newTestConns(t)
+	defer c.Close()
+	defer s.Close()
+
+	ch := make(chan error, 1)
+	go func() {
+		_, err := VMSelectServer(s)
+		ch <- err
+	}()
+	if _, err := VMInsertClient(c); err == nil {
+		t.Fatalf("expecting non-nil error on client side")
+	}
+	if err := <-ch; err == nil {
+		t.Fatalf("expecting non-nil error on server side")
+	}
+}
+
+func testHandshake(t *testing.T, clientFunc, serverFunc Func) {
+	t.Helper()
+
+	c, s := newTestConns(t)
+	defer c.Close()
+	defer s.Close()
+
+	ch := make(chan error, 1)
+	go func() {
+		bcs, err := serverFunc(s)
+		if err != nil {
+			ch <- fmt.Errorf("error on server-side handshake: %s", err)
+			return
+		}
+		buf := make([]byte, 5)
+		if _, err := bcs.Read(buf); err != nil {
+			ch <- fmt.Errorf("cannot read data on server side: %s", err)
+			return
+		}
+		if _, err := bcs.Write(buf); err != nil {
+			ch <- fmt.Errorf("cannot write data on server side: %s", err)
+			return
+		}
+		ch <- bcs.Flush()
+	}()
+	bcc, err := clientFunc(c)
+	if err != nil {
+		t.Fatalf("error on client-side handshake: %s", err)
+	}
+	if _, err := bcc.Write([]byte("hello")); err != nil {
+		t.Fatalf("cannot write data on client side: %s", err)
+	}
+	if err := bcc.Flush(); err != nil {
+		t.Fatalf("cannot flush data on client side: %s", err)
+	}
+	buf := make([]byte, 5)
+	if _, err := bcc.Read(buf); err != nil {
+		t.Fatalf("cannot read data on client side: %s", err)
+	}
+	if string(buf) != "hello" {
+		t.Fatalf("unexpected data read on client side; got %q; want %q", buf, "hello")
+	}
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Fatalf("server error: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timeout")
+	}
+}
+
+func newTestConns(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot start listener: %s", err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("cannot dial listener: %s", err)
+	}
+	s, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("cannot accept connection: %s", err)
+	}
+	return c, s
+}
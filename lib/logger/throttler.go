@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Throttler limits the rate of log messages, which may be emitted on hot paths.
+//
+// It is safe calling Throttler methods from concurrent goroutines.
+type Throttler struct {
+	// lastLogTime must be the first field in the struct for proper alignment of atomic ops on 32-bit arches.
+	lastLogTime uint64
+	interval    uint64
+}
+
+// NewThrottler returns new Throttler, which allows a single log message per the given interval.
+func NewThrottler(interval time.Duration) *Throttler {
+	seconds := uint64(interval / time.Second)
+	if seconds == 0 {
+		seconds = 1
+	}
+	return &Throttler{
+		interval: seconds,
+	}
+}
+
+// Allow returns true if the caller may emit a log message now.
+//
+// It returns true at most once per the interval passed to NewThrottler.
+func (t *Throttler) Allow() bool {
+	currentTime := uint64(time.Now().Unix())
+	lastLogTime := atomic.LoadUint64(&t.lastLogTime)
+	if currentTime < lastLogTime+t.interval {
+		return false
+	}
+	// Another goroutine may be already logging the message.
+	return atomic.CompareAndSwapUint64(&t.lastLogTime, lastLogTime, currentTime)
+}
@@ -399,6 +399,11 @@ func (tb *Table) mergePartsOptimal(pws []*partWrapper) error {
 //
 // This function is only for debugging and testing.
 func (tb *Table) DebugFlush() {
+	tb.MustFlush()
+}
+
+// MustFlush flushes all the items added to tb before the call to disk.
+func (tb *Table) MustFlush() {
 	tb.flushRawItems(true)
 
 	// Wait for background flushers to finish.
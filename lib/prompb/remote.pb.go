@@ -208,3 +208,277 @@ var (
 	errInvalidLengthRemote = fmt.Errorf("proto: negative length found during unmarshaling")
 	errIntOverflowRemote   = fmt.Errorf("proto: integer overflow")
 )
+
+// ReadRequest represents Prometheus remote read API request
+type ReadRequest struct {
+	Queries []Query
+
+	matchersPool []LabelMatcher
+}
+
+// Query is a single query in ReadRequest.
+type Query struct {
+	StartTimestampMs int64
+	EndTimestampMs   int64
+	Matchers         []LabelMatcher
+}
+
+// ReadResponse represents Prometheus remote read API response.
+type ReadResponse struct {
+	// In same order as the request's queries.
+	Results []QueryResult
+}
+
+// QueryResult is a result for a single query in ReadResponse.
+type QueryResult struct {
+	Timeseries []TimeSeries
+}
+
+// Unmarshal unmarshals m from dAtA.
+func (m *ReadRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return errIntOverflowRemote
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ReadRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ReadRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Queries", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return errIntOverflowRemote
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return errInvalidLengthRemote
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if cap(m.Queries) > len(m.Queries) {
+				m.Queries = m.Queries[:len(m.Queries)+1]
+			} else {
+				m.Queries = append(m.Queries, Query{})
+			}
+			q := &m.Queries[len(m.Queries)-1]
+			var err error
+			m.matchersPool, err = q.Unmarshal(dAtA[iNdEx:postIndex], m.matchersPool)
+			if err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRemote(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return errInvalidLengthRemote
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// Unmarshal unmarshals m from dAtA.
+func (m *Query) Unmarshal(dAtA []byte, dstMatchers []LabelMatcher) ([]LabelMatcher, error) {
+	matchersStart := len(dstMatchers)
+	m.StartTimestampMs = 0
+	m.EndTimestampMs = 0
+
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return dstMatchers, errIntOverflowRemote
+			}
+			if iNdEx >= l {
+				return dstMatchers, io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return dstMatchers, fmt.Errorf("proto: Query: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return dstMatchers, fmt.Errorf("proto: Query: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1, 2:
+			if wireType != 0 {
+				return dstMatchers, fmt.Errorf("proto: wrong wireType = %d for field number %d", wireType, fieldNum)
+			}
+			var v int64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return dstMatchers, errIntOverflowRemote
+				}
+				if iNdEx >= l {
+					return dstMatchers, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if fieldNum == 1 {
+				m.StartTimestampMs = v
+			} else {
+				m.EndTimestampMs = v
+			}
+		case 3:
+			if wireType != 2 {
+				return dstMatchers, fmt.Errorf("proto: wrong wireType = %d for field Matchers", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return dstMatchers, errIntOverflowRemote
+				}
+				if iNdEx >= l {
+					return dstMatchers, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return dstMatchers, errInvalidLengthRemote
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return dstMatchers, io.ErrUnexpectedEOF
+			}
+			if cap(dstMatchers) > len(dstMatchers) {
+				dstMatchers = dstMatchers[:len(dstMatchers)+1]
+			} else {
+				dstMatchers = append(dstMatchers, LabelMatcher{})
+			}
+			lm := &dstMatchers[len(dstMatchers)-1]
+			if err := lm.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return dstMatchers, err
+			}
+			iNdEx = postIndex
+		default:
+			// Skip unsupported fields such as hints.
+			iNdEx = preIndex
+			skippy, err := skipRemote(dAtA[iNdEx:])
+			if err != nil {
+				return dstMatchers, err
+			}
+			if skippy < 0 {
+				return dstMatchers, errInvalidLengthRemote
+			}
+			if (iNdEx + skippy) > l {
+				return dstMatchers, io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return dstMatchers, io.ErrUnexpectedEOF
+	}
+
+	m.Matchers = dstMatchers[matchersStart:]
+	return dstMatchers, nil
+}
+
+// Marshal appends marshaled m to dst and returns the result.
+func (m *ReadResponse) Marshal(dst []byte) []byte {
+	for i := range m.Results {
+		qr := &m.Results[i]
+		dst = append(dst, 0xa)
+		dst = encodeVarintTypes(dst, uint64(qr.Size()))
+		dst = qr.Marshal(dst)
+	}
+	return dst
+}
+
+// Size returns the size of marshaled m.
+func (m *ReadResponse) Size() (n int) {
+	for i := range m.Results {
+		l := m.Results[i].Size()
+		n += 1 + l + sovTypes(uint64(l))
+	}
+	return n
+}
+
+// Marshal appends marshaled m to dst and returns the result.
+func (m *QueryResult) Marshal(dst []byte) []byte {
+	for i := range m.Timeseries {
+		ts := &m.Timeseries[i]
+		dst = append(dst, 0xa)
+		dst = encodeVarintTypes(dst, uint64(ts.Size()))
+		dst = ts.Marshal(dst)
+	}
+	return dst
+}
+
+// Size returns the size of marshaled m.
+func (m *QueryResult) Size() (n int) {
+	for i := range m.Timeseries {
+		l := m.Timeseries[i].Size()
+		n += 1 + l + sovTypes(uint64(l))
+	}
+	return n
+}
@@ -0,0 +1,132 @@
+package prompb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueryResultMarshalUnmarshal(t *testing.T) {
+	qr := &QueryResult{
+		Timeseries: []TimeSeries{
+			{
+				Labels: []Label{
+					{
+						Name:  []byte("__name__"),
+						Value: []byte("foo"),
+					},
+					{
+						Name:  []byte("job"),
+						Value: []byte("bar"),
+					},
+				},
+				Samples: []Sample{
+					{
+						Value:     1.25,
+						Timestamp: 1562529662000,
+					},
+					{
+						Value:     -42,
+						Timestamp: 1562529677000,
+					},
+				},
+			},
+			{
+				Labels: []Label{
+					{
+						Name:  []byte("__name__"),
+						Value: []byte("x"),
+					},
+				},
+				Samples: []Sample{
+					{
+						Value:     0,
+						Timestamp: -123,
+					},
+				},
+			},
+		},
+	}
+	resp := &ReadResponse{
+		Results: []QueryResult{*qr},
+	}
+	data := resp.Marshal(nil)
+	if len(data) != resp.Size() {
+		t.Fatalf("unexpected marshaled size; got %d; want %d", len(data), resp.Size())
+	}
+
+	// Unmarshal the first query result by hand and compare it to the original.
+	if data[0] != 0xa {
+		t.Fatalf("unexpected tag for ReadResponse.Results; got 0x%x; want 0xa", data[0])
+	}
+	qrLen := qr.Size()
+	tail := data[1+sovTypes(uint64(qrLen)):]
+	if len(tail) != qrLen {
+		t.Fatalf("unexpected QueryResult size; got %d; want %d", len(tail), qrLen)
+	}
+	var tss []TimeSeries
+	var labelsPool []Label
+	var samplesPool []Sample
+	for len(tail) > 0 {
+		if tail[0] != 0xa {
+			t.Fatalf("unexpected tag for QueryResult.Timeseries; got 0x%x; want 0xa", tail[0])
+		}
+		tsLen := int(tail[1])
+		var ts TimeSeries
+		var err error
+		labelsPool, samplesPool, err = ts.Unmarshal(tail[2:2+tsLen], labelsPool, samplesPool)
+		if err != nil {
+			t.Fatalf("cannot unmarshal TimeSeries: %s", err)
+		}
+		tss = append(tss, ts)
+		tail = tail[2+tsLen:]
+	}
+	if !reflect.DeepEqual(tss, qr.Timeseries) {
+		t.Fatalf("unexpected timeseries after unmarshaling;\ngot\n%+v\nwant\n%+v", tss, qr.Timeseries)
+	}
+}
+
+func TestReadRequestUnmarshal(t *testing.T) {
+	// ReadRequest{Queries: [{StartTimestampMs: 1000, EndTimestampMs: 2000, Matchers: [{EQ, "__name__", "up"}, {NRE, "job", "foo.+"}]}]}
+	data := []byte{
+		0xa, 0x26,
+		0x8, 0xe8, 0x7,
+		0x10, 0xd0, 0xf,
+		0x1a, 0xe, 0x12, 0x8, '_', '_', 'n', 'a', 'm', 'e', '_', '_', 0x1a, 0x2, 'u', 'p',
+		0x1a, 0xe, 0x8, 0x3, 0x12, 0x3, 'j', 'o', 'b', 0x1a, 0x5, 'f', 'o', 'o', '.', '+',
+	}
+	var rr ReadRequest
+	if err := rr.Unmarshal(data); err != nil {
+		t.Fatalf("cannot unmarshal ReadRequest: %s", err)
+	}
+	queriesExpected := []Query{
+		{
+			StartTimestampMs: 1000,
+			EndTimestampMs:   2000,
+			Matchers: []LabelMatcher{
+				{
+					Type:  LabelMatcher_EQ,
+					Name:  []byte("__name__"),
+					Value: []byte("up"),
+				},
+				{
+					Type:  LabelMatcher_NRE,
+					Name:  []byte("job"),
+					Value: []byte("foo.+"),
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(rr.Queries, queriesExpected) {
+		t.Fatalf("unexpected queries;\ngot\n%+v\nwant\n%+v", rr.Queries, queriesExpected)
+	}
+
+	rr.Reset()
+	if len(rr.Queries) != 0 {
+		t.Fatalf("non-empty queries after Reset: %+v", rr.Queries)
+	}
+
+	// Truncated request must result in error.
+	if err := rr.Unmarshal(data[:len(data)-3]); err == nil {
+		t.Fatalf("expecting non-nil error for truncated ReadRequest")
+	}
+}
@@ -453,3 +453,241 @@ var (
 	errInvalidLengthTypes = fmt.Errorf("proto: negative length found during unmarshaling")
 	errIntOverflowTypes   = fmt.Errorf("proto: integer overflow")
 )
+
+// LabelMatcher_Type is the type of LabelMatcher.
+type LabelMatcher_Type int32
+
+// LabelMatcher types.
+const (
+	LabelMatcher_EQ  LabelMatcher_Type = 0
+	LabelMatcher_NEQ LabelMatcher_Type = 1
+	LabelMatcher_RE  LabelMatcher_Type = 2
+	LabelMatcher_NRE LabelMatcher_Type = 3
+)
+
+// String returns string representation for t.
+func (t LabelMatcher_Type) String() string {
+	switch t {
+	case LabelMatcher_EQ:
+		return "EQ"
+	case LabelMatcher_NEQ:
+		return "NEQ"
+	case LabelMatcher_RE:
+		return "RE"
+	case LabelMatcher_NRE:
+		return "NRE"
+	default:
+		return fmt.Sprintf("LabelMatcher_Type(%d)", int32(t))
+	}
+}
+
+// LabelMatcher specifies a rule, which can match or not match a set of labels.
+type LabelMatcher struct {
+	Type  LabelMatcher_Type
+	Name  []byte
+	Value []byte
+}
+
+// Unmarshal unmarshals LabelMatcher from dAtA.
+func (m *LabelMatcher) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return errIntOverflowTypes
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: LabelMatcher: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: LabelMatcher: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Type", wireType)
+			}
+			m.Type = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return errIntOverflowTypes
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Type |= (LabelMatcher_Type(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2, 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field number %d", wireType, fieldNum)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return errIntOverflowTypes
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return errInvalidLengthTypes
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if fieldNum == 2 {
+				m.Name = dAtA[iNdEx:postIndex]
+			} else {
+				m.Value = dAtA[iNdEx:postIndex]
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTypes(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return errInvalidLengthTypes
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// Marshal appends marshaled m to dst and returns the result.
+func (m *Sample) Marshal(dst []byte) []byte {
+	if m.Value != 0 {
+		dst = append(dst, 0x9)
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(m.Value))
+		dst = append(dst, b[:]...)
+	}
+	if m.Timestamp != 0 {
+		dst = append(dst, 0x10)
+		dst = encodeVarintTypes(dst, uint64(m.Timestamp))
+	}
+	return dst
+}
+
+// Size returns the size of marshaled m.
+func (m *Sample) Size() (n int) {
+	if m.Value != 0 {
+		n += 9
+	}
+	if m.Timestamp != 0 {
+		n += 1 + sovTypes(uint64(m.Timestamp))
+	}
+	return n
+}
+
+// Marshal appends marshaled m to dst and returns the result.
+func (m *TimeSeries) Marshal(dst []byte) []byte {
+	for i := range m.Labels {
+		lb := &m.Labels[i]
+		dst = append(dst, 0xa)
+		dst = encodeVarintTypes(dst, uint64(lb.Size()))
+		dst = lb.Marshal(dst)
+	}
+	for i := range m.Samples {
+		s := &m.Samples[i]
+		dst = append(dst, 0x12)
+		dst = encodeVarintTypes(dst, uint64(s.Size()))
+		dst = s.Marshal(dst)
+	}
+	return dst
+}
+
+// Size returns the size of marshaled m.
+func (m *TimeSeries) Size() (n int) {
+	for i := range m.Labels {
+		l := m.Labels[i].Size()
+		n += 1 + l + sovTypes(uint64(l))
+	}
+	for i := range m.Samples {
+		l := m.Samples[i].Size()
+		n += 1 + l + sovTypes(uint64(l))
+	}
+	return n
+}
+
+// Marshal appends marshaled m to dst and returns the result.
+func (m *Label) Marshal(dst []byte) []byte {
+	if len(m.Name) > 0 {
+		dst = append(dst, 0xa)
+		dst = encodeVarintTypes(dst, uint64(len(m.Name)))
+		dst = append(dst, m.Name...)
+	}
+	if len(m.Value) > 0 {
+		dst = append(dst, 0x12)
+		dst = encodeVarintTypes(dst, uint64(len(m.Value)))
+		dst = append(dst, m.Value...)
+	}
+	return dst
+}
+
+// Size returns the size of marshaled m.
+func (m *Label) Size() (n int) {
+	if l := len(m.Name); l > 0 {
+		n += 1 + l + sovTypes(uint64(l))
+	}
+	if l := len(m.Value); l > 0 {
+		n += 1 + l + sovTypes(uint64(l))
+	}
+	return n
+}
+
+func encodeVarintTypes(dst []byte, v uint64) []byte {
+	for v >= 1<<7 {
+		dst = append(dst, uint8(v&0x7f|0x80))
+		v >>= 7
+	}
+	return append(dst, uint8(v))
+}
+
+func sovTypes(x uint64) (n int) {
+	for {
+		n++
+		x >>= 7
+		if x == 0 {
+			return n
+		}
+	}
+}
@@ -65,3 +65,22 @@ func (wr *WriteRequest) Reset() {
 	}
 	wr.samplesPool = wr.samplesPool[:0]
 }
+
+// Reset resets rr.
+func (rr *ReadRequest) Reset() {
+	for i := range rr.Queries {
+		q := &rr.Queries[i]
+		q.StartTimestampMs = 0
+		q.EndTimestampMs = 0
+		q.Matchers = nil
+	}
+	rr.Queries = rr.Queries[:0]
+
+	for i := range rr.matchersPool {
+		lm := &rr.matchersPool[i]
+		lm.Type = 0
+		lm.Name = nil
+		lm.Value = nil
+	}
+	rr.matchersPool = rr.matchersPool[:0]
+}
@@ -0,0 +1,118 @@
+package promrelabel
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// RelabelConfig represents relabel config.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#relabel_config
+type RelabelConfig struct {
+	SourceLabels []string `yaml:"source_labels"`
+	Separator    *string  `yaml:"separator"`
+	TargetLabel  string   `yaml:"target_label"`
+	Regex        *string  `yaml:"regex"`
+	Modulus      uint64   `yaml:"modulus"`
+	Replacement  *string  `yaml:"replacement"`
+	Action       string   `yaml:"action"`
+}
+
+// LoadRelabelConfigs loads relabel configs from the given path.
+//
+// The file must contain a list of relabel configs in Prometheus `relabel_configs` format.
+func LoadRelabelConfigs(path string) ([]ParsedRelabelConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read `relabel_configs` from %q: %s", path, err)
+	}
+	prcs, err := ParseRelabelConfigs(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `relabel_configs` from %q: %s", path, err)
+	}
+	return prcs, nil
+}
+
+// ParseRelabelConfigs parses a list of relabel configs from data.
+func ParseRelabelConfigs(data []byte) ([]ParsedRelabelConfig, error) {
+	var rcs []RelabelConfig
+	if err := yaml.UnmarshalStrict(data, &rcs); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal data: %s", err)
+	}
+	prcs := make([]ParsedRelabelConfig, 0, len(rcs))
+	for i := range rcs {
+		prc, err := parseRelabelConfig(&rcs[i])
+		if err != nil {
+			return nil, fmt.Errorf("error when parsing `relabel_config` #%d: %s", i+1, err)
+		}
+		prcs = append(prcs, *prc)
+	}
+	return prcs, nil
+}
+
+var defaultRegexForRelabelConfig = regexp.MustCompile("^(.*)$")
+
+func parseRelabelConfig(rc *RelabelConfig) (*ParsedRelabelConfig, error) {
+	sourceLabels := rc.SourceLabels
+	separator := ";"
+	if rc.Separator != nil {
+		separator = *rc.Separator
+	}
+	targetLabel := rc.TargetLabel
+	regexCompiled := defaultRegexForRelabelConfig
+	if rc.Regex != nil {
+		// Prometheus anchors regexps at both ends.
+		re, err := regexp.Compile("^(?:" + *rc.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse `regex` %q: %s", *rc.Regex, err)
+		}
+		regexCompiled = re
+	}
+	modulus := rc.Modulus
+	replacement := "$1"
+	if rc.Replacement != nil {
+		replacement = *rc.Replacement
+	}
+	action := strings.ToLower(rc.Action)
+	if action == "" {
+		action = "replace"
+	}
+	switch action {
+	case "replace":
+		if targetLabel == "" {
+			return nil, fmt.Errorf("missing `target_label` for `action=replace`")
+		}
+	case "keep", "drop":
+		if len(sourceLabels) == 0 {
+			return nil, fmt.Errorf("missing `source_labels` for `action=%s`", action)
+		}
+	case "hashmod":
+		if len(sourceLabels) == 0 {
+			return nil, fmt.Errorf("missing `source_labels` for `action=hashmod`")
+		}
+		if targetLabel == "" {
+			return nil, fmt.Errorf("missing `target_label` for `action=hashmod`")
+		}
+		if modulus < 1 {
+			return nil, fmt.Errorf("unexpected `modulus` for `action=hashmod`: %d; must be greater than 0", modulus)
+		}
+	case "labelmap", "labeldrop", "labelkeep":
+	default:
+		return nil, fmt.Errorf("unknown `action` %q; supported values: replace, keep, drop, hashmod, labelmap, labeldrop, labelkeep", action)
+	}
+	return &ParsedRelabelConfig{
+		SourceLabels: sourceLabels,
+		Separator:    separator,
+		TargetLabel:  targetLabel,
+		Regex:        regexCompiled,
+		Modulus:      modulus,
+		Replacement:  replacement,
+		Action:       action,
+
+		hasCaptureGroupInTargetLabel: strings.Contains(targetLabel, "$"),
+	}, nil
+}
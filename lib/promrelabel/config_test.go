@@ -0,0 +1,92 @@
+package promrelabel
+
+import (
+	"testing"
+)
+
+func TestParseRelabelConfigsFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		prcs, err := ParseRelabelConfigs([]byte(data))
+		if err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", data)
+		}
+		if prcs != nil {
+			t.Fatalf("expecting nil prcs; got %v", prcs)
+		}
+	}
+
+	// Invalid yaml
+	f("foo bar")
+
+	// Unknown field
+	f(`
+- action: drop
+  source_labels: [foo]
+  foobar: baz
+`)
+
+	// Invalid regex
+	f(`
+- action: drop
+  source_labels: [foo]
+  regex: "foo["
+`)
+
+	// Unknown action
+	f(`
+- action: foobar
+`)
+
+	// Missing target_label for replace
+	f(`
+- source_labels: [foo]
+`)
+
+	// Missing source_labels for keep and drop
+	f(`
+- action: keep
+`)
+	f(`
+- action: drop
+  regex: foo
+`)
+
+	// Invalid hashmod
+	f(`
+- action: hashmod
+  source_labels: [foo]
+  target_label: bar
+`)
+	f(`
+- action: hashmod
+  source_labels: [foo]
+  modulus: 3
+`)
+}
+
+func TestParseRelabelConfigsSuccess(t *testing.T) {
+	prcs, err := ParseRelabelConfigs([]byte(`
+- source_labels: [foo, bar]
+  target_label: baz
+- action: DROP
+  source_labels: [job]
+  regex: "test.*"
+- action: labeldrop
+  regex: "temp_.+"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(prcs) != 3 {
+		t.Fatalf("unexpected number of relabel configs; got %d; want 3", len(prcs))
+	}
+	prc := &prcs[0]
+	if prc.Action != "replace" || prc.Separator != ";" || prc.Replacement != "$1" || prc.Regex.String() != "^(.*)$" {
+		t.Fatalf("unexpected defaults in %s", prc)
+	}
+	prc = &prcs[1]
+	if prc.Action != "drop" || prc.Regex.String() != "^(?:test.*)$" {
+		t.Fatalf("unexpected relabel config %s", prc)
+	}
+}
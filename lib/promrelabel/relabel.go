@@ -0,0 +1,183 @@
+package promrelabel
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+)
+
+// ParsedRelabelConfig contains parsed `relabel_config`.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#relabel_config
+type ParsedRelabelConfig struct {
+	SourceLabels []string
+	Separator    string
+	TargetLabel  string
+	Regex        *regexp.Regexp
+	Modulus      uint64
+	Replacement  string
+	Action       string
+
+	hasCaptureGroupInTargetLabel bool
+}
+
+// String returns human-readable representation for prc.
+func (prc *ParsedRelabelConfig) String() string {
+	return fmt.Sprintf("SourceLabels=%s, Separator=%s, TargetLabel=%s, Regex=%s, Modulus=%d, Replacement=%s, Action=%s",
+		prc.SourceLabels, prc.Separator, prc.TargetLabel, prc.Regex.String(), prc.Modulus, prc.Replacement, prc.Action)
+}
+
+// ApplyRelabelConfigs applies prcs to labels and returns the result.
+//
+// Empty result means the labels must be dropped.
+// labels may be modified in place.
+func ApplyRelabelConfigs(labels []prompb.Label, prcs []ParsedRelabelConfig) ([]prompb.Label, error) {
+	for i := range prcs {
+		var err error
+		labels, err = prcs[i].Apply(labels)
+		if err != nil {
+			return nil, err
+		}
+		if len(labels) == 0 {
+			return labels, nil
+		}
+	}
+	return labels, nil
+}
+
+// Apply applies prc to labels and returns the result.
+//
+// Empty result means the labels must be dropped.
+// labels may be modified in place.
+//
+// The metric name must be stored in `__name__` label.
+//
+// An error is returned if prc contains unsupported action. This is possible only
+// if prc is constructed manually instead of ParseRelabelConfigs.
+func (prc *ParsedRelabelConfig) Apply(labels []prompb.Label) ([]prompb.Label, error) {
+	switch prc.Action {
+	case "replace":
+		value := concatLabelValues(labels, prc.SourceLabels, prc.Separator)
+		match := prc.Regex.FindSubmatchIndex(value)
+		if match == nil {
+			// Nothing to replace.
+			return labels, nil
+		}
+		targetLabel := prc.TargetLabel
+		if prc.hasCaptureGroupInTargetLabel {
+			targetLabel = string(prc.Regex.Expand(nil, []byte(targetLabel), value, match))
+			if len(targetLabel) == 0 {
+				return labels, nil
+			}
+		}
+		v := prc.Regex.Expand(nil, []byte(prc.Replacement), value, match)
+		if len(v) == 0 {
+			return removeLabel(labels, targetLabel), nil
+		}
+		return setLabel(labels, targetLabel, v), nil
+	case "keep":
+		value := concatLabelValues(labels, prc.SourceLabels, prc.Separator)
+		if !prc.Regex.Match(value) {
+			return labels[:0], nil
+		}
+		return labels, nil
+	case "drop":
+		value := concatLabelValues(labels, prc.SourceLabels, prc.Separator)
+		if prc.Regex.Match(value) {
+			return labels[:0], nil
+		}
+		return labels, nil
+	case "hashmod":
+		value := concatLabelValues(labels, prc.SourceLabels, prc.Separator)
+		sum := md5.Sum(value)
+		mod := binary.BigEndian.Uint64(sum[8:]) % prc.Modulus
+		return setLabel(labels, prc.TargetLabel, strconv.AppendUint(nil, mod, 10)), nil
+	case "labelmap":
+		// Iterate only over the original labels, since new labels may be appended to labels.
+		n := len(labels)
+		for i := 0; i < n; i++ {
+			label := &labels[i]
+			if !prc.Regex.Match(label.Name) {
+				continue
+			}
+			name := prc.Regex.ReplaceAll(label.Name, []byte(prc.Replacement))
+			labels = setLabel(labels, string(name), labels[i].Value)
+		}
+		return labels, nil
+	case "labeldrop":
+		dst := labels[:0]
+		for _, label := range labels {
+			if !prc.Regex.Match(label.Name) {
+				dst = append(dst, label)
+			}
+		}
+		return dst, nil
+	case "labelkeep":
+		dst := labels[:0]
+		for _, label := range labels {
+			if prc.Regex.Match(label.Name) {
+				dst = append(dst, label)
+			}
+		}
+		return dst, nil
+	default:
+		return nil, fmt.Errorf("unknown `action`: %q", prc.Action)
+	}
+}
+
+// concatLabelValues returns values for the given labelNames joined with separator.
+//
+// Missing labels are treated as labels with empty values.
+func concatLabelValues(labels []prompb.Label, labelNames []string, separator string) []byte {
+	if len(labelNames) == 1 {
+		// Fast path - return the value without copying.
+		if label := getLabel(labels, labelNames[0]); label != nil {
+			return label.Value
+		}
+		return nil
+	}
+	var b []byte
+	for i, name := range labelNames {
+		if i > 0 {
+			b = append(b, separator...)
+		}
+		if label := getLabel(labels, name); label != nil {
+			b = append(b, label.Value...)
+		}
+	}
+	return b
+}
+
+func getLabel(labels []prompb.Label, name string) *prompb.Label {
+	for i := range labels {
+		label := &labels[i]
+		if string(label.Name) == name {
+			return label
+		}
+	}
+	return nil
+}
+
+func setLabel(labels []prompb.Label, name string, value []byte) []prompb.Label {
+	if label := getLabel(labels, name); label != nil {
+		label.Value = value
+		return labels
+	}
+	return append(labels, prompb.Label{
+		Name:  []byte(name),
+		Value: value,
+	})
+}
+
+func removeLabel(labels []prompb.Label, name string) []prompb.Label {
+	for i := range labels {
+		if string(labels[i].Name) == name {
+			return append(labels[:i], labels[i+1:]...)
+		}
+	}
+	return labels
+}
@@ -0,0 +1,169 @@
+package promrelabel
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+)
+
+func TestApplyRelabelConfigs(t *testing.T) {
+	f := func(config string, labels []prompb.Label, resultExpected string) {
+		t.Helper()
+		prcs, err := ParseRelabelConfigs([]byte(config))
+		if err != nil {
+			t.Fatalf("cannot parse %q: %s", config, err)
+		}
+		labels, err = ApplyRelabelConfigs(labels, prcs)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		result := labelsString(labels)
+		if result != resultExpected {
+			t.Fatalf("unexpected result;\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	// Empty config
+	f(`[]`, newLabels("__name__", "foo", "a", "b"), `{__name__="foo",a="b"}`)
+
+	// replace
+	f(`
+- source_labels: [a, b]
+  target_label: c
+`, newLabels("a", "x", "b", "y"), `{a="x",b="y",c="x;y"}`)
+	f(`
+- source_labels: [a, missing]
+  separator: "-"
+  target_label: a
+  regex: "(.+)-"
+  replacement: "new-$1"
+`, newLabels("a", "x"), `{a="new-x"}`)
+	f(`
+- source_labels: [a]
+  target_label: c
+  regex: "nomatch"
+`, newLabels("a", "x", "c", "y"), `{a="x",c="y"}`)
+	f(`
+- source_labels: [missing]
+  target_label: c
+`, newLabels("a", "x", "c", "y"), `{a="x"}`)
+	f(`
+- source_labels: [__name__]
+  target_label: "label_${1}"
+  regex: "foo_(.+)"
+  replacement: "$1"
+`, newLabels("__name__", "foo_bar"), `{__name__="foo_bar",label_bar="bar"}`)
+
+	// keep
+	f(`
+- action: keep
+  source_labels: [__name__]
+  regex: "foo|bar"
+`, newLabels("__name__", "foo"), `{__name__="foo"}`)
+	f(`
+- action: keep
+  source_labels: [__name__]
+  regex: "foo|bar"
+`, newLabels("__name__", "foobar"), `{}`)
+
+	// drop
+	f(`
+- action: drop
+  source_labels: [__name__, job]
+  regex: "foo;.*"
+`, newLabels("__name__", "foo", "job", "x"), `{}`)
+	f(`
+- action: drop
+  source_labels: [__name__]
+  regex: "foo"
+`, newLabels("__name__", "foobar"), `{__name__="foobar"}`)
+
+	// hashmod
+	f(`
+- action: hashmod
+  source_labels: [instance]
+  target_label: shard
+  modulus: 10
+`, newLabels("instance", "host1:9100"), `{instance="host1:9100",shard="6"}`)
+
+	// labelmap
+	f(`
+- action: labelmap
+  regex: "__meta_(.+)"
+`, newLabels("__meta_a", "x", "__meta_b", "y", "c", "z"), `{__meta_a="x",__meta_b="y",a="x",b="y",c="z"}`)
+	f(`
+- action: labelmap
+  regex: "old_(.+)"
+  replacement: "new_$1"
+`, newLabels("old_a", "x", "new_a", "y"), `{new_a="x",old_a="x"}`)
+
+	// labeldrop
+	f(`
+- action: labeldrop
+  regex: "temp_.+"
+`, newLabels("__name__", "foo", "temp_a", "x", "temp", "y"), `{__name__="foo",temp="y"}`)
+
+	// labelkeep
+	f(`
+- action: labelkeep
+  regex: "__name__|job"
+`, newLabels("__name__", "foo", "job", "x", "instance", "y"), `{__name__="foo",job="x"}`)
+
+	// Multiple rules
+	f(`
+- action: labeldrop
+  regex: "request_id"
+- source_labels: [host]
+  target_label: instance
+- action: labeldrop
+  regex: host
+- action: drop
+  source_labels: [instance]
+  regex: "test-.+"
+`, newLabels("__name__", "foo", "request_id", "123", "host", "prod-1"), `{__name__="foo",instance="prod-1"}`)
+	f(`
+- source_labels: [host]
+  target_label: instance
+- action: drop
+  source_labels: [instance]
+  regex: "test-.+"
+- action: labeldrop
+  regex: host
+`, newLabels("__name__", "foo", "host", "test-1"), `{}`)
+}
+
+func newLabels(nameValues ...string) []prompb.Label {
+	var labels []prompb.Label
+	for i := 0; i < len(nameValues); i += 2 {
+		labels = append(labels, prompb.Label{
+			Name:  []byte(nameValues[i]),
+			Value: []byte(nameValues[i+1]),
+		})
+	}
+	return labels
+}
+
+func TestApplyRelabelConfigsUnknownAction(t *testing.T) {
+	prcs := []ParsedRelabelConfig{{
+		SourceLabels: []string{"a"},
+		Regex:        regexp.MustCompile(".*"),
+		Action:       "unknown",
+	}}
+	labels, err := ApplyRelabelConfigs(newLabels("a", "x"), prcs)
+	if err == nil {
+		t.Fatalf("expecting non-nil error; got %s", labelsString(labels))
+	}
+}
+
+func labelsString(labels []prompb.Label) string {
+	a := make([]string, 0, len(labels))
+	for _, label := range labels {
+		a = append(a, fmt.Sprintf("%s=%q", label.Name, label.Value))
+	}
+	sort.Strings(a)
+	return "{" + strings.Join(a, ",") + "}"
+}
@@ -0,0 +1,283 @@
+package promscrape
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"gopkg.in/yaml.v2"
+)
+
+// Config represents Prometheus config with scrape_configs.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/
+//
+// Only the subset of Prometheus config required for static_configs
+// and file_sd_configs is supported. The rest of options is ignored.
+type Config struct {
+	Global        GlobalConfig   `yaml:"global"`
+	ScrapeConfigs []ScrapeConfig `yaml:"scrape_configs"`
+
+	// This is set to the directory from where the config has been loaded.
+	baseDir string
+}
+
+// GlobalConfig represents essential parts for `global` section of Prometheus config.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#configuration-file
+type GlobalConfig struct {
+	ScrapeInterval time.Duration `yaml:"scrape_interval"`
+	ScrapeTimeout  time.Duration `yaml:"scrape_timeout"`
+}
+
+// ScrapeConfig represents essential parts for `scrape_config` section of Prometheus config.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#scrape_config
+type ScrapeConfig struct {
+	JobName         string              `yaml:"job_name"`
+	ScrapeInterval  time.Duration       `yaml:"scrape_interval"`
+	ScrapeTimeout   time.Duration       `yaml:"scrape_timeout"`
+	MetricsPath     string              `yaml:"metrics_path"`
+	HonorLabels     bool                `yaml:"honor_labels"`
+	HonorTimestamps *bool               `yaml:"honor_timestamps"`
+	Scheme          string              `yaml:"scheme"`
+	Params          map[string][]string `yaml:"params"`
+	StaticConfigs   []StaticConfig      `yaml:"static_configs"`
+	FileSDConfigs   []FileSDConfig      `yaml:"file_sd_configs"`
+}
+
+// FileSDConfig represents file-based service discovery config.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#file_sd_config
+type FileSDConfig struct {
+	Files []string `yaml:"files"`
+}
+
+// StaticConfig represents essential parts for `static_config` section of Prometheus config.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#static_config
+type StaticConfig struct {
+	Targets []string          `yaml:"targets"`
+	Labels  map[string]string `yaml:"labels"`
+}
+
+const (
+	defaultScrapeInterval = time.Minute
+	defaultScrapeTimeout  = 10 * time.Second
+)
+
+// loadConfig loads Prometheus config from the given path.
+func loadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read Prometheus config from %q: %s", path, err)
+	}
+	var cfg Config
+	if err := cfg.parse(data, path); err != nil {
+		return nil, fmt.Errorf("cannot parse Prometheus config from %q: %s", path, err)
+	}
+	return &cfg, nil
+}
+
+func (cfg *Config) parse(data []byte, path string) error {
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("cannot unmarshal data: %s", err)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("cannot obtain abs path for %q: %s", path, err)
+	}
+	cfg.baseDir = filepath.Dir(absPath)
+
+	jobNames := make(map[string]bool, len(cfg.ScrapeConfigs))
+	for i := range cfg.ScrapeConfigs {
+		sc := &cfg.ScrapeConfigs[i]
+		if len(sc.JobName) == 0 {
+			return fmt.Errorf("missing `job_name` field in `scrape_config` #%d", i+1)
+		}
+		if jobNames[sc.JobName] {
+			return fmt.Errorf("duplicate `job_name` %q in `scrape_configs`", sc.JobName)
+		}
+		jobNames[sc.JobName] = true
+		if err := sc.setDefaults(&cfg.Global); err != nil {
+			return fmt.Errorf("invalid `scrape_config` for `job_name` %q: %s", sc.JobName, err)
+		}
+	}
+	return nil
+}
+
+func (sc *ScrapeConfig) setDefaults(gc *GlobalConfig) error {
+	if sc.ScrapeInterval <= 0 {
+		sc.ScrapeInterval = gc.ScrapeInterval
+		if sc.ScrapeInterval <= 0 {
+			sc.ScrapeInterval = defaultScrapeInterval
+		}
+	}
+	if sc.ScrapeTimeout <= 0 {
+		sc.ScrapeTimeout = gc.ScrapeTimeout
+		if sc.ScrapeTimeout <= 0 {
+			sc.ScrapeTimeout = defaultScrapeTimeout
+		}
+	}
+	if sc.ScrapeTimeout > sc.ScrapeInterval {
+		// Limit the scrape timeout like Prometheus does.
+		sc.ScrapeTimeout = sc.ScrapeInterval
+	}
+	if len(sc.MetricsPath) == 0 {
+		sc.MetricsPath = "/metrics"
+	}
+	if !strings.HasPrefix(sc.MetricsPath, "/") {
+		return fmt.Errorf("`metrics_path` must start with `/`; got %q", sc.MetricsPath)
+	}
+	if len(sc.Scheme) == 0 {
+		sc.Scheme = "http"
+	}
+	if sc.Scheme != "http" && sc.Scheme != "https" {
+		return fmt.Errorf("unsupported `scheme` %q; supported values: http, https", sc.Scheme)
+	}
+	for i := range sc.StaticConfigs {
+		if err := sc.StaticConfigs[i].validate(); err != nil {
+			return fmt.Errorf("invalid `static_configs` #%d: %s", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (stc *StaticConfig) validate() error {
+	for _, target := range stc.Targets {
+		if len(target) == 0 {
+			return fmt.Errorf("`targets` cannot contain empty items")
+		}
+		if strings.Contains(target, "/") {
+			return fmt.Errorf("target %q cannot contain `/`; it must be in the form `host:port`", target)
+		}
+	}
+	return nil
+}
+
+// getStaticScrapeWork returns ScrapeWork items for static_configs from cfg.
+func (cfg *Config) getStaticScrapeWork() []ScrapeWork {
+	var dst []ScrapeWork
+	for i := range cfg.ScrapeConfigs {
+		sc := &cfg.ScrapeConfigs[i]
+		for j := range sc.StaticConfigs {
+			dst = sc.appendScrapeWork(dst, &sc.StaticConfigs[j])
+		}
+	}
+	return dst
+}
+
+// getFileSDScrapeWork returns ScrapeWork items for file_sd_configs from cfg.
+//
+// Files are re-read on each call, so the returned list reflects their current contents.
+// Files, which cannot be read, are skipped with the logged error.
+func (cfg *Config) getFileSDScrapeWork() []ScrapeWork {
+	var dst []ScrapeWork
+	for i := range cfg.ScrapeConfigs {
+		sc := &cfg.ScrapeConfigs[i]
+		for j := range sc.FileSDConfigs {
+			sdc := &sc.FileSDConfigs[j]
+			for _, pattern := range sdc.Files {
+				paths, err := filepath.Glob(getFilepath(cfg.baseDir, pattern))
+				if err != nil {
+					logger.Errorf("invalid pattern %q in `file_sd_config->files` for `job_name` %q: %s", pattern, sc.JobName, err)
+					continue
+				}
+				for _, path := range paths {
+					stcs, err := loadStaticConfigs(path)
+					if err != nil {
+						logger.Errorf("cannot load file %q for `job_name` %q: %s", path, sc.JobName, err)
+						continue
+					}
+					for k := range stcs {
+						dst = sc.appendScrapeWork(dst, &stcs[k])
+					}
+				}
+			}
+		}
+	}
+	return dst
+}
+
+func getFilepath(baseDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
+
+// loadStaticConfigs loads target groups from file_sd file at the given path.
+//
+// The file may be in JSON or YAML format.
+func loadStaticConfigs(path string) ([]StaticConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read file: %s", err)
+	}
+	var stcs []StaticConfig
+	if err := yaml.Unmarshal(data, &stcs); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal data: %s", err)
+	}
+	for i := range stcs {
+		if err := stcs[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid target group #%d: %s", i+1, err)
+		}
+	}
+	return stcs, nil
+}
+
+func (sc *ScrapeConfig) appendScrapeWork(dst []ScrapeWork, stc *StaticConfig) []ScrapeWork {
+	honorTimestamps := true
+	if sc.HonorTimestamps != nil {
+		honorTimestamps = *sc.HonorTimestamps
+	}
+	for _, target := range stc.Targets {
+		// Build target labels in the order of precedence like Prometheus does.
+		m := make(map[string]string)
+		m["job"] = sc.JobName
+		m["__address__"] = target
+		m["__scheme__"] = sc.Scheme
+		m["__metrics_path__"] = sc.MetricsPath
+		for k, v := range stc.Labels {
+			m[k] = v
+		}
+		if _, ok := m["instance"]; !ok {
+			m["instance"] = m["__address__"]
+		}
+
+		scrapeURL := fmt.Sprintf("%s://%s%s", m["__scheme__"], m["__address__"], m["__metrics_path__"])
+		if len(sc.Params) > 0 {
+			scrapeURL += "?" + url.Values(sc.Params).Encode()
+		}
+
+		// Drop internal labels starting with `__`.
+		var labels []Tag
+		for k, v := range m {
+			if strings.HasPrefix(k, "__") {
+				continue
+			}
+			labels = append(labels, Tag{
+				Key:   k,
+				Value: v,
+			})
+		}
+		sort.Slice(labels, func(i, j int) bool {
+			return labels[i].Key < labels[j].Key
+		})
+
+		dst = append(dst, ScrapeWork{
+			ScrapeURL:       scrapeURL,
+			ScrapeInterval:  sc.ScrapeInterval,
+			ScrapeTimeout:   sc.ScrapeTimeout,
+			HonorLabels:     sc.HonorLabels,
+			HonorTimestamps: honorTimestamps,
+			Labels:          labels,
+		})
+	}
+	return dst
+}
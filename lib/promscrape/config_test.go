@@ -0,0 +1,269 @@
+package promscrape
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfigParseFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		var cfg Config
+		if err := cfg.parse([]byte(data), "test.yml"); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", data)
+		}
+	}
+
+	// Invalid yaml
+	f("foo bar")
+
+	// Missing job_name
+	f(`
+scrape_configs:
+- static_configs:
+  - targets: ["foo:123"]
+`)
+
+	// Duplicate job_name
+	f(`
+scrape_configs:
+- job_name: foo
+- job_name: foo
+`)
+
+	// Invalid scheme
+	f(`
+scrape_configs:
+- job_name: foo
+  scheme: ftp
+`)
+
+	// Invalid metrics_path
+	f(`
+scrape_configs:
+- job_name: foo
+  metrics_path: metrics
+`)
+
+	// Invalid target
+	f(`
+scrape_configs:
+- job_name: foo
+  static_configs:
+  - targets: ["http://foo:123/metrics"]
+`)
+}
+
+func TestGetStaticScrapeWork(t *testing.T) {
+	f := func(data string, expectedSws []ScrapeWork) {
+		t.Helper()
+		var cfg Config
+		if err := cfg.parse([]byte(data), "test.yml"); err != nil {
+			t.Fatalf("cannot parse %q: %s", data, err)
+		}
+		sws := cfg.getStaticScrapeWork()
+		if !reflect.DeepEqual(sws, expectedSws) {
+			t.Fatalf("unexpected ScrapeWork;\ngot\n%+v\nwant\n%+v", sws, expectedSws)
+		}
+	}
+
+	f(``, nil)
+
+	// Defaults
+	f(`
+scrape_configs:
+- job_name: foo
+  static_configs:
+  - targets: ["foo.bar:1234"]
+`, []ScrapeWork{{
+		ScrapeURL:       "http://foo.bar:1234/metrics",
+		ScrapeInterval:  defaultScrapeInterval,
+		ScrapeTimeout:   defaultScrapeTimeout,
+		HonorTimestamps: true,
+		Labels: []Tag{
+			{
+				Key:   "instance",
+				Value: "foo.bar:1234",
+			},
+			{
+				Key:   "job",
+				Value: "foo",
+			},
+		},
+	}})
+
+	// Global settings, overrides and labels
+	f(`
+global:
+  scrape_interval: 15s
+  scrape_timeout: 30s
+scrape_configs:
+- job_name: foo
+  static_configs:
+  - targets: ["foo.bar:1234"]
+- job_name: bar
+  scrape_interval: 5s
+  metrics_path: /abc
+  scheme: https
+  honor_labels: true
+  honor_timestamps: false
+  params:
+    p: ["x", "y"]
+  static_configs:
+  - targets: ["a:1", "b:2"]
+    labels:
+      instance: xxx
+      job: yyy
+      env: prod
+`, []ScrapeWork{
+		{
+			ScrapeURL:       "http://foo.bar:1234/metrics",
+			ScrapeInterval:  15 * time.Second,
+			ScrapeTimeout:   15 * time.Second,
+			HonorTimestamps: true,
+			Labels: []Tag{
+				{
+					Key:   "instance",
+					Value: "foo.bar:1234",
+				},
+				{
+					Key:   "job",
+					Value: "foo",
+				},
+			},
+		},
+		{
+			ScrapeURL:       "https://a:1/abc?p=x&p=y",
+			ScrapeInterval:  5 * time.Second,
+			ScrapeTimeout:   5 * time.Second,
+			HonorLabels:     true,
+			HonorTimestamps: false,
+			Labels: []Tag{
+				{
+					Key:   "env",
+					Value: "prod",
+				},
+				{
+					Key:   "instance",
+					Value: "xxx",
+				},
+				{
+					Key:   "job",
+					Value: "yyy",
+				},
+			},
+		},
+		{
+			ScrapeURL:       "https://b:2/abc?p=x&p=y",
+			ScrapeInterval:  5 * time.Second,
+			ScrapeTimeout:   5 * time.Second,
+			HonorLabels:     true,
+			HonorTimestamps: false,
+			Labels: []Tag{
+				{
+					Key:   "env",
+					Value: "prod",
+				},
+				{
+					Key:   "instance",
+					Value: "xxx",
+				},
+				{
+					Key:   "job",
+					Value: "yyy",
+				},
+			},
+		},
+	})
+}
+
+func TestGetFileSDScrapeWork(t *testing.T) {
+	dir, err := ioutil.TempDir("", "promscrape")
+	if err != nil {
+		t.Fatalf("cannot create temporary dir: %s", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	writeFile := func(name, data string) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatalf("cannot write %q: %s", name, err)
+		}
+	}
+
+	writeFile("prometheus.yml", `
+scrape_configs:
+- job_name: foo
+  file_sd_configs:
+  - files: ["sd/*.json", "sd/*.yml"]
+`)
+	if err := os.Mkdir(filepath.Join(dir, "sd"), 0755); err != nil {
+		t.Fatalf("cannot create sd dir: %s", err)
+	}
+	writeFile("sd/a.json", `[{"targets": ["host1:80"], "labels": {"dc": "a"}}]`)
+	writeFile("sd/b.yml", `
+- targets: ["host2:80"]
+`)
+	writeFile("sd/invalid.json", `foobar`)
+
+	cfg, err := loadConfig(filepath.Join(dir, "prometheus.yml"))
+	if err != nil {
+		t.Fatalf("cannot load config: %s", err)
+	}
+	sws := cfg.getFileSDScrapeWork()
+	expectedSws := []ScrapeWork{
+		{
+			ScrapeURL:       "http://host1:80/metrics",
+			ScrapeInterval:  defaultScrapeInterval,
+			ScrapeTimeout:   defaultScrapeTimeout,
+			HonorTimestamps: true,
+			Labels: []Tag{
+				{
+					Key:   "dc",
+					Value: "a",
+				},
+				{
+					Key:   "instance",
+					Value: "host1:80",
+				},
+				{
+					Key:   "job",
+					Value: "foo",
+				},
+			},
+		},
+		{
+			ScrapeURL:       "http://host2:80/metrics",
+			ScrapeInterval:  defaultScrapeInterval,
+			ScrapeTimeout:   defaultScrapeTimeout,
+			HonorTimestamps: true,
+			Labels: []Tag{
+				{
+					Key:   "instance",
+					Value: "host2:80",
+				},
+				{
+					Key:   "job",
+					Value: "foo",
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(sws, expectedSws) {
+		t.Fatalf("unexpected ScrapeWork;\ngot\n%+v\nwant\n%+v", sws, expectedSws)
+	}
+
+	// Remove a file and verify the target disappears.
+	if err := os.Remove(filepath.Join(dir, "sd/a.json")); err != nil {
+		t.Fatalf("cannot remove file: %s", err)
+	}
+	sws = cfg.getFileSDScrapeWork()
+	if !reflect.DeepEqual(sws, expectedSws[1:]) {
+		t.Fatalf("unexpected ScrapeWork after file removal;\ngot\n%+v\nwant\n%+v", sws, expectedSws[1:])
+	}
+}
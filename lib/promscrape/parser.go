@@ -0,0 +1,245 @@
+package promscrape
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rows contains parsed Prometheus rows.
+type Rows struct {
+	Rows []Row
+
+	tagsPool []Tag
+}
+
+// Reset resets rs.
+func (rs *Rows) Reset() {
+	// Reset items, so they can be GC'ed
+
+	for i := range rs.Rows {
+		rs.Rows[i].reset()
+	}
+	rs.Rows = rs.Rows[:0]
+
+	for i := range rs.tagsPool {
+		rs.tagsPool[i].reset()
+	}
+	rs.tagsPool = rs.tagsPool[:0]
+}
+
+// Unmarshal unmarshals Prometheus text exposition format rows from s.
+//
+// See https://github.com/prometheus/docs/blob/master/content/docs/instrumenting/exposition_formats.md#text-based-format
+//
+// s must be unchanged until rs is in use.
+func (rs *Rows) Unmarshal(s string) error {
+	var err error
+	rs.Rows, rs.tagsPool, err = unmarshalRows(rs.Rows[:0], s, rs.tagsPool[:0])
+	return err
+}
+
+// Row is a single Prometheus row.
+type Row struct {
+	Metric    string
+	Tags      []Tag
+	Value     float64
+	Timestamp int64
+}
+
+func (r *Row) reset() {
+	r.Metric = ""
+	r.Tags = nil
+	r.Value = 0
+	r.Timestamp = 0
+}
+
+func (r *Row) unmarshal(s string, tagsPool []Tag) ([]Tag, error) {
+	r.reset()
+	n := strings.IndexAny(s, "{ \t")
+	if n < 0 {
+		return tagsPool, fmt.Errorf("cannot find value for metric %q", s)
+	}
+	r.Metric = s[:n]
+	if len(r.Metric) == 0 {
+		return tagsPool, fmt.Errorf("metric cannot be empty in %q", s)
+	}
+	s = s[n:]
+	if s[0] == '{' {
+		// Tags found
+		tagsStart := len(tagsPool)
+		var err error
+		s, tagsPool, err = unmarshalTags(tagsPool, s[1:])
+		if err != nil {
+			return tagsPool, fmt.Errorf("cannot unmarshal tags for metric %q: %s", r.Metric, err)
+		}
+		tags := tagsPool[tagsStart:]
+		r.Tags = tags[:len(tags):len(tags)]
+	}
+
+	s = skipLeadingWhitespace(s)
+	n = strings.IndexAny(s, " \t")
+	if n < 0 {
+		// There is no timestamp.
+		v, err := parseValue(s)
+		if err != nil {
+			return tagsPool, fmt.Errorf("cannot parse value for metric %q: %s", r.Metric, err)
+		}
+		r.Value = v
+		return tagsPool, nil
+	}
+	v, err := parseValue(s[:n])
+	if err != nil {
+		return tagsPool, fmt.Errorf("cannot parse value for metric %q: %s", r.Metric, err)
+	}
+	r.Value = v
+	s = strings.TrimSpace(s[n+1:])
+	if len(s) == 0 {
+		return tagsPool, nil
+	}
+	ts, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return tagsPool, fmt.Errorf("cannot parse timestamp for metric %q: %s", r.Metric, err)
+	}
+	r.Timestamp = ts
+	return tagsPool, nil
+}
+
+func parseValue(s string) (float64, error) {
+	if len(s) == 0 {
+		return 0, fmt.Errorf("value cannot be empty")
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func unmarshalRows(dst []Row, s string, tagsPool []Tag) ([]Row, []Tag, error) {
+	for len(s) > 0 {
+		n := strings.IndexByte(s, '\n')
+		line := s
+		if n >= 0 {
+			line = s[:n]
+			s = s[n+1:]
+		} else {
+			s = ""
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			// Skip empty lines and comments
+			continue
+		}
+		if cap(dst) > len(dst) {
+			dst = dst[:len(dst)+1]
+		} else {
+			dst = append(dst, Row{})
+		}
+		r := &dst[len(dst)-1]
+		var err error
+		tagsPool, err = r.unmarshal(line, tagsPool)
+		if err != nil {
+			return dst, tagsPool, err
+		}
+	}
+	return dst, tagsPool, nil
+}
+
+func unmarshalTags(dst []Tag, s string) (string, []Tag, error) {
+	for {
+		s = skipLeadingWhitespace(s)
+		if len(s) > 0 && s[0] == '}' {
+			return s[1:], dst, nil
+		}
+		n := strings.IndexByte(s, '=')
+		if n < 0 {
+			return s, dst, fmt.Errorf("missing value for tag %q", s)
+		}
+		key := strings.TrimSpace(s[:n])
+		if len(key) == 0 {
+			return s, dst, fmt.Errorf("tag key cannot be empty")
+		}
+		s = skipLeadingWhitespace(s[n+1:])
+		if len(s) == 0 || s[0] != '"' {
+			return s, dst, fmt.Errorf("expecting quoted value for tag %q", key)
+		}
+		value, tail, err := unquoteTagValue(s[1:])
+		if err != nil {
+			return s, dst, fmt.Errorf("cannot unquote value for tag %q: %s", key, err)
+		}
+		s = skipLeadingWhitespace(tail)
+
+		if cap(dst) > len(dst) {
+			dst = dst[:len(dst)+1]
+		} else {
+			dst = append(dst, Tag{})
+		}
+		tag := &dst[len(dst)-1]
+		tag.Key = key
+		tag.Value = value
+
+		if len(s) > 0 && s[0] == ',' {
+			s = s[1:]
+			continue
+		}
+		if len(s) > 0 && s[0] == '}' {
+			return s[1:], dst, nil
+		}
+		return s, dst, fmt.Errorf("missing closing curly brace")
+	}
+}
+
+// unquoteTagValue returns the tag value from s, which must start after the opening quote.
+//
+// It also returns the tail after the closing quote.
+func unquoteTagValue(s string) (string, string, error) {
+	n := strings.IndexAny(s, `"\`)
+	if n < 0 {
+		return "", s, fmt.Errorf("missing closing quote")
+	}
+	if s[n] == '"' {
+		// Fast path - the value has no escape sequences.
+		return s[:n], s[n+1:], nil
+	}
+
+	// Slow path - the value contains escape sequences.
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			return string(b), s[i+1:], nil
+		case '\\':
+			i++
+			if i >= len(s) {
+				return "", s, fmt.Errorf("missing closing quote")
+			}
+			switch s[i] {
+			case 'n':
+				b = append(b, '\n')
+			case '\\', '"':
+				b = append(b, s[i])
+			default:
+				b = append(b, '\\', s[i])
+			}
+		default:
+			b = append(b, c)
+		}
+	}
+	return "", s, fmt.Errorf("missing closing quote")
+}
+
+func skipLeadingWhitespace(s string) string {
+	for len(s) > 0 && (s[0] == ' ' || s[0] == '\t') {
+		s = s[1:]
+	}
+	return s
+}
+
+// Tag is a Prometheus tag.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+func (t *Tag) reset() {
+	t.Key = ""
+	t.Value = ""
+}
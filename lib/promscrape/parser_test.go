@@ -0,0 +1,209 @@
+package promscrape
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestRowsUnmarshalFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		var rows Rows
+		if err := rows.Unmarshal(s); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", s)
+		}
+
+		// Try again
+		if err := rows.Unmarshal(s); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", s)
+		}
+	}
+
+	// Missing value
+	f("aaa")
+	f("aaa{}")
+	f("aaa{foo=\"bar\"}")
+
+	// Invalid value
+	f("aaa bbb")
+	f("aaa{} 12s")
+
+	// Invalid timestamp
+	f("aaa 123 foo")
+
+	// Invalid multiline
+	f("aaa 123\nbbb")
+
+	// Missing tag value
+	f("aaa{foo} 123")
+	f("aaa{foo=} 123")
+	f("aaa{foo=bar} 123")
+	f("aaa{=\"bar\"} 123")
+
+	// Missing closing quote
+	f("aaa{foo=\"bar} 123")
+	f("aaa{foo=\"bar\\\"} 123")
+
+	// Missing closing curly brace
+	f("aaa{foo=\"bar\" 123")
+	f("aaa{foo=\"bar\";x=\"y\"} 123")
+}
+
+func TestRowsUnmarshalSuccess(t *testing.T) {
+	f := func(s string, rowsExpected *Rows) {
+		t.Helper()
+		var rows Rows
+		if err := rows.Unmarshal(s); err != nil {
+			t.Fatalf("cannot unmarshal %q: %s", s, err)
+		}
+		if !reflect.DeepEqual(rows.Rows, rowsExpected.Rows) {
+			t.Fatalf("unexpected rows;\ngot\n%+v;\nwant\n%+v", rows.Rows, rowsExpected.Rows)
+		}
+
+		// Try unmarshaling again
+		if err := rows.Unmarshal(s); err != nil {
+			t.Fatalf("cannot unmarshal %q: %s", s, err)
+		}
+		if !reflect.DeepEqual(rows.Rows, rowsExpected.Rows) {
+			t.Fatalf("unexpected rows;\ngot\n%+v;\nwant\n%+v", rows.Rows, rowsExpected.Rows)
+		}
+
+		rows.Reset()
+		if len(rows.Rows) != 0 {
+			t.Fatalf("non-empty rows after reset: %+v", rows.Rows)
+		}
+	}
+
+	// Empty line or comments
+	f("", &Rows{})
+	f("\n\n", &Rows{})
+	f("# HELP foo bar\n# TYPE foo counter\n", &Rows{})
+
+	// Single line
+	f("foobar -123.456", &Rows{
+		Rows: []Row{{
+			Metric: "foobar",
+			Value:  -123.456,
+		}},
+	})
+	f("foo_bar 123.456 789\n", &Rows{
+		Rows: []Row{{
+			Metric:    "foo_bar",
+			Value:     123.456,
+			Timestamp: 789,
+		}},
+	})
+	f("foo_bar{} 1e3", &Rows{
+		Rows: []Row{{
+			Metric: "foo_bar",
+			Value:  1000,
+		}},
+	})
+
+	// Special values
+	f("foo +Inf\nbar -Inf", &Rows{
+		Rows: []Row{
+			{
+				Metric: "foo",
+				Value:  math.Inf(1),
+			},
+			{
+				Metric: "bar",
+				Value:  math.Inf(-1),
+			},
+		},
+	})
+
+	// Tags
+	f(`foo{bar="baz"} 1 2`, &Rows{
+		Rows: []Row{{
+			Metric: "foo",
+			Tags: []Tag{{
+				Key:   "bar",
+				Value: "baz",
+			}},
+			Value:     1,
+			Timestamp: 2,
+		}},
+	})
+	f(`foo{ bar = "b\"a\\z" , aa="", x="\n",} 1`, &Rows{
+		Rows: []Row{{
+			Metric: "foo",
+			Tags: []Tag{
+				{
+					Key:   "bar",
+					Value: `b"a\z`,
+				},
+				{
+					Key:   "aa",
+					Value: "",
+				},
+				{
+					Key:   "x",
+					Value: "\n",
+				},
+			},
+			Value: 1,
+		}},
+	})
+
+	// Multiple lines with comments
+	f(`# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+  http_requests_total{method="post",code="400"}    3 1395066363000
+
+process_start_time_seconds 1.5e+09
+`, &Rows{
+		Rows: []Row{
+			{
+				Metric: "http_requests_total",
+				Tags: []Tag{
+					{
+						Key:   "method",
+						Value: "post",
+					},
+					{
+						Key:   "code",
+						Value: "200",
+					},
+				},
+				Value:     1027,
+				Timestamp: 1395066363000,
+			},
+			{
+				Metric: "http_requests_total",
+				Tags: []Tag{
+					{
+						Key:   "method",
+						Value: "post",
+					},
+					{
+						Key:   "code",
+						Value: "400",
+					},
+				},
+				Value:     3,
+				Timestamp: 1395066363000,
+			},
+			{
+				Metric: "process_start_time_seconds",
+				Value:  1.5e9,
+			},
+		},
+	})
+}
+
+func TestRowsUnmarshalNaN(t *testing.T) {
+	var rows Rows
+	if err := rows.Unmarshal("foo NaN"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(rows.Rows) != 1 {
+		t.Fatalf("unexpected number of rows; got %d; want 1", len(rows.Rows))
+	}
+	if !math.IsNaN(rows.Rows[0].Value) {
+		t.Fatalf("unexpected value; got %v; want NaN", rows.Rows[0].Value)
+	}
+}
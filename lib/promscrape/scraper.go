@@ -0,0 +1,244 @@
+package promscrape
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	promscrapeConfigFile = flag.String("promscrape.config", "", "Optional path to Prometheus config file with `scrape_configs` section containing targets to scrape. "+
+		"Only `static_configs` and `file_sd_configs` are supported. "+
+		"See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#scrape_config for details")
+	fileSDCheckInterval = flag.Duration("promscrape.fileSDCheckInterval", 30*time.Second, "Interval for checking for changes in `file_sd_config` files")
+	maxScrapeSize       = flag.Int("promscrape.maxScrapeSize", 16*1024*1024, "The maximum size of scrape response in bytes to process from Prometheus targets. "+
+		"Bigger responses are rejected")
+)
+
+// Init initializes Prometheus scraper with config from the `-promscrape.config`.
+//
+// Scraped data is passed to pushData.
+//
+// It does nothing if `-promscrape.config` is empty.
+func Init(pushData func(wr *prompb.WriteRequest)) {
+	if len(*promscrapeConfigFile) == 0 {
+		return
+	}
+	cfg, err := loadConfig(*promscrapeConfigFile)
+	if err != nil {
+		logger.Fatalf("cannot load `-promscrape.config`: %s", err)
+	}
+	stopCh = make(chan struct{})
+	scraperWG.Add(1)
+	go func() {
+		defer scraperWG.Done()
+		runScraper(cfg, pushData, stopCh)
+	}()
+}
+
+// Stop stops Prometheus scraper.
+func Stop() {
+	if stopCh == nil {
+		return
+	}
+	close(stopCh)
+	scraperWG.Wait()
+	stopCh = nil
+}
+
+var (
+	stopCh    chan struct{}
+	scraperWG sync.WaitGroup
+)
+
+func runScraper(cfg *Config, pushData func(wr *prompb.WriteRequest), stopCh <-chan struct{}) {
+	logger.Infof("starting Prometheus scraper with config %q", *promscrapeConfigFile)
+	defer logger.Infof("stopped Prometheus scraper with config %q", *promscrapeConfigFile)
+
+	staticTargets := newScraperGroup("static_configs", pushData)
+	staticTargets.update(cfg.getStaticScrapeWork())
+	defer staticTargets.stop()
+
+	fileSDTargets := newScraperGroup("file_sd_configs", pushData)
+	fileSDTargets.update(cfg.getFileSDScrapeWork())
+	defer fileSDTargets.stop()
+
+	ticker := time.NewTicker(*fileSDCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			fileSDTargets.update(cfg.getFileSDScrapeWork())
+		}
+	}
+}
+
+// scraperGroup runs scrapers for the given set of ScrapeWork items.
+type scraperGroup struct {
+	name     string
+	pushData func(wr *prompb.WriteRequest)
+
+	wg sync.WaitGroup
+	m  map[string]chan struct{}
+
+	activeTargets *metrics.Counter
+	changes       *metrics.Counter
+}
+
+func newScraperGroup(name string, pushData func(wr *prompb.WriteRequest)) *scraperGroup {
+	return &scraperGroup{
+		name:     name,
+		pushData: pushData,
+		m:        make(map[string]chan struct{}),
+
+		activeTargets: metrics.NewCounter(fmt.Sprintf(`vm_promscrape_active_targets{type=%q}`, name)),
+		changes:       metrics.NewCounter(fmt.Sprintf(`vm_promscrape_config_changes_total{type=%q}`, name)),
+	}
+}
+
+// update starts scrapers for new items in sws and stops scrapers for items missing in sws.
+func (sg *scraperGroup) update(sws []ScrapeWork) {
+	additionsCount := 0
+	deletionsCount := 0
+	swsMap := make(map[string]bool, len(sws))
+	for i := range sws {
+		sw := &sws[i]
+		key := sw.key()
+		if swsMap[key] {
+			logger.Errorf("skipping duplicate scrape target with identical labels; endpoint=%s, labels=%s; "+
+				"make sure service discovery and relabeling is set up properly", sw.ScrapeURL, sw.labelsString())
+			continue
+		}
+		swsMap[key] = true
+		if _, ok := sg.m[key]; ok {
+			// The scraper for sw is already running.
+			continue
+		}
+
+		// Start a scraper for the new target.
+		ch := make(chan struct{})
+		sg.m[key] = ch
+		sg.activeTargets.Inc()
+		additionsCount++
+		scraper := newScrapeWork(sw, sg.pushData)
+		sg.wg.Add(1)
+		go func() {
+			defer sg.wg.Done()
+			scraper.run(ch)
+		}()
+	}
+
+	// Stop scrapers for the missing targets.
+	for key, ch := range sg.m {
+		if swsMap[key] {
+			continue
+		}
+		close(ch)
+		delete(sg.m, key)
+		sg.activeTargets.Dec()
+		deletionsCount++
+	}
+
+	if additionsCount > 0 || deletionsCount > 0 {
+		sg.changes.Add(additionsCount + deletionsCount)
+		logger.Infof("%s: added targets: %d, removed targets: %d; total targets: %d", sg.name, additionsCount, deletionsCount, len(sg.m))
+	}
+}
+
+// stop stops all the scrapers in sg and waits until they are finished.
+func (sg *scraperGroup) stop() {
+	for key, ch := range sg.m {
+		close(ch)
+		delete(sg.m, key)
+		sg.activeTargets.Dec()
+	}
+	sg.wg.Wait()
+}
+
+func (sw *ScrapeWork) labelsString() string {
+	s := "{"
+	for i, label := range sw.Labels {
+		if i > 0 {
+			s += ", "
+		}
+		s += label.Key + "=" + strconv.Quote(label.Value)
+	}
+	return s + "}"
+}
+
+func newScrapeWork(sw *ScrapeWork, pushData func(wr *prompb.WriteRequest)) *scrapeWork {
+	c := newClient(sw)
+	return &scrapeWork{
+		Config:   *sw,
+		ReadData: c.ReadData,
+		PushData: pushData,
+	}
+}
+
+// client reads data from the scrape target.
+type client struct {
+	hc *http.Client
+
+	scrapeURL     string
+	scrapeTimeout string
+}
+
+func newClient(sw *ScrapeWork) *client {
+	return &client{
+		hc: &http.Client{
+			Timeout: sw.ScrapeTimeout,
+		},
+		scrapeURL:     sw.ScrapeURL,
+		scrapeTimeout: strconv.FormatFloat(sw.ScrapeTimeout.Seconds(), 'f', -1, 64),
+	}
+}
+
+// ReadData appends the response body for the scrape target to dst and returns the result.
+func (c *client) ReadData(dst []byte) ([]byte, error) {
+	req, err := http.NewRequest("GET", c.scrapeURL, nil)
+	if err != nil {
+		return dst, fmt.Errorf("cannot create request: %s", err)
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4;q=1,*/*;q=0.1")
+	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", c.scrapeTimeout)
+	req.Header.Set("User-Agent", "vm_promscrape")
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		scrapeRequestErrors.Inc()
+		return dst, fmt.Errorf("cannot perform request: %s", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		scrapeRequestErrors.Inc()
+		return dst, fmt.Errorf("unexpected status code: %d; expecting %d", resp.StatusCode, http.StatusOK)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, int64(*maxScrapeSize)+1))
+	if err != nil {
+		scrapeRequestErrors.Inc()
+		return dst, fmt.Errorf("cannot read response: %s", err)
+	}
+	if len(data) > *maxScrapeSize {
+		scrapeRequestErrors.Inc()
+		return dst, fmt.Errorf("the response size exceeds -promscrape.maxScrapeSize=%d", *maxScrapeSize)
+	}
+	scrapeRequests.Inc()
+	return append(dst, data...), nil
+}
+
+var (
+	scrapeRequests      = metrics.NewCounter(`vm_promscrape_scrapes_total`)
+	scrapeRequestErrors = metrics.NewCounter(`vm_promscrape_scrape_errors_total`)
+)
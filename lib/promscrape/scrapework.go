@@ -0,0 +1,223 @@
+package promscrape
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// ScrapeWork represents a unit of work for scraping Prometheus metrics.
+type ScrapeWork struct {
+	// Full URL (including query args) for the scrape.
+	ScrapeURL string
+
+	// Interval for scraping the ScrapeURL.
+	ScrapeInterval time.Duration
+
+	// Timeout for scraping the ScrapeURL.
+	ScrapeTimeout time.Duration
+
+	// Whether to honor labels from the scraped data on conflicts with Labels.
+	HonorLabels bool
+
+	// Whether to use timestamps from the scraped data.
+	HonorTimestamps bool
+
+	// Labels to add to all the scraped metrics, sorted by Key.
+	//
+	// They contain at least `job` and `instance` labels.
+	Labels []Tag
+}
+
+// key returns unique key for sw.
+func (sw *ScrapeWork) key() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "url=%s, interval=%s, timeout=%s, honorLabels=%v, honorTimestamps=%v, labels=",
+		sw.ScrapeURL, sw.ScrapeInterval, sw.ScrapeTimeout, sw.HonorLabels, sw.HonorTimestamps)
+	for _, label := range sw.Labels {
+		fmt.Fprintf(&b, "%s=%q,", label.Key, label.Value)
+	}
+	return b.String()
+}
+
+type scrapeWork struct {
+	// Config for the scrape.
+	Config ScrapeWork
+
+	// ReadData is called for reading the data.
+	ReadData func(dst []byte) ([]byte, error)
+
+	// PushData is called for pushing collected data.
+	PushData func(wr *prompb.WriteRequest)
+
+	bodyBuf []byte
+	rows    Rows
+	wr      prompb.WriteRequest
+	labels  []prompb.Label
+	samples []prompb.Sample
+}
+
+func (sw *scrapeWork) run(stopCh <-chan struct{}) {
+	// Randomize start time for the first scrape in order to spread load
+	// when scraping many targets.
+	scrapeInterval := sw.Config.ScrapeInterval
+	randSleep := time.Duration(rand.Int63n(int64(scrapeInterval)))
+	timer := time.NewTimer(randSleep)
+	select {
+	case <-stopCh:
+		timer.Stop()
+		return
+	case <-timer.C:
+	}
+
+	ticker := time.NewTicker(scrapeInterval)
+	defer ticker.Stop()
+	timestamp := time.Now().UnixNano() / 1e6
+	for {
+		if err := sw.scrapeInternal(timestamp); err != nil {
+			logger.Errorf("error when scraping %q: %s", sw.Config.ScrapeURL, err)
+		}
+		select {
+		case <-stopCh:
+			return
+		case t := <-ticker.C:
+			timestamp = t.UnixNano() / 1e6
+		}
+	}
+}
+
+var (
+	scrapeDuration              = metrics.NewSummary("vm_promscrape_scrape_duration_seconds")
+	scrapeResponseSize          = metrics.NewSummary("vm_promscrape_scrape_response_size_bytes")
+	scrapedSamples              = metrics.NewSummary("vm_promscrape_scraped_samples")
+	scrapesFailed               = metrics.NewCounter(`vm_promscrape_scrapes_failed_total`)
+	pushDataDuration            = metrics.NewSummary("vm_promscrape_push_data_duration_seconds")
+	scrapesSkippedByParseErrors = metrics.NewCounter(`vm_promscrape_scrapes_skipped_total{reason="parse_error"}`)
+)
+
+func (sw *scrapeWork) scrapeInternal(timestamp int64) error {
+	var err error
+	startTime := time.Now()
+	sw.bodyBuf, err = sw.ReadData(sw.bodyBuf[:0])
+	endTime := time.Now()
+	duration := endTime.Sub(startTime).Seconds()
+	scrapeDuration.Update(duration)
+	scrapeResponseSize.Update(float64(len(sw.bodyBuf)))
+	up := 1
+	if err != nil {
+		up = 0
+		scrapesFailed.Inc()
+	} else if err = sw.rows.Unmarshal(bytesutil.ToUnsafeString(sw.bodyBuf)); err != nil {
+		up = 0
+		scrapesSkippedByParseErrors.Inc()
+		err = fmt.Errorf("cannot parse response: %s", err)
+	}
+	if up == 0 {
+		sw.rows.Reset()
+	}
+	samplesScraped := len(sw.rows.Rows)
+	scrapedSamples.Update(float64(samplesScraped))
+
+	sw.resetWriteRequest()
+	for i := range sw.rows.Rows {
+		sw.addRowToWriteRequest(&sw.rows.Rows[i], timestamp)
+	}
+	sw.addAutoTimeseries("up", float64(up), timestamp)
+	sw.addAutoTimeseries("scrape_duration_seconds", duration, timestamp)
+	sw.addAutoTimeseries("scrape_samples_scraped", float64(samplesScraped), timestamp)
+
+	startTime = time.Now()
+	sw.PushData(&sw.wr)
+	pushDataDuration.UpdateDuration(startTime)
+	sw.rows.Reset()
+	sw.resetWriteRequest()
+	return err
+}
+
+func (sw *scrapeWork) resetWriteRequest() {
+	for i := range sw.labels {
+		label := &sw.labels[i]
+		label.Name = nil
+		label.Value = nil
+	}
+	sw.labels = sw.labels[:0]
+	sw.samples = sw.samples[:0]
+	sw.wr.Reset()
+}
+
+// addAutoTimeseries adds automatically generated time series with the given name, value and timestamp.
+//
+// See https://prometheus.io/docs/concepts/jobs_instances/#automatically-generated-labels-and-time-series
+func (sw *scrapeWork) addAutoTimeseries(name string, value float64, timestamp int64) {
+	labelsStart := len(sw.labels)
+	sw.labels = appendLabel(sw.labels, "__name__", name)
+	for _, label := range sw.Config.Labels {
+		sw.labels = appendLabel(sw.labels, label.Key, label.Value)
+	}
+	sw.appendTimeseries(labelsStart, value, timestamp)
+}
+
+func (sw *scrapeWork) addRowToWriteRequest(r *Row, timestamp int64) {
+	labelsStart := len(sw.labels)
+	sw.labels = appendLabel(sw.labels, "__name__", r.Metric)
+	for _, tag := range r.Tags {
+		sw.labels = appendLabel(sw.labels, tag.Key, tag.Value)
+	}
+	for _, label := range sw.Config.Labels {
+		if !hasLabel(sw.labels[labelsStart:], label.Key) {
+			sw.labels = appendLabel(sw.labels, label.Key, label.Value)
+			continue
+		}
+		if sw.Config.HonorLabels {
+			// Leave the scraped label as is.
+			continue
+		}
+		// Rename the conflicting scraped label to `exported_<name>` like Prometheus does.
+		exportedName := "exported_" + label.Key
+		for i := range sw.labels[labelsStart:] {
+			l := &sw.labels[labelsStart+i]
+			if string(l.Name) == label.Key {
+				l.Name = []byte(exportedName)
+				break
+			}
+		}
+		sw.labels = appendLabel(sw.labels, label.Key, label.Value)
+	}
+	if r.Timestamp != 0 && sw.Config.HonorTimestamps {
+		timestamp = r.Timestamp
+	}
+	sw.appendTimeseries(labelsStart, r.Value, timestamp)
+}
+
+func (sw *scrapeWork) appendTimeseries(labelsStart int, value float64, timestamp int64) {
+	sw.samples = append(sw.samples, prompb.Sample{
+		Value:     value,
+		Timestamp: timestamp,
+	})
+	sw.wr.Timeseries = append(sw.wr.Timeseries, prompb.TimeSeries{
+		Labels:  sw.labels[labelsStart:],
+		Samples: sw.samples[len(sw.samples)-1:],
+	})
+}
+
+func hasLabel(labels []prompb.Label, name string) bool {
+	for i := range labels {
+		if string(labels[i].Name) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func appendLabel(dst []prompb.Label, name, value string) []prompb.Label {
+	return append(dst, prompb.Label{
+		Name:  bytesutil.ToUnsafeBytes(name),
+		Value: bytesutil.ToUnsafeBytes(value),
+	})
+}
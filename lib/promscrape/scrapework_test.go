@@ -0,0 +1,191 @@
+package promscrape
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+)
+
+func TestScrapeWorkScrapeInternalFailure(t *testing.T) {
+	var sw scrapeWork
+	sw.Config = ScrapeWork{
+		Labels: []Tag{
+			{
+				Key:   "instance",
+				Value: "foo:123",
+			},
+			{
+				Key:   "job",
+				Value: "xx",
+			},
+		},
+	}
+	readDataCalls := 0
+	sw.ReadData = func(dst []byte) ([]byte, error) {
+		readDataCalls++
+		return dst, fmt.Errorf("error when reading data")
+	}
+	var got []string
+	sw.PushData = func(wr *prompb.WriteRequest) {
+		got = timeseriesToStrings(wr)
+	}
+	if err := sw.scrapeInternal(123); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if readDataCalls != 1 {
+		t.Fatalf("unexpected number of readData calls; got %d; want 1", readDataCalls)
+	}
+	expected := []string{
+		`scrape_samples_scraped{instance="foo:123",job="xx"} 0 123`,
+		`up{instance="foo:123",job="xx"} 0 123`,
+	}
+	if err := checkTimeseries(got, expected); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScrapeWorkScrapeInternalSuccess(t *testing.T) {
+	f := func(data string, cfg *ScrapeWork, expected []string) {
+		t.Helper()
+		var sw scrapeWork
+		sw.Config = *cfg
+		sw.ReadData = func(dst []byte) ([]byte, error) {
+			return append(dst, data...), nil
+		}
+		pushDataCalls := 0
+		var got []string
+		sw.PushData = func(wr *prompb.WriteRequest) {
+			pushDataCalls++
+			got = timeseriesToStrings(wr)
+		}
+		if err := sw.scrapeInternal(123); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if pushDataCalls != 1 {
+			t.Fatalf("unexpected number of pushData calls; got %d; want 1", pushDataCalls)
+		}
+		if err := checkTimeseries(got, expected); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f(``, &ScrapeWork{}, []string{
+		`scrape_samples_scraped 0 123`,
+		`up 1 123`,
+	})
+	f(`
+foo{bar="baz"} 34.44
+bar_total 4 456
+`, &ScrapeWork{
+		HonorTimestamps: true,
+		Labels: []Tag{
+			{
+				Key:   "instance",
+				Value: "foo.com",
+			},
+		},
+	}, []string{
+		`bar_total{instance="foo.com"} 4 456`,
+		`foo{bar="baz",instance="foo.com"} 34.44 123`,
+		`scrape_samples_scraped{instance="foo.com"} 2 123`,
+		`up{instance="foo.com"} 1 123`,
+	})
+
+	// Timestamps from the scraped data are ignored if honor_timestamps is false
+	f(`bar_total 4 456`, &ScrapeWork{}, []string{
+		`bar_total 4 123`,
+		`scrape_samples_scraped 1 123`,
+		`up 1 123`,
+	})
+
+	// Conflicting labels are renamed to exported_<name>
+	f(`foo{job="orig",bar="x"} 1`, &ScrapeWork{
+		Labels: []Tag{
+			{
+				Key:   "job",
+				Value: "target",
+			},
+		},
+	}, []string{
+		`foo{bar="x",exported_job="orig",job="target"} 1 123`,
+		`scrape_samples_scraped{job="target"} 1 123`,
+		`up{job="target"} 1 123`,
+	})
+
+	// Conflicting labels are preserved if honor_labels is set
+	f(`foo{job="orig",bar="x"} 1`, &ScrapeWork{
+		HonorLabels: true,
+		Labels: []Tag{
+			{
+				Key:   "job",
+				Value: "target",
+			},
+		},
+	}, []string{
+		`foo{bar="x",job="orig"} 1 123`,
+		`scrape_samples_scraped{job="target"} 1 123`,
+		`up{job="target"} 1 123`,
+	})
+}
+
+func TestScrapeWorkScrapeInternalParseError(t *testing.T) {
+	var sw scrapeWork
+	sw.ReadData = func(dst []byte) ([]byte, error) {
+		return append(dst, "foo bar"...), nil
+	}
+	var got []string
+	sw.PushData = func(wr *prompb.WriteRequest) {
+		got = timeseriesToStrings(wr)
+	}
+	if err := sw.scrapeInternal(123); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	expected := []string{
+		`scrape_samples_scraped 0 123`,
+		`up 0 123`,
+	}
+	if err := checkTimeseries(got, expected); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// timeseriesToStrings converts wr to sorted strings in text exposition format.
+//
+// scrape_duration_seconds is skipped, since its value isn't deterministic.
+func timeseriesToStrings(wr *prompb.WriteRequest) []string {
+	var a []string
+	for _, ts := range wr.Timeseries {
+		var name string
+		var labels []string
+		for _, label := range ts.Labels {
+			if string(label.Name) == "__name__" {
+				name = string(label.Value)
+				continue
+			}
+			labels = append(labels, fmt.Sprintf("%s=%q", label.Name, label.Value))
+		}
+		if name == "scrape_duration_seconds" {
+			continue
+		}
+		sort.Strings(labels)
+		s := name
+		if len(labels) > 0 {
+			s += "{" + strings.Join(labels, ",") + "}"
+		}
+		for _, sample := range ts.Samples {
+			a = append(a, fmt.Sprintf("%s %g %d", s, sample.Value, sample.Timestamp))
+		}
+	}
+	sort.Strings(a)
+	return a
+}
+
+func checkTimeseries(got, expected []string) error {
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		return fmt.Errorf("unexpected timeseries;\ngot\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"sync"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 )
@@ -25,6 +26,12 @@ type Block struct {
 	timestamps []int64
 	values     []int64
 
+	// keepTimestamps is set if timestamps must be marshaled as is.
+	//
+	// This is the case for blocks with deleted rows, since otherwise MarshalData
+	// could put evenly spaced timestamps into the deleted time ranges.
+	keepTimestamps bool
+
 	// Marshaled representation of block header.
 	headerData []byte
 
@@ -41,6 +48,7 @@ func (b *Block) Reset() {
 	b.nextIdx = 0
 	b.timestamps = b.timestamps[:0]
 	b.values = b.values[:0]
+	b.keepTimestamps = false
 
 	b.headerData = b.headerData[:0]
 	b.timestampsData = b.timestampsData[:0]
@@ -53,6 +61,7 @@ func (b *Block) CopyFrom(src *Block) {
 	b.nextIdx = 0
 	b.timestamps = append(b.timestamps[:0], src.timestamps[src.nextIdx:]...)
 	b.values = append(b.values[:0], src.values[src.nextIdx:]...)
+	b.keepTimestamps = src.keepTimestamps
 
 	b.headerData = append(b.headerData[:0], src.headerData...)
 	b.timestampsData = append(b.timestampsData[:0], src.timestampsData...)
@@ -147,6 +156,22 @@ func (b *Block) assertMergeable(ib *Block) {
 	}
 }
 
+// deduplicateSamplesDuringMerge leaves only the last sample per each minScrapeInterval
+// in the unmarshaled b.
+//
+// It is no-op for marshaled b.
+func (b *Block) deduplicateSamplesDuringMerge() {
+	if len(b.values) == 0 {
+		// The block is marshaled. Nothing to deduplicate.
+		return
+	}
+	srcTimestamps := b.timestamps[b.nextIdx:]
+	srcValues := b.values[b.nextIdx:]
+	timestamps, values := deduplicateSamplesDuringMerge(srcTimestamps, srcValues)
+	b.timestamps = b.timestamps[:b.nextIdx+len(timestamps)]
+	b.values = b.values[:b.nextIdx+len(values)]
+}
+
 // tooBig returns true if the block is too big to be extended.
 func (b *Block) tooBig() bool {
 	if b.bh.RowsCount >= maxRowsPerBlock || len(b.values[b.nextIdx:]) >= maxRowsPerBlock {
@@ -200,12 +225,17 @@ func (b *Block) MarshalData(timestampsBlockOffset, valuesBlockOffset uint64) ([]
 		logger.Panicf("BUG: the number of values must match the number of timestamps; got %d vs %d", len(values), len(timestamps))
 	}
 
-	b.valuesData, b.bh.ValuesMarshalType, b.bh.FirstValue = encoding.MarshalValues(b.valuesData[:0], values, b.bh.PrecisionBits)
+	valuesPrecisionBits := b.bh.PrecisionBits
+	if valuesPrecisionBits < 64 && hasSpecialValues(values) {
+		// Special values such as Inf and staleness marks must be stored without precision loss.
+		valuesPrecisionBits = 64
+	}
+	b.valuesData, b.bh.ValuesMarshalType, b.bh.FirstValue = encoding.MarshalValues(b.valuesData[:0], values, valuesPrecisionBits)
 	b.bh.ValuesBlockOffset = valuesBlockOffset
 	b.bh.ValuesBlockSize = uint32(len(b.valuesData))
 	b.values = b.values[:0]
 
-	if len(timestamps) > 1 && (b.bh.ValuesMarshalType == encoding.MarshalTypeConst || b.bh.ValuesMarshalType == encoding.MarshalTypeDeltaConst) {
+	if len(timestamps) > 1 && isConstMarshalType(b.bh.ValuesMarshalType) && !b.keepTimestamps {
 		// Special case - values are constant or are changed with constant rate.
 		// In this case we may 'cheat' by assuming timestamps are changed
 		// at ideal constant rate. This improves timestamps' compression rate.
@@ -232,6 +262,19 @@ func (b *Block) MarshalData(timestampsBlockOffset, valuesBlockOffset uint64) ([]
 	return b.headerData, b.timestampsData, b.valuesData
 }
 
+func isConstMarshalType(mt encoding.MarshalType) bool {
+	return mt == encoding.MarshalTypeConst || mt == encoding.MarshalTypeDeltaConst
+}
+
+func hasSpecialValues(values []int64) bool {
+	for _, v := range values {
+		if decimal.IsSpecialValue(v) {
+			return true
+		}
+	}
+	return false
+}
+
 // UnmarshalData unmarshals block data.
 func (b *Block) UnmarshalData() error {
 	// blockHeader (b.bh) must be already unmarshaled.
@@ -266,6 +309,13 @@ func (b *Block) UnmarshalData() error {
 	}
 	b.valuesData = b.valuesData[:0]
 
+	if b.bh.RowsCount > 1 && isConstMarshalType(b.bh.ValuesMarshalType) && !isConstMarshalType(b.bh.TimestampsMarshalType) {
+		// MarshalData always stores evenly spaced timestamps for such values unless keepTimestamps is set.
+		// So the block has been marshaled with keepTimestamps and its timestamps must be kept as is
+		// during subsequent merges.
+		b.keepTimestamps = true
+	}
+
 	if len(b.timestamps) != len(b.values) {
 		logger.Panicf("BUG: timestamps and values count mismatch; got %d vs %d", len(b.timestamps), len(b.values))
 	}
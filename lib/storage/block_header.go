@@ -153,6 +153,9 @@ func (bh *blockHeader) Unmarshal(src []byte) ([]byte, error) {
 	if bh.RowsCount == 0 {
 		return src, fmt.Errorf("RowsCount in block header cannot be zero")
 	}
+	if bh.RowsCount > maxRowsPerBlock {
+		return src, fmt.Errorf("too big RowsCount in block header; got %d; cannot exceed %d", bh.RowsCount, maxRowsPerBlock)
+	}
 	if err = encoding.CheckMarshalType(bh.TimestampsMarshalType); err != nil {
 		return src, fmt.Errorf("unsupported TimestampsMarshalType: %s", err)
 	}
@@ -101,6 +101,11 @@ func (bsm *blockStreamMerger) nextBlock() error {
 	return nil
 }
 
+// Tombstones returns tombstones, which must be applied to bsm.Block.
+func (bsm *blockStreamMerger) Tombstones() []*tombstone {
+	return bsm.bsrHeap[0].tombstones
+}
+
 func (bsm *blockStreamMerger) Error() error {
 	if bsm.err == io.EOF {
 		return nil
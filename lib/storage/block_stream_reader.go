@@ -53,6 +53,9 @@ type blockStreamReader struct {
 	// Cursor to indexData.
 	indexCursor []byte
 
+	// tombstones contains tombstones, which must be applied to blocks during the merge.
+	tombstones []*tombstone
+
 	err error
 }
 
@@ -83,6 +86,11 @@ func (bsr *blockStreamReader) reset() {
 
 	bsr.indexCursor = nil
 
+	for i := range bsr.tombstones {
+		bsr.tombstones[i] = nil
+	}
+	bsr.tombstones = bsr.tombstones[:0]
+
 	bsr.err = nil
 }
 
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+)
+
+func TestBlockMarshalUnmarshalSpecialValues(t *testing.T) {
+	f := func(values []float64, precisionBits uint8) {
+		t.Helper()
+		timestamps := make([]int64, len(values))
+		for i := range timestamps {
+			timestamps[i] = int64(i) * 1000
+		}
+		vs, scale := decimal.AppendFloatToDecimal(nil, values)
+
+		var b Block
+		var tsid TSID
+		b.Init(&tsid, timestamps, vs, scale, precisionBits)
+		b.MarshalData(0, 0)
+		b.values = b.values[:0]
+		b.timestamps = b.timestamps[:0]
+		if err := b.UnmarshalData(); err != nil {
+			t.Fatalf("cannot unmarshal block data: %s", err)
+		}
+		if !reflect.DeepEqual(b.Timestamps(), timestamps) {
+			t.Fatalf("unexpected timestamps; got %v; want %v", b.Timestamps(), timestamps)
+		}
+		for i, v := range b.Values() {
+			if v != vs[i] && (decimal.IsSpecialValue(v) || decimal.IsSpecialValue(vs[i])) {
+				t.Fatalf("unexpected value at position %d; got %d; want %d", i, v, vs[i])
+			}
+		}
+	}
+	values := []float64{1.25, 2.5, decimal.StaleNaN, 123456789, -4.75, decimal.StaleNaN, 1e10}
+	f(values, 64)
+	f(values, 4)
+	f(values, 1)
+	f([]float64{decimal.StaleNaN, decimal.StaleNaN}, 8)
+	f([]float64{math.Inf(1), decimal.StaleNaN, math.Inf(-1), decimal.StaleNaN}, 4)
+}
+
+func TestBlockMarshalUnmarshalKeepTimestamps(t *testing.T) {
+	// Constant values with a gap in timestamps, like after deleting a single row.
+	timestamps := []int64{1000, 2000, 3000, 5000, 6000}
+	values := []int64{1, 1, 1, 1, 1}
+	marshalUnmarshal := func(b *Block) {
+		t.Helper()
+		b.MarshalData(0, 0)
+		b.values = b.values[:0]
+		b.timestamps = b.timestamps[:0]
+		if err := b.UnmarshalData(); err != nil {
+			t.Fatalf("cannot unmarshal block data: %s", err)
+		}
+	}
+
+	// Timestamps are replaced with evenly spaced ones by default.
+	var b Block
+	var tsid TSID
+	b.Init(&tsid, timestamps, values, 0, 64)
+	marshalUnmarshal(&b)
+	if reflect.DeepEqual(b.Timestamps(), timestamps) {
+		t.Fatalf("expecting evenly spaced timestamps; got %v", b.Timestamps())
+	}
+	if b.keepTimestamps {
+		t.Fatalf("keepTimestamps must be unset for the block with evenly spaced timestamps")
+	}
+
+	// Timestamps must be kept if keepTimestamps is set.
+	b.Init(&tsid, timestamps, values, 0, 64)
+	b.keepTimestamps = true
+	marshalUnmarshal(&b)
+	if !reflect.DeepEqual(b.Timestamps(), timestamps) {
+		t.Fatalf("unexpected timestamps; got %v; want %v", b.Timestamps(), timestamps)
+	}
+
+	// keepTimestamps must be restored on unmarshaling, so timestamps are kept during subsequent merges.
+	var b2 Block
+	b.MarshalData(0, 0)
+	b2.bh = b.bh
+	b2.timestampsData = append(b2.timestampsData[:0], b.timestampsData...)
+	b2.valuesData = append(b2.valuesData[:0], b.valuesData...)
+	if err := b2.UnmarshalData(); err != nil {
+		t.Fatalf("cannot unmarshal block data: %s", err)
+	}
+	if !b2.keepTimestamps {
+		t.Fatalf("keepTimestamps must be set for the block with unevenly spaced timestamps and constant values")
+	}
+	marshalUnmarshal(&b2)
+	if !reflect.DeepEqual(b2.Timestamps(), timestamps) {
+		t.Fatalf("unexpected timestamps after re-marshaling; got %v; want %v", b2.Timestamps(), timestamps)
+	}
+}
+
+func TestUnmarshalBlockInvalidHeader(t *testing.T) {
+	f := func(updateHeader func(bh *blockHeader)) {
+		t.Helper()
+		var b Block
+		var tsid TSID
+		b.Init(&tsid, []int64{1000, 2000, 4000}, []int64{1, 5, 2}, 0, 64)
+		b.MarshalData(0, 0)
+		updateHeader(&b.bh)
+		data := MarshalBlock(nil, &b)
+		var b2 Block
+		if _, err := UnmarshalBlock(&b2, data); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// Too many rows
+	f(func(bh *blockHeader) {
+		bh.RowsCount = maxRowsPerBlock + 1
+	})
+	f(func(bh *blockHeader) {
+		bh.RowsCount = math.MaxUint32
+	})
+
+	// Data sizes mismatch
+	f(func(bh *blockHeader) {
+		bh.TimestampsBlockSize++
+	})
+	f(func(bh *blockHeader) {
+		bh.ValuesBlockSize--
+	})
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SetMinScrapeIntervalForDeduplication sets the minimum interval for samples per each time series.
+//
+// Only the last sample per each interval is left for each time series during background merges
+// and during search. Intervals are aligned to multiples of interval, so the deduplication
+// leaves the same samples as the downsampling with the same interval.
+//
+// Background merges remove duplicates only inside the merged blocks, so duplicates
+// located at the boundary of adjacent blocks may remain on disk. Such duplicates
+// are removed during search, since samples from all the blocks for the given time series
+// are merged before the deduplication. See DeduplicateSamples.
+//
+// De-duplication is disabled if interval is zero.
+//
+// This function must be called before opening the storage.
+func SetMinScrapeIntervalForDeduplication(interval time.Duration) {
+	minScrapeInterval = int64(interval / time.Millisecond)
+}
+
+// minScrapeInterval is the minimum interval in milliseconds between samples for the same time series.
+var minScrapeInterval = int64(0)
+
+// DeduplicateSamples leaves only the last sample from src* per each interval
+// set via SetMinScrapeIntervalForDeduplication.
+//
+// srcTimestamps must be sorted. The returned slices share memory with src*.
+func DeduplicateSamples(srcTimestamps []int64, srcValues []float64) ([]int64, []float64) {
+	if !needsDedup(srcTimestamps, minScrapeInterval) {
+		// Fast path - nothing to deduplicate.
+		return srcTimestamps, srcValues
+	}
+	dstTimestamps := deduplicateTimestamps(srcTimestamps, func(dstIdx, srcIdx int) {
+		srcValues[dstIdx] = srcValues[srcIdx]
+	})
+	return dstTimestamps, srcValues[:len(dstTimestamps)]
+}
+
+func deduplicateSamplesDuringMerge(srcTimestamps, srcValues []int64) ([]int64, []int64) {
+	if !needsDedup(srcTimestamps, minScrapeInterval) {
+		// Fast path - nothing to deduplicate.
+		return srcTimestamps, srcValues
+	}
+	dstTimestamps := deduplicateTimestamps(srcTimestamps, func(dstIdx, srcIdx int) {
+		srcValues[dstIdx] = srcValues[srcIdx]
+	})
+	atomic.AddUint64(&dedupsDuringMerge, uint64(len(srcTimestamps)-len(dstTimestamps)))
+	return dstTimestamps, srcValues[:len(dstTimestamps)]
+}
+
+// deduplicateTimestamps leaves only the last timestamp per each minScrapeInterval
+// and returns the remaining timestamps.
+//
+// moveValue is called for each remaining sample, so the caller could move
+// the corresponding value from srcIdx to dstIdx.
+func deduplicateTimestamps(timestamps []int64, moveValue func(dstIdx, srcIdx int)) []int64 {
+	dstTimestamps := timestamps[:0]
+	for i, ts := range timestamps {
+		if i+1 < len(timestamps) && getIntervalStart(ts, minScrapeInterval) == getIntervalStart(timestamps[i+1], minScrapeInterval) {
+			// Leave only the last sample per interval.
+			continue
+		}
+		moveValue(len(dstTimestamps), i)
+		dstTimestamps = append(dstTimestamps, ts)
+	}
+	return dstTimestamps
+}
+
+// dedupsDuringMerge is the number of samples removed by the deduplication during background merges.
+var dedupsDuringMerge uint64
+
+func needsDedup(timestamps []int64, interval int64) bool {
+	if interval <= 0 || len(timestamps) < 2 {
+		return false
+	}
+	prevIntervalStart := getIntervalStart(timestamps[0], interval)
+	for _, ts := range timestamps[1:] {
+		intervalStart := getIntervalStart(ts, interval)
+		if intervalStart == prevIntervalStart {
+			return true
+		}
+		prevIntervalStart = intervalStart
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNeedsDedup(t *testing.T) {
+	f := func(interval int64, timestamps []int64, expectedResult bool) {
+		t.Helper()
+		result := needsDedup(timestamps, interval)
+		if result != expectedResult {
+			t.Fatalf("unexpected result for needsDedup(%d, %d); got %v; want %v", timestamps, interval, result, expectedResult)
+		}
+	}
+	f(-1, nil, false)
+	f(-1, []int64{1}, false)
+	f(0, []int64{1, 2}, false)
+	f(10, []int64{1}, false)
+	f(10, []int64{1, 2}, true)
+	f(10, []int64{1, 11}, false)
+	f(10, []int64{1, 11, 19}, true)
+	f(10, []int64{1, 11, 20}, false)
+	f(10, []int64{1, 11, 21}, false)
+}
+
+func TestDeduplicateSamples(t *testing.T) {
+	f := func(scrapeInterval time.Duration, timestamps, timestampsExpected []int64) {
+		t.Helper()
+		SetMinScrapeIntervalForDeduplication(scrapeInterval)
+		defer SetMinScrapeIntervalForDeduplication(0)
+
+		values := make([]float64, len(timestamps))
+		for i := range values {
+			values[i] = float64(i)
+		}
+		timestampsCopy := append([]int64(nil), timestamps...)
+		timestampsResult, valuesResult := DeduplicateSamples(timestampsCopy, values)
+		if !reflect.DeepEqual(timestampsResult, timestampsExpected) {
+			t.Fatalf("unexpected timestamps for DeduplicateSamples(%d, %s);\ngot\n%d\nwant\n%d", timestamps, scrapeInterval, timestampsResult, timestampsExpected)
+		}
+		if len(valuesResult) != len(timestampsResult) {
+			t.Fatalf("values count must match timestamps count; got %d vs %d", len(valuesResult), len(timestampsResult))
+		}
+	}
+	f(0, []int64{1, 2, 3}, []int64{1, 2, 3})
+	f(time.Millisecond, nil, nil)
+	f(time.Millisecond, []int64{123}, []int64{123})
+	f(time.Millisecond, []int64{123, 456}, []int64{123, 456})
+	f(time.Millisecond, []int64{0, 0, 0, 1, 1, 2, 3, 3, 3, 4}, []int64{0, 1, 2, 3, 4})
+	f(10*time.Millisecond, []int64{0, 5, 9, 10, 11, 21, 25, 30}, []int64{9, 11, 25, 30})
+	f(15*time.Second, []int64{0, 100, 15000, 15100, 30000, 30100}, []int64{100, 15100, 30100})
+}
+
+func TestDeduplicateSamplesDuringMerge(t *testing.T) {
+	SetMinScrapeIntervalForDeduplication(10 * time.Millisecond)
+	defer SetMinScrapeIntervalForDeduplication(0)
+
+	var b Block
+	tsid := &TSID{
+		MetricID: 123,
+	}
+	timestamps := []int64{0, 5, 9, 10, 11, 21, 25, 30}
+	values := []int64{1, 2, 3, 4, 5, 6, 7, 8}
+	b.Init(tsid, timestamps, values, 0, defaultPrecisionBits)
+	b.deduplicateSamplesDuringMerge()
+	timestampsExpected := []int64{9, 11, 25, 30}
+	if !reflect.DeepEqual(b.Timestamps(), timestampsExpected) {
+		t.Fatalf("unexpected timestamps after deduplication; got %d; want %d", b.Timestamps(), timestampsExpected)
+	}
+	valuesExpected := []int64{3, 5, 7, 8}
+	if !reflect.DeepEqual(b.Values(), valuesExpected) {
+		t.Fatalf("unexpected values after deduplication; got %d; want %d", b.Values(), valuesExpected)
+	}
+
+	// Marshaled block must remain unchanged.
+	b.MarshalData(0, 0)
+	rowsCount := b.RowsCount()
+	b.deduplicateSamplesDuringMerge()
+	if b.RowsCount() != rowsCount {
+		t.Fatalf("unexpected rows count after deduplication of marshaled block; got %d; want %d", b.RowsCount(), rowsCount)
+	}
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// downsamplingFilename is the name of the file with the downsampling interval applied to the partition.
+//
+// The file is stored in the directory with small parts.
+const downsamplingFilename = "downsampling_interval"
+
+// DownsamplingPeriod defines the interval between samples older than Offset.
+type DownsamplingPeriod struct {
+	// Offset is the minimum age in milliseconds for samples to be downsampled.
+	Offset int64
+
+	// Interval is the interval in milliseconds.
+	//
+	// A single sample per each Interval is left after the downsampling.
+	Interval int64
+}
+
+// ParseDownsamplingPeriods parses comma-separated `offset:interval` pairs from s.
+//
+// For example, `30d:5m,180d:1h` means that a single sample per 5 minutes must be left
+// for samples older than 30 days, while a single sample per hour must be left
+// for samples older than 180 days.
+func ParseDownsamplingPeriods(s string) ([]DownsamplingPeriod, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	var dps []DownsamplingPeriod
+	for _, item := range strings.Split(s, ",") {
+		n := strings.IndexByte(item, ':')
+		if n < 0 {
+			return nil, fmt.Errorf("missing `:` in %q; expecting `offset:interval`", item)
+		}
+		offset, err := parseDuration(item[:n])
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse offset in %q: %s", item, err)
+		}
+		interval, err := parseDuration(item[n+1:])
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse interval in %q: %s", item, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("interval must be positive in %q", item)
+		}
+		dps = append(dps, DownsamplingPeriod{
+			Offset:   offset,
+			Interval: interval,
+		})
+	}
+	sort.Slice(dps, func(i, j int) bool {
+		return dps[i].Offset < dps[j].Offset
+	})
+	for i := 1; i < len(dps); i++ {
+		if dps[i].Offset == dps[i-1].Offset {
+			return nil, fmt.Errorf("duplicate offset %dms in %q", dps[i].Offset, s)
+		}
+		if dps[i].Interval < dps[i-1].Interval {
+			return nil, fmt.Errorf("interval for offset %dms cannot be smaller than interval for offset %dms in %q", dps[i].Offset, dps[i-1].Offset, s)
+		}
+	}
+	return dps, nil
+}
+
+// parseDuration parses duration in milliseconds from s.
+//
+// s may contain the following suffixes additionally to suffixes supported by time.ParseDuration:
+// `d` for days, `w` for weeks and `y` for years.
+func parseDuration(s string) (int64, error) {
+	multiplier := int64(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		multiplier = 24 * 3600 * 1000
+	case strings.HasSuffix(s, "w"):
+		multiplier = 7 * 24 * 3600 * 1000
+	case strings.HasSuffix(s, "y"):
+		multiplier = 365 * 24 * 3600 * 1000
+	}
+	if multiplier > 0 {
+		f, err := strconv.ParseFloat(s[:len(s)-1], 64)
+		if err != nil {
+			return 0, err
+		}
+		if f < 0 {
+			return 0, fmt.Errorf("duration cannot be negative; got %q", s)
+		}
+		return int64(f * float64(multiplier)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("duration cannot be negative; got %q", s)
+	}
+	return int64(d / time.Millisecond), nil
+}
+
+// SetDownsamplingPeriods sets periods for the downsampling of old data during background merges of big parts.
+//
+// dps must be obtained via ParseDownsamplingPeriods.
+//
+// This function must be called before opening the storage.
+func SetDownsamplingPeriods(dps []DownsamplingPeriod) {
+	downsamplingPeriods = append(downsamplingPeriods[:0], dps...)
+}
+
+var downsamplingPeriods []DownsamplingPeriod
+
+// downsampler downsamples blocks according to downsamplingPeriods.
+type downsampler struct {
+	// steps contains downsampling steps in the order of downsamplingPeriods,
+	// i.e. maxTimestamp in steps is decreasing.
+	steps []downsamplingStep
+}
+
+type downsamplingStep struct {
+	// maxTimestamp is the timestamp for the downsampling step. Samples with smaller timestamps must be downsampled.
+	maxTimestamp int64
+
+	interval int64
+}
+
+// newDownsampler returns downsampler for the given currentTimestamp in milliseconds.
+//
+// nil is returned if the downsampling is disabled.
+func newDownsampler(currentTimestamp int64) *downsampler {
+	if len(downsamplingPeriods) == 0 {
+		return nil
+	}
+	var ds downsampler
+	for _, dp := range downsamplingPeriods {
+		ds.steps = append(ds.steps, downsamplingStep{
+			maxTimestamp: currentTimestamp - dp.Offset,
+			interval:     dp.Interval,
+		})
+	}
+	return &ds
+}
+
+// getInterval returns the downsampling interval for the given timestamp.
+//
+// Zero is returned if the sample with the given timestamp mustn't be downsampled.
+func (ds *downsampler) getInterval(timestamp int64) int64 {
+	if ds == nil {
+		return 0
+	}
+	for i := len(ds.steps) - 1; i >= 0; i-- {
+		step := &ds.steps[i]
+		if timestamp < step.maxTimestamp {
+			return step.interval
+		}
+	}
+	return 0
+}
+
+// downsampleBlock leaves the last sample per each downsampling interval in b.
+//
+// b remains unmarshaled if samples are removed from it.
+//
+// Returns the number of removed samples.
+func (ds *downsampler) downsampleBlock(b *Block) (int, error) {
+	if ds == nil || b.bh.MinTimestamp >= ds.steps[0].maxTimestamp {
+		// Fast path - nothing to downsample.
+		return 0, nil
+	}
+	if err := b.UnmarshalData(); err != nil {
+		return 0, fmt.Errorf("cannot unmarshal block data: %s", err)
+	}
+	timestamps := b.timestamps
+	values := b.values
+	dstIdx := b.nextIdx
+	for i := b.nextIdx; i < len(timestamps); i++ {
+		ts := timestamps[i]
+		if i+1 < len(timestamps) {
+			interval := ds.getInterval(ts)
+			tsNext := timestamps[i+1]
+			if interval > 0 && interval == ds.getInterval(tsNext) && getIntervalStart(ts, interval) == getIntervalStart(tsNext, interval) {
+				// Leave only the last sample per interval.
+				continue
+			}
+		}
+		timestamps[dstIdx] = ts
+		values[dstIdx] = values[i]
+		dstIdx++
+	}
+	removedRows := len(timestamps) - dstIdx
+	b.timestamps = timestamps[:dstIdx]
+	b.values = values[:dstIdx]
+	b.bh.RowsCount = uint32(dstIdx - b.nextIdx)
+	if b.bh.RowsCount > 0 {
+		b.fixupTimestamps()
+	}
+	return removedRows, nil
+}
+
+// mustLoadDownsamplingInterval loads the downsampling interval applied to the partition at the given path.
+//
+// Zero is returned if the partition hasn't been downsampled yet.
+func mustLoadDownsamplingInterval(path string) int64 {
+	path = path + "/" + downsamplingFilename
+	if !fs.IsPathExist(path) {
+		return 0
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		logger.Panicf("FATAL: cannot read %q: %s", path, err)
+	}
+	interval, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		logger.Panicf("FATAL: cannot parse downsampling interval from %q: %s", path, err)
+	}
+	return interval
+}
+
+// mustSaveDownsamplingInterval atomically stores the downsampling interval at the given partition path.
+func mustSaveDownsamplingInterval(path string, interval int64) {
+	data := strconv.AppendInt(nil, interval, 10)
+	mustWritePartitionFile(path, downsamplingFilename, data)
+}
+
+// downsampledRows is the number of rows removed by the downsampling during background merges.
+var downsampledRows uint64
+
+func getIntervalStart(timestamp, interval int64) int64 {
+	n := timestamp % interval
+	if n < 0 {
+		n += interval
+	}
+	return timestamp - n
+}
@@ -0,0 +1,318 @@
+package storage
+
+import (
+	"os"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseDownsamplingPeriodsSuccess(t *testing.T) {
+	f := func(s string, dpsExpected []DownsamplingPeriod) {
+		t.Helper()
+		dps, err := ParseDownsamplingPeriods(s)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		if !reflect.DeepEqual(dps, dpsExpected) {
+			t.Fatalf("unexpected downsampling periods for %q; got %+v; want %+v", s, dps, dpsExpected)
+		}
+	}
+	f("", nil)
+	f("30d:5m", []DownsamplingPeriod{
+		{
+			Offset:   30 * 24 * 3600 * 1000,
+			Interval: 5 * 60 * 1000,
+		},
+	})
+	f("180d:1h,30d:5m,1y:1d", []DownsamplingPeriod{
+		{
+			Offset:   30 * 24 * 3600 * 1000,
+			Interval: 5 * 60 * 1000,
+		},
+		{
+			Offset:   180 * 24 * 3600 * 1000,
+			Interval: 3600 * 1000,
+		},
+		{
+			Offset:   365 * 24 * 3600 * 1000,
+			Interval: 24 * 3600 * 1000,
+		},
+	})
+	f("1.5w:500ms", []DownsamplingPeriod{
+		{
+			Offset:   21 * 12 * 3600 * 1000,
+			Interval: 500,
+		},
+	})
+}
+
+func TestParseDownsamplingPeriodsError(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		dps, err := ParseDownsamplingPeriods(s)
+		if err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q; got %+v", s, dps)
+		}
+	}
+	f("foo")
+	f("30d")
+	f("30d:")
+	f(":5m")
+	f("30x:5m")
+	f("-30d:5m")
+	f("30d:0s")
+	f("30d:-5m")
+	f("30d:5m,30d:1h")
+	f("30d:1h,180d:5m")
+}
+
+func TestGetIntervalStart(t *testing.T) {
+	f := func(timestamp, interval, startExpected int64) {
+		t.Helper()
+		start := getIntervalStart(timestamp, interval)
+		if start != startExpected {
+			t.Fatalf("unexpected interval start for timestamp=%d, interval=%d; got %d; want %d", timestamp, interval, start, startExpected)
+		}
+	}
+	f(0, 10, 0)
+	f(9, 10, 0)
+	f(10, 10, 10)
+	f(25, 10, 20)
+	f(-1, 10, -10)
+	f(-10, 10, -10)
+	f(-11, 10, -20)
+}
+
+func TestDownsamplerGetInterval(t *testing.T) {
+	SetDownsamplingPeriods([]DownsamplingPeriod{
+		{
+			Offset:   100,
+			Interval: 10,
+		},
+		{
+			Offset:   200,
+			Interval: 50,
+		},
+	})
+	defer SetDownsamplingPeriods(nil)
+
+	ds := newDownsampler(1000)
+	f := func(timestamp, intervalExpected int64) {
+		t.Helper()
+		interval := ds.getInterval(timestamp)
+		if interval != intervalExpected {
+			t.Fatalf("unexpected interval for timestamp=%d; got %d; want %d", timestamp, interval, intervalExpected)
+		}
+	}
+	f(1000, 0)
+	f(900, 0)
+	f(899, 10)
+	f(800, 10)
+	f(799, 50)
+	f(-100, 50)
+
+	var dsNil *downsampler
+	if interval := dsNil.getInterval(0); interval != 0 {
+		t.Fatalf("unexpected interval for nil downsampler; got %d; want 0", interval)
+	}
+}
+
+func TestDownsampleBlock(t *testing.T) {
+	SetDownsamplingPeriods([]DownsamplingPeriod{
+		{
+			Offset:   100,
+			Interval: 10,
+		},
+		{
+			Offset:   200,
+			Interval: 50,
+		},
+	})
+	defer SetDownsamplingPeriods(nil)
+
+	var b Block
+	tsid := &TSID{
+		MetricID: 42,
+	}
+	var timestamps, values []int64
+	for ts := int64(700); ts <= 1000; ts += 4 {
+		timestamps = append(timestamps, ts)
+		values = append(values, ts*2)
+	}
+	b.Init(tsid, timestamps, values, 0, defaultPrecisionBits)
+	b.MarshalData(0, 0)
+
+	// Recent block mustn't be downsampled.
+	ds := newDownsampler(500)
+	n, err := ds.downsampleBlock(&b)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 0 {
+		t.Fatalf("unexpected number of downsampled rows; got %d; want 0", n)
+	}
+
+	ds = newDownsampler(1000)
+	n, err = ds.downsampleBlock(&b)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// The last sample per each 50ms interval must be left for timestamps below 800,
+	// the last sample per each 10ms interval must be left for timestamps in the range [800..900),
+	// while timestamps starting from 900 must remain untouched.
+	timestampsExpected := []int64{748, 796, 808, 816, 828, 836, 848, 856, 868, 876, 888, 896}
+	for ts := int64(900); ts <= 1000; ts += 4 {
+		timestampsExpected = append(timestampsExpected, ts)
+	}
+	if n != len(timestamps)-len(timestampsExpected) {
+		t.Fatalf("unexpected number of downsampled rows; got %d; want %d", n, len(timestamps)-len(timestampsExpected))
+	}
+	if !reflect.DeepEqual(b.Timestamps(), timestampsExpected) {
+		t.Fatalf("unexpected timestamps;\ngot\n%d\nwant\n%d", b.Timestamps(), timestampsExpected)
+	}
+	var valuesExpected []int64
+	for _, ts := range timestampsExpected {
+		valuesExpected = append(valuesExpected, ts*2)
+	}
+	if !reflect.DeepEqual(b.Values(), valuesExpected) {
+		t.Fatalf("unexpected values;\ngot\n%d\nwant\n%d", b.Values(), valuesExpected)
+	}
+	if b.RowsCount() != len(timestampsExpected) {
+		t.Fatalf("unexpected rows count; got %d; want %d", b.RowsCount(), len(timestampsExpected))
+	}
+	if b.bh.MinTimestamp != 748 || b.bh.MaxTimestamp != 1000 {
+		t.Fatalf("unexpected time range in block header; got [%d..%d]; want [%d..%d]", b.bh.MinTimestamp, b.bh.MaxTimestamp, 748, 1000)
+	}
+}
+
+func TestPartitionDownsampleParts(t *testing.T) {
+	defer SetDownsamplingPeriods(nil)
+
+	ptt := timestampFromTime(time.Now()) - 400*24*3600*1000
+	pt, err := createPartition(ptt, "./small-table-downsampling", "./big-table-downsampling", nilGetDeletedMetricIDs)
+	if err != nil {
+		t.Fatalf("cannot create partition: %s", err)
+	}
+	defer func() {
+		pt.MustClose()
+		if err := os.RemoveAll("./small-table-downsampling"); err != nil {
+			t.Fatalf("cannot remove small parts directory: %s", err)
+		}
+		if err := os.RemoveAll("./big-table-downsampling"); err != nil {
+			t.Fatalf("cannot remove big parts directory: %s", err)
+		}
+	}()
+
+	// Create two parts with 1s interval between rows.
+	const rowsPerPart = 600
+	for i := 0; i < 2; i++ {
+		var rows []rawRow
+		var r rawRow
+		r.PrecisionBits = defaultPrecisionBits
+		r.TSID.MetricID = 123
+		for j := 0; j < rowsPerPart; j++ {
+			r.Timestamp = pt.tr.MinTimestamp + int64(i*rowsPerPart+j)*1000
+			r.Value = float64(j)
+			rows = append(rows, r)
+		}
+		pt.AddRows(rows)
+		pt.flushRawRows(nil, true)
+	}
+	getRowsCount := func() uint64 {
+		pt.partsLock.Lock()
+		defer pt.partsLock.Unlock()
+		rowsCount := uint64(0)
+		for _, pws := range [][]*partWrapper{pt.smallParts, pt.bigParts} {
+			for _, pw := range pws {
+				rowsCount += pw.p.ph.RowsCount
+			}
+		}
+		return rowsCount
+	}
+
+	// Mark a part as being merged. The downsampling interval mustn't be saved for the partition
+	// until this part is downsampled.
+	pt.partsLock.Lock()
+	var pwBusy *partWrapper
+	for _, pw := range pt.smallParts {
+		if !pw.isInMerge {
+			pwBusy = pw
+			pw.isInMerge = true
+			break
+		}
+	}
+	pt.partsLock.Unlock()
+	if pwBusy == nil {
+		t.Fatalf("cannot find a part, which isn't merged now")
+	}
+
+	// Enable downsampling after marking the part as busy, so background merges cannot downsample it before.
+	SetDownsamplingPeriods([]DownsamplingPeriod{
+		{
+			Offset:   24 * 3600 * 1000,
+			Interval: 60 * 1000,
+		},
+	})
+	err = pt.downsampleParts()
+	pt.releasePartsToMerge([]*partWrapper{pwBusy})
+	if err != nil && err != errNothingToMerge {
+		t.Fatalf("unexpected error when downsampling parts: %s", err)
+	}
+	if interval := atomic.LoadInt64(&pt.downsamplingInterval); interval != 0 {
+		t.Fatalf("unexpected downsampling interval for the partition with busy parts; got %d; want 0", interval)
+	}
+	rowsCount := getRowsCount()
+	if rowsCount <= rowsPerPart {
+		t.Fatalf("the busy part mustn't be downsampled; got %d rows; want more than %d rows", rowsCount, rowsPerPart)
+	}
+	if rowsCount >= 2*rowsPerPart {
+		t.Fatalf("the free part must be downsampled; got %d rows; want less than %d rows", rowsCount, 2*rowsPerPart)
+	}
+
+	// The remaining parts must be downsampled after concurrent merges are finished.
+	deadline := time.Now().Add(10 * time.Second)
+	for atomic.LoadInt64(&pt.downsamplingInterval) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout when waiting for partition downsampling")
+		}
+		if err := pt.downsampleParts(); err != nil && err != errNothingToMerge {
+			t.Fatalf("unexpected error when downsampling parts: %s", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if interval := atomic.LoadInt64(&pt.downsamplingInterval); interval != 60*1000 {
+		t.Fatalf("unexpected downsampling interval; got %d; want %d", interval, 60*1000)
+	}
+	// A single row per minute must be left.
+	if rowsCount := getRowsCount(); rowsCount > 2*rowsPerPart/60+2 {
+		t.Fatalf("too many rows left after the downsampling; got %d; want up to %d", rowsCount, 2*rowsPerPart/60+2)
+	}
+
+	// Parts backfilled after the partition downsampling must be downsampled too.
+	var rows []rawRow
+	var r rawRow
+	r.PrecisionBits = defaultPrecisionBits
+	r.TSID.MetricID = 456
+	for j := 0; j < rowsPerPart; j++ {
+		r.Timestamp = pt.tr.MinTimestamp + int64(j)*1000
+		r.Value = float64(j)
+		rows = append(rows, r)
+	}
+	pt.AddRows(rows)
+	pt.flushRawRows(nil, true)
+	deadline = time.Now().Add(10 * time.Second)
+	for getRowsCount() > 3*rowsPerPart/60+3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout when waiting for downsampling of the backfilled part; got %d rows", getRowsCount())
+		}
+		if err := pt.downsampleParts(); err != nil && err != errNothingToMerge {
+			t.Fatalf("unexpected error when downsampling parts: %s", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if interval := atomic.LoadInt64(&pt.downsamplingInterval); interval != 60*1000 {
+		t.Fatalf("unexpected downsampling interval after backfilling; got %d; want %d", interval, 60*1000)
+	}
+}
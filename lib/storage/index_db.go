@@ -2,6 +2,7 @@ package storage
 
 import (
 	"bytes"
+	"container/heap"
 	"errors"
 	"fmt"
 	"io"
@@ -349,9 +350,7 @@ func marshalTagFiltersKey(dst []byte, tfss []*TagFilters, versioned bool) []byte
 	dst = encoding.MarshalUint64(dst, prefix)
 	for _, tfs := range tfss {
 		dst = append(dst, 0) // separator between tfs groups.
-		for i := range tfs.tfs {
-			dst = tfs.tfs[i].Marshal(dst)
-		}
+		dst = tfs.marshal(dst)
 	}
 	return dst
 }
@@ -520,6 +519,8 @@ func (db *indexDB) generateTSID(dst *TSID, metricName []byte, mn *MetricName) er
 
 	// The TSID wan't found in the external storage.
 	// Generate it locally.
+	dst.AccountID = mn.AccountID
+	dst.ProjectID = mn.ProjectID
 	dst.MetricGroupID = xxhash.Sum64(mn.MetricGroup)
 	if len(mn.Tags) > 0 {
 		dst.JobID = uint32(xxhash.Sum64(mn.Tags[0].Value))
@@ -545,19 +546,19 @@ func (db *indexDB) createIndexes(tsid *TSID, mn *MetricName) error {
 	items.Next()
 
 	// Create MetricID -> MetricName index.
-	items.B = marshalCommonPrefix(items.B, nsPrefixMetricIDToMetricName)
+	items.B = marshalCommonPrefix(items.B, nsPrefixMetricIDToMetricName, mn.AccountID, mn.ProjectID)
 	items.B = encoding.MarshalUint64(items.B, tsid.MetricID)
 	items.B = mn.Marshal(items.B)
 	items.Next()
 
 	// Create MetricID -> TSID index.
-	items.B = marshalCommonPrefix(items.B, nsPrefixMetricIDToTSID)
+	items.B = marshalCommonPrefix(items.B, nsPrefixMetricIDToTSID, mn.AccountID, mn.ProjectID)
 	items.B = encoding.MarshalUint64(items.B, tsid.MetricID)
 	items.B = tsid.Marshal(items.B)
 	items.Next()
 
 	commonPrefix := kbPool.Get()
-	commonPrefix.B = marshalCommonPrefix(commonPrefix.B[:0], nsPrefixTagToMetricID)
+	commonPrefix.B = marshalCommonPrefix(commonPrefix.B[:0], nsPrefixTagToMetricID, mn.AccountID, mn.ProjectID)
 
 	// Create MetricGroup -> MetricID index.
 	items.B = append(items.B, commonPrefix.B...)
@@ -614,14 +615,14 @@ func putIndexItems(ii *indexItems) {
 
 var indexItemsPool sync.Pool
 
-// SearchTagKeys returns all the tag keys.
-func (db *indexDB) SearchTagKeys(maxTagKeys int) ([]string, error) {
+// SearchTagKeys returns all the tag keys for the given accountID, projectID.
+func (db *indexDB) SearchTagKeys(accountID, projectID uint32, maxTagKeys int) ([]string, error) {
 	// TODO: cache results?
 
 	tks := make(map[string]struct{})
 
 	is := db.getIndexSearch()
-	err := is.searchTagKeys(tks, maxTagKeys)
+	err := is.searchTagKeys(tks, accountID, projectID, maxTagKeys)
 	db.putIndexSearch(is)
 	if err != nil {
 		return nil, err
@@ -629,7 +630,7 @@ func (db *indexDB) SearchTagKeys(maxTagKeys int) ([]string, error) {
 
 	ok := db.doExtDB(func(extDB *indexDB) {
 		is := extDB.getIndexSearch()
-		err = is.searchTagKeys(tks, maxTagKeys)
+		err = is.searchTagKeys(tks, accountID, projectID, maxTagKeys)
 		extDB.putIndexSearch(is)
 	})
 	if ok && err != nil {
@@ -645,11 +646,11 @@ func (db *indexDB) SearchTagKeys(maxTagKeys int) ([]string, error) {
 	return keys, nil
 }
 
-func (is *indexSearch) searchTagKeys(tks map[string]struct{}, maxTagKeys int) error {
+func (is *indexSearch) searchTagKeys(tks map[string]struct{}, accountID, projectID uint32, maxTagKeys int) error {
 	ts := &is.ts
 	kb := &is.kb
 	dmis := is.db.getDeletedMetricIDs()
-	commonPrefix := marshalCommonPrefix(nil, nsPrefixTagToMetricID)
+	commonPrefix := marshalCommonPrefix(nil, nsPrefixTagToMetricID, accountID, projectID)
 	ts.Seek(commonPrefix)
 	for len(tks) < maxTagKeys && ts.NextItem() {
 		item := ts.Item
@@ -696,12 +697,12 @@ func (is *indexSearch) searchTagKeys(tks map[string]struct{}, maxTagKeys int) er
 	return nil
 }
 
-// SearchTagValues returns all the tag values for the given tagKey
-func (db *indexDB) SearchTagValues(tagKey []byte, maxTagValues int) ([]string, error) {
+// SearchTagValues returns all the tag values for the given accountID, projectID and tagKey
+func (db *indexDB) SearchTagValues(accountID, projectID uint32, tagKey []byte, maxTagValues int) ([]string, error) {
 	// TODO: cache results?
 
 	kb := kbPool.Get()
-	kb.B = marshalCommonPrefix(kb.B[:0], nsPrefixTagToMetricID)
+	kb.B = marshalCommonPrefix(kb.B[:0], nsPrefixTagToMetricID, accountID, projectID)
 	kb.B = marshalTagValue(kb.B, tagKey)
 
 	tvs := make(map[string]struct{})
@@ -783,13 +784,115 @@ func (is *indexSearch) searchTagValues(tvs map[string]struct{}, prefix []byte, m
 	return nil
 }
 
-// GetSeriesCount returns the approximate number of unique timeseries in the db.
+// SearchTagValueSuffixes returns all the tag value suffixes for the given tagKey and tagValuePrefix in (accountID, projectID).
+//
+// Each suffix is cut after the first delimiter, so only the next node of hierarchical tag values
+// such as Graphite metric paths is returned. Suffixes ending with delimiter have child nodes.
+// Child nodes aren't scanned, so the search doesn't depend on the number of the tag values under the returned nodes.
+func (db *indexDB) SearchTagValueSuffixes(accountID, projectID uint32, tagKey, tagValuePrefix []byte, delimiter byte, maxTagValueSuffixes int) ([]string, error) {
+	// TODO: cache results?
+
+	tvss := make(map[string]struct{})
+	is := db.getIndexSearch()
+	err := is.searchTagValueSuffixes(tvss, accountID, projectID, tagKey, tagValuePrefix, delimiter, maxTagValueSuffixes)
+	db.putIndexSearch(is)
+	if err != nil {
+		return nil, err
+	}
+	ok := db.doExtDB(func(extDB *indexDB) {
+		is := extDB.getIndexSearch()
+		err = is.searchTagValueSuffixes(tvss, accountID, projectID, tagKey, tagValuePrefix, delimiter, maxTagValueSuffixes)
+		extDB.putIndexSearch(is)
+	})
+	if ok && err != nil {
+		return nil, err
+	}
+
+	suffixes := make([]string, 0, len(tvss))
+	for suffix := range tvss {
+		suffixes = append(suffixes, suffix)
+	}
+
+	// Do not sort suffixes, since they must be sorted by vmselect.
+	return suffixes, nil
+}
+
+func (is *indexSearch) searchTagValueSuffixes(tvss map[string]struct{}, accountID, projectID uint32, tagKey, tagValuePrefix []byte, delimiter byte, maxTagValueSuffixes int) error {
+	if delimiter == escapeChar || delimiter == tagSeparatorChar || delimiter == kvSeparatorChar || delimiter == 0xff {
+		return fmt.Errorf("unsupported delimiter %q", delimiter)
+	}
+	ts := &is.ts
+	kb := &is.kb
+	dmis := is.db.getDeletedMetricIDs()
+
+	// tkp (tag key prefix) contains (commonPrefix + encoded tag key).
+	tkp := marshalCommonPrefix(nil, nsPrefixTagToMetricID, accountID, projectID)
+	tkp = marshalTagValue(tkp, tagKey)
+	prefix := marshalTagValueNoTrailingTagSeparator(tkp, tagValuePrefix)
+	var tagValue []byte
+	ts.Seek(prefix)
+	for len(tvss) < maxTagValueSuffixes && ts.NextItem() {
+		k := ts.Item
+		if !bytes.HasPrefix(k, prefix) {
+			break
+		}
+
+		// Get TagValue
+		var err error
+		k, tagValue, err = unmarshalTagValue(tagValue[:0], k[len(tkp):])
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal tagValue: %s", err)
+		}
+		if len(k) != 8 {
+			return fmt.Errorf("unexpected suffix after tag value; want %d bytes; got %d bytes", 8, len(k))
+		}
+
+		// Verify whether the corresponding metric is deleted.
+		if len(dmis) > 0 {
+			metricID := encoding.UnmarshalUint64(k)
+			if _, deleted := dmis[metricID]; deleted {
+				// The metric is deleted.
+				continue
+			}
+		}
+
+		// Store tag value suffix up to the delimiter.
+		suffix := tagValue[len(tagValuePrefix):]
+		n := bytes.IndexByte(suffix, delimiter)
+		if n < 0 {
+			tvss[string(suffix)] = struct{}{}
+
+			// Search for the next tag value. The last char in the item prefix
+			// must be tagSeparatorChar. Just increment it in order to jump to the next tag value.
+			kb.B = append(kb.B[:0], ts.Item[:len(ts.Item)-8]...)
+			if len(kb.B) == 0 || kb.B[len(kb.B)-1] != tagSeparatorChar || tagSeparatorChar >= 0xff {
+				logger.Panicf("BUG: the last char in %X must be %X. Check unmarshalTagValue code", kb.B, tagSeparatorChar)
+			}
+			kb.B[len(kb.B)-1]++
+			ts.Seek(kb.B)
+			continue
+		}
+		tvss[string(suffix[:n+1])] = struct{}{}
+
+		// Skip child nodes by jumping to the tag values following (tagValuePrefix + suffix up to the delimiter).
+		kb.B = marshalTagValueNoTrailingTagSeparator(append(kb.B[:0], tkp...), tagValue[:len(tagValuePrefix)+n])
+		kb.B = append(kb.B, delimiter+1)
+		ts.Seek(kb.B)
+	}
+	if err := ts.Error(); err != nil {
+		return fmt.Errorf("error when searching for tag value suffixes for prefix %q: %s", prefix, err)
+	}
+	return nil
+}
+
+// GetSeriesCount returns the approximate number of unique timeseries in the db
+// for the given accountID, projectID.
 //
 // It includes the deleted series too and may count the same series
 // up to two times - in db and extDB.
-func (db *indexDB) GetSeriesCount() (uint64, error) {
+func (db *indexDB) GetSeriesCount(accountID, projectID uint32) (uint64, error) {
 	is := db.getIndexSearch()
-	n, err := is.getSeriesCount()
+	n, err := is.getSeriesCount(accountID, projectID)
 	db.putIndexSearch(is)
 	if err != nil {
 		return 0, err
@@ -798,7 +901,7 @@ func (db *indexDB) GetSeriesCount() (uint64, error) {
 	var nExt uint64
 	ok := db.doExtDB(func(extDB *indexDB) {
 		is := extDB.getIndexSearch()
-		nExt, err = is.getSeriesCount()
+		nExt, err = is.getSeriesCount(accountID, projectID)
 		extDB.putIndexSearch(is)
 	})
 	if ok && err != nil {
@@ -807,11 +910,358 @@ func (db *indexDB) GetSeriesCount() (uint64, error) {
 	return n + nExt, nil
 }
 
-// searchMetricName appends metric name for the given metricID to dst
+// TSDBStatus contains TSDB status data for /api/v1/status/tsdb.
+type TSDBStatus struct {
+	// TotalSeries is the number of series.
+	TotalSeries uint64
+
+	// SeriesCountByMetricName contains top metric names by the number of series.
+	SeriesCountByMetricName []TopHeapEntry
+
+	// LabelValueCountByLabelName contains top label names by the number of distinct values.
+	LabelValueCountByLabelName []TopHeapEntry
+
+	// SeriesCountByLabelValuePair contains top `label=value` pairs by the number of series.
+	SeriesCountByLabelValuePair []TopHeapEntry
+}
+
+// TopHeapEntry represents an entry from TSDBStatus.
+type TopHeapEntry struct {
+	Name  string
+	Count uint64
+}
+
+// GetTopHeapEntries returns up to topN entries with the biggest counts from m.
+//
+// Entries are sorted by Count in descending order.
+func GetTopHeapEntries(m map[string]uint64, topN int) []TopHeapEntry {
+	a := make([]TopHeapEntry, 0, len(m))
+	for name, count := range m {
+		a = append(a, TopHeapEntry{
+			Name:  name,
+			Count: count,
+		})
+	}
+	sort.Slice(a, func(i, j int) bool {
+		if a[i].Count != a[j].Count {
+			return a[i].Count > a[j].Count
+		}
+		return a[i].Name < a[j].Name
+	})
+	if len(a) > topN {
+		a = a[:topN]
+	}
+	return a
+}
+
+// GetTSDBStatusForDate returns TSDB status for the given (accountID, projectID) and date.
+//
+// date is the number of days since the Unix epoch. The whole index is used if date is 0.
+// Only topN entries are returned in each list. deadline is the unix timestamp in seconds
+// for the scan to finish.
+func (db *indexDB) GetTSDBStatusForDate(accountID, projectID uint32, date uint64, topN int, deadline uint64) (*TSDBStatus, error) {
+	var filter map[uint64]struct{}
+	if date > 0 {
+		filter = make(map[uint64]struct{})
+		is := db.getIndexSearch()
+		err := is.getMetricIDsForDate(date, filter, 1<<31-1, accountID, projectID)
+		db.putIndexSearch(is)
+		if err != nil && err != errMissingMetricIDsForDate {
+			return nil, err
+		}
+		ok := db.doExtDB(func(extDB *indexDB) {
+			is := extDB.getIndexSearch()
+			err = is.getMetricIDsForDate(date, filter, 1<<31-1, accountID, projectID)
+			extDB.putIndexSearch(is)
+		})
+		if ok && err != nil && err != errMissingMetricIDsForDate {
+			return nil, err
+		}
+	}
+
+	is := db.getIndexSearch()
+	defer db.putIndexSearch(is)
+	var status *TSDBStatus
+	var err error
+	ok := db.doExtDB(func(extDB *indexDB) {
+		isExt := extDB.getIndexSearch()
+		status, err = is.getTSDBStatus(isExt, accountID, projectID, filter, topN, deadline)
+		extDB.putIndexSearch(isExt)
+	})
+	if !ok {
+		status, err = is.getTSDBStatus(nil, accountID, projectID, filter, topN, deadline)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// getTSDBStatus calculates TSDB status from Tag->MetricID entries in is and isExt.
+//
+// Entries are read in sorted order from both indexDBs simultaneously, so series registered
+// in both indexDBs after the last rotation are counted only once, while only topN entries
+// are kept in memory for each list. isExt may be nil. Only series from filter are counted if filter isn't nil.
+func (is *indexSearch) getTSDBStatus(isExt *indexSearch, accountID, projectID uint32, filter map[uint64]struct{}, topN int, deadline uint64) (*TSDBStatus, error) {
+	dmis := is.db.getDeletedMetricIDs()
+	commonPrefix := marshalCommonPrefix(nil, nsPrefixTagToMetricID, accountID, projectID)
+	src := newTagToMetricIDItemsReader(is, commonPrefix)
+	srcExt := newTagToMetricIDItemsReader(isExt, commonPrefix)
+
+	var totalSeries uint64
+	seriesCountByMetricName := newTopHeap(topN)
+	labelValueCountByLabelName := newTopHeap(topN)
+	seriesCountByLabelValuePair := newTopHeap(topN)
+
+	// pair contains (marshaled tag key + marshaled tag value) for the series counted in seriesCount.
+	var pair, labelName, labelValue, nameBuf []byte
+	var seriesCount uint64
+	// prevLabelName contains the label name for the values counted in labelValuesCount.
+	var prevLabelName []byte
+	var labelValuesCount uint64
+	flushPair := func() error {
+		if seriesCount == 0 {
+			return nil
+		}
+		tail, key, err := unmarshalTagValue(labelName[:0], pair)
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal tag key from %X: %s", pair, err)
+		}
+		labelName = key
+		_, value, err := unmarshalTagValue(labelValue[:0], tail)
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal tag value from %X: %s", pair, err)
+		}
+		labelValue = value
+		name := labelName
+		if len(name) == 0 {
+			// Each series has exactly one metric name entry.
+			totalSeries += seriesCount
+			seriesCountByMetricName.add(labelValue, seriesCount)
+			name = metricNameLabel
+		}
+		if string(name) != string(prevLabelName) {
+			// Entries for each label name are contiguous, since they are sorted by the marshaled tag key.
+			labelValueCountByLabelName.add(prevLabelName, labelValuesCount)
+			prevLabelName = append(prevLabelName[:0], name...)
+			labelValuesCount = 0
+		}
+		labelValuesCount++
+		nameBuf = append(nameBuf[:0], name...)
+		nameBuf = append(nameBuf, '=')
+		nameBuf = append(nameBuf, labelValue...)
+		seriesCountByLabelValuePair.add(nameBuf, seriesCount)
+		seriesCount = 0
+		return nil
+	}
+
+	loops := 0
+	for src.hasItem() || srcExt.hasItem() {
+		if loops&(1<<16-1) == 0 && uint64(time.Now().Unix()) > deadline {
+			return nil, fmt.Errorf("the deadline exceeded during TSDB status calculation; try specifying `date` query arg or increasing -search.maxQueryDuration")
+		}
+		loops++
+
+		// Obtain the next item in sorted order. Identical items from both indexDBs are read only once.
+		var item []byte
+		nextSrc, nextSrcExt := false, false
+		switch {
+		case !srcExt.hasItem():
+			item, nextSrc = src.item, true
+		case !src.hasItem():
+			item, nextSrcExt = srcExt.item, true
+		default:
+			switch bytes.Compare(src.item, srcExt.item) {
+			case -1:
+				item, nextSrc = src.item, true
+			case 1:
+				item, nextSrcExt = srcExt.item, true
+			default:
+				item, nextSrc, nextSrcExt = src.item, true, true
+			}
+		}
+
+		if len(item) < len(commonPrefix)+8 {
+			return nil, fmt.Errorf("cannot unmarshal metricID from less than 8 bytes; item=%X", item)
+		}
+		itemPair := item[len(commonPrefix) : len(item)-8]
+		if string(itemPair) != string(pair) {
+			if err := flushPair(); err != nil {
+				return nil, err
+			}
+			pair = append(pair[:0], itemPair...)
+		}
+		metricID := encoding.UnmarshalUint64(item[len(item)-8:])
+		_, deleted := dmis[metricID]
+		if !deleted {
+			if filter == nil {
+				seriesCount++
+			} else if _, ok := filter[metricID]; ok {
+				seriesCount++
+			}
+		}
+
+		if nextSrc {
+			src.next()
+		}
+		if nextSrcExt {
+			srcExt.next()
+		}
+	}
+	if err := src.error(); err != nil {
+		return nil, err
+	}
+	if err := srcExt.error(); err != nil {
+		return nil, err
+	}
+	if err := flushPair(); err != nil {
+		return nil, err
+	}
+	labelValueCountByLabelName.add(prevLabelName, labelValuesCount)
+	return &TSDBStatus{
+		TotalSeries:                 totalSeries,
+		SeriesCountByMetricName:     seriesCountByMetricName.getSortedResult(),
+		LabelValueCountByLabelName:  labelValueCountByLabelName.getSortedResult(),
+		SeriesCountByLabelValuePair: seriesCountByLabelValuePair.getSortedResult(),
+	}, nil
+}
+
+var metricNameLabel = []byte("__name__")
+
+// tagToMetricIDItemsReader reads Tag->MetricID items with the given prefix in sorted order.
+type tagToMetricIDItemsReader struct {
+	ts     *mergeset.TableSearch
+	prefix []byte
+
+	// item is the current item. It is nil if there are no more items.
+	item []byte
+}
+
+// newTagToMetricIDItemsReader returns a reader for items with the given prefix from is.
+//
+// The returned reader contains no items if is is nil.
+func newTagToMetricIDItemsReader(is *indexSearch, prefix []byte) *tagToMetricIDItemsReader {
+	r := &tagToMetricIDItemsReader{
+		prefix: prefix,
+	}
+	if is == nil {
+		return r
+	}
+	r.ts = &is.ts
+	r.ts.Seek(prefix)
+	r.next()
+	return r
+}
+
+func (r *tagToMetricIDItemsReader) hasItem() bool {
+	return r.item != nil
+}
+
+func (r *tagToMetricIDItemsReader) next() {
+	if r.ts.NextItem() && bytes.HasPrefix(r.ts.Item, r.prefix) {
+		r.item = r.ts.Item
+		return
+	}
+	r.item = nil
+}
+
+func (r *tagToMetricIDItemsReader) error() error {
+	if r.ts == nil {
+		return nil
+	}
+	if err := r.ts.Error(); err != nil {
+		return fmt.Errorf("error when searching for prefix %q: %s", r.prefix, err)
+	}
+	return nil
+}
+
+// topHeap keeps up to topN entries with the biggest counts.
+type topHeap struct {
+	topN int
+	a    []TopHeapEntry
+}
+
+func newTopHeap(topN int) *topHeap {
+	return &topHeap{
+		topN: topN,
+	}
+}
+
+// add adds an entry with the given name and count to th.
+//
+// The name is copied only if the entry gets into th.
+func (th *topHeap) add(name []byte, count uint64) {
+	if count == 0 || th.topN <= 0 {
+		return
+	}
+	if len(th.a) == th.topN {
+		e := &th.a[0]
+		if count < e.Count || count == e.Count && string(name) >= e.Name {
+			return
+		}
+		e.Name = string(name)
+		e.Count = count
+		heap.Fix(th, 0)
+		return
+	}
+	heap.Push(th, TopHeapEntry{
+		Name:  string(name),
+		Count: count,
+	})
+}
+
+// getSortedResult returns entries from th sorted by Count in descending order.
+func (th *topHeap) getSortedResult() []TopHeapEntry {
+	a := append([]TopHeapEntry{}, th.a...)
+	sort.Slice(a, func(i, j int) bool {
+		if a[i].Count != a[j].Count {
+			return a[i].Count > a[j].Count
+		}
+		return a[i].Name < a[j].Name
+	})
+	return a
+}
+
+// Len implements heap.Interface.
+func (th *topHeap) Len() int {
+	return len(th.a)
+}
+
+// Less implements heap.Interface.
+//
+// The entry with the smallest count is at the top of the heap, so it is replaced first.
+func (th *topHeap) Less(i, j int) bool {
+	a := th.a
+	if a[i].Count != a[j].Count {
+		return a[i].Count < a[j].Count
+	}
+	return a[i].Name > a[j].Name
+}
+
+// Swap implements heap.Interface.
+func (th *topHeap) Swap(i, j int) {
+	a := th.a
+	a[j], a[i] = a[i], a[j]
+}
+
+// Push implements heap.Interface.
+func (th *topHeap) Push(x interface{}) {
+	th.a = append(th.a, x.(TopHeapEntry))
+}
+
+// Pop implements heap.Interface.
+func (th *topHeap) Pop() interface{} {
+	a := th.a
+	x := a[len(a)-1]
+	th.a = a[:len(a)-1]
+	return x
+}
+
+// searchMetricName appends metric name for the given metricID, accountID and projectID to dst
 // and returns the result.
-func (db *indexDB) searchMetricName(dst []byte, metricID uint64) ([]byte, error) {
+func (db *indexDB) searchMetricName(dst []byte, metricID uint64, accountID, projectID uint32) ([]byte, error) {
 	is := db.getIndexSearch()
-	dst, err := is.searchMetricName(dst, metricID)
+	dst, err := is.searchMetricName(dst, metricID, accountID, projectID)
 	db.putIndexSearch(is)
 
 	if err != io.EOF {
@@ -821,7 +1271,7 @@ func (db *indexDB) searchMetricName(dst []byte, metricID uint64) ([]byte, error)
 	// Try searching in the external indexDB.
 	if db.doExtDB(func(extDB *indexDB) {
 		is := extDB.getIndexSearch()
-		dst, err = is.searchMetricName(dst, metricID)
+		dst, err = is.searchMetricName(dst, metricID, accountID, projectID)
 		extDB.putIndexSearch(is)
 	}) {
 		return dst, err
@@ -1034,7 +1484,7 @@ func (is *indexSearch) getTSIDByMetricName(dst *TSID, metricName []byte) error {
 	return io.EOF
 }
 
-func (is *indexSearch) searchMetricName(dst []byte, metricID uint64) ([]byte, error) {
+func (is *indexSearch) searchMetricName(dst []byte, metricID uint64, accountID, projectID uint32) ([]byte, error) {
 	metricName := is.db.getMetricNameFromCache(dst, metricID)
 	if len(metricName) > len(dst) {
 		return metricName, nil
@@ -1042,7 +1492,7 @@ func (is *indexSearch) searchMetricName(dst []byte, metricID uint64) ([]byte, er
 
 	ts := &is.ts
 	kb := &is.kb
-	kb.B = marshalCommonPrefix(kb.B[:0], nsPrefixMetricIDToMetricName)
+	kb.B = marshalCommonPrefix(kb.B[:0], nsPrefixMetricIDToMetricName, accountID, projectID)
 	kb.B = encoding.MarshalUint64(kb.B, metricID)
 	if err := ts.FirstItemWithPrefix(kb.B); err != nil {
 		if err == io.EOF {
@@ -1084,8 +1534,11 @@ func mergeTSIDs(a, b []TSID) []TSID {
 }
 
 func (is *indexSearch) searchTSIDs(tfss []*TagFilters, tr TimeRange, maxMetrics int) ([]TSID, error) {
+	accountID := tfss[0].accountID
+	projectID := tfss[0].projectID
+
 	// Verify whether `is` contains data for the given tr.
-	ok, err := is.containsTimeRange(tr)
+	ok, err := is.containsTimeRange(tr, accountID, projectID)
 	if err != nil {
 		return nil, fmt.Errorf("error in containsTimeRange(%s): %s", &tr, err)
 	}
@@ -1118,7 +1571,7 @@ func (is *indexSearch) searchTSIDs(tfss []*TagFilters, tr TimeRange, maxMetrics
 		if err != io.EOF {
 			return nil, err
 		}
-		if err := is.getTSIDByMetricID(tsid, metricID); err != nil {
+		if err := is.getTSIDByMetricID(tsid, metricID, accountID, projectID); err != nil {
 			if err == io.EOF {
 				// Cannot find TSID for the given metricID.
 				// This may be the case on incomplete indexDB
@@ -1138,12 +1591,12 @@ func (is *indexSearch) searchTSIDs(tfss []*TagFilters, tr TimeRange, maxMetrics
 	return tsids, nil
 }
 
-func (is *indexSearch) getTSIDByMetricID(dst *TSID, metricID uint64) error {
+func (is *indexSearch) getTSIDByMetricID(dst *TSID, metricID uint64, accountID, projectID uint32) error {
 	// There is no need in checking for deleted metricIDs here, since they
 	// must be checked by the caller.
 	ts := &is.ts
 	kb := &is.kb
-	kb.B = marshalCommonPrefix(kb.B[:0], nsPrefixMetricIDToTSID)
+	kb.B = marshalCommonPrefix(kb.B[:0], nsPrefixMetricIDToTSID, accountID, projectID)
 	kb.B = encoding.MarshalUint64(kb.B, metricID)
 	if err := ts.FirstItemWithPrefix(kb.B); err != nil {
 		if err == io.EOF {
@@ -1162,11 +1615,11 @@ func (is *indexSearch) getTSIDByMetricID(dst *TSID, metricID uint64) error {
 	return nil
 }
 
-func (is *indexSearch) getSeriesCount() (uint64, error) {
+func (is *indexSearch) getSeriesCount(accountID, projectID uint32) (uint64, error) {
 	ts := &is.ts
 	kb := &is.kb
 	var n uint64
-	kb.B = append(kb.B[:0], nsPrefixMetricIDToTSID)
+	kb.B = marshalCommonPrefix(kb.B[:0], nsPrefixMetricIDToTSID, accountID, projectID)
 	ts.Seek(kb.B)
 	for ts.NextItem() {
 		if !bytes.HasPrefix(ts.Item, kb.B) {
@@ -1183,7 +1636,7 @@ func (is *indexSearch) getSeriesCount() (uint64, error) {
 
 // updateMetricIDsByMetricNameMatch matches metricName values for the given srcMetricIDs against tfs
 // and adds matching metrics to metricIDs.
-func (is *indexSearch) updateMetricIDsByMetricNameMatch(metricIDs, srcMetricIDs map[uint64]struct{}, tfs []*tagFilter) error {
+func (is *indexSearch) updateMetricIDsByMetricNameMatch(metricIDs, srcMetricIDs map[uint64]struct{}, tfs []*tagFilter, accountID, projectID uint32) error {
 	// sort srcMetricIDs in order to speed up Seek below.
 	sortedMetricIDs := make([]uint64, 0, len(srcMetricIDs))
 	for metricID := range srcMetricIDs {
@@ -1197,7 +1650,7 @@ func (is *indexSearch) updateMetricIDsByMetricNameMatch(metricIDs, srcMetricIDs
 	defer PutMetricName(mn)
 	for _, metricID := range sortedMetricIDs {
 		var err error
-		metricName.B, err = is.searchMetricName(metricName.B[:0], metricID)
+		metricName.B, err = is.searchMetricName(metricName.B[:0], metricID, accountID, projectID)
 		if err != nil {
 			return fmt.Errorf("cannot find metricName by metricID %d: %s", metricID, err)
 		}
@@ -1319,7 +1772,7 @@ func (is *indexSearch) getTagFilterWithMinMetricIDsCount(tfs *TagFilters, maxMet
 }
 
 func matchTagFilters(mn *MetricName, tfs []*tagFilter, kb *bytesutil.ByteBuffer) (bool, error) {
-	kb.B = marshalCommonPrefix(kb.B[:0], nsPrefixTagToMetricID)
+	kb.B = marshalCommonPrefix(kb.B[:0], nsPrefixTagToMetricID, mn.AccountID, mn.ProjectID)
 
 	for _, tf := range tfs {
 		if len(tf.key) == 0 {
@@ -1443,7 +1896,7 @@ func (is *indexSearch) updateMetricIDsForTagFilters(metricIDs map[uint64]struct{
 		// Allow fetching up to 20*maxMetrics metrics for the given time range
 		// in the hope these metricIDs will be filtered out by other filters below.
 		maxTimeRangeMetrics := 20 * maxMetrics
-		metricIDsForTimeRange, err := is.getMetricIDsForTimeRange(tr, maxTimeRangeMetrics+1)
+		metricIDsForTimeRange, err := is.getMetricIDsForTimeRange(tr, maxTimeRangeMetrics+1, tfs.accountID, tfs.projectID)
 		if err == errMissingMetricIDsForDate {
 			return fmt.Errorf("cannot find tag filter matching less than %d time series; either increase -search.maxUniqueTimeseries or use more specific tag filters",
 				maxMetrics)
@@ -1481,7 +1934,7 @@ func (is *indexSearch) updateMetricIDsForTagFilters(metricIDs map[uint64]struct{
 	for i, tf := range tfsPostponed {
 		mIDs, err := is.intersectMetricIDsWithTagFilter(tf, minMetricIDs)
 		if err == errFallbackToMetricNameMatch {
-			return is.updateMetricIDsByMetricNameMatch(metricIDs, minMetricIDs, tfsPostponed[i:])
+			return is.updateMetricIDsByMetricNameMatch(metricIDs, minMetricIDs, tfsPostponed[i:], tfs.accountID, tfs.projectID)
 		}
 		if err != nil {
 			return err
@@ -1705,12 +2158,12 @@ var errFallbackToMetricNameMatch = errors.New("fall back to updateMetricIDsByMet
 
 var errMissingMetricIDsForDate = errors.New("missing metricIDs for date")
 
-func (is *indexSearch) getMetricIDsForTimeRange(tr TimeRange, maxMetrics int) (map[uint64]struct{}, error) {
+func (is *indexSearch) getMetricIDsForTimeRange(tr TimeRange, maxMetrics int, accountID, projectID uint32) (map[uint64]struct{}, error) {
 	if tr.isZero() {
 		return nil, errMissingMetricIDsForDate
 	}
 	atomic.AddUint64(&is.db.recentHourMetricIDsSearchCalls, 1)
-	if metricIDs, ok := is.getMetricIDsForRecentHours(tr, maxMetrics); ok {
+	if metricIDs, ok := is.getMetricIDsForRecentHours(tr, maxMetrics, accountID, projectID); ok {
 		// Fast path: tr covers the current and / or the previous hour.
 		// Return the full list of metric ids for this time range.
 		atomic.AddUint64(&is.db.recentHourMetricIDsSearchHits, 1)
@@ -1727,7 +2180,7 @@ func (is *indexSearch) getMetricIDsForTimeRange(tr TimeRange, maxMetrics int) (m
 	}
 	metricIDs := make(map[uint64]struct{}, maxMetrics)
 	for minDate <= maxDate {
-		if err := is.getMetricIDsForDate(minDate, metricIDs, maxMetrics); err != nil {
+		if err := is.getMetricIDsForDate(minDate, metricIDs, maxMetrics, accountID, projectID); err != nil {
 			return nil, err
 		}
 		minDate++
@@ -1736,39 +2189,47 @@ func (is *indexSearch) getMetricIDsForTimeRange(tr TimeRange, maxMetrics int) (m
 	return metricIDs, nil
 }
 
-func (is *indexSearch) getMetricIDsForRecentHours(tr TimeRange, maxMetrics int) (map[uint64]struct{}, bool) {
+func (is *indexSearch) getMetricIDsForRecentHours(tr TimeRange, maxMetrics int, accountID, projectID uint32) (map[uint64]struct{}, bool) {
 	minHour := uint64(tr.MinTimestamp) / msecPerHour
 	maxHour := uint64(tr.MaxTimestamp) / msecPerHour
+	k := accountProjectKey{
+		AccountID: accountID,
+		ProjectID: projectID,
+	}
 	hmCurr := is.db.currHourMetricIDs.Load().(*hourMetricIDs)
 	if maxHour == hmCurr.hour && minHour == maxHour && hmCurr.isFull {
 		// The tr fits the current hour.
-		// Return a copy of hmCurr.m, because the caller may modify
+		// Return a copy of hmCurr.byTenant[k], because the caller may modify
 		// the returned map.
-		if len(hmCurr.m) > maxMetrics {
+		m := hmCurr.byTenant[k]
+		if len(m) > maxMetrics {
 			return nil, false
 		}
-		return getMetricIDsCopy(hmCurr.m), true
+		return getMetricIDsCopy(m), true
 	}
 	hmPrev := is.db.prevHourMetricIDs.Load().(*hourMetricIDs)
 	if maxHour == hmPrev.hour && minHour == maxHour && hmPrev.isFull {
 		// The tr fits the previous hour.
-		// Return a copy of hmPrev.m, because the caller may modify
+		// Return a copy of hmPrev.byTenant[k], because the caller may modify
 		// the returned map.
-		if len(hmPrev.m) > maxMetrics {
+		m := hmPrev.byTenant[k]
+		if len(m) > maxMetrics {
 			return nil, false
 		}
-		return getMetricIDsCopy(hmPrev.m), true
+		return getMetricIDsCopy(m), true
 	}
 	if maxHour == hmCurr.hour && minHour == hmPrev.hour && hmCurr.isFull && hmPrev.isFull {
 		// The tr spans the previous and the current hours.
-		if len(hmCurr.m)+len(hmPrev.m) > maxMetrics {
+		mCurr := hmCurr.byTenant[k]
+		mPrev := hmPrev.byTenant[k]
+		if len(mCurr)+len(mPrev) > maxMetrics {
 			return nil, false
 		}
-		metricIDs := make(map[uint64]struct{}, len(hmCurr.m)+len(hmPrev.m))
-		for metricID := range hmCurr.m {
+		metricIDs := make(map[uint64]struct{}, len(mCurr)+len(mPrev))
+		for metricID := range mCurr {
 			metricIDs[metricID] = struct{}{}
 		}
-		for metricID := range hmPrev.m {
+		for metricID := range mPrev {
 			metricIDs[metricID] = struct{}{}
 		}
 		return metricIDs, true
@@ -1784,9 +2245,9 @@ func getMetricIDsCopy(src map[uint64]struct{}) map[uint64]struct{} {
 	return dst
 }
 
-func (db *indexDB) storeDateMetricID(date, metricID uint64) error {
+func (db *indexDB) storeDateMetricID(date, metricID uint64, accountID, projectID uint32) error {
 	is := db.getIndexSearch()
-	ok, err := is.hasDateMetricID(date, metricID)
+	ok, err := is.hasDateMetricID(date, metricID, accountID, projectID)
 	db.putIndexSearch(is)
 	if err != nil {
 		return err
@@ -1798,7 +2259,7 @@ func (db *indexDB) storeDateMetricID(date, metricID uint64) error {
 
 	// Slow path: create (date, metricID) entry.
 	items := getIndexItems()
-	items.B = marshalCommonPrefix(items.B[:0], nsPrefixDateToMetricID)
+	items.B = marshalCommonPrefix(items.B[:0], nsPrefixDateToMetricID, accountID, projectID)
 	items.B = encoding.MarshalUint64(items.B, date)
 	items.B = encoding.MarshalUint64(items.B, metricID)
 	items.Next()
@@ -1807,10 +2268,10 @@ func (db *indexDB) storeDateMetricID(date, metricID uint64) error {
 	return err
 }
 
-func (is *indexSearch) hasDateMetricID(date, metricID uint64) (bool, error) {
+func (is *indexSearch) hasDateMetricID(date, metricID uint64, accountID, projectID uint32) (bool, error) {
 	ts := &is.ts
 	kb := &is.kb
-	kb.B = marshalCommonPrefix(kb.B[:0], nsPrefixDateToMetricID)
+	kb.B = marshalCommonPrefix(kb.B[:0], nsPrefixDateToMetricID, accountID, projectID)
 	kb.B = encoding.MarshalUint64(kb.B, date)
 	kb.B = encoding.MarshalUint64(kb.B, metricID)
 	if err := ts.FirstItemWithPrefix(kb.B); err != nil {
@@ -1825,10 +2286,10 @@ func (is *indexSearch) hasDateMetricID(date, metricID uint64) (bool, error) {
 	return true, nil
 }
 
-func (is *indexSearch) getMetricIDsForDate(date uint64, metricIDs map[uint64]struct{}, maxMetrics int) error {
+func (is *indexSearch) getMetricIDsForDate(date uint64, metricIDs map[uint64]struct{}, maxMetrics int, accountID, projectID uint32) error {
 	ts := &is.ts
 	kb := &is.kb
-	kb.B = marshalCommonPrefix(kb.B[:0], nsPrefixDateToMetricID)
+	kb.B = marshalCommonPrefix(kb.B[:0], nsPrefixDateToMetricID, accountID, projectID)
 	kb.B = encoding.MarshalUint64(kb.B, date)
 	ts.Seek(kb.B)
 	items := 0
@@ -1856,13 +2317,14 @@ func (is *indexSearch) getMetricIDsForDate(date uint64, metricIDs map[uint64]str
 	return nil
 }
 
-func (is *indexSearch) containsTimeRange(tr TimeRange) (bool, error) {
+func (is *indexSearch) containsTimeRange(tr TimeRange, accountID, projectID uint32) (bool, error) {
 	ts := &is.ts
 	kb := &is.kb
 
 	// Verify whether the maximum date in `ts` covers tr.MinTimestamp.
 	minDate := uint64(tr.MinTimestamp) / msecPerDay
-	kb.B = marshalCommonPrefix(kb.B[:0], nsPrefixDateToMetricID)
+	kb.B = marshalCommonPrefix(kb.B[:0], nsPrefixDateToMetricID, accountID, projectID)
+	prefix := kb.B
 	kb.B = encoding.MarshalUint64(kb.B, minDate)
 	ts.Seek(kb.B)
 	if !ts.NextItem() {
@@ -1871,7 +2333,7 @@ func (is *indexSearch) containsTimeRange(tr TimeRange) (bool, error) {
 		}
 		return false, nil
 	}
-	if !bytes.HasPrefix(ts.Item, kb.B[:1]) {
+	if !bytes.HasPrefix(ts.Item, prefix) {
 		// minDate exceeds max date from ts.
 		return false, nil
 	}
@@ -1954,8 +2416,10 @@ func getUniqueUint64() uint64 {
 // between VictoriaMetrics restarts.
 var uniqueUint64 = uint64(time.Now().UnixNano())
 
-func marshalCommonPrefix(dst []byte, nsPrefix byte) []byte {
+func marshalCommonPrefix(dst []byte, nsPrefix byte, accountID, projectID uint32) []byte {
 	dst = append(dst, nsPrefix)
+	dst = encoding.MarshalUint32(dst, accountID)
+	dst = encoding.MarshalUint32(dst, projectID)
 	return dst
 }
 
@@ -282,6 +282,8 @@ func testIndexDBGetOrCreateTSIDByName(db *indexDB, accountsCount, projectsCount,
 
 	for i := 0; i < 4e2+1; i++ {
 		var mn MetricName
+		mn.AccountID = uint32((i + 2) % accountsCount)
+		mn.ProjectID = uint32((i + 1) % projectsCount)
 
 		// Init MetricGroup.
 		mn.MetricGroup = []byte(fmt.Sprintf("metricGroup_%d\x00\x01\x02", i%metricGroups))
@@ -316,8 +318,8 @@ func testIndexDBCheckTSIDByName(db *indexDB, mns []MetricName, tsids []TSID, isC
 	date := uint64(timestampFromTime(time.Now())) / msecPerDay
 	for i := range tsids {
 		tsid := &tsids[i]
-		if err := db.storeDateMetricID(date, tsid.MetricID); err != nil {
-			return fmt.Errorf("error in storeDateMetricID(%d, %d): %s", date, tsid.MetricID, err)
+		if err := db.storeDateMetricID(date, tsid.MetricID, tsid.AccountID, tsid.ProjectID); err != nil {
+			return fmt.Errorf("error in storeDateMetricID(%d, %d, %d, %d): %s", date, tsid.MetricID, tsid.AccountID, tsid.ProjectID, err)
 		}
 	}
 	db.tb.DebugFlush()
@@ -331,15 +333,23 @@ func testIndexDBCheckTSIDByName(db *indexDB, mns []MetricName, tsids []TSID, isC
 		return false
 	}
 
-	timeseriesCounters := make(map[uint64]bool)
+	timeseriesCounters := make(map[accountProjectKey]map[uint64]bool)
 	var tsidCopy TSID
 	var metricNameCopy []byte
-	allKeys := make(map[string]bool)
+	allKeys := make(map[accountProjectKey]map[string]bool)
 	for i := range mns {
 		mn := &mns[i]
 		tsid := &tsids[i]
 
-		tc := timeseriesCounters
+		apKey := accountProjectKey{
+			AccountID: tsid.AccountID,
+			ProjectID: tsid.ProjectID,
+		}
+		tc := timeseriesCounters[apKey]
+		if tc == nil {
+			tc = make(map[uint64]bool)
+			timeseriesCounters[apKey] = tc
+		}
 		tc[tsid.MetricID] = true
 
 		mn.sortTags()
@@ -359,7 +369,7 @@ func testIndexDBCheckTSIDByName(db *indexDB, mns []MetricName, tsids []TSID, isC
 
 		// Search for metric name for the given metricID.
 		var err error
-		metricNameCopy, err = db.searchMetricName(metricNameCopy[:0], tsidCopy.MetricID)
+		metricNameCopy, err = db.searchMetricName(metricNameCopy[:0], tsidCopy.MetricID, tsidCopy.AccountID, tsidCopy.ProjectID)
 		if err != nil {
 			return fmt.Errorf("error in searchMetricName: %s", err)
 		}
@@ -368,7 +378,7 @@ func testIndexDBCheckTSIDByName(db *indexDB, mns []MetricName, tsids []TSID, isC
 		}
 
 		// Try searching metric name for non-existent MetricID.
-		buf, err := db.searchMetricName(nil, 1)
+		buf, err := db.searchMetricName(nil, 1, mn.AccountID, mn.ProjectID)
 		if err != io.EOF {
 			return fmt.Errorf("expecting io.EOF error when searching for non-existing metricID; got %v", err)
 		}
@@ -377,37 +387,44 @@ func testIndexDBCheckTSIDByName(db *indexDB, mns []MetricName, tsids []TSID, isC
 		}
 
 		// Test SearchTagValues
-		tvs, err := db.SearchTagValues(nil, 1e5)
+		tvs, err := db.SearchTagValues(mn.AccountID, mn.ProjectID, nil, 1e5)
 		if err != nil {
 			return fmt.Errorf("error in SearchTagValues for __name__: %s", err)
 		}
 		if !hasValue(tvs, mn.MetricGroup) {
 			return fmt.Errorf("SearchTagValues couldn't find %q; found %q", mn.MetricGroup, tvs)
 		}
+		apKeys := allKeys[apKey]
+		if apKeys == nil {
+			apKeys = make(map[string]bool)
+			allKeys[apKey] = apKeys
+		}
 		for i := range mn.Tags {
 			tag := &mn.Tags[i]
-			tvs, err := db.SearchTagValues(tag.Key, 1e5)
+			tvs, err := db.SearchTagValues(mn.AccountID, mn.ProjectID, tag.Key, 1e5)
 			if err != nil {
 				return fmt.Errorf("error in SearchTagValues for __name__: %s", err)
 			}
 			if !hasValue(tvs, tag.Value) {
 				return fmt.Errorf("SearchTagValues couldn't find %q=%q; found %q", tag.Key, tag.Value, tvs)
 			}
-			allKeys[string(tag.Key)] = true
+			apKeys[string(tag.Key)] = true
 		}
 	}
 
 	// Test SearchTagKeys
-	tks, err := db.SearchTagKeys(1e5)
-	if err != nil {
-		return fmt.Errorf("error in SearchTagKeys: %s", err)
-	}
-	if !hasValue(tks, nil) {
-		return fmt.Errorf("cannot find __name__ in %q", tks)
-	}
-	for key := range allKeys {
-		if !hasValue(tks, []byte(key)) {
-			return fmt.Errorf("cannot find %q in %q", key, tks)
+	for k, apKeys := range allKeys {
+		tks, err := db.SearchTagKeys(k.AccountID, k.ProjectID, 1e5)
+		if err != nil {
+			return fmt.Errorf("error in SearchTagKeys: %s", err)
+		}
+		if !hasValue(tks, nil) {
+			return fmt.Errorf("cannot find __name__ in %q", tks)
+		}
+		for key := range apKeys {
+			if !hasValue(tks, []byte(key)) {
+				return fmt.Errorf("cannot find %q in %q", key, tks)
+			}
 		}
 	}
 
@@ -415,12 +432,14 @@ func testIndexDBCheckTSIDByName(db *indexDB, mns []MetricName, tsids []TSID, isC
 	// Concurrent test may create duplicate timeseries, so GetSeriesCount
 	// would return more timeseries than needed.
 	if !isConcurrent {
-		n, err := db.GetSeriesCount()
-		if err != nil {
-			return fmt.Errorf("unexpected error in GetSeriesCount(): %s", err)
-		}
-		if n != uint64(len(timeseriesCounters)) {
-			return fmt.Errorf("unexpected GetSeriesCount(); got %d; want %d", n, uint64(len(timeseriesCounters)))
+		for k, tc := range timeseriesCounters {
+			n, err := db.GetSeriesCount(k.AccountID, k.ProjectID)
+			if err != nil {
+				return fmt.Errorf("unexpected error in GetSeriesCount(%v): %s", k, err)
+			}
+			if n != uint64(len(tc)) {
+				return fmt.Errorf("unexpected GetSeriesCount(%v); got %d; want %d", k, n, uint64(len(tc)))
+			}
 		}
 	}
 
@@ -430,7 +449,7 @@ func testIndexDBCheckTSIDByName(db *indexDB, mns []MetricName, tsids []TSID, isC
 		tsid := &tsids[i]
 
 		// Search without regexps.
-		tfs := NewTagFilters()
+		tfs := NewTagFilters(mn.AccountID, mn.ProjectID)
 		if err := tfs.Add(nil, mn.MetricGroup, false, false); err != nil {
 			return fmt.Errorf("cannot create tag filter for MetricGroup: %s", err)
 		}
@@ -476,7 +495,7 @@ func testIndexDBCheckTSIDByName(db *indexDB, mns []MetricName, tsids []TSID, isC
 		}
 
 		// Search with regexps.
-		tfs.Reset()
+		tfs.Reset(mn.AccountID, mn.ProjectID)
 		if err := tfs.Add(nil, mn.MetricGroup, false, true); err != nil {
 			return fmt.Errorf("cannot create regexp tag filter for MetricGroup: %s", err)
 		}
@@ -514,7 +533,7 @@ func testIndexDBCheckTSIDByName(db *indexDB, mns []MetricName, tsids []TSID, isC
 		}
 
 		// Search with filter matching zero results.
-		tfs.Reset()
+		tfs.Reset(mn.AccountID, mn.ProjectID)
 		if err := tfs.Add([]byte("non-existing-key"), []byte("foobar"), false, false); err != nil {
 			return fmt.Errorf("cannot add non-existing key: %s", err)
 		}
@@ -536,7 +555,7 @@ func testIndexDBCheckTSIDByName(db *indexDB, mns []MetricName, tsids []TSID, isC
 		}
 
 		// Search with empty filter. It should match all the results.
-		tfs.Reset()
+		tfs.Reset(mn.AccountID, mn.ProjectID)
 		tsidsFound, err = db.searchTSIDs([]*TagFilters{tfs}, TimeRange{}, 1e5)
 		if err != nil {
 			return fmt.Errorf("cannot search for common prefix: %s", err)
@@ -546,7 +565,7 @@ func testIndexDBCheckTSIDByName(db *indexDB, mns []MetricName, tsids []TSID, isC
 		}
 
 		// Search with empty metricGroup. It should match zero results.
-		tfs.Reset()
+		tfs.Reset(mn.AccountID, mn.ProjectID)
 		if err := tfs.Add(nil, nil, false, false); err != nil {
 			return fmt.Errorf("cannot create tag filter for empty metricGroup: %s", err)
 		}
@@ -559,11 +578,11 @@ func testIndexDBCheckTSIDByName(db *indexDB, mns []MetricName, tsids []TSID, isC
 		}
 
 		// Search with multiple tfss
-		tfs1 := NewTagFilters()
+		tfs1 := NewTagFilters(mn.AccountID, mn.ProjectID)
 		if err := tfs1.Add(nil, nil, false, false); err != nil {
 			return fmt.Errorf("cannot create tag filter for empty metricGroup: %s", err)
 		}
-		tfs2 := NewTagFilters()
+		tfs2 := NewTagFilters(mn.AccountID, mn.ProjectID)
 		if err := tfs2.Add(nil, mn.MetricGroup, false, false); err != nil {
 			return fmt.Errorf("cannot create tag filter for MetricGroup: %s", err)
 		}
@@ -575,6 +594,19 @@ func testIndexDBCheckTSIDByName(db *indexDB, mns []MetricName, tsids []TSID, isC
 			return fmt.Errorf("tsids is missing when searching for multiple tfss \ntsid=%+v\ntsidsFound=%+v\ntfs=%s\nmn=%s", tsid, tsidsFound, tfs, mn)
 		}
 
+		// Verify the search doesn't return tsids from another tenant.
+		tfs.Reset(mn.AccountID+1, mn.ProjectID)
+		if err := tfs.Add(nil, mn.MetricGroup, false, false); err != nil {
+			return fmt.Errorf("cannot create tag filter for MetricGroup: %s", err)
+		}
+		tsidsFound, err = db.searchTSIDs([]*TagFilters{tfs}, TimeRange{}, 1e5)
+		if err != nil {
+			return fmt.Errorf("cannot search for another tenant: %s", err)
+		}
+		if testHasTSID(tsidsFound, tsid) {
+			return fmt.Errorf("unexpected tsid found for another tenant\ntsid=%+v\ntsidsFound=%+v\ntfs=%s\nmn=%s", tsid, tsidsFound, tfs, mn)
+		}
+
 		// Verify empty tfss
 		tsidsFound, err = db.searchTSIDs(nil, TimeRange{}, 1e5)
 		if err != nil {
@@ -599,6 +631,8 @@ func testHasTSID(tsids []TSID, tsid *TSID) bool {
 
 func TestMatchTagFilters(t *testing.T) {
 	var mn MetricName
+	mn.AccountID = 123
+	mn.ProjectID = 456
 	mn.MetricGroup = append(mn.MetricGroup, "foobar_metric"...)
 	for i := 0; i < 5; i++ {
 		key := fmt.Sprintf("key %d", i)
@@ -608,7 +642,7 @@ func TestMatchTagFilters(t *testing.T) {
 	var bb bytesutil.ByteBuffer
 
 	var tfs TagFilters
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add(nil, []byte("foobar_metric"), false, false); err != nil {
 		t.Fatalf("cannot add filter: %s", err)
 	}
@@ -621,7 +655,7 @@ func TestMatchTagFilters(t *testing.T) {
 	}
 
 	// Empty tag filters should match.
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	ok, err = matchTagFilters(&mn, toTFPointers(tfs.tfs), &bb)
 	if err != nil {
 		t.Fatalf("unexpected error: %s", err)
@@ -631,7 +665,7 @@ func TestMatchTagFilters(t *testing.T) {
 	}
 
 	// Negative match by MetricGroup
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add(nil, []byte("foobar"), false, false); err != nil {
 		t.Fatalf("cannot add no regexp, no negative filter: %s", err)
 	}
@@ -642,7 +676,7 @@ func TestMatchTagFilters(t *testing.T) {
 	if ok {
 		t.Fatalf("Shouldn't match")
 	}
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add(nil, []byte("obar.+"), false, true); err != nil {
 		t.Fatalf("cannot add regexp, no negative filter: %s", err)
 	}
@@ -653,7 +687,7 @@ func TestMatchTagFilters(t *testing.T) {
 	if ok {
 		t.Fatalf("Shouldn't match")
 	}
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add(nil, []byte("foobar_metric"), true, false); err != nil {
 		t.Fatalf("cannot add no regexp, negative filter: %s", err)
 	}
@@ -664,7 +698,7 @@ func TestMatchTagFilters(t *testing.T) {
 	if ok {
 		t.Fatalf("Shouldn't match")
 	}
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add(nil, []byte("foob.+metric"), true, true); err != nil {
 		t.Fatalf("cannot add regexp, negative filter: %s", err)
 	}
@@ -677,7 +711,7 @@ func TestMatchTagFilters(t *testing.T) {
 	}
 
 	// Positive match by MetricGroup
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add(nil, []byte("foobar_metric"), false, false); err != nil {
 		t.Fatalf("cannot add no regexp, no negative filter: %s", err)
 	}
@@ -688,7 +722,7 @@ func TestMatchTagFilters(t *testing.T) {
 	if !ok {
 		t.Fatalf("Should match")
 	}
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add(nil, []byte("foobar.+etric"), false, true); err != nil {
 		t.Fatalf("cannot add regexp, no negative filter: %s", err)
 	}
@@ -699,7 +733,7 @@ func TestMatchTagFilters(t *testing.T) {
 	if !ok {
 		t.Fatalf("Should match")
 	}
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add(nil, []byte("obar_metric"), true, false); err != nil {
 		t.Fatalf("cannot add no regexp, negative filter: %s", err)
 	}
@@ -710,7 +744,7 @@ func TestMatchTagFilters(t *testing.T) {
 	if !ok {
 		t.Fatalf("Should match")
 	}
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add(nil, []byte("ob.+metric"), true, true); err != nil {
 		t.Fatalf("cannot add regexp, negative filter: %s", err)
 	}
@@ -723,7 +757,7 @@ func TestMatchTagFilters(t *testing.T) {
 	}
 
 	// Negative match by non-existing tag
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add([]byte("non-existing-tag"), []byte("foobar"), false, false); err != nil {
 		t.Fatalf("cannot add no regexp, no negative filter: %s", err)
 	}
@@ -734,7 +768,7 @@ func TestMatchTagFilters(t *testing.T) {
 	if ok {
 		t.Fatalf("Shouldn't match")
 	}
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add([]byte("non-existing-tag"), []byte("obar.+"), false, true); err != nil {
 		t.Fatalf("cannot add regexp, no negative filter: %s", err)
 	}
@@ -745,7 +779,7 @@ func TestMatchTagFilters(t *testing.T) {
 	if ok {
 		t.Fatalf("Shouldn't match")
 	}
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add([]byte("non-existing-tag"), []byte("foobar_metric"), true, false); err != nil {
 		t.Fatalf("cannot add no regexp, negative filter: %s", err)
 	}
@@ -756,7 +790,7 @@ func TestMatchTagFilters(t *testing.T) {
 	if ok {
 		t.Fatalf("Shouldn't match")
 	}
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add([]byte("non-existing-tag"), []byte("foob.+metric"), true, true); err != nil {
 		t.Fatalf("cannot add regexp, negative filter: %s", err)
 	}
@@ -769,7 +803,7 @@ func TestMatchTagFilters(t *testing.T) {
 	}
 
 	// Negative match by existing tag
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add([]byte("key 0"), []byte("foobar"), false, false); err != nil {
 		t.Fatalf("cannot add no regexp, no negative filter: %s", err)
 	}
@@ -780,7 +814,7 @@ func TestMatchTagFilters(t *testing.T) {
 	if ok {
 		t.Fatalf("Shouldn't match")
 	}
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add([]byte("key 1"), []byte("obar.+"), false, true); err != nil {
 		t.Fatalf("cannot add regexp, no negative filter: %s", err)
 	}
@@ -791,7 +825,7 @@ func TestMatchTagFilters(t *testing.T) {
 	if ok {
 		t.Fatalf("Shouldn't match")
 	}
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add([]byte("key 2"), []byte("value 2"), true, false); err != nil {
 		t.Fatalf("cannot add no regexp, negative filter: %s", err)
 	}
@@ -802,7 +836,7 @@ func TestMatchTagFilters(t *testing.T) {
 	if ok {
 		t.Fatalf("Shouldn't match")
 	}
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add([]byte("key 3"), []byte("v.+lue 3"), true, true); err != nil {
 		t.Fatalf("cannot add regexp, negative filter: %s", err)
 	}
@@ -815,7 +849,7 @@ func TestMatchTagFilters(t *testing.T) {
 	}
 
 	// Positive match by existing tag
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add([]byte("key 0"), []byte("value 0"), false, false); err != nil {
 		t.Fatalf("cannot add no regexp, no negative filter: %s", err)
 	}
@@ -826,7 +860,7 @@ func TestMatchTagFilters(t *testing.T) {
 	if !ok {
 		t.Fatalf("Should match")
 	}
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add([]byte("key 1"), []byte(".+lue 1"), false, true); err != nil {
 		t.Fatalf("cannot add regexp, no negative filter: %s", err)
 	}
@@ -837,7 +871,7 @@ func TestMatchTagFilters(t *testing.T) {
 	if !ok {
 		t.Fatalf("Should match")
 	}
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add([]byte("key 2"), []byte("value 3"), true, false); err != nil {
 		t.Fatalf("cannot add no regexp, negative filter: %s", err)
 	}
@@ -848,7 +882,7 @@ func TestMatchTagFilters(t *testing.T) {
 	if !ok {
 		t.Fatalf("Should match")
 	}
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add([]byte("key 3"), []byte("v.+lue 2"), true, true); err != nil {
 		t.Fatalf("cannot add regexp, negative filter: %s", err)
 	}
@@ -861,7 +895,7 @@ func TestMatchTagFilters(t *testing.T) {
 	}
 
 	// Positive match by multiple tags and MetricGroup
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	if err := tfs.Add([]byte("key 0"), []byte("value 0"), false, false); err != nil {
 		t.Fatalf("cannot add no regexp, no negative filter: %s", err)
 	}
@@ -895,7 +929,7 @@ func TestMatchTagFilters(t *testing.T) {
 	}
 
 	// Negative match by multiple tags and MetricGroup
-	tfs.Reset()
+	tfs.Reset(mn.AccountID, mn.ProjectID)
 	// Positive matches
 	if err := tfs.Add([]byte("key 0"), []byte("value 0"), false, false); err != nil {
 		t.Fatalf("cannot add no regexp, no negative filter: %s", err)
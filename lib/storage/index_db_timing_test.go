@@ -104,7 +104,12 @@ func BenchmarkIndexDBSearchTSIDs(b *testing.B) {
 	const recordsCount = 1e5
 
 	// Fill the db with recordsCount records.
+	const accountID = 12
+	const projectID = 34
+
 	var mn MetricName
+	mn.AccountID = accountID
+	mn.ProjectID = projectID
 	mn.MetricGroup = []byte("rps")
 	for i := 0; i < 2; i++ {
 		key := fmt.Sprintf("key_%d", i)
@@ -135,7 +140,7 @@ func BenchmarkIndexDBSearchTSIDs(b *testing.B) {
 		tfss := []*TagFilters{&tfs}
 		i := 0
 		for pb.Next() {
-			tfs.Reset()
+			tfs.Reset(accountID, projectID)
 			for j := range tags {
 				if err := tfs.Add(tags[j].Key, tags[j].Value, false, false); err != nil {
 					panic(fmt.Errorf("BUG: unexpected error: %s", err))
@@ -3,6 +3,7 @@ package storage
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
@@ -13,13 +14,15 @@ import (
 // mergeBlockStreams returns immediately if stopCh is closed.
 //
 // rowsMerged is atomically updated with the number of merged rows during the merge.
+//
+// Rows are downsampled with ds if it isn't nil.
 func mergeBlockStreams(ph *partHeader, bsw *blockStreamWriter, bsrs []*blockStreamReader, stopCh <-chan struct{}, rowsMerged *uint64,
-	deletedMetricIDs map[uint64]struct{}, rowsDeleted *uint64) error {
+	deletedMetricIDs map[uint64]struct{}, rowsDeleted *uint64, ds *downsampler) error {
 	ph.Reset()
 
 	bsm := bsmPool.Get().(*blockStreamMerger)
 	bsm.Init(bsrs)
-	err := mergeBlockStreamsInternal(ph, bsw, bsm, stopCh, rowsMerged, deletedMetricIDs, rowsDeleted)
+	err := mergeBlockStreamsInternal(ph, bsw, bsm, stopCh, rowsMerged, deletedMetricIDs, rowsDeleted, ds)
 	bsm.reset()
 	bsmPool.Put(bsm)
 	bsw.MustClose()
@@ -41,7 +44,7 @@ var bsmPool = &sync.Pool{
 var errForciblyStopped = fmt.Errorf("forcibly stopped")
 
 func mergeBlockStreamsInternal(ph *partHeader, bsw *blockStreamWriter, bsm *blockStreamMerger, stopCh <-chan struct{}, rowsMerged *uint64,
-	deletedMetricIDs map[uint64]struct{}, rowsDeleted *uint64) error {
+	deletedMetricIDs map[uint64]struct{}, rowsDeleted *uint64, ds *downsampler) error {
 	// Search for the first block to merge
 	var pendingBlock *Block
 	for bsm.NextBlock() {
@@ -55,6 +58,13 @@ func mergeBlockStreamsInternal(ph *partHeader, bsw *blockStreamWriter, bsm *bloc
 			*rowsDeleted += uint64(bsm.Block.bh.RowsCount)
 			continue
 		}
+		if err := deleteTombstonedRowsFromMergedBlock(bsm, rowsDeleted); err != nil {
+			return err
+		}
+		if bsm.Block.RowsCount() == 0 {
+			// All the rows in the block have been deleted.
+			continue
+		}
 		pendingBlock = getBlock()
 		pendingBlock.CopyFrom(bsm.Block)
 		break
@@ -77,6 +87,13 @@ func mergeBlockStreamsInternal(ph *partHeader, bsw *blockStreamWriter, bsm *bloc
 			*rowsDeleted += uint64(bsm.Block.bh.RowsCount)
 			continue
 		}
+		if err := deleteTombstonedRowsFromMergedBlock(bsm, rowsDeleted); err != nil {
+			return err
+		}
+		if bsm.Block.RowsCount() == 0 {
+			// All the rows in the block have been deleted.
+			continue
+		}
 
 		// Verify whether pendingBlock may be merged with bsm.Block (the current block).
 		if pendingBlock.bh.TSID.MetricID != bsm.Block.bh.TSID.MetricID {
@@ -85,14 +102,18 @@ func mergeBlockStreamsInternal(ph *partHeader, bsw *blockStreamWriter, bsm *bloc
 			if bsm.Block.bh.TSID.Less(&pendingBlock.bh.TSID) {
 				logger.Panicf("BUG: the next TSID=%+v is smaller than the current TSID=%+v", &bsm.Block.bh.TSID, &pendingBlock.bh.TSID)
 			}
-			bsw.WriteExternalBlock(pendingBlock, ph, rowsMerged)
+			if err := downsampleAndWriteBlock(bsw, pendingBlock, ph, rowsMerged, ds); err != nil {
+				return err
+			}
 			pendingBlock.CopyFrom(bsm.Block)
 			continue
 		}
 		if pendingBlock.tooBig() && pendingBlock.bh.MaxTimestamp <= bsm.Block.bh.MinTimestamp {
 			// Fast path - pendingBlock is too big and it doesn't overlap with bsm.Block.
 			// Write the pendingBlock and then deal with bsm.Block.
-			bsw.WriteExternalBlock(pendingBlock, ph, rowsMerged)
+			if err := downsampleAndWriteBlock(bsw, pendingBlock, ph, rowsMerged, ds); err != nil {
+				return err
+			}
 			pendingBlock.CopyFrom(bsm.Block)
 			continue
 		}
@@ -124,14 +145,41 @@ func mergeBlockStreamsInternal(ph *partHeader, bsw *blockStreamWriter, bsm *bloc
 		tmpBlock.timestamps = tmpBlock.timestamps[:maxRowsPerBlock]
 		tmpBlock.values = tmpBlock.values[:maxRowsPerBlock]
 		tmpBlock.fixupTimestamps()
-		bsw.WriteExternalBlock(tmpBlock, ph, rowsMerged)
+		if err := downsampleAndWriteBlock(bsw, tmpBlock, ph, rowsMerged, ds); err != nil {
+			return err
+		}
 	}
 	if err := bsm.Error(); err != nil {
 		return fmt.Errorf("cannot read block to be merged: %s", err)
 	}
 	if pendingBlock != nil {
-		bsw.WriteExternalBlock(pendingBlock, ph, rowsMerged)
+		if err := downsampleAndWriteBlock(bsw, pendingBlock, ph, rowsMerged, ds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteTombstonedRowsFromMergedBlock(bsm *blockStreamMerger, rowsDeleted *uint64) error {
+	tss := bsm.Tombstones()
+	if len(tss) == 0 {
+		return nil
+	}
+	n, err := deleteTombstonedRows(bsm.Block, tss)
+	if err != nil {
+		return fmt.Errorf("cannot delete tombstoned rows from block to be merged: %s", err)
+	}
+	*rowsDeleted += uint64(n)
+	return nil
+}
+
+func downsampleAndWriteBlock(bsw *blockStreamWriter, b *Block, ph *partHeader, rowsMerged *uint64, ds *downsampler) error {
+	n, err := ds.downsampleBlock(b)
+	if err != nil {
+		return fmt.Errorf("cannot downsample block to be merged: %s", err)
 	}
+	atomic.AddUint64(&downsampledRows, uint64(n))
+	bsw.WriteExternalBlock(b, ph, rowsMerged)
 	return nil
 }
 
@@ -140,6 +188,7 @@ func mergeBlocks(ob, ib1, ib2 *Block) {
 	ib1.assertMergeable(ib2)
 	ib1.assertUnmarshaled()
 	ib2.assertUnmarshaled()
+	ob.keepTimestamps = ib1.keepTimestamps || ib2.keepTimestamps
 
 	if ib1.bh.MaxTimestamp < ib2.bh.MinTimestamp {
 		// Fast path - ib1 values have smaller timestamps than ib2 values.
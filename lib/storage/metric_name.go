@@ -79,6 +79,13 @@ func marshalTagValue(dst, src []byte) []byte {
 	return dst
 }
 
+// marshalTagValueNoTrailingTagSeparator marshals src like marshalTagValue, but without the trailing tagSeparatorChar,
+// so the result may be used as a prefix for searching tag values starting with src.
+func marshalTagValueNoTrailingTagSeparator(dst, src []byte) []byte {
+	dst = marshalTagValue(dst, src)
+	return dst[:len(dst)-1]
+}
+
 func unmarshalTagValue(dst, src []byte) ([]byte, []byte, error) {
 	n := bytes.IndexByte(src, tagSeparatorChar)
 	if n < 0 {
@@ -113,6 +120,9 @@ func unmarshalTagValue(dst, src []byte) ([]byte, []byte, error) {
 
 // MetricName reperesents a metric name.
 type MetricName struct {
+	AccountID uint32
+	ProjectID uint32
+
 	MetricGroup []byte
 
 	// Tags are optional. They must be sorted by tag Key for canonical view.
@@ -139,12 +149,16 @@ var mnPool sync.Pool
 
 // Reset resets the mn.
 func (mn *MetricName) Reset() {
+	mn.AccountID = 0
+	mn.ProjectID = 0
 	mn.MetricGroup = mn.MetricGroup[:0]
 	mn.Tags = mn.Tags[:0]
 }
 
 // CopyFrom copies src to mn.
 func (mn *MetricName) CopyFrom(src *MetricName) {
+	mn.AccountID = src.AccountID
+	mn.ProjectID = src.ProjectID
 	if cap(mn.MetricGroup) > 0 {
 		mn.MetricGroup = append(mn.MetricGroup[:0], src.MetricGroup...)
 		mn.Tags = copyTags(mn.Tags[:0], src.Tags)
@@ -316,7 +330,7 @@ func (mn *MetricName) String() string {
 		tags = append(tags, fmt.Sprintf("%q=%q", t.Key, t.Value))
 	}
 	tagsStr := strings.Join(tags, ", ")
-	return fmt.Sprintf("MetricGroup=%q, tags=[%s]", mn.MetricGroup, tagsStr)
+	return fmt.Sprintf("AccountID=%d, ProjectID=%d, MetricGroup=%q, tags=[%s]", mn.AccountID, mn.ProjectID, mn.MetricGroup, tagsStr)
 }
 
 // Marshal appends marshaled mn to dst and returns the result.
@@ -325,7 +339,7 @@ func (mn *MetricName) String() string {
 func (mn *MetricName) Marshal(dst []byte) []byte {
 	// Calculate the required size and pre-allocate space in dst
 	dstLen := len(dst)
-	requiredSize := len(mn.MetricGroup) + 1
+	requiredSize := 8 + len(mn.MetricGroup) + 1
 	for i := range mn.Tags {
 		tag := &mn.Tags[i]
 		requiredSize += len(tag.Key) + len(tag.Value) + 2
@@ -333,6 +347,9 @@ func (mn *MetricName) Marshal(dst []byte) []byte {
 	dst = bytesutil.Resize(dst, requiredSize)
 	dst = dst[:dstLen]
 
+	dst = encoding.MarshalUint32(dst, mn.AccountID)
+	dst = encoding.MarshalUint32(dst, mn.ProjectID)
+
 	// Marshal MetricGroup
 	dst = marshalTagValue(dst, mn.MetricGroup)
 
@@ -343,6 +360,13 @@ func (mn *MetricName) Marshal(dst []byte) []byte {
 
 // Unmarshal unmarshals mn from src.
 func (mn *MetricName) Unmarshal(src []byte) error {
+	if len(src) < 8 {
+		return fmt.Errorf("too short src: %d bytes; must be at least %d bytes", len(src), 8)
+	}
+	mn.AccountID = encoding.UnmarshalUint32(src)
+	mn.ProjectID = encoding.UnmarshalUint32(src[4:])
+	src = src[8:]
+
 	// Unmarshal MetricGroup.
 	var err error
 	src, mn.MetricGroup, err = unmarshalTagValue(mn.MetricGroup[:0], src)
@@ -390,10 +414,19 @@ const maxLabelValueLen = 16 * 1024
 // Superflouos lables are dropped.
 const maxLabelsPerTimeseries = 30
 
-// MarshalMetricNameRaw marshals labels to dst and returns the result.
+// MarshalMetricNameRaw marshals labels for the given accountID and projectID to dst and returns the result.
 //
 // The result must be unmarshaled with MetricName.unmarshalRaw
-func MarshalMetricNameRaw(dst []byte, labels []prompb.Label) []byte {
+func MarshalMetricNameRaw(dst []byte, accountID, projectID uint32, labels []prompb.Label) []byte {
+	dst = encoding.MarshalUint32(dst, accountID)
+	dst = encoding.MarshalUint32(dst, projectID)
+	return MarshalMetricLabelsRaw(dst, labels)
+}
+
+// MarshalMetricLabelsRaw marshals labels without accountID and projectID to dst and returns the result.
+//
+// The result may be appended to MarshalMetricNameRaw output in order to add labels to the metric name.
+func MarshalMetricLabelsRaw(dst []byte, labels []prompb.Label) []byte {
 	// Calculate the required space for dst.
 	dstLen := len(dst)
 	dstSize := dstLen
@@ -444,6 +477,8 @@ func MarshalMetricNameRaw(dst []byte, labels []prompb.Label) []byte {
 // This function is for testing purposes. MarshalMetricNameRaw must be used
 // in prod instead.
 func (mn *MetricName) marshalRaw(dst []byte) []byte {
+	dst = encoding.MarshalUint32(dst, mn.AccountID)
+	dst = encoding.MarshalUint32(dst, mn.ProjectID)
 	dst = marshalBytesFast(dst, nil)
 	dst = marshalBytesFast(dst, mn.MetricGroup)
 
@@ -459,6 +494,12 @@ func (mn *MetricName) marshalRaw(dst []byte) []byte {
 // unmarshalRaw unmarshals mn encoded with MarshalMetricNameRaw.
 func (mn *MetricName) unmarshalRaw(src []byte) error {
 	mn.Reset()
+	if len(src) < 8 {
+		return fmt.Errorf("too short src: %d bytes; must be at least %d bytes", len(src), 8)
+	}
+	mn.AccountID = encoding.UnmarshalUint32(src)
+	mn.ProjectID = encoding.UnmarshalUint32(src[4:])
+	src = src[8:]
 	for len(src) > 0 {
 		tail, key, err := unmarshalBytesFast(src)
 		if err != nil {
@@ -38,6 +38,8 @@ func TestMetricNameMarshalUnmarshal(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		for tagsCount := 0; tagsCount < 10; tagsCount++ {
 			var mn MetricName
+			mn.AccountID = uint32(i)
+			mn.ProjectID = uint32(i + tagsCount)
 			for j := 0; j < tagsCount; j++ {
 				key := fmt.Sprintf("key_%d_%d_\x00\x01\x02", i, j)
 				value := fmt.Sprintf("\x02\x00\x01value_%d_%d", i, j)
@@ -53,6 +55,11 @@ func TestMetricNameMarshalUnmarshal(t *testing.T) {
 				t.Fatalf("unexpected mn unmarshaled;\ngot\n%+v\nwant\n%+v", &mn1, &mn)
 			}
 
+			// Try unmarshaling MetricName without tenant.
+			if err := mn1.Unmarshal(data[:7]); err == nil {
+				t.Fatalf("expecting non-zero error when unmarshaling MetricName without tenant")
+			}
+
 			// Try unmarshaling MetricName without tag value.
 			brokenData := marshalTagValue(data, []byte("foobar"))
 			if err := mn1.Unmarshal(brokenData); err == nil {
@@ -80,6 +87,8 @@ func TestMetricNameMarshalUnmarshalRaw(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		for tagsCount := 0; tagsCount < 10; tagsCount++ {
 			var mn MetricName
+			mn.AccountID = uint32(i)
+			mn.ProjectID = uint32(i + tagsCount)
 			for j := 0; j < tagsCount; j++ {
 				key := fmt.Sprintf("key_%d_%d_\x00\x01\x02", i, j)
 				value := fmt.Sprintf("\x02\x00\x01value_%d_%d", i, j)
@@ -94,6 +103,11 @@ func TestMetricNameMarshalUnmarshalRaw(t *testing.T) {
 				t.Fatalf("unexpected mn unmarshaled;\ngot\n%+v\nwant\n%+v", &mn1, &mn)
 			}
 
+			// Try unmarshaling MetricName without tenant.
+			if err := mn1.unmarshalRaw(data[:7]); err == nil {
+				t.Fatalf("expecting non-zero error when unmarshaling MetricName without tenant")
+			}
+
 			// Try unmarshaling MetricName without tag value.
 			brokenData := marshalTagValue(data, []byte("foobar"))
 			if err := mn1.unmarshalRaw(brokenData); err == nil {
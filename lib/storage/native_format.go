@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+)
+
+// nativeFormatMagic is the prefix for data exported in native format.
+const nativeFormatMagic = "vmnative"
+
+// NativeFormatVersion is the version of native export format.
+//
+// It must be incremented on incompatible changes in MetricBlock, MetricName or Block encoding,
+// so data exported by incompatible versions is rejected on import.
+const NativeFormatVersion = 1
+
+// NativeHeaderSize is the size of the header for data in native format.
+const NativeHeaderSize = len(nativeFormatMagic) + 4 + 8 + 8
+
+// MarshalNativeHeader appends native format header for the data on the given tr to dst and returns the result.
+//
+// The header must be followed by MetricBlocks marshaled with MarshalNativeMetricBlock.
+func MarshalNativeHeader(dst []byte, tr TimeRange) []byte {
+	dst = append(dst, nativeFormatMagic...)
+	dst = encoding.MarshalUint32(dst, NativeFormatVersion)
+	dst = encoding.MarshalInt64(dst, tr.MinTimestamp)
+	dst = encoding.MarshalInt64(dst, tr.MaxTimestamp)
+	return dst
+}
+
+// UnmarshalNativeHeader unmarshals native format header from src and returns the time range for the exported data.
+//
+// src must contain exactly NativeHeaderSize bytes.
+func UnmarshalNativeHeader(src []byte) (TimeRange, error) {
+	var tr TimeRange
+	if len(src) != NativeHeaderSize {
+		return tr, fmt.Errorf("unexpected native header size; got %d bytes; want %d bytes", len(src), NativeHeaderSize)
+	}
+	if string(src[:len(nativeFormatMagic)]) != nativeFormatMagic {
+		return tr, fmt.Errorf("missing %q prefix in the native header; make sure the data is obtained from /api/v1/export/native", nativeFormatMagic)
+	}
+	src = src[len(nativeFormatMagic):]
+	version := encoding.UnmarshalUint32(src)
+	if version != NativeFormatVersion {
+		return tr, fmt.Errorf("unsupported native format version %d; supported version is %d", version, NativeFormatVersion)
+	}
+	src = src[4:]
+	tr.MinTimestamp = encoding.UnmarshalInt64(src)
+	tr.MaxTimestamp = encoding.UnmarshalInt64(src[8:])
+	return tr, nil
+}
+
+// MarshalNativeMetricBlock appends mb in native format to dst and returns the result.
+//
+// The block is prefixed with its size, so it may be read from a stream with UnmarshalNativeMetricBlockSize.
+// mb.Block must be in marshaled form, i.e. it must be obtained from Search.
+func MarshalNativeMetricBlock(dst []byte, mb *MetricBlock) []byte {
+	dstLen := len(dst)
+	dst = encoding.MarshalUint32(dst, 0)
+	dst = mb.Marshal(dst)
+	size := uint32(len(dst) - dstLen - 4)
+	// Overwrite the size placeholder in place.
+	encoding.MarshalUint32(dst[:dstLen], size)
+	return dst
+}
+
+// UnmarshalNativeMetricBlockSize returns the size of the MetricBlock marshaled with MarshalNativeMetricBlock
+// from the 4-byte prefix in src.
+func UnmarshalNativeMetricBlockSize(src []byte) uint32 {
+	return encoding.UnmarshalUint32(src)
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+)
+
+func TestNativeHeaderMarshalUnmarshal(t *testing.T) {
+	tr := TimeRange{
+		MinTimestamp: -123,
+		MaxTimestamp: 1e15,
+	}
+	data := MarshalNativeHeader(nil, tr)
+	if len(data) != NativeHeaderSize {
+		t.Fatalf("unexpected header size; got %d; want %d", len(data), NativeHeaderSize)
+	}
+	tr2, err := UnmarshalNativeHeader(data)
+	if err != nil {
+		t.Fatalf("cannot unmarshal native header: %s", err)
+	}
+	if tr2 != tr {
+		t.Fatalf("unexpected time range; got %s; want %s", &tr2, &tr)
+	}
+}
+
+func TestNativeHeaderUnmarshalFailure(t *testing.T) {
+	f := func(data []byte) {
+		t.Helper()
+		if _, err := UnmarshalNativeHeader(data); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	header := MarshalNativeHeader(nil, TimeRange{})
+
+	// Empty data
+	f(nil)
+
+	// Truncated header
+	f(header[:NativeHeaderSize-1])
+
+	// Invalid magic
+	data := append([]byte{}, header...)
+	data[0] = 'x'
+	f(data)
+
+	// Unsupported version
+	data = append([]byte{}, nativeFormatMagic...)
+	data = encoding.MarshalUint32(data, NativeFormatVersion+1)
+	data = append(data, header[len(data):]...)
+	f(data)
+}
+
+func TestNativeMetricBlockMarshalUnmarshal(t *testing.T) {
+	var b Block
+	b.Init(&TSID{MetricID: 42}, []int64{1000, 2000, 3000}, []int64{1, 2, 3}, -1, 64)
+	b.MarshalData(0, 0)
+	mb := &MetricBlock{
+		MetricName: []byte("foobar"),
+		Block:      &b,
+	}
+	prefix := []byte("prefix")
+	data := MarshalNativeMetricBlock(append([]byte{}, prefix...), mb)
+	if string(data[:len(prefix)]) != string(prefix) {
+		t.Fatalf("prefix mustn't be changed; got %q; want %q", data[:len(prefix)], prefix)
+	}
+	data = data[len(prefix):]
+	size := UnmarshalNativeMetricBlockSize(data)
+	data = data[4:]
+	if int(size) != len(data) {
+		t.Fatalf("unexpected block size; got %d; want %d", size, len(data))
+	}
+
+	var mb2 MetricBlock
+	mb2.Block = &Block{}
+	tail, err := mb2.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("cannot unmarshal MetricBlock: %s", err)
+	}
+	if len(tail) > 0 {
+		t.Fatalf("unexpected tail left after unmarshaling MetricBlock: %q", tail)
+	}
+	if string(mb2.MetricName) != string(mb.MetricName) {
+		t.Fatalf("unexpected MetricName; got %q; want %q", mb2.MetricName, mb.MetricName)
+	}
+	if err := mb2.Block.UnmarshalData(); err != nil {
+		t.Fatalf("cannot unmarshal block data: %s", err)
+	}
+	if timestamps := mb2.Block.Timestamps(); !reflect.DeepEqual(timestamps, []int64{1000, 2000, 3000}) {
+		t.Fatalf("unexpected timestamps; got %d", timestamps)
+	}
+	if values := mb2.Block.Values(); !reflect.DeepEqual(values, []int64{1, 2, 3}) {
+		t.Fatalf("unexpected values; got %d", values)
+	}
+	if scale := mb2.Block.Scale(); scale != -1 {
+		t.Fatalf("unexpected scale; got %d; want -1", scale)
+	}
+}
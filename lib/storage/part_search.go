@@ -40,6 +40,9 @@ type partSearch struct {
 	compressedIndexBuf []byte
 	indexBuf           []byte
 
+	// tombstones contains tombstones for rows deleted from p.
+	tombstones []*tombstone
+
 	err error
 }
 
@@ -57,6 +60,10 @@ func (ps *partSearch) reset() {
 	}
 	ps.compressedIndexBuf = ps.compressedIndexBuf[:0]
 	ps.indexBuf = ps.indexBuf[:0]
+	for i := range ps.tombstones {
+		ps.tombstones[i] = nil
+	}
+	ps.tombstones = ps.tombstones[:0]
 	ps.err = nil
 }
 
@@ -270,6 +277,14 @@ func (ps *partSearch) searchBHS() bool {
 		// Found the tsid block with the matching timestamp range.
 		// Read it.
 		ps.readBlock(bh)
+		if !ps.deleteTombstonedRows() {
+			if ps.err != nil {
+				ps.bhs = nil
+				return false
+			}
+			// All the rows in the block have been deleted. Proceed to the next block.
+			continue
+		}
 
 		ps.bhs = ps.bhs[i+1:]
 		return true
@@ -279,6 +294,30 @@ func (ps *partSearch) searchBHS() bool {
 	return false
 }
 
+// deleteTombstonedRows deletes rows covered by ps.tombstones from ps.Block.
+//
+// Returns false if ps.Block has no rows left or on error.
+func (ps *partSearch) deleteTombstonedRows() bool {
+	if len(ps.tombstones) == 0 {
+		return true
+	}
+	n, err := deleteTombstonedRows(&ps.Block, ps.tombstones)
+	if err != nil {
+		ps.err = fmt.Errorf("cannot delete tombstoned rows from block in part %q: %s", &ps.p.ph, err)
+		return false
+	}
+	if n == 0 {
+		return true
+	}
+	if ps.Block.RowsCount() == 0 {
+		return false
+	}
+
+	// Marshal the block back, since the caller expects marshaled blocks.
+	ps.Block.MarshalData(0, 0)
+	return true
+}
+
 func (ps *partSearch) readBlock(bh *blockHeader) {
 	ps.Block.Reset()
 	ps.Block.timestampsData = bytesutil.Resize(ps.Block.timestampsData[:0], int(bh.TimestampsBlockSize))
@@ -101,7 +101,7 @@ type partition struct {
 	// The time range for the partition. Usually this is a whole month.
 	tr TimeRange
 
-	// partsLock protects smallParts and bigParts.
+	// partsLock protects smallParts, bigParts and tombstones.
 	partsLock sync.Mutex
 
 	// Contains all the inmemoryPart plus file-based parts
@@ -111,6 +111,23 @@ type partition struct {
 	// Contains file-based parts with big number of items.
 	bigParts []*partWrapper
 
+	// Contains tombstones for rows deleted from smallParts and bigParts.
+	tombstones []*tombstone
+
+	// tombstonesSaveLock serializes tombstones' saving to disk.
+	tombstonesSaveLock sync.Mutex
+
+	// downsamplingInterval is the downsampling interval in milliseconds applied to all the parts in the partition.
+	//
+	// It is accessed atomically.
+	downsamplingInterval int64
+
+	// downsamplingIntervalSaveLock serializes downsamplingInterval saving to disk.
+	downsamplingIntervalSaveLock sync.Mutex
+
+	// downsamplingLock prevents from concurrent downsampling of the partition.
+	downsamplingLock sync.Mutex
+
 	// rawRowsLock protects rawRows.
 	rawRowsLock sync.Mutex
 
@@ -158,6 +175,9 @@ type partWrapper struct {
 
 	// Whether the part is in merge now.
 	isInMerge bool
+
+	// downsamplingInterval is the downsampling interval in milliseconds applied to the part rows.
+	downsamplingInterval int64
 }
 
 func (pw *partWrapper) incRef() {
@@ -245,6 +265,13 @@ func openPartition(smallPartsPath, bigPartsPath string, getDeletedMetricIDs func
 	pt := newPartition(name, smallPartsPath, bigPartsPath, getDeletedMetricIDs)
 	pt.smallParts = smallParts
 	pt.bigParts = bigParts
+	pws := append([]*partWrapper{}, smallParts...)
+	pws = append(pws, bigParts...)
+	pt.tombstones = mustLoadTombstones(smallPartsPath, pws)
+	pt.downsamplingInterval = mustLoadDownsamplingInterval(smallPartsPath)
+	for _, pw := range pws {
+		pw.downsamplingInterval = pt.downsamplingInterval
+	}
 	if err := pt.tr.fromPartitionName(name); err != nil {
 		return nil, fmt.Errorf("cannot obtain partition time range from smallPartsPath %q: %s", smallPartsPath, err)
 	}
@@ -545,6 +572,100 @@ func (pt *partition) PutParts(pws []*partWrapper) {
 	}
 }
 
+// AddTombstone marks rows for the given metricIDs on the given tr as deleted.
+//
+// The tombstone is applied only to rows added to pt before the call.
+func (pt *partition) AddTombstone(metricIDs map[uint64]struct{}, tr TimeRange) {
+	// Convert pending raw rows into parts, so the tombstone is applied to them.
+	pt.flushRawRows(nil, true)
+
+	t := &tombstone{
+		metricIDs: metricIDs,
+		tr:        tr,
+		pws:       make(map[*partWrapper]struct{}),
+	}
+	pt.partsLock.Lock()
+	for _, pw := range pt.smallParts {
+		t.pws[pw] = struct{}{}
+	}
+	for _, pw := range pt.bigParts {
+		t.pws[pw] = struct{}{}
+	}
+	if len(t.pws) > 0 {
+		pt.tombstones = append(pt.tombstones, t)
+	}
+	pt.partsLock.Unlock()
+
+	if len(t.pws) > 0 {
+		pt.saveTombstones()
+	}
+}
+
+// appendPartTombstones appends tombstones for the given pw to dst and returns the result.
+func (pt *partition) appendPartTombstones(dst []*tombstone, pw *partWrapper) []*tombstone {
+	pt.partsLock.Lock()
+	for _, t := range pt.tombstones {
+		if _, ok := t.pws[pw]; ok {
+			dst = append(dst, t)
+		}
+	}
+	pt.partsLock.Unlock()
+	return dst
+}
+
+// updateTombstonesLocked updates tombstones after the merge of pws into newPW.
+//
+// appliedTombstones must contain tombstones applied during the merge.
+// Tombstones for pws, which have been created after the merge start,
+// are moved to newPW.
+//
+// Returns true if tombstones have been changed.
+//
+// pt.partsLock must be locked when calling this function.
+func (pt *partition) updateTombstonesLocked(pws []*partWrapper, newPW *partWrapper, appliedTombstones map[*tombstone]bool) bool {
+	tombstonesChanged := false
+	dst := pt.tombstones[:0]
+	for _, t := range pt.tombstones {
+		hasParts := false
+		for _, pw := range pws {
+			if _, ok := t.pws[pw]; ok {
+				delete(t.pws, pw)
+				hasParts = true
+			}
+		}
+		if hasParts {
+			tombstonesChanged = true
+			if !appliedTombstones[t] && newPW != nil {
+				t.pws[newPW] = struct{}{}
+			}
+		}
+		if len(t.pws) > 0 {
+			dst = append(dst, t)
+		}
+	}
+	for i := len(dst); i < len(pt.tombstones); i++ {
+		pt.tombstones[i] = nil
+	}
+	pt.tombstones = dst
+	return tombstonesChanged
+}
+
+// saveTombstones stores pt tombstones to disk.
+func (pt *partition) saveTombstones() {
+	pt.tombstonesSaveLock.Lock()
+	defer pt.tombstonesSaveLock.Unlock()
+
+	pt.partsLock.Lock()
+	data := marshalTombstones(nil, pt.tombstones)
+	pt.partsLock.Unlock()
+
+	// Prevent from concurrent snapshot creation, so it contains
+	// tombstones consistent with parts.
+	pt.snapshotLock.RLock()
+	mustSaveTombstones(pt.smallPartsPath, data)
+	pt.snapshotLock.RUnlock()
+}
+
 // MustClose closes the pt, so the app may safely exit.
 //
 // The pt must be detached from table before calling pt.MustClose.
@@ -712,15 +833,50 @@ func (pt *partition) flushInmemoryParts(dstPws []*partWrapper, force bool) ([]*p
 	return dstPws, nil
 }
 
+// mustFlushToDisk flushes all the rows added to pt before the call to disk.
+//
+// Rows added concurrently with the call may remain in memory.
+func (pt *partition) mustFlushToDisk() {
+	rr := getRawRowsMaxSize()
+	rr.rows = pt.flushRawRows(rr.rows[:0], true)
+	putRawRows(rr)
+	flushTime := time.Now()
+
+	var pws []*partWrapper
+	for {
+		var err error
+		pws, err = pt.flushInmemoryParts(pws[:0], true)
+		if err != nil {
+			logger.Panicf("FATAL: cannot flush inmemory parts: %s", err)
+		}
+
+		// Some of inmemory parts may be merged by background mergers at the moment.
+		// Wait until they are written to disk.
+		pending := false
+		pt.partsLock.Lock()
+		for _, pw := range pt.smallParts {
+			if pw.mp != nil && !pw.mp.creationTime.After(flushTime) {
+				pending = true
+				break
+			}
+		}
+		pt.partsLock.Unlock()
+		if !pending {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func (pt *partition) mergePartsOptimal(pws []*partWrapper) error {
 	for len(pws) > defaultPartsToMerge {
-		if err := pt.mergeParts(pws[:defaultPartsToMerge], nil); err != nil {
+		if err := pt.mergeParts(pws[:defaultPartsToMerge], nil, nil); err != nil {
 			return fmt.Errorf("cannot merge %d parts: %s", defaultPartsToMerge, err)
 		}
 		pws = pws[defaultPartsToMerge:]
 	}
 	if len(pws) > 0 {
-		if err := pt.mergeParts(pws, nil); err != nil {
+		if err := pt.mergeParts(pws, nil, nil); err != nil {
 			return fmt.Errorf("cannot merge %d parts: %s", len(pws), err)
 		}
 	}
@@ -876,17 +1032,133 @@ func (pt *partition) mergeBigParts(isFinal bool) error {
 	pt.partsLock.Unlock()
 
 	if len(pws) == 0 {
-		return errNothingToMerge
+		return pt.downsampleParts()
 	}
 
+	ds := newDownsampler(timestampFromTime(time.Now()))
 	atomic.AddUint64(&pt.bigMergesCount, 1)
 	atomic.AddUint64(&pt.activeBigMerges, 1)
-	err := pt.mergeParts(pws, pt.stopCh)
+	err := pt.mergeParts(pws, pt.stopCh, ds)
 	atomic.AddUint64(&pt.activeBigMerges, ^uint64(0))
 
 	return err
 }
 
+// downsampleParts re-writes parts in pt with the downsampling
+// if pt contains rows, which must be downsampled to bigger interval than the already applied interval.
+//
+// Parts, which are merged concurrently, are skipped. They are downsampled on the next call.
+//
+// See SetDownsamplingPeriods for details.
+func (pt *partition) downsampleParts() error {
+	pt.downsamplingLock.Lock()
+	defer pt.downsamplingLock.Unlock()
+
+	ds := newDownsampler(timestampFromTime(time.Now()))
+	interval := ds.getInterval(pt.tr.MaxTimestamp)
+	if interval <= 0 {
+		return errNothingToMerge
+	}
+
+	// Check every part instead of pt.downsamplingInterval, since parts
+	// may be backfilled after the partition has been downsampled.
+
+	pt.partsLock.Lock()
+	var pws []*partWrapper
+	pendingParts := 0
+	for _, pwsSrc := range [][]*partWrapper{pt.smallParts, pt.bigParts} {
+		for _, pw := range pwsSrc {
+			if pw.downsamplingInterval >= interval {
+				// The part is already downsampled.
+				continue
+			}
+			if pw.isInMerge {
+				// The part is merged concurrently. It will be downsampled on the next call.
+				pendingParts++
+				continue
+			}
+			pw.isInMerge = true
+			pws = append(pws, pw)
+		}
+	}
+	pt.partsLock.Unlock()
+
+	if len(pws) == 0 {
+		if pendingParts == 0 {
+			pt.saveDownsamplingInterval()
+		}
+		return errNothingToMerge
+	}
+	logger.Infof("downsampling %d parts in the partition %q to %dms interval", len(pws), pt.name, interval)
+	startTime := time.Now()
+	for len(pws) > 0 {
+		n := defaultPartsToMerge
+		if n > len(pws) {
+			n = len(pws)
+		}
+		atomic.AddUint64(&pt.bigMergesCount, 1)
+		atomic.AddUint64(&pt.activeBigMerges, 1)
+		err := pt.mergeParts(pws[:n], pt.stopCh, ds)
+		atomic.AddUint64(&pt.activeBigMerges, ^uint64(0))
+		pws = pws[n:]
+		if err != nil {
+			pt.releasePartsToMerge(pws)
+			return err
+		}
+	}
+	if pendingParts > 0 {
+		// Do not save the downsampling interval for the partition until the remaining parts are downsampled.
+		logger.Infof("postponed downsampling for %d parts in the partition %q, since they are merged now", pendingParts, pt.name)
+		return nil
+	}
+
+	pt.saveDownsamplingInterval()
+	logger.Infof("downsampled the partition %q to %dms interval in %s", pt.name, interval, time.Since(startTime))
+	return nil
+}
+
+// saveDownsamplingInterval saves the minimum downsampling interval across pt parts to disk.
+//
+// The saved interval is applied to all the parts on the next partition opening,
+// so it mustn't exceed the interval of any part.
+func (pt *partition) saveDownsamplingInterval() {
+	pt.downsamplingIntervalSaveLock.Lock()
+	defer pt.downsamplingIntervalSaveLock.Unlock()
+
+	pt.partsLock.Lock()
+	interval := int64(-1)
+	for _, pwsSrc := range [][]*partWrapper{pt.smallParts, pt.bigParts} {
+		for _, pw := range pwsSrc {
+			if interval < 0 || pw.downsamplingInterval < interval {
+				interval = pw.downsamplingInterval
+			}
+		}
+	}
+	pt.partsLock.Unlock()
+
+	if interval < 0 || interval == atomic.LoadInt64(&pt.downsamplingInterval) {
+		return
+	}
+
+	// Prevent from concurrent snapshot creation, so it contains
+	// the downsampling interval consistent with parts.
+	pt.snapshotLock.RLock()
+	mustSaveDownsamplingInterval(pt.smallPartsPath, interval)
+	pt.snapshotLock.RUnlock()
+	atomic.StoreInt64(&pt.downsamplingInterval, interval)
+}
+
+func (pt *partition) releasePartsToMerge(pws []*partWrapper) {
+	pt.partsLock.Lock()
+	for _, pw := range pws {
+		if !pw.isInMerge {
+			logger.Panicf("BUG: missing isInMerge flag on the part %q", pw.p.path)
+		}
+		pw.isInMerge = false
+	}
+	pt.partsLock.Unlock()
+}
+
 func (pt *partition) mergeSmallParts(isFinal bool) error {
 	maxRows := uint64(maxRowsPerSmallPart * defaultPartsToMerge)
 
@@ -900,7 +1172,7 @@ func (pt *partition) mergeSmallParts(isFinal bool) error {
 
 	atomic.AddUint64(&pt.smallMergesCount, 1)
 	atomic.AddUint64(&pt.activeSmallMerges, 1)
-	err := pt.mergeParts(pws, pt.stopCh)
+	err := pt.mergeParts(pws, pt.stopCh, nil)
 	atomic.AddUint64(&pt.activeSmallMerges, ^uint64(0))
 
 	return err
@@ -908,23 +1180,32 @@ func (pt *partition) mergeSmallParts(isFinal bool) error {
 
 var errNothingToMerge = fmt.Errorf("nothing to merge")
 
-func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}) error {
+// getMergedDownsamplingInterval returns the downsampling interval for the part obtained
+// by merging pws with the given ds.
+func getMergedDownsamplingInterval(pws []*partWrapper, maxTimestamp int64, ds *downsampler) int64 {
+	interval := pws[0].downsamplingInterval
+	for _, pw := range pws[1:] {
+		if pw.downsamplingInterval < interval {
+			interval = pw.downsamplingInterval
+		}
+	}
+	if n := ds.getInterval(maxTimestamp); n > interval {
+		interval = n
+	}
+	return interval
+}
+
+// mergeParts merges pws into a single part.
+//
+// Rows are downsampled with ds if it isn't nil.
+func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}, ds *downsampler) error {
 	if len(pws) == 0 {
 		// Nothing to merge.
 		return errNothingToMerge
 	}
 
-	defer func() {
-		// Remove isInMerge flag from pws.
-		pt.partsLock.Lock()
-		for _, pw := range pws {
-			if !pw.isInMerge {
-				logger.Panicf("BUG: missing isInMerge flag on the part %q", pw.p.path)
-			}
-			pw.isInMerge = false
-		}
-		pt.partsLock.Unlock()
-	}()
+	// Remove isInMerge flag from pws.
+	defer pt.releasePartsToMerge(pws)
 
 	startTime := time.Now()
 
@@ -947,6 +1228,16 @@ func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}) erro
 		bsrs = append(bsrs, bsr)
 	}
 
+	// Obtain tombstones, which must be applied to the source parts.
+	appliedTombstones := make(map[*tombstone]bool)
+	for i, pw := range pws {
+		bsr := bsrs[i]
+		bsr.tombstones = pt.appendPartTombstones(bsr.tombstones[:0], pw)
+		for _, t := range bsr.tombstones {
+			appliedTombstones[t] = true
+		}
+	}
+
 	outRowsCount := uint64(0)
 	for _, pw := range pws {
 		outRowsCount += pw.p.ph.RowsCount
@@ -977,7 +1268,7 @@ func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}) erro
 		rowsDeleted = &pt.bigRowsDeleted
 	}
 	dmis := pt.getDeletedMetricIDs()
-	err := mergeBlockStreams(&ph, bsw, bsrs, stopCh, rowsMerged, dmis, rowsDeleted)
+	err := mergeBlockStreams(&ph, bsw, bsrs, stopCh, rowsMerged, dmis, rowsDeleted, ds)
 	putBlockStreamWriter(bsw)
 	if err != nil {
 		if err == errForciblyStopped {
@@ -1003,7 +1294,7 @@ func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}) erro
 	dstPartPath := ""
 	if ph.RowsCount > 0 {
 		// The destination part may have no rows if they are deleted
-		// during the merge due to dmis.
+		// during the merge due to dmis or tombstones.
 		dstPartPath = ph.Path(ptPath, mergeIdx)
 	}
 	fmt.Fprintf(&bb, "%s -> %s\n", tmpPartPath, dstPartPath)
@@ -1027,8 +1318,9 @@ func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}) erro
 		}
 		newPSize = newP.size
 		newPW = &partWrapper{
-			p:        newP,
-			refCount: 1,
+			p:                    newP,
+			refCount:             1,
+			downsamplingInterval: getMergedDownsamplingInterval(pws, pt.tr.MaxTimestamp, ds),
 		}
 	}
 
@@ -1052,10 +1344,19 @@ func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}) erro
 			pt.smallParts = append(pt.smallParts, newPW)
 		}
 	}
+	tombstonesChanged := pt.updateTombstonesLocked(pws, newPW, appliedTombstones)
 	pt.partsLock.Unlock()
 	if removedSmallParts+removedBigParts != len(m) {
 		logger.Panicf("BUG: unexpected number of parts removed; got %d, want %d", removedSmallParts+removedBigParts, len(m))
 	}
+	if tombstonesChanged {
+		pt.saveTombstones()
+	}
+	if newPW != nil && newPW.downsamplingInterval < atomic.LoadInt64(&pt.downsamplingInterval) {
+		// The merged part contains rows backfilled after the partition downsampling.
+		// Lower the saved interval, so the part isn't treated as downsampled after restart.
+		pt.saveDownsamplingInterval()
+	}
 
 	// Remove partition references from old parts.
 	for _, pw := range pws {
@@ -1323,11 +1624,20 @@ func (pt *partition) createSnapshot(srcDir, dstDir string) error {
 		return fmt.Errorf("cannot read directory: %s", err)
 	}
 	for _, fi := range fis {
+		fn := fi.Name()
+		if fn == tombstonesFilename || fn == downsamplingFilename {
+			// These files are atomically replaced on update, so it is safe to hard link them.
+			srcPath := srcDir + "/" + fn
+			dstPath := dstDir + "/" + fn
+			if err := os.Link(srcPath, dstPath); err != nil {
+				return fmt.Errorf("cannot create hard link from %q to %q: %s", srcPath, dstPath, err)
+			}
+			continue
+		}
 		if !fs.IsDirOrSymlink(fi) {
 			// Skip non-directories.
 			continue
 		}
-		fn := fi.Name()
 		if fn == "tmp" || fn == "txn" {
 			// Skip special dirs.
 			continue
@@ -1345,6 +1655,20 @@ func (pt *partition) createSnapshot(srcDir, dstDir string) error {
 	return nil
 }
 
+// mustWritePartitionFile atomically writes data to the file with the given filename at the given partition path.
+func mustWritePartitionFile(path, filename string, data []byte) {
+	startTime := time.Now()
+	tmpPath := fmt.Sprintf("%s/tmp/%s_%016X", path, filename, uint64(startTime.UnixNano()))
+	if err := fs.WriteFile(tmpPath, data); err != nil {
+		logger.Panicf("FATAL: cannot write %q: %s", tmpPath, err)
+	}
+	dstPath := path + "/" + filename
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		logger.Panicf("FATAL: cannot rename %q to %q: %s", tmpPath, dstPath, err)
+	}
+	fs.MustSyncPath(path)
+}
+
 func runTransactions(txnLock *sync.RWMutex, pathPrefix1, pathPrefix2, path string) error {
 	txnDir := path + "/txn"
 	d, err := os.Open(txnDir)
@@ -85,7 +85,9 @@ func (pts *partitionSearch) Init(pt *partition, tsids []TSID, tr TimeRange) {
 	}
 	pts.psPool = pts.psPool[:len(pts.pws)]
 	for i, pw := range pts.pws {
-		pts.psPool[i].Init(pw.p, tsids, tr)
+		ps := &pts.psPool[i]
+		ps.Init(pw.p, tsids, tr)
+		ps.tombstones = pt.appendPartTombstones(ps.tombstones, pw)
 	}
 
 	// Initialize the psHeap.
@@ -52,7 +52,8 @@ func (mb *MetricBlock) Unmarshal(src []byte) ([]byte, error) {
 
 // UnmarshalBlock unmarshal Block from src to dst.
 //
-// dst.UnmarshalData isn't called on the block.
+// The block header is verified against the marshaled data, so the block
+// may be obtained from untrusted source. dst.UnmarshalData isn't called on the block.
 func UnmarshalBlock(dst *Block, src []byte) ([]byte, error) {
 	tail, err := dst.bh.Unmarshal(src)
 	if err != nil {
@@ -64,6 +65,9 @@ func UnmarshalBlock(dst *Block, src []byte) ([]byte, error) {
 	if err != nil {
 		return tail, fmt.Errorf("cannot unmarshal timestampsData: %s", err)
 	}
+	if uint64(len(tds)) != uint64(dst.bh.TimestampsBlockSize) {
+		return tail, fmt.Errorf("unexpected timestampsData size; got %d bytes; want %d bytes from block header", len(tds), dst.bh.TimestampsBlockSize)
+	}
 	dst.timestampsData = append(dst.timestampsData[:0], tds...)
 	src = tail
 
@@ -71,6 +75,9 @@ func UnmarshalBlock(dst *Block, src []byte) ([]byte, error) {
 	if err != nil {
 		return tail, fmt.Errorf("cannot unmarshal valuesData: %s", err)
 	}
+	if uint64(len(vd)) != uint64(dst.bh.ValuesBlockSize) {
+		return tail, fmt.Errorf("unexpected valuesData size; got %d bytes; want %d bytes from block header", len(vd), dst.bh.ValuesBlockSize)
+	}
 	dst.valuesData = append(dst.valuesData[:0], vd...)
 	src = tail
 
@@ -158,7 +165,7 @@ func (s *Search) NextMetricBlock() bool {
 	for s.ts.NextBlock() {
 		tsid := &s.ts.Block.bh.TSID
 		var err error
-		s.MetricBlock.MetricName, err = s.storage.searchMetricName(s.MetricBlock.MetricName[:0], tsid.MetricID)
+		s.MetricBlock.MetricName, err = s.storage.searchMetricName(s.MetricBlock.MetricName[:0], tsid.MetricID, tsid.AccountID, tsid.ProjectID)
 		if err != nil {
 			if err == io.EOF {
 				// Missing metricName for tsid.MetricID. Increment error counter and skip it.
@@ -182,6 +189,8 @@ func (s *Search) NextMetricBlock() bool {
 
 // SearchQuery is used for sending search queries from vmselect to vmstorage.
 type SearchQuery struct {
+	AccountID    uint32
+	ProjectID    uint32
 	MinTimestamp int64
 	MaxTimestamp int64
 	TagFilterss  [][]TagFilter
@@ -263,8 +272,8 @@ func (tf *TagFilter) Unmarshal(src []byte) ([]byte, error) {
 // String returns string representation of the search query.
 func (sq *SearchQuery) String() string {
 	var bb bytesutil.ByteBuffer
-	fmt.Fprintf(&bb, "MinTimestamp=%s, MaxTimestamp=%s, TagFilters=[\n",
-		timestampToTime(sq.MinTimestamp), timestampToTime(sq.MaxTimestamp))
+	fmt.Fprintf(&bb, "AccountID=%d, ProjectID=%d, MinTimestamp=%s, MaxTimestamp=%s, TagFilters=[\n",
+		sq.AccountID, sq.ProjectID, timestampToTime(sq.MinTimestamp), timestampToTime(sq.MaxTimestamp))
 	for _, tagFilters := range sq.TagFilterss {
 		for _, tf := range tagFilters {
 			fmt.Fprintf(&bb, "%s", tf.String())
@@ -277,6 +286,8 @@ func (sq *SearchQuery) String() string {
 
 // Marshal appends marshaled sq to dst and returns the result.
 func (sq *SearchQuery) Marshal(dst []byte) []byte {
+	dst = encoding.MarshalUint32(dst, sq.AccountID)
+	dst = encoding.MarshalUint32(dst, sq.ProjectID)
 	dst = encoding.MarshalVarInt64(dst, sq.MinTimestamp)
 	dst = encoding.MarshalVarInt64(dst, sq.MaxTimestamp)
 	dst = encoding.MarshalVarUint64(dst, uint64(len(sq.TagFilterss)))
@@ -291,6 +302,18 @@ func (sq *SearchQuery) Marshal(dst []byte) []byte {
 
 // Unmarshal unmarshals sq from src and returns the tail.
 func (sq *SearchQuery) Unmarshal(src []byte) ([]byte, error) {
+	if len(src) < 4 {
+		return src, fmt.Errorf("cannot unmarshal AccountID: too short src len: %d; must be at least %d bytes", len(src), 4)
+	}
+	sq.AccountID = encoding.UnmarshalUint32(src)
+	src = src[4:]
+
+	if len(src) < 4 {
+		return src, fmt.Errorf("cannot unmarshal ProjectID: too short src len: %d; must be at least %d bytes", len(src), 4)
+	}
+	sq.ProjectID = encoding.UnmarshalUint32(src)
+	src = src[4:]
+
 	tail, minTs, err := encoding.UnmarshalVarInt64(src)
 	if err != nil {
 		return src, fmt.Errorf("cannot unmarshal MinTimestamp: %s", err)
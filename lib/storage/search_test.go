@@ -99,6 +99,7 @@ func TestSearch(t *testing.T) {
 	startTimestamp -= startTimestamp % (1e3 * 3600 * 24)
 	blockRowsCount := 0
 	for i := 0; i < rowsCount; i++ {
+		mn.AccountID = uint32(i % accountsCount)
 		mn.MetricGroup = []byte(fmt.Sprintf("metric_%d", i%metricGroupsCount))
 
 		mr := &mrs[i]
@@ -162,7 +163,7 @@ func testSearch(st *Storage, tr TimeRange, mrs []MetricRow, accountsCount int) e
 	var s Search
 	for i := 0; i < 10; i++ {
 		// Prepare TagFilters for search.
-		tfs := NewTagFilters()
+		tfs := NewTagFilters(uint32(i%accountsCount), 0)
 		metricGroupRe := fmt.Sprintf(`metric_\d*%d%d`, i, i)
 		if err := tfs.Add(nil, []byte(metricGroupRe), false, true); err != nil {
 			return fmt.Errorf("cannot add metricGroupRe=%q: %s", metricGroupRe, err)
@@ -186,6 +187,9 @@ func testSearch(st *Storage, tr TimeRange, mrs []MetricRow, accountsCount int) e
 			if err := mn.unmarshalRaw(mr.MetricNameRaw); err != nil {
 				return fmt.Errorf("cannot unmarshal MetricName: %s", err)
 			}
+			if mn.AccountID != tfs.accountID || mn.ProjectID != tfs.projectID {
+				continue
+			}
 			if !metricGroupRegexp.Match(mn.MetricGroup) {
 				continue
 			}
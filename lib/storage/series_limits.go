@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bloomfilter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// SetMaxSeriesLimits sets limits on the number of unique series, which may be added
+// to the storage during the last hour and the last day.
+//
+// Samples for new series over the limits are dropped. Zero limit disables the corresponding check.
+//
+// This function must be called before opening the storage.
+func SetMaxSeriesLimits(maxHourly, maxDaily int) {
+	maxHourlySeries = maxHourly
+	maxDailySeries = maxDaily
+}
+
+var (
+	maxHourlySeries int
+	maxDailySeries  int
+)
+
+func (s *Storage) startSeriesLimiters() {
+	if maxHourlySeries > 0 {
+		s.hourlySeriesLimiter = bloomfilter.NewLimiter(maxHourlySeries, time.Hour)
+	}
+	if maxDailySeries > 0 {
+		s.dailySeriesLimiter = bloomfilter.NewLimiter(maxDailySeries, 24*time.Hour)
+	}
+}
+
+func (s *Storage) stopSeriesLimiters() {
+	if s.hourlySeriesLimiter != nil {
+		s.hourlySeriesLimiter.MustStop()
+	}
+	if s.dailySeriesLimiter != nil {
+		s.dailySeriesLimiter.MustStop()
+	}
+}
+
+// registerSeriesCardinality registers the series with the given metricID in series limiters.
+//
+// It returns false if the series exceeds either -storage.maxHourlySeries or -storage.maxDailySeries limit,
+// so its samples must be dropped. The series is registered in limiters only if it fits all the limits.
+//
+// metricID is used instead of the hash of metricNameRaw, so the check is cheap for series with TSID in the cache.
+// New series over the limit are registered in the indexdb though.
+func (s *Storage) registerSeriesCardinality(metricID uint64, metricNameRaw []byte) bool {
+	if s.hourlySeriesLimiter != nil && !s.hourlySeriesLimiter.Fits(metricID) {
+		atomic.AddUint64(&s.hourlySeriesLimitRowsDropped, 1)
+		s.logSkippedSeries(metricNameRaw, "-storage.maxHourlySeries", s.hourlySeriesLimiter.MaxItems())
+		return false
+	}
+	if s.dailySeriesLimiter != nil && !s.dailySeriesLimiter.Fits(metricID) {
+		atomic.AddUint64(&s.dailySeriesLimitRowsDropped, 1)
+		s.logSkippedSeries(metricNameRaw, "-storage.maxDailySeries", s.dailySeriesLimiter.MaxItems())
+		return false
+	}
+	if s.hourlySeriesLimiter != nil {
+		s.hourlySeriesLimiter.Add(metricID)
+	}
+	if s.dailySeriesLimiter != nil {
+		s.dailySeriesLimiter.Add(metricID)
+	}
+	return true
+}
+
+// logSkippedSeries logs the series with the given metricNameRaw dropped because of flagName limit.
+//
+// The log message is emitted at most once per 5 seconds in order to avoid log flood.
+func (s *Storage) logSkippedSeries(metricNameRaw []byte, flagName string, maxSeries int) {
+	if !s.seriesLimitLogThrottler.Allow() {
+		return
+	}
+	mn := GetMetricName()
+	defer PutMetricName(mn)
+	name := "<invalid metric name>"
+	if err := mn.unmarshalRaw(metricNameRaw); err == nil {
+		name = mn.String()
+	}
+	logger.Errorf("dropping samples for the new series %s because %s=%d limit is exceeded", name, flagName, maxSeries)
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"os"
@@ -14,7 +15,9 @@ import (
 	"time"
 	"unsafe"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bloomfilter"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
@@ -28,10 +31,20 @@ const maxRetentionMonths = 12 * 100
 
 // Storage represents TSDB storage.
 type Storage struct {
+	// Atomic counters must go at the top of the structure in order to properly align by 8 bytes on 32-bit archs.
+	hourlySeriesLimitRowsDropped uint64
+	dailySeriesLimitRowsDropped  uint64
+	tooSmallTimestampRows        uint64
+	tooBigTimestampRows          uint64
+
 	path            string
 	cachePath       string
 	retentionMonths int
 
+	// Throttlers for logging dropped rows.
+	outOfRangeLogThrottler  *logger.Throttler
+	seriesLimitLogThrottler *logger.Throttler
+
 	// lock file for exclusive access to the storage on the given path.
 	flockF *os.File
 
@@ -59,12 +72,28 @@ type Storage struct {
 
 	// Pending MetricID values to be added to currHourMetricIDs.
 	pendingHourMetricIDsLock sync.Mutex
-	pendingHourMetricIDs     map[uint64]struct{}
+	pendingHourMetricIDs     map[pendingHourMetricIDEntry]struct{}
 
 	stop chan struct{}
 
 	currHourMetricIDsUpdaterWG sync.WaitGroup
 	retentionWatcherWG         sync.WaitGroup
+
+	// Limiters for the number of unique series added during the last hour and the last day.
+	// They are nil if the corresponding limits are disabled.
+	hourlySeriesLimiter *bloomfilter.Limiter
+	dailySeriesLimiter  *bloomfilter.Limiter
+
+	// wal is write-ahead log for the added rows. It is nil if the WAL is disabled.
+	//
+	// walLock is held in read mode while the rows are written to wal and added to tb,
+	// so wal segments may be safely rotated under write lock.
+	wal               *wal
+	walLock           sync.RWMutex
+	walCheckpointerWG sync.WaitGroup
+
+	// walCheckpointLock serializes walCheckpoint calls.
+	walCheckpointLock sync.Mutex
 }
 
 // OpenStorage opens storage on the given path with the given number of retention months.
@@ -85,6 +114,9 @@ func OpenStorage(path string, retentionMonths int) (*Storage, error) {
 		cachePath:       path + "/cache",
 		retentionMonths: retentionMonths,
 
+		outOfRangeLogThrottler:  logger.NewThrottler(5 * time.Second),
+		seriesLimitLogThrottler: logger.NewThrottler(5 * time.Second),
+
 		stop: make(chan struct{}),
 	}
 
@@ -106,6 +138,11 @@ func OpenStorage(path string, retentionMonths int) (*Storage, error) {
 	}
 	s.flockF = flockF
 
+	if err := checkFormatVersion(path); err != nil {
+		fs.MustClose(flockF)
+		return nil, err
+	}
+
 	// Load caches.
 	mem := memory.Allowed()
 	s.tsidCache = s.mustLoadCache("MetricName->TSID", "metricName_tsid", mem/3)
@@ -118,7 +155,7 @@ func OpenStorage(path string, retentionMonths int) (*Storage, error) {
 	hmPrev := s.mustLoadHourMetricIDs(hour-1, "prev_hour_metric_ids")
 	s.currHourMetricIDs.Store(hmCurr)
 	s.prevHourMetricIDs.Store(hmPrev)
-	s.pendingHourMetricIDs = make(map[uint64]struct{})
+	s.pendingHourMetricIDs = make(map[pendingHourMetricIDEntry]struct{})
 
 	// Load indexdb
 	idbPath := path + "/indexdb"
@@ -144,10 +181,48 @@ func OpenStorage(path string, retentionMonths int) (*Storage, error) {
 
 	s.startCurrHourMetricIDsUpdater()
 	s.startRetentionWatcher()
+	s.startSeriesLimiters()
+	if walEnabled {
+		s.mustStartWAL()
+	}
 
 	return s, nil
 }
 
+// formatVersionFilename is the name of the file with the on-disk data format version.
+const formatVersionFilename = "format_version"
+
+// formatVersion is the current on-disk data format version.
+//
+// It must be increased on incompatible changes in the on-disk format of TSID, MetricName or indexdb items.
+// Version 1 adds accountID and projectID to TSID, MetricName and indexdb items.
+const formatVersion = "1"
+
+// checkFormatVersion verifies whether the data at the given storage path has the supported format version.
+//
+// The format version is written to the storage path if it doesn't contain data yet.
+func checkFormatVersion(path string) error {
+	versionPath := path + "/" + formatVersionFilename
+	if !fs.IsPathExist(versionPath) {
+		if fs.IsPathExist(path+"/data") || fs.IsPathExist(path+"/indexdb") {
+			return fmt.Errorf("cannot open storage at %q: it contains data written by older VictoriaMetrics version without multi-tenancy support; "+
+				"the data must be exported via /api/v1/export from the older version and then imported via /api/v1/import into an empty storage", path)
+		}
+		if err := fs.WriteFile(versionPath, []byte(formatVersion)); err != nil {
+			return fmt.Errorf("cannot write data format version: %s", err)
+		}
+		return nil
+	}
+	data, err := ioutil.ReadFile(versionPath)
+	if err != nil {
+		return fmt.Errorf("cannot read data format version: %s", err)
+	}
+	if string(data) != formatVersion {
+		return fmt.Errorf("cannot open storage at %q: unsupported data format version %q in %q; want %q", path, data, versionPath, formatVersion)
+	}
+	return nil
+}
+
 // debugFlush flushes recently added storage data, so it becomes visible to search.
 func (s *Storage) debugFlush() {
 	s.tb.flushRawRows()
@@ -206,6 +281,12 @@ func (s *Storage) CreateSnapshot() (string, error) {
 		return "", fmt.Errorf("cannot create symlink from %q to %q: %s", idbSnapshot, dstIdbDir, err)
 	}
 
+	// Store the data format version in the snapshot, so the storage could be restored from it.
+	dstVersionPath := dstDir + "/" + formatVersionFilename
+	if err := fs.WriteFile(dstVersionPath, []byte(formatVersion)); err != nil {
+		return "", fmt.Errorf("cannot write data format version to snapshot: %s", err)
+	}
+
 	fs.MustSyncPath(dstDir)
 	fs.MustSyncPath(srcDir + "/snapshots")
 
@@ -297,6 +378,23 @@ type Metrics struct {
 
 	HourMetricIDCacheSize uint64
 
+	DedupsDuringMerge uint64
+	DownsampledRows   uint64
+
+	HourlySeriesLimitRowsDropped   uint64
+	HourlySeriesLimitMaxSeries     uint64
+	HourlySeriesLimitCurrentSeries uint64
+
+	DailySeriesLimitRowsDropped   uint64
+	DailySeriesLimitMaxSeries     uint64
+	DailySeriesLimitCurrentSeries uint64
+
+	TooSmallTimestampRows uint64
+	TooBigTimestampRows   uint64
+
+	WALSegmentsCount uint64
+	WALSizeBytes     uint64
+
 	IndexDBMetrics IndexDBMetrics
 	TableMetrics   TableMetrics
 }
@@ -348,6 +446,28 @@ func (s *Storage) UpdateMetrics(m *Metrics) {
 	}
 	m.HourMetricIDCacheSize += uint64(hourMetricIDsLen)
 
+	m.DedupsDuringMerge = atomic.LoadUint64(&dedupsDuringMerge)
+	m.DownsampledRows = atomic.LoadUint64(&downsampledRows)
+
+	m.HourlySeriesLimitRowsDropped += atomic.LoadUint64(&s.hourlySeriesLimitRowsDropped)
+	if sl := s.hourlySeriesLimiter; sl != nil {
+		m.HourlySeriesLimitMaxSeries += uint64(sl.MaxItems())
+		m.HourlySeriesLimitCurrentSeries += uint64(sl.CurrentItems())
+	}
+	m.DailySeriesLimitRowsDropped += atomic.LoadUint64(&s.dailySeriesLimitRowsDropped)
+	if sl := s.dailySeriesLimiter; sl != nil {
+		m.DailySeriesLimitMaxSeries += uint64(sl.MaxItems())
+		m.DailySeriesLimitCurrentSeries += uint64(sl.CurrentItems())
+	}
+
+	m.TooSmallTimestampRows += atomic.LoadUint64(&s.tooSmallTimestampRows)
+	m.TooBigTimestampRows += atomic.LoadUint64(&s.tooBigTimestampRows)
+
+	if w := s.wal; w != nil {
+		m.WALSegmentsCount += atomic.LoadUint64(&w.segmentsCount)
+		m.WALSizeBytes += atomic.LoadUint64(&w.sizeBytes)
+	}
+
 	s.idb().UpdateMetrics(&m.IndexDBMetrics)
 	s.tb.UpdateMetrics(&m.TableMetrics)
 }
@@ -434,6 +554,11 @@ func (s *Storage) MustClose() {
 
 	s.retentionWatcherWG.Wait()
 	s.currHourMetricIDsUpdaterWG.Wait()
+	s.stopSeriesLimiters()
+	s.walCheckpointerWG.Wait()
+	if s.wal != nil {
+		s.mustStopWAL()
+	}
 
 	s.tb.MustClose()
 	s.idb().MustClose()
@@ -472,6 +597,8 @@ func (s *Storage) mustLoadHourMetricIDs(hour uint64, name string) *hourMetricIDs
 		logger.Errorf("discarding %s, since it has broken header; got %d bytes; want %d bytes", path, len(src), 24)
 		return &hourMetricIDs{}
 	}
+
+	// Unmarshal header
 	isFull := encoding.UnmarshalUint64(src)
 	src = src[8:]
 	hourLoaded := encoding.UnmarshalUint64(src)
@@ -480,10 +607,12 @@ func (s *Storage) mustLoadHourMetricIDs(hour uint64, name string) *hourMetricIDs
 		logger.Infof("discarding %s, since it is outdated", name)
 		return &hourMetricIDs{}
 	}
+
+	// Unmarshal hm.m
 	hmLen := encoding.UnmarshalUint64(src)
 	src = src[8:]
-	if uint64(len(src)) != 8*hmLen {
-		logger.Errorf("discarding %s, since it has broken body; got %d bytes; want %d bytes", path, len(src), 8*hmLen)
+	if uint64(len(src)) < 8*hmLen {
+		logger.Errorf("discarding %s, since it has broken hm.m data; got %d bytes; want at least %d bytes", path, len(src), 8*hmLen)
 		return &hourMetricIDs{}
 	}
 	m := make(map[uint64]struct{}, hmLen)
@@ -492,11 +621,53 @@ func (s *Storage) mustLoadHourMetricIDs(hour uint64, name string) *hourMetricIDs
 		src = src[8:]
 		m[metricID] = struct{}{}
 	}
+
+	// Unmarshal hm.byTenant
+	if len(src) < 8 {
+		logger.Errorf("discarding %s, since it has broken hm.byTenant header; got %d bytes; want %d bytes", path, len(src), 8)
+		return &hourMetricIDs{}
+	}
+	byTenantLen := encoding.UnmarshalUint64(src)
+	src = src[8:]
+	byTenant := make(map[accountProjectKey]map[uint64]struct{}, byTenantLen)
+	for i := uint64(0); i < byTenantLen; i++ {
+		if len(src) < 16 {
+			logger.Errorf("discarding %s, since it has broken accountID:projectID prefix; got %d bytes; want %d bytes", path, len(src), 16)
+			return &hourMetricIDs{}
+		}
+		accountID := encoding.UnmarshalUint32(src)
+		src = src[4:]
+		projectID := encoding.UnmarshalUint32(src)
+		src = src[4:]
+		metricIDsLen := encoding.UnmarshalUint64(src)
+		src = src[8:]
+		if uint64(len(src)) < 8*metricIDsLen {
+			logger.Errorf("discarding %s, since it has broken accountID:projectID entry; got %d bytes; want at least %d bytes", path, len(src), 8*metricIDsLen)
+			return &hourMetricIDs{}
+		}
+		metricIDs := make(map[uint64]struct{}, metricIDsLen)
+		for j := uint64(0); j < metricIDsLen; j++ {
+			metricID := encoding.UnmarshalUint64(src)
+			src = src[8:]
+			metricIDs[metricID] = struct{}{}
+		}
+		k := accountProjectKey{
+			AccountID: accountID,
+			ProjectID: projectID,
+		}
+		byTenant[k] = metricIDs
+	}
+	if len(src) > 0 {
+		logger.Errorf("discarding %s, since it contains %d unexpected trailing bytes", path, len(src))
+		return &hourMetricIDs{}
+	}
+
 	logger.Infof("loaded %s from %q in %s; entriesCount: %d; bytesSize: %d", name, path, time.Since(startTime), hmLen, srcOrigLen)
 	return &hourMetricIDs{
-		m:      m,
-		hour:   hourLoaded,
-		isFull: isFull != 0,
+		m:        m,
+		byTenant: byTenant,
+		hour:     hourLoaded,
+		isFull:   isFull != 0,
 	}
 }
 
@@ -511,10 +682,23 @@ func (s *Storage) mustSaveHourMetricIDs(hm *hourMetricIDs, name string) {
 	}
 	dst = encoding.MarshalUint64(dst, isFull)
 	dst = encoding.MarshalUint64(dst, hm.hour)
+
+	// Marshal hm.m
 	dst = encoding.MarshalUint64(dst, uint64(len(hm.m)))
 	for metricID := range hm.m {
 		dst = encoding.MarshalUint64(dst, metricID)
 	}
+
+	// Marshal hm.byTenant
+	dst = encoding.MarshalUint64(dst, uint64(len(hm.byTenant)))
+	for k, metricIDs := range hm.byTenant {
+		dst = encoding.MarshalUint32(dst, k.AccountID)
+		dst = encoding.MarshalUint32(dst, k.ProjectID)
+		dst = encoding.MarshalUint64(dst, uint64(len(metricIDs)))
+		for metricID := range metricIDs {
+			dst = encoding.MarshalUint64(dst, metricID)
+		}
+	}
 	if err := ioutil.WriteFile(path, dst, 0644); err != nil {
 		logger.Panicf("FATAL: cannot write %d bytes to %q: %s", len(dst), path, err)
 	}
@@ -569,6 +753,37 @@ func (s *Storage) searchTSIDs(tfss []*TagFilters, tr TimeRange, maxMetrics int)
 	return tsids, nil
 }
 
+// SearchMetricNames returns metric names matching the given tfss on the given tr.
+//
+// Only the index is searched, so this is much faster than Search
+// when only metric names are needed.
+func (s *Storage) SearchMetricNames(tfss []*TagFilters, tr TimeRange, maxMetrics int) ([]MetricName, error) {
+	tsids, err := s.searchTSIDs(tfss, tr, maxMetrics)
+	if err != nil {
+		return nil, err
+	}
+	mns := make([]MetricName, 0, len(tsids))
+	var metricName []byte
+	for i := range tsids {
+		tsid := &tsids[i]
+		var err error
+		metricName, err = s.searchMetricName(metricName[:0], tsid.MetricID, tsid.AccountID, tsid.ProjectID)
+		if err != nil {
+			if err == io.EOF {
+				// Skip missing metricName for tsid.MetricID like Search does.
+				continue
+			}
+			return nil, fmt.Errorf("error when searching metricName for metricID=%d: %s", tsid.MetricID, err)
+		}
+		mns = mns[:len(mns)+1]
+		mn := &mns[len(mns)-1]
+		if err := mn.Unmarshal(metricName); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal metricName=%q: %s", metricName, err)
+		}
+	}
+	return mns, nil
+}
+
 // DeleteMetrics deletes all the metrics matching the given tfss.
 //
 // Returns the number of metrics deleted.
@@ -586,26 +801,62 @@ func (s *Storage) DeleteMetrics(tfss []*TagFilters) (int, error) {
 	return deletedCount, nil
 }
 
-// searchMetricName appends metric name for the given metricID to dst
+// DeleteSamples deletes samples on the given tr for the metrics matching the given tfss.
+//
+// Samples added after the call aren't deleted even if they belong to tr.
+//
+// Returns the number of metrics with deleted samples.
+func (s *Storage) DeleteSamples(tfss []*TagFilters, tr TimeRange) (int, error) {
+	tsids, err := s.searchTSIDs(tfss, tr, 1e9)
+	if err != nil {
+		return 0, fmt.Errorf("cannot search tsids: %s", err)
+	}
+	if len(tsids) == 0 {
+		// Nothing to delete.
+		return 0, nil
+	}
+	metricIDs := make(map[uint64]struct{}, len(tsids))
+	for i := range tsids {
+		metricIDs[tsids[i].MetricID] = struct{}{}
+	}
+	if s.wal != nil {
+		// Tombstones for inmemory parts aren't persisted, while the rows from these parts
+		// are replayed from WAL after unclean shutdown. So flush the rows to disk
+		// and drop them from WAL before adding tombstones. Otherwise the deleted rows
+		// may re-appear after unclean shutdown.
+		s.walCheckpoint()
+	}
+	s.tb.DeleteRows(metricIDs, tr)
+	return len(metricIDs), nil
+}
+
+// searchMetricName appends metric name for the given metricID, accountID and projectID to dst
 // and returns the result.
-func (s *Storage) searchMetricName(dst []byte, metricID uint64) ([]byte, error) {
-	return s.idb().searchMetricName(dst, metricID)
+func (s *Storage) searchMetricName(dst []byte, metricID uint64, accountID, projectID uint32) ([]byte, error) {
+	return s.idb().searchMetricName(dst, metricID, accountID, projectID)
 }
 
-// SearchTagKeys searches for tag keys
-func (s *Storage) SearchTagKeys(maxTagKeys int) ([]string, error) {
-	return s.idb().SearchTagKeys(maxTagKeys)
+// SearchTagKeys searches for tag keys for the given (accountID, projectID).
+func (s *Storage) SearchTagKeys(accountID, projectID uint32, maxTagKeys int) ([]string, error) {
+	return s.idb().SearchTagKeys(accountID, projectID, maxTagKeys)
 }
 
-// SearchTagValues searches for tag values for the given tagKey
-func (s *Storage) SearchTagValues(tagKey []byte, maxTagValues int) ([]string, error) {
-	return s.idb().SearchTagValues(tagKey, maxTagValues)
+// SearchTagValues searches for tag values for the given tagKey in (accountID, projectID).
+func (s *Storage) SearchTagValues(accountID, projectID uint32, tagKey []byte, maxTagValues int) ([]string, error) {
+	return s.idb().SearchTagValues(accountID, projectID, tagKey, maxTagValues)
+}
+
+// SearchTagValueSuffixes returns tag value suffixes for the given tagKey and tagValuePrefix in (accountID, projectID).
+//
+// Suffixes are cut after the first delimiter. See indexDB.SearchTagValueSuffixes for details.
+func (s *Storage) SearchTagValueSuffixes(accountID, projectID uint32, tagKey, tagValuePrefix []byte, delimiter byte, maxTagValueSuffixes int) ([]string, error) {
+	return s.idb().SearchTagValueSuffixes(accountID, projectID, tagKey, tagValuePrefix, delimiter, maxTagValueSuffixes)
 }
 
 // SearchTagEntries returns a list of (tagName -> tagValues) for (accountID, projectID).
-func (s *Storage) SearchTagEntries(maxTagKeys, maxTagValues int) ([]TagEntry, error) {
+func (s *Storage) SearchTagEntries(accountID, projectID uint32, maxTagKeys, maxTagValues int) ([]TagEntry, error) {
 	idb := s.idb()
-	keys, err := idb.SearchTagKeys(maxTagKeys)
+	keys, err := idb.SearchTagKeys(accountID, projectID, maxTagKeys)
 	if err != nil {
 		return nil, fmt.Errorf("cannot search tag keys: %s", err)
 	}
@@ -615,7 +866,7 @@ func (s *Storage) SearchTagEntries(maxTagKeys, maxTagValues int) ([]TagEntry, er
 
 	tes := make([]TagEntry, len(keys))
 	for i, key := range keys {
-		values, err := idb.SearchTagValues([]byte(key), maxTagValues)
+		values, err := idb.SearchTagValues(accountID, projectID, []byte(key), maxTagValues)
 		if err != nil {
 			return nil, fmt.Errorf("cannot search values for tag %q: %s", key, err)
 		}
@@ -635,12 +886,20 @@ type TagEntry struct {
 	Values []string
 }
 
-// GetSeriesCount returns the approximate number of unique time series.
+// GetSeriesCount returns the approximate number of unique time series for the given (accountID, projectID).
 //
 // It includes the deleted series too and may count the same series
 // up to two times - in db and extDB.
-func (s *Storage) GetSeriesCount() (uint64, error) {
-	return s.idb().GetSeriesCount()
+func (s *Storage) GetSeriesCount(accountID, projectID uint32) (uint64, error) {
+	return s.idb().GetSeriesCount(accountID, projectID)
+}
+
+// GetTSDBStatusForDate returns TSDB status data for /api/v1/status/tsdb for the given (accountID, projectID).
+//
+// date is the number of days since the Unix epoch. The whole index is used if date is 0.
+// deadline is the unix timestamp in seconds for the index scan to finish.
+func (s *Storage) GetTSDBStatusForDate(accountID, projectID uint32, date uint64, topN int, deadline uint64) (*TSDBStatus, error) {
+	return s.idb().GetTSDBStatusForDate(accountID, projectID, date, topN, deadline)
 }
 
 // MetricRow is a metric to insert into storage.
@@ -724,6 +983,12 @@ func (s *Storage) AddRows(mrs []MetricRow, precisionBits uint8) error {
 			len(mrs), addRowsTimeout, cap(addRowsConcurrencyCh))
 	}
 
+	if s.wal != nil {
+		s.walLock.RLock()
+		defer s.walLock.RUnlock()
+		s.wal.mustWrite(mrs, precisionBits)
+	}
+
 	// Add rows to the storage.
 	var err error
 	rr := getRawRowsWithSize(len(mrs))
@@ -746,6 +1011,7 @@ func (s *Storage) add(rows []rawRow, mrs []MetricRow, precisionBits uint8) ([]ra
 
 	idb := s.idb()
 	dmis := idb.getDeletedMetricIDs()
+	minTimestamp, maxTimestamp := s.tb.getMinMaxTimestamps()
 	rowsLen := len(rows)
 	if n := rowsLen + len(mrs) - cap(rows); n > 0 {
 		rows = append(rows[:cap(rows)], make([]rawRow, n)...)
@@ -754,9 +1020,23 @@ func (s *Storage) add(rows []rawRow, mrs []MetricRow, precisionBits uint8) ([]ra
 	j := 0
 	for i := range mrs {
 		mr := &mrs[i]
-		if math.IsNaN(mr.Value) {
-			// Just skip NaNs, since the underlying encoding
-			// doesn't know how to work with them.
+		if math.IsNaN(mr.Value) && !decimal.IsStaleNaN(mr.Value) {
+			// Just skip NaNs other than Prometheus staleness marks,
+			// since the underlying encoding doesn't know how to work with them.
+			continue
+		}
+		if mr.Timestamp < minTimestamp {
+			// Skip rows with timestamps outside the retention, since they would be deleted soon anyway.
+			// Such rows mustn't go to partitions, which still exist until the next retention check.
+			atomic.AddUint64(&s.tooSmallTimestampRows, 1)
+			s.logOutOfRangeRow(mr, minTimestamp, maxTimestamp)
+			continue
+		}
+		if mr.Timestamp > maxTimestamp {
+			// Skip rows with timestamps too far in the future, since they may create
+			// needless partitions and pollute caches.
+			atomic.AddUint64(&s.tooBigTimestampRows, 1)
+			s.logOutOfRangeRow(mr, minTimestamp, maxTimestamp)
 			continue
 		}
 		r := &rows[rowsLen+j]
@@ -765,12 +1045,12 @@ func (s *Storage) add(rows []rawRow, mrs []MetricRow, precisionBits uint8) ([]ra
 		r.Value = mr.Value
 		r.PrecisionBits = precisionBits
 		if s.getTSIDFromCache(&r.TSID, mr.MetricNameRaw) {
-			if len(dmis) == 0 {
-				// Fast path - the TSID for the given MetricName has been found in cache and isn't deleted.
-				continue
-			}
 			if _, deleted := dmis[r.TSID.MetricID]; !deleted {
 				// Fast path - the TSID for the given MetricName has been found in cache and isn't deleted.
+				if !s.registerSeriesCardinality(r.TSID.MetricID, mr.MetricNameRaw) {
+					// Skip the row, since it exceeds the limit on the number of unique series.
+					j--
+				}
 				continue
 			}
 		}
@@ -802,6 +1082,11 @@ func (s *Storage) add(rows []rawRow, mrs []MetricRow, precisionBits uint8) ([]ra
 			continue
 		}
 		s.putTSIDToCache(&r.TSID, mr.MetricNameRaw)
+		if !s.registerSeriesCardinality(r.TSID.MetricID, mr.MetricNameRaw) {
+			// Skip the row, since it exceeds the limit on the number of unique series.
+			j--
+			continue
+		}
 	}
 	if is != nil {
 		kbPool.Put(kb)
@@ -847,8 +1132,13 @@ func (s *Storage) updateDateMetricIDCache(rows []rawRow, errors []error) []error
 				// Fast path: the metricID is in the current hour cache.
 				continue
 			}
+			e := pendingHourMetricIDEntry{
+				AccountID: r.TSID.AccountID,
+				ProjectID: r.TSID.ProjectID,
+				MetricID:  metricID,
+			}
 			s.pendingHourMetricIDsLock.Lock()
-			s.pendingHourMetricIDs[metricID] = struct{}{}
+			s.pendingHourMetricIDs[e] = struct{}{}
 			s.pendingHourMetricIDsLock.Unlock()
 		}
 
@@ -863,7 +1153,7 @@ func (s *Storage) updateDateMetricIDCache(rows []rawRow, errors []error) []error
 		// It is OK if the (date, metricID) entry is added multiple times to db
 		// by concurrent goroutines.
 		s.dateMetricIDCache.Set(keyBuf, nil)
-		if err := idb.storeDateMetricID(date, metricID); err != nil {
+		if err := idb.storeDateMetricID(date, metricID, r.TSID.AccountID, r.TSID.ProjectID); err != nil {
 			errors = append(errors, err)
 			continue
 		}
@@ -884,28 +1174,45 @@ func (s *Storage) updateCurrHourMetricIDs() {
 
 	// Slow path: hm.m must be updated with non-empty s.pendingHourMetricIDs.
 	var m map[uint64]struct{}
+	var byTenant map[accountProjectKey]map[uint64]struct{}
 	isFull := hm.isFull
 	if hm.hour == hour {
 		m = make(map[uint64]struct{}, len(hm.m)+newMetricIDsLen)
 		for metricID := range hm.m {
 			m[metricID] = struct{}{}
 		}
+		byTenant = make(map[accountProjectKey]map[uint64]struct{}, len(hm.byTenant))
+		for k, e := range hm.byTenant {
+			byTenant[k] = getMetricIDsCopy(e)
+		}
 	} else {
 		m = make(map[uint64]struct{}, newMetricIDsLen)
+		byTenant = make(map[accountProjectKey]map[uint64]struct{})
 		isFull = true
 	}
 	s.pendingHourMetricIDsLock.Lock()
-	newMetricIDs := s.pendingHourMetricIDs
-	s.pendingHourMetricIDs = make(map[uint64]struct{}, len(newMetricIDs))
+	newEntries := s.pendingHourMetricIDs
+	s.pendingHourMetricIDs = make(map[pendingHourMetricIDEntry]struct{}, len(newEntries))
 	s.pendingHourMetricIDsLock.Unlock()
-	for metricID := range newMetricIDs {
-		m[metricID] = struct{}{}
+	for e := range newEntries {
+		m[e.MetricID] = struct{}{}
+		k := accountProjectKey{
+			AccountID: e.AccountID,
+			ProjectID: e.ProjectID,
+		}
+		x := byTenant[k]
+		if x == nil {
+			x = make(map[uint64]struct{})
+			byTenant[k] = x
+		}
+		x[e.MetricID] = struct{}{}
 	}
 
 	hmNew := &hourMetricIDs{
-		m:      m,
-		hour:   hour,
-		isFull: isFull,
+		m:        m,
+		byTenant: byTenant,
+		hour:     hour,
+		isFull:   isFull,
 	}
 	s.currHourMetricIDs.Store(hmNew)
 	if hm.hour != hour {
@@ -914,9 +1221,21 @@ func (s *Storage) updateCurrHourMetricIDs() {
 }
 
 type hourMetricIDs struct {
-	m      map[uint64]struct{}
-	hour   uint64
-	isFull bool
+	m        map[uint64]struct{}
+	byTenant map[accountProjectKey]map[uint64]struct{}
+	hour     uint64
+	isFull   bool
+}
+
+type pendingHourMetricIDEntry struct {
+	AccountID uint32
+	ProjectID uint32
+	MetricID  uint64
+}
+
+type accountProjectKey struct {
+	AccountID uint32
+	ProjectID uint32
 }
 
 func (s *Storage) getTSIDFromCache(dst *TSID, metricName []byte) bool {
@@ -1011,3 +1330,20 @@ func nextIndexDBTableName() string {
 }
 
 var indexDBTableIdx = uint64(time.Now().UnixNano())
+
+// logOutOfRangeRow logs mr with the timestamp outside [minTimestamp ... maxTimestamp] range.
+//
+// The log message is emitted at most once per 5 seconds in order to avoid log flood.
+func (s *Storage) logOutOfRangeRow(mr *MetricRow, minTimestamp, maxTimestamp int64) {
+	if !s.outOfRangeLogThrottler.Allow() {
+		return
+	}
+	mn := GetMetricName()
+	defer PutMetricName(mn)
+	name := "<invalid metric name>"
+	if err := mn.unmarshalRaw(mr.MetricNameRaw); err == nil {
+		name = mn.String()
+	}
+	logger.Errorf("dropping sample for %s with timestamp %d outside the allowed range [%d ... %d]; "+
+		"see vm_too_small_timestamp_rows_total and vm_too_big_timestamp_rows_total metrics", name, mr.Timestamp, minTimestamp, maxTimestamp)
+}
@@ -2,9 +2,11 @@ package storage
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"testing/quick"
@@ -16,7 +18,7 @@ func TestUpdateCurrHourMetricIDs(t *testing.T) {
 		var s Storage
 		s.currHourMetricIDs.Store(&hourMetricIDs{})
 		s.prevHourMetricIDs.Store(&hourMetricIDs{})
-		s.pendingHourMetricIDs = make(map[uint64]struct{})
+		s.pendingHourMetricIDs = make(map[pendingHourMetricIDEntry]struct{})
 		return &s
 	}
 	t.Run("empty_pedning_metric_ids_stale_curr_hour", func(t *testing.T) {
@@ -96,10 +98,10 @@ func TestUpdateCurrHourMetricIDs(t *testing.T) {
 	})
 	t.Run("nonempty_pending_metric_ids_stale_curr_hour", func(t *testing.T) {
 		s := newStorage()
-		pendingHourMetricIDs := map[uint64]struct{}{
-			343:     {},
-			32424:   {},
-			8293432: {},
+		pendingHourMetricIDs := map[pendingHourMetricIDEntry]struct{}{
+			{AccountID: 1, ProjectID: 2, MetricID: 343}:     {},
+			{AccountID: 1, ProjectID: 2, MetricID: 32424}:   {},
+			{AccountID: 2, ProjectID: 0, MetricID: 8293432}: {},
 		}
 		s.pendingHourMetricIDs = pendingHourMetricIDs
 
@@ -121,8 +123,25 @@ func TestUpdateCurrHourMetricIDs(t *testing.T) {
 				t.Fatalf("unexpected hmCurr.hour; got %d; want %d", hmCurr.hour, hour)
 			}
 		}
-		if !reflect.DeepEqual(hmCurr.m, pendingHourMetricIDs) {
-			t.Fatalf("unexpected hm.m; got %v; want %v", hmCurr.m, pendingHourMetricIDs)
+		m := map[uint64]struct{}{
+			343:     {},
+			32424:   {},
+			8293432: {},
+		}
+		if !reflect.DeepEqual(hmCurr.m, m) {
+			t.Fatalf("unexpected hm.m; got %v; want %v", hmCurr.m, m)
+		}
+		byTenantExpected := map[accountProjectKey]map[uint64]struct{}{
+			{AccountID: 1, ProjectID: 2}: {
+				343:   {},
+				32424: {},
+			},
+			{AccountID: 2, ProjectID: 0}: {
+				8293432: {},
+			},
+		}
+		if !reflect.DeepEqual(hmCurr.byTenant, byTenantExpected) {
+			t.Fatalf("unexpected hm.byTenant; got %v; want %v", hmCurr.byTenant, byTenantExpected)
 		}
 		if !hmCurr.isFull {
 			t.Fatalf("unexpected hmCurr.isFull; got %v; want %v", hmCurr.isFull, true)
@@ -139,10 +158,10 @@ func TestUpdateCurrHourMetricIDs(t *testing.T) {
 	})
 	t.Run("nonempty_pending_metric_ids_valid_curr_hour", func(t *testing.T) {
 		s := newStorage()
-		pendingHourMetricIDs := map[uint64]struct{}{
-			343:     {},
-			32424:   {},
-			8293432: {},
+		pendingHourMetricIDs := map[pendingHourMetricIDEntry]struct{}{
+			{AccountID: 1, ProjectID: 2, MetricID: 343}:     {},
+			{AccountID: 1, ProjectID: 2, MetricID: 32424}:   {},
+			{AccountID: 2, ProjectID: 0, MetricID: 8293432}: {},
 		}
 		s.pendingHourMetricIDs = pendingHourMetricIDs
 
@@ -152,6 +171,14 @@ func TestUpdateCurrHourMetricIDs(t *testing.T) {
 				12: {},
 				34: {},
 			},
+			byTenant: map[accountProjectKey]map[uint64]struct{}{
+				{AccountID: 1, ProjectID: 2}: {
+					12: {},
+				},
+				{AccountID: 3, ProjectID: 4}: {
+					34: {},
+				},
+			},
 			hour: hour,
 		}
 		s.currHourMetricIDs.Store(hmOrig)
@@ -166,13 +193,32 @@ func TestUpdateCurrHourMetricIDs(t *testing.T) {
 			// Do not run other checks, since they may fail.
 			return
 		}
-		m := getMetricIDsCopy(pendingHourMetricIDs)
-		for metricID := range hmOrig.m {
-			m[metricID] = struct{}{}
+		m := map[uint64]struct{}{
+			12:      {},
+			34:      {},
+			343:     {},
+			32424:   {},
+			8293432: {},
 		}
 		if !reflect.DeepEqual(hmCurr.m, m) {
 			t.Fatalf("unexpected hm.m; got %v; want %v", hmCurr.m, m)
 		}
+		byTenantExpected := map[accountProjectKey]map[uint64]struct{}{
+			{AccountID: 1, ProjectID: 2}: {
+				12:    {},
+				343:   {},
+				32424: {},
+			},
+			{AccountID: 2, ProjectID: 0}: {
+				8293432: {},
+			},
+			{AccountID: 3, ProjectID: 4}: {
+				34: {},
+			},
+		}
+		if !reflect.DeepEqual(hmCurr.byTenant, byTenantExpected) {
+			t.Fatalf("unexpected hm.byTenant; got %v; want %v", hmCurr.byTenant, byTenantExpected)
+		}
 		if hmCurr.isFull {
 			t.Fatalf("unexpected hmCurr.isFull; got %v; want %v", hmCurr.isFull, false)
 		}
@@ -251,6 +297,52 @@ func TestStorageOpenClose(t *testing.T) {
 	}
 }
 
+func TestStorageFormatVersion(t *testing.T) {
+	path := "TestStorageFormatVersion"
+	versionPath := path + "/" + formatVersionFilename
+	s, err := OpenStorage(path, -1)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+	s.MustClose()
+	data, err := ioutil.ReadFile(versionPath)
+	if err != nil {
+		t.Fatalf("cannot read format version: %s", err)
+	}
+	if string(data) != formatVersion {
+		t.Fatalf("unexpected format version; got %q; want %q", data, formatVersion)
+	}
+
+	// The storage with the current format version must be opened.
+	s, err = OpenStorage(path, -1)
+	if err != nil {
+		t.Fatalf("cannot re-open storage: %s", err)
+	}
+	s.MustClose()
+
+	// The storage with unsupported format version mustn't be opened.
+	if err := ioutil.WriteFile(versionPath, []byte("foobar"), 0600); err != nil {
+		t.Fatalf("cannot write format version: %s", err)
+	}
+	if s, err := OpenStorage(path, -1); err == nil {
+		s.MustClose()
+		t.Fatalf("expecting non-nil error when opening storage with unsupported format version")
+	}
+
+	// The storage with data written by older versions without format version mustn't be opened.
+	if err := os.Remove(versionPath); err != nil {
+		t.Fatalf("cannot remove format version: %s", err)
+	}
+	if s, err := OpenStorage(path, -1); err == nil {
+		s.MustClose()
+		t.Fatalf("expecting non-nil error when opening storage without format version")
+	}
+
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("cannot remove %q: %s", path, err)
+	}
+}
+
 func TestStorageOpenMultipleTimes(t *testing.T) {
 	path := "TestStorageOpenMultipleTimes"
 	s1, err := OpenStorage(path, -1)
@@ -271,6 +363,136 @@ func TestStorageOpenMultipleTimes(t *testing.T) {
 	}
 }
 
+func TestStorageSeriesLimits(t *testing.T) {
+	f := func(maxHourly, maxDaily int) {
+		t.Helper()
+		SetMaxSeriesLimits(maxHourly, maxDaily)
+		defer SetMaxSeriesLimits(0, 0)
+
+		path := "TestStorageSeriesLimits"
+		s, err := OpenStorage(path, 0)
+		if err != nil {
+			t.Fatalf("cannot open storage: %s", err)
+		}
+		maxSeries := maxHourly
+		if maxSeries == 0 || maxDaily > 0 && maxDaily < maxSeries {
+			maxSeries = maxDaily
+		}
+
+		// Add rows for 10*maxSeries series.
+		seriesCount := 10 * maxSeries
+		timestamp := timestampFromTime(time.Now())
+		mrs := make([]MetricRow, seriesCount)
+		for i := range mrs {
+			var mn MetricName
+			mn.MetricGroup = []byte(fmt.Sprintf("metric_%d", i))
+			mrs[i] = MetricRow{
+				MetricNameRaw: mn.marshalRaw(nil),
+				Timestamp:     timestamp,
+				Value:         float64(i),
+			}
+		}
+		if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+			t.Fatalf("unexpected error when adding rows: %s", err)
+		}
+		var m Metrics
+		s.UpdateMetrics(&m)
+		rowsDropped := m.HourlySeriesLimitRowsDropped + m.DailySeriesLimitRowsDropped
+		// Some series over the limit may be accepted because of bloom filter false positives.
+		maxFalsePositives := uint64(seriesCount-maxSeries) / 100
+		// Series rejected by one limiter mustn't be registered in another limiter.
+		checkCurrentSeries := func(limiterName string, currentSeries uint64) {
+			t.Helper()
+			if currentSeries < uint64(maxSeries) || currentSeries > uint64(maxSeries)+maxFalsePositives {
+				t.Fatalf("unexpected number of series in the %s limiter; got %d; want from %d to %d",
+					limiterName, currentSeries, maxSeries, uint64(maxSeries)+maxFalsePositives)
+			}
+		}
+		if maxHourly > 0 {
+			checkCurrentSeries("hourly", m.HourlySeriesLimitCurrentSeries)
+		}
+		if maxDaily > 0 {
+			checkCurrentSeries("daily", m.DailySeriesLimitCurrentSeries)
+		}
+		minRowsDropped := uint64(seriesCount-maxSeries) - maxFalsePositives
+		if rowsDropped < minRowsDropped || rowsDropped > uint64(seriesCount-maxSeries) {
+			t.Fatalf("unexpected number of dropped rows; got %d; want from %d to %d", rowsDropped, minRowsDropped, seriesCount-maxSeries)
+		}
+
+		// Existing series must continue ingesting.
+		for i := range mrs[:maxSeries] {
+			mrs[i].Timestamp += 1000
+		}
+		if err := s.AddRows(mrs[:maxSeries], defaultPrecisionBits); err != nil {
+			t.Fatalf("unexpected error when adding rows: %s", err)
+		}
+		var m2 Metrics
+		s.UpdateMetrics(&m2)
+		rowsDropped2 := m2.HourlySeriesLimitRowsDropped + m2.DailySeriesLimitRowsDropped
+		if rowsDropped2 != rowsDropped {
+			t.Fatalf("unexpected rows dropped for existing series; got %d; want %d", rowsDropped2, rowsDropped)
+		}
+
+		s.MustClose()
+		if err := os.RemoveAll(path); err != nil {
+			t.Fatalf("cannot remove %q: %s", path, err)
+		}
+	}
+	f(100, 0)
+	f(0, 100)
+	f(1000, 200)
+}
+
+func TestStorageAddRowsOutOfRange(t *testing.T) {
+	path := "TestStorageAddRowsOutOfRange"
+	s, err := OpenStorage(path, 1)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+	var mn MetricName
+	mn.MetricGroup = []byte("metric")
+	metricNameRaw := mn.marshalRaw(nil)
+	now := timestampFromTime(time.Now())
+	timestamps := []int64{
+		now - 365*24*3600*1000,
+		now - 3600*1000,
+		now,
+		now + 3600*1000,
+		now + 3*24*3600*1000,
+		now + 365*24*3600*1000,
+	}
+	mrs := make([]MetricRow, len(timestamps))
+	for i, timestamp := range timestamps {
+		mrs[i] = MetricRow{
+			MetricNameRaw: metricNameRaw,
+			Timestamp:     timestamp,
+			Value:         float64(i),
+		}
+	}
+	if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+		t.Fatalf("unexpected error when adding rows: %s", err)
+	}
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if m.TooSmallTimestampRows != 1 {
+		t.Fatalf("unexpected TooSmallTimestampRows; got %d; want 1", m.TooSmallTimestampRows)
+	}
+	if m.TooBigTimestampRows != 2 {
+		t.Fatalf("unexpected TooBigTimestampRows; got %d; want 2", m.TooBigTimestampRows)
+	}
+	s.tb.ptwsLock.Lock()
+	partitionsCount := len(s.tb.ptws)
+	s.tb.ptwsLock.Unlock()
+	if partitionsCount > 2 {
+		t.Fatalf("too many partitions created for in-range rows; got %d; want up to 2", partitionsCount)
+	}
+
+	s.MustClose()
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("cannot remove %q: %s", path, err)
+	}
+}
+
 func TestStorageRandTimestamps(t *testing.T) {
 	path := "TestStorageRandTimestamps"
 	retentionMonths := 60
@@ -371,13 +593,17 @@ func TestStorageDeleteMetrics(t *testing.T) {
 		t.Fatalf("cannot open storage: %s", err)
 	}
 
+	const workersCount = 3
+
 	// Verify no tag keys exist
-	tks, err := s.SearchTagKeys(1e5)
-	if err != nil {
-		t.Fatalf("error in SearchTagKeys at the start: %s", err)
-	}
-	if len(tks) != 0 {
-		t.Fatalf("found non-empty tag keys at the start: %q", tks)
+	for workerNum := 0; workerNum < workersCount; workerNum++ {
+		tks, err := s.SearchTagKeys(uint32(workerNum), 123, 1e5)
+		if err != nil {
+			t.Fatalf("error in SearchTagKeys at the start: %s", err)
+		}
+		if len(tks) != 0 {
+			t.Fatalf("found non-empty tag keys at the start: %q", tks)
+		}
 	}
 
 	t.Run("serial", func(t *testing.T) {
@@ -397,7 +623,7 @@ func TestStorageDeleteMetrics(t *testing.T) {
 	})
 
 	t.Run("concurrent", func(t *testing.T) {
-		ch := make(chan error, 3)
+		ch := make(chan error, workersCount)
 		for i := 0; i < cap(ch); i++ {
 			go func(workerNum int) {
 				var err error
@@ -423,12 +649,271 @@ func TestStorageDeleteMetrics(t *testing.T) {
 	})
 
 	// Verify no more tag keys exist
-	tks, err = s.SearchTagKeys(1e5)
+	for workerNum := 0; workerNum < workersCount; workerNum++ {
+		tks, err := s.SearchTagKeys(uint32(workerNum), 123, 1e5)
+		if err != nil {
+			t.Fatalf("error in SearchTagKeys after the test: %s", err)
+		}
+		if len(tks) != 0 {
+			t.Fatalf("found non-empty tag keys after the test: %q", tks)
+		}
+	}
+
+	s.MustClose()
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("cannot remove %q: %s", path, err)
+	}
+}
+
+func TestStorageSearchMetricNames(t *testing.T) {
+	path := "TestStorageSearchMetricNames"
+	s, err := OpenStorage(path, 0)
 	if err != nil {
-		t.Fatalf("error in SearchTagKeys after the test: %s", err)
+		t.Fatalf("cannot open storage: %s", err)
 	}
-	if len(tks) != 0 {
-		t.Fatalf("found non-empty tag keys after the test: %q", tks)
+
+	const accountID = 12
+	const projectID = 34
+	const metricsCount = 10
+	var mrs []MetricRow
+	for i := 0; i < metricsCount; i++ {
+		var mn MetricName
+		mn.AccountID = accountID
+		mn.ProjectID = projectID
+		mn.MetricGroup = []byte(fmt.Sprintf("foo.bar.metric_%d", i))
+		mn.Tags = []Tag{
+			{[]byte("instance"), []byte("x")},
+		}
+		mrs = append(mrs, MetricRow{
+			MetricNameRaw: mn.marshalRaw(nil),
+			Timestamp:     int64(i) * 1000,
+			Value:         float64(i),
+		})
+	}
+	if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+		t.Fatalf("unexpected error when adding mrs: %s", err)
+	}
+	s.debugFlush()
+
+	tr := TimeRange{
+		MinTimestamp: 0,
+		MaxTimestamp: 1e10,
+	}
+	f := func(accountID, projectID uint32, re string, namesExpected []string) {
+		t.Helper()
+		tfs := NewTagFilters(accountID, projectID)
+		if err := tfs.Add(nil, []byte(re), false, true); err != nil {
+			t.Fatalf("cannot add tag filter: %s", err)
+		}
+		mns, err := s.SearchMetricNames([]*TagFilters{tfs}, tr, 1e5)
+		if err != nil {
+			t.Fatalf("error in SearchMetricNames: %s", err)
+		}
+		var names []string
+		for i := range mns {
+			mn := &mns[i]
+			if mn.AccountID != accountID || mn.ProjectID != projectID {
+				t.Fatalf("unexpected tenant for %s; got %d:%d; want %d:%d", mn, mn.AccountID, mn.ProjectID, accountID, projectID)
+			}
+			if string(mn.GetTagValue("instance")) != "x" {
+				t.Fatalf("missing instance tag in %s", mn)
+			}
+			names = append(names, string(mn.MetricGroup))
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, namesExpected) {
+			t.Fatalf("unexpected metric names; got %q; want %q", names, namesExpected)
+		}
+	}
+	f(accountID, projectID, `foo\.bar\.metric_[1-3]`, []string{"foo.bar.metric_1", "foo.bar.metric_2", "foo.bar.metric_3"})
+	f(accountID, projectID, `foo\.baz.*`, nil)
+
+	// Verify metric names aren't visible from another tenant
+	f(accountID, projectID+1, `foo.*`, nil)
+
+	s.MustClose()
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("cannot remove %q: %s", path, err)
+	}
+}
+
+func TestStorageSearchTagValueSuffixes(t *testing.T) {
+	path := "TestStorageSearchTagValueSuffixes"
+	s, err := OpenStorage(path, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+
+	const accountID = 12
+	const projectID = 34
+	var mrs []MetricRow
+	for i, name := range []string{"foo.bar.baz", "foo.bar.qux", "foo.bax", "foo.bax.x", "foo.b-c.d", "foo", "foox.y", "deleted.x"} {
+		var mn MetricName
+		mn.AccountID = accountID
+		mn.ProjectID = projectID
+		mn.MetricGroup = []byte(name)
+		mrs = append(mrs, MetricRow{
+			MetricNameRaw: mn.marshalRaw(nil),
+			Timestamp:     int64(i) * 1000,
+			Value:         float64(i),
+		})
+	}
+	if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+		t.Fatalf("unexpected error when adding mrs: %s", err)
+	}
+	s.debugFlush()
+	tfs := NewTagFilters(accountID, projectID)
+	if err := tfs.Add(nil, []byte("deleted.x"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	if _, err := s.DeleteMetrics([]*TagFilters{tfs}); err != nil {
+		t.Fatalf("cannot delete metrics: %s", err)
+	}
+
+	f := func(accountID, projectID uint32, prefix string, suffixesExpected []string) {
+		t.Helper()
+		suffixes, err := s.SearchTagValueSuffixes(accountID, projectID, nil, []byte(prefix), '.', 1e5)
+		if err != nil {
+			t.Fatalf("error in SearchTagValueSuffixes for %q: %s", prefix, err)
+		}
+		sort.Strings(suffixes)
+		if !reflect.DeepEqual(suffixes, suffixesExpected) {
+			t.Fatalf("unexpected suffixes for %q; got %q; want %q", prefix, suffixes, suffixesExpected)
+		}
+	}
+	f(accountID, projectID, "", []string{"foo", "foo.", "foox."})
+	f(accountID, projectID, "foo.", []string{"b-c.", "bar.", "bax", "bax."})
+	f(accountID, projectID, "foo.ba", []string{"r.", "x", "x."})
+	f(accountID, projectID, "foo.bar.", []string{"baz", "qux"})
+	f(accountID, projectID, "foo.bar.baz", []string{""})
+	f(accountID, projectID, "missing", []string{})
+
+	// Verify tag values aren't visible from another tenant
+	f(accountID, projectID+1, "", []string{})
+
+	s.MustClose()
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("cannot remove %q: %s", path, err)
+	}
+}
+
+func TestStorageGetTSDBStatusForDate(t *testing.T) {
+	path := "TestStorageGetTSDBStatusForDate"
+	s, err := OpenStorage(path, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+
+	const accountID = 12
+	const projectID = 34
+	const date = 18000
+	addRows := func(date uint64, metricGroup, job string, instances int) {
+		t.Helper()
+		var mrs []MetricRow
+		for i := 0; i < instances; i++ {
+			var mn MetricName
+			mn.AccountID = accountID
+			mn.ProjectID = projectID
+			mn.MetricGroup = []byte(metricGroup)
+			mn.Tags = []Tag{
+				{[]byte("job"), []byte(job)},
+				{[]byte("instance"), []byte(fmt.Sprintf("host_%d", i))},
+			}
+			mrs = append(mrs, MetricRow{
+				MetricNameRaw: mn.marshalRaw(nil),
+				Timestamp:     int64(date*msecPerDay) + 1000,
+				Value:         1,
+			})
+		}
+		if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+			t.Fatalf("unexpected error when adding mrs: %s", err)
+		}
+		s.debugFlush()
+	}
+	addRows(date, "foo", "a", 3)
+	addRows(date, "bar", "a", 1)
+	addRows(date+1, "baz", "b", 2)
+
+	f := func(accountID, projectID uint32, date uint64, topN int, statusExpected *TSDBStatus) {
+		t.Helper()
+		status, err := s.GetTSDBStatusForDate(accountID, projectID, date, topN, 1e10)
+		if err != nil {
+			t.Fatalf("error in GetTSDBStatusForDate: %s", err)
+		}
+		if !reflect.DeepEqual(status, statusExpected) {
+			t.Fatalf("unexpected status for date=%d, topN=%d\ngot\n%+v\nwant\n%+v", date, topN, status, statusExpected)
+		}
+	}
+	f(accountID, projectID, date, 2, &TSDBStatus{
+		TotalSeries: 4,
+		SeriesCountByMetricName: []TopHeapEntry{
+			{"foo", 3},
+			{"bar", 1},
+		},
+		LabelValueCountByLabelName: []TopHeapEntry{
+			{"instance", 3},
+			{"__name__", 2},
+		},
+		SeriesCountByLabelValuePair: []TopHeapEntry{
+			{"job=a", 4},
+			{"__name__=foo", 3},
+		},
+	})
+	f(accountID, projectID, date+1, 10, &TSDBStatus{
+		TotalSeries: 2,
+		SeriesCountByMetricName: []TopHeapEntry{
+			{"baz", 2},
+		},
+		LabelValueCountByLabelName: []TopHeapEntry{
+			{"instance", 2},
+			{"__name__", 1},
+			{"job", 1},
+		},
+		SeriesCountByLabelValuePair: []TopHeapEntry{
+			{"__name__=baz", 2},
+			{"job=b", 2},
+			{"instance=host_0", 1},
+			{"instance=host_1", 1},
+		},
+	})
+
+	// Series registered in both the current and the previous indexDB must be counted only once.
+	s.mustRotateIndexDB()
+	addRows(date+1, "baz", "b", 2)
+	statusExpected := &TSDBStatus{
+		TotalSeries: 6,
+		SeriesCountByMetricName: []TopHeapEntry{
+			{"foo", 3},
+			{"baz", 2},
+		},
+		LabelValueCountByLabelName: []TopHeapEntry{
+			{"__name__", 3},
+			{"instance", 3},
+		},
+		SeriesCountByLabelValuePair: []TopHeapEntry{
+			{"job=a", 4},
+			{"__name__=foo", 3},
+		},
+	}
+	f(accountID, projectID, 0, 2, statusExpected)
+
+	// Missing date
+	f(accountID, projectID, date+2, 10, &TSDBStatus{
+		SeriesCountByMetricName:     []TopHeapEntry{},
+		LabelValueCountByLabelName:  []TopHeapEntry{},
+		SeriesCountByLabelValuePair: []TopHeapEntry{},
+	})
+
+	// Series aren't visible from another tenant
+	f(accountID, projectID+1, 0, 10, &TSDBStatus{
+		SeriesCountByMetricName:     []TopHeapEntry{},
+		LabelValueCountByLabelName:  []TopHeapEntry{},
+		SeriesCountByLabelValuePair: []TopHeapEntry{},
+	})
+
+	// Expired deadline
+	if _, err := s.GetTSDBStatusForDate(accountID, projectID, 0, 10, 1); err == nil {
+		t.Fatalf("expecting non-nil error for expired deadline")
 	}
 
 	s.MustClose()
@@ -442,12 +927,16 @@ func testStorageDeleteMetrics(s *Storage, workerNum int) error {
 	const metricsCount = 30
 
 	workerTag := []byte(fmt.Sprintf("workerTag_%d", workerNum))
+	accountID := uint32(workerNum)
+	projectID := uint32(123)
 
 	tksAll := make(map[string]bool)
 	tksAll[""] = true // __name__
 	for i := 0; i < metricsCount; i++ {
 		var mrs []MetricRow
 		var mn MetricName
+		mn.AccountID = accountID
+		mn.ProjectID = projectID
 		job := fmt.Sprintf("job_%d_%d", i, workerNum)
 		instance := fmt.Sprintf("instance_%d_%d", i, workerNum)
 		mn.Tags = []Tag{
@@ -479,7 +968,7 @@ func testStorageDeleteMetrics(s *Storage, workerNum int) error {
 	s.debugFlush()
 
 	// Verify tag values exist
-	tvs, err := s.SearchTagValues(workerTag, 1e5)
+	tvs, err := s.SearchTagValues(accountID, projectID, workerTag, 1e5)
 	if err != nil {
 		return fmt.Errorf("error in SearchTagValues before metrics removal: %s", err)
 	}
@@ -488,7 +977,7 @@ func testStorageDeleteMetrics(s *Storage, workerNum int) error {
 	}
 
 	// Verify tag keys exist
-	tks, err := s.SearchTagKeys(1e5)
+	tks, err := s.SearchTagKeys(accountID, projectID, 1e5)
 	if err != nil {
 		return fmt.Errorf("error in SearchTagKeys before metrics removal: %s", err)
 	}
@@ -496,6 +985,15 @@ func testStorageDeleteMetrics(s *Storage, workerNum int) error {
 		return fmt.Errorf("unexpected tag keys before metrics removal: %s", err)
 	}
 
+	// Verify tag keys aren't visible from another tenant
+	tks, err = s.SearchTagKeys(accountID, projectID+1, 1e5)
+	if err != nil {
+		return fmt.Errorf("error in SearchTagKeys for another tenant: %s", err)
+	}
+	if len(tks) != 0 {
+		return fmt.Errorf("found non-empty tag keys for another tenant: %q", tks)
+	}
+
 	var sr Search
 	tr := TimeRange{
 		MinTimestamp: 0,
@@ -511,7 +1009,7 @@ func testStorageDeleteMetrics(s *Storage, workerNum int) error {
 		return n
 	}
 	for i := 0; i < metricsCount; i++ {
-		tfs := NewTagFilters()
+		tfs := NewTagFilters(accountID, projectID)
 		if err := tfs.Add(nil, []byte("metric_.+"), false, true); err != nil {
 			return fmt.Errorf("cannot add regexp tag filter: %s", err)
 		}
@@ -544,14 +1042,14 @@ func testStorageDeleteMetrics(s *Storage, workerNum int) error {
 	}
 
 	// Make sure no more metrics left for the given workerNum
-	tfs := NewTagFilters()
+	tfs := NewTagFilters(accountID, projectID)
 	if err := tfs.Add(nil, []byte(fmt.Sprintf("metric_.+_%d", workerNum)), false, true); err != nil {
 		return fmt.Errorf("cannot add regexp tag filter for worker metrics: %s", err)
 	}
 	if n := metricBlocksCount(tfs); n != 0 {
 		return fmt.Errorf("expecting zero metric blocks after deleting all the metrics; got %d blocks", n)
 	}
-	tvs, err = s.SearchTagValues(workerTag, 1e5)
+	tvs, err = s.SearchTagValues(accountID, projectID, workerTag, 1e5)
 	if err != nil {
 		return fmt.Errorf("error in SearchTagValues after all the metrics are removed: %s", err)
 	}
@@ -562,6 +1060,257 @@ func testStorageDeleteMetrics(s *Storage, workerNum int) error {
 	return nil
 }
 
+func TestStorageDeleteSamples(t *testing.T) {
+	path := "TestStorageDeleteSamples"
+	s, err := OpenStorage(path, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+
+	var mn MetricName
+	mn.MetricGroup = []byte("foo")
+	mn.Tags = []Tag{
+		{[]byte("job"), []byte("bar")},
+	}
+	metricNameRaw := mn.marshalRaw(nil)
+	addRows := func(minTimestamp int64, rowsCount int) {
+		t.Helper()
+		var mrs []MetricRow
+		for i := 0; i < rowsCount; i++ {
+			mr := MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     minTimestamp + int64(i)*1000,
+				Value:         float64(i),
+			}
+			mrs = append(mrs, mr)
+		}
+		if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+			t.Fatalf("unexpected error when adding mrs: %s", err)
+		}
+		s.debugFlush()
+	}
+	tfs := NewTagFilters(0, 0)
+	if err := tfs.Add(nil, []byte("foo"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	tfss := []*TagFilters{tfs}
+	trAll := TimeRange{
+		MinTimestamp: 0,
+		MaxTimestamp: 2e10,
+	}
+	checkRowsCount := func(rowsCountExpected int) {
+		t.Helper()
+		var sr Search
+		sr.Init(s, tfss, trAll, 1e5)
+		rowsCount := 0
+		for sr.NextMetricBlock() {
+			rowsCount += sr.MetricBlock.Block.RowsCount()
+		}
+		if err := sr.Error(); err != nil {
+			t.Fatalf("unexpected error during search: %s", err)
+		}
+		sr.MustClose()
+		if rowsCount != rowsCountExpected {
+			t.Fatalf("unexpected number of rows; got %d; want %d", rowsCount, rowsCountExpected)
+		}
+	}
+
+	addRows(1e9, 1000)
+	checkRowsCount(1000)
+
+	// Delete rows on the time range with 100 rows.
+	tr := TimeRange{
+		MinTimestamp: 1e9 + 100e3,
+		MaxTimestamp: 1e9 + 199e3,
+	}
+	deletedCount, err := s.DeleteSamples(tfss, tr)
+	if err != nil {
+		t.Fatalf("cannot delete samples: %s", err)
+	}
+	if deletedCount != 1 {
+		t.Fatalf("unexpected number of metrics with deleted samples; got %d; want %d", deletedCount, 1)
+	}
+	checkRowsCount(900)
+
+	// Rows added after the deletion must remain visible.
+	addRows(1e9+150e3+500, 10)
+	checkRowsCount(910)
+
+	// Deleting samples for missing metrics must be no-op.
+	tfsMissing := NewTagFilters(0, 0)
+	if err := tfsMissing.Add(nil, []byte("non-existing-metric"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	deletedCount, err = s.DeleteSamples([]*TagFilters{tfsMissing}, tr)
+	if err != nil {
+		t.Fatalf("cannot delete samples for missing metrics: %s", err)
+	}
+	if deletedCount != 0 {
+		t.Fatalf("unexpected number of metrics with deleted samples; got %d; want %d", deletedCount, 0)
+	}
+
+	// Re-open the storage in order to verify tombstones are applied during merges
+	// and persisted for the remaining parts.
+	s.MustClose()
+	s, err = OpenStorage(path, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage after closing: %s", err)
+	}
+	checkRowsCount(910)
+
+	s.MustClose()
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("cannot remove %q: %s", path, err)
+	}
+}
+
+func TestStorageDeleteSamplesConstSeries(t *testing.T) {
+	path := "TestStorageDeleteSamplesConstSeries"
+	s, err := OpenStorage(path, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+
+	var mn MetricName
+	mn.MetricGroup = []byte("up")
+	mn.Tags = []Tag{
+		{[]byte("job"), []byte("bar")},
+	}
+	metricNameRaw := mn.marshalRaw(nil)
+	var mrs []MetricRow
+	for i := 0; i < 1000; i++ {
+		mr := MetricRow{
+			MetricNameRaw: metricNameRaw,
+			Timestamp:     1e9 + int64(i)*1000,
+			Value:         1,
+		}
+		mrs = append(mrs, mr)
+	}
+	if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+		t.Fatalf("unexpected error when adding mrs: %s", err)
+	}
+	s.debugFlush()
+
+	tfs := NewTagFilters(0, 0)
+	if err := tfs.Add(nil, []byte("up"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	tfss := []*TagFilters{tfs}
+	var trsDeleted []TimeRange
+	checkRows := func(rowsCountExpected int) {
+		t.Helper()
+		var sr Search
+		sr.Init(s, tfss, TimeRange{MinTimestamp: 0, MaxTimestamp: 2e10}, 1e5)
+		rowsCount := 0
+		for sr.NextMetricBlock() {
+			b := sr.MetricBlock.Block
+			if err := b.UnmarshalData(); err != nil {
+				t.Fatalf("cannot unmarshal block: %s", err)
+			}
+			for _, timestamp := range b.Timestamps() {
+				for i := range trsDeleted {
+					tr := &trsDeleted[i]
+					if timestamp >= tr.MinTimestamp && timestamp <= tr.MaxTimestamp {
+						t.Fatalf("unexpected row with timestamp %d in the deleted time range %+v", timestamp, tr)
+					}
+				}
+			}
+			rowsCount += b.RowsCount()
+		}
+		if err := sr.Error(); err != nil {
+			t.Fatalf("unexpected error during search: %s", err)
+		}
+		sr.MustClose()
+		if rowsCount != rowsCountExpected {
+			t.Fatalf("unexpected number of rows; got %d; want %d", rowsCount, rowsCountExpected)
+		}
+	}
+	deleteSamples := func(tr TimeRange) {
+		t.Helper()
+		if _, err := s.DeleteSamples(tfss, tr); err != nil {
+			t.Fatalf("cannot delete samples: %s", err)
+		}
+		trsDeleted = append(trsDeleted, tr)
+	}
+	reopenStorage := func() {
+		t.Helper()
+		s.MustClose()
+		s, err = OpenStorage(path, 0)
+		if err != nil {
+			t.Fatalf("cannot open storage after closing: %s", err)
+		}
+	}
+
+	// Delete the middle range of the constant series.
+	deleteSamples(TimeRange{
+		MinTimestamp: 1e9 + 300e3,
+		MaxTimestamp: 1e9 + 599e3,
+	})
+	checkRows(700)
+
+	// Delete a single sample, so the gap is only twice the interval between samples.
+	deleteSamples(TimeRange{
+		MinTimestamp: 1e9 + 800e3,
+		MaxTimestamp: 1e9 + 800e3,
+	})
+	checkRows(699)
+
+	// Re-open the storage, so the block with deleted rows is merged and written to disk.
+	reopenStorage()
+	checkRows(699)
+
+	// The block without tombstones must keep its timestamps during subsequent merges.
+	mrs = mrs[:1]
+	mrs[0].Timestamp = 1e9 + 1000e3
+	if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+		t.Fatalf("unexpected error when adding mrs: %s", err)
+	}
+	s.debugFlush()
+	reopenStorage()
+	checkRows(700)
+
+	s.MustClose()
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("cannot remove %q: %s", path, err)
+	}
+}
+
+// mustOpenTestStorage opens the storage at the given path for tests.
+func mustOpenTestStorage(t *testing.T, path string, retentionMonths int) *Storage {
+	t.Helper()
+	s, err := OpenStorage(path, retentionMonths)
+	if err != nil {
+		t.Fatalf("cannot open storage at %q: %s", path, err)
+	}
+	return s
+}
+
+// mustCloseTestStorage closes s and removes its data at the given path.
+func mustCloseTestStorage(t *testing.T, s *Storage, path string) {
+	t.Helper()
+	s.MustClose()
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("cannot remove %q: %s", path, err)
+	}
+}
+
+// mustAddTestRows adds mrs to s and makes them visible for search.
+func mustAddTestRows(t *testing.T, s *Storage, mrs []MetricRow) {
+	t.Helper()
+	if err := s.AddRows(mrs, defaultPrecisionBits); err != nil {
+		t.Fatalf("unexpected error when adding rows: %s", err)
+	}
+	s.debugFlush()
+}
+
+func newTestMetricRow(mn *MetricName, timestamp int64, value float64) MetricRow {
+	return MetricRow{
+		MetricNameRaw: mn.marshalRaw(nil),
+		Timestamp:     timestamp,
+		Value:         value,
+	}
+}
+
 func checkTagKeys(tks []string, tksExpected map[string]bool) error {
 	if len(tks) < len(tksExpected) {
 		return fmt.Errorf("unexpected number of tag keys found; got %d; want at least %d; tks=%q, tksExpected=%v", len(tks), len(tksExpected), tks, tksExpected)
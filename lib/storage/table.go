@@ -223,6 +223,31 @@ func (tb *table) flushRawRows() {
 	}
 }
 
+// mustFlushToDisk flushes all the rows added to tb before the call to disk.
+func (tb *table) mustFlushToDisk() {
+	ptws := tb.GetPartitions(nil)
+	defer tb.PutPartitions(ptws)
+
+	for _, ptw := range ptws {
+		ptw.pt.mustFlushToDisk()
+	}
+}
+
+// DeleteRows marks rows for the given metricIDs on the given tr as deleted.
+func (tb *table) DeleteRows(metricIDs map[uint64]struct{}, tr TimeRange) {
+	ptws := tb.GetPartitions(nil)
+	defer tb.PutPartitions(ptws)
+
+	for _, ptw := range ptws {
+		pt := ptw.pt
+		if pt.tr.MinTimestamp > tr.MaxTimestamp || pt.tr.MaxTimestamp < tr.MinTimestamp {
+			// The partition doesn't contain rows for the given tr.
+			continue
+		}
+		pt.AddTombstone(metricIDs, tr)
+	}
+}
+
 // TableMetrics contains essential metrics for the table.
 type TableMetrics struct {
 	partitionMetrics
@@ -310,9 +335,7 @@ func (tb *table) AddRows(rows []rawRow) error {
 	// The slowest path - there are rows that don't fit any existing partition.
 	// Create new partitions for these rows.
 	// Do this under tb.ptwsLock.
-	now := timestampFromTime(time.Now())
-	minTimestamp := now - tb.retentionMilliseconds
-	maxTimestamp := now + 2*24*3600*1000 // allow max +2 days from now due to timezones shit :)
+	minTimestamp, maxTimestamp := tb.getMinMaxTimestamps()
 	tb.ptwsLock.Lock()
 	var errors []error
 	for i := range missingRows {
@@ -359,6 +382,21 @@ func (tb *table) AddRows(rows []rawRow) error {
 	return nil
 }
 
+// maxFutureDriftMsecs is the maximum duration in milliseconds for row timestamps in the future.
+//
+// Allow max +2 days from now due to timezones shit :)
+const maxFutureDriftMsecs = 2 * 24 * 3600 * 1000
+
+// getMinMaxTimestamps returns the range of timestamps for rows, which may be added to tb.
+//
+// Rows outside the range are either out of the retention or are too far in the future.
+func (tb *table) getMinMaxTimestamps() (int64, int64) {
+	now := timestampFromTime(time.Now())
+	minTimestamp := now - tb.retentionMilliseconds
+	maxTimestamp := now + maxFutureDriftMsecs
+	return minTimestamp, maxTimestamp
+}
+
 func (tb *table) startRetentionWatcher() {
 	tb.retentionWatcherWG.Add(1)
 	go func() {
@@ -10,23 +10,29 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/memory"
 )
 
 // TagFilters represents filters used for filtering tags.
 type TagFilters struct {
+	accountID uint32
+	projectID uint32
+
 	tfs []tagFilter
 
 	// Common prefix for all the tag filters.
-	// Contains encoded nsPrefixTagToMetricID.
+	// Contains encoded nsPrefixTagToMetricID + accountID + projectID.
 	commonPrefix []byte
 }
 
-// NewTagFilters returns new TagFilters.
-func NewTagFilters() *TagFilters {
+// NewTagFilters returns new TagFilters for the given accountID and projectID.
+func NewTagFilters(accountID, projectID uint32) *TagFilters {
 	return &TagFilters{
-		commonPrefix: marshalCommonPrefix(nil, nsPrefixTagToMetricID),
+		accountID:    accountID,
+		projectID:    projectID,
+		commonPrefix: marshalCommonPrefix(nil, nsPrefixTagToMetricID, accountID, projectID),
 	}
 }
 
@@ -75,13 +81,17 @@ func (tfs *TagFilters) String() string {
 	return bb.String()
 }
 
-// Reset resets the tf
-func (tfs *TagFilters) Reset() {
+// Reset resets the tf for the given accountID and projectID
+func (tfs *TagFilters) Reset(accountID, projectID uint32) {
+	tfs.accountID = accountID
+	tfs.projectID = projectID
 	tfs.tfs = tfs.tfs[:0]
-	tfs.commonPrefix = marshalCommonPrefix(tfs.commonPrefix[:0], nsPrefixTagToMetricID)
+	tfs.commonPrefix = marshalCommonPrefix(tfs.commonPrefix[:0], nsPrefixTagToMetricID, accountID, projectID)
 }
 
 func (tfs *TagFilters) marshal(dst []byte) []byte {
+	dst = encoding.MarshalUint32(dst, tfs.accountID)
+	dst = encoding.MarshalUint32(dst, tfs.projectID)
 	for i := range tfs.tfs {
 		dst = tfs.tfs[i].Marshal(dst)
 	}
@@ -95,7 +105,7 @@ type tagFilter struct {
 	isNegative bool
 	isRegexp   bool
 
-	// Prefix always contains {nsPrefixTagToMetricID, key}.
+	// Prefix always contains {nsPrefixTagToMetricID, accountID, projectID, key}.
 	// Additionally it contains:
 	//  - value ending with tagSeparatorChar if !isRegexp.
 	//  - non-regexp prefix if isRegexp.
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// tombstonesFilename is the name of the file with partition tombstones.
+//
+// The file is stored in the directory with small parts.
+const tombstonesFilename = "tombstones"
+
+// tombstone marks rows for metricIDs on the time range tr as deleted.
+//
+// The tombstone applies only to parts, which existed at the time
+// the tombstone has been created. Rows added after that aren't affected
+// by the tombstone even if they belong to tr.
+//
+// The tombstone is applied to parts when they are merged. Until then
+// it is used for filtering out deleted rows during search.
+type tombstone struct {
+	metricIDs map[uint64]struct{}
+	tr        TimeRange
+
+	// pws contains parts the tombstone must be applied to.
+	//
+	// pws is protected by partition.partsLock.
+	pws map[*partWrapper]struct{}
+}
+
+// mayContainBlockRows returns true if t may delete rows from the block with the given bh.
+func (t *tombstone) mayContainBlockRows(bh *blockHeader) bool {
+	if _, ok := t.metricIDs[bh.TSID.MetricID]; !ok {
+		return false
+	}
+	return bh.MinTimestamp <= t.tr.MaxTimestamp && bh.MaxTimestamp >= t.tr.MinTimestamp
+}
+
+// deleteTombstonedRows deletes rows covered by tss from b.
+//
+// b remains unmarshaled if rows are deleted from it.
+// b.RowsCount() returns zero if all the rows have been deleted.
+//
+// Returns the number of deleted rows.
+func deleteTombstonedRows(b *Block, tss []*tombstone) (int, error) {
+	var tssMatched []*tombstone
+	for _, t := range tss {
+		if t.mayContainBlockRows(&b.bh) {
+			tssMatched = append(tssMatched, t)
+		}
+	}
+	if len(tssMatched) == 0 {
+		// Fast path - tss don't cover rows in b.
+		return 0, nil
+	}
+
+	// Slow path - unmarshal b and drop rows covered by tssMatched.
+	if err := b.UnmarshalData(); err != nil {
+		return 0, fmt.Errorf("cannot unmarshal block data: %s", err)
+	}
+	timestamps := b.timestamps
+	values := b.values
+	dstIdx := b.nextIdx
+	for i := b.nextIdx; i < len(timestamps); i++ {
+		if isTombstonedTimestamp(tssMatched, timestamps[i]) {
+			continue
+		}
+		timestamps[dstIdx] = timestamps[i]
+		values[dstIdx] = values[i]
+		dstIdx++
+	}
+	deletedRows := len(timestamps) - dstIdx
+	if deletedRows > 0 {
+		b.keepTimestamps = true
+	}
+	b.timestamps = timestamps[:dstIdx]
+	b.values = values[:dstIdx]
+	b.bh.RowsCount = uint32(dstIdx - b.nextIdx)
+	if b.bh.RowsCount > 0 {
+		b.fixupTimestamps()
+	}
+	return deletedRows, nil
+}
+
+func isTombstonedTimestamp(tss []*tombstone, timestamp int64) bool {
+	for _, t := range tss {
+		if timestamp >= t.tr.MinTimestamp && timestamp <= t.tr.MaxTimestamp {
+			return true
+		}
+	}
+	return false
+}
+
+// marshalTombstones appends marshaled tss to dst and returns the result.
+//
+// Only references to file-based parts are marshaled, since inmemory parts
+// don't survive restarts.
+func marshalTombstones(dst []byte, tss []*tombstone) []byte {
+	dst = encoding.MarshalUint64(dst, uint64(len(tss)))
+	for _, t := range tss {
+		dst = encoding.MarshalInt64(dst, t.tr.MinTimestamp)
+		dst = encoding.MarshalInt64(dst, t.tr.MaxTimestamp)
+		dst = encoding.MarshalUint64(dst, uint64(len(t.metricIDs)))
+		for metricID := range t.metricIDs {
+			dst = encoding.MarshalUint64(dst, metricID)
+		}
+		partNamesCount := 0
+		for pw := range t.pws {
+			if pw.mp == nil {
+				partNamesCount++
+			}
+		}
+		dst = encoding.MarshalUint64(dst, uint64(partNamesCount))
+		for pw := range t.pws {
+			if pw.mp == nil {
+				dst = encoding.MarshalBytes(dst, []byte(filepath.Base(pw.p.path)))
+			}
+		}
+	}
+	return dst
+}
+
+// unmarshalTombstones unmarshals tombstones from src.
+//
+// Part names in tombstones are resolved via pwsByName. References to missing parts
+// are dropped, since such parts have been already merged. Tombstones without parts are dropped.
+func unmarshalTombstones(src []byte, pwsByName map[string]*partWrapper) ([]*tombstone, error) {
+	if len(src) < 8 {
+		return nil, fmt.Errorf("cannot unmarshal tombstones count from %d bytes; need at least 8 bytes", len(src))
+	}
+	tssLen := encoding.UnmarshalUint64(src)
+	src = src[8:]
+	var tss []*tombstone
+	for i := uint64(0); i < tssLen; i++ {
+		if len(src) < 24 {
+			return nil, fmt.Errorf("cannot unmarshal tombstone header from %d bytes; need at least 24 bytes", len(src))
+		}
+		t := &tombstone{
+			pws: make(map[*partWrapper]struct{}),
+		}
+		t.tr.MinTimestamp = encoding.UnmarshalInt64(src)
+		t.tr.MaxTimestamp = encoding.UnmarshalInt64(src[8:])
+		metricIDsLen := encoding.UnmarshalUint64(src[16:])
+		src = src[24:]
+		if uint64(len(src)) < 8*metricIDsLen+8 {
+			return nil, fmt.Errorf("cannot unmarshal %d metricIDs from %d bytes", metricIDsLen, len(src))
+		}
+		t.metricIDs = make(map[uint64]struct{}, metricIDsLen)
+		for j := uint64(0); j < metricIDsLen; j++ {
+			t.metricIDs[encoding.UnmarshalUint64(src)] = struct{}{}
+			src = src[8:]
+		}
+		partNamesLen := encoding.UnmarshalUint64(src)
+		src = src[8:]
+		for j := uint64(0); j < partNamesLen; j++ {
+			tail, partName, err := encoding.UnmarshalBytes(src)
+			if err != nil {
+				return nil, fmt.Errorf("cannot unmarshal part name: %s", err)
+			}
+			src = tail
+			if pw := pwsByName[string(partName)]; pw != nil {
+				t.pws[pw] = struct{}{}
+			}
+		}
+		if len(t.pws) > 0 {
+			tss = append(tss, t)
+		}
+	}
+	if len(src) > 0 {
+		return nil, fmt.Errorf("unexpected non-empty tail left after unmarshaling tombstones; len(tail)=%d", len(src))
+	}
+	return tss, nil
+}
+
+// mustLoadTombstones loads tombstones for pws from the given partition path.
+func mustLoadTombstones(path string, pws []*partWrapper) []*tombstone {
+	path = path + "/" + tombstonesFilename
+	if !fs.IsPathExist(path) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		logger.Panicf("FATAL: cannot read %q: %s", path, err)
+	}
+	pwsByName := make(map[string]*partWrapper, len(pws))
+	for _, pw := range pws {
+		pwsByName[filepath.Base(pw.p.path)] = pw
+	}
+	tss, err := unmarshalTombstones(data, pwsByName)
+	if err != nil {
+		logger.Panicf("FATAL: cannot unmarshal tombstones from %q: %s", path, err)
+	}
+	logger.Infof("loaded %d tombstones from %q", len(tss), path)
+	return tss
+}
+
+// mustSaveTombstones atomically stores data with marshaled tombstones at the given partition path.
+func mustSaveTombstones(path string, data []byte) {
+	mustWritePartitionFile(path, tombstonesFilename, data)
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarshalUnmarshalTombstones(t *testing.T) {
+	pws := []*partWrapper{
+		{p: &part{path: "/foo/small/2019_07/1_1_20190701000000.000_20190701000000.000_15B0D2FC5B8A1D2E"}},
+		{p: &part{path: "/foo/big/2019_07/2_1_20190701000000.000_20190701000000.000_15B0D2FC5B8A1D2F"}},
+		{p: &part{}, mp: &inmemoryPart{}},
+	}
+	pwsByName := map[string]*partWrapper{
+		"1_1_20190701000000.000_20190701000000.000_15B0D2FC5B8A1D2E": pws[0],
+		"2_1_20190701000000.000_20190701000000.000_15B0D2FC5B8A1D2F": pws[1],
+	}
+	tss := []*tombstone{
+		{
+			metricIDs: map[uint64]struct{}{
+				1:  {},
+				42: {},
+			},
+			tr: TimeRange{
+				MinTimestamp: -10,
+				MaxTimestamp: 1234,
+			},
+			pws: map[*partWrapper]struct{}{
+				pws[0]: {},
+				pws[1]: {},
+				pws[2]: {},
+			},
+		},
+		{
+			// This tombstone must be dropped, since it refers only to inmemory part.
+			metricIDs: map[uint64]struct{}{
+				3: {},
+			},
+			pws: map[*partWrapper]struct{}{
+				pws[2]: {},
+			},
+		},
+	}
+	data := marshalTombstones(nil, tss)
+	tssUnmarshaled, err := unmarshalTombstones(data, pwsByName)
+	if err != nil {
+		t.Fatalf("cannot unmarshal tombstones: %s", err)
+	}
+	tssExpected := []*tombstone{
+		{
+			metricIDs: tss[0].metricIDs,
+			tr:        tss[0].tr,
+			pws: map[*partWrapper]struct{}{
+				pws[0]: {},
+				pws[1]: {},
+			},
+		},
+	}
+	if !reflect.DeepEqual(tssUnmarshaled, tssExpected) {
+		t.Fatalf("unexpected tombstones;\ngot\n%+v\nwant\n%+v", tssUnmarshaled, tssExpected)
+	}
+
+	// Missing parts must be dropped from tombstones.
+	tssUnmarshaled, err = unmarshalTombstones(data, nil)
+	if err != nil {
+		t.Fatalf("cannot unmarshal tombstones without parts: %s", err)
+	}
+	if len(tssUnmarshaled) != 0 {
+		t.Fatalf("expecting zero tombstones for missing parts; got %d", len(tssUnmarshaled))
+	}
+
+	// Truncated data must result in error.
+	if _, err := unmarshalTombstones(data[:len(data)-1], pwsByName); err == nil {
+		t.Fatalf("expecting non-nil error for truncated data")
+	}
+}
+
+func TestDeleteTombstonedRows(t *testing.T) {
+	var b Block
+	tsid := &TSID{
+		MetricID: 42,
+	}
+	timestamps := []int64{10, 20, 30, 40, 50}
+	values := []int64{1, 2, 3, 4, 5}
+	b.Init(tsid, timestamps, values, 0, defaultPrecisionBits)
+	b.MarshalData(0, 0)
+
+	tss := []*tombstone{
+		{
+			// Tombstone for another metric must be ignored.
+			metricIDs: map[uint64]struct{}{
+				1: {},
+			},
+			tr: TimeRange{
+				MinTimestamp: 0,
+				MaxTimestamp: 100,
+			},
+		},
+		{
+			metricIDs: map[uint64]struct{}{
+				42: {},
+			},
+			tr: TimeRange{
+				MinTimestamp: 15,
+				MaxTimestamp: 30,
+			},
+		},
+	}
+	n, err := deleteTombstonedRows(&b, tss)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 2 {
+		t.Fatalf("unexpected number of deleted rows; got %d; want %d", n, 2)
+	}
+	if b.RowsCount() != 3 {
+		t.Fatalf("unexpected number of rows left; got %d; want %d", b.RowsCount(), 3)
+	}
+	timestampsExpected := []int64{10, 40, 50}
+	if !reflect.DeepEqual(b.Timestamps(), timestampsExpected) {
+		t.Fatalf("unexpected timestamps; got %d; want %d", b.Timestamps(), timestampsExpected)
+	}
+	valuesExpected := []int64{1, 4, 5}
+	if !reflect.DeepEqual(b.Values(), valuesExpected) {
+		t.Fatalf("unexpected values; got %d; want %d", b.Values(), valuesExpected)
+	}
+	if b.bh.MinTimestamp != 10 || b.bh.MaxTimestamp != 50 {
+		t.Fatalf("unexpected time range in block header; got [%d..%d]; want [%d..%d]", b.bh.MinTimestamp, b.bh.MaxTimestamp, 10, 50)
+	}
+
+	// Delete the remaining rows.
+	tss[1].tr.MaxTimestamp = 100
+	n, err = deleteTombstonedRows(&b, tss)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 2 {
+		t.Fatalf("unexpected number of deleted rows; got %d; want %d", n, 2)
+	}
+	tss[1].tr.MinTimestamp = 0
+	n, err = deleteTombstonedRows(&b, tss)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 1 {
+		t.Fatalf("unexpected number of deleted rows; got %d; want %d", n, 1)
+	}
+	if b.RowsCount() != 0 {
+		t.Fatalf("unexpected number of rows left; got %d; want %d", b.RowsCount(), 0)
+	}
+}
@@ -10,10 +10,18 @@ import (
 //
 // Time series blocks are sorted by TSID.
 //
-// All the fields except MetricID are optional. They exist solely for better
-// grouping of related metrics.
+// All the fields except AccountID, ProjectID and MetricID are optional.
+// They exist solely for better grouping of related metrics.
 // It is OK if their meaning differ from their naming.
 type TSID struct {
+	// AccountID is the id of the registered account.
+	AccountID uint32
+
+	// ProjectID is the id of the project.
+	//
+	// The ProjectID must be unique for the given AccountID.
+	ProjectID uint32
+
 	// MetricGroupID is the id of metric group inside the given project.
 	//
 	// MetricGroupID must be unique.
@@ -61,6 +69,8 @@ var marshaledTSIDSize = func() int {
 
 // Marshal appends marshaled t to dst and returns the result.
 func (t *TSID) Marshal(dst []byte) []byte {
+	dst = encoding.MarshalUint32(dst, t.AccountID)
+	dst = encoding.MarshalUint32(dst, t.ProjectID)
 	dst = encoding.MarshalUint64(dst, t.MetricGroupID)
 	dst = encoding.MarshalUint32(dst, t.JobID)
 	dst = encoding.MarshalUint32(dst, t.InstanceID)
@@ -74,6 +84,10 @@ func (t *TSID) Unmarshal(src []byte) ([]byte, error) {
 		return nil, fmt.Errorf("too short src; got %d bytes; want %d bytes", len(src), marshaledTSIDSize)
 	}
 
+	t.AccountID = encoding.UnmarshalUint32(src)
+	src = src[4:]
+	t.ProjectID = encoding.UnmarshalUint32(src)
+	src = src[4:]
 	t.MetricGroupID = encoding.UnmarshalUint64(src)
 	src = src[8:]
 	t.JobID = encoding.UnmarshalUint32(src)
@@ -93,6 +107,18 @@ func (t *TSID) Less(b *TSID) bool {
 		return false
 	}
 
+	if t.AccountID < b.AccountID {
+		return true
+	}
+	if t.AccountID > b.AccountID {
+		return false
+	}
+	if t.ProjectID < b.ProjectID {
+		return true
+	}
+	if t.ProjectID > b.ProjectID {
+		return false
+	}
 	if t.MetricGroupID < b.MetricGroupID {
 		return true
 	}
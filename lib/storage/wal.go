@@ -0,0 +1,492 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// WALSyncPolicy defines when the data written to write-ahead log is synced to disk.
+type WALSyncPolicy int
+
+const (
+	// WALSyncAlways syncs the write-ahead log after every write.
+	//
+	// This guarantees the added rows survive power loss, but slows down data ingestion.
+	WALSyncAlways WALSyncPolicy = iota
+
+	// WALSyncInterval syncs the write-ahead log every syncInterval passed to SetWAL.
+	//
+	// Rows added during the last syncInterval may be lost on power loss.
+	WALSyncInterval
+
+	// WALSyncNone never syncs the write-ahead log, so the OS decides when to write it to disk.
+	//
+	// The added rows survive process crash, but may be lost on power loss.
+	WALSyncNone
+)
+
+// ParseWALSyncPolicy parses WALSyncPolicy from s.
+//
+// s may be `always`, `interval` or `none`.
+func ParseWALSyncPolicy(s string) (WALSyncPolicy, error) {
+	switch s {
+	case "always":
+		return WALSyncAlways, nil
+	case "interval":
+		return WALSyncInterval, nil
+	case "none":
+		return WALSyncNone, nil
+	default:
+		return 0, fmt.Errorf("unsupported WAL sync policy %q; supported values: always, interval, none", s)
+	}
+}
+
+// SetWAL enables write-ahead log for the rows added to the storage.
+//
+// The write-ahead log is replayed on OpenStorage, so rows buffered in memory
+// survive unclean shutdown. syncInterval is used only for WALSyncInterval policy.
+//
+// This function must be called before opening the storage.
+func SetWAL(syncPolicy WALSyncPolicy, syncInterval time.Duration) {
+	walEnabled = true
+	walSyncPolicy = syncPolicy
+	walSyncInterval = syncInterval
+}
+
+var (
+	walEnabled      bool
+	walSyncPolicy   WALSyncPolicy
+	walSyncInterval time.Duration
+)
+
+// walCheckpointInterval is the interval for making write-ahead log checkpoints.
+//
+// Each checkpoint flushes rows buffered in memory to disk and then removes
+// write-ahead log segments containing these rows.
+const walCheckpointInterval = 10 * time.Second
+
+// walRecordHeaderSize is the size of the header for each record in write-ahead log segment.
+//
+// The header contains the record size and crc32 checksum.
+const walRecordHeaderSize = 8
+
+// maxWALRecordSize is the maximum size of a single record in write-ahead log segment.
+const maxWALRecordSize = 256 * 1024 * 1024
+
+// wal is write-ahead log for the rows added to the storage.
+//
+// It consists of segments. Rows are appended to the last segment.
+// Other segments are sealed and are removed after the rows
+// from these segments are flushed to disk.
+type wal struct {
+	// Atomic counters must go at the top of the structure in order to properly align by 8 bytes on 32-bit archs.
+	segmentsCount uint64
+	sizeBytes     uint64
+
+	path string
+
+	syncPolicy WALSyncPolicy
+
+	// mu protects the fields below.
+	mu sync.Mutex
+
+	f          *os.File
+	segmentIdx uint64
+	needSync   bool
+
+	// buf is used for marshaling records.
+	buf []byte
+
+	// compressBuf is used for compressing records.
+	compressBuf []byte
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func mustOpenWAL(path string, segmentIdx uint64, syncPolicy WALSyncPolicy, syncInterval time.Duration) *wal {
+	w := &wal{
+		path:       path,
+		syncPolicy: syncPolicy,
+		stopCh:     make(chan struct{}),
+	}
+	w.mu.Lock()
+	w.mustCreateSegmentLocked(segmentIdx)
+	w.mu.Unlock()
+	if syncPolicy == WALSyncInterval {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			w.syncer(syncInterval)
+		}()
+	}
+	return w
+}
+
+func (w *wal) syncer(syncInterval time.Duration) {
+	t := time.NewTicker(syncInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case <-t.C:
+			w.mu.Lock()
+			w.mustSyncLocked()
+			w.mu.Unlock()
+		}
+	}
+}
+
+// mustWrite writes mrs with the given precisionBits to w.
+func (w *wal) mustWrite(mrs []MetricRow, precisionBits uint8) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = marshalWALRows(w.buf[:0], mrs, precisionBits)
+	w.compressBuf = encoding.CompressZSTDLevel(w.compressBuf[:0], w.buf, 1)
+	payload := w.compressBuf
+	if len(payload) > maxWALRecordSize {
+		logger.Panicf("BUG: too big WAL record for %d rows: %d bytes; mustn't exceed %d bytes", len(mrs), len(payload), maxWALRecordSize)
+	}
+	var header [walRecordHeaderSize]byte
+	encoding.MarshalUint32(header[:0], uint32(len(payload)))
+	encoding.MarshalUint32(header[4:4], crc32.ChecksumIEEE(payload))
+	fs.MustWriteData(w.f, header[:])
+	fs.MustWriteData(w.f, payload)
+	atomic.AddUint64(&w.sizeBytes, uint64(len(header)+len(payload)))
+
+	w.needSync = true
+	if w.syncPolicy == WALSyncAlways {
+		w.mustSyncLocked()
+	}
+}
+
+func (w *wal) mustSyncLocked() {
+	if !w.needSync || w.syncPolicy == WALSyncNone {
+		return
+	}
+	if err := w.f.Sync(); err != nil {
+		logger.Panicf("FATAL: cannot sync WAL segment %q: %s", w.f.Name(), err)
+	}
+	w.needSync = false
+}
+
+// mustRotate seals the current segment and starts a new one.
+//
+// It returns the index of the sealed segment. The caller must make sure
+// there are no concurrent mustWrite calls for rows, which must go to the sealed segment.
+func (w *wal) mustRotate() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	sealedIdx := w.segmentIdx
+	w.mustSyncLocked()
+	fs.MustClose(w.f)
+	w.mustCreateSegmentLocked(sealedIdx + 1)
+	return sealedIdx
+}
+
+func (w *wal) mustCreateSegmentLocked(segmentIdx uint64) {
+	path := w.segmentPath(segmentIdx)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		logger.Panicf("FATAL: cannot create WAL segment: %s", err)
+	}
+	if w.syncPolicy != WALSyncNone {
+		// Sync the WAL directory, so the segment is guaranteed to appear in it.
+		fs.MustSyncPath(w.path)
+	}
+	w.f = f
+	w.segmentIdx = segmentIdx
+	w.needSync = false
+	atomic.AddUint64(&w.segmentsCount, 1)
+}
+
+// mustRemoveSegments removes segments with indexes up to maxSegmentIdx.
+//
+// The rows from these segments must be already flushed to disk.
+func (w *wal) mustRemoveSegments(maxSegmentIdx uint64) {
+	segmentsCount, sizeBytes := mustRemoveWALSegments(w.path, maxSegmentIdx, w.syncPolicy != WALSyncNone)
+	atomic.AddUint64(&w.segmentsCount, ^(segmentsCount - 1))
+	atomic.AddUint64(&w.sizeBytes, ^(sizeBytes - 1))
+}
+
+// mustRemoveWALSegments removes segments with indexes up to maxSegmentIdx at the given WAL path.
+//
+// It returns the number of removed segments and their total size in bytes.
+func mustRemoveWALSegments(path string, maxSegmentIdx uint64, needSync bool) (uint64, uint64) {
+	segmentIdxs, err := readWALSegmentIdxs(path)
+	if err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
+	segmentsCount := uint64(0)
+	sizeBytes := uint64(0)
+	for _, segmentIdx := range segmentIdxs {
+		if segmentIdx > maxSegmentIdx {
+			continue
+		}
+		segmentPath := walSegmentPath(path, segmentIdx)
+		sizeBytes += fs.MustFileSize(segmentPath)
+		if err := os.Remove(segmentPath); err != nil {
+			logger.Panicf("FATAL: cannot remove WAL segment: %s", err)
+		}
+		segmentsCount++
+	}
+	if needSync {
+		fs.MustSyncPath(path)
+	}
+	return segmentsCount, sizeBytes
+}
+
+// mustClose closes w and removes all its segments.
+//
+// The rows written to w must be already flushed to disk.
+func (w *wal) mustClose() {
+	close(w.stopCh)
+	w.wg.Wait()
+
+	w.mu.Lock()
+	fs.MustClose(w.f)
+	w.f = nil
+	segmentIdx := w.segmentIdx
+	w.mu.Unlock()
+
+	w.mustRemoveSegments(segmentIdx)
+}
+
+func (w *wal) segmentPath(segmentIdx uint64) string {
+	return walSegmentPath(w.path, segmentIdx)
+}
+
+func walSegmentPath(path string, segmentIdx uint64) string {
+	return fmt.Sprintf("%s/%016X", path, segmentIdx)
+}
+
+// readWALSegmentIdxs returns sorted indexes of segments at the given WAL path.
+func readWALSegmentIdxs(path string) ([]uint64, error) {
+	fis, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read WAL directory: %s", err)
+	}
+	var segmentIdxs []uint64
+	for _, fi := range fis {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		segmentIdx, err := strconv.ParseUint(fi.Name(), 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected file %q in WAL directory %q: %s", fi.Name(), path, err)
+		}
+		segmentIdxs = append(segmentIdxs, segmentIdx)
+	}
+	sort.Slice(segmentIdxs, func(i, j int) bool { return segmentIdxs[i] < segmentIdxs[j] })
+	return segmentIdxs, nil
+}
+
+func marshalWALRows(dst []byte, mrs []MetricRow, precisionBits uint8) []byte {
+	dst = append(dst, precisionBits)
+	dst = encoding.MarshalVarUint64(dst, uint64(len(mrs)))
+	for i := range mrs {
+		mr := &mrs[i]
+		dst = encoding.MarshalBytes(dst, mr.MetricNameRaw)
+		dst = encoding.MarshalInt64(dst, mr.Timestamp)
+		dst = encoding.MarshalUint64(dst, math.Float64bits(mr.Value))
+	}
+	return dst
+}
+
+// unmarshalWALRows unmarshals rows marshaled with marshalWALRows from src and appends them to dst.
+//
+// MetricNameRaw in the returned rows refers to src.
+func unmarshalWALRows(dst []MetricRow, src []byte) ([]MetricRow, uint8, error) {
+	if len(src) < 1 {
+		return dst, 0, fmt.Errorf("missing precisionBits")
+	}
+	precisionBits := src[0]
+	if err := encoding.CheckPrecisionBits(precisionBits); err != nil {
+		return dst, 0, err
+	}
+	tail, rowsCount, err := encoding.UnmarshalVarUint64(src[1:])
+	if err != nil {
+		return dst, 0, fmt.Errorf("cannot unmarshal rows count: %s", err)
+	}
+	src = tail
+	for i := uint64(0); i < rowsCount; i++ {
+		tail, metricNameRaw, err := encoding.UnmarshalBytes(src)
+		if err != nil {
+			return dst, 0, fmt.Errorf("cannot unmarshal MetricNameRaw: %s", err)
+		}
+		src = tail
+		if len(src) < 16 {
+			return dst, 0, fmt.Errorf("cannot unmarshal timestamp and value from %d bytes; need at least 16 bytes", len(src))
+		}
+		dst = append(dst, MetricRow{
+			MetricNameRaw: metricNameRaw,
+			Timestamp:     encoding.UnmarshalInt64(src),
+			Value:         math.Float64frombits(encoding.UnmarshalUint64(src[8:])),
+		})
+		src = src[16:]
+	}
+	if len(src) > 0 {
+		return dst, 0, fmt.Errorf("unexpected tail left after unmarshaling %d rows; len(tail)=%d", rowsCount, len(src))
+	}
+	return dst, precisionBits, nil
+}
+
+// replayWALSegment calls f for each batch of rows stored in the WAL segment at the given path.
+//
+// The segment may have a truncated or corrupted tail after unclean shutdown.
+// In this case the rows up to the tail are replayed and an error is returned.
+func replayWALSegment(path string, f func(mrs []MetricRow, precisionBits uint8)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot open WAL segment: %s", err)
+	}
+	defer fs.MustClose(file)
+
+	br := bufio.NewReaderSize(file, 64*1024)
+	var header [walRecordHeaderSize]byte
+	var payload, buf []byte
+	var mrs []MetricRow
+	for {
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("cannot read record header from WAL segment %q: %s", path, err)
+		}
+		size := encoding.UnmarshalUint32(header[:])
+		checksum := encoding.UnmarshalUint32(header[4:])
+		if size > maxWALRecordSize {
+			return fmt.Errorf("too big record size in WAL segment %q: %d bytes; mustn't exceed %d bytes", path, size, maxWALRecordSize)
+		}
+		if uint32(cap(payload)) < size {
+			payload = make([]byte, size)
+		}
+		payload = payload[:size]
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return fmt.Errorf("cannot read record with size %d bytes from WAL segment %q: %s", size, path, err)
+		}
+		if crc32.ChecksumIEEE(payload) != checksum {
+			return fmt.Errorf("checksum mismatch for record with size %d bytes in WAL segment %q", size, path)
+		}
+		buf, err = encoding.DecompressZSTD(buf[:0], payload)
+		if err != nil {
+			return fmt.Errorf("cannot decompress record from WAL segment %q: %s", path, err)
+		}
+		var precisionBits uint8
+		mrs, precisionBits, err = unmarshalWALRows(mrs[:0], buf)
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal record from WAL segment %q: %s", path, err)
+		}
+		f(mrs, precisionBits)
+	}
+}
+
+// mustStartWAL replays the write-ahead log for s, flushes the replayed rows to disk
+// and starts new write-ahead log.
+//
+// It must be called after s is fully initialized.
+func (s *Storage) mustStartWAL() {
+	walPath := s.path + "/wal"
+	if err := fs.MkdirAllIfNotExist(walPath); err != nil {
+		logger.Panicf("FATAL: cannot create WAL directory %q: %s", walPath, err)
+	}
+	segmentIdxs, err := readWALSegmentIdxs(walPath)
+	if err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
+	nextSegmentIdx := uint64(0)
+	if len(segmentIdxs) > 0 {
+		logger.Infof("replaying %d WAL segments from %q...", len(segmentIdxs), walPath)
+		startTime := time.Now()
+		rowsReplayed := 0
+		var rows []rawRow
+		for _, segmentIdx := range segmentIdxs {
+			path := walSegmentPath(walPath, segmentIdx)
+			err := replayWALSegment(path, func(mrs []MetricRow, precisionBits uint8) {
+				var err error
+				rows, err = s.add(rows[:0], mrs, precisionBits)
+				if err != nil {
+					logger.Errorf("error when replaying rows from WAL segment %q: %s", path, err)
+				}
+				rowsReplayed += len(mrs)
+			})
+			if err != nil {
+				// This is expected for the last segment after unclean shutdown.
+				logger.Errorf("skipping the remaining data from WAL segment: %s", err)
+			}
+		}
+		s.mustFlushToDisk()
+		logger.Infof("replayed %d rows from %d WAL segments in %s", rowsReplayed, len(segmentIdxs), time.Since(startTime))
+
+		// The replayed rows are already flushed to disk, so the replayed segments may be removed.
+		// Continue segment numbering, so new segments never clash with partially removed old segments.
+		nextSegmentIdx = segmentIdxs[len(segmentIdxs)-1] + 1
+		mustRemoveWALSegments(walPath, nextSegmentIdx-1, true)
+	}
+
+	s.wal = mustOpenWAL(walPath, nextSegmentIdx, walSyncPolicy, walSyncInterval)
+
+	s.walCheckpointerWG.Add(1)
+	go func() {
+		defer s.walCheckpointerWG.Done()
+		s.walCheckpointer()
+	}()
+}
+
+func (s *Storage) walCheckpointer() {
+	t := time.NewTicker(walCheckpointInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+			s.walCheckpoint()
+		}
+	}
+}
+
+// walCheckpoint flushes rows from the sealed WAL segments to disk and then removes these segments.
+func (s *Storage) walCheckpoint() {
+	s.walCheckpointLock.Lock()
+	defer s.walCheckpointLock.Unlock()
+
+	// Wait until the rows written to the current segment are added to the table.
+	s.walLock.Lock()
+	sealedIdx := s.wal.mustRotate()
+	s.walLock.Unlock()
+
+	s.mustFlushToDisk()
+	s.wal.mustRemoveSegments(sealedIdx)
+}
+
+// mustStopWAL flushes all the added rows to disk and then removes the write-ahead log for s.
+//
+// Rows mustn't be added to s after the call.
+func (s *Storage) mustStopWAL() {
+	s.mustFlushToDisk()
+	s.wal.mustClose()
+}
+
+// mustFlushToDisk flushes all the rows added to s to disk.
+func (s *Storage) mustFlushToDisk() {
+	s.idb().tb.MustFlush()
+	s.tb.mustFlushToDisk()
+}
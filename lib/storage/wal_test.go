@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+)
+
+func TestParseWALSyncPolicy(t *testing.T) {
+	f := func(s string, policyExpected WALSyncPolicy) {
+		t.Helper()
+		policy, err := ParseWALSyncPolicy(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if policy != policyExpected {
+			t.Fatalf("unexpected policy for %q; got %d; want %d", s, policy, policyExpected)
+		}
+	}
+	f("always", WALSyncAlways)
+	f("interval", WALSyncInterval)
+	f("none", WALSyncNone)
+
+	if _, err := ParseWALSyncPolicy("foobar"); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestWALRowsMarshalUnmarshal(t *testing.T) {
+	mrs := newTestWALRows(10)
+	data := marshalWALRows(nil, mrs, 12)
+	mrs2, precisionBits, err := unmarshalWALRows(nil, data)
+	if err != nil {
+		t.Fatalf("cannot unmarshal rows: %s", err)
+	}
+	if precisionBits != 12 {
+		t.Fatalf("unexpected precisionBits; got %d; want 12", precisionBits)
+	}
+	if !reflect.DeepEqual(mrs2, mrs) {
+		t.Fatalf("unexpected rows\ngot\n%+v\nwant\n%+v", mrs2, mrs)
+	}
+
+	// Truncated data
+	for i := 0; i < len(data); i++ {
+		if _, _, err := unmarshalWALRows(nil, data[:i]); err == nil {
+			t.Fatalf("expecting non-nil error when unmarshaling %d bytes out of %d bytes", i, len(data))
+		}
+	}
+}
+
+func TestReplayWALSegment(t *testing.T) {
+	path := "TestReplayWALSegment"
+	if err := fs.MkdirAllIfNotExist(path); err != nil {
+		t.Fatalf("cannot create %q: %s", path, err)
+	}
+	defer fs.MustRemoveAll(path)
+
+	w := mustOpenWAL(path, 0, WALSyncAlways, 0)
+	mrs := newTestWALRows(100)
+	w.mustWrite(mrs[:40], 10)
+	w.mustWrite(mrs[40:], 20)
+	sealedIdx := w.mustRotate()
+
+	replay := func() ([]MetricRow, error) {
+		t.Helper()
+		var mrsReplayed []MetricRow
+		err := replayWALSegment(walSegmentPath(path, sealedIdx), func(mrs []MetricRow, precisionBits uint8) {
+			if len(mrsReplayed) == 0 && precisionBits != 10 || len(mrsReplayed) > 0 && precisionBits != 20 {
+				t.Fatalf("unexpected precisionBits=%d after replaying %d rows", precisionBits, len(mrsReplayed))
+			}
+			for _, mr := range mrs {
+				mr.MetricNameRaw = append([]byte{}, mr.MetricNameRaw...)
+				mrsReplayed = append(mrsReplayed, mr)
+			}
+		})
+		return mrsReplayed, err
+	}
+	mrsReplayed, err := replay()
+	if err != nil {
+		t.Fatalf("cannot replay WAL segment: %s", err)
+	}
+	if !reflect.DeepEqual(mrsReplayed, mrs) {
+		t.Fatalf("unexpected rows replayed\ngot\n%+v\nwant\n%+v", mrsReplayed, mrs)
+	}
+
+	// Truncate the last record, like after unclean shutdown.
+	segmentPath := walSegmentPath(path, sealedIdx)
+	if err := os.Truncate(segmentPath, int64(fs.MustFileSize(segmentPath)-1)); err != nil {
+		t.Fatalf("cannot truncate WAL segment: %s", err)
+	}
+	mrsReplayed, err = replay()
+	if err == nil {
+		t.Fatalf("expecting non-nil error when replaying truncated WAL segment")
+	}
+	if !reflect.DeepEqual(mrsReplayed, mrs[:40]) {
+		t.Fatalf("unexpected rows replayed from truncated WAL segment\ngot\n%+v\nwant\n%+v", mrsReplayed, mrs[:40])
+	}
+
+	w.mustClose()
+	segmentIdxs, err := readWALSegmentIdxs(path)
+	if err != nil {
+		t.Fatalf("cannot read WAL segments: %s", err)
+	}
+	if len(segmentIdxs) > 0 {
+		t.Fatalf("WAL segments must be removed on close; got %d segments", len(segmentIdxs))
+	}
+}
+
+func TestStorageWALReplay(t *testing.T) {
+	walEnabledOrig := walEnabled
+	defer func() {
+		walEnabled = walEnabledOrig
+	}()
+	SetWAL(WALSyncInterval, time.Second)
+
+	path := "TestStorageWALReplay"
+	walPath := path + "/wal"
+	if err := fs.MkdirAllIfNotExist(walPath); err != nil {
+		t.Fatalf("cannot create %q: %s", walPath, err)
+	}
+	defer fs.MustRemoveAll(path)
+
+	// Write rows to WAL and leave it as is, like after unclean shutdown.
+	w := mustOpenWAL(walPath, 0, WALSyncNone, 0)
+	mrs := newTestWALRows(1000)
+	w.mustWrite(mrs, defaultPrecisionBits)
+	close(w.stopCh)
+	fs.MustClose(w.f)
+
+	s, err := OpenStorage(path, 0)
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if rowsCount := m.TableMetrics.SmallRowsCount + m.TableMetrics.BigRowsCount; rowsCount != uint64(len(mrs)) {
+		t.Fatalf("unexpected number of rows after WAL replay; got %d; want %d", rowsCount, len(mrs))
+	}
+	if m.WALSegmentsCount != 1 {
+		t.Fatalf("unexpected number of WAL segments after replay; got %d; want 1", m.WALSegmentsCount)
+	}
+
+	// New rows must be written to WAL.
+	if err := s.AddRows(mrs[:10], defaultPrecisionBits); err != nil {
+		t.Fatalf("cannot add rows: %s", err)
+	}
+	m.Reset()
+	s.UpdateMetrics(&m)
+	if m.WALSizeBytes == 0 {
+		t.Fatalf("WAL size mustn't be zero after adding rows")
+	}
+
+	s.MustClose()
+	segmentIdxs, err := readWALSegmentIdxs(walPath)
+	if err != nil {
+		t.Fatalf("cannot read WAL segments: %s", err)
+	}
+	if len(segmentIdxs) > 0 {
+		t.Fatalf("WAL segments must be removed on storage close; got %d segments", len(segmentIdxs))
+	}
+}
+
+func TestStorageDeleteSamplesWAL(t *testing.T) {
+	walEnabledOrig := walEnabled
+	defer func() {
+		walEnabled = walEnabledOrig
+	}()
+	SetWAL(WALSyncNone, 0)
+
+	path := "TestStorageDeleteSamplesWAL"
+	s := mustOpenTestStorage(t, path, 0)
+	var mn MetricName
+	mn.MetricGroup = []byte("foo")
+	now := timestampFromTime(time.Now())
+	var mrs []MetricRow
+	for i := 0; i < 100; i++ {
+		mrs = append(mrs, newTestMetricRow(&mn, now-int64(i)*1000, float64(i)))
+	}
+	mustAddTestRows(t, s, mrs)
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if m.WALSizeBytes == 0 {
+		t.Fatalf("WAL size mustn't be zero after adding rows")
+	}
+
+	tfs := NewTagFilters(0, 0)
+	if err := tfs.Add(nil, []byte("foo"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	tr := TimeRange{
+		MinTimestamp: now - 3600*1000,
+		MaxTimestamp: now,
+	}
+	deletedCount, err := s.DeleteSamples([]*TagFilters{tfs}, tr)
+	if err != nil {
+		t.Fatalf("cannot delete samples: %s", err)
+	}
+	if deletedCount != 1 {
+		t.Fatalf("unexpected number of metrics with deleted samples; got %d; want 1", deletedCount)
+	}
+
+	// The deleted rows mustn't remain in WAL, since otherwise they are replayed
+	// after unclean shutdown, while the tombstones for inmemory parts aren't persisted.
+	m.Reset()
+	s.UpdateMetrics(&m)
+	if m.WALSizeBytes != 0 {
+		t.Fatalf("WAL must be empty after deleting samples; got %d bytes", m.WALSizeBytes)
+	}
+	walPath := path + "/wal"
+	segmentIdxs, err := readWALSegmentIdxs(walPath)
+	if err != nil {
+		t.Fatalf("cannot read WAL segments: %s", err)
+	}
+	for _, segmentIdx := range segmentIdxs {
+		if n := fs.MustFileSize(walSegmentPath(walPath, segmentIdx)); n > 0 {
+			t.Fatalf("unexpected non-empty WAL segment %d with %d bytes after deleting samples", segmentIdx, n)
+		}
+	}
+
+	mustCloseTestStorage(t, s, path)
+}
+
+func newTestWALRows(n int) []MetricRow {
+	now := timestampFromTime(time.Now())
+	mrs := make([]MetricRow, n)
+	for i := range mrs {
+		var mn MetricName
+		mn.MetricGroup = []byte(fmt.Sprintf("metric_%d", i%10))
+		mn.AddTag("instance", fmt.Sprintf("host_%d", i))
+		mrs[i] = MetricRow{
+			MetricNameRaw: mn.marshalRaw(nil),
+			Timestamp:     now - int64(i)*1000,
+			Value:         float64(i),
+		}
+	}
+	return mrs
+}
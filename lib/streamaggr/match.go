@@ -0,0 +1,193 @@
+package streamaggr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+)
+
+// matcher matches series by Prometheus series selector such as `metric{label="value",other=~"re.*"}`.
+type matcher struct {
+	filters []labelFilter
+}
+
+// labelFilter is a single filter from series selector.
+type labelFilter struct {
+	name       string
+	value      string
+	re         *regexp.Regexp
+	isNegative bool
+}
+
+// newMatcher returns matcher for the given series selector s.
+func newMatcher(s string) (*matcher, error) {
+	src := strings.TrimSpace(s)
+	var filters []labelFilter
+	n := 0
+	for n < len(src) && isIdentChar(src[n], n == 0) {
+		n++
+	}
+	if n > 0 {
+		filters = append(filters, labelFilter{
+			name:  "__name__",
+			value: src[:n],
+		})
+		src = strings.TrimSpace(src[n:])
+	}
+	if len(src) > 0 {
+		if src[0] != '{' || src[len(src)-1] != '}' {
+			return nil, fmt.Errorf("cannot parse series selector %q: label filters must be enclosed in curly braces", s)
+		}
+		fs, err := parseLabelFilters(src[1 : len(src)-1])
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse series selector %q: %s", s, err)
+		}
+		filters = append(filters, fs...)
+	}
+	if len(filters) == 0 {
+		return nil, fmt.Errorf("series selector cannot be empty")
+	}
+	return &matcher{
+		filters: filters,
+	}, nil
+}
+
+func parseLabelFilters(src string) ([]labelFilter, error) {
+	var filters []labelFilter
+	for {
+		src = strings.TrimSpace(src)
+		if len(src) == 0 {
+			return filters, nil
+		}
+		n := 0
+		for n < len(src) && isIdentChar(src[n], n == 0) {
+			n++
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("missing label name in front of %q", src)
+		}
+		var lf labelFilter
+		lf.name = src[:n]
+		src = strings.TrimSpace(src[n:])
+
+		isRegexp := false
+		switch {
+		case strings.HasPrefix(src, "=~"):
+			isRegexp = true
+			src = src[2:]
+		case strings.HasPrefix(src, "!~"):
+			isRegexp = true
+			lf.isNegative = true
+			src = src[2:]
+		case strings.HasPrefix(src, "!="):
+			lf.isNegative = true
+			src = src[2:]
+		case strings.HasPrefix(src, "="):
+			src = src[1:]
+		default:
+			return nil, fmt.Errorf("missing operator after label name %q", lf.name)
+		}
+
+		value, tail, err := parseQuotedString(strings.TrimSpace(src))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse value for label %q: %s", lf.name, err)
+		}
+		lf.value = value
+		if isRegexp {
+			// Prometheus anchors regexps at both ends.
+			re, err := regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse regexp for label %q: %s", lf.name, err)
+			}
+			lf.re = re
+		}
+		filters = append(filters, lf)
+
+		src = strings.TrimSpace(tail)
+		if len(src) == 0 {
+			return filters, nil
+		}
+		if src[0] != ',' {
+			return nil, fmt.Errorf("missing comma after the filter for label %q", lf.name)
+		}
+		src = src[1:]
+	}
+}
+
+func parseQuotedString(src string) (string, string, error) {
+	if len(src) == 0 || (src[0] != '"' && src[0] != '\'' && src[0] != '`') {
+		return "", src, fmt.Errorf("missing quoted string in front of %q", src)
+	}
+	quote := src[0]
+	n := 1
+	for n < len(src) && src[n] != quote {
+		if src[n] == '\\' && quote != '`' {
+			n++
+		}
+		n++
+	}
+	if n >= len(src) {
+		return "", src, fmt.Errorf("missing closing quote in %q", src)
+	}
+	s := src[:n+1]
+	if quote == '\'' {
+		// Convert single-quoted string into double-quoted string, so it may be unquoted with strconv.Unquote.
+		s = `"` + strings.Replace(strings.Replace(s[1:n], `\'`, `'`, -1), `"`, `\"`, -1) + `"`
+	}
+	value, err := strconv.Unquote(s)
+	if err != nil {
+		return "", src, fmt.Errorf("cannot unquote %s: %s", src[:n+1], err)
+	}
+	return value, src[n+1:], nil
+}
+
+func isIdentChar(c byte, isFirst bool) bool {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' {
+		return true
+	}
+	return !isFirst && c >= '0' && c <= '9'
+}
+
+// Match returns true if labels match m.
+//
+// Empty label name in labels is treated as `__name__`.
+// Missing labels are treated as labels with empty values like Prometheus does.
+func (m *matcher) Match(labels []prompb.Label) bool {
+	for i := range m.filters {
+		if !m.filters[i].match(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (lf *labelFilter) match(labels []prompb.Label) bool {
+	value := getLabelValue(labels, lf.name)
+	var ok bool
+	if lf.re != nil {
+		ok = lf.re.MatchString(value)
+	} else {
+		ok = value == lf.value
+	}
+	return ok != lf.isNegative
+}
+
+var metricNameLabel = []byte("__name__")
+
+func getLabelValue(labels []prompb.Label, name string) string {
+	for i := range labels {
+		label := &labels[i]
+		labelName := label.Name
+		if len(labelName) == 0 {
+			labelName = metricNameLabel
+		}
+		if string(labelName) == name {
+			return bytesutil.ToUnsafeString(label.Value)
+		}
+	}
+	return ""
+}
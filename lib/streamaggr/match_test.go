@@ -0,0 +1,67 @@
+package streamaggr
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+)
+
+func TestMatcherMatch(t *testing.T) {
+	f := func(selector string, labels []prompb.Label, resultExpected bool) {
+		t.Helper()
+		m, err := newMatcher(selector)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %s", selector, err)
+		}
+		result := m.Match(labels)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %q; got %v; want %v", selector, result, resultExpected)
+		}
+	}
+	labels := []prompb.Label{
+		{Name: nil, Value: []byte("http_requests_total")},
+		{Name: []byte("job"), Value: []byte("api")},
+		{Name: []byte("path"), Value: []byte("/foo/bar")},
+	}
+	f("http_requests_total", labels, true)
+	f("http_requests", labels, false)
+	f(`{__name__="http_requests_total"}`, labels, true)
+	f(`http_requests_total{job="api"}`, labels, true)
+	f(`http_requests_total{job="api",}`, labels, true)
+	f(`http_requests_total{job='api'}`, labels, true)
+	f("http_requests_total{job=`api`}", labels, true)
+	f(`http_requests_total{job="db"}`, labels, false)
+	f(`http_requests_total{job!="db"}`, labels, true)
+	f(`http_requests_total{job!="api"}`, labels, false)
+	f(`{__name__=~"http_.+", path=~"/foo/.*"}`, labels, true)
+	f(`{__name__=~"http_.+", path=~"/foo"}`, labels, false)
+	f(`{path!~"/foo/.*"}`, labels, false)
+	f(`{path!~"/baz/.*"}`, labels, true)
+
+	// Missing labels are treated as labels with empty values.
+	f(`http_requests_total{instance=""}`, labels, true)
+	f(`http_requests_total{instance!=""}`, labels, false)
+	f(`http_requests_total{instance=~".*"}`, labels, true)
+}
+
+func TestNewMatcherFailure(t *testing.T) {
+	f := func(selector string) {
+		t.Helper()
+		if _, err := newMatcher(selector); err == nil {
+			t.Fatalf("expecting non-nil error for %q", selector)
+		}
+	}
+	f("")
+	f("{}")
+	f("foo{")
+	f("foo}")
+	f("foo bar")
+	f(`foo{bar}`)
+	f(`foo{bar=}`)
+	f(`foo{bar=baz}`)
+	f(`foo{bar="baz}`)
+	f(`foo{bar=="baz"}`)
+	f(`foo{bar="baz" x="y"}`)
+	f(`foo{bar=~"("}`)
+	f(`foo{="baz"}`)
+}
@@ -0,0 +1,643 @@
+package streamaggr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/valyala/histogram"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is a configuration for a single stream aggregation rule.
+type Config struct {
+	// Match is Prometheus series selector for the input series such as `http_requests_total{env="prod"}`.
+	Match string `yaml:"match"`
+
+	// Interval is the interval between aggregated samples such as `1m`.
+	Interval string `yaml:"interval"`
+
+	// By is an optional list of labels to group input series by like in `sum by (...)`.
+	By []string `yaml:"by"`
+
+	// Without is an optional list of labels to remove from input series like in `sum without (...)`.
+	Without []string `yaml:"without"`
+
+	// StalenessInterval is an optional interval for keeping the state for input series and groups without new samples.
+	//
+	// By default it equals to 2*Interval. It cannot be smaller than Interval.
+	StalenessInterval string `yaml:"staleness_interval"`
+
+	// Outputs is a list of aggregates to calculate for each group of input series.
+	//
+	// Supported outputs: sum, count, min, max, last, rate and quantiles(phi1, ..., phiN).
+	//
+	// sum and count are calculated over input series instead of samples: sum is the sum of the last values
+	// for input series updated during the interval, while count is the number of such series.
+	Outputs []string `yaml:"outputs"`
+}
+
+// PushFunc is called by Aggregators on every flush with the aggregated series for the given tenant.
+//
+// tss mustn't be used after returning from the call.
+type PushFunc func(at *auth.Token, tss []prompb.TimeSeries)
+
+// Aggregators aggregates input samples according to a list of stream aggregation rules.
+type Aggregators struct {
+	as []*aggregator
+}
+
+// LoadFromFile loads Aggregators from YAML file at the given path.
+//
+// The file must contain a list of Config items.
+// The aggregated series are passed to pushFunc on every flush.
+func LoadFromFile(path string, pushFunc PushFunc) (*Aggregators, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read stream aggregation config from %q: %s", path, err)
+	}
+	a, err := NewAggregatorsFromData(data, pushFunc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse stream aggregation config from %q: %s", path, err)
+	}
+	return a, nil
+}
+
+// NewAggregatorsFromData creates Aggregators from YAML data containing a list of Config items.
+//
+// MustStop must be called when the returned Aggregators are no longer needed.
+func NewAggregatorsFromData(data []byte, pushFunc PushFunc) (*Aggregators, error) {
+	var cfgs []*Config
+	if err := yaml.UnmarshalStrict(data, &cfgs); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal data: %s", err)
+	}
+	as := make([]*aggregator, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		ag, err := newAggregator(cfg, pushFunc)
+		if err != nil {
+			for _, ag := range as {
+				ag.mustStop()
+			}
+			return nil, fmt.Errorf("cannot initialize stream aggregation rule #%d: %s", i+1, err)
+		}
+		as = append(as, ag)
+	}
+	return &Aggregators{
+		as: as,
+	}, nil
+}
+
+// Len returns the number of stream aggregation rules in a.
+func (a *Aggregators) Len() int {
+	return len(a.as)
+}
+
+// Push pushes the sample with the given labels for the given tenant to the matching aggregation rules.
+//
+// It returns true if the sample matches at least a single rule.
+//
+// Empty label name is treated as `__name__`. labels aren't modified and may be reused after returning from the call.
+func (a *Aggregators) Push(at *auth.Token, labels []prompb.Label, timestamp int64, value float64) bool {
+	matched := false
+	for _, ag := range a.as {
+		if ag.push(at, labels, timestamp, value) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// MustStop stops a and flushes the data aggregated during the current interval.
+func (a *Aggregators) MustStop() {
+	for _, ag := range a.as {
+		ag.mustStop()
+	}
+}
+
+// aggregator aggregates input samples for a single stream aggregation rule.
+type aggregator struct {
+	match   *matcher
+	by      []string
+	without []string
+	outputs []*output
+
+	// suffix is added to the input metric name in order to obtain the output metric name.
+	suffix string
+
+	// needSeries is set if per-series state must be tracked for outputs.
+	needSeries bool
+
+	// needHistogram is set if the histogram for sample values must be tracked for outputs.
+	needHistogram bool
+
+	// stalenessFlushes is the number of flushes without new samples after which the state
+	// for input series and groups is dropped.
+	stalenessFlushes int
+
+	pushFunc PushFunc
+
+	// mu protects the fields below.
+	mu            sync.Mutex
+	groups        map[string]*aggrGroup
+	lastFlushTime time.Time
+	labelsBuf     []prompb.Label
+	keyBuf        []byte
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newAggregator(cfg *Config, pushFunc PushFunc) (*aggregator, error) {
+	if len(cfg.Match) == 0 {
+		return nil, fmt.Errorf("missing `match` option")
+	}
+	m, err := newMatcher(cfg.Match)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `match` option: %s", err)
+	}
+	if len(cfg.Interval) == 0 {
+		return nil, fmt.Errorf("missing `interval` option")
+	}
+	interval, err := time.ParseDuration(cfg.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `interval` option: %s", err)
+	}
+	if interval < time.Second {
+		return nil, fmt.Errorf("`interval` cannot be smaller than 1s; got %s", interval)
+	}
+	stalenessInterval := 2 * interval
+	if len(cfg.StalenessInterval) > 0 {
+		stalenessInterval, err = time.ParseDuration(cfg.StalenessInterval)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse `staleness_interval` option: %s", err)
+		}
+		if stalenessInterval < interval {
+			return nil, fmt.Errorf("`staleness_interval` cannot be smaller than `interval`=%s; got %s", interval, stalenessInterval)
+		}
+	}
+	stalenessFlushes := int((stalenessInterval + interval - 1) / interval)
+	if len(cfg.By) > 0 && len(cfg.Without) > 0 {
+		return nil, fmt.Errorf("`by` and `without` options cannot be set simultaneously")
+	}
+	if len(cfg.Outputs) == 0 {
+		return nil, fmt.Errorf("missing `outputs` option")
+	}
+	outputs := make([]*output, 0, len(cfg.Outputs))
+	outputNames := make(map[string]bool, len(cfg.Outputs))
+	needSeries := false
+	needHistogram := false
+	for _, s := range cfg.Outputs {
+		out, err := parseOutput(s)
+		if err != nil {
+			return nil, err
+		}
+		if outputNames[out.name] {
+			return nil, fmt.Errorf("duplicate output %q", out.name)
+		}
+		outputNames[out.name] = true
+		switch out.kind {
+		case outputSum, outputCount, outputRate:
+			needSeries = true
+		case outputQuantiles:
+			needHistogram = true
+		}
+		outputs = append(outputs, out)
+	}
+
+	by := sortedStrings(cfg.By)
+	without := sortedStrings(cfg.Without)
+	suffix := ":" + cfg.Interval
+	if len(by) > 0 {
+		suffix += "_by_" + strings.Join(by, "_")
+	}
+	if len(without) > 0 {
+		suffix += "_without_" + strings.Join(without, "_")
+	}
+	suffix += "_"
+
+	ag := &aggregator{
+		match:            m,
+		by:               by,
+		without:          without,
+		outputs:          outputs,
+		suffix:           suffix,
+		needSeries:       needSeries,
+		needHistogram:    needHistogram,
+		stalenessFlushes: stalenessFlushes,
+		pushFunc:         pushFunc,
+		groups:           make(map[string]*aggrGroup),
+		lastFlushTime:    time.Now(),
+		stopCh:           make(chan struct{}),
+	}
+	ag.wg.Add(1)
+	go func() {
+		defer ag.wg.Done()
+		ag.flusher(interval)
+	}()
+	return ag, nil
+}
+
+func sortedStrings(a []string) []string {
+	if len(a) == 0 {
+		return nil
+	}
+	a = append([]string{}, a...)
+	sort.Strings(a)
+	return a
+}
+
+type outputKind int
+
+const (
+	outputSum outputKind = iota
+	outputCount
+	outputMin
+	outputMax
+	outputLast
+	outputRate
+	outputQuantiles
+)
+
+// output is a single aggregate calculated by aggregator.
+type output struct {
+	kind outputKind
+
+	// name is the suffix for the output metric name.
+	name string
+
+	// phis contains quantiles for outputQuantiles.
+	phis    []float64
+	phiStrs []string
+}
+
+func parseOutput(s string) (*output, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "quantiles(") && strings.HasSuffix(s, ")") {
+		args := strings.Split(s[len("quantiles("):len(s)-1], ",")
+		out := &output{
+			kind: outputQuantiles,
+			name: "quantiles",
+		}
+		for _, arg := range args {
+			arg = strings.TrimSpace(arg)
+			phi, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse phi=%q for %q: %s", arg, s, err)
+			}
+			if phi < 0 || phi > 1 {
+				return nil, fmt.Errorf("phi=%q for %q must be in the range [0..1]", arg, s)
+			}
+			out.phis = append(out.phis, phi)
+			out.phiStrs = append(out.phiStrs, strconv.FormatFloat(phi, 'g', -1, 64))
+		}
+		return out, nil
+	}
+	kinds := map[string]outputKind{
+		"sum":   outputSum,
+		"count": outputCount,
+		"min":   outputMin,
+		"max":   outputMax,
+		"last":  outputLast,
+		"rate":  outputRate,
+	}
+	kind, ok := kinds[s]
+	if !ok {
+		return nil, fmt.Errorf("unsupported output %q; supported outputs: sum, count, min, max, last, rate, quantiles(phi1, ..., phiN)", s)
+	}
+	return &output{
+		kind: kind,
+		name: s,
+	}, nil
+}
+
+// aggrGroup contains the aggregate state for a group of input series with identical output labels.
+type aggrGroup struct {
+	at         auth.Token
+	metricName string
+	labels     []prompb.Label
+
+	// series contains per-series state. It is nil if aggregator.needSeries isn't set.
+	series map[string]*aggrSeries
+
+	// samples is the number of samples added to the group during the current interval.
+	samples       int
+	min           float64
+	max           float64
+	last          float64
+	lastTimestamp int64
+
+	// h is the histogram for sample values. It is nil if aggregator.needHistogram isn't set.
+	h *histogram.Fast
+
+	// idleFlushes is the number of flushes since the last sample added to the group.
+	idleFlushes int
+}
+
+// aggrSeries contains the state for a single input series.
+type aggrSeries struct {
+	// updated is set if the series received samples during the current interval.
+	updated bool
+
+	// value is the last sample value for the series.
+	value float64
+
+	// increase is the counter increase for the series during the current interval.
+	increase float64
+
+	// idleFlushes is the number of flushes since the last sample for the series.
+	idleFlushes int
+}
+
+func (ag *aggregator) push(at *auth.Token, labels []prompb.Label, timestamp int64, value float64) bool {
+	if !ag.match.Match(labels) {
+		return false
+	}
+	if math.IsNaN(value) {
+		// Skip Prometheus staleness marks and other NaNs, since they cannot be aggregated.
+		return true
+	}
+
+	ag.mu.Lock()
+	defer ag.mu.Unlock()
+
+	// Sort labels, so the keys don't depend on the order of labels in the input series.
+	var metricName []byte
+	lbs := ag.labelsBuf[:0]
+	for _, label := range labels {
+		if len(label.Value) == 0 {
+			continue
+		}
+		if len(label.Name) == 0 || string(label.Name) == "__name__" {
+			metricName = label.Value
+			continue
+		}
+		lbs = append(lbs, label)
+	}
+	sort.Slice(lbs, func(i, j int) bool {
+		return string(lbs[i].Name) < string(lbs[j].Name)
+	})
+	ag.labelsBuf = lbs
+
+	key := ag.keyBuf[:0]
+	key = encoding.MarshalUint32(key, at.AccountID)
+	key = encoding.MarshalUint32(key, at.ProjectID)
+	key = encoding.MarshalBytes(key, metricName)
+	for i := range lbs {
+		label := &lbs[i]
+		if ag.isGroupLabel(label.Name) {
+			key = encoding.MarshalBytes(key, label.Name)
+			key = encoding.MarshalBytes(key, label.Value)
+		}
+	}
+	groupKeyLen := len(key)
+	g := ag.groups[string(key)]
+	if g == nil {
+		g = ag.newGroup(at, metricName, lbs)
+		ag.groups[string(key)] = g
+	}
+	if ag.needSeries {
+		// The group key is already known, so the series key needs only the remaining labels.
+		for i := range lbs {
+			label := &lbs[i]
+			if !ag.isGroupLabel(label.Name) {
+				key = encoding.MarshalBytes(key, label.Name)
+				key = encoding.MarshalBytes(key, label.Value)
+			}
+		}
+		seriesKey := key[groupKeyLen:]
+		s := g.series[string(seriesKey)]
+		if s == nil {
+			s = &aggrSeries{
+				value: value,
+			}
+			g.series[string(seriesKey)] = s
+		} else {
+			d := value - s.value
+			if d < 0 {
+				// Counter reset.
+				d = value
+			}
+			s.increase += d
+			s.value = value
+		}
+		s.updated = true
+	}
+	ag.keyBuf = key
+
+	if g.samples == 0 || value < g.min {
+		g.min = value
+	}
+	if g.samples == 0 || value > g.max {
+		g.max = value
+	}
+	if g.samples == 0 || timestamp >= g.lastTimestamp {
+		g.last = value
+		g.lastTimestamp = timestamp
+	}
+	g.samples++
+	if g.h != nil {
+		g.h.Update(value)
+	}
+	return true
+}
+
+// isGroupLabel returns true if the label with the given name must be preserved in the output series.
+func (ag *aggregator) isGroupLabel(name []byte) bool {
+	if len(ag.by) > 0 {
+		return containsString(ag.by, name)
+	}
+	if len(ag.without) > 0 {
+		return !containsString(ag.without, name)
+	}
+	return true
+}
+
+func containsString(a []string, b []byte) bool {
+	for _, s := range a {
+		if s == string(b) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ag *aggregator) newGroup(at *auth.Token, metricName []byte, lbs []prompb.Label) *aggrGroup {
+	// Copy labels, since they may be reused by the caller.
+	var labels []prompb.Label
+	for i := range lbs {
+		label := &lbs[i]
+		if ag.isGroupLabel(label.Name) {
+			labels = append(labels, prompb.Label{
+				Name:  append([]byte{}, label.Name...),
+				Value: append([]byte{}, label.Value...),
+			})
+		}
+	}
+	g := &aggrGroup{
+		at:         *at,
+		metricName: string(metricName),
+		labels:     labels,
+	}
+	if ag.needSeries {
+		g.series = make(map[string]*aggrSeries)
+	}
+	if ag.needHistogram {
+		g.h = histogram.GetFast()
+	}
+	return g
+}
+
+func (ag *aggregator) flusher(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ag.stopCh:
+			ag.flush()
+			return
+		case <-t.C:
+			ag.flush()
+		}
+	}
+}
+
+func (ag *aggregator) mustStop() {
+	close(ag.stopCh)
+	ag.wg.Wait()
+}
+
+// flush passes the series aggregated during the current interval to pushFunc and starts new interval.
+func (ag *aggregator) flush() {
+	tssByTenant := make(map[auth.Token][]prompb.TimeSeries)
+
+	ag.mu.Lock()
+	currentTime := time.Now()
+	d := currentTime.Sub(ag.lastFlushTime).Seconds()
+	ag.lastFlushTime = currentTime
+	timestamp := currentTime.UnixNano() / 1e6
+	for key, g := range ag.groups {
+		if g.samples == 0 {
+			g.idleFlushes++
+			if g.idleFlushes >= ag.stalenessFlushes {
+				// Delete groups without samples during staleness_interval in order to free up memory.
+				if g.h != nil {
+					histogram.PutFast(g.h)
+				}
+				delete(ag.groups, key)
+				continue
+			}
+			// Keep the per-series state for the idle group, so rate continuity is preserved
+			// when the series resume receiving samples.
+			g.reset(ag.stalenessFlushes)
+			continue
+		}
+		g.idleFlushes = 0
+		tssByTenant[g.at] = ag.appendGroupSeries(tssByTenant[g.at], g, d, timestamp)
+		g.reset(ag.stalenessFlushes)
+	}
+	ag.mu.Unlock()
+
+	for at, tss := range tssByTenant {
+		at := at
+		ag.pushFunc(&at, tss)
+	}
+}
+
+func (ag *aggregator) appendGroupSeries(dst []prompb.TimeSeries, g *aggrGroup, d float64, timestamp int64) []prompb.TimeSeries {
+	for _, out := range ag.outputs {
+		metricName := g.metricName + ag.suffix + out.name
+		switch out.kind {
+		case outputSum:
+			sum := float64(0)
+			for _, s := range g.series {
+				if s.updated {
+					sum += s.value
+				}
+			}
+			dst = appendSeries(dst, metricName, g.labels, "", "", timestamp, sum)
+		case outputCount:
+			count := 0
+			for _, s := range g.series {
+				if s.updated {
+					count++
+				}
+			}
+			dst = appendSeries(dst, metricName, g.labels, "", "", timestamp, float64(count))
+		case outputMin:
+			dst = appendSeries(dst, metricName, g.labels, "", "", timestamp, g.min)
+		case outputMax:
+			dst = appendSeries(dst, metricName, g.labels, "", "", timestamp, g.max)
+		case outputLast:
+			dst = appendSeries(dst, metricName, g.labels, "", "", timestamp, g.last)
+		case outputRate:
+			increase := float64(0)
+			for _, s := range g.series {
+				increase += s.increase
+			}
+			rate := float64(0)
+			if d > 0 {
+				rate = increase / d
+			}
+			dst = appendSeries(dst, metricName, g.labels, "", "", timestamp, rate)
+		case outputQuantiles:
+			for i, phi := range out.phis {
+				dst = appendSeries(dst, metricName, g.labels, "quantile", out.phiStrs[i], timestamp, g.h.Quantile(phi))
+			}
+		default:
+			panic(fmt.Errorf("BUG: unexpected output kind %d", out.kind))
+		}
+	}
+	return dst
+}
+
+func appendSeries(dst []prompb.TimeSeries, metricName string, labels []prompb.Label, extraName, extraValue string, timestamp int64, value float64) []prompb.TimeSeries {
+	// Allocate new labels for each series, since the labels may be modified by pushFunc.
+	lbs := make([]prompb.Label, 0, len(labels)+2)
+	lbs = append(lbs, prompb.Label{
+		Name:  []byte("__name__"),
+		Value: []byte(metricName),
+	})
+	lbs = append(lbs, labels...)
+	if len(extraName) > 0 {
+		lbs = append(lbs, prompb.Label{
+			Name:  []byte(extraName),
+			Value: []byte(extraValue),
+		})
+	}
+	return append(dst, prompb.TimeSeries{
+		Labels: lbs,
+		Samples: []prompb.Sample{{
+			Value:     value,
+			Timestamp: timestamp,
+		}},
+	})
+}
+
+func (g *aggrGroup) reset(stalenessFlushes int) {
+	g.samples = 0
+	if g.h != nil {
+		g.h.Reset()
+	}
+	for key, s := range g.series {
+		if s.updated {
+			s.idleFlushes = 0
+		} else {
+			s.idleFlushes++
+			if s.idleFlushes >= stalenessFlushes {
+				// Delete series without samples during staleness_interval in order to free up memory.
+				delete(g.series, key)
+				continue
+			}
+		}
+		s.updated = false
+		s.increase = 0
+	}
+}
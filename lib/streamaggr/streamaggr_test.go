@@ -0,0 +1,408 @@
+package streamaggr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+)
+
+func TestNewAggregatorsFromDataFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		a, err := NewAggregatorsFromData([]byte(data), func(at *auth.Token, tss []prompb.TimeSeries) {})
+		if err == nil {
+			a.MustStop()
+			t.Fatalf("expecting non-nil error for config\n%s", data)
+		}
+	}
+
+	// Invalid yaml
+	f(`foobar`)
+
+	// Unknown option
+	f(`
+- match: foo
+  interval: 1m
+  outputs: [sum]
+  foo: bar
+`)
+
+	// Missing match
+	f(`
+- interval: 1m
+  outputs: [sum]
+`)
+
+	// Invalid match
+	f(`
+- match: "foo{"
+  interval: 1m
+  outputs: [sum]
+`)
+
+	// Missing interval
+	f(`
+- match: foo
+  outputs: [sum]
+`)
+
+	// Invalid interval
+	f(`
+- match: foo
+  interval: foo
+  outputs: [sum]
+`)
+
+	// Too small interval
+	f(`
+- match: foo
+  interval: 10ms
+  outputs: [sum]
+`)
+
+	// Invalid staleness_interval
+	f(`
+- match: foo
+  interval: 1m
+  staleness_interval: foo
+  outputs: [sum]
+`)
+
+	// Too small staleness_interval
+	f(`
+- match: foo
+  interval: 1m
+  staleness_interval: 30s
+  outputs: [sum]
+`)
+
+	// by and without at the same time
+	f(`
+- match: foo
+  interval: 1m
+  by: [job]
+  without: [instance]
+  outputs: [sum]
+`)
+
+	// Missing outputs
+	f(`
+- match: foo
+  interval: 1m
+`)
+
+	// Unsupported output
+	f(`
+- match: foo
+  interval: 1m
+  outputs: [avg]
+`)
+
+	// Invalid quantiles
+	f(`
+- match: foo
+  interval: 1m
+  outputs: ["quantiles(foo)"]
+`)
+	f(`
+- match: foo
+  interval: 1m
+  outputs: ["quantiles(1.5)"]
+`)
+
+	// Duplicate outputs
+	f(`
+- match: foo
+  interval: 1m
+  outputs: [sum, sum]
+`)
+}
+
+func TestAggregatorsPush(t *testing.T) {
+	f := func(config, inputs, outputsExpected string, matchedExpected bool) {
+		t.Helper()
+
+		var mu sync.Mutex
+		var outputs []string
+		pushFunc := func(at *auth.Token, tss []prompb.TimeSeries) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, ts := range tss {
+				outputs = append(outputs, fmt.Sprintf("%s %s %v", at, labelsString(ts.Labels), ts.Samples[0].Value))
+			}
+		}
+		a, err := NewAggregatorsFromData([]byte(config), pushFunc)
+		if err != nil {
+			t.Fatalf("cannot initialize aggregators: %s", err)
+		}
+
+		matched := false
+		at := &auth.Token{AccountID: 1, ProjectID: 2}
+		for i, line := range strings.Split(strings.TrimSpace(inputs), "\n") {
+			labels, value := parseTestSample(t, line)
+			if a.Push(at, labels, int64(i), value) {
+				matched = true
+			}
+		}
+		if matched != matchedExpected {
+			t.Fatalf("unexpected matched result; got %v; want %v", matched, matchedExpected)
+		}
+
+		// MustStop flushes the aggregated data.
+		a.MustStop()
+		sort.Strings(outputs)
+		result := strings.Join(outputs, "\n")
+		if result != strings.TrimSpace(outputsExpected) {
+			t.Fatalf("unexpected outputs\ngot\n%s\nwant\n%s", result, outputsExpected)
+		}
+	}
+
+	// Non-matching input
+	f(`
+- match: foo
+  interval: 1m
+  outputs: [count]
+`, `
+bar{job="x"} 1
+`, ``, false)
+
+	// Aggregation by labels
+	f(`
+- match: 'http_requests{env="prod"}'
+  interval: 1m
+  by: [service]
+  outputs: [sum, count, min, max, last]
+`, `
+http_requests{env="prod",service="a",pod="1"} 1
+http_requests{env="prod",service="a",pod="2"} 3
+http_requests{env="prod",service="a",pod="1"} 5
+http_requests{env="prod",service="b",pod="1"} 10
+http_requests{env="dev",service="b",pod="2"} 20
+`, `
+1:2 {__name__="http_requests:1m_by_service_count",service="a"} 2
+1:2 {__name__="http_requests:1m_by_service_count",service="b"} 1
+1:2 {__name__="http_requests:1m_by_service_last",service="a"} 5
+1:2 {__name__="http_requests:1m_by_service_last",service="b"} 10
+1:2 {__name__="http_requests:1m_by_service_max",service="a"} 5
+1:2 {__name__="http_requests:1m_by_service_max",service="b"} 10
+1:2 {__name__="http_requests:1m_by_service_min",service="a"} 1
+1:2 {__name__="http_requests:1m_by_service_min",service="b"} 10
+1:2 {__name__="http_requests:1m_by_service_sum",service="a"} 8
+1:2 {__name__="http_requests:1m_by_service_sum",service="b"} 10
+`, true)
+
+	// Aggregation without labels; the order of input labels mustn't matter.
+	f(`
+- match: '{__name__=~"foo|bar"}'
+  interval: 5m
+  without: [pod, instance]
+  outputs: [count]
+`, `
+foo{job="a",pod="1"} 1
+foo{pod="2",job="a"} 1
+bar{instance="x",job="b",path="/"} 1
+`, `
+1:2 {__name__="bar:5m_without_instance_pod_count",job="b",path="/"} 1
+1:2 {__name__="foo:5m_without_instance_pod_count",job="a"} 2
+`, true)
+
+	// Quantiles
+	f(`
+- match: foo
+  interval: 1m
+  by: [job]
+  outputs: ["quantiles(0, 0.5, 1)"]
+`, `
+foo{job="a",pod="1"} 1
+foo{job="a",pod="2"} 2
+foo{job="a",pod="3"} 3
+`, `
+1:2 {__name__="foo:1m_by_job_quantiles",job="a",quantile="0"} 1
+1:2 {__name__="foo:1m_by_job_quantiles",job="a",quantile="0.5"} 2
+1:2 {__name__="foo:1m_by_job_quantiles",job="a",quantile="1"} 3
+`, true)
+
+	// Multiple rules
+	f(`
+- match: foo
+  interval: 1m
+  outputs: [last]
+- match: '{job="a"}'
+  interval: 1h
+  by: [job]
+  outputs: [max]
+`, `
+foo{job="a"} 1
+foo{job="a"} 2
+bar{job="a"} 5
+`, `
+1:2 {__name__="bar:1h_by_job_max",job="a"} 5
+1:2 {__name__="foo:1h_by_job_max",job="a"} 2
+1:2 {__name__="foo:1m_last",job="a"} 2
+`, true)
+}
+
+func TestAggregatorsRate(t *testing.T) {
+	var mu sync.Mutex
+	var values []float64
+	pushFunc := func(at *auth.Token, tss []prompb.TimeSeries) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, ts := range tss {
+			values = append(values, ts.Samples[0].Value)
+		}
+	}
+	a, err := NewAggregatorsFromData([]byte(`
+- match: foo
+  interval: 1h
+  by: [job]
+  outputs: [rate]
+`), pushFunc)
+	if err != nil {
+		t.Fatalf("cannot initialize aggregators: %s", err)
+	}
+	defer a.MustStop()
+
+	push := func(pod string, value float64) {
+		labels := []prompb.Label{
+			{Value: []byte("foo")},
+			{Name: []byte("job"), Value: []byte("a")},
+			{Name: []byte("pod"), Value: []byte(pod)},
+		}
+		a.Push(auth.DefaultToken, labels, 0, value)
+	}
+	push("1", 10)
+	push("1", 15)
+	push("2", 100)
+	// Counter reset
+	push("2", 3)
+
+	// Pretend the previous flush was 10 seconds ago.
+	ag := a.as[0]
+	ag.mu.Lock()
+	ag.lastFlushTime = time.Now().Add(-10 * time.Second)
+	ag.mu.Unlock()
+	ag.flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(values) != 1 {
+		t.Fatalf("unexpected number of values; got %d; want 1", len(values))
+	}
+	// The increase is 5 for pod 1 and 3 for pod 2 after the counter reset.
+	if values[0] < 0.79 || values[0] > 0.8 {
+		t.Fatalf("unexpected rate; got %v; want 0.8", values[0])
+	}
+}
+
+func TestAggregatorsStaleness(t *testing.T) {
+	var mu sync.Mutex
+	var values []float64
+	pushFunc := func(at *auth.Token, tss []prompb.TimeSeries) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, ts := range tss {
+			values = append(values, ts.Samples[0].Value)
+		}
+	}
+	a, err := NewAggregatorsFromData([]byte(`
+- match: foo
+  interval: 1h
+  staleness_interval: 2h
+  outputs: [rate]
+`), pushFunc)
+	if err != nil {
+		t.Fatalf("cannot initialize aggregators: %s", err)
+	}
+	defer a.MustStop()
+
+	ag := a.as[0]
+	push := func(value float64) {
+		labels := []prompb.Label{
+			{Value: []byte("foo")},
+			{Name: []byte("pod"), Value: []byte("1")},
+		}
+		a.Push(auth.DefaultToken, labels, 0, value)
+	}
+	flush := func() {
+		// Pretend the previous flush was 10 seconds ago.
+		ag.mu.Lock()
+		ag.lastFlushTime = time.Now().Add(-10 * time.Second)
+		ag.mu.Unlock()
+		ag.flush()
+	}
+	groupsCount := func() int {
+		ag.mu.Lock()
+		defer ag.mu.Unlock()
+		return len(ag.groups)
+	}
+
+	push(10)
+	flush()
+
+	// The state must be kept for the series without samples during a single interval.
+	flush()
+	if n := groupsCount(); n != 1 {
+		t.Fatalf("unexpected number of groups after an idle interval; got %d; want 1", n)
+	}
+	push(30)
+	flush()
+
+	// The state must be dropped after staleness_interval without samples.
+	flush()
+	flush()
+	if n := groupsCount(); n != 0 {
+		t.Fatalf("unexpected number of groups after staleness_interval; got %d; want 0", n)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(values) != 2 {
+		t.Fatalf("unexpected number of values; got %d; want 2", len(values))
+	}
+	if values[0] != 0 {
+		t.Fatalf("unexpected rate for the first interval; got %v; want 0", values[0])
+	}
+	// The increase since the last sample before the idle interval is 20.
+	if values[1] < 1.99 || values[1] > 2 {
+		t.Fatalf("unexpected rate after the idle interval; got %v; want 2", values[1])
+	}
+}
+
+func parseTestSample(t *testing.T, line string) ([]prompb.Label, float64) {
+	t.Helper()
+	line = strings.TrimSpace(line)
+	n := strings.LastIndexByte(line, ' ')
+	var value float64
+	if _, err := fmt.Sscanf(line[n+1:], "%g", &value); err != nil {
+		t.Fatalf("cannot parse value from %q: %s", line, err)
+	}
+	series := line[:n]
+	n = strings.IndexByte(series, '{')
+	labels := []prompb.Label{{
+		Value: []byte(series[:n]),
+	}}
+	for _, kv := range strings.Split(series[n+1:len(series)-1], ",") {
+		tmp := strings.SplitN(kv, "=", 2)
+		labels = append(labels, prompb.Label{
+			Name:  []byte(tmp[0]),
+			Value: []byte(strings.Trim(tmp[1], `"`)),
+		})
+	}
+	return labels, value
+}
+
+func labelsString(labels []prompb.Label) string {
+	a := make([]string, 0, len(labels))
+	for _, label := range labels {
+		a = append(a, fmt.Sprintf("%s=%q", label.Name, label.Value))
+	}
+	sort.Strings(a)
+	return "{" + strings.Join(a, ",") + "}"
+}